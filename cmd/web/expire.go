@@ -0,0 +1,97 @@
+package main
+
+import (
+	"time"
+)
+
+// Defaults for the unconfirmed-reservation expiry job, overridable with
+// RESERVATION_EXPIRY_INTERVAL and RESERVATION_TTL. Unconfirmed means still
+// pending, which every booking is until staff confirm it, so the job is off
+// unless an interval is set.
+const (
+	defaultExpiryInterval = time.Duration(0)
+	defaultReservationTTL = 24 * time.Hour
+)
+
+// Defaults for date holds taken during booking, overridable with HOLD_TTL and
+// HOLD_EXPIRY_INTERVAL. Holds are short, so the job runs often.
+const (
+	defaultHoldTTL            = 15 * time.Minute
+	defaultHoldExpiryInterval = time.Minute
+)
+
+// reservationExpirer is the part of repository.DatabaseRepo the expiry job
+// needs, so it can be exercised without a database.
+type reservationExpirer interface {
+	ExpireUnconfirmedReservations(olderThan time.Time) (int, error)
+}
+
+// holdExpirer is the part of repository.DatabaseRepo the hold expiry job
+// needs.
+type holdExpirer interface {
+	ExpireHolds(now time.Time) (int, error)
+}
+
+// expireUnconfirmed deletes reservations that have stayed pending for
+// longer than ttl as of now and returns how many were removed.
+func expireUnconfirmed(repo reservationExpirer, ttl time.Duration, now time.Time) (int, error) {
+	return repo.ExpireUnconfirmedReservations(now.Add(-ttl))
+}
+
+// startReservationExpiry runs expireUnconfirmed every interval in a
+// background goroutine, logging how many reservations expired, and returns a
+// function that stops it. now supplies the current time for each run. An
+// interval of zero or less disables the job.
+func startReservationExpiry(repo reservationExpirer, interval, ttl time.Duration, now func() time.Time) (stop func()) {
+	return every(interval, func() {
+		n, err := expireUnconfirmed(repo, ttl, now())
+		if err != nil {
+			errorLog.Printf("expiring unconfirmed reservations: %v", err)
+			return
+		}
+		if n > 0 {
+			infoLog.Printf("Expired %d unconfirmed reservation(s) older than %s", n, ttl)
+		}
+	})
+}
+
+// startHoldExpiry runs ExpireHolds every interval in a background goroutine,
+// logging how many holds lapsed, and returns a function that stops it. now
+// supplies the current time for each run. An interval of zero or less
+// disables the job.
+func startHoldExpiry(repo holdExpirer, interval time.Duration, now func() time.Time) (stop func()) {
+	return every(interval, func() {
+		n, err := repo.ExpireHolds(now())
+		if err != nil {
+			errorLog.Printf("expiring holds: %v", err)
+			return
+		}
+		if n > 0 {
+			infoLog.Printf("Released %d expired hold(s)", n)
+		}
+	})
+}
+
+// every calls job every interval in a background goroutine until the
+// returned function is called. An interval of zero or less starts nothing.
+func every(interval time.Duration, job func()) (stop func()) {
+	if interval <= 0 {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				job()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
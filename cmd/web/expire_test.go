@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeExpirer records the cutoff it is asked to expire before.
+type fakeExpirer struct {
+	olderThan time.Time
+	n         int
+	err       error
+}
+
+func (f *fakeExpirer) ExpireUnconfirmedReservations(olderThan time.Time) (int, error) {
+	f.olderThan = olderThan
+	return f.n, f.err
+}
+
+// TestExpireUnconfirmed verifies that the cutoff is the injected time minus
+// the TTL and that the repository's count and error are passed through.
+func TestExpireUnconfirmed(t *testing.T) {
+	now := time.Date(2050, time.January, 2, 12, 0, 0, 0, time.UTC)
+	repo := &fakeExpirer{n: 2}
+
+	n, err := expireUnconfirmed(repo, 24*time.Hour, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("expired: got %d, want 2", n)
+	}
+	if want := time.Date(2050, time.January, 1, 12, 0, 0, 0, time.UTC); !repo.olderThan.Equal(want) {
+		t.Fatalf("cutoff: got %v, want %v", repo.olderThan, want)
+	}
+
+	repo.err = errors.New("db down")
+	if _, err := expireUnconfirmed(repo, time.Hour, now); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+// fakeHoldExpirer reports ExpireHolds calls on calls, dropping any that
+// arrive while one is unread. It expires nothing, so the job doesn't log.
+type fakeHoldExpirer struct {
+	calls chan time.Time
+}
+
+func (f *fakeHoldExpirer) ExpireHolds(now time.Time) (int, error) {
+	select {
+	case f.calls <- now:
+	default:
+	}
+	return 0, nil
+}
+
+// TestStartHoldExpiry verifies that the hold job passes the injected time to
+// ExpireHolds on each tick and that a zero interval starts nothing.
+func TestStartHoldExpiry(t *testing.T) {
+	now := time.Date(2050, time.January, 2, 12, 0, 0, 0, time.UTC)
+	repo := &fakeHoldExpirer{calls: make(chan time.Time, 1)}
+
+	stop := startHoldExpiry(repo, time.Millisecond, func() time.Time { return now })
+	select {
+	case got := <-repo.calls:
+		if !got.Equal(now) {
+			t.Errorf("now: got %v, want %v", got, now)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ExpireHolds was not called")
+	}
+	stop()
+
+	idle := &fakeHoldExpirer{calls: make(chan time.Time, 1)}
+	startHoldExpiry(idle, 0, func() time.Time { return now })()
+	select {
+	case <-idle.calls:
+		t.Fatal("ExpireHolds called with the job disabled")
+	case <-time.After(20 * time.Millisecond):
+	}
+}
@@ -5,21 +5,32 @@
 package main
 
 import (
+	"context"
 	"encoding/gob"
+	"errors"
+	"flag"
 	"fmt"
+	"html/template"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
+	_ "time/tzdata" // DISPLAY_TIMEZONE must resolve on hosts without zoneinfo
 
 	"github.com/alexedwards/scs/v2"
+	"github.com/alexedwards/scs/v2/memstore"
 	"github.com/bensabler/milos-residence/internal/config"
 	"github.com/bensabler/milos-residence/internal/driver"
 	"github.com/bensabler/milos-residence/internal/handlers"
 	"github.com/bensabler/milos-residence/internal/helpers"
 	"github.com/bensabler/milos-residence/internal/models"
 	"github.com/bensabler/milos-residence/internal/render"
+	"golang.org/x/crypto/bcrypt"
 )
 
 // app holds the process-wide application configuration populated during startup.
@@ -54,6 +65,297 @@ func env(key, fallback string) string {
 	return fallback
 }
 
+// envDuration returns the environment variable key parsed as a time.Duration
+// (e.g., "90s", "1h"), or fallback if the variable is unset or malformed.
+//
+// Parameters:
+//   - key: environment variable name.
+//   - fallback: value returned when key is unset or cannot be parsed.
+//
+// Returns:
+//   - time.Duration: resolved value.
+func envDuration(key string, fallback time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Printf("invalid duration %q for %s; using %s", v, key, fallback)
+		return fallback
+	}
+	return d
+}
+
+// envInt returns the environment variable key parsed as an int, or fallback
+// if the variable is unset or not a valid integer.
+//
+// Parameters:
+//   - key: environment variable name.
+//   - fallback: value returned when key is unset or cannot be parsed.
+//
+// Returns:
+//   - int: resolved value.
+func envInt(key string, fallback int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Printf("invalid integer %q for %s; using %d", v, key, fallback)
+		return fallback
+	}
+	return n
+}
+
+// splitList splits a comma-separated value into trimmed, non-empty items.
+//
+// Parameters:
+//   - v: raw value such as "/admin, /user".
+//
+// Returns:
+//   - []string: the items in order; nil when v has none.
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// cookieConfig resolves the Secure and SameSite attributes shared by the
+// session and CSRF cookies from the environment.
+//
+// Behavior:
+//   - Secure is true in production, or when TRUST_PROXY or FORCE_SECURE_COOKIES
+//     is "true" (the app sits behind a TLS-terminating proxy and sees plain HTTP).
+//   - COOKIE_SAMESITE selects lax (default), strict, or none (case-insensitive).
+//   - SameSite=None always implies Secure, since browsers reject it otherwise.
+//
+// Parameters:
+//   - inProduction: whether the app runs with APP_ENV=prod.
+//
+// Returns:
+//   - bool: whether cookies should be marked Secure.
+//   - http.SameSite: the SameSite mode to apply.
+//   - error: non-nil if COOKIE_SAMESITE has an unrecognized value.
+func cookieConfig(inProduction bool) (bool, http.SameSite, error) {
+	secure := inProduction ||
+		env("TRUST_PROXY", "false") == "true" ||
+		env("FORCE_SECURE_COOKIES", "false") == "true"
+
+	var sameSite http.SameSite
+	switch v := strings.ToLower(env("COOKIE_SAMESITE", "lax")); v {
+	case "lax":
+		sameSite = http.SameSiteLaxMode
+	case "strict":
+		sameSite = http.SameSiteStrictMode
+	case "none":
+		sameSite = http.SameSiteNoneMode
+		secure = true
+	default:
+		return false, 0, fmt.Errorf("COOKIE_SAMESITE must be lax, strict or none, got %q", v)
+	}
+
+	return secure, sameSite, nil
+}
+
+// defaultPort is the listen port used when PORT is unset or invalid.
+const defaultPort = "8080"
+
+// Recognized APP_ENV values.
+const (
+	envDev  = "dev"
+	envProd = "prod"
+)
+
+// resolvePort validates a PORT value. An empty value selects defaultPort.
+//
+// Returns:
+//   - string: the port to listen on; defaultPort when v is invalid.
+//   - error: non-nil when v is not a number in 1-65535, so the caller can warn.
+func resolvePort(v string) (string, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return defaultPort, nil
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return defaultPort, fmt.Errorf("PORT must be a number, got %q", v)
+	}
+	if n < 1 || n > 65535 {
+		return defaultPort, fmt.Errorf("PORT must be between 1 and 65535, got %d", n)
+	}
+	return strconv.Itoa(n), nil
+}
+
+// shutdownTimeout bounds how long serve waits for in-flight requests after a
+// shutdown signal before closing their connections.
+const shutdownTimeout = 10 * time.Second
+
+// serve runs srv until ctx is cancelled, then shuts it down gracefully: the
+// listener closes at once and in-flight requests get up to grace to finish.
+// Handlers have all returned when serve does, so nothing queues mail after
+// the caller closes app.MailChan.
+//
+// Returns:
+//   - error: nil after a clean shutdown; the listen error if the server
+//     could not start, or the Shutdown error if grace ran out
+func serve(ctx context.Context, srv *http.Server, grace time.Duration) error {
+	errCh := make(chan error, 1)
+	go func() { errCh <- srv.ListenAndServe() }()
+
+	select {
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+	return srv.Shutdown(shutdownCtx)
+}
+
+// Server timeouts used when the SERVER_* variables are not configured. The
+// header timeout is short to shed slowloris clients; the write timeout leaves
+// room for the slowest admin pages.
+const (
+	defaultReadHeaderTimeout = 5 * time.Second
+	defaultReadTimeout       = 15 * time.Second
+	defaultWriteTimeout      = 30 * time.Second
+	defaultIdleTimeout       = 120 * time.Second
+)
+
+// newServer builds the HTTP server for addr and h with the timeouts from app,
+// using the defaults above for any that are zero or negative.
+//
+// Parameters:
+//   - addr: listen address, e.g. ":8080".
+//   - h: the root handler, normally routes(&app).
+//
+// Returns:
+//   - *http.Server: ready for ListenAndServe.
+func newServer(addr string, h http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           h,
+		ReadHeaderTimeout: orDefault(app.ServerReadHeaderTimeout, defaultReadHeaderTimeout),
+		ReadTimeout:       orDefault(app.ServerReadTimeout, defaultReadTimeout),
+		WriteTimeout:      orDefault(app.ServerWriteTimeout, defaultWriteTimeout),
+		IdleTimeout:       orDefault(app.ServerIdleTimeout, defaultIdleTimeout),
+	}
+}
+
+// orDefault returns d, or fallback when d is not positive.
+func orDefault(d, fallback time.Duration) time.Duration {
+	if d <= 0 {
+		return fallback
+	}
+	return d
+}
+
+// resolveAppEnv validates an APP_ENV value. An empty value selects dev.
+//
+// Returns:
+//   - string: "dev" or "prod"; "dev" when v is not recognized.
+//   - error: non-nil when v is neither, so the caller can warn.
+func resolveAppEnv(v string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "", envDev:
+		return envDev, nil
+	case envProd:
+		return envProd, nil
+	default:
+		return envDev, fmt.Errorf("APP_ENV must be %s or %s, got %q", envDev, envProd, v)
+	}
+}
+
+// resolveBaseURL validates a BASE_URL value. An empty value is allowed and
+// leaves absolute links to be built from each request's host.
+//
+// Returns:
+//   - string: the URL with surrounding space and trailing slashes removed.
+//   - error: non-nil when v is not an http or https URL with a host.
+func resolveBaseURL(v string) (string, error) {
+	v = strings.TrimRight(strings.TrimSpace(v), "/")
+	if v == "" {
+		return "", nil
+	}
+
+	u, err := url.Parse(v)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("BASE_URL must be an http or https URL such as https://example.com, got %q", v)
+	}
+	return v, nil
+}
+
+// sessionStore maps SESSION_STORE to an scs.Store.
+//
+// Accepted values:
+//   - "memory" (default): process-local store; sessions are lost on restart.
+//   - "postgres": the sessions table via driver.SessionStore, so sessions
+//     survive restarts and are shared across instances. Falls back to memory
+//     when no database pool is available.
+//
+// Returns an error for any other value.
+func sessionStore(kind string, db *driver.DB) (scs.Store, error) {
+	switch strings.ToLower(strings.TrimSpace(kind)) {
+	case "", "memory":
+		return memstore.New(), nil
+	case "postgres":
+		if db == nil || db.SQL == nil {
+			return memstore.New(), nil
+		}
+		return driver.NewSessionStore(db.SQL), nil
+	default:
+		return nil, fmt.Errorf("SESSION_STORE must be memory or postgres, got %q", kind)
+	}
+}
+
+// newSessionManager returns the application's session manager backed by
+// store, with cookie attributes taken from app.
+func newSessionManager(store scs.Store) *scs.SessionManager {
+	s := scs.New()
+	s.Store = store
+	s.Lifetime = 24 * time.Hour
+	s.Cookie.Persist = true
+	s.Cookie.SameSite = app.CookieSameSite
+	s.Cookie.Secure = app.CookieSecure
+	return s
+}
+
+// requiredTemplates are pages every deployment must be able to render. Their
+// absence from a cached template set means TemplateDir points at the wrong
+// place, which would otherwise only surface as "Template Not Found" per page.
+var requiredTemplates = []string{"home.page.tmpl"}
+
+// checkTemplateCache verifies that a cache used for every request (useCache
+// true) is non-empty and holds each of requiredTemplates. When useCache is
+// false templates are re-parsed per request, so nothing is checked.
+func checkTemplateCache(tc map[string]*template.Template, useCache bool) error {
+	if !useCache {
+		return nil
+	}
+	if len(tc) == 0 {
+		return fmt.Errorf("template cache is empty; check the templates directory")
+	}
+	for _, name := range requiredTemplates {
+		if _, ok := tc[name]; !ok {
+			return fmt.Errorf("template cache is missing %s; check the templates directory", name)
+		}
+	}
+	return nil
+}
+
 // buildDSN constructs a PostgreSQL DSN string from individual environment
 // variables. It supports an optional password and extra parameters.
 //
@@ -99,14 +401,32 @@ func buildDSN() string {
 }
 
 // main coordinates process lifecycle: initialize subsystems, start the mail
-// listener, build the HTTP server, and block on ListenAndServe. Fatal errors
-// cause process exit.
+// listener, build the HTTP server, and serve until SIGINT or SIGTERM (see
+// serve). Fatal errors cause process exit.
 //
 // Side effects:
 //   - Starts asynchronous mail listener.
+//   - Starts the unconfirmed-reservation expiry job.
 //   - Logs server address and environment on startup.
-//   - Defers database and mail channel cleanup.
+//   - Defers database and mail channel cleanup. The deferred calls run after
+//     the server has shut down, so queued mail is sent before exit, and also
+//     when the server fails; the non-zero exit comes last.
+//
+// With -seed, it inserts development data (see seed) and exits instead of
+// serving.
 func main() {
+	seedOnly := flag.Bool("seed", false, "insert development rooms, an admin user and sample reservations, then exit")
+	flag.Parse()
+
+	// Deferred first so it runs last: a failed server exits non-zero only
+	// after the other deferred cleanup has run.
+	exitCode := 0
+	defer func() {
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
+	}()
+
 	// Perform full bootstrap and retrieve the live DB wrapper.
 	db, err := run()
 	if err != nil {
@@ -114,27 +434,65 @@ func main() {
 	}
 	defer db.SQL.Close() // ensure pool closes on shutdown
 
-	// Close the mail channel after all senders are done.
-	defer close(app.MailChan)
+	if *seedOnly {
+		if app.InProduction {
+			errorLog.Println("refusing to seed a production database")
+			return
+		}
+		counts, err := seed(handlers.Repo.DB, env("SEED_ADMIN_EMAIL", "admin@milosresidence.com"), env("SEED_ADMIN_PASSWORD", "admin123"))
+		if err != nil {
+			errorLog.Println(err)
+			return
+		}
+		infoLog.Printf("Seed complete: %d rooms, %d users, %d reservations added\n", counts.Rooms, counts.Users, counts.Reservations)
+		return
+	}
+
+	// Start background email workers (non-blocking).
+	infoLog.Printf("Starting %d mail workers\n", app.MailWorkers)
+	waitMail := listenForMail(app.MailWorkers)
+
+	// Close the mail channel after all senders are done, then let the
+	// workers finish what is already queued.
+	defer func() {
+		close(app.MailChan)
+		waitMail()
+	}()
+
+	// Release dates held by reservations nobody confirmed (opt-in).
+	stopExpiry := startReservationExpiry(handlers.Repo.DB,
+		envDuration("RESERVATION_EXPIRY_INTERVAL", defaultExpiryInterval),
+		envDuration("RESERVATION_TTL", defaultReservationTTL),
+		time.Now)
+	defer stopExpiry()
 
-	// Start background email dispatcher (non-blocking).
-	fmt.Println("Starting mail listener...")
-	listenForMail()
+	// Release dates held for guests who chose a room but never booked.
+	stopHoldExpiry := startHoldExpiry(handlers.Repo.DB,
+		envDuration("HOLD_EXPIRY_INTERVAL", defaultHoldExpiryInterval),
+		time.Now)
+	defer stopHoldExpiry()
 
 	// Construct the HTTP server with resolved address and router.
-	addr := ":" + env("PORT", "8080")
-	srv := &http.Server{
-		Addr:    addr,
-		Handler: routes(&app),
+	port, err := resolvePort(os.Getenv("PORT"))
+	if err != nil {
+		infoLog.Printf("WARNING: %v; using port %s\n", err, port)
 	}
+	addr := ":" + port
+	srv := newServer(addr, routes(&app))
+
+	// Stop serving on Ctrl-C or a container stop.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	// Announce server start with environment context.
-	infoLog.Printf("HTTP server listening on %s (env=%s)\n", addr, env("APP_ENV", "dev"))
+	infoLog.Printf("HTTP server listening on %s (env=%s)\n", addr, app.Env)
 
-	// Serve until error or shutdown; ignore the normal ServerClosed signal.
-	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		errorLog.Fatal(err)
+	if err := serve(ctx, srv, shutdownTimeout); err != nil {
+		errorLog.Println(err)
+		exitCode = 1
+		return
 	}
+	infoLog.Println("HTTP server stopped; finishing queued mail")
 }
 
 // run performs application bootstrap and returns an initialized database handle.
@@ -154,14 +512,12 @@ func run() (*driver.DB, error) {
 	gob.Register(models.Room{})
 	gob.Register(models.RoomRestriction{})
 	gob.Register(map[string]int{})
+	gob.Register([]models.FlashMessage{})
 
 	// Initialize mail channel used by async sender.
 	mailChan := make(chan models.MailData)
 	app.MailChan = mailChan
 
-	// Determine production mode from environment.
-	app.InProduction = env("APP_ENV", "dev") == "prod"
-
 	// Configure loggers with appropriate prefixes and flags.
 	infoLog = log.New(os.Stdout, "INFO:\t", log.Ldate|log.Ltime)
 	app.InfoLog = infoLog
@@ -169,23 +525,152 @@ func run() (*driver.DB, error) {
 	errorLog = log.New(os.Stderr, "ERROR:\t", log.Ldate|log.Ltime|log.Lshortfile)
 	app.ErrorLog = errorLog
 
-	// Configure secure cookie-backed session manager.
-	session = scs.New()
-	session.Lifetime = 24 * time.Hour
-	session.Cookie.Persist = true
-	session.Cookie.SameSite = http.SameSiteLaxMode
-	session.Cookie.Secure = app.InProduction
-	app.Session = session
+	// Determine production mode from environment.
+	appEnv, err := resolveAppEnv(os.Getenv("APP_ENV"))
+	if err != nil {
+		infoLog.Printf("WARNING: %v; using %s\n", err, appEnv)
+	}
+	app.Env = appEnv
+	app.InProduction = appEnv == envProd
+
+	// Resolve the contact-form honeypot input name (rotatable without a deploy).
+	app.HoneypotField = env("HONEYPOT_FIELD", "website")
+
+	// Times quoted to guests in confirmations and the reservation summary.
+	app.CheckInTime = env("CHECK_IN_TIME", config.DefaultCheckInTime)
+	app.CheckOutTime = env("CHECK_OUT_TIME", config.DefaultCheckOutTime)
+
+	// Public address used for absolute links in emails and crawler files.
+	app.BaseURL, err = resolveBaseURL(os.Getenv("BASE_URL"))
+	if err != nil {
+		return nil, err
+	}
+	if app.BaseURL == "" {
+		infoLog.Println("WARNING: BASE_URL is not set; password reset and welcome emails will not be sent")
+	}
+
+	// Zone that stored UTC timestamps are displayed in.
+	app.DisplayLocation, err = time.LoadLocation(env("DISPLAY_TIMEZONE", "Local"))
+	if err != nil {
+		return nil, fmt.Errorf("DISPLAY_TIMEZONE must be an IANA time zone such as America/Chicago: %w", err)
+	}
+
+	// Terms guests must accept before booking.
+	app.TermsURL = env("TERMS_URL", "")
+
+	// Route contact-form messages to a staff address per topic.
+	app.ContactRecipients = map[string]string{
+		"booking": env("CONTACT_EMAIL_BOOKING", ""),
+		"billing": env("CONTACT_EMAIL_BILLING", ""),
+		"general": env("CONTACT_EMAIL_GENERAL", config.DefaultContactRecipient),
+	}
+
+	// Resolve browser cache lifetime for static assets.
+	app.StaticMaxAge = envDuration("STATIC_MAX_AGE", defaultStaticMaxAge)
+	app.FaviconPath = env("FAVICON_PATH", defaultFaviconPath)
+
+	// Resolve the HTTP server timeouts.
+	app.ServerReadHeaderTimeout = envDuration("SERVER_READ_HEADER_TIMEOUT", defaultReadHeaderTimeout)
+	app.ServerReadTimeout = envDuration("SERVER_READ_TIMEOUT", defaultReadTimeout)
+	app.ServerWriteTimeout = envDuration("SERVER_WRITE_TIMEOUT", defaultWriteTimeout)
+	app.ServerIdleTimeout = envDuration("SERVER_IDLE_TIMEOUT", defaultIdleTimeout)
+
+	// Resolve password hashing cost; bcrypt rejects values outside 4..31.
+	app.BcryptCost = envInt("BCRYPT_COST", 12)
+	if app.BcryptCost < bcrypt.MinCost || app.BcryptCost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("BCRYPT_COST must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+
+	// Resolve the request body size limit enforced by MaxBodyBytes.
+	app.MaxBodyBytes = int64(envInt("MAX_BODY_BYTES", defaultMaxBodyBytes))
+	if app.MaxBodyBytes <= 0 {
+		return nil, fmt.Errorf("MAX_BODY_BYTES must be positive")
+	}
+
+	// Resolve the robots.txt disallow list.
+	app.RobotsDisallow = splitList(env("ROBOTS_DISALLOW", "/admin,/user"))
+
+	// Resolve the origins allowed to call the JSON API cross-origin.
+	app.CORSAllowedOrigins = splitList(env("CORS_ALLOWED_ORIGINS", ""))
+
+	// Resolve the reverse proxies whose forwarding headers name the client.
+	app.TrustedProxies, err = helpers.ParseTrustedProxies(splitList(env("TRUSTED_PROXIES", "")))
+	if err != nil {
+		return nil, fmt.Errorf("TRUSTED_PROXIES: %w", err)
+	}
+
+	// Resolve the duration after which repository queries are logged as slow.
+	app.SlowQueryThreshold = envDuration("SLOW_QUERY_THRESHOLD", 500*time.Millisecond)
+
+	// Resolve how long password reset links stay valid.
+	app.PasswordResetTTL = envDuration("PASSWORD_RESET_TTL", time.Hour)
+	if app.PasswordResetTTL <= 0 {
+		return nil, fmt.Errorf("PASSWORD_RESET_TTL must be positive")
+	}
+
+	// Resolve how many emails may be sent at once.
+	app.MailWorkers = envInt("MAIL_WORKERS", 2)
+	if app.MailWorkers < 1 {
+		return nil, fmt.Errorf("MAIL_WORKERS must be at least 1")
+	}
+
+	// Resolve the property name shown in pages and emails.
+	app.PropertyName = env("PROPERTY_NAME", config.DefaultPropertyName)
+	app.HoldTTL = envDuration("HOLD_TTL", defaultHoldTTL)
+
+	// Record outbound email in the mail log unless turned off.
+	app.LogMail = env("MAIL_LOG", "true") == "true"
+
+	// Resolve the stay-length policy applied to quotes and reservations.
+	app.MinStayNights = envInt("MIN_STAY_NIGHTS", 1)
+	app.MaxStayNights = envInt("MAX_STAY_NIGHTS", 30)
+	if app.MinStayNights < 1 || app.MaxStayNights < app.MinStayNights {
+		return nil, fmt.Errorf("MIN_STAY_NIGHTS must be at least 1 and no greater than MAX_STAY_NIGHTS")
+	}
+
+	// Resolve how far ahead guests may book.
+	app.MaxAdvanceDays = envInt("MAX_ADVANCE_DAYS", 365)
+	if app.MaxAdvanceDays < 1 {
+		return nil, fmt.Errorf("MAX_ADVANCE_DAYS must be at least 1")
+	}
+
+	// Resolve how many rooms each page of availability results lists.
+	app.RoomsPerPage = envInt("ROOMS_PER_PAGE", 10)
+	if app.RoomsPerPage < 1 {
+		return nil, fmt.Errorf("ROOMS_PER_PAGE must be at least 1")
+	}
+
+	// Resolve how much notice a booking needs.
+	app.MinLeadDays = envInt("MIN_LEAD_DAYS", 0)
+	if app.MinLeadDays < 0 {
+		return nil, fmt.Errorf("MIN_LEAD_DAYS must not be negative")
+	}
+
+	// Resolve cookie attributes shared by the session and CSRF cookies.
+	secure, sameSite, err := cookieConfig(app.InProduction)
+	if err != nil {
+		return nil, err
+	}
+	app.CookieSecure = secure
+	app.CookieSameSite = sameSite
 
 	// Establish database connectivity.
 	infoLog.Println("Connecting to database...")
 	dsn := buildDSN()
-	db, err := driver.ConnectSQL(dsn)
+	db, err := driver.ConnectSQL(dsn, envInt("DB_CONNECT_RETRIES", driver.DefaultConnectRetries))
 	if err != nil {
 		return nil, fmt.Errorf("cannot connect to database: %s", err)
 	}
 	infoLog.Println("Connected to database")
 
+	// Configure the session manager; the store may need the database pool.
+	store, err := sessionStore(env("SESSION_STORE", "memory"), db)
+	if err != nil {
+		return nil, err
+	}
+	session = newSessionManager(store)
+	app.Session = session
+
 	// Build initial template cache.
 	tc, err := render.CreateTemplateCache()
 	if err != nil {
@@ -195,10 +680,20 @@ func run() (*driver.DB, error) {
 
 	// Toggle cache usage: typically true in production, false in development.
 	app.UseCache = env("USE_TEMPLATE_CACHE", "false") == "true"
+	if err := checkTemplateCache(tc, app.UseCache); err != nil {
+		return nil, err
+	}
 
 	// Wire repositories and package-level dependencies.
 	repo := handlers.NewRepo(&app, db)
 	handlers.NewHandlers(repo)
+
+	// Confirm the repository can reach the database before serving.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := repo.DB.Ping(ctx); err != nil {
+		return nil, fmt.Errorf("database health check failed: %w", err)
+	}
 	render.NewRenderer(&app)
 	helpers.NewHelpers(&app)
 
@@ -1,8 +1,18 @@
 // Command web tests cover startup/bootstrap routines for the web binary.
-// This file verifies that run() completes without returning an error.
+// This file verifies that run() completes without returning an error and that
+// environment-driven helpers resolve the expected settings.
 package main
 
-import "testing"
+import (
+	"context"
+	"database/sql"
+	"html/template"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bensabler/milos-residence/internal/driver"
+)
 
 // TestRun validates that run() performs application bootstrap successfully.
 // It expects no error on normal test initialization.
@@ -13,3 +23,268 @@ func TestRun(t *testing.T) {
 		t.Error("Failed run()")
 	}
 }
+
+// TestCookieConfig verifies the mapping from environment to the Secure and
+// SameSite attributes applied to the session and CSRF cookies.
+func TestCookieConfig(t *testing.T) {
+	tests := []struct {
+		name         string
+		inProduction bool
+		env          map[string]string
+		wantSecure   bool
+		wantSameSite http.SameSite
+		wantErr      bool
+	}{
+		{name: "development defaults", wantSecure: false, wantSameSite: http.SameSiteLaxMode},
+		{name: "production", inProduction: true, wantSecure: true, wantSameSite: http.SameSiteLaxMode},
+		{name: "trust proxy", env: map[string]string{"TRUST_PROXY": "true"}, wantSecure: true, wantSameSite: http.SameSiteLaxMode},
+		{name: "force secure cookies", env: map[string]string{"FORCE_SECURE_COOKIES": "true"}, wantSecure: true, wantSameSite: http.SameSiteLaxMode},
+		{name: "strict", env: map[string]string{"COOKIE_SAMESITE": "Strict"}, wantSecure: false, wantSameSite: http.SameSiteStrictMode},
+		{name: "none implies secure", env: map[string]string{"COOKIE_SAMESITE": "none"}, wantSecure: true, wantSameSite: http.SameSiteNoneMode},
+		{name: "invalid samesite", env: map[string]string{"COOKIE_SAMESITE": "sometimes"}, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, key := range []string{"TRUST_PROXY", "FORCE_SECURE_COOKIES", "COOKIE_SAMESITE"} {
+				t.Setenv(key, tc.env[key])
+			}
+
+			secure, sameSite, err := cookieConfig(tc.inProduction)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if secure != tc.wantSecure {
+				t.Errorf("secure: got %v, want %v", secure, tc.wantSecure)
+			}
+			if sameSite != tc.wantSameSite {
+				t.Errorf("sameSite: got %v, want %v", sameSite, tc.wantSameSite)
+			}
+		})
+	}
+}
+
+// TestSessionStore verifies the SESSION_STORE mapping: memory by default,
+// the PostgreSQL store when a pool is available, memory when it is not, and
+// an error for unknown values.
+func TestSessionStore(t *testing.T) {
+	pool := &driver.DB{SQL: &sql.DB{}}
+
+	tests := []struct {
+		name    string
+		kind    string
+		db      *driver.DB
+		wantPG  bool
+		wantErr bool
+	}{
+		{name: "default", kind: ""},
+		{name: "memory", kind: "memory", db: pool},
+		{name: "postgres", kind: "postgres", db: pool, wantPG: true},
+		{name: "postgres without database", kind: "postgres"},
+		{name: "unknown", kind: "redis", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			store, err := sessionStore(tc.kind, tc.db)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			pg, ok := store.(*driver.SessionStore)
+			if ok != tc.wantPG {
+				t.Fatalf("store: got %T, want postgres=%v", store, tc.wantPG)
+			}
+			if ok {
+				pg.StopCleanup()
+			}
+		})
+	}
+}
+
+// TestResolvePort verifies PORT validation: numeric ports in range are used,
+// and anything else falls back to the default with an error to log.
+func TestResolvePort(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "", want: defaultPort},
+		{in: "3000", want: "3000"},
+		{in: " 443 ", want: "443"},
+		{in: "65535", want: "65535"},
+		{in: "0", want: defaultPort, wantErr: true},
+		{in: "65536", want: defaultPort, wantErr: true},
+		{in: "-1", want: defaultPort, wantErr: true},
+		{in: "abc", want: defaultPort, wantErr: true},
+		{in: "80a", want: defaultPort, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			got, err := resolvePort(tc.in)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("error: got %v, wantErr %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("port: got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestNewServer verifies that newServer applies the configured timeouts and
+// falls back to the defaults for any left unset.
+func TestNewServer(t *testing.T) {
+	saved := app
+	defer func() { app = saved }()
+
+	h := http.NotFoundHandler()
+
+	app.ServerReadHeaderTimeout = 0
+	app.ServerReadTimeout = 0
+	app.ServerWriteTimeout = 0
+	app.ServerIdleTimeout = 0
+	srv := newServer(":8080", h)
+	if srv.Addr != ":8080" || srv.Handler == nil {
+		t.Fatalf("server: got addr %q handler %v", srv.Addr, srv.Handler)
+	}
+	if srv.ReadHeaderTimeout != defaultReadHeaderTimeout || srv.ReadTimeout != defaultReadTimeout ||
+		srv.WriteTimeout != defaultWriteTimeout || srv.IdleTimeout != defaultIdleTimeout {
+		t.Errorf("defaults: got %v/%v/%v/%v", srv.ReadHeaderTimeout, srv.ReadTimeout, srv.WriteTimeout, srv.IdleTimeout)
+	}
+
+	app.ServerReadHeaderTimeout = 2 * time.Second
+	app.ServerReadTimeout = 10 * time.Second
+	app.ServerWriteTimeout = time.Minute
+	app.ServerIdleTimeout = 5 * time.Minute
+	srv = newServer(":8080", h)
+	if srv.ReadHeaderTimeout != 2*time.Second || srv.ReadTimeout != 10*time.Second ||
+		srv.WriteTimeout != time.Minute || srv.IdleTimeout != 5*time.Minute {
+		t.Errorf("configured: got %v/%v/%v/%v", srv.ReadHeaderTimeout, srv.ReadTimeout, srv.WriteTimeout, srv.IdleTimeout)
+	}
+}
+
+// TestServe verifies that serve shuts the server down cleanly once its
+// context is cancelled, and reports a listen failure.
+func TestServe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- serve(ctx, newServer("127.0.0.1:0", http.NotFoundHandler()), time.Second) }()
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("shutdown: got %v, want nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve did not return after cancel")
+	}
+
+	if err := serve(context.Background(), newServer("127.0.0.1:-1", http.NotFoundHandler()), time.Second); err == nil {
+		t.Error("expected an error for an invalid address")
+	}
+}
+
+// TestResolveAppEnv verifies APP_ENV validation: dev and prod are accepted
+// case-insensitively and anything else falls back to dev with an error.
+func TestResolveAppEnv(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "", want: envDev},
+		{in: "dev", want: envDev},
+		{in: "PROD", want: envProd},
+		{in: "production", want: envDev, wantErr: true},
+		{in: "staging", want: envDev, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			got, err := resolveAppEnv(tc.in)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("error: got %v, wantErr %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("env: got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestResolveBaseURL verifies BASE_URL validation: empty is allowed, trailing
+// slashes are dropped, and anything but an http(s) URL with a host is rejected.
+func TestResolveBaseURL(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "", want: ""},
+		{in: "https://example.com", want: "https://example.com"},
+		{in: " https://example.com/ ", want: "https://example.com"},
+		{in: "http://localhost:8080/milo/", want: "http://localhost:8080/milo"},
+		{in: "example.com", wantErr: true},
+		{in: "ftp://example.com", wantErr: true},
+		{in: "https://", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			got, err := resolveBaseURL(tc.in)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("error: got %v, wantErr %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("base URL: got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestCheckTemplateCache verifies that a cache in use must be non-empty and
+// contain the required pages, while an unused cache is never rejected.
+func TestCheckTemplateCache(t *testing.T) {
+	populated := map[string]*template.Template{
+		"home.page.tmpl":  template.New("home.page.tmpl"),
+		"about.page.tmpl": template.New("about.page.tmpl"),
+	}
+	missingHome := map[string]*template.Template{"about.page.tmpl": template.New("about.page.tmpl")}
+
+	tests := []struct {
+		name     string
+		tc       map[string]*template.Template
+		useCache bool
+		wantErr  bool
+	}{
+		{name: "populated", tc: populated, useCache: true},
+		{name: "empty", tc: map[string]*template.Template{}, useCache: true, wantErr: true},
+		{name: "nil", useCache: true, wantErr: true},
+		{name: "missing home", tc: missingHome, useCache: true, wantErr: true},
+		{name: "empty but unused", tc: map[string]*template.Template{}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkTemplateCache(tc.tc, tc.useCache)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("error: got %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
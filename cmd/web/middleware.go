@@ -1,12 +1,18 @@
 // Command web defines HTTP middleware used by the application binary.
 // It provides CSRF protection (NoSurf), session load/save (SessionLoad),
-// and an authentication gate for admin routes (Auth).
+// an authentication gate for admin routes (Auth), HTTPS enforcement
+// behind a TLS-terminating proxy (RequireHTTPS), a request body size
+// limit (MaxBodyBytes), cross-origin access to the JSON API (CORS), and
+// canonical URL redirects (RedirectSlashes, LowercasePaths).
 package main
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/bensabler/milos-residence/internal/helpers"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/justinas/nosurf"
 )
 
@@ -21,20 +27,30 @@ import (
 //   - http.Handler: a handler that validates CSRF tokens on incoming requests.
 //
 // Notes:
-//   - Cookie.Secure is bound to app.InProduction to avoid HTTPS-only cookies
-//     in local development.
-//   - SameSite Lax is a safe default that defends most CSRF vectors while
-//     keeping top-level POST redirects functional.
+//   - Cookie.Secure follows app.CookieSecure: on in production or when forced
+//     behind a TLS-terminating proxy, off in local development.
+//   - SameSite follows app.CookieSameSite; Lax is the default and defends most
+//     CSRF vectors while keeping top-level POST redirects functional.
 func NoSurf(next http.Handler) http.Handler {
 	// Wrap the next handler with nosurf’s token generation/verification.
 	csrfHandler := nosurf.New(next)
 
+	// The /api group is called cross-origin without a CSRF token, but only
+	// where another site can't forge the request: reads, form posts to
+	// read-only endpoints, and JSON bodies (see helpers.CSRFExempt). Some of
+	// these handlers do use the session: GET /api/session/status reads it to
+	// report the login and hands out this middleware's token, and POST
+	// /api/reservations writes to the database. Neither is exposed to other
+	// sites, since CORS never allows credentials, so a cross-origin caller
+	// can't read a session-backed answer.
+	csrfHandler.ExemptFunc(helpers.CSRFExempt)
+
 	// Establish cookie policy for the CSRF base cookie.
 	csrfHandler.SetBaseCookie(http.Cookie{
-		HttpOnly: true,                 // prevent JavaScript access
-		Path:     "/",                  // send with all requests
-		Secure:   app.InProduction,     // HTTPS-only in production
-		SameSite: http.SameSiteLaxMode, // sensible CSRF default
+		HttpOnly: true,               // prevent JavaScript access
+		Path:     "/",                // send with all requests
+		Secure:   app.CookieSecure,   // HTTPS-only in production or behind a TLS proxy
+		SameSite: app.CookieSameSite, // configurable, Lax by default
 	})
 
 	return csrfHandler
@@ -56,7 +72,9 @@ func SessionLoad(next http.Handler) http.Handler {
 
 // Auth enforces that the caller is authenticated (has "user_id" in session)
 // before allowing access to protected routes. Unauthenticated users are
-// redirected to the login page with a one-time error message.
+// redirected to the login page with a one-time error message. The gate itself
+// is helpers.RequireAuth wired to the application session, which the handler
+// tests reuse with their own session.
 //
 // Parameters:
 //   - next: the protected handler to run after authentication succeeds.
@@ -68,18 +86,203 @@ func SessionLoad(next http.Handler) http.Handler {
 //   - Sets a session error flash message: "Log in first!"
 //   - Issues an HTTP 303 See Other redirect on failure.
 func Auth(next http.Handler) http.Handler {
+	return helpers.RequireAuth(session)(next)
+}
+
+// hstsValue is the Strict-Transport-Security policy sent in production:
+// two years, covering subdomains.
+const hstsValue = "max-age=63072000; includeSubDomains"
+
+// RequireHTTPS redirects plain-HTTP requests to HTTPS and sets HSTS when the
+// application runs in production. TLS is expected to terminate at a proxy, so
+// the original scheme is read from the X-Forwarded-Proto header.
+//
+// Parameters:
+//   - next: the next http.Handler in the chain.
+//
+// Returns:
+//   - http.Handler: a handler that enforces HTTPS in production and is a
+//     pass-through in development.
+//
+// Side effects:
+//   - Issues an HTTP 308 Permanent Redirect (method and body preserved) when
+//     X-Forwarded-Proto is "http".
+//   - Sets the Strict-Transport-Security header on HTTPS responses.
+func RequireHTTPS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Gate access based on session authentication marker.
-		if !helpers.IsAuthenticated(r) {
-			// Let the UI show a concise reason for the redirect.
-			session.Put(r.Context(), "error", "Log in first!")
+		// Local development runs over plain HTTP; leave requests untouched.
+		if !app.InProduction {
+			next.ServeHTTP(w, r)
+			return
+		}
 
-			// Redirect to the login page using a safe 303 response.
-			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		// Bounce plain-HTTP requests to the same URL on the https scheme.
+		if r.Header.Get("X-Forwarded-Proto") == "http" {
+			target := "https://" + r.Host + r.URL.RequestURI()
+			http.Redirect(w, r, target, http.StatusPermanentRedirect)
 			return
 		}
 
-		// Forward to the protected handler when authenticated.
+		// Tell browsers to use HTTPS for all future requests.
+		w.Header().Set("Strict-Transport-Security", hstsValue)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// defaultMaxBodyBytes is the request body limit (1MB) used when MAX_BODY_BYTES
+// is not configured. It comfortably fits every form the site accepts.
+const defaultMaxBodyBytes = 1 << 20
+
+// MaxBodyBytes returns middleware that caps request bodies at n bytes using
+// http.MaxBytesReader. Reads past the limit fail with *http.MaxBytesError,
+// which handlers turn into 413 responses via helpers.BodyTooLarge.
+//
+// Parameters:
+//   - n: maximum number of body bytes a request may send.
+//
+// Returns:
+//   - func(http.Handler) http.Handler: middleware suitable for mux.Use.
+//
+// Notes:
+//   - A declared Content-Length above n is rejected with 413 immediately. This
+//     must run before NoSurf, which parses the form to find the CSRF token and
+//     would otherwise report an oversized body as a token failure.
+//   - Requests without a body (GET, HEAD) are passed through untouched.
+func MaxBodyBytes(n int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Fail fast when the client tells us the body is too large.
+			if r.ContentLength > n {
+				http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+				return
+			}
+
+			// Bound the body so ParseForm cannot buffer arbitrarily large input.
+			if r.Body != nil && r.Body != http.NoBody {
+				r.Body = http.MaxBytesReader(w, r.Body, n)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// corsAllowMethods and corsAllowHeaders are advertised in preflight responses
+// for the JSON API.
+const (
+	corsAllowMethods = "GET, POST, OPTIONS"
+	corsAllowHeaders = "Accept, Content-Type, Idempotency-Key"
+)
+
+// CORS returns middleware that lets pages on allowedOrigins call the wrapped
+// routes from the browser. A request whose Origin header exactly matches an
+// entry gets Access-Control-Allow-Origin echoing that origin; other origins
+// get no CORS headers, so the browser blocks them.
+//
+// Parameters:
+//   - allowedOrigins: scheme://host[:port] origins (CORS_ALLOWED_ORIGINS).
+//
+// Returns:
+//   - func(http.Handler) http.Handler: middleware suitable for mux.Use.
+//
+// Notes:
+//   - Preflight requests (OPTIONS with Access-Control-Request-Method) are
+//     answered with 204 and never reach the wrapped handler.
+//   - Vary: Origin is always set so caches do not share one origin's answer
+//     with another.
+//   - Access-Control-Allow-Credentials is never sent, so browsers don't let
+//     other origins read responses made with the visitor's cookies, such as
+//     the CSRF token from /api/session/status.
+func CORS(allowedOrigins []string) func(http.Handler) http.Handler {
+	allowed := make(map[string]bool, len(allowedOrigins))
+	for _, o := range allowedOrigins {
+		allowed[strings.TrimRight(o, "/")] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Origin")
+
+			origin := r.Header.Get("Origin")
+			if origin != "" && allowed[origin] {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+
+			// Answer preflight here; allowed origins also learn what they may send.
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				if allowed[origin] {
+					w.Header().Set("Access-Control-Allow-Methods", corsAllowMethods)
+					w.Header().Set("Access-Control-Allow-Headers", corsAllowHeaders)
+					w.Header().Set("Access-Control-Max-Age", "600")
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// canonicalRedirect reports whether r may be redirected to a canonical URL.
+// Only GET and HEAD qualify, since a redirect turns a form POST into a GET
+// and drops its body. /static/ is left alone: http.FileServer redirects
+// directories to a trailing slash and file names there are case-sensitive.
+func canonicalRedirect(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	return !strings.HasPrefix(r.URL.Path, "/static/")
+}
+
+// RedirectSlashes redirects GET and HEAD requests for a path ending in a
+// slash (other than "/") to the same path without it, so "/about/" reaches
+// "/about". It wraps chi's middleware.RedirectSlashes, which answers 301 and
+// keeps the query string.
+//
+// Parameters:
+//   - next: the next http.Handler in the chain.
+//
+// Returns:
+//   - http.Handler: a handler that redirects slash variants and passes every
+//     other request through.
+func RedirectSlashes(next http.Handler) http.Handler {
+	redirect := middleware.RedirectSlashes(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if canonicalRedirect(r) {
+			redirect.ServeHTTP(w, r)
+			return
+		}
 		next.ServeHTTP(w, r)
 	})
 }
+
+// LowercasePaths returns middleware that redirects (301) a GET or HEAD
+// request whose path matches no route in routes to the lowercase path when
+// that one does, so "/About" reaches "/about". Paths that already match are
+// never rewritten, which keeps mixed-case URL parameters working.
+//
+// Parameters:
+//   - routes: the router the middleware is installed on, used to check
+//     which form of the path resolves.
+//
+// Returns:
+//   - func(http.Handler) http.Handler: middleware suitable for mux.Use.
+func LowercasePaths(routes chi.Routes) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path := r.URL.Path
+			lower := strings.ToLower(path)
+			if lower == path || !canonicalRedirect(r) ||
+				routes.Match(chi.NewRouteContext(), r.Method, path) ||
+				!routes.Match(chi.NewRouteContext(), r.Method, lower) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if r.URL.RawQuery != "" {
+				lower += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, lower, http.StatusMovedPermanently)
+		})
+	}
+}
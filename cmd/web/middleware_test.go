@@ -3,8 +3,13 @@
 package main
 
 import (
+	"errors"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+
+	"github.com/go-chi/chi/v5"
 )
 
 // TestNoSurf asserts that NoSurf returns an http.Handler wrapper compatible
@@ -22,6 +27,35 @@ func TestNoSurf(t *testing.T) {
 	}
 }
 
+// TestNoSurf_APIExempt verifies that tokenless /api POSTs are allowed only
+// where a cross-site form can't forge them (the read-only quote and JSON
+// bodies) and rejected elsewhere, including form posts that create
+// reservations.
+func TestNoSurf_APIExempt(t *testing.T) {
+	var myH myHandler
+	h := NoSurf(&myH)
+
+	tests := []struct {
+		path        string
+		contentType string
+		want        int
+	}{
+		{"/api/quote", "application/x-www-form-urlencoded", http.StatusOK},
+		{"/api/reservations", "application/json", http.StatusOK},
+		{"/api/reservations", "application/x-www-form-urlencoded", http.StatusBadRequest},
+		{"/make-reservation", "application/x-www-form-urlencoded", http.StatusBadRequest},
+	}
+	for _, tc := range tests {
+		req := httptest.NewRequest(http.MethodPost, tc.path, nil)
+		req.Header.Set("Content-Type", tc.contentType)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		if rr.Code != tc.want {
+			t.Errorf("POST %s (%s): got %d, want %d", tc.path, tc.contentType, rr.Code, tc.want)
+		}
+	}
+}
+
 // TestSessionLoad asserts that SessionLoad returns an http.Handler wrapper
 // that can be composed in the middleware pipeline.
 func TestSessionLoad(t *testing.T) {
@@ -36,3 +70,234 @@ func TestSessionLoad(t *testing.T) {
 		t.Errorf("type is not http.Handler, but is %T", v)
 	}
 }
+
+// TestRequireHTTPS verifies that production requests forwarded over plain HTTP
+// are redirected to HTTPS, HTTPS requests pass through with HSTS set, and the
+// middleware is inert outside production.
+func TestRequireHTTPS(t *testing.T) {
+	orig := app.InProduction
+	defer func() { app.InProduction = orig }()
+
+	tests := []struct {
+		name         string
+		inProduction bool
+		proto        string
+		wantStatus   int
+		wantLocation string
+		wantHSTS     bool
+	}{
+		{"production http redirects", true, "http", http.StatusPermanentRedirect, "https://example.com/about?x=1", false},
+		{"production https passes through", true, "https", http.StatusOK, "", true},
+		{"development http passes through", false, "http", http.StatusOK, "", false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			app.InProduction = tc.inProduction
+
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/about?x=1", nil)
+			req.Header.Set("X-Forwarded-Proto", tc.proto)
+			rr := httptest.NewRecorder()
+
+			RequireHTTPS(&myHandler{}).ServeHTTP(rr, req)
+
+			if rr.Code != tc.wantStatus {
+				t.Fatalf("status: got %d, want %d", rr.Code, tc.wantStatus)
+			}
+			if loc := rr.Header().Get("Location"); loc != tc.wantLocation {
+				t.Fatalf("Location: got %q, want %q", loc, tc.wantLocation)
+			}
+			if hsts := rr.Header().Get("Strict-Transport-Security"); (hsts != "") != tc.wantHSTS {
+				t.Fatalf("Strict-Transport-Security: got %q, want set=%v", hsts, tc.wantHSTS)
+			}
+		})
+	}
+}
+
+// TestMaxBodyBytes verifies that oversized bodies are rejected: a declared
+// Content-Length over the limit gets 413 before the handler runs, and an
+// undeclared (chunked) oversized body fails form parsing with *http.MaxBytesError.
+func TestMaxBodyBytes(t *testing.T) {
+	const limit = 16
+
+	var parseErr error
+	var called bool
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		parseErr = r.ParseForm()
+	})
+	h := MaxBodyBytes(limit)(next)
+
+	t.Run("within limit", func(t *testing.T) {
+		called, parseErr = false, nil
+		req := httptest.NewRequest(http.MethodPost, "/contact", strings.NewReader("a=b"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+
+		if !called || parseErr != nil {
+			t.Fatalf("called=%v parseErr=%v, want handler called without error", called, parseErr)
+		}
+	})
+
+	t.Run("declared length over limit", func(t *testing.T) {
+		called = false
+		req := httptest.NewRequest(http.MethodPost, "/contact", strings.NewReader("message="+strings.Repeat("x", limit)))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusRequestEntityTooLarge {
+			t.Fatalf("status: got %d, want %d", rr.Code, http.StatusRequestEntityTooLarge)
+		}
+		if called {
+			t.Fatal("handler should not run for an oversized body")
+		}
+	})
+
+	t.Run("undeclared length over limit", func(t *testing.T) {
+		called, parseErr = false, nil
+		req := httptest.NewRequest(http.MethodPost, "/contact", strings.NewReader("message="+strings.Repeat("x", limit)))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.ContentLength = -1
+		h.ServeHTTP(httptest.NewRecorder(), req)
+
+		var maxErr *http.MaxBytesError
+		if !errors.As(parseErr, &maxErr) {
+			t.Fatalf("parseErr: got %v, want *http.MaxBytesError", parseErr)
+		}
+	})
+}
+
+// TestCORS verifies that allowed origins are echoed, other origins get no
+// Access-Control-Allow-Origin, and preflight requests are answered with 204
+// and the allowed methods and headers without reaching the handler.
+func TestCORS(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		origin      string
+		preflight   bool
+		wantStatus  int
+		wantACAO    string
+		wantMethods bool
+		wantNext    bool
+	}{
+		{name: "allowed origin", method: http.MethodPost, origin: "https://app.example.com", wantStatus: http.StatusOK, wantACAO: "https://app.example.com", wantNext: true},
+		{name: "disallowed origin", method: http.MethodPost, origin: "https://evil.example.com", wantStatus: http.StatusOK, wantNext: true},
+		{name: "same-origin request", method: http.MethodPost, wantStatus: http.StatusOK, wantNext: true},
+		{name: "preflight", method: http.MethodOptions, origin: "https://app.example.com", preflight: true, wantStatus: http.StatusNoContent, wantACAO: "https://app.example.com", wantMethods: true},
+		{name: "disallowed preflight", method: http.MethodOptions, origin: "https://evil.example.com", preflight: true, wantStatus: http.StatusNoContent},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			called := false
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true })
+			h := CORS([]string{"https://app.example.com/", "http://localhost:3000"})(next)
+
+			req := httptest.NewRequest(tc.method, "/api/quote", nil)
+			if tc.origin != "" {
+				req.Header.Set("Origin", tc.origin)
+			}
+			if tc.preflight {
+				req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			}
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+
+			if rr.Code != tc.wantStatus {
+				t.Errorf("status: got %d, want %d", rr.Code, tc.wantStatus)
+			}
+			if got := rr.Header().Get("Access-Control-Allow-Origin"); got != tc.wantACAO {
+				t.Errorf("Access-Control-Allow-Origin: got %q, want %q", got, tc.wantACAO)
+			}
+			if got := rr.Header().Get("Access-Control-Allow-Methods") != ""; got != tc.wantMethods {
+				t.Errorf("Access-Control-Allow-Methods set: got %v, want %v", got, tc.wantMethods)
+			}
+			if tc.wantMethods && !strings.Contains(rr.Header().Get("Access-Control-Allow-Headers"), "Content-Type") {
+				t.Errorf("Access-Control-Allow-Headers: got %q", rr.Header().Get("Access-Control-Allow-Headers"))
+			}
+			if rr.Header().Get("Vary") != "Origin" {
+				t.Errorf("Vary: got %q, want Origin", rr.Header().Get("Vary"))
+			}
+			if called != tc.wantNext {
+				t.Errorf("next called: got %v, want %v", called, tc.wantNext)
+			}
+		})
+	}
+}
+
+// TestCanonicalPaths verifies that GET requests for trailing-slash and
+// mixed-case variants of a route are redirected to the canonical path, that
+// paths which already resolve (including under the admin subrouter) are
+// served as-is, and that POSTs are never redirected.
+func TestCanonicalPaths(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+
+	mux := chi.NewRouter()
+	mux.Get("/about", ok)
+	mux.Post("/make-reservation", ok)
+	mux.Route("/admin", func(mux chi.Router) {
+		mux.Get("/dashboard", ok)
+		mux.Get("/email-preview/{template}", ok)
+	})
+
+	root := chi.NewRouter()
+	root.Use(RedirectSlashes)
+	root.Use(LowercasePaths(root))
+	root.Get("/healthz", ok)
+	root.Mount("/", mux)
+
+	tests := []struct {
+		method       string
+		target       string
+		wantStatus   int
+		wantLocation string
+	}{
+		{http.MethodGet, "/about", http.StatusOK, ""},
+		{http.MethodGet, "/about/", http.StatusMovedPermanently, "/about"},
+		{http.MethodHead, "/about/", http.StatusMovedPermanently, "/about"},
+		{http.MethodGet, "/About", http.StatusMovedPermanently, "/about"},
+		{http.MethodGet, "/ABOUT?lang=es", http.StatusMovedPermanently, "/about?lang=es"},
+		{http.MethodGet, "/HealthZ", http.StatusMovedPermanently, "/healthz"},
+		{http.MethodGet, "/admin/Dashboard", http.StatusMovedPermanently, "/admin/dashboard"},
+		{http.MethodGet, "/admin/dashboard/", http.StatusMovedPermanently, "/admin/dashboard"},
+		{http.MethodGet, "/admin/email-preview/Basic", http.StatusOK, ""},
+		{http.MethodGet, "/Nowhere", http.StatusNotFound, ""},
+		{http.MethodPost, "/make-reservation", http.StatusOK, ""},
+		{http.MethodPost, "/make-reservation/", http.StatusNotFound, ""},
+		{http.MethodPost, "/Make-Reservation", http.StatusNotFound, ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.method+" "+tc.target, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			root.ServeHTTP(rr, httptest.NewRequest(tc.method, tc.target, nil))
+
+			if rr.Code != tc.wantStatus {
+				t.Fatalf("status: got %d, want %d", rr.Code, tc.wantStatus)
+			}
+			if loc := rr.Header().Get("Location"); loc != tc.wantLocation {
+				t.Fatalf("Location: got %q, want %q", loc, tc.wantLocation)
+			}
+		})
+	}
+}
+
+// TestCORS_NoCredentials verifies that even an allowed origin is never told
+// it may send cookies, so the CSRF token from /api/session/status stays
+// readable only by the site itself.
+func TestCORS_NoCredentials(t *testing.T) {
+	h := CORS([]string{"https://app.example.com"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, method := range []string{http.MethodGet, http.MethodOptions} {
+		req := httptest.NewRequest(method, "/api/session/status", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		if got := rr.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+			t.Errorf("%s: Access-Control-Allow-Credentials: got %q, want none", method, got)
+		}
+	}
+}
@@ -4,7 +4,11 @@
 package main
 
 import (
+	"fmt"
 	"net/http"
+	"path"
+	"strings"
+	"time"
 
 	"github.com/bensabler/milos-residence/internal/config"
 	"github.com/bensabler/milos-residence/internal/handlers"
@@ -15,14 +19,22 @@ import (
 // routes constructs the HTTP router and registers all endpoints.
 //
 // Behavior:
-//   - Installs core middleware (panic recovery, CSRF protection, session load/save).
+//   - Installs core middleware (panic recovery, HTTPS enforcement, request
+//     body limit, CSRF protection, session load/save).
 //   - Registers public site routes (home, about, rooms, availability, booking, auth).
-//   - Serves static assets under /static/* from the local ./static directory.
+//   - Groups the JSON API under /api with CORS for configured origins and a
+//     JSON 405 response for unsupported methods.
+//   - Serves static assets under /static/* from the local ./static directory
+//     with Cache-Control and ETag headers (see staticFileServer).
 //   - Nests admin routes under /admin protected by Auth middleware.
+//   - Serves /robots.txt, /sitemap.xml, /favicon.ico and /site.webmanifest
+//     ahead of the CSRF/session middleware.
+//   - Redirects GET/HEAD requests for trailing-slash and mixed-case variants
+//     of a route to the canonical path.
 //
 // Parameters:
-//   - app: process-wide application configuration (unused here but kept for
-//     symmetry and future expansion).
+//   - app: process-wide application configuration (static cache lifetime,
+//     request body limit).
 //
 // Returns:
 //   - http.Handler: a fully configured chi.Mux ready to pass to http.Server.
@@ -33,25 +45,53 @@ import (
 func routes(app *config.AppConfig) http.Handler {
 	mux := chi.NewRouter()
 
-	// Core middleware — keep order logical: recover -> csrf -> session persistence.
+	maxBody := app.MaxBodyBytes
+	if maxBody <= 0 {
+		maxBody = defaultMaxBodyBytes
+	}
+
+	// Core middleware — keep order logical: recover -> https -> body limit -> csrf -> session persistence.
 	mux.Use(middleware.Recoverer)
-	mux.Use(NoSurf)      // CSRF protection with nosurf base cookie policy in middleware.go
-	mux.Use(SessionLoad) // scs session load/save wrapper
+	mux.Use(RequireHTTPS)          // production-only HTTP->HTTPS redirect and HSTS
+	mux.Use(MaxBodyBytes(maxBody)) // cap request bodies before anything parses them
+	mux.Use(NoSurf)                // CSRF protection with nosurf base cookie policy in middleware.go
+	mux.Use(SessionLoad)           // scs session load/save wrapper
 
 	// Public, non-auth routes.
 	mux.Get("/", handlers.Repo.Home)
 	mux.Get("/about", handlers.Repo.About)
 	mux.Get("/photos", handlers.Repo.Photos)
+	mux.Get("/rooms", handlers.Repo.Rooms)
 
 	// Room detail pages.
-	mux.Get("/golden-haybeam-loft", handlers.Repo.GoldenHaybeamLoft)
-	mux.Get("/window-perch-theater", handlers.Repo.WindowPerchTheater)
-	mux.Get("/laundry-basket-nook", handlers.Repo.LaundryBasketNook)
+	for _, page := range handlers.RoomRoutes {
+		mux.Get(page.Path, handlers.Repo.RoomPage(page))
+	}
 
 	// Availability search endpoints (HTML + JSON).
 	mux.Get("/search-availability", handlers.Repo.Availability)
 	mux.Post("/search-availability", handlers.Repo.PostAvailability)
 	mux.Post("/search-availability-json", handlers.Repo.AvailabilityJSON)
+	mux.Post("/search-flexible", handlers.Repo.PostFlexibleAvailability)
+	mux.Get("/search-availability.ics", handlers.Repo.AvailabilityICal)
+
+	// JSON API, callable cross-origin from CORS_ALLOWED_ORIGINS.
+	mux.Route("/api", func(mux chi.Router) {
+		mux.Use(CORS(app.CORSAllowedOrigins))
+
+		// Wrong-method requests get the JSON error envelope, not chi's empty 405.
+		mux.MethodNotAllowed(handlers.APIMethodNotAllowed(mux))
+
+		// Dry-run quote: availability, pricing and booking policies without booking.
+		mux.Post("/quote", handlers.Repo.QuoteAPI)
+		mux.Post("/reservations", handlers.Repo.ReservationAPI)
+
+		// Reserved and blocked date ranges for a room's availability calendar.
+		mux.Get("/rooms/{id}/blocked", handlers.Repo.RoomBlockedAPI)
+
+		// Login state and a fresh CSRF token for pages that post with fetch.
+		mux.Get("/session/status", handlers.Repo.SessionStatus)
+	})
 
 	// Booking flow.
 	mux.Get("/choose-room/{id}", handlers.Repo.ChooseRoom)
@@ -61,6 +101,10 @@ func routes(app *config.AppConfig) http.Handler {
 	mux.Get("/contact", handlers.Repo.Contact)
 	mux.Post("/contact", handlers.Repo.PostContact)
 
+	// Waitlist signup when a search finds no rooms.
+	mux.Get("/waitlist", handlers.Repo.Waitlist)
+	mux.Post("/waitlist", handlers.Repo.PostWaitlist)
+
 	// Reservation submission + confirmation.
 	mux.Get("/make-reservation", handlers.Repo.MakeReservation)
 	mux.Post("/make-reservation", handlers.Repo.PostReservation)
@@ -71,8 +115,18 @@ func routes(app *config.AppConfig) http.Handler {
 	mux.Post("/user/login", handlers.Repo.PostShowLogin)
 	mux.Get("/user/logout", handlers.Repo.Logout)
 
-	// Static assets served from local filesystem.
-	fileServer := http.FileServer(http.Dir("./static/"))
+	// Self-service password reset by emailed link.
+	mux.Get("/user/forgot-password", handlers.Repo.ForgotPassword)
+	mux.Post("/user/forgot-password", handlers.Repo.PostForgotPassword)
+	mux.Get("/user/reset-password/{token}", handlers.Repo.ResetPassword)
+	mux.Post("/user/reset-password/{token}", handlers.Repo.PostResetPassword)
+
+	// Static assets served from local filesystem with browser caching.
+	maxAge := app.StaticMaxAge
+	if maxAge <= 0 {
+		maxAge = defaultStaticMaxAge
+	}
+	fileServer := staticFileServer("./static/", maxAge)
 	mux.Handle("/static/*", http.StripPrefix("/static", fileServer))
 
 	// Admin routes — protected by Auth middleware, grouped under /admin.
@@ -81,16 +135,173 @@ func routes(app *config.AppConfig) http.Handler {
 
 		mux.Get("/dashboard", handlers.Repo.AdminDashboard)
 
+		mux.Get("/reservations", handlers.Repo.AdminReservations)
 		mux.Get("/reservations-new", handlers.Repo.AdminNewReservations)
 		mux.Get("/reservations-all", handlers.Repo.AdminAllReservations)
+		mux.Get("/arrivals", handlers.Repo.AdminArrivals)
 		mux.Get("/reservations-calendar", handlers.Repo.AdminReservationsCalendar)
 		mux.Post("/reservations-calendar", handlers.Repo.AdminPostReservationsCalendar)
 		mux.Get("/process-reservation/{src}/{id}/do", handlers.Repo.AdminProcessReservation)
+		mux.Get("/unprocess-reservation/{src}/{id}/do", handlers.Repo.AdminUnprocessReservation)
 		mux.Get("/delete-reservation/{src}/{id}/do", handlers.Repo.AdminDeleteReservation)
+		mux.Post("/reservations/bulk-delete", handlers.Repo.AdminBulkDeleteReservations)
+
+		// Take a room out of service indefinitely, or return it.
+		mux.Post("/rooms/{id}/close", handlers.Repo.AdminCloseRoom)
+		mux.Post("/rooms/{id}/open", handlers.Repo.AdminOpenRoom)
+
+		// Preview conflicts for a manual booking before submitting it.
+		mux.Get("/rooms/{id}/check", handlers.Repo.AdminRoomCheck)
+
+		// Room details and room page galleries.
+		mux.Get("/rooms/{id}/edit", handlers.Repo.AdminEditRoom)
+		mux.Post("/rooms/{id}/edit", handlers.Repo.AdminPostEditRoom)
+		mux.Get("/rooms/{id}/images", handlers.Repo.AdminRoomImages)
+		mux.Post("/rooms/{id}/images", handlers.Repo.AdminPostRoomImage)
+		mux.Post("/rooms/{id}/images/{imageID}/delete", handlers.Repo.AdminDeleteRoomImage)
+
+		mux.Get("/reports/conflicts", handlers.Repo.AdminReportConflicts)
+		mux.Get("/audit", handlers.Repo.AdminAuditLog)
+
+		// Read-only view of a guest's reservations (top access level only; enforced in the handler).
+		mux.Get("/view-as", handlers.Repo.AdminViewAsGuest)
+		mux.Get("/mail-log", handlers.Repo.AdminMailLog)
+
+		// Failed-mail (dead-letter) queue.
+		mux.Get("/mail-failures", handlers.Repo.AdminMailFailures)
+		mux.Post("/mail-failures/{id}/retry", handlers.Repo.AdminRetryMail)
+
+		// Staff account creation (top access level only; enforced in the handlers).
+		mux.Get("/users/new", handlers.Repo.AdminNewUser)
+		mux.Post("/users/new", handlers.Repo.AdminPostNewUser)
+
+		// Runtime settings (top access level only; enforced in the handlers).
+		mux.Get("/settings", handlers.Repo.AdminSettings)
+		mux.Post("/settings", handlers.Repo.AdminPostSettings)
 
 		mux.Get("/reservations/{src}/{id}/show", handlers.Repo.AdminShowReservation)
+		mux.Get("/reservations/{src}/{id}/print", handlers.Repo.AdminPrintReservation)
 		mux.Post("/reservations/{src}/{id}", handlers.Repo.AdminPostShowReservation)
+		mux.Post("/reservations/{src}/{id}/dates", handlers.Repo.AdminPostReservationDates)
+		mux.Post("/reservations/{src}/{id}/status", handlers.Repo.AdminSetReservationStatus)
+
+		// JSON API for admin front-end pages.
+		mux.Get("/api/reservations/{id}", handlers.Repo.AdminReservationJSON)
+		mux.Get("/api/stats/bookings", handlers.Repo.AdminBookingsStats)
+
+		// Development-only email template preview (404 in production).
+		mux.Get("/email-preview/{template}", handlers.Repo.AdminEmailPreview)
 	})
 
-	return mux
+	// Crawler files and the health check sit on a root router in front of the
+	// app so they skip the CSRF cookie and session middleware; everything else
+	// falls through to mux.
+	root := chi.NewRouter()
+	root.Use(middleware.Recoverer)
+	root.Use(RedirectSlashes)      // "/about/" -> "/about" (GET/HEAD only)
+	root.Use(LowercasePaths(root)) // "/About" -> "/about" when only the lowercase path exists
+	root.Get("/healthz", handlers.Repo.Healthz)
+	root.Get("/robots.txt", handlers.Repo.RobotsTxt)
+	root.Get("/sitemap.xml", handlers.Repo.SitemapXML)
+
+	// Browsers fetch the favicon and manifest from the site root on every
+	// page; serve them with the same cache headers as /static.
+	favicon := app.FaviconPath
+	if favicon == "" {
+		favicon = defaultFaviconPath
+	}
+	root.Get("/favicon.ico", staticFile("./static/", favicon, maxAge).ServeHTTP)
+	root.Get("/site.webmanifest", handlers.Repo.WebManifest)
+	root.Mount("/", mux)
+
+	return root
+}
+
+// defaultStaticMaxAge is the browser cache lifetime for static assets when
+// STATIC_MAX_AGE is not configured.
+const defaultStaticMaxAge = time.Hour
+
+// defaultFaviconPath is the file under ./static served at /favicon.ico when
+// FAVICON_PATH is not configured.
+const defaultFaviconPath = "admin/images/favicon.ico"
+
+// iconContentTypes maps favicon file extensions to the Content-Type sent for
+// them. Go's built-in MIME table has no entry for .ico, so relying on
+// http.FileServer would depend on the host's mime.types.
+var iconContentTypes = map[string]string{
+	".ico": "image/x-icon",
+	".png": "image/png",
+	".svg": "image/svg+xml",
+}
+
+// staticFileServer serves files from root and adds caching headers so browsers
+// stop refetching CSS/JS on every page load.
+//
+// Behavior:
+//   - Opens the requested file once and, when it is a regular file, sets
+//     "Cache-Control: public, max-age=<seconds>" and a weak validator ETag
+//     derived from the file's size and mtime before serving it, so
+//     conditional requests (If-None-Match) receive 304 Not Modified.
+//   - Last-Modified and Range handling are provided by http.ServeContent.
+//   - Missing files and directories are left to http.FileServer without
+//     cache headers, so a 404 is never cached.
+//
+// Parameters:
+//   - root: directory to serve (e.g., "./static/").
+//   - maxAge: cache lifetime advertised to clients.
+//
+// Returns:
+//   - http.Handler: a file server wrapped with cache headers.
+func staticFileServer(root string, maxAge time.Duration) http.Handler {
+	dir := http.Dir(root)
+	fileServer := http.FileServer(dir)
+	cacheControl := fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds()))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, err := dir.Open(path.Clean("/" + r.URL.Path))
+		if err != nil {
+			fileServer.ServeHTTP(w, r)
+			return
+		}
+		defer f.Close()
+
+		fi, err := f.Stat()
+		if err != nil || fi.IsDir() {
+			fileServer.ServeHTTP(w, r)
+			return
+		}
+
+		// Derive an ETag from file metadata; http.ServeContent honors it for
+		// If-None-Match.
+		w.Header().Set("Cache-Control", cacheControl)
+		w.Header().Set("ETag", fmt.Sprintf(`W/"%x-%x"`, fi.ModTime().UnixNano(), fi.Size()))
+		http.ServeContent(w, r, fi.Name(), fi.ModTime(), f)
+	})
+}
+
+// staticFile serves the single file name from root, whatever the request path,
+// with the caching headers of staticFileServer. It is used for files that
+// browsers expect at a fixed root URL, such as /favicon.ico.
+//
+// Parameters:
+//   - root: directory containing the file (e.g., "./static/").
+//   - name: path of the file relative to root.
+//   - maxAge: cache lifetime advertised to clients.
+//
+// Returns:
+//   - http.Handler: a handler that always serves root/name.
+func staticFile(root, name string, maxAge time.Duration) http.Handler {
+	fileServer := staticFileServer(root, maxAge)
+	filePath := path.Clean("/" + name)
+	contentType := iconContentTypes[strings.ToLower(path.Ext(filePath))]
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if contentType != "" {
+			w.Header().Set("Content-Type", contentType)
+		}
+		r2 := r.Clone(r.Context())
+		r2.URL.Path = filePath
+		r2.URL.RawPath = ""
+		fileServer.ServeHTTP(w, r2)
+	})
 }
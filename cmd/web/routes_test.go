@@ -3,9 +3,15 @@
 package main
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/bensabler/milos-residence/internal/config"
+	"github.com/bensabler/milos-residence/internal/handlers"
 	"github.com/go-chi/chi/v5"
 )
 
@@ -25,3 +31,152 @@ func TestRoutes(t *testing.T) {
 		t.Errorf("type is not *chi.Mux, but is %T", v)
 	}
 }
+
+// TestRoutes_CanonicalPaths checks the redirects are installed on the real
+// router: "/about/" and "/About" redirect to "/about", while a booking POST
+// is handled (here rejected by CSRF) rather than redirected.
+func TestRoutes_CanonicalPaths(t *testing.T) {
+	var app config.AppConfig
+	mux := routes(&app)
+
+	for _, target := range []string{"/about/", "/About"} {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, target, nil))
+		if rr.Code != http.StatusMovedPermanently || rr.Header().Get("Location") != "/about" {
+			t.Errorf("GET %s: got %d to %q, want 301 to /about", target, rr.Code, rr.Header().Get("Location"))
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/make-reservation", nil))
+	if rr.Code == http.StatusMovedPermanently || rr.Header().Get("Location") != "" {
+		t.Errorf("POST /make-reservation was redirected: %d to %q", rr.Code, rr.Header().Get("Location"))
+	}
+}
+
+// TestStaticFileServer verifies that static files are served with a public
+// Cache-Control header carrying the configured max-age, plus an ETag that
+// allows conditional requests to short-circuit with 304 Not Modified, and
+// that a missing file's 404 carries neither.
+func TestStaticFileServer(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "site.css"), []byte("body{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	h := staticFileServer(dir, 2*time.Hour)
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/site.css", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status: got %d, want %d", rr.Code, http.StatusOK)
+	}
+	if got := rr.Header().Get("Cache-Control"); got != "public, max-age=7200" {
+		t.Fatalf("Cache-Control: got %q, want %q", got, "public, max-age=7200")
+	}
+	etag := rr.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("expected ETag header")
+	}
+	if rr.Header().Get("Last-Modified") == "" {
+		t.Fatal("expected Last-Modified header")
+	}
+
+	// A conditional request with the returned ETag should be answered with 304.
+	req := httptest.NewRequest(http.MethodGet, "/site.css", nil)
+	req.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotModified {
+		t.Fatalf("conditional status: got %d, want %d", rr.Code, http.StatusNotModified)
+	}
+
+	// A missing file is a 404 that browsers must not cache.
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/missing.css", nil))
+
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("missing status: got %d, want %d", rr.Code, http.StatusNotFound)
+	}
+	if got := rr.Header().Get("Cache-Control"); got != "" {
+		t.Errorf("missing Cache-Control: got %q, want none", got)
+	}
+	if got := rr.Header().Get("ETag"); got != "" {
+		t.Errorf("missing ETag: got %q, want none", got)
+	}
+}
+
+// TestStaticFile checks that a single-file route serves the configured file
+// with an explicit icon Content-Type and the static cache headers.
+func TestStaticFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "images"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "images", "icon.ico"), []byte("\x00\x00\x01\x00"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	h := staticFile(dir, "images/icon.ico", time.Hour)
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/favicon.ico", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status: got %d, want %d", rr.Code, http.StatusOK)
+	}
+	if got := rr.Header().Get("Content-Type"); got != "image/x-icon" {
+		t.Fatalf("Content-Type: got %q, want %q", got, "image/x-icon")
+	}
+	if got := rr.Header().Get("Cache-Control"); got != "public, max-age=3600" {
+		t.Fatalf("Cache-Control: got %q, want %q", got, "public, max-age=3600")
+	}
+	if rr.Header().Get("ETag") == "" {
+		t.Fatal("expected ETag header")
+	}
+}
+
+// TestRoutes_FaviconAndManifest requests the root files through the full
+// router, without a session or CSRF cookie, using the default favicon in
+// ./static.
+func TestRoutes_FaviconAndManifest(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir("../.."); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+
+	testApp := config.AppConfig{StaticMaxAge: time.Hour}
+	prev := handlers.Repo
+	handlers.NewHandlers(handlers.NewTestRepo(&testApp))
+	t.Cleanup(func() { handlers.NewHandlers(prev) })
+
+	h := routes(&testApp)
+
+	tests := []struct {
+		path        string
+		contentType string
+	}{
+		{path: "/favicon.ico", contentType: "image/x-icon"},
+		{path: "/site.webmanifest", contentType: "application/manifest+json"},
+	}
+	for _, tc := range tests {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tc.path, nil))
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s status: got %d, want %d", tc.path, rr.Code, http.StatusOK)
+		}
+		if got := rr.Header().Get("Content-Type"); got != tc.contentType {
+			t.Errorf("%s Content-Type: got %q, want %q", tc.path, got, tc.contentType)
+		}
+		if rr.Header().Get("Cache-Control") == "" {
+			t.Errorf("%s: expected Cache-Control header", tc.path)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bensabler/milos-residence/internal/models"
+	"github.com/bensabler/milos-residence/internal/repository"
+)
+
+// seedRooms are the rooms the public room pages expect, matching the
+// seed_room_table migration.
+var seedRooms = []models.Room{
+	{RoomName: "Golden Haybeam Loft", NightlyRate: 15000, MaxGuests: 2, Active: true},
+	{RoomName: "Window Perch Theater", NightlyRate: 12000, MaxGuests: 2, Active: true},
+	{RoomName: "Laundry-Basket Nook", NightlyRate: 9000, MaxGuests: 1, Active: true},
+}
+
+// seedReservation is a sample booking, tied to its room by name because room
+// IDs depend on the database.
+type seedReservation struct {
+	RoomName  string
+	FirstName string
+	LastName  string
+	Email     string
+	Phone     string
+	StartDate time.Time
+	EndDate   time.Time
+}
+
+// seedReservations are fixed sample bookings, so every developer's database
+// holds the same data.
+var seedReservations = []seedReservation{
+	{
+		RoomName: "Golden Haybeam Loft", FirstName: "Whiskers", LastName: "McFluff",
+		Email: "whiskers@example.com", Phone: "555-0101",
+		StartDate: time.Date(2030, time.June, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2030, time.June, 4, 0, 0, 0, 0, time.UTC),
+	},
+	{
+		RoomName: "Window Perch Theater", FirstName: "Tabitha", LastName: "Purrington",
+		Email: "tabitha@example.com", Phone: "555-0102",
+		StartDate: time.Date(2030, time.July, 10, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2030, time.July, 12, 0, 0, 0, 0, time.UTC),
+	},
+}
+
+// seedAdminAccessLevel is the access level given to the seeded admin user.
+const seedAdminAccessLevel = 3
+
+// seedCounts reports how many records a seed run inserted.
+type seedCounts struct {
+	Rooms        int
+	Users        int
+	Reservations int
+}
+
+// seed inserts development data through the repository: the three rooms, an
+// admin user and the sample reservations. Every step skips records that are
+// already present (rooms by name, the admin by email, reservations by guest,
+// room and dates), so running it again inserts nothing.
+//
+// Parameters:
+//   - db: Repository to write to
+//   - adminEmail, adminPassword: Credentials for the admin user; the password
+//     is hashed by CreateUser
+//
+// Returns:
+//   - seedCounts: Records inserted by this run
+//   - error: The first repository error; earlier inserts are kept
+func seed(db repository.DatabaseRepo, adminEmail, adminPassword string) (seedCounts, error) {
+	var counts seedCounts
+
+	rooms, err := db.AllRooms()
+	if err != nil {
+		return counts, err
+	}
+	roomIDs := make(map[string]int, len(rooms))
+	for _, room := range rooms {
+		roomIDs[room.RoomName] = room.ID
+	}
+	for _, room := range seedRooms {
+		if _, ok := roomIDs[room.RoomName]; ok {
+			continue
+		}
+		id, err := db.InsertRoom(room)
+		if err != nil {
+			return counts, err
+		}
+		roomIDs[room.RoomName] = id
+		counts.Rooms++
+	}
+
+	_, err = db.GetUserByEmail(adminEmail)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		admin := models.User{FirstName: "Admin", LastName: "User", Email: adminEmail, AccessLevel: seedAdminAccessLevel}
+		if _, err := db.CreateUser(admin, adminPassword); err != nil {
+			return counts, err
+		}
+		counts.Users++
+	} else if err != nil {
+		return counts, err
+	}
+
+	for _, sr := range seedReservations {
+		roomID := roomIDs[sr.RoomName]
+		exists, err := db.ReservationExists(sr.Email, roomID, sr.StartDate, sr.EndDate)
+		if err != nil {
+			return counts, err
+		}
+		if exists {
+			continue
+		}
+
+		rate := 0
+		for _, room := range seedRooms {
+			if room.RoomName == sr.RoomName {
+				rate = room.NightlyRate
+			}
+		}
+		nights := int(sr.EndDate.Sub(sr.StartDate).Hours() / 24)
+
+		_, err = db.CreateReservation(models.Reservation{
+			FirstName:       sr.FirstName,
+			LastName:        sr.LastName,
+			Email:           sr.Email,
+			Phone:           sr.Phone,
+			StartDate:       sr.StartDate,
+			EndDate:         sr.EndDate,
+			RoomID:          roomID,
+			Total:           nights * rate,
+			TermsAcceptedAt: sr.StartDate.AddDate(0, -1, 0),
+		}, "")
+		if err != nil {
+			return counts, fmt.Errorf("seeding reservation for %s: %w", sr.Email, err)
+		}
+		counts.Reservations++
+	}
+
+	return counts, nil
+}
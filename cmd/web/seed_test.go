@@ -0,0 +1,110 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bensabler/milos-residence/internal/models"
+	"github.com/bensabler/milos-residence/internal/repository"
+)
+
+// seedRepo is an in-memory stand-in for the methods seed uses. Other
+// repository methods are left to the embedded nil interface and panic if
+// called.
+type seedRepo struct {
+	repository.DatabaseRepo
+	rooms        []models.Room
+	users        []models.User
+	reservations []models.Reservation
+}
+
+func (s *seedRepo) AllRooms() ([]models.Room, error) { return s.rooms, nil }
+
+func (s *seedRepo) InsertRoom(room models.Room) (int, error) {
+	room.ID = len(s.rooms) + 1
+	s.rooms = append(s.rooms, room)
+	return room.ID, nil
+}
+
+func (s *seedRepo) GetUserByEmail(email string) (models.User, error) {
+	for _, u := range s.users {
+		if strings.EqualFold(u.Email, email) {
+			return u, nil
+		}
+	}
+	return models.User{}, repository.ErrUserNotFound
+}
+
+func (s *seedRepo) CreateUser(u models.User, password string) (int, error) {
+	u.ID = len(s.users) + 1
+	s.users = append(s.users, u)
+	return u.ID, nil
+}
+
+func (s *seedRepo) ReservationExists(email string, roomID int, start, end time.Time) (bool, error) {
+	for _, r := range s.reservations {
+		if strings.EqualFold(r.Email, email) && r.RoomID == roomID && r.StartDate.Equal(start) && r.EndDate.Equal(end) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *seedRepo) CreateReservation(res models.Reservation, idempotencyKey string) (int, error) {
+	res.ID = len(s.reservations) + 1
+	s.reservations = append(s.reservations, res)
+	return res.ID, nil
+}
+
+// TestSeed verifies that seeding an empty database inserts the rooms, admin
+// and sample reservations, that a second run inserts nothing, and that a
+// database already holding the migration-seeded rooms only gets the rest.
+func TestSeed(t *testing.T) {
+	repo := &seedRepo{}
+
+	counts, err := seed(repo, "admin@example.com", "secret123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := seedCounts{Rooms: len(seedRooms), Users: 1, Reservations: len(seedReservations)}
+	if counts != want {
+		t.Fatalf("first run: got %+v, want %+v", counts, want)
+	}
+	if repo.users[0].AccessLevel != seedAdminAccessLevel {
+		t.Errorf("admin access level: got %d, want %d", repo.users[0].AccessLevel, seedAdminAccessLevel)
+	}
+	for _, res := range repo.reservations {
+		if res.RoomID == 0 || res.Total == 0 {
+			t.Errorf("reservation not tied to a priced room: %+v", res)
+		}
+	}
+
+	counts, err = seed(repo, "ADMIN@example.com", "secret123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts != (seedCounts{}) {
+		t.Fatalf("second run: got %+v, want nothing inserted", counts)
+	}
+	if len(repo.rooms) != len(seedRooms) || len(repo.users) != 1 || len(repo.reservations) != len(seedReservations) {
+		t.Fatalf("duplicates after second run: %d rooms, %d users, %d reservations",
+			len(repo.rooms), len(repo.users), len(repo.reservations))
+	}
+
+	migrated := &seedRepo{rooms: []models.Room{
+		{ID: 7, RoomName: "Golden Haybeam Loft"},
+		{ID: 8, RoomName: "Window Perch Theater"},
+		{ID: 9, RoomName: "Laundry-Basket Nook"},
+	}}
+	counts, err = seed(migrated, "admin@example.com", "secret123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts.Rooms != 0 || counts.Reservations != len(seedReservations) {
+		t.Fatalf("migrated database: got %+v", counts)
+	}
+	if migrated.reservations[0].RoomID != 7 {
+		t.Errorf("reservation room: got %d, want existing room 7", migrated.reservations[0].RoomID)
+	}
+}
@@ -1,39 +1,68 @@
-// Command web implements an asynchronous mail-sending loop for the application.
-// It consumes messages from the global app.MailChan and delivers them using
-// a local SMTP server via the go-simple-mail library.
+// Command web implements an asynchronous mail-sending pool for the application.
+// Its workers consume messages from the global app.MailChan and deliver them
+// using a local SMTP server via the go-simple-mail library.
 package main
 
 import (
 	"fmt"
+	"html/template"
 	"log"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/bensabler/milos-residence/internal/handlers"
 	"github.com/bensabler/milos-residence/internal/models"
 	mail "github.com/xhit/go-simple-mail/v2"
 )
 
-// listenForMail starts a background goroutine that continuously reads messages
-// from app.MailChan and dispatches them using sendMsg.
+// mailSender delivers a single message. It is sendMsg in production and a
+// variable so tests can substitute a slow or recording sender.
+var mailSender = sendMsg
+
+// listenForMail starts workers goroutines that read messages from
+// app.MailChan and deliver each one with mailSender, so one slow SMTP
+// exchange no longer holds up the rest of the queue.
 //
 // Behavior:
-//   - Blocks on app.MailChan, ensuring backpressure when the channel is full.
-//   - Each received MailData is handed to sendMsg for SMTP delivery.
+//   - Senders still block on app.MailChan while every worker is busy,
+//     which keeps backpressure on the handlers.
+//   - Each send opens its own SMTP connection (see sendMsg), so workers
+//     share no client state.
+//   - Workers exit once app.MailChan is closed and drained; the returned
+//     function waits for them, letting shutdown finish in-flight sends.
+//
+// Parameters:
+//   - workers: number of concurrent senders (MAIL_WORKERS); values below 1
+//     start a single worker
+//
+// Returns:
+//   - func(): blocks until every worker has exited
 //
 // Usage:
 //
 //	// During startup after app.MailChan is created:
-//	go listenForMail()
-func listenForMail() {
-	go func() {
-		for {
-			// Pull the next queued email and send it.
-			msg := <-app.MailChan
-			sendMsg(msg)
-		}
-	}()
+//	waitMail := listenForMail(app.MailWorkers)
+//	defer func() { close(app.MailChan); waitMail() }()
+func listenForMail(workers int) func() {
+	if workers < 1 {
+		workers = 1
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Pull queued emails until the channel is closed.
+			for msg := range app.MailChan {
+				mailSender(msg)
+			}
+		}()
+	}
+	return wg.Wait
 }
 
 // sendMsg builds and sends a single email message through an SMTP server.
@@ -46,15 +75,17 @@ func listenForMail() {
 //   - Resolves SMTP host and port from environment variables MAIL_HOST and
 //     MAIL_PORT, defaulting to "localhost" and "1025" when unset.
 //   - Configures a go-simple-mail SMTP client with 10-second connect/send
-//     timeouts and no persistent connections (KeepAlive=false).
+//     timeouts and no persistent connections (KeepAlive=false). The client
+//     is created per call, so concurrent mail workers never share one.
 //   - Establishes a connection to the SMTP server.
 //   - Constructs a new email message and sets From, To, and Subject headers.
-//   - If m.Template is empty, sets the raw HTML body to m.Content.
-//   - If m.Template is provided, reads the template file from
-//     ./email-templates/, replaces the [%body%] placeholder with m.Content,
-//     and uses the resulting HTML as the body.
+//   - Builds the HTML body with mailBody: m.Content on its own or wrapped
+//     in the named template from ./email-templates/.
 //   - Attempts to send the email, logging any connection or send errors to
 //     errorLog and the standard logger.
+//   - Records the attempt and its outcome in the mail log (see recordSentMail).
+//   - Keeps a message that could not be sent in the failed-mail queue so
+//     staff can retry it (see deadLetterMail).
 //
 // Notes:
 //   - Designed for development and testing with MailHog or a similar SMTP
@@ -94,25 +125,69 @@ func sendMsg(m models.MailData) {
 	email.SetFrom(m.From).AddTo(m.To).SetSubject(m.Subject)
 
 	// Determine body source: direct content or template substitution.
-	if m.Template == "" {
-		// No template provided; send raw HTML content.
-		email.SetBody(mail.TextHTML, m.Content)
-	} else {
-		// Template specified; read file and replace [%body%] placeholder.
-		data, err := os.ReadFile(fmt.Sprintf("./email-templates/%s", m.Template))
-		if err != nil {
-			app.ErrorLog.Println(err)
-		}
-		mailTemplate := string(data)
-		msgToSend := strings.Replace(mailTemplate, "[%body%]", m.Content, 1)
-		email.SetBody(mail.TextHTML, msgToSend)
-	}
+	email.SetBody(mail.TextHTML, mailBody(m))
 
 	// Attempt to send the email and log the outcome.
 	err = email.Send(client)
+	status := models.MailStatusSent
 	if err != nil {
 		log.Println(err)
+		status = models.MailStatusFailed
+		deadLetterMail(m, err)
 	} else {
 		log.Println("Email sent!")
 	}
+	recordSentMail(m, status)
+}
+
+// readMailTemplate loads an email template by file name from
+// ./email-templates/. It is a variable so tests can supply templates without
+// touching the filesystem.
+var readMailTemplate = func(name string) ([]byte, error) {
+	return os.ReadFile(fmt.Sprintf("./email-templates/%s", name))
+}
+
+// mailBody returns the HTML body for m. Without a template it is m.Content;
+// with one, the template's [%body%] placeholder is replaced by m.Content and
+// each [%property%] placeholder by the configured property name.
+//
+// A template that can't be read is logged as a warning and m.Content is sent
+// as the raw body instead, so a missing file never produces a blank email.
+func mailBody(m models.MailData) string {
+	if m.Template == "" {
+		return m.Content
+	}
+
+	data, err := readMailTemplate(m.Template)
+	if err != nil {
+		errorLog.Printf("WARNING: email template %q could not be read, sending raw content to %s: %v", m.Template, m.To, err)
+		return m.Content
+	}
+	body := strings.Replace(string(data), "[%body%]", m.Content, 1)
+	return strings.ReplaceAll(body, "[%property%]", template.HTMLEscapeString(app.Property()))
+}
+
+// recordSentMail writes the outcome of a send attempt to the mail log when
+// MAIL_LOG is enabled. A logging failure is reported but never retried, so
+// the log can't hold up delivery.
+func recordSentMail(m models.MailData, status string) {
+	if !app.LogMail || handlers.Repo == nil {
+		return
+	}
+	if err := handlers.Repo.DB.LogSentMail(m, status); err != nil {
+		errorLog.Println(err)
+	}
+}
+
+// deadLetterMail stores a message whose delivery failed in the failed-mail
+// queue, where /admin/mail-failures lists it for a retry. There is no
+// automatic retry, so one failed attempt is enough to dead-letter it.
+// Messages marked Sensitive are left out by the repository.
+func deadLetterMail(m models.MailData, sendErr error) {
+	if handlers.Repo == nil {
+		return
+	}
+	if err := handlers.Repo.DB.DeadLetterMail(m, sendErr.Error()); err != nil {
+		errorLog.Println(err)
+	}
 }
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bensabler/milos-residence/internal/models"
+)
+
+// TestMailBody verifies that content is sent raw without a template, wrapped
+// when the template loads, and sent raw with a logged warning when the
+// template file is missing rather than producing an empty body.
+func TestMailBody(t *testing.T) {
+	origRead, origLog := readMailTemplate, errorLog
+	defer func() { readMailTemplate, errorLog = origRead, origLog }()
+
+	var logged bytes.Buffer
+	errorLog = log.New(&logged, "", 0)
+	readMailTemplate = func(name string) ([]byte, error) {
+		if name == "basic.html" {
+			return []byte("<html><body><h4>[%property%]</h4>[%body%]</body></html>"), nil
+		}
+		return nil, fs.ErrNotExist
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+		warns    bool
+	}{
+		{name: "no template", want: "<p>Hello</p>"},
+		{name: "template", template: "basic.html", want: "<html><body><h4>Milo&#39;s Residence</h4><p>Hello</p></body></html>"},
+		{name: "missing template", template: "gone.html", want: "<p>Hello</p>", warns: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			logged.Reset()
+
+			got := mailBody(models.MailData{To: "guest@example.com", Content: "<p>Hello</p>", Template: tc.template})
+			if got != tc.want {
+				t.Errorf("body: got %q, want %q", got, tc.want)
+			}
+			if warned := strings.Contains(logged.String(), "WARNING"); warned != tc.warns {
+				t.Errorf("warning logged: got %v, want %v (%q)", warned, tc.warns, logged.String())
+			}
+			if tc.warns && !strings.Contains(logged.String(), tc.template) {
+				t.Errorf("warning %q does not name the template", logged.String())
+			}
+		})
+	}
+
+	// The default loader reports a missing file as an error.
+	readMailTemplate = origRead
+	if _, err := readMailTemplate("does-not-exist.html"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("readMailTemplate: got %v, want fs.ErrNotExist", err)
+	}
+}
+
+// TestListenForMail verifies that the worker pool delivers every queued
+// message, runs slow sends in parallel, and that the wait function returns
+// once the channel is closed and drained.
+func TestListenForMail(t *testing.T) {
+	origChan, origSender := app.MailChan, mailSender
+	defer func() { app.MailChan, mailSender = origChan, origSender }()
+
+	const (
+		workers = 4
+		delay   = 100 * time.Millisecond
+	)
+
+	var mu sync.Mutex
+	var delivered []string
+	mailSender = func(m models.MailData) {
+		time.Sleep(delay)
+		mu.Lock()
+		delivered = append(delivered, m.To)
+		mu.Unlock()
+	}
+	app.MailChan = make(chan models.MailData)
+
+	wait := listenForMail(workers)
+
+	began := time.Now()
+	for i := 0; i < workers; i++ {
+		app.MailChan <- models.MailData{To: fmt.Sprintf("guest%d@example.com", i)}
+	}
+	close(app.MailChan)
+	wait()
+	elapsed := time.Since(began)
+
+	if len(delivered) != workers {
+		t.Fatalf("delivered: got %d, want %d", len(delivered), workers)
+	}
+	// Serial sends would take workers*delay; parallel ones about one delay.
+	if elapsed >= 2*delay {
+		t.Errorf("%d sends took %v, want roughly %v with %d workers", workers, elapsed, delay, workers)
+	}
+}
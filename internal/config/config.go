@@ -7,6 +7,9 @@ package config
 import (
 	"html/template"
 	"log"
+	"net"
+	"net/http"
+	"time"
 
 	"github.com/alexedwards/scs/v2"
 	"github.com/bensabler/milos-residence/internal/models"
@@ -52,6 +55,10 @@ type AppConfig struct {
 	// CSRF, disabled debug features). Set once at startup based on environment.
 	InProduction bool
 
+	// Env is the validated APP_ENV value, "dev" or "prod". InProduction is
+	// true exactly when Env is "prod".
+	Env string
+
 	// Session is the global session manager used across handlers. Configure cookie
 	// attributes (lifetime, persistence, SameSite, Secure) during bootstrap.
 	Session *scs.SessionManager
@@ -59,4 +66,147 @@ type AppConfig struct {
 	// MailChan provides an asynchronous pathway for outbound mail work. A background
 	// goroutine should drain this channel for the lifetime of the process.
 	MailChan chan models.MailData
+
+	// HoneypotField names the hidden contact-form input that only bots fill in.
+	// Rotate it (via HONEYPOT_FIELD) if spammers learn to skip the current name.
+	HoneypotField string
+
+	// ContactRecipients maps each contact-form topic ("booking", "billing",
+	// "general") to the staff address its messages are sent to
+	// (CONTACT_EMAIL_<TOPIC>). Topics without an address use the "general" one.
+	ContactRecipients map[string]string
+
+	// StaticMaxAge is the Cache-Control max-age applied to files under /static.
+	// Zero means the router's default (one hour) is used.
+	StaticMaxAge time.Duration
+
+	// FaviconPath is the file served at /favicon.ico, relative to the static
+	// directory (FAVICON_PATH). Empty means the router's default is used.
+	FaviconPath string
+
+	// BcryptCost is the work factor used when hashing passwords. Stored hashes
+	// with a lower cost are transparently upgraded on the next successful login.
+	// Zero means the repository default (12) is used.
+	BcryptCost int
+
+	// CookieSecure marks the session and CSRF cookies Secure. It is true in
+	// production and can be forced (TRUST_PROXY/FORCE_SECURE_COOKIES) when a
+	// TLS-terminating proxy forwards plain HTTP to the app.
+	CookieSecure bool
+
+	// CookieSameSite is the SameSite mode for the session and CSRF cookies
+	// (COOKIE_SAMESITE: lax, strict or none).
+	CookieSameSite http.SameSite
+
+	// MaxBodyBytes caps the size of request bodies (MAX_BODY_BYTES). Larger
+	// POSTs fail form parsing and are answered with 413 Request Entity Too Large.
+	MaxBodyBytes int64
+
+	// RobotsDisallow lists path prefixes robots.txt asks crawlers to skip
+	// (ROBOTS_DISALLOW, comma-separated).
+	RobotsDisallow []string
+
+	// CORSAllowedOrigins lists the origins allowed to call the /api routes from
+	// the browser (CORS_ALLOWED_ORIGINS, comma-separated). Empty allows none.
+	CORSAllowedOrigins []string
+
+	// TrustedProxies lists the networks of reverse proxies whose
+	// X-Forwarded-For and X-Real-IP headers are believed when resolving a
+	// client's IP (TRUSTED_PROXIES, comma-separated CIDRs or addresses).
+	// Empty trusts none, so RemoteAddr is always used.
+	TrustedProxies []*net.IPNet
+
+	// MinStayNights and MaxStayNights bound the length of a stay accepted by
+	// quotes and reservations (MIN_STAY_NIGHTS, MAX_STAY_NIGHTS).
+	MinStayNights int
+	MaxStayNights int
+
+	// MaxAdvanceDays is how many days ahead of today a stay may start
+	// (MAX_ADVANCE_DAYS). Zero means the handlers' default (365).
+	MaxAdvanceDays int
+
+	// RoomsPerPage is how many rooms the availability results page lists at
+	// a time (ROOMS_PER_PAGE). Zero uses the handlers' default of 10.
+	RoomsPerPage int
+
+	// MinLeadDays is how many days' notice a booking needs, e.g. 1 to stop
+	// same-day bookings (MIN_LEAD_DAYS). Rooms may override it with
+	// Room.LeadDays. Zero allows a stay to start today.
+	MinLeadDays int
+
+	// DisplayLocation is the time zone stored timestamps (which are UTC) are
+	// shown in by the localTime template helper (DISPLAY_TIMEZONE, an IANA
+	// name such as "America/Chicago"). Nil means the server's local zone.
+	DisplayLocation *time.Location
+
+	// CheckInTime and CheckOutTime are shown to guests in confirmations and on
+	// the reservation summary, e.g. "3:00 PM" (CHECK_IN_TIME, CHECK_OUT_TIME).
+	CheckInTime  string
+	CheckOutTime string
+
+	// TermsURL is linked from the "I accept the terms" checkbox on the
+	// reservation form (TERMS_URL). Empty shows the label without a link.
+	TermsURL string
+
+	// SlowQueryThreshold is how long a repository call may take before it is
+	// logged to InfoLog as a slow query (SLOW_QUERY_THRESHOLD).
+	SlowQueryThreshold time.Duration
+
+	// BaseURL is the public scheme, host and optional path prefix the site is
+	// served from, e.g. "https://milosresidence.com" (BASE_URL). It is used to
+	// build absolute links in emails and crawler files. Emails with links
+	// (password resets, staff welcomes) are not sent while it is empty;
+	// crawler files fall back to the scheme and host of the current request.
+	BaseURL string
+
+	// PasswordResetTTL is how long a password reset link stays valid
+	// (PASSWORD_RESET_TTL). Zero means the repository default (one hour).
+	PasswordResetTTL time.Duration
+
+	// MailWorkers is how many emails are sent concurrently from MailChan
+	// (MAIL_WORKERS, default 2).
+	MailWorkers int
+
+	// LogMail records every email send attempt and its outcome in the mail
+	// log shown on /admin/mail-log (MAIL_LOG, default true).
+	LogMail bool
+
+	// PropertyName is the name of the property shown in page titles,
+	// headings and emails (PROPERTY_NAME, default DefaultPropertyName).
+	PropertyName string
+
+	// HoldTTL is how long a guest's chosen dates are held while they fill in
+	// the booking form (HOLD_TTL). Zero disables holds.
+	HoldTTL time.Duration
+
+	// Server timeouts applied to the HTTP server (SERVER_READ_HEADER_TIMEOUT,
+	// SERVER_READ_TIMEOUT, SERVER_WRITE_TIMEOUT, SERVER_IDLE_TIMEOUT). Zero
+	// selects the default for each.
+	ServerReadHeaderTimeout time.Duration
+	ServerReadTimeout       time.Duration
+	ServerWriteTimeout      time.Duration
+	ServerIdleTimeout       time.Duration
+}
+
+// DefaultPropertyName is used wherever the property is named when
+// PropertyName is not configured.
+const DefaultPropertyName = "Milo's Residence"
+
+// Check-in and check-out times shown to guests when neither a saved setting
+// nor CheckInTime/CheckOutTime is configured.
+const (
+	DefaultCheckInTime  = "3:00 PM"
+	DefaultCheckOutTime = "11:00 AM"
+)
+
+// DefaultContactRecipient receives contact-form mail when ContactRecipients
+// has no address for the topic or for "general".
+const DefaultContactRecipient = "admin@milosresidence.com"
+
+// Property returns PropertyName, or DefaultPropertyName when it is empty.
+func (a *AppConfig) Property() string {
+	if a.PropertyName == "" {
+		return DefaultPropertyName
+	}
+	return a.PropertyName
 }
@@ -0,0 +1,91 @@
+package driver
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// flakyPinger fails its first failures pings, then succeeds.
+type flakyPinger struct {
+	failures int
+	calls    int
+}
+
+func (p *flakyPinger) Ping() error {
+	p.calls++
+	if p.calls <= p.failures {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+// TestPingWithRetry verifies that startup pings are retried with growing
+// backoff until the database answers, and give up after the configured
+// number of attempts.
+func TestPingWithRetry(t *testing.T) {
+	var waits []time.Duration
+	sleep = func(d time.Duration) { waits = append(waits, d) }
+	defer func() { sleep = time.Sleep }()
+
+	t.Run("succeeds after two failures", func(t *testing.T) {
+		waits = nil
+		p := &flakyPinger{failures: 2}
+		n, err := pingWithRetry(p, 5)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if n != 3 || p.calls != 3 {
+			t.Fatalf("attempts: got %d (pinged %d times), want 3", n, p.calls)
+		}
+		want := []time.Duration{initialConnectBackoff, 2 * initialConnectBackoff}
+		if len(waits) != len(want) || waits[0] != want[0] || waits[1] != want[1] {
+			t.Errorf("backoff: got %v, want %v", waits, want)
+		}
+	})
+
+	t.Run("gives up after attempts", func(t *testing.T) {
+		waits = nil
+		p := &flakyPinger{failures: 10}
+		n, err := pingWithRetry(p, 3)
+		if err == nil {
+			t.Fatal("expected error after exhausting attempts")
+		}
+		if n != 3 || p.calls != 3 {
+			t.Fatalf("attempts: got %d (pinged %d times), want 3", n, p.calls)
+		}
+		if len(waits) != 2 {
+			t.Errorf("sleeps: got %d, want 2 (none after the last attempt)", len(waits))
+		}
+	})
+
+	t.Run("backoff is capped", func(t *testing.T) {
+		waits = nil
+		pingWithRetry(&flakyPinger{failures: 10}, 10)
+		for _, w := range waits {
+			if w > maxConnectBackoff {
+				t.Fatalf("backoff %s exceeds cap %s", w, maxConnectBackoff)
+			}
+		}
+	})
+}
+
+// TestConnectSQL_Unreachable verifies that a database which never answers
+// yields an error and leaves no pool published on the shared wrapper.
+func TestConnectSQL_Unreachable(t *testing.T) {
+	sleep = func(time.Duration) {}
+	defer func() { sleep = time.Sleep }()
+	defer func() { dbConn.SQL = nil }()
+
+	dbConn.SQL = nil
+	db, err := ConnectSQL("postgres://u:p@127.0.0.1:1/none?sslmode=disable&connect_timeout=1", 2)
+	if err == nil {
+		t.Fatal("expected error connecting to a closed port")
+	}
+	if db != nil {
+		t.Errorf("db: got %v, want nil", db)
+	}
+	if dbConn.SQL != nil {
+		t.Error("failed connect left a pool on the shared wrapper")
+	}
+}
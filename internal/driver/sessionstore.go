@@ -0,0 +1,104 @@
+package driver
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+)
+
+// defaultSessionCleanupInterval is how often NewSessionStore deletes expired
+// rows from the sessions table.
+const defaultSessionCleanupInterval = 5 * time.Minute
+
+// SessionStore is an scs.Store backed by the sessions table (see the
+// create_sessions migration). Because session data lives in PostgreSQL rather
+// than process memory, sessions survive restarts and are shared by every
+// instance that points at the same database.
+//
+// It mirrors github.com/alexedwards/scs/postgresstore: same table, queries
+// and cleanup behaviour. That module is published separately from scs/v2 and
+// the module proxy this project builds through doesn't serve it, so depending
+// on it would break `go mod download`. Once it can be fetched, swap
+// NewSessionStore for postgresstore.New and delete this file; the sessions
+// table needs no change.
+type SessionStore struct {
+	db          *sql.DB
+	stopCleanup chan bool
+}
+
+// NewSessionStore returns a SessionStore using db and starts a background
+// goroutine that removes expired sessions every five minutes.
+func NewSessionStore(db *sql.DB) *SessionStore {
+	return NewSessionStoreWithCleanupInterval(db, defaultSessionCleanupInterval)
+}
+
+// NewSessionStoreWithCleanupInterval is like NewSessionStore but removes
+// expired sessions every interval. An interval of zero disables cleanup.
+func NewSessionStoreWithCleanupInterval(db *sql.DB, interval time.Duration) *SessionStore {
+	s := &SessionStore{db: db}
+	if interval > 0 {
+		s.stopCleanup = make(chan bool)
+		go s.startCleanup(interval)
+	}
+	return s
+}
+
+// Find returns the data for an unexpired session token. A missing or expired
+// token reports found=false with a nil error.
+func (s *SessionStore) Find(token string) ([]byte, bool, error) {
+	var b []byte
+	row := s.db.QueryRow("SELECT data FROM sessions WHERE token = $1 AND current_timestamp < expiry", token)
+	err := row.Scan(&b)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	return b, true, nil
+}
+
+// Commit stores the session data and expiry, replacing any existing row for
+// the token.
+func (s *SessionStore) Commit(token string, b []byte, expiry time.Time) error {
+	_, err := s.db.Exec(`INSERT INTO sessions (token, data, expiry) VALUES ($1, $2, $3)
+		ON CONFLICT (token) DO UPDATE SET data = EXCLUDED.data, expiry = EXCLUDED.expiry`,
+		token, b, expiry)
+	return err
+}
+
+// Delete removes the session token. Deleting an unknown token is a no-op.
+func (s *SessionStore) Delete(token string) error {
+	_, err := s.db.Exec("DELETE FROM sessions WHERE token = $1", token)
+	return err
+}
+
+// StopCleanup terminates the background cleanup goroutine. It is a no-op when
+// cleanup was disabled.
+func (s *SessionStore) StopCleanup() {
+	if s.stopCleanup != nil {
+		s.stopCleanup <- true
+	}
+}
+
+// startCleanup deletes expired sessions on every tick until StopCleanup.
+func (s *SessionStore) startCleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.deleteExpired(); err != nil {
+				log.Println(err)
+			}
+		case <-s.stopCleanup:
+			return
+		}
+	}
+}
+
+// deleteExpired removes every session whose expiry has passed.
+func (s *SessionStore) deleteExpired() error {
+	_, err := s.db.Exec("DELETE FROM sessions WHERE expiry < current_timestamp")
+	return err
+}
@@ -0,0 +1,151 @@
+package driver
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+)
+
+// fakeSessionDB is a database/sql connector that emulates the sessions table
+// for the statements SessionStore issues, so the store can be exercised
+// without a PostgreSQL server.
+type fakeSessionDB struct {
+	mu   sync.Mutex
+	rows map[string]fakeSession
+}
+
+type fakeSession struct {
+	data   []byte
+	expiry time.Time
+}
+
+func (f *fakeSessionDB) Connect(context.Context) (driver.Conn, error) {
+	return &fakeSessionConn{f}, nil
+}
+func (f *fakeSessionDB) Driver() driver.Driver { return nil }
+
+type fakeSessionConn struct{ f *fakeSessionDB }
+
+func (c *fakeSessionConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+func (c *fakeSessionConn) Close() error              { return nil }
+func (c *fakeSessionConn) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+
+func (c *fakeSessionConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.f.mu.Lock()
+	defer c.f.mu.Unlock()
+	rows := &fakeSessionRows{}
+	if s, ok := c.f.rows[args[0].Value.(string)]; ok && time.Now().Before(s.expiry) {
+		rows.data = [][]byte{s.data}
+	}
+	return rows, nil
+}
+
+func (c *fakeSessionConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.f.mu.Lock()
+	defer c.f.mu.Unlock()
+	switch {
+	case strings.HasPrefix(query, "INSERT"):
+		c.f.rows[args[0].Value.(string)] = fakeSession{data: args[1].Value.([]byte), expiry: args[2].Value.(time.Time)}
+	case strings.Contains(query, "token = $1"):
+		delete(c.f.rows, args[0].Value.(string))
+	default:
+		for token, s := range c.f.rows {
+			if s.expiry.Before(time.Now()) {
+				delete(c.f.rows, token)
+			}
+		}
+	}
+	return driver.RowsAffected(1), nil
+}
+
+type fakeSessionRows struct{ data [][]byte }
+
+func (r *fakeSessionRows) Columns() []string { return []string{"data"} }
+func (r *fakeSessionRows) Close() error      { return nil }
+func (r *fakeSessionRows) Next(dest []driver.Value) error {
+	if len(r.data) == 0 {
+		return io.EOF
+	}
+	dest[0], r.data = r.data[0], r.data[1:]
+	return nil
+}
+
+// TestSessionStore_SharedAcrossManagers verifies that a value written through
+// one session manager is readable by a second manager with its own
+// SessionStore over the same database, as happens across restarts or
+// between instances.
+func TestSessionStore_SharedAcrossManagers(t *testing.T) {
+	db := sql.OpenDB(&fakeSessionDB{rows: map[string]fakeSession{}})
+	defer db.Close()
+
+	newManager := func() *scs.SessionManager {
+		m := scs.New()
+		m.Store = NewSessionStoreWithCleanupInterval(db, 0)
+		return m
+	}
+	writer, reader := newManager(), newManager()
+
+	put := writer.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writer.Put(r.Context(), "flash", "hello from instance one")
+	}))
+	rr := httptest.NewRecorder()
+	put.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	cookies := rr.Result().Cookies()
+	if len(cookies) == 0 {
+		t.Fatal("writer did not set a session cookie")
+	}
+
+	var got string
+	get := reader.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = reader.GetString(r.Context(), "flash")
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookies[0])
+	get.ServeHTTP(httptest.NewRecorder(), req)
+
+	if got != "hello from instance one" {
+		t.Fatalf("reader session value: got %q", got)
+	}
+}
+
+// TestSessionStore_FindDelete covers the Store contract: unknown and expired
+// tokens are not found, and Delete removes a committed token.
+func TestSessionStore_FindDelete(t *testing.T) {
+	db := sql.OpenDB(&fakeSessionDB{rows: map[string]fakeSession{}})
+	defer db.Close()
+	s := NewSessionStoreWithCleanupInterval(db, 0)
+
+	if _, found, err := s.Find("missing"); found || err != nil {
+		t.Fatalf("missing token: found=%v err=%v", found, err)
+	}
+	if err := s.Commit("expired", []byte("x"), time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if _, found, _ := s.Find("expired"); found {
+		t.Error("expired token should not be found")
+	}
+	if err := s.Commit("live", []byte("x"), time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if b, found, _ := s.Find("live"); !found || string(b) != "x" {
+		t.Fatalf("live token: found=%v data=%q", found, b)
+	}
+	if err := s.Delete("live"); err != nil {
+		t.Fatal(err)
+	}
+	if _, found, _ := s.Find("live"); found {
+		t.Error("deleted token should not be found")
+	}
+}
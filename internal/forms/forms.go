@@ -1,12 +1,15 @@
 // Package forms centralizes request form validation with a small API designed
 // for handlers and templates. It wraps url.Values, accumulates errors, and
-// exposes helpers like Required, MinLength, and IsEmail.
+// exposes helpers like Trim, Required, MinLength, MaxLength, PermittedValues,
+// IsEmail, and IsEmailList.
 package forms
 
 import (
 	"fmt"
 	"net/url"
+	"slices"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/asaskevich/govalidator"
 )
@@ -35,6 +38,32 @@ func New(data url.Values) *Form {
 	}
 }
 
+// Trim rewrites each named field in place with leading and trailing whitespace
+// removed, so later validation and reads see canonical values. Call it before
+// validation and read values back through the Form rather than r.Form.
+// Usage: f.Trim("first_name", "email")
+func (f *Form) Trim(fields ...string) {
+	for _, field := range fields {
+		// Rewrite every submitted value for the field, not just the first.
+		for i, v := range f.Values[field] {
+			f.Values[field][i] = strings.TrimSpace(v)
+		}
+	}
+}
+
+// Collapse trims each named field and replaces internal runs of whitespace
+// with a single space, e.g. "  Mary   Ann " becomes "Mary Ann". Intended for
+// names and other single-line free text.
+// Usage: f.Collapse("first_name", "last_name")
+func (f *Form) Collapse(fields ...string) {
+	for _, field := range fields {
+		// strings.Fields splits on any whitespace run and drops the ends.
+		for i, v := range f.Values[field] {
+			f.Values[field][i] = strings.Join(strings.Fields(v), " ")
+		}
+	}
+}
+
 // Required asserts that each named field is present and non-blank.
 // On failure, adds "This field cannot be blank" for each missing field.
 // Usage: f.Required("first_name", "email")
@@ -74,6 +103,31 @@ func (f *Form) MinLength(field string, length int) bool {
 	return true
 }
 
+// MaxLength asserts that field's value is at most length characters long.
+// Characters are counted as runes so multi-byte text is not penalized.
+// Returns false and records an error when the limit is exceeded.
+// Usage: f.MaxLength("notes", 2000)
+func (f *Form) MaxLength(field string, length int) bool {
+	if utf8.RuneCountInString(f.Get(field)) > length {
+		f.Errors.Add(field, fmt.Sprintf("This field must be at most %d characters long", length))
+		return false
+	}
+	return true
+}
+
+// PermittedValues asserts that field's value is one of allowed, for inputs
+// such as selects and route segments that must come from a fixed set. An
+// empty or missing value is rejected unless "" is itself allowed.
+// Returns false and records "Invalid selection" when the value is not allowed.
+// Usage: f.PermittedValues("src", "new", "all", "cal")
+func (f *Form) PermittedValues(field string, allowed ...string) bool {
+	if slices.Contains(allowed, f.Get(field)) {
+		return true
+	}
+	f.Errors.Add(field, "Invalid selection")
+	return false
+}
+
 // IsEmail asserts that field contains a syntactically valid email address.
 // Uses govalidator.IsEmail for format validation; records an error on failure.
 // Usage: f.IsEmail("email")
@@ -83,3 +137,30 @@ func (f *Form) IsEmail(field string) {
 		f.Errors.Add(field, "Invalid email address")
 	}
 }
+
+// IsEmailList asserts that field holds a comma-separated list of one or more
+// syntactically valid email addresses, e.g. "a@x.com, b@x.com". Entries are
+// trimmed and blank entries (such as a trailing comma) are ignored. On failure
+// the recorded error names each invalid entry.
+// Usage: f.IsEmailList("notification_emails")
+func (f *Form) IsEmailList(field string) {
+	var count int
+	var invalid []string
+	for _, entry := range strings.Split(f.Get(field), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		count++
+		if !govalidator.IsEmail(entry) {
+			invalid = append(invalid, entry)
+		}
+	}
+
+	switch {
+	case count == 0:
+		f.Errors.Add(field, "Enter at least one email address")
+	case len(invalid) > 0:
+		f.Errors.Add(field, fmt.Sprintf("Invalid email address: %s", strings.Join(invalid, ", ")))
+	}
+}
@@ -102,6 +102,58 @@ func TestForm_MinLength(t *testing.T) {
 	}
 }
 
+// TestForm_MaxLength ensures MaxLength() passes values at or under the limit,
+// counting runes rather than bytes, and flags longer values.
+func TestForm_MaxLength(t *testing.T) {
+	postedValues := url.Values{}
+	postedValues.Add("notes", "héllo")
+	form := New(postedValues)
+
+	if !form.MaxLength("notes", 5) || !form.MaxLength("missing", 0) {
+		t.Error("shows max length exceeded when it is not")
+	}
+	if !form.Valid() {
+		t.Error("form should be valid")
+	}
+
+	if form.MaxLength("notes", 4) {
+		t.Error("shows max length of 4 is met when it is not")
+	}
+	if form.Errors.Get("notes") == "" {
+		t.Error("should have an error, but did not get one")
+	}
+}
+
+// TestForm_PermittedValues ensures PermittedValues() accepts a value from the
+// allowed set and records "Invalid selection" for anything else, including an
+// empty value.
+func TestForm_PermittedValues(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  bool
+	}{
+		{name: "allowed", value: "cal", want: true},
+		{name: "disallowed", value: "../evil", want: false},
+		{name: "empty", value: "", want: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			form := New(url.Values{"src": {tc.value}})
+			if got := form.PermittedValues("src", "new", "all", "cal"); got != tc.want {
+				t.Fatalf("PermittedValues(%q): got %v, want %v", tc.value, got, tc.want)
+			}
+			if got := form.Errors.Get("src"); (got == "") != tc.want {
+				t.Fatalf("error: got %q", got)
+			}
+			if !tc.want && form.Errors.Get("src") != "Invalid selection" {
+				t.Fatalf("error message: got %q", form.Errors.Get("src"))
+			}
+		})
+	}
+}
+
 // TestForm_IsEmail validates that IsEmail() fails for empty/invalid values
 // and passes for syntactically valid email addresses.
 func TestForm_IsEmail(t *testing.T) {
@@ -131,3 +183,67 @@ func TestForm_IsEmail(t *testing.T) {
 		t.Error("got a valid email for an invalid email")
 	}
 }
+
+// TestForm_Trim verifies Trim() strips leading/trailing whitespace from every
+// value of the named fields and leaves other fields untouched.
+func TestForm_Trim(t *testing.T) {
+	posted := url.Values{}
+	posted.Add("email", "  me@here.com\t")
+	posted.Add("tags", " a ")
+	posted.Add("tags", "b  ")
+	posted.Add("password", " secret ")
+
+	form := New(posted)
+	form.Trim("email", "tags", "missing")
+
+	if got := form.Get("email"); got != "me@here.com" {
+		t.Errorf("email: got %q, want %q", got, "me@here.com")
+	}
+	if got := form.Values["tags"]; got[0] != "a" || got[1] != "b" {
+		t.Errorf("tags: got %q, want [a b]", got)
+	}
+	if got := form.Get("password"); got != " secret " {
+		t.Errorf("untrimmed field changed: got %q", got)
+	}
+	if _, ok := form.Values["missing"]; ok {
+		t.Error("Trim should not create missing fields")
+	}
+}
+
+// TestForm_Collapse verifies Collapse() trims and squeezes internal whitespace.
+func TestForm_Collapse(t *testing.T) {
+	posted := url.Values{}
+	posted.Add("first_name", "  Mary   Ann \t ")
+
+	form := New(posted)
+	form.Collapse("first_name")
+
+	if got := form.Get("first_name"); got != "Mary Ann" {
+		t.Errorf("first_name: got %q, want %q", got, "Mary Ann")
+	}
+}
+
+// TestForm_IsEmailList verifies IsEmailList() accepts a list of valid
+// addresses, names the invalid entry in a mixed list, and rejects an empty
+// field.
+func TestForm_IsEmailList(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantErr string
+	}{
+		{name: "all valid", value: "front@milos.com, owner@milos.com,"},
+		{name: "one bad entry", value: "front@milos.com, not-an-email , owner@milos.com", wantErr: "Invalid email address: not-an-email"},
+		{name: "empty", value: " , ", wantErr: "Enter at least one email address"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			form := New(url.Values{"emails": {tc.value}})
+			form.IsEmailList("emails")
+			if got := form.Errors.Get("emails"); got != tc.wantErr {
+				t.Fatalf("error: got %q, want %q", got, tc.wantErr)
+			}
+		})
+	}
+}
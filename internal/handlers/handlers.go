@@ -4,11 +4,22 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
 	"encoding/json"
+	"encoding/xml"
 	"errors"
 	"fmt"
+	"html/template"
 	"log"
 	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -22,6 +33,7 @@ import (
 	"github.com/bensabler/milos-residence/internal/repository"
 	"github.com/bensabler/milos-residence/internal/repository/dbrepo"
 	"github.com/go-chi/chi/v5"
+	"github.com/justinas/nosurf"
 )
 
 // Repo is the global repository instance used by all handlers.
@@ -36,6 +48,9 @@ var Repo *Repository
 type Repository struct {
 	App *config.AppConfig       // Application configuration and shared services
 	DB  repository.DatabaseRepo // Database operations interface
+
+	settings *settingsCache   // Runtime settings read through GetSetting
+	now      func() time.Time // Clock for date rules; tests substitute a fixed time
 }
 
 // NewRepo creates a new Repository instance with the provided application configuration
@@ -51,6 +66,9 @@ func NewRepo(a *config.AppConfig, db *driver.DB) *Repository {
 	return &Repository{
 		App: a,
 		DB:  dbrepo.NewPostgresRepo(db.SQL, a),
+
+		settings: newSettingsCache(),
+		now:      time.Now,
 	}
 }
 
@@ -66,7 +84,30 @@ func NewTestRepo(a *config.AppConfig) *Repository {
 	return &Repository{
 		App: a,
 		DB:  dbrepo.NewTestingRepo(a),
+
+		settings: newSettingsCache(),
+		now:      time.Now,
+	}
+}
+
+// today returns the current date (from m.now) as midnight UTC, the form
+// dates parsed from MM/DD/YYYY fields take.
+func (m *Repository) today() time.Time {
+	now := m.now()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// requireSession reports whether the request has session data loaded. When it
+// doesn't, which means the route skipped the SessionLoad middleware, it logs
+// helpers.ErrNoSession and redirects to the home page, so handlers that keep
+// state in the session fail with a redirect instead of an scs panic.
+func (m *Repository) requireSession(w http.ResponseWriter, r *http.Request) bool {
+	if _, err := helpers.SessionFromContext(r.Context(), m.App.Session); err != nil {
+		m.App.ErrorLog.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return false
 	}
+	return true
 }
 
 // NewHandlers sets the global repository instance for use by handler functions.
@@ -80,11 +121,9 @@ func NewHandlers(r *Repository) {
 }
 
 // Home handles GET requests to the homepage route (/).
-// It renders the home page template with basic template data,
-// demonstrating a simple handler that calls a database method
-// and renders a template without complex business logic.
+// It renders the home page template with basic template data and does not
+// touch the database.
 func (m *Repository) Home(w http.ResponseWriter, r *http.Request) {
-	m.DB.AllUsers()
 	render.Template(w, r, "home.page.tmpl", &models.TemplateData{})
 }
 
@@ -106,19 +145,22 @@ func (m *Repository) Photos(w http.ResponseWriter, r *http.Request) {
 // It retrieves reservation data from the user session, validates the room exists,
 // and renders the reservation form with pre-populated data. If the session
 // doesn't contain valid reservation data or the room cannot be found,
-// it redirects to the home page with an error message.
+// it redirects to the home page with an error message, or without one when
+// the route has no session loaded.
 func (m *Repository) MakeReservation(w http.ResponseWriter, r *http.Request) {
+	if !m.requireSession(w, r) {
+		return
+	}
+
 	res, ok := m.App.Session.Get(r.Context(), "reservation").(models.Reservation)
 	if !ok {
-		m.App.Session.Put(r.Context(), "error", "can't get reservation from session")
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		helpers.RedirectWithError(w, r, m.App.Session, "/", "can't get reservation from session")
 		return
 	}
 
 	room, err := m.DB.GetRoomByID(res.RoomID)
 	if err != nil {
-		m.App.Session.Put(r.Context(), "error", "can't find room!")
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		helpers.RedirectWithError(w, r, m.App.Session, "/", "can't find room!")
 		return
 	}
 
@@ -132,6 +174,7 @@ func (m *Repository) MakeReservation(w http.ResponseWriter, r *http.Request) {
 	stringMap := make(map[string]string)
 	stringMap["start_date"] = sd
 	stringMap["end_date"] = ed
+	stringMap["terms_url"] = m.App.TermsURL
 
 	data := make(map[string]interface{})
 	data["reservation"] = res
@@ -152,73 +195,93 @@ func (m *Repository) MakeReservation(w http.ResponseWriter, r *http.Request) {
 // it re-renders the form with error messages.
 //
 // The handler performs the following steps:
-// 1. Parses and validates form data including dates and guest information
-// 2. Validates required fields and data formats using the forms package
-// 3. Creates reservation and room restriction records in the database
-// 4. Sends confirmation email to guest and notification email to staff
-// 5. Stores reservation in session and redirects to summary page
+//  1. Parses and validates form data including dates and guest information
+//  2. Validates required fields and data formats using the forms package,
+//     requires the terms checkbox (stamping TermsAcceptedAt), rejects a start
+//     date beyond the advance-booking window or inside the room's lead time,
+//     and checks the stay against the booking rules shared with QuoteAPI and
+//     against the guest's existing bookings (duplicate submissions)
+//  3. Creates reservation and room restriction records in the database,
+//     then releases the hold taken when the room was chosen (see holdDates)
+//  4. Sends confirmation email to guest, localized by requestLocale, and
+//     notification email to staff
+//  5. Stores reservation in session and redirects to summary page
 func (m *Repository) PostReservation(w http.ResponseWriter, r *http.Request) {
 
 	err := r.ParseForm()
 	if err != nil {
-		m.App.Session.Put(r.Context(), "error", "can't parse form!")
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		if helpers.BodyTooLarge(w, err) {
+			return
+		}
+		helpers.RedirectWithError(w, r, m.App.Session, "/", "can't parse form!")
 		return
 	}
 
-	sd := r.Form.Get("start_date")
-	ed := r.Form.Get("end_date")
+	// Canonicalize input before parsing and validation.
+	form := forms.New(r.PostForm)
+	form.Trim("start_date", "end_date", "room_id", "email", "phone", "guests")
+	form.Collapse("first_name", "last_name")
+
+	sd := form.Get("start_date")
+	ed := form.Get("end_date")
 
 	layout := "01/02/2006"
 
 	startDate, err := time.Parse(layout, sd)
 	if err != nil {
-		m.App.Session.Put(r.Context(), "error", "can't parse start date")
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		helpers.RedirectWithError(w, r, m.App.Session, "/", "can't parse start date")
 		return
 	}
 
 	endDate, err := time.Parse(layout, ed)
 	if err != nil {
-		m.App.Session.Put(r.Context(), "error", "can't get parse end date")
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		helpers.RedirectWithError(w, r, m.App.Session, "/", "can't get parse end date")
 		return
 	}
 
-	roomID, err := strconv.Atoi(r.Form.Get("room_id"))
+	roomID, err := strconv.Atoi(form.Get("room_id"))
 	if err != nil {
-		m.App.Session.Put(r.Context(), "error", "invalid data!")
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		helpers.RedirectWithError(w, r, m.App.Session, "/", "invalid data!")
 		return
 	}
 
+	// The party size is optional on the form and defaults to one guest.
+	guests := 1
+	if g := form.Get("guests"); g != "" {
+		guests, err = strconv.Atoi(g)
+		if err != nil {
+			helpers.RedirectWithError(w, r, m.App.Session, "/", "invalid data!")
+			return
+		}
+	}
+
 	reservation := models.Reservation{
-		FirstName: r.Form.Get("first_name"),
-		LastName:  r.Form.Get("last_name"),
-		Phone:     r.Form.Get("phone"),
-		Email:     r.Form.Get("email"),
+		FirstName: form.Get("first_name"),
+		LastName:  form.Get("last_name"),
+		Phone:     form.Get("phone"),
+		Email:     form.Get("email"),
 		StartDate: startDate,
 		EndDate:   endDate,
 		RoomID:    roomID,
 	}
 
-	form := forms.New(r.PostForm)
-
-	form.Required("first_name", "last_name", "email", "phone")
-	form.MinLength("first_name", 3)
-	form.IsEmail("email")
+	// The room is needed for its lead time and for re-rendering the form.
+	room, err := m.DB.GetRoomByID(roomID)
+	if err != nil {
+		helpers.RedirectWithError(w, r, m.App.Session, "/", "can't find room!")
+		return
+	}
 
-	if !form.Valid() {
-		// Get room info for re-rendering the form
-		room, err := m.DB.GetRoomByID(roomID)
-		if err != nil {
-			m.App.Session.Put(r.Context(), "error", "can't find room!")
-			http.Redirect(w, r, "/", http.StatusSeeOther)
-			return
-		}
+	reservation.Room.RoomName = room.RoomName
 
-		reservation.Room.RoomName = room.RoomName
+	validateGuestFields(form)
+	m.checkAdvanceWindow(form, startDate)
+	m.checkLeadTime(form, startDate, room)
+	if form.Get("accept_terms") != "" {
+		reservation.TermsAcceptedAt = time.Now().UTC()
+	}
 
+	if !form.Valid() {
 		data := make(map[string]interface{})
 		data["reservation"] = reservation
 
@@ -228,6 +291,7 @@ func (m *Repository) PostReservation(w http.ResponseWriter, r *http.Request) {
 		stringMap := make(map[string]string)
 		stringMap["start_date"] = sd
 		stringMap["end_date"] = ed
+		stringMap["terms_url"] = m.App.TermsURL
 
 		// Re-render the form with validation errors (200 status)
 		render.Template(w, r, "make-reservation.page.tmpl", &models.TemplateData{
@@ -238,19 +302,31 @@ func (m *Repository) PostReservation(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	room, err := m.DB.GetRoomByID(roomID)
+	q, err := m.quote(reservation, guests)
 	if err != nil {
-		m.App.Session.Put(r.Context(), "error", "can't find room!")
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		helpers.RedirectWithError(w, r, m.App.Session, "/", "can't calculate price!")
+		return
+	}
+	if !q.OK {
+		helpers.RedirectWithError(w, r, m.App.Session, "/", strings.Join(q.failures(), "; "))
 		return
 	}
+	reservation.Total = q.Total
 
-	reservation.Room.RoomName = room.RoomName
+	// A double-submitted form would otherwise store an identical second booking.
+	exists, err := m.DB.ReservationExists(reservation.Email, roomID, startDate, endDate)
+	if err != nil {
+		helpers.RedirectWithError(w, r, m.App.Session, "/", "can't check for existing reservations!")
+		return
+	}
+	if exists {
+		helpers.RedirectWithError(w, r, m.App.Session, "/", "You already have a booking for these dates.")
+		return
+	}
 
 	newReservationID, err := m.DB.InsertReservation(reservation)
 	if err != nil {
-		m.App.Session.Put(r.Context(), "error", "can't insert reservation into database!")
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		helpers.RedirectWithError(w, r, m.App.Session, "/", "can't insert reservation into database!")
 		return
 	}
 
@@ -264,65 +340,131 @@ func (m *Repository) PostReservation(w http.ResponseWriter, r *http.Request) {
 
 	err = m.DB.InsertRoomRestriction(restriction)
 	if err != nil {
-		m.App.Session.Put(r.Context(), "error", "can't insert room restriction!")
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		helpers.RedirectWithError(w, r, m.App.Session, "/", "can't insert room restriction!")
 		return
 	}
 
-	htmlMessage := fmt.Sprintf(`
-			<strong>Reservation Confirmation</strong><br>
-			Dear %s, <br>
-			This is to confirm your reservation from %s to %s.
-	`, reservation.FirstName, reservation.StartDate.Format("01/02/2006"), reservation.EndDate.Format("01/02/2006"))
+	// The reservation's restriction now covers the dates, so the hold taken
+	// when the room was chosen can go.
+	m.releaseHold(r)
 
-	msg := models.MailData{
-		To:       reservation.Email,
-		From:     "milo@milos-residence.com",
-		Subject:  "Reservation Confirmation",
-		Content:  htmlMessage,
-		Template: "basic.html",
-	}
+	// The guest hears back in their language; staff notifications always use
+	// the default locale.
+	checkIn, checkOut := m.checkInOutTimes()
+	m.queueMail(reservationEmails(m.App.Property(), reservation, requestLocale(r), checkIn, checkOut, m.notificationRecipients()))
 
-	m.App.MailChan <- msg
+	m.App.Session.Put(r.Context(), "reservation", reservation)
 
-	htmlMessage = fmt.Sprintf(`
-			<strong>Reservation Notification</strong><br>
-			A reservation has been made at Milo's Residence for the %s snooze spot from %s to %s.
-	`, reservation.Room.RoomName, reservation.StartDate.Format("01/02/2006"), reservation.EndDate.Format("01/02/2006"))
+	http.Redirect(w, r, "/reservation-summary", http.StatusSeeOther)
+}
 
-	msg = models.MailData{
-		To:      "you@there.com",
-		From:    "milo@milos-residence.com",
-		Subject: "Reservation Notification",
-		Content: htmlMessage,
+// validateGuestFields applies the guest-detail rules shared by the booking
+// form and POST /api/reservations: name, email and phone are required, the
+// first name needs at least three characters, the email must be valid and
+// the terms must be accepted.
+func validateGuestFields(form *forms.Form) {
+	form.Required("first_name", "last_name", "email", "phone")
+	form.MinLength("first_name", 3)
+	form.IsEmail("email")
+	if form.Get("accept_terms") == "" {
+		form.Errors.Add("accept_terms", "You must accept the terms to make a reservation")
 	}
+}
 
-	m.App.MailChan <- msg
-
-	m.App.Session.Put(r.Context(), "reservation", reservation)
+// RoomRoute describes one public room page: the URL path it is served at, the
+// page template that renders it, and the ID of the room it shows. Pages are
+// tied to the room's ID rather than its name, so staff can rename a room
+// without detaching it from its page.
+type RoomRoute struct {
+	Path     string // URL path, e.g. "/golden-haybeam-loft"
+	Template string // Page template, e.g. "golden-haybeam-loft.page.tmpl"
+	RoomID   int    // rooms.id used to load the room's data
+}
 
-	http.Redirect(w, r, "/reservation-summary", http.StatusSeeOther)
+// RoomRoutes lists the public room pages registered by the router. Adding a
+// room page means adding its template and an entry here; no new handler.
+// The IDs are those the seed migration gives the three original rooms.
+var RoomRoutes = []RoomRoute{
+	{Path: "/golden-haybeam-loft", Template: "golden-haybeam-loft.page.tmpl", RoomID: 1},
+	{Path: "/window-perch-theater", Template: "window-perch-theater.page.tmpl", RoomID: 2},
+	{Path: "/laundry-basket-nook", Template: "laundry-basket-nook.page.tmpl", RoomID: 3},
 }
 
-// GoldenHaybeamLoft handles GET requests to display the Golden Haybeam Loft room page.
-// It renders a detailed page showcasing this specific room with its amenities,
-// photos, and booking options.
-func (m *Repository) GoldenHaybeamLoft(w http.ResponseWriter, r *http.Request) {
-	render.Template(w, r, "golden-haybeam-loft.page.tmpl", &models.TemplateData{})
+// RoomPage returns the handler for a public room page. It renders page.Template
+// and, when the room can be found by ID, passes it to the template as
+// Data["room"] along with its gallery as Data["images"]. A failed room or
+// gallery lookup is logged and the page still renders, since the templates
+// carry their own static copy and photos.
+//
+// Usage:
+//
+//	for _, page := range handlers.RoomRoutes {
+//		mux.Get(page.Path, handlers.Repo.RoomPage(page))
+//	}
+func (m *Repository) RoomPage(page RoomRoute) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data := make(map[string]interface{})
+
+		room, err := m.DB.GetRoomByID(page.RoomID)
+		if err != nil {
+			m.App.ErrorLog.Println(err)
+		} else {
+			data["room"] = room
+
+			images, err := m.DB.ImagesForRoom(room.ID)
+			if err != nil {
+				m.App.ErrorLog.Println(err)
+			}
+			data["images"] = images
+		}
+
+		render.Template(w, r, page.Template, &models.TemplateData{
+			Data: data,
+		})
+	}
 }
 
-// WindowPerchTheater handles GET requests to display the Window Perch Theater room page.
-// It renders a detailed page showcasing this specific room with its amenities,
-// photos, and booking options.
-func (m *Repository) WindowPerchTheater(w http.ResponseWriter, r *http.Request) {
-	render.Template(w, r, "window-perch-theater.page.tmpl", &models.TemplateData{})
+// roomListing is one row of the /rooms page.
+type roomListing struct {
+	Room          models.Room
+	Path          string    // Public room page, empty when the room has none
+	NextAvailable time.Time // First free night; zero when none within the horizon
 }
 
-// LaundryBasketNook handles GET requests to display the Laundry Basket Nook room page.
-// It renders a detailed page showcasing this specific room with its amenities,
-// photos, and booking options.
-func (m *Repository) LaundryBasketNook(w http.ResponseWriter, r *http.Request) {
-	render.Template(w, r, "laundry-basket-nook.page.tmpl", &models.TemplateData{})
+// Rooms handles GET /rooms and lists every open room with the first night it
+// is free, starting today, so guests can find the soonest opening. Rooms booked
+// through the next repository.NextAvailableHorizonDays show as unavailable.
+func (m *Repository) Rooms(w http.ResponseWriter, r *http.Request) {
+	rooms, err := m.DB.AllRooms()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	paths := make(map[int]string, len(RoomRoutes))
+	for _, page := range RoomRoutes {
+		paths[page.RoomID] = page.Path
+	}
+
+	today := m.today()
+
+	listings := make([]roomListing, 0, len(rooms))
+	for _, room := range rooms {
+		if !room.Active {
+			continue
+		}
+		next, err := m.DB.NextAvailableDate(room.ID, today)
+		if err != nil && !errors.Is(err, repository.ErrNoAvailability) {
+			helpers.ServerError(w, err)
+			return
+		}
+		listings = append(listings, roomListing{Room: room, Path: paths[room.ID], NextAvailable: next})
+	}
+
+	render.Template(w, r, "rooms.page.tmpl", &models.TemplateData{
+		Data:   map[string]interface{}{"rooms": listings},
+		IntMap: map[string]int{"horizon_days": repository.NextAvailableHorizonDays},
+	})
 }
 
 // Availability handles GET requests to display the availability search form.
@@ -342,11 +484,27 @@ func (m *Repository) Availability(w http.ResponseWriter, r *http.Request) {
 // 2. Queries the database for rooms available during the date range
 // 3. If rooms are found, stores search criteria in session and shows room selection
 // 4. If no rooms are available, redirects back to search with error message
+//
+// Results are listed AppConfig.RoomsPerPage at a time (default
+// defaultRoomsPerPage). The page field selects which; the template's page
+// links post the same dates back with a different page. Each listed room
+// shows the first image of its gallery, loaded lazily.
+//
+// Clients that send Accept: application/json (see helpers.WantsJSON) get the
+// result as availabilityResults instead, with failures in the apiError
+// envelope; nothing is written to the session.
 func (m *Repository) PostAvailability(w http.ResponseWriter, r *http.Request) {
+	if helpers.WantsJSON(r) {
+		m.postAvailabilityJSON(w, r)
+		return
+	}
+
 	err := r.ParseForm()
 	if err != nil {
-		m.App.Session.Put(r.Context(), "error", "can't parse form!")
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		if helpers.BodyTooLarge(w, err) {
+			return
+		}
+		helpers.RedirectWithError(w, r, m.App.Session, "/", "can't parse form!")
 		return
 	}
 
@@ -356,33 +514,52 @@ func (m *Repository) PostAvailability(w http.ResponseWriter, r *http.Request) {
 	layout := "01/02/2006"
 	startDate, err := time.Parse(layout, start)
 	if err != nil {
-		m.App.Session.Put(r.Context(), "error", "can't parse start date!")
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		helpers.RedirectWithError(w, r, m.App.Session, "/", "can't parse start date!")
 		return
 	}
 
 	endDate, err := time.Parse(layout, end)
 	if err != nil {
-		m.App.Session.Put(r.Context(), "error", "can't parse end date!")
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		helpers.RedirectWithError(w, r, m.App.Session, "/", "can't parse end date!")
 		return
 	}
 
 	rooms, err := m.DB.SearchAvailabilityForAllRooms(startDate, endDate)
 	if err != nil {
-		m.App.Session.Put(r.Context(), "error", "can't get availability for rooms")
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		helpers.RedirectWithError(w, r, m.App.Session, "/", "can't get availability for rooms")
 		return
 	}
 
 	if len(rooms) == 0 {
-		m.App.Session.Put(r.Context(), "error", "No availability")
-		http.Redirect(w, r, "/search-availability", http.StatusSeeOther)
+		// Offer the waitlist with the searched dates already filled in.
+		q := url.Values{}
+		q.Set("start", start)
+		q.Set("end", end)
+		helpers.RedirectWithError(w, r, m.App.Session, "/waitlist?"+q.Encode(), "No availability")
 		return
 	}
 
+	pages := newPagination(len(rooms), parsePage(r.Form.Get("page")), m.App.RoomsPerPage)
+	first, last := pages.Bounds()
+	rooms = rooms[first:last]
+
+	// Only the rooms on this page need a cover image.
+	covers := make(map[int]models.RoomImage)
+	for _, room := range rooms {
+		images, err := m.DB.ImagesForRoom(room.ID)
+		if err != nil {
+			m.App.ErrorLog.Println(err)
+			continue
+		}
+		if len(images) > 0 {
+			covers[room.ID] = images[0]
+		}
+	}
+
 	data := make(map[string]interface{})
 	data["rooms"] = rooms
+	data["covers"] = covers
+	data["pagination"] = pages
 
 	res := models.Reservation{
 		StartDate: startDate,
@@ -391,11 +568,214 @@ func (m *Repository) PostAvailability(w http.ResponseWriter, r *http.Request) {
 
 	m.App.Session.Put(r.Context(), "reservation", res)
 
+	// Echoed into each room's calendar download link.
+	stringMap := make(map[string]string)
+	stringMap["start"] = start
+	stringMap["end"] = end
+
 	render.Template(w, r, "choose-room.page.tmpl", &models.TemplateData{
-		Data: data,
+		StringMap: stringMap,
+		Data:      data,
+	})
+}
+
+// flexibleWindow is one free window on the flexible-dates results page.
+type flexibleWindow struct {
+	Range   models.DateRange // Free nights in the month
+	BookURL string           // Books the first requested nights of Range
+}
+
+// flexibleResult lists a room's free windows for a flexible-dates search.
+type flexibleResult struct {
+	Room    models.Room
+	Windows []flexibleWindow
+}
+
+// PostFlexibleAvailability handles POST /search-flexible, for guests who want
+// "any 3 nights in March" rather than fixed dates. The form carries month
+// (YYYY-MM) and nights; each open room is scanned with FindAvailableWindows
+// and every gap of at least that many nights is listed with a link that books
+// its first nights. Nights before today are never offered.
+//
+// Invalid input (unparseable month, a month already over, or nights outside
+// 1 to the length of the month) redirects back to /search-availability with
+// an error, as does a failed lookup. A search with no matches renders the
+// results page with a message rather than redirecting.
+func (m *Repository) PostFlexibleAvailability(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		if helpers.BodyTooLarge(w, err) {
+			return
+		}
+		helpers.RedirectWithError(w, r, m.App.Session, "/search-availability", "can't parse form!")
+		return
+	}
+
+	month, err := time.Parse("2006-01", r.Form.Get("month"))
+	if err != nil {
+		helpers.RedirectWithError(w, r, m.App.Session, "/search-availability", "Choose a month to search")
+		return
+	}
+
+	today := m.today()
+	if month.Before(time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)) {
+		helpers.RedirectWithError(w, r, m.App.Session, "/search-availability", "Choose this month or a later one")
+		return
+	}
+
+	daysInMonth := month.AddDate(0, 1, -1).Day()
+	nights, err := strconv.Atoi(r.Form.Get("nights"))
+	if err != nil || nights < 1 || nights > daysInMonth {
+		helpers.RedirectWithError(w, r, m.App.Session, "/search-availability",
+			fmt.Sprintf("Nights must be between 1 and %d", daysInMonth))
+		return
+	}
+
+	rooms, err := m.DB.AllRooms()
+	if err != nil {
+		m.App.ErrorLog.Println(err)
+		helpers.RedirectWithError(w, r, m.App.Session, "/search-availability", "can't get availability for rooms")
+		return
+	}
+
+	results := make([]flexibleResult, 0, len(rooms))
+	for _, room := range rooms {
+		if !room.Active {
+			continue
+		}
+		windows, err := m.DB.FindAvailableWindows(room.ID, month, nights)
+		if err != nil {
+			m.App.ErrorLog.Println(err)
+			helpers.RedirectWithError(w, r, m.App.Session, "/search-availability", "can't get availability for rooms")
+			return
+		}
+
+		result := flexibleResult{Room: room}
+		for _, win := range windows {
+			if win.Start.Before(today) {
+				win.Start = today
+			}
+			if win.Nights() < nights {
+				continue
+			}
+			q := url.Values{}
+			q.Set("id", strconv.Itoa(room.ID))
+			q.Set("s", win.Start.Format("01/02/2006"))
+			q.Set("e", win.Start.AddDate(0, 0, nights).Format("01/02/2006"))
+			result.Windows = append(result.Windows, flexibleWindow{Range: win, BookURL: "/book-room?" + q.Encode()})
+		}
+		if len(result.Windows) > 0 {
+			results = append(results, result)
+		}
+	}
+
+	render.Template(w, r, "search-flexible.page.tmpl", &models.TemplateData{
+		Data:      map[string]interface{}{"results": results},
+		StringMap: map[string]string{"month": month.Format("January 2006")},
+		IntMap:    map[string]int{"nights": nights},
+	})
+}
+
+// availabilityResults is the JSON form of a PostAvailability search. Dates are
+// echoed as submitted, like jsonResponse; ok is false when no room is free.
+type availabilityResults struct {
+	OK        bool          `json:"ok"`         // Whether any room is available
+	StartDate string        `json:"start_date"` // Arrival as submitted (MM/DD/YYYY)
+	EndDate   string        `json:"end_date"`   // Departure as submitted (MM/DD/YYYY)
+	Rooms     []models.Room `json:"rooms"`      // Rooms free for the whole stay
+}
+
+// postAvailabilityJSON answers PostAvailability for clients that asked for
+// JSON: 200 with availabilityResults, 400 invalid_input for bad form data or
+// dates, 413 request_too_large and 500 server_error if the search fails.
+func (m *Repository) postAvailabilityJSON(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		writeParseFormError(w, err)
+		return
+	}
+
+	start := r.Form.Get("start")
+	end := r.Form.Get("end")
+
+	layout := "01/02/2006"
+	startDate, err := time.Parse(layout, start)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, errCodeInvalidInput, "Invalid start date")
+		return
+	}
+	endDate, err := time.Parse(layout, end)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, errCodeInvalidInput, "Invalid end date")
+		return
+	}
+
+	rooms, err := m.DB.SearchAvailabilityForAllRooms(startDate, endDate)
+	if err != nil {
+		m.App.ErrorLog.Println(err)
+		writeAPIError(w, http.StatusInternalServerError, errCodeServer, "Error querying database")
+		return
+	}
+	if rooms == nil {
+		rooms = []models.Room{}
+	}
+
+	writeJSON(w, http.StatusOK, availabilityResults{
+		OK:        len(rooms) > 0,
+		StartDate: start,
+		EndDate:   end,
+		Rooms:     rooms,
 	})
 }
 
+// AvailabilityICal handles GET /search-availability.ics?room_id=&start=&end=,
+// letting a guest save a prospective stay from the search results to their
+// calendar. It returns an iCalendar file with one all-day TENTATIVE event
+// for the room from start (MM/DD/YYYY) to the check-out date end. Nothing is
+// booked or held.
+//
+// Responses:
+//   - 200 text/calendar attachment
+//   - 400 if the room id or dates are malformed, or end is not after start
+//   - 404 if the room does not exist; 500 if it cannot be loaded
+func (m *Repository) AvailabilityICal(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	layout := "01/02/2006"
+	startDate, err := time.Parse(layout, q.Get("start"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	}
+	endDate, err := time.Parse(layout, q.Get("end"))
+	if err != nil || !endDate.After(startDate) {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	}
+	roomID, err := strconv.Atoi(q.Get("room_id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	room, err := m.DB.GetRoomByID(roomID)
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	} else if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	writeICal(w, "milos-residence-stay.ics", []icalEvent{{
+		UID:     fmt.Sprintf("stay-%d-%s-%s@milosresidence.com", room.ID, startDate.Format("20060102"), endDate.Format("20060102")),
+		Summary: fmt.Sprintf("%s: %s (tentative)", m.App.Property(), room.RoomName),
+		Start:   startDate,
+		End:     endDate,
+		Status:  "TENTATIVE",
+	}})
+}
+
 // jsonResponse represents the structure of JSON responses returned by the AvailabilityJSON handler.
 // It provides a consistent format for AJAX availability checking requests,
 // including success status, error messages, and booking details.
@@ -407,6 +787,10 @@ type jsonResponse struct {
 	EndDate   string `json:"end_date"`   // Formatted end date
 }
 
+// zeroNightsMessage is returned by AvailabilityJSON when the end date is not
+// after the start date.
+const zeroNightsMessage = "select at least one night"
+
 // AvailabilityJSON handles POST requests for AJAX availability checking.
 // It processes room availability requests and returns JSON responses
 // indicating whether the specified room is available for the given dates.
@@ -415,19 +799,18 @@ type jsonResponse struct {
 //
 // The response includes:
 // - ok: boolean indicating availability
-// - message: error message if request failed
 // - room_id, start_date, end_date: echoed back for frontend processing
+//
+// An end date that is not after the start date is answered with ok=false and
+// the message "select at least one night" without querying the database.
+//
+// Failures use the apiError envelope: 400 invalid_input for unparseable form
+// data, dates or room, 413 request_too_large, and 500 server_error when the
+// availability query fails.
 func (m *Repository) AvailabilityJSON(w http.ResponseWriter, r *http.Request) {
 	err := r.ParseForm()
 	if err != nil {
-		resp := jsonResponse{
-			OK:      false,
-			Message: "Internal server error",
-		}
-
-		out, _ := json.MarshalIndent(resp, "", "     ")
-		w.Header().Set("Content-Type", "application/json")
-		w.Write(out)
+		writeParseFormError(w, err)
 		return
 	}
 
@@ -435,147 +818,450 @@ func (m *Repository) AvailabilityJSON(w http.ResponseWriter, r *http.Request) {
 	ed := r.Form.Get("end")
 
 	layout := "01/02/2006"
-	startDate, _ := time.Parse(layout, sd)
-	endDate, _ := time.Parse(layout, ed)
+	startDate, err := time.Parse(layout, sd)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, errCodeInvalidInput, "Invalid start date")
+		return
+	}
+	endDate, err := time.Parse(layout, ed)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, errCodeInvalidInput, "Invalid end date")
+		return
+	}
+
+	roomID, err := strconv.Atoi(r.Form.Get("room_id"))
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, errCodeInvalidInput, "Invalid room")
+		return
+	}
 
-	roomID, _ := strconv.Atoi(r.Form.Get("room_id"))
+	if !endDate.After(startDate) {
+		writeJSON(w, http.StatusOK, jsonResponse{
+			OK:        false,
+			Message:   zeroNightsMessage,
+			StartDate: sd,
+			EndDate:   ed,
+			RoomID:    strconv.Itoa(roomID),
+		})
+		return
+	}
 
 	available, err := m.DB.SearchAvailabilityByDatesByRoomID(startDate, endDate, roomID)
 	if err != nil {
-		resp := jsonResponse{
-			OK:      false,
-			Message: "Error querying database",
-		}
-
-		out, _ := json.MarshalIndent(resp, "", "     ")
-		w.Header().Set("Content-Type", "application/json")
-		w.Write(out)
+		m.App.ErrorLog.Println(err)
+		writeAPIError(w, http.StatusInternalServerError, errCodeServer, "Error querying database")
 		return
 	}
 
-	resp := jsonResponse{
+	writeJSON(w, http.StatusOK, jsonResponse{
 		OK:        available,
 		Message:   "",
 		StartDate: sd,
 		EndDate:   ed,
 		RoomID:    strconv.Itoa(roomID),
-	}
-
-	out, _ := json.MarshalIndent(resp, "", "     ")
-
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(out)
-}
-
-// Contact handles GET requests to display the contact form.
-// It renders the contact page with an empty form ready for user input,
-// allowing visitors to send messages to the residence administrators.
-func (m *Repository) Contact(w http.ResponseWriter, r *http.Request) {
-	render.Template(w, r, "contact.page.tmpl", &models.TemplateData{
-		Form: forms.New(nil),
 	})
 }
 
-// PostContact handles POST requests to process contact form submissions.
-// It validates the form data, performs spam detection using a honeypot field,
-// sends email notifications to both the administration and the sender,
-// and redirects with success or error messages.
+// QuoteAPI handles POST /api/quote, a dry run of the booking rules. It takes
+// room_id, start, end (MM/DD/YYYY) and guests, and returns a reservationQuote
+// with availability, the number of nights, per-night and total prices, and the
+// outcome of each policy. Nothing is written to the database.
 //
-// Security features:
-// - Honeypot field detection to prevent automated spam submissions
-// - Form validation for required fields and email format
-// - Dual email notifications for proper message handling
-func (m *Repository) PostContact(w http.ResponseWriter, r *http.Request) {
+// Responses:
+//   - 200 with the quote (ok=false when the room is taken or a policy fails)
+//   - 400 invalid_input if the dates, room or guest count cannot be parsed
+//   - 500 server_error if the room, rates or availability cannot be loaded
+func (m *Repository) QuoteAPI(w http.ResponseWriter, r *http.Request) {
 	err := r.ParseForm()
 	if err != nil {
-		m.App.Session.Put(r.Context(), "error", "can't parse form!")
-		http.Redirect(w, r, "/contact", http.StatusSeeOther)
+		writeParseFormError(w, err)
 		return
 	}
 
-	// Honeypot check should be early
-	website := r.Form.Get("website")
-	if website != "" {
-		m.App.Session.Put(r.Context(), "error", "Spam detected")
-		http.Redirect(w, r, "/contact", http.StatusSeeOther)
-		return
+	form := forms.New(r.PostForm)
+	form.Trim("room_id", "start", "end", "guests")
+
+	layout := "01/02/2006"
+	startDate, err := time.Parse(layout, form.Get("start"))
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, errCodeInvalidInput, "Invalid start date")
+		return
+	}
+	endDate, err := time.Parse(layout, form.Get("end"))
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, errCodeInvalidInput, "Invalid end date")
+		return
+	}
+	roomID, err := strconv.Atoi(form.Get("room_id"))
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, errCodeInvalidInput, "Invalid room")
+		return
+	}
+	guests := 1
+	if g := form.Get("guests"); g != "" {
+		guests, err = strconv.Atoi(g)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, errCodeInvalidInput, "Invalid guest count")
+			return
+		}
+	}
+
+	res := models.Reservation{RoomID: roomID, StartDate: startDate, EndDate: endDate}
+	q, err := m.quote(res, guests)
+	if err != nil {
+		m.App.ErrorLog.Println(err)
+		writeAPIError(w, http.StatusInternalServerError, errCodeServer, "Error calculating quote")
+		return
 	}
 
-	name := r.Form.Get("name")
-	email := r.Form.Get("email")
-	topic := r.Form.Get("topic")
-	message := r.Form.Get("message")
+	q.Available, err = m.DB.SearchAvailabilityByDatesByRoomID(startDate, endDate, roomID)
+	if err != nil {
+		m.App.ErrorLog.Println(err)
+		writeAPIError(w, http.StatusInternalServerError, errCodeServer, "Error querying database")
+		return
+	}
+	q.OK = q.OK && q.Available
 
-	form := forms.New(r.PostForm)
-	form.Required("name", "email", "message")
-	form.MinLength("name", 3)
-	form.IsEmail("email")
-	form.MinLength("message", 10)
+	writeJSON(w, http.StatusOK, q)
+}
 
-	if !form.Valid() {
-		render.Template(w, r, "contact.page.tmpl", &models.TemplateData{
-			Form: form,
+// blockedRange is one unavailable span in the RoomBlockedAPI response. Type
+// is "reservation" for a guest booking and "block" for an owner block.
+type blockedRange struct {
+	Start string `json:"start"` // First unavailable date, YYYY-MM-DD
+	End   string `json:"end"`   // Day after the last unavailable night, YYYY-MM-DD
+	Type  string `json:"type"`  // "reservation" or "block"
+}
+
+// RoomBlockedAPI handles GET /api/rooms/{id}/blocked?start=&end=, returning
+// the room's restrictions overlapping the YYYY-MM-DD range as a JSON array of
+// blockedRange so front-end calendars can grey out unavailable dates. Only
+// dates and the kind of restriction are exposed, never guest details.
+//
+// Responses:
+//   - 200 with the ranges (an empty array when the room is free)
+//   - 400 invalid_input if the room id or dates are malformed, or end is before start
+//   - 500 server_error if the restrictions cannot be loaded
+func (m *Repository) RoomBlockedAPI(w http.ResponseWriter, r *http.Request) {
+	roomID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, errCodeInvalidInput, "Invalid room")
+		return
+	}
+
+	layout := "2006-01-02"
+	start, err := time.Parse(layout, r.URL.Query().Get("start"))
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, errCodeInvalidInput, "Invalid start date")
+		return
+	}
+	end, err := time.Parse(layout, r.URL.Query().Get("end"))
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, errCodeInvalidInput, "Invalid end date")
+		return
+	}
+	if end.Before(start) {
+		writeAPIError(w, http.StatusBadRequest, errCodeInvalidInput, "End date must not be before start date")
+		return
+	}
+
+	restrictions, err := m.DB.GetRestrictionsForRoomByDate(roomID, start, end)
+	if err != nil {
+		m.App.ErrorLog.Println(err)
+		writeAPIError(w, http.StatusInternalServerError, errCodeServer, "Error querying database")
+		return
+	}
+
+	ranges := make([]blockedRange, 0, len(restrictions))
+	for _, rr := range restrictions {
+		kind := "block"
+		if rr.ReservationID > 0 {
+			kind = "reservation"
+		}
+		ranges = append(ranges, blockedRange{
+			Start: rr.StartDate.Format(layout),
+			End:   rr.EndDate.Format(layout),
+			Type:  kind,
 		})
+	}
+
+	writeJSON(w, http.StatusOK, ranges)
+}
+
+// sessionStatus is the JSON body of GET /api/session/status.
+type sessionStatus struct {
+	Authenticated bool   `json:"authenticated"` // Whether the session has a logged-in user
+	CSRFToken     string `json:"csrf_token"`    // Token to send as csrf_token with the next POST
+}
+
+// SessionStatus handles GET /api/session/status, a keep-alive for pages that
+// post with fetch. It reports whether the session is still logged in and
+// returns a current CSRF token, so a script can notice an expired session and
+// refresh its token before a form fails silently. The response carries the
+// CSRF cookie and is never cached.
+//
+// Responses:
+//   - 200 with a sessionStatus
+func (m *Repository) SessionStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, sessionStatus{
+		Authenticated: helpers.IsAuthenticated(r),
+		CSRFToken:     nosurf.Token(r),
+	})
+}
+
+// Error codes carried in apiError.Code. Clients branch on the code; the
+// message is for people and may change.
+const (
+	errCodeInvalidInput     = "invalid_input"      // 400: malformed or missing parameters
+	errCodeNotFound         = "not_found"          // 404: the requested record does not exist
+	errCodeMethodNotAllowed = "method_not_allowed" // 405: the route exists but not for this method
+	errCodeUnavailable      = "unavailable"        // 409: the room is already booked for the dates
+	errCodeDuplicate        = "duplicate"          // 409: the guest already holds this booking
+	errCodeTooLarge         = "request_too_large"  // 413: body exceeded MaxBodyBytes
+	errCodeServer           = "server_error"       // 500: database or other internal failure
+)
+
+// apiError is the JSON envelope every API handler returns when a request
+// cannot be satisfied, paired with a matching HTTP status.
+type apiError struct {
+	Code    string `json:"code"`    // Machine-readable error code, e.g. "invalid_input"
+	Message string `json:"message"` // Human-readable failure reason
+
+	// Fields holds per-field validation messages for invalid_input errors
+	// raised by form-style endpoints, keyed by the request field name.
+	Fields map[string][]string `json:"fields,omitempty"`
+}
+
+// writeAPIError writes an apiError envelope with the given status, code and
+// message.
+func writeAPIError(w http.ResponseWriter, status int, code, msg string) {
+	writeJSON(w, status, apiError{Code: code, Message: msg})
+}
+
+// writeParseFormError reports a ParseForm failure in an API handler: 413
+// request_too_large when the body exceeded the size limit, 400 invalid_input
+// otherwise.
+func writeParseFormError(w http.ResponseWriter, err error) {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		writeAPIError(w, http.StatusRequestEntityTooLarge, errCodeTooLarge, "Request body too large")
 		return
 	}
+	writeAPIError(w, http.StatusBadRequest, errCodeInvalidInput, "Invalid form data")
+}
+
+// apiMethods are the methods APIMethodNotAllowed checks when building the
+// Allow header.
+var apiMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+	http.MethodPatch, http.MethodDelete, http.MethodOptions,
+}
+
+// APIMethodNotAllowed returns the 405 handler for the router the JSON API is
+// mounted on. Instead of chi's empty 405 it writes the apiError envelope with
+// code method_not_allowed, and lists the methods the route does accept in
+// the Allow header.
+//
+// Parameters:
+//   - api: The /api sub-router, used to look up the methods registered for
+//     the requested path
+//
+// Usage:
+//
+//	mux.Route("/api", func(api chi.Router) {
+//		api.MethodNotAllowed(handlers.APIMethodNotAllowed(api))
+//		...
+//	})
+func APIMethodNotAllowed(api chi.Routes) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePath != "" {
+			path = rctx.RoutePath
+		}
+
+		for _, method := range apiMethods {
+			if api.Match(chi.NewRouteContext(), method, path) {
+				w.Header().Add("Allow", method)
+			}
+		}
+
+		writeAPIError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed,
+			fmt.Sprintf("Method %s is not allowed for this endpoint", r.Method))
+	}
+}
+
+// writeJSON marshals payload as indented JSON and writes it with the given
+// status code. It is the shared response path for all JSON handlers so that
+// content type and formatting stay consistent.
+//
+// Nothing is written until the payload has been encoded, so a value that
+// can't be marshaled (a channel, a NaN, a MarshalJSON that fails or panics)
+// is logged and answered with a 500 server_error envelope instead of an
+// empty or half-written body.
+//
+// Parameters:
+//   - w: response writer
+//   - status: HTTP status code to send
+//   - payload: value to encode (struct, map, or slice)
+func writeJSON(w http.ResponseWriter, status int, payload interface{}) {
+	out, err := marshalJSON(payload)
+	if err != nil {
+		log.Printf("writeJSON: cannot encode %T: %v", payload, err)
+		status = http.StatusInternalServerError
+		out = serverErrorJSON
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(out)
+}
+
+// serverErrorJSON is the body writeJSON sends when a payload can't be
+// encoded. It is built once from literals, so it can't fail itself.
+var serverErrorJSON, _ = json.MarshalIndent(apiError{Code: errCodeServer, Message: "Error encoding response"}, "", "     ")
+
+// marshalJSON is json.MarshalIndent with a panic raised during encoding
+// turned into an error.
+func marshalJSON(payload interface{}) (out []byte, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			out, err = nil, fmt.Errorf("panic while encoding: %v", p)
+		}
+	}()
+	return json.MarshalIndent(payload, "", "     ")
+}
+
+// defaultHoneypotField is the contact-form honeypot input name used when
+// AppConfig.HoneypotField is not configured.
+const defaultHoneypotField = "website"
+
+// honeypotField returns the configured honeypot input name, falling back to
+// defaultHoneypotField so the form keeps working with a zero-value config.
+func (m *Repository) honeypotField() string {
+	if m.App.HoneypotField != "" {
+		return m.App.HoneypotField
+	}
+	return defaultHoneypotField
+}
+
+// contactTopics are the topics the contact form offers. The topic decides
+// which staff address receives the message (AppConfig.ContactRecipients).
+var contactTopics = []string{"booking", "billing", "general"}
+
+// defaultContactTopic is used when a message is sent without a topic, and its
+// address receives topics that have none configured.
+const defaultContactTopic = "general"
+
+// contactRecipient returns the staff address for a contact topic, falling
+// back to the defaultContactTopic address and then
+// config.DefaultContactRecipient.
+func (m *Repository) contactRecipient(topic string) string {
+	if to := m.App.ContactRecipients[topic]; to != "" {
+		return to
+	}
+	if to := m.App.ContactRecipients[defaultContactTopic]; to != "" {
+		return to
+	}
+	return config.DefaultContactRecipient
+}
+
+// Contact handles GET requests to display the contact form.
+// It renders the contact page with an empty form ready for user input,
+// allowing visitors to send messages to the residence administrators.
+func (m *Repository) Contact(w http.ResponseWriter, r *http.Request) {
+	stringMap := make(map[string]string)
+	stringMap["honeypot_field"] = m.honeypotField()
 
-	// Send email notification
-	htmlMessage := fmt.Sprintf(`
-		<strong>New Contact Form Message</strong><br><br>
-		<strong>From:</strong> %s (%s)<br>
-		<strong>Topic:</strong> %s<br><br>
-		<strong>Message:</strong><br>
-		%s
-	`, name, email, topic, message)
+	render.Template(w, r, "contact.page.tmpl", &models.TemplateData{
+		Form:      forms.New(nil),
+		StringMap: stringMap,
+	})
+}
 
-	msg := models.MailData{
-		To:       "admin@milosresidence.com", // Change to your email
-		From:     email,
-		Subject:  fmt.Sprintf("Contact Form: %s", topic),
-		Content:  htmlMessage,
-		Template: "basic.html",
+// PostContact handles POST requests to process contact form submissions.
+// It validates the form data, performs spam detection using a honeypot field,
+// sends email notifications to both the administration and the sender,
+// and redirects with success or error messages.
+//
+// Security features:
+// - Honeypot field detection (configurable name) to prevent automated spam submissions
+// - Spam attempts are logged with client IP and user agent for monitoring
+// - Form validation for required fields, email format and the topic allowlist
+// - Dual email notifications for proper message handling
+//
+// The staff notification goes to the address configured for the topic (see
+// contactRecipient); a submission without a topic is treated as "general".
+func (m *Repository) PostContact(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		if helpers.BodyTooLarge(w, err) {
+			return
+		}
+		helpers.RedirectWithError(w, r, m.App.Session, "/contact", "can't parse form!")
+		return
 	}
 
-	m.App.MailChan <- msg
+	// Honeypot check must run before any mail is queued.
+	if r.Form.Get(m.honeypotField()) != "" {
+		m.App.InfoLog.Printf("contact form spam detected: ip=%s user_agent=%q", helpers.ClientIP(r), r.UserAgent())
+		helpers.RedirectWithError(w, r, m.App.Session, "/contact", "Spam detected")
+		return
+	}
 
-	// Send confirmation email to user
-	confirmationMessage := fmt.Sprintf(`
-		Hi %s,<br><br>
-		Thank you for contacting Milo's Residence! We've received your message and will get back to you within 24 hours.<br><br>
-		Best purrs,<br>
-		The Milo's Residence Team
-	`, name)
+	// Canonicalize input before validation.
+	form := forms.New(r.PostForm)
+	form.Trim("email", "topic", "message")
+	form.Collapse("name")
 
-	confirmMsg := models.MailData{
-		To:       email,
-		From:     "hello@milosresidence.com",
-		Subject:  "Thanks for contacting Milo's Residence",
-		Content:  confirmationMessage,
-		Template: "basic.html",
+	// Topics are matched case-insensitively; a missing topic means general.
+	topic := strings.ToLower(form.Get("topic"))
+	if topic == "" {
+		topic = defaultContactTopic
 	}
+	form.Set("topic", topic)
+
+	name := form.Get("name")
+	email := form.Get("email")
+	message := form.Get("message")
+
+	form.Required("name", "email", "message")
+	form.MinLength("name", 3)
+	form.IsEmail("email")
+	form.MinLength("message", 10)
+	form.PermittedValues("topic", contactTopics...)
+
+	if !form.Valid() {
+		stringMap := make(map[string]string)
+		stringMap["honeypot_field"] = m.honeypotField()
 
-	m.App.MailChan <- confirmMsg
-	// If the honeypot field is filled, treat it as spam and do not process further
-	if website != "" {
-		m.App.Session.Put(r.Context(), "error", "Spam detected")
-		http.Redirect(w, r, "/contact", http.StatusSeeOther)
+		render.Template(w, r, "contact.page.tmpl", &models.TemplateData{
+			Form:      form,
+			StringMap: stringMap,
+		})
 		return
 	}
 
-	m.App.Session.Put(r.Context(), "flash", "Thank you for your message! We'll get back to you soon.")
-	http.Redirect(w, r, "/contact", http.StatusSeeOther)
+	// Forward the message to staff and confirm receipt to the sender.
+	m.queueMail(contactEmails(m.App.Property(), name, email, topic, message, m.contactRecipient(topic)))
+
+	helpers.RedirectWithFlash(w, r, m.App.Session, "/contact", "Thank you for your message! We'll get back to you soon.")
 }
 
 // ReservationSummary handles GET requests to display reservation confirmation details.
 // It retrieves the completed reservation from the session, displays the summary
 // information to the user, and removes the reservation data from the session
 // to prevent reuse. If no reservation data exists in the session,
-// it redirects to the home page with an error message.
+// it redirects to the home page with an error message, or without one when
+// the route has no session loaded.
 func (m *Repository) ReservationSummary(w http.ResponseWriter, r *http.Request) {
+	if !m.requireSession(w, r) {
+		return
+	}
+
 	reservation, ok := m.App.Session.Get(r.Context(), "reservation").(models.Reservation)
 	if !ok {
-		m.App.Session.Put(r.Context(), "error", "Can't get reservation from session")
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		helpers.RedirectWithError(w, r, m.App.Session, "/", "Can't get reservation from session")
 		return
 	}
 
@@ -589,6 +1275,10 @@ func (m *Repository) ReservationSummary(w http.ResponseWriter, r *http.Request)
 	stringMap := make(map[string]string)
 	stringMap["start_date"] = sd
 	stringMap["end_date"] = ed
+	if reservation.Total > 0 {
+		stringMap["total"] = formatPrice(reservation.Total)
+	}
+	stringMap["check_in_time"], stringMap["check_out_time"] = m.checkInOutTimes()
 
 	render.Template(w, r, "reservation-summary.page.tmpl", &models.TemplateData{
 		Data:      data,
@@ -596,38 +1286,109 @@ func (m *Repository) ReservationSummary(w http.ResponseWriter, r *http.Request)
 	})
 }
 
+// checkInOutTimes returns the check-in and check-out times from the saved
+// settings, then AppConfig, falling back to config.DefaultCheckInTime and
+// config.DefaultCheckOutTime.
+func (m *Repository) checkInOutTimes() (string, string) {
+	checkIn, checkOut := m.App.CheckInTime, m.App.CheckOutTime
+	if v, ok := m.setting(settingCheckInTime); ok && v != "" {
+		checkIn = v
+	}
+	if v, ok := m.setting(settingCheckOutTime); ok && v != "" {
+		checkOut = v
+	}
+	if checkIn == "" {
+		checkIn = config.DefaultCheckInTime
+	}
+	if checkOut == "" {
+		checkOut = config.DefaultCheckOutTime
+	}
+	return checkIn, checkOut
+}
+
 // ChooseRoom handles GET requests to select a specific room for reservation.
 // It extracts the room ID from the URL path, validates the room exists,
 // updates the reservation in the session with the selected room,
-// and redirects to the reservation form. If the session doesn't contain
-// valid reservation data or the URL is malformed, it redirects with an error.
+// holds the dates for the guest (see holdDates), and redirects to the
+// reservation form. If the session doesn't contain valid reservation data or
+// the URL is malformed, it redirects with an error; if someone else has just
+// taken the dates it sends the guest back to the search.
 func (m *Repository) ChooseRoom(w http.ResponseWriter, r *http.Request) {
 	exploded := strings.Split(r.RequestURI, "/")
 	roomID, err := strconv.Atoi(exploded[2])
 	if err != nil {
-		m.App.Session.Put(r.Context(), "error", "missing url parameter")
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		helpers.RedirectWithError(w, r, m.App.Session, "/", "missing url parameter")
 		return
 	}
 
 	res, ok := m.App.Session.Get(r.Context(), "reservation").(models.Reservation)
 	if !ok {
-		m.App.Session.Put(r.Context(), "error", "Can't get reservation from session")
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		helpers.RedirectWithError(w, r, m.App.Session, "/", "Can't get reservation from session")
 		return
 	}
 
 	res.RoomID = roomID
 
+	if errors.Is(m.holdDates(r, res), repository.ErrDatesTaken) {
+		helpers.RedirectWithError(w, r, m.App.Session, "/search-availability", datesTakenMessage)
+		return
+	}
+
 	m.App.Session.Put(r.Context(), "reservation", res)
 
 	http.Redirect(w, r, "/make-reservation", http.StatusSeeOther)
 }
 
+// datesTakenMessage is shown when a guest picks a room whose dates another
+// guest has just reserved or is holding.
+const datesTakenMessage = "Sorry, those dates were just taken for that room. Please search again."
+
+// holdDates holds res's room and dates for the guest while they fill in the
+// booking form, replacing any hold the session already has. The hold's ID is
+// kept in the session under "hold_id" so PostReservation can release it once
+// the reservation's own restriction is in place; otherwise it lapses after
+// App.HoldTTL and the expiry job removes it.
+//
+// It returns repository.ErrDatesTaken when the dates are no longer free. Any
+// other failure is logged and reported as nil: the hold is a courtesy, and
+// the guest can still book without one. With HoldTTL zero nothing is held.
+func (m *Repository) holdDates(r *http.Request, res models.Reservation) error {
+	if m.App.HoldTTL <= 0 {
+		return nil
+	}
+
+	m.releaseHold(r)
+
+	id, err := m.DB.CreateHold(res.RoomID, res.StartDate, res.EndDate, m.now().Add(m.App.HoldTTL))
+	if errors.Is(err, repository.ErrDatesTaken) {
+		return err
+	}
+	if err != nil {
+		m.App.ErrorLog.Println(err)
+		return nil
+	}
+
+	m.App.Session.Put(r.Context(), "hold_id", id)
+	return nil
+}
+
+// releaseHold removes the session's hold, if it has one. Failures are only
+// logged; the expiry job removes the hold later anyway.
+func (m *Repository) releaseHold(r *http.Request) {
+	id := m.App.Session.PopInt(r.Context(), "hold_id")
+	if id == 0 {
+		return
+	}
+	if err := m.DB.ReleaseHold(id); err != nil {
+		m.App.ErrorLog.Println(err)
+	}
+}
+
 // BookRoom handles GET requests to initiate room booking from external links.
 // It extracts booking parameters (room ID, start date, end date) from URL query parameters,
-// validates the room exists, creates a reservation object, stores it in the session,
-// and redirects to the reservation form. This handler enables direct booking links
+// validates the dates and the room, creates a reservation object, stores it in the
+// session, and redirects to the reservation form. Links with a missing or malformed
+// date, or an end date not after the start, are refused before anything is held. This handler enables direct booking links
 // from room pages or external sources.
 func (m *Repository) BookRoom(w http.ResponseWriter, r *http.Request) {
 	roomID, _ := strconv.Atoi(r.URL.Query().Get("id"))
@@ -636,15 +1397,26 @@ func (m *Repository) BookRoom(w http.ResponseWriter, r *http.Request) {
 	ed := r.URL.Query().Get("e")
 
 	layout := "01/02/2006"
-	startDate, _ := time.Parse(layout, sd)
-	endDate, _ := time.Parse(layout, ed)
+	startDate, err := time.Parse(layout, sd)
+	if err != nil {
+		helpers.RedirectWithError(w, r, m.App.Session, "/", "can't parse start date!")
+		return
+	}
+	endDate, err := time.Parse(layout, ed)
+	if err != nil {
+		helpers.RedirectWithError(w, r, m.App.Session, "/", "can't parse end date!")
+		return
+	}
+	if !startDate.Before(endDate) {
+		helpers.RedirectWithError(w, r, m.App.Session, "/", "End date must be after start date!")
+		return
+	}
 
 	var res models.Reservation
 
 	room, err := m.DB.GetRoomByID(roomID)
 	if err != nil {
-		m.App.Session.Put(r.Context(), "error", "Can't get room from db!")
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		helpers.RedirectWithError(w, r, m.App.Session, "/", "Can't get room from db!")
 		return
 	}
 
@@ -653,6 +1425,11 @@ func (m *Repository) BookRoom(w http.ResponseWriter, r *http.Request) {
 	res.StartDate = startDate
 	res.EndDate = endDate
 
+	if errors.Is(m.holdDates(r, res), repository.ErrDatesTaken) {
+		helpers.RedirectWithError(w, r, m.App.Session, "/search-availability", datesTakenMessage)
+		return
+	}
+
 	m.App.Session.Put(r.Context(), "reservation", res)
 
 	http.Redirect(w, r, "/make-reservation", http.StatusSeeOther)
@@ -682,13 +1459,19 @@ func (m *Repository) PostShowLogin(w http.ResponseWriter, r *http.Request) {
 
 	err := r.ParseForm()
 	if err != nil {
+		if helpers.BodyTooLarge(w, err) {
+			return
+		}
 		log.Println(err)
 	}
 
-	email := r.Form.Get("email")
-	password := r.Form.Get("password")
-
+	// Trim the email only; passwords are used exactly as typed.
 	form := forms.New(r.PostForm)
+	form.Trim("email")
+
+	email := form.Get("email")
+	password := form.Get("password")
+
 	form.Required("email", "password")
 	form.IsEmail("email")
 
@@ -702,14 +1485,12 @@ func (m *Repository) PostShowLogin(w http.ResponseWriter, r *http.Request) {
 	id, _, err := m.DB.Authenticate(email, password)
 	if err != nil {
 		log.Println(err)
-		m.App.Session.Put(r.Context(), "error", "Invalid login credentials")
-		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		helpers.RedirectWithError(w, r, m.App.Session, "/user/login", "Invalid login credentials")
 		return
 	}
 
 	m.App.Session.Put(r.Context(), "user_id", id)
-	m.App.Session.Put(r.Context(), "flash", "Logged in successfully!")
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+	helpers.RedirectWithFlash(w, r, m.App.Session, "/", "Logged in successfully!")
 
 }
 
@@ -732,41 +1513,144 @@ func (m *Repository) AdminDashboard(w http.ResponseWriter, r *http.Request) {
 	render.Template(w, r, "admin-dashboard.page.tmpl", &models.TemplateData{})
 }
 
-// AdminAllReservations handles GET requests to display all reservations.
-// It retrieves all reservations from the database and renders them in
-// a table format for administrative review. If database access fails,
-// it returns an internal server error response.
-func (m *Repository) AdminAllReservations(w http.ResponseWriter, r *http.Request) {
-	reservations, err := m.DB.AllReservations()
+// sortOption is one entry in the admin reservation list's sort selector.
+type sortOption struct {
+	Value    string // Key from repository.ReservationSorts
+	Label    string // Text shown in the selector
+	Selected bool   // Whether this is the order currently applied
+}
+
+// reservationSortLabels holds the selector text for each reservation sort key.
+var reservationSortLabels = map[string]string{
+	"start_date_asc":  "Arrival (earliest first)",
+	"start_date_desc": "Arrival (latest first)",
+	"created_at_asc":  "Booked (oldest first)",
+	"created_at_desc": "Booked (newest first)",
+	"last_name_asc":   "Last name (A-Z)",
+	"last_name_desc":  "Last name (Z-A)",
+}
+
+// statusTab is one entry in the admin reservation list's status filter.
+type statusTab struct {
+	Value  string // Key from repository.ReservationStatuses
+	Label  string // Tab text
+	Active bool   // Whether this is the filter currently applied
+}
+
+// reservationStatusLabels holds the tab text for each reservation status.
+var reservationStatusLabels = map[string]string{
+	repository.ReservationStatusNew:       "New",
+	repository.ReservationStatusProcessed: "Processed",
+	repository.ReservationStatusAll:       "All",
+}
+
+// AdminReservations handles GET /admin/reservations, the admin reservation
+// list. The status query parameter picks all, new or processed reservations
+// and sort selects one of repository.ReservationSorts; missing or unknown
+// values use all and the default order. Both go to a single
+// SearchReservations call. If database access fails, it returns an internal
+// server error response.
+//
+// With stream=1 the page is rendered by render.TemplateStream, which writes
+// rows as they are produced instead of buffering the whole table; use it
+// for lists of thousands of reservations.
+func (m *Repository) AdminReservations(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if !repository.ValidReservationStatus(status) {
+		status = repository.ReservationStatusAll
+	}
+	sort := r.URL.Query().Get("sort")
+	if !repository.ValidReservationSort(sort) {
+		sort = repository.DefaultReservationSort
+	}
+
+	reservations, err := m.DB.SearchReservations(repository.ReservationFilter{Status: status, Sort: sort})
 	if err != nil {
 		helpers.ServerError(w, err)
 		return
 	}
 
+	sorts := make([]sortOption, 0, len(repository.ReservationSorts))
+	for _, key := range repository.ReservationSorts {
+		sorts = append(sorts, sortOption{Value: key, Label: reservationSortLabels[key], Selected: key == sort})
+	}
+	tabs := make([]statusTab, 0, len(repository.ReservationStatuses))
+	for _, key := range repository.ReservationStatuses {
+		tabs = append(tabs, statusTab{Value: key, Label: reservationStatusLabels[key], Active: key == status})
+	}
+
 	data := make(map[string]interface{})
 	data["reservations"] = reservations
+	data["sorts"] = sorts
+	data["statuses"] = tabs
 
-	render.Template(w, r, "admin-all-reservations.page.tmpl", &models.TemplateData{
+	// Very long lists can be streamed with ?stream=1 rather than buffered.
+	renderPage := render.Template
+	if r.URL.Query().Get("stream") == "1" {
+		renderPage = render.TemplateStream
+	}
+	renderPage(w, r, "admin-reservations.page.tmpl", &models.TemplateData{
 		Data: data,
+		StringMap: map[string]string{
+			"status": status,
+			"title":  reservationStatusLabels[status] + " Reservations",
+			"sort":   sort,
+		},
 	})
 }
 
-// AdminNewReservations handles GET requests to display unprocessed reservations.
-// It retrieves all new (unprocessed) reservations from the database and
-// renders them in a table format for administrative processing. This allows
-// staff to review and handle new booking requests efficiently.
+// AdminAllReservations handles GET /admin/reservations-all, kept so old links
+// and bookmarks still work. It redirects to the all filter of
+// AdminReservations, keeping any sort.
+func (m *Repository) AdminAllReservations(w http.ResponseWriter, r *http.Request) {
+	redirectToReservations(w, r, repository.ReservationStatusAll)
+}
+
+// AdminNewReservations handles GET /admin/reservations-new, kept so old links
+// and bookmarks still work. It redirects to the new filter of
+// AdminReservations, keeping any sort.
 func (m *Repository) AdminNewReservations(w http.ResponseWriter, r *http.Request) {
-	reservations, err := m.DB.AllNewReservations()
+	redirectToReservations(w, r, repository.ReservationStatusNew)
+}
+
+// redirectToReservations permanently redirects a legacy list URL to
+// /admin/reservations with status set and the original query preserved.
+func redirectToReservations(w http.ResponseWriter, r *http.Request, status string) {
+	q := r.URL.Query()
+	q.Set("status", status)
+	http.Redirect(w, r, "/admin/reservations?"+q.Encode(), http.StatusMovedPermanently)
+}
+
+// AdminArrivals handles GET /admin/arrivals?date=YYYY-MM-DD and lists the
+// reservations arriving on that day, sorted by guest name, for the front
+// desk. Without date it shows today's arrivals; a malformed date is a 400.
+func (m *Repository) AdminArrivals(w http.ResponseWriter, r *http.Request) {
+	day := m.today()
+	if v := r.URL.Query().Get("date"); v != "" {
+		parsed, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			helpers.ClientError(w, http.StatusBadRequest)
+			return
+		}
+		day = parsed
+	}
+
+	arrivals, err := m.DB.ArrivalsBetween(day, day.AddDate(0, 0, 1))
 	if err != nil {
 		helpers.ServerError(w, err)
 		return
 	}
 
-	data := make(map[string]interface{})
-	data["reservations"] = reservations
-
-	render.Template(w, r, "admin-new-reservations.page.tmpl", &models.TemplateData{
-		Data: data,
+	render.Template(w, r, "admin-arrivals.page.tmpl", &models.TemplateData{
+		Data: map[string]interface{}{
+			"arrivals": arrivals,
+			"date":     day,
+		},
+		StringMap: map[string]string{
+			"date":     day.Format("2006-01-02"),
+			"previous": day.AddDate(0, 0, -1).Format("2006-01-02"),
+			"next":     day.AddDate(0, 0, 1).Format("2006-01-02"),
+		},
 	})
 }
 
@@ -774,7 +1658,9 @@ func (m *Repository) AdminNewReservations(w http.ResponseWriter, r *http.Request
 // It extracts the reservation ID from the URL path, retrieves the complete
 // reservation details from the database, and renders a detailed view with
 // editing capabilities. URL parameters for year and month are preserved
-// for navigation context when coming from calendar views.
+// for navigation context when coming from calendar views. A reservation that
+// does not exist renders a "not found" page with HTTP 404; other database
+// failures return HTTP 500.
 func (m *Repository) AdminShowReservation(w http.ResponseWriter, r *http.Request) {
 
 	exploded := strings.Split(r.RequestURI, "/")
@@ -797,7 +1683,7 @@ func (m *Repository) AdminShowReservation(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	src := exploded[3]
+	src := normalizeSrc(exploded[3])
 	stringMap := make(map[string]string)
 	stringMap["src"] = src
 
@@ -808,29 +1694,270 @@ func (m *Repository) AdminShowReservation(w http.ResponseWriter, r *http.Request
 	stringMap["year"] = year
 
 	res, err := m.DB.GetReservationByID(id)
+	if errors.Is(err, repository.ErrReservationNotFound) {
+		m.reservationNotFound(w, r, id, stringMap)
+		return
+	} else if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	m.renderShowReservation(w, r, res, stringMap, forms.New(nil))
+}
+
+// renderShowReservation renders the admin reservation detail page for res,
+// including the guest's other stays. form carries any validation errors from
+// a rejected save; stringMap carries the src/year/month navigation context.
+func (m *Repository) renderShowReservation(w http.ResponseWriter, r *http.Request, res models.Reservation, stringMap map[string]string, form *forms.Form) {
+	// Load the guest's other stays so staff can spot returning guests.
+	history, err := m.DB.ReservationsByEmail(res.Email)
 	if err != nil {
 		helpers.ServerError(w, err)
 		return
 	}
 
+	var previous []models.Reservation
+	for _, h := range history {
+		if h.ID != res.ID {
+			previous = append(previous, h)
+		}
+	}
+
+	// Echoed back on save so a concurrent edit can be detected.
+	stringMap["updated_at"] = res.UpdatedAt.Format(time.RFC3339Nano)
+
 	data := make(map[string]interface{})
 	data["reservation"] = res
+	data["previous_stays"] = previous
+
+	// Offer a button for each status the reservation may move to next.
+	status := res.Status
+	if status == "" {
+		status = models.StatusPending
+	}
+	stringMap["status"] = status
+	stringMap["status_label"] = lifecycleStatusLabels[status]
+	var options []statusOption
+	for _, next := range models.StatusTransitions[status] {
+		options = append(options, statusOption{Value: next, Action: lifecycleStatusActions[next]})
+	}
+	data["status_options"] = options
+
+	intMap := make(map[string]int)
+	intMap["previous_stays"] = len(previous)
+	intMap["max_notes"] = maxReservationNotesLength
 
 	render.Template(w, r, "admin-reservations-show.page.tmpl", &models.TemplateData{
 		StringMap: stringMap,
+		IntMap:    intMap,
 		Data:      data,
-		Form:      forms.New(nil),
+		Form:      form,
 	})
 }
 
-// AdminPostShowReservation handles POST requests to update reservation details.
-// It processes form submissions from the reservation detail page, updates
-// the reservation information in the database, and redirects back to the
+// AdminPrintReservation handles GET /admin/reservations/{src}/{id}/print. It
+// renders reservation-print.page.tmpl without the admin layout so the browser's
+// print-to-PDF produces a clean confirmation with the reservation and room
+// details. The page is rendered through render.Fragment, which executes the
+// template standalone and leaves flash messages in the session for the next
+// full page.
+//
+// Responses:
+//   - 200: printable page
+//   - 400: non-numeric reservation ID
+//   - 404: no reservation with the given ID
+//   - 500: database failure
+func (m *Repository) AdminPrintReservation(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	res, err := m.DB.GetReservationByID(id)
+	if errors.Is(err, repository.ErrReservationNotFound) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	} else if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	stringMap := map[string]string{
+		"start_date": res.StartDate.Format("01/02/2006"),
+		"end_date":   res.EndDate.Format("01/02/2006"),
+	}
+	if res.Total > 0 {
+		stringMap["total"] = formatPrice(res.Total)
+	}
+
+	render.Fragment(w, r, "reservation-print.page.tmpl", &models.TemplateData{
+		StringMap: stringMap,
+		IntMap:    map[string]int{"nights": int(res.EndDate.Sub(res.StartDate).Hours() / 24)},
+		Data:      map[string]interface{}{"reservation": res},
+	})
+}
+
+// AdminReservationJSON handles GET requests for a single reservation as JSON.
+// It reads the reservation ID from the route, loads the reservation (with its
+// embedded room) and writes it using writeJSON. This supports admin front-end
+// pages that fetch reservation details without a full page load.
+//
+// Responses:
+//   - 200: reservation payload
+//   - 400 invalid_input: non-numeric reservation ID
+//   - 404 not_found: no reservation with the given ID
+//   - 500 server_error: database failure
+func (m *Repository) AdminReservationJSON(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, errCodeInvalidInput, "invalid reservation id")
+		return
+	}
+
+	res, err := m.DB.GetReservationByID(id)
+	if errors.Is(err, repository.ErrReservationNotFound) {
+		writeAPIError(w, http.StatusNotFound, errCodeNotFound, "reservation not found")
+		return
+	} else if err != nil {
+		m.App.ErrorLog.Println(err)
+		writeAPIError(w, http.StatusInternalServerError, errCodeServer, "error querying database")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+// adminSources lists the admin views a reservation page can be opened from.
+// The value travels in the {src} route segment and picks the page to return to.
+var adminSources = []string{"new", "processed", "all", "cal"}
+
+// normalizeSrc returns src if it names a known admin view, or "all" otherwise,
+// so a crafted route segment can never steer a redirect to an arbitrary path.
+func normalizeSrc(src string) string {
+	form := forms.New(url.Values{"src": {src}})
+	if form.PermittedValues("src", adminSources...) {
+		return src
+	}
+	return "all"
+}
+
+// adminListURL returns the reservation list URL for a normalized src value.
+// "cal" maps to the calendar page; the others to the matching status filter
+// of /admin/reservations.
+func adminListURL(src string) string {
+	if src == "cal" {
+		return "/admin/reservations-calendar"
+	}
+	return "/admin/reservations?status=" + src
+}
+
+// adminReturnURL returns where an admin action on a reservation sends the
+// user back to: the calendar month when year is set, otherwise the list for
+// the normalized src value.
+func adminReturnURL(src, year, month string) string {
+	if year == "" {
+		return adminListURL(src)
+	}
+	return fmt.Sprintf("/admin/reservations-calendar?y=%s&m=%s", year, month)
+}
+
+// reservationNotFound renders the admin "reservation not found" page with an
+// HTTP 404 status. stringMap carries the src/year/month navigation context so
+// the page can link back to the list or calendar the user came from. The 404
+// is only sent once the page renders; a render failure keeps its own 500.
+func (m *Repository) reservationNotFound(w http.ResponseWriter, r *http.Request, id int, stringMap map[string]string) {
+	intMap := make(map[string]int)
+	intMap["id"] = id
+
+	render.Template(&statusOnWrite{ResponseWriter: w, status: http.StatusNotFound}, r, "admin-reservation-not-found.page.tmpl", &models.TemplateData{
+		StringMap: stringMap,
+		IntMap:    intMap,
+	})
+}
+
+// statusOnWrite sends status before the first body write, unless a status was
+// set explicitly first. It lets a page render with a non-200 status without
+// fixing that status before the render is known to have succeeded.
+type statusOnWrite struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+// WriteHeader sends code, if no status has been sent yet.
+func (s *statusOnWrite) WriteHeader(code int) {
+	if s.wroteHeader {
+		return
+	}
+	s.wroteHeader = true
+	s.ResponseWriter.WriteHeader(code)
+}
+
+// Write sends the pending status on the first call, then writes p.
+func (s *statusOnWrite) Write(p []byte) (int, error) {
+	s.WriteHeader(s.status)
+	return s.ResponseWriter.Write(p)
+}
+
+// maxReservationNotesLength caps the staff notes saved on a reservation, in
+// characters.
+const maxReservationNotesLength = 2000
+
+// reservationChange is one guest-facing field altered by a staff edit.
+type reservationChange struct {
+	Field string // Label shown to the guest, e.g. "Email"
+	From  string // Value before the edit
+	To    string // Value after the edit
+}
+
+// reservationChanges lists the guest-facing fields that differ between before
+// and after, in the order they appear on the edit form. Staff notes are
+// internal and never listed.
+func reservationChanges(before, after models.Reservation) []reservationChange {
+	fields := []struct {
+		label    string
+		from, to string
+	}{
+		{"First name", before.FirstName, after.FirstName},
+		{"Last name", before.LastName, after.LastName},
+		{"Email", before.Email, after.Email},
+		{"Phone", before.Phone, after.Phone},
+		{"Arrival", before.StartDate.Format("01/02/2006"), after.StartDate.Format("01/02/2006")},
+		{"Departure", before.EndDate.Format("01/02/2006"), after.EndDate.Format("01/02/2006")},
+	}
+
+	var changes []reservationChange
+	for _, f := range fields {
+		if f.from != f.to {
+			changes = append(changes, reservationChange{Field: f.label, From: f.from, To: f.to})
+		}
+	}
+	return changes
+}
+
+// AdminPostShowReservation handles POST requests to update reservation details.
+// It processes form submissions from the reservation detail page, updates
+// the reservation information in the database, and redirects back to the
 // appropriate listing (calendar or reservation list) based on the source context.
-// Navigation context is preserved through hidden form fields.
+// Navigation context is preserved through hidden form fields. Updating a
+// reservation that no longer exists renders the 404 "not found" page.
+//
+// Staff notes longer than maxReservationNotesLength are rejected and the page
+// is re-rendered with the submitted values and a field error.
+//
+// The form carries the updated_at value the page was rendered with. If the
+// reservation has been saved since, the update is refused and the admin is
+// sent back to the reservation with a warning to review the latest version.
+//
+// When the "notify guest" box is checked and a guest-facing field changed,
+// the guest is emailed a summary of the changes at their (new) address.
 func (m *Repository) AdminPostShowReservation(w http.ResponseWriter, r *http.Request) {
 	err := r.ParseForm()
 	if err != nil {
+		if helpers.BodyTooLarge(w, err) {
+			return
+		}
 		helpers.ServerError(w, err)
 		return
 	}
@@ -842,38 +1969,290 @@ func (m *Repository) AdminPostShowReservation(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	src := exploded[3]
+	src := normalizeSrc(exploded[3])
 	stringMap := make(map[string]string)
 	stringMap["src"] = src
 
 	res, err := m.DB.GetReservationByID(id)
-	if err != nil {
+	if errors.Is(err, repository.ErrReservationNotFound) {
+		m.reservationNotFound(w, r, id, stringMap)
+		return
+	} else if err != nil {
 		helpers.ServerError(w, err)
 		return
 	}
 
+	form := forms.New(r.PostForm)
+	form.Trim("notes")
+
+	before := res
 	res.FirstName = r.Form.Get("first_name")
 	res.LastName = r.Form.Get("last_name")
 	res.Email = r.Form.Get("email")
 	res.Phone = r.Form.Get("phone")
+	res.Notes = form.Get("notes")
+
+	// Compare against the version the admin was editing, not the one just loaded.
+	if v := r.Form.Get("updated_at"); v != "" {
+		loaded, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			helpers.ClientError(w, http.StatusBadRequest)
+			return
+		}
+		res.UpdatedAt = loaded
+	}
+
+	month := r.Form.Get("month")
+	year := r.Form.Get("year")
+
+	// Re-render with the admin's edits rather than discarding an over-long note.
+	form.MaxLength("notes", maxReservationNotesLength)
+	if !form.Valid() {
+		stringMap["month"] = month
+		stringMap["year"] = year
+		m.renderShowReservation(w, r, res, stringMap, form)
+		return
+	}
 
 	err = m.DB.UpdateReservation(res)
+	if errors.Is(err, repository.ErrStaleUpdate) {
+		helpers.AddFlash(r, models.FlashWarning, "This reservation was changed by someone else. Review the latest details and save again.")
+		http.Redirect(w, r, adminShowURL(src, id, year, month), http.StatusSeeOther)
+		return
+	} else if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	m.audit(r, "reservation.update", fmt.Sprintf("Updated reservation %d for %s %s", id, res.FirstName, res.LastName))
+
+	flash := "Changes saved"
+	if changes := reservationChanges(before, res); form.Get("notify_guest") != "" && len(changes) > 0 {
+		m.queueMail([]models.MailData{reservationUpdatedEmail(res, changes)})
+		flash = "Changes saved and the guest was notified"
+	}
+	helpers.RedirectWithFlash(w, r, m.App.Session, adminReturnURL(src, year, month), flash)
+}
+
+// adminShowURL returns the admin page for reservation id, carrying the
+// calendar month (when year is set) so its links lead back there.
+func adminShowURL(src string, id int, year, month string) string {
+	showURL := fmt.Sprintf("/admin/reservations/%s/%d/show", src, id)
+	if year != "" {
+		showURL += fmt.Sprintf("?y=%s&m=%s", url.QueryEscape(year), url.QueryEscape(month))
+	}
+	return showURL
+}
+
+// lifecycleStatusLabels names each Reservation.Status value for staff.
+var lifecycleStatusLabels = map[string]string{
+	models.StatusPending:    "Pending",
+	models.StatusConfirmed:  "Confirmed",
+	models.StatusCheckedIn:  "Checked in",
+	models.StatusCheckedOut: "Checked out",
+	models.StatusCancelled:  "Cancelled",
+}
+
+// lifecycleStatusActions labels the button that moves a reservation to
+// each status.
+var lifecycleStatusActions = map[string]string{
+	models.StatusPending:    "Back to Pending",
+	models.StatusConfirmed:  "Confirm",
+	models.StatusCheckedIn:  "Check In",
+	models.StatusCheckedOut: "Check Out",
+	models.StatusCancelled:  "Cancel Reservation",
+}
+
+// statusOption is one status change offered on the reservation page.
+type statusOption struct {
+	Value  string // Status submitted to AdminSetReservationStatus
+	Action string // Button label
+}
+
+// AdminSetReservationStatus handles POST /admin/reservations/{src}/{id}/status
+// and moves the reservation to the form's status. The repository enforces
+// models.StatusTransitions, so a disallowed change (such as checking in a
+// cancelled reservation) is refused even if the form is forged. Cancelling
+// frees the reservation's dates, so, like a delete, it checks the waitlist
+// for guests who wanted them.
+//
+// Responses:
+//   - 303 back to the reservation page with a flash on success, or with an
+//     error when the change is not allowed
+//   - 400 for a malformed ID or an unknown status
+//   - 404 page when the reservation doesn't exist
+//   - 500 for other database errors
+func (m *Repository) AdminSetReservationStatus(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
 	if err != nil {
+		if helpers.BodyTooLarge(w, err) {
+			return
+		}
 		helpers.ServerError(w, err)
 		return
 	}
 
-	month := r.Form.Get("month")
-	year := r.Form.Get("year")
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	}
 
-	m.App.Session.Put(r.Context(), "flash", "Changes saved")
+	src := normalizeSrc(chi.URLParam(r, "src"))
+	year, month := r.Form.Get("year"), r.Form.Get("month")
+	showURL := adminShowURL(src, id, year, month)
+	status := r.Form.Get("status")
 
-	if year == "" {
-		http.Redirect(w, r, fmt.Sprintf("/admin/reservations-%s", src), http.StatusSeeOther)
-	} else {
-		http.Redirect(w, r, fmt.Sprintf("/admin/reservations-calendar?y=%s&m=%s", year, month), http.StatusSeeOther)
+	err = m.DB.SetReservationStatus(id, status)
+	switch {
+	case errors.Is(err, repository.ErrInvalidStatus):
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	case errors.Is(err, repository.ErrReservationNotFound):
+		m.reservationNotFound(w, r, id, map[string]string{"src": src, "year": year, "month": month})
+		return
+	case errors.Is(err, repository.ErrStatusTransition):
+		helpers.RedirectWithError(w, r, m.App.Session, showURL,
+			fmt.Sprintf("This reservation can't be changed to %s from its current status", strings.ToLower(lifecycleStatusLabels[status])))
+		return
+	case err != nil:
+		helpers.ServerError(w, err)
+		return
+	}
+
+	if status == models.StatusCancelled {
+		if res, err := m.DB.GetReservationByID(id); err == nil {
+			m.logWaitlistMatches(res)
+		}
+	}
+
+	m.audit(r, "reservation.status", fmt.Sprintf("Set reservation %d to %s", id, status))
+	helpers.RedirectWithFlash(w, r, m.App.Session, showURL, "Status changed to "+strings.ToLower(lifecycleStatusLabels[status]))
+}
+
+// AdminPostReservationDates handles POST /admin/reservations/{src}/{id}/dates
+// and moves a reservation to new dates, along with the room restriction that
+// holds those dates on the calendar.
+//
+// Processing logic:
+//  1. Loads the reservation (404 page when missing) and parses start_date and
+//     end_date as YYYY-MM-DD; end must be after start
+//  2. Loads the reservation's restriction with GetReservationRestriction
+//  3. Refuses the change if any other restriction on the room overlaps the
+//     new nights; the reservation's own restriction is ignored, so a stay
+//     can be extended or shortened in place
+//  4. Reprices the stay night by night with the same rates as quote
+//  5. Updates both rows and the total together, records an audit entry and
+//     returns to the reservation page
+//
+// Staff override the guest booking rules: the minimum and maximum stay, the
+// room's lead time and the advance-booking window are not applied, so a stay
+// already under way can be extended or a long booking arranged by hand.
+// Validation problems redirect back to the reservation page with an error.
+func (m *Repository) AdminPostReservationDates(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		if helpers.BodyTooLarge(w, err) {
+			return
+		}
+		helpers.ServerError(w, err)
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	src := normalizeSrc(chi.URLParam(r, "src"))
+	year, month := r.Form.Get("year"), r.Form.Get("month")
+	showURL := adminShowURL(src, id, year, month)
+
+	res, err := m.DB.GetReservationByID(id)
+	if errors.Is(err, repository.ErrReservationNotFound) {
+		m.reservationNotFound(w, r, id, map[string]string{"src": src, "year": year, "month": month})
+		return
+	} else if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	layout := "2006-01-02"
+	start, startErr := time.Parse(layout, r.Form.Get("start_date"))
+	end, endErr := time.Parse(layout, r.Form.Get("end_date"))
+	if startErr != nil || endErr != nil {
+		helpers.RedirectWithError(w, r, m.App.Session, showURL, "Enter both dates as YYYY-MM-DD")
+		return
+	}
+	if !end.After(start) {
+		helpers.RedirectWithError(w, r, m.App.Session, showURL, "Departure must be after arrival")
+		return
+	}
+
+	own, err := m.DB.GetReservationRestriction(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.RedirectWithError(w, r, m.App.Session, showURL, "This reservation has no calendar entry to move")
+		return
+	} else if err != nil {
+		helpers.ServerError(w, err)
+		return
 	}
 
+	// The restriction query includes its end day, and the departure night
+	// isn't stayed, so look through the last night only.
+	restrictions, err := m.DB.GetRestrictionsForRoomByDate(own.RoomID, start, end.AddDate(0, 0, -1))
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	for _, rr := range restrictions {
+		if rr.ID != own.ID {
+			helpers.RedirectWithError(w, r, m.App.Session, showURL, "The room is not available for those dates")
+			return
+		}
+	}
+
+	_, total, err := m.priceNights(own.RoomID, start, end)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	if err := m.DB.UpdateReservationDates(id, own.ID, start, end, total); err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	m.audit(r, "reservation.dates", fmt.Sprintf("Changed reservation %d dates from %s - %s to %s - %s", id,
+		res.StartDate.Format(layout), res.EndDate.Format(layout), start.Format(layout), end.Format(layout)))
+	helpers.RedirectWithFlash(w, r, m.App.Session, showURL, "Dates changed")
+}
+
+// calendarYearWindow is how many years either side of the current year the
+// admin calendar will display.
+const calendarYearWindow = 5
+
+// calendarMonth resolves the admin calendar's y/m query parameters to the
+// month to display. With neither parameter it returns now. When both parse,
+// the month is 1-12 and the year is within calendarYearWindow of now, it
+// returns the first of that month; otherwise it returns now and false so the
+// caller can warn about the bad input.
+func calendarMonth(q url.Values, now time.Time) (time.Time, bool) {
+	if q.Get("y") == "" && q.Get("m") == "" {
+		return now, true
+	}
+
+	year, err := strconv.Atoi(q.Get("y"))
+	if err != nil || year < now.Year()-calendarYearWindow || year > now.Year()+calendarYearWindow {
+		return now, false
+	}
+	month, err := strconv.Atoi(q.Get("m"))
+	if err != nil || month < 1 || month > 12 {
+		return now, false
+	}
+
+	return time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.Local), true
 }
 
 // AdminReservationsCalendar handles GET requests to display the reservation calendar view.
@@ -888,14 +2267,13 @@ func (m *Repository) AdminPostShowReservation(w http.ResponseWriter, r *http.Req
 // - Month navigation with preserved state
 // - Interactive editing of room blocks
 // - Session storage of block maps for form processing
+//
+// An out-of-range or non-numeric y/m falls back to the current month with a
+// warning flash (see calendarMonth).
 func (m *Repository) AdminReservationsCalendar(w http.ResponseWriter, r *http.Request) {
-	now := time.Now()
-
-	if r.URL.Query().Get("y") != "" {
-		year, _ := strconv.Atoi(r.URL.Query().Get("y"))
-		month, _ := strconv.Atoi(r.URL.Query().Get("m"))
-
-		now = time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.Local)
+	now, ok := calendarMonth(r.URL.Query(), time.Now())
+	if !ok {
+		helpers.AddFlash(r, models.FlashWarning, "That calendar month is not available; showing the current month instead.")
 	}
 
 	data := make(map[string]interface{})
@@ -935,32 +2313,36 @@ func (m *Repository) AdminReservationsCalendar(w http.ResponseWriter, r *http.Re
 
 	data["rooms"] = rooms
 
+	// Fetch every room's restrictions for the month in one query.
+	allRestrictions, err := m.DB.GetRestrictionsForAllRoomsByDate(firstOfMonth, lastOfMonth)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
 	for _, x := range rooms {
 		reservationMap := make(map[string]int)
 		blockMap := make(map[string]int)
+		blockNotes := make(map[string]string)
 
 		for d := firstOfMonth; !d.After(lastOfMonth); d = d.AddDate(0, 0, 1) {
 			reservationMap[d.Format("01/02/2006")] = 0
 			blockMap[d.Format("01/02/2006")] = 0
 		}
 
-		restrictions, err := m.DB.GetRestrictionsForRoomByDate(x.ID, firstOfMonth, lastOfMonth)
-		if err != nil {
-			helpers.ServerError(w, err)
-			return
-		}
-
-		for _, y := range restrictions {
+		for _, y := range allRestrictions[x.ID] {
 			if y.ReservationID > 0 {
 				for d := y.StartDate; !d.After(y.EndDate); d = d.AddDate(0, 0, 1) {
 					reservationMap[d.Format("01/02/2006")] = y.ReservationID
 				}
 			} else {
 				blockMap[y.StartDate.Format("01/02/2006")] = y.ID
+				blockNotes[y.StartDate.Format("01/02/2006")] = blockLabel(y)
 			}
 		}
 		data[fmt.Sprintf("reservation_map_%d", x.ID)] = reservationMap
 		data[fmt.Sprintf("block_map_%d", x.ID)] = blockMap
+		data[fmt.Sprintf("block_notes_%d", x.ID)] = blockNotes
 
 		m.App.Session.Put(r.Context(), fmt.Sprintf("block_map_%d", x.ID), blockMap)
 
@@ -979,26 +2361,35 @@ func (m *Repository) AdminReservationsCalendar(w http.ResponseWriter, r *http.Re
 // The handler preserves navigation context for seamless user experience
 // when working with large reservation lists.
 func (m *Repository) AdminProcessReservation(w http.ResponseWriter, r *http.Request) {
+	m.setProcessed(w, r, models.ReservationProcessed, "reservation.process", "Marked reservation %d as processed", "Reservation marked as processed!")
+}
+
+// AdminUnprocessReservation handles GET requests to reopen a reservation that
+// was marked as processed by mistake. It sets processed back to
+// models.ReservationNew and redirects exactly like AdminProcessReservation.
+func (m *Repository) AdminUnprocessReservation(w http.ResponseWriter, r *http.Request) {
+	m.setProcessed(w, r, models.ReservationNew, "reservation.unprocess", "Reopened reservation %d", "Reservation reopened!")
+}
+
+// setProcessed implements AdminProcessReservation and AdminUnprocessReservation:
+// it stores processed for the {id} route parameter, audits the change with
+// action and auditFormat (which receives the ID), flashes flash, and returns
+// to the calendar (when y/m are present) or the {src} list.
+func (m *Repository) setProcessed(w http.ResponseWriter, r *http.Request, processed int, action, auditFormat, flash string) {
 	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
-	src := chi.URLParam(r, "src")
+	src := normalizeSrc(chi.URLParam(r, "src"))
 
-	err := m.DB.UpdateProcessedForReservation(id, 1)
+	err := m.DB.UpdateProcessedForReservation(id, processed)
 	if err != nil {
 		log.Println(err)
+	} else {
+		m.audit(r, action, fmt.Sprintf(auditFormat, id))
 	}
 
 	year := r.URL.Query().Get("y")
 	month := r.URL.Query().Get("m")
 
-	m.App.Session.Put(r.Context(), "flash", "Reservation marked as processed!")
-
-	if year == "" {
-		http.Redirect(w, r, fmt.Sprintf("/admin/reservations-%s", src), http.StatusSeeOther)
-	} else {
-		http.Redirect(w, r, fmt.Sprintf("/admin/reservations-calendar?y=%s&m=%s", year, month), http.StatusSeeOther)
-
-	}
-
+	helpers.RedirectWithFlash(w, r, m.App.Session, adminReturnURL(src, year, month), flash)
 }
 
 // AdminDeleteReservation handles GET requests to delete reservations.
@@ -1008,83 +2399,1260 @@ func (m *Repository) AdminProcessReservation(w http.ResponseWriter, r *http.Requ
 // through flash messages.
 func (m *Repository) AdminDeleteReservation(w http.ResponseWriter, r *http.Request) {
 	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
-	src := chi.URLParam(r, "src")
-
-	_ = m.DB.DeleteReservation(id)
+	src := normalizeSrc(chi.URLParam(r, "src"))
+
+	// Look the reservation up first so freed dates can be matched to the waitlist.
+	res, lookupErr := m.DB.GetReservationByID(id)
+
+	err := m.DB.DeleteReservation(id)
+	if err == nil {
+		detail := fmt.Sprintf("Deleted reservation %d", id)
+		if lookupErr == nil {
+			m.logWaitlistMatches(res)
+			detail += fmt.Sprintf(" for %s %s (%s to %s)", res.FirstName, res.LastName,
+				res.StartDate.Format("01/02/2006"), res.EndDate.Format("01/02/2006"))
+		}
+		m.audit(r, "reservation.delete", detail)
+	}
 
 	year := r.URL.Query().Get("y")
 	month := r.URL.Query().Get("m")
 
-	m.App.Session.Put(r.Context(), "flash", "Reservation deleted!")
-
-	if year == "" {
-		http.Redirect(w, r, fmt.Sprintf("/admin/reservations-%s", src), http.StatusSeeOther)
-	} else {
-		http.Redirect(w, r, fmt.Sprintf("/admin/reservations-calendar?y=%s&m=%s", year, month), http.StatusSeeOther)
-
-	}
-
+	helpers.RedirectWithFlash(w, r, m.App.Session, adminReturnURL(src, year, month), "Reservation deleted!")
 }
 
-// AdminPostReservationsCalendar handles POST requests to update room availability blocks.
-// It processes form submissions from the calendar view, managing room blocks
-// (owner-restricted dates) by adding new blocks and removing existing ones
-// based on checkbox selections. The handler compares current form state
-// with stored session data to determine which blocks to add or remove.
+// bulkDeleteConfirmation is the word staff must type into the confirm field
+// of the bulk delete form. Checking it server-side means a stray submit, or a
+// form replayed without it, can't remove a batch of bookings.
+const bulkDeleteConfirmation = "DELETE"
+
+// AdminBulkDeleteReservations handles POST /admin/reservations/bulk-delete,
+// removing every reservation ticked on the reservations list (ids[]) and the
+// room restrictions holding their dates, in one transaction.
 //
-// Processing logic:
-// 1. Retrieves all rooms and their current block states from session
-// 2. Removes blocks that were unchecked (removed checkboxes)
-// 3. Adds new blocks for checked dates (added checkboxes)
-// 4. Redirects back to calendar view with success message
-func (m *Repository) AdminPostReservationsCalendar(w http.ResponseWriter, r *http.Request) {
+// The form must carry confirm=DELETE (bulkDeleteConfirmation); without it
+// nothing is deleted. The optional status field is the list tab to return to.
+//
+// Responses:
+//   - 303 back to the list with a flash reporting the count on success
+//   - 303 back to the list with an error when the confirmation is missing,
+//     no reservations were selected, or one of them no longer exists (in
+//     which case none are deleted)
+//   - 400 for a malformed ID
+//   - 500 for other database errors
+func (m *Repository) AdminBulkDeleteReservations(w http.ResponseWriter, r *http.Request) {
 	err := r.ParseForm()
 	if err != nil {
+		if helpers.BodyTooLarge(w, err) {
+			return
+		}
 		helpers.ServerError(w, err)
 		return
 	}
 
-	year, _ := strconv.Atoi(r.Form.Get("y"))
-	month, _ := strconv.Atoi(r.Form.Get("m"))
+	status := r.Form.Get("status")
+	if !repository.ValidReservationStatus(status) {
+		status = repository.ReservationStatusAll
+	}
+	listURL := "/admin/reservations?status=" + status
+
+	ids := make([]int, 0, len(r.Form["ids[]"]))
+	seen := make(map[int]bool)
+	for _, v := range r.Form["ids[]"] {
+		id, err := strconv.Atoi(v)
+		if err != nil || id <= 0 {
+			helpers.ClientError(w, http.StatusBadRequest)
+			return
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
 
-	rooms, err := m.DB.AllRooms()
-	if err != nil {
-		helpers.ServerError(w, err)
+	if strings.TrimSpace(r.Form.Get("confirm")) != bulkDeleteConfirmation {
+		helpers.RedirectWithError(w, r, m.App.Session, listURL, "Type "+bulkDeleteConfirmation+" to confirm the bulk delete")
+		return
+	}
+	if len(ids) == 0 {
+		helpers.RedirectWithError(w, r, m.App.Session, listURL, "Select at least one reservation to delete")
 		return
 	}
 
-	form := forms.New(r.PostForm)
-
-	for _, x := range rooms {
-		curMap := m.App.Session.Get(r.Context(), fmt.Sprintf("block_map_%d", x.ID)).(map[string]int)
-		for name, value := range curMap {
-			if val, ok := curMap[name]; ok {
-				if val > 0 {
-					if !form.Has(fmt.Sprintf("remove_block_%d_%s", x.ID, name)) {
-						err := m.DB.DeleteBlockByID(value)
-						if err != nil {
-							log.Println(err)
-						}
-					}
-				}
-			}
+	// Look the reservations up first so freed dates can be matched to the waitlist.
+	deleted := make([]models.Reservation, 0, len(ids))
+	for _, id := range ids {
+		if res, err := m.DB.GetReservationByID(id); err == nil {
+			deleted = append(deleted, res)
 		}
 	}
 
-	for name, _ := range r.PostForm {
+	err = m.DB.DeleteReservationsBatch(ids)
+	switch {
+	case errors.Is(err, repository.ErrReservationNotFound):
+		helpers.RedirectWithError(w, r, m.App.Session, listURL, "One of the selected reservations no longer exists; nothing was deleted")
+		return
+	case err != nil:
+		helpers.ServerError(w, err)
+		return
+	}
+
+	for _, res := range deleted {
+		m.logWaitlistMatches(res)
+	}
+
+	idList := make([]string, 0, len(ids))
+	for _, id := range ids {
+		idList = append(idList, strconv.Itoa(id))
+	}
+	m.audit(r, "reservation.bulk_delete", "Deleted reservations "+strings.Join(idList, ", "))
+
+	flash := fmt.Sprintf("Deleted %d reservations", len(ids))
+	if len(ids) == 1 {
+		flash = "Deleted 1 reservation"
+	}
+	helpers.RedirectWithFlash(w, r, m.App.Session, listURL, flash)
+}
+
+// Restriction IDs staff can choose as the reason for a calendar block.
+const (
+	restrictionOwnerBlock  = 2
+	restrictionMaintenance = 3
+)
+
+// blockReasons maps selectable block reasons to their display names.
+var blockReasons = map[int]string{
+	restrictionOwnerBlock:  "Owner Block",
+	restrictionMaintenance: "Maintenance",
+}
+
+// blockLabel returns the calendar hover text for a block: its reason, followed
+// by the staff note when one was recorded. Holds taken during booking are
+// labelled as such.
+func blockLabel(b models.RoomRestriction) string {
+	if b.RestrictionID == repository.HoldRestrictionID {
+		return "Held for a guest who is booking"
+	}
+	label, ok := blockReasons[b.RestrictionID]
+	if !ok {
+		label = blockReasons[restrictionOwnerBlock]
+	}
+	if b.Note != "" {
+		label += ": " + b.Note
+	}
+	return label
+}
+
+// AdminPostReservationsCalendar handles POST requests to update room availability blocks.
+// It processes form submissions from the calendar view, managing room blocks
+// (owner-restricted dates) by adding new blocks and removing existing ones
+// based on checkbox selections. The handler compares current form state
+// with stored session data to determine which blocks to add or remove.
+//
+// Processing logic:
+//  1. Retrieves all rooms and their current block states from session
+//  2. Removes blocks that were unchecked (removed checkboxes)
+//  3. Adds new blocks for checked dates (added checkboxes) with the selected
+//     reason (Owner Block or Maintenance) and optional note. Dates before
+//     today, or that don't parse, are skipped, logged and reported in a
+//     warning flash
+//  4. Redirects back to calendar view with success message
+func (m *Repository) AdminPostReservationsCalendar(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		if helpers.BodyTooLarge(w, err) {
+			return
+		}
+		helpers.ServerError(w, err)
+		return
+	}
+
+	year, _ := strconv.Atoi(r.Form.Get("y"))
+	month, _ := strconv.Atoi(r.Form.Get("m"))
+
+	rooms, err := m.DB.AllRooms()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Trim("block_note")
+
+	// New blocks share the reason and note chosen above the calendar.
+	reason, _ := strconv.Atoi(form.Get("block_reason"))
+	if _, ok := blockReasons[reason]; !ok {
+		reason = restrictionOwnerBlock
+	}
+	note := form.Get("block_note")
+
+	for _, x := range rooms {
+		// A room added since the calendar was loaded, or an expired session,
+		// has no stored block map; there are no existing blocks to remove.
+		curMap, ok := m.App.Session.Get(r.Context(), fmt.Sprintf("block_map_%d", x.ID)).(map[string]int)
+		if !ok {
+			continue
+		}
+		for name, value := range curMap {
+			if val, ok := curMap[name]; ok {
+				if val > 0 {
+					if !form.Has(fmt.Sprintf("remove_block_%d_%s", x.ID, name)) {
+						err := m.DB.DeleteBlockByID(value)
+						if err != nil {
+							log.Println(err)
+						} else {
+							m.audit(r, "block.remove", fmt.Sprintf("Removed block %d on %s from %s", value, name, x.RoomName))
+						}
+					}
+				}
+			}
+		}
+	}
+
+	today := m.today()
+	var skipped []string
+
+	for name, _ := range r.PostForm {
 		if strings.HasPrefix(name, "add_block") {
 			exploded := strings.Split(name, "_")
 			roomID, _ := strconv.Atoi(exploded[2])
-			t, _ := time.Parse("01/02/2006", exploded[3])
+			t, err := time.Parse("01/02/2006", exploded[3])
+			if err != nil || t.Before(today) {
+				m.App.InfoLog.Printf("skipping block for room %d on %q: date is in the past or invalid", roomID, exploded[3])
+				skipped = append(skipped, exploded[3])
+				continue
+			}
 
-			err := m.DB.InsertBlockForRoom(roomID, t)
+			err = m.DB.InsertBlockForRoom(roomID, t, reason, note)
 			if err != nil {
 				log.Println(err)
+			} else {
+				m.audit(r, "block.add", fmt.Sprintf("Added %s on %s to room %d",
+					blockLabel(models.RoomRestriction{RestrictionID: reason, Note: note}), exploded[3], roomID))
 			}
 		}
 	}
 
-	m.App.Session.Put(r.Context(), "flash", "Changes Saved")
-	http.Redirect(w, r, fmt.Sprintf("/admin/reservations-calendar?y=%d&m=%d", year, month), http.StatusSeeOther)
+	if len(skipped) > 0 {
+		sort.Strings(skipped)
+		helpers.AddFlash(r, models.FlashWarning, fmt.Sprintf("Past dates can't be blocked; skipped %s", strings.Join(skipped, ", ")))
+	}
+
+	helpers.RedirectWithFlash(w, r, m.App.Session, fmt.Sprintf("/admin/reservations-calendar?y=%d&m=%d", year, month), "Changes Saved")
+
+}
+
+// AdminCloseRoom handles POST /admin/rooms/{id}/close. It takes the room out
+// of service indefinitely: its reservations and blocks are kept, but it is no
+// longer offered in availability searches or accepted for new bookings.
+func (m *Repository) AdminCloseRoom(w http.ResponseWriter, r *http.Request) {
+	m.setRoomActive(w, r, false)
+}
+
+// AdminOpenRoom handles POST /admin/rooms/{id}/open and returns a closed room
+// to service.
+func (m *Repository) AdminOpenRoom(w http.ResponseWriter, r *http.Request) {
+	m.setRoomActive(w, r, true)
+}
+
+// setRoomActive opens or closes the room named by the {id} URL parameter,
+// audits the change, and redirects back to the calendar month in the form's
+// y/m fields (the current month when absent). An unknown room is a 404.
+func (m *Repository) setRoomActive(w http.ResponseWriter, r *http.Request, active bool) {
+	err := r.ParseForm()
+	if err != nil {
+		if helpers.BodyTooLarge(w, err) {
+			return
+		}
+		helpers.ServerError(w, err)
+		return
+	}
+
+	roomID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = m.DB.SetRoomActive(roomID, active)
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	} else if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	action, detail, flash := "room.close", "Closed", "Room closed; it will not be offered to guests until reopened"
+	if active {
+		action, detail, flash = "room.open", "Reopened", "Room reopened"
+	}
+	m.audit(r, action, fmt.Sprintf("%s room %d", detail, roomID))
+
+	dest := "/admin/reservations-calendar"
+	if y, mo := r.Form.Get("y"), r.Form.Get("m"); y != "" && mo != "" {
+		dest += fmt.Sprintf("?y=%s&m=%s", url.QueryEscape(y), url.QueryEscape(mo))
+	}
+	helpers.RedirectWithFlash(w, r, m.App.Session, dest, flash)
+}
+
+// roomConflict is one existing restriction reported by AdminRoomCheck. Unlike
+// blockedRange it is for staff, so it links the reservation and carries the
+// block note.
+type roomConflict struct {
+	Start         string `json:"start"`                    // First date of the restriction, YYYY-MM-DD
+	End           string `json:"end"`                      // Day after the last night (check-out day), YYYY-MM-DD
+	Type          string `json:"type"`                     // "reservation" or "block"
+	ReservationID int    `json:"reservation_id,omitempty"` // Set for reservations
+	Note          string `json:"note,omitempty"`           // Staff note on a block
+}
+
+// AdminRoomCheck handles GET /admin/rooms/{id}/check?start=&end=, previewing
+// which existing restrictions a manual booking from start to end (YYYY-MM-DD)
+// would collide with, so staff see conflicts before they submit. It reports
+// what GetRestrictionsForRoomByDate returns for the range.
+//
+// The response is a room-conflicts.partial.tmpl fragment to drop into the
+// booking page, or a JSON array of roomConflict (empty when the range is
+// clear) for clients that send Accept: application/json.
+//
+// Responses:
+//   - 200 with the conflicts, or "No conflicts" when there are none
+//   - 400 if the room id or dates are malformed, or end is not after start
+//   - 500 if the restrictions cannot be loaded
+func (m *Repository) AdminRoomCheck(w http.ResponseWriter, r *http.Request) {
+	wantsJSON := helpers.WantsJSON(r)
+	badRequest := func(msg string) {
+		if wantsJSON {
+			writeAPIError(w, http.StatusBadRequest, errCodeInvalidInput, msg)
+			return
+		}
+		helpers.ClientError(w, http.StatusBadRequest)
+	}
+
+	roomID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		badRequest("Invalid room")
+		return
+	}
+
+	layout := "2006-01-02"
+	start, err := time.Parse(layout, r.URL.Query().Get("start"))
+	if err != nil {
+		badRequest("Invalid start date")
+		return
+	}
+	end, err := time.Parse(layout, r.URL.Query().Get("end"))
+	if err != nil {
+		badRequest("Invalid end date")
+		return
+	}
+	if !end.After(start) {
+		badRequest("End date must be after start date")
+		return
+	}
+
+	restrictions, err := m.DB.GetRestrictionsForRoomByDate(roomID, start, end)
+	if err != nil {
+		if wantsJSON {
+			m.App.ErrorLog.Println(err)
+			writeAPIError(w, http.StatusInternalServerError, errCodeServer, "Error querying database")
+			return
+		}
+		helpers.ServerError(w, err)
+		return
+	}
+
+	conflicts := make([]roomConflict, 0, len(restrictions))
+	for _, rr := range restrictions {
+		c := roomConflict{
+			Start: rr.StartDate.Format(layout),
+			End:   rr.EndDate.Format(layout),
+			Type:  "block",
+			Note:  rr.Note,
+		}
+		if rr.ReservationID > 0 {
+			c.Type = "reservation"
+			c.ReservationID = rr.ReservationID
+		}
+		conflicts = append(conflicts, c)
+	}
+
+	if wantsJSON {
+		writeJSON(w, http.StatusOK, conflicts)
+		return
+	}
+
+	render.Fragment(w, r, "room-conflicts.partial.tmpl", &models.TemplateData{
+		Data: map[string]interface{}{"conflicts": conflicts},
+	})
+}
+
+// AdminReportConflicts handles GET requests for the restriction conflict report.
+// It lists every pair of overlapping restrictions on the same room so staff can
+// remove stray blocks or fix double bookings by hand. If the audit query fails,
+// it returns an internal server error response.
+func (m *Repository) AdminReportConflicts(w http.ResponseWriter, r *http.Request) {
+	conflicts, err := m.DB.FindOverlappingRestrictions()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["conflicts"] = conflicts
+
+	render.Template(w, r, "admin-reports-conflicts.page.tmpl", &models.TemplateData{
+		Data: data,
+	})
+}
+
+// auditPageSize is the number of entries shown on the audit log page.
+const auditPageSize = 100
+
+// audit records an admin action against the user ID in the session. Failures
+// are logged rather than returned: the action itself has already succeeded and
+// staff should not be told otherwise.
+func (m *Repository) audit(r *http.Request, action, detail string) {
+	userID := m.App.Session.GetInt(r.Context(), "user_id")
+	if err := m.DB.RecordAudit(userID, action, detail); err != nil {
+		m.App.ErrorLog.Println(err)
+	}
+}
+
+// AdminAuditLog handles GET /admin/audit and lists the most recent audit log
+// entries (edits, deletes, processing and calendar block changes) with the
+// staff member who made each one.
+func (m *Repository) AdminAuditLog(w http.ResponseWriter, r *http.Request) {
+	entries, err := m.DB.RecentAudit(auditPageSize)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	render.Template(w, r, "admin-audit.page.tmpl", &models.TemplateData{
+		Data: map[string]interface{}{"entries": entries},
+	})
+}
+
+// mailLogPageSize is the number of entries shown on the mail log page.
+const mailLogPageSize = 100
+
+// AdminMailLog handles GET /admin/mail-log and lists the most recent email
+// send attempts with their recipient, subject and outcome.
+func (m *Repository) AdminMailLog(w http.ResponseWriter, r *http.Request) {
+	entries, err := m.DB.RecentSentMail(mailLogPageSize)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	render.Template(w, r, "admin-mail-log.page.tmpl", &models.TemplateData{
+		Data: map[string]interface{}{"entries": entries},
+	})
+}
+
+// AdminMailFailures handles GET /admin/mail-failures and lists the messages
+// in the failed-mail queue: emails whose delivery failed, kept in full with
+// the error from the attempt so staff can retry them.
+func (m *Repository) AdminMailFailures(w http.ResponseWriter, r *http.Request) {
+	msgs, err := m.DB.FailedMail()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	render.Template(w, r, "admin-mail-failures.page.tmpl", &models.TemplateData{
+		Data: map[string]interface{}{"messages": msgs},
+	})
+}
+
+// AdminRetryMail handles POST /admin/mail-failures/{id}/retry. It takes the
+// message out of the failed-mail queue and puts it back on the mail channel;
+// if delivery fails again the message returns to the queue as a new entry.
+// A message that is no longer queued (e.g. a double-clicked retry) redirects
+// back with an error rather than failing.
+func (m *Repository) AdminRetryMail(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	msg, err := m.DB.RequeueMail(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.RedirectWithError(w, r, m.App.Session, "/admin/mail-failures", "That message is no longer waiting to be retried.")
+		return
+	} else if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	m.queueMail([]models.MailData{msg})
+	m.audit(r, "mail.retry", fmt.Sprintf("Retried %q to %s", msg.Subject, msg.To))
+	helpers.RedirectWithFlash(w, r, m.App.Session, "/admin/mail-failures", fmt.Sprintf("Message to %s queued for another attempt", msg.To))
+}
+
+// AdminViewAsGuest handles GET /admin/view-as?email=, a read-only view of
+// the reservations a guest has made with that email, for staff debugging a
+// guest's report. It only reads ReservationsByEmail; the guest's own session
+// and data are never touched.
+//
+// Only users at adminAccessLevel may use it; others get 403. Each lookup is
+// recorded in the audit log as "guest.view_as". Without an email the page
+// shows just the lookup form; an invalid email re-renders it with an error.
+func (m *Repository) AdminViewAsGuest(w http.ResponseWriter, r *http.Request) {
+	if !m.hasAccessLevel(r, adminAccessLevel) {
+		helpers.ClientError(w, http.StatusForbidden)
+		return
+	}
+
+	form := forms.New(r.URL.Query())
+	form.Trim("email")
+	email := strings.ToLower(form.Get("email"))
+	data := map[string]interface{}{}
+
+	if email != "" {
+		form.IsEmail("email")
+	}
+	if email != "" && form.Valid() {
+		reservations, err := m.DB.ReservationsByEmail(email)
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+		m.audit(r, "guest.view_as", fmt.Sprintf("Viewed reservations of %s", email))
+		data["reservations"] = reservations
+	}
+
+	render.Template(w, r, "admin-view-as.page.tmpl", &models.TemplateData{
+		Form:      form,
+		Data:      data,
+		StringMap: map[string]string{"email": email},
+	})
+}
+
+// adminAccessLevel is the access level required to manage staff accounts.
+const adminAccessLevel = 3
+
+// minPasswordLength is the shortest password accepted for a new account or a
+// password reset.
+const minPasswordLength = 8
+
+// hasAccessLevel reports whether the logged-in user's access level is at
+// least level. Anonymous requests and failed lookups are treated as lacking it.
+func (m *Repository) hasAccessLevel(r *http.Request, level int) bool {
+	id := m.App.Session.GetInt(r.Context(), "user_id")
+	if id == 0 {
+		return false
+	}
+
+	u, err := m.DB.GetUserByID(id)
+	if err != nil {
+		m.App.ErrorLog.Println(err)
+		return false
+	}
+	return u.AccessLevel >= level
+}
+
+// generatePassword returns a random, URL-safe initial password for accounts
+// created without one.
+func generatePassword() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// AdminNewUser handles GET /admin/users/new and renders the staff account
+// form. Only users at adminAccessLevel may create accounts; others get 403.
+func (m *Repository) AdminNewUser(w http.ResponseWriter, r *http.Request) {
+	if !m.hasAccessLevel(r, adminAccessLevel) {
+		helpers.ClientError(w, http.StatusForbidden)
+		return
+	}
+
+	render.Template(w, r, "admin-users-new.page.tmpl", &models.TemplateData{
+		Form: forms.New(nil),
+		Data: map[string]interface{}{"user": models.User{AccessLevel: 1}},
+	})
+}
+
+// AdminPostNewUser handles POST /admin/users/new. It validates the form,
+// rejects emails that are already registered, creates the account with the
+// given password (or a generated one when left blank), and emails the new
+// user a welcome message with a link to sign in.
+//
+// Processing logic:
+//  1. Rejects callers below adminAccessLevel with 403
+//  2. Validates names, email, optional password length and access level (1-3)
+//  3. Checks GetUserByEmail for an existing account, then calls CreateUser,
+//     which also reports a duplicate that slipped in concurrently
+//  4. Queues the welcome email and redirects with a flash. A generated
+//     password is instead rendered once, in this response only, so it can be
+//     passed on; it is never put in the session, where it would be stored
+//     with the rest of the session data
+func (m *Repository) AdminPostNewUser(w http.ResponseWriter, r *http.Request) {
+	if !m.hasAccessLevel(r, adminAccessLevel) {
+		helpers.ClientError(w, http.StatusForbidden)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		if helpers.BodyTooLarge(w, err) {
+			return
+		}
+		helpers.ServerError(w, err)
+		return
+	}
+
+	// Passwords are used exactly as typed.
+	form := forms.New(r.PostForm)
+	form.Trim("email", "access_level")
+	form.Collapse("first_name", "last_name")
+
+	form.Required("first_name", "last_name", "email")
+	form.IsEmail("email")
+
+	password := form.Get("password")
+	if password != "" && len(password) < minPasswordLength {
+		form.Errors.Add("password", fmt.Sprintf("Password must be at least %d characters long", minPasswordLength))
+	}
+
+	level, err := strconv.Atoi(form.Get("access_level"))
+	if err != nil || level < 1 || level > adminAccessLevel {
+		form.Errors.Add("access_level", "Choose an access level")
+	}
+
+	u := models.User{
+		FirstName:   form.Get("first_name"),
+		LastName:    form.Get("last_name"),
+		Email:       form.Get("email"),
+		AccessLevel: level,
+	}
+
+	if form.Valid() {
+		_, err := m.DB.GetUserByEmail(u.Email)
+		switch {
+		case err == nil:
+			form.Errors.Add("email", "A user with this email already exists")
+		case !errors.Is(err, repository.ErrUserNotFound):
+			helpers.ServerError(w, err)
+			return
+		}
+	}
+
+	generated := false
+	if form.Valid() && password == "" {
+		password, err = generatePassword()
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+		generated = true
+	}
+
+	if form.Valid() {
+		_, err = m.DB.CreateUser(u, password)
+		if errors.Is(err, repository.ErrDuplicateEmail) {
+			form.Errors.Add("email", "A user with this email already exists")
+		} else if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+	}
+
+	if !form.Valid() {
+		render.Template(w, r, "admin-users-new.page.tmpl", &models.TemplateData{
+			Form: form,
+			Data: map[string]interface{}{"user": u},
+		})
+		return
+	}
+
+	msg := fmt.Sprintf("Account created for %s", u.Email)
+
+	loginURL, err := m.emailURL("/user/login")
+	if err != nil {
+		m.App.ErrorLog.Println(err)
+		msg += ". No welcome email was sent because BASE_URL is not set"
+	} else {
+		resetURL, _ := m.emailURL("/user/forgot-password")
+		m.App.MailChan <- models.MailData{
+			To:      u.Email,
+			From:    "milo@milos-residence.com",
+			Subject: "Welcome to " + m.App.Property(),
+			Content: fmt.Sprintf(`
+			<strong>Welcome, %s!</strong><br>
+			A staff account has been created for you at %s.<br>
+			Sign in with this email address and the password your administrator gives you at
+			<a href="%s">%s</a>.<br>
+			You can choose your own password at any time from <a href="%s">%s</a>.
+	`, template.HTMLEscapeString(u.FirstName), template.HTMLEscapeString(m.App.Property()), loginURL, loginURL, resetURL, resetURL),
+			Template: "basic.html",
+		}
+	}
+
+	if generated {
+		// The password appears in this response only; keep browsers and
+		// proxies from storing it.
+		w.Header().Set("Cache-Control", "no-store")
+		render.Template(w, r, "admin-users-new.page.tmpl", &models.TemplateData{
+			Form:      forms.New(nil),
+			Data:      map[string]interface{}{"user": models.User{AccessLevel: 1}},
+			StringMap: map[string]string{"created": msg, "temp_password": password},
+		})
+		return
+	}
+
+	helpers.RedirectWithFlash(w, r, m.App.Session, "/admin/users/new", msg)
+}
+
+// pathToEmailTemplates is the directory email templates are loaded from.
+// Tests override it because they run from the package directory.
+var pathToEmailTemplates = "./email-templates"
+
+// emailPreviewBody is the sample message substituted for the [%body%]
+// placeholder when previewing an email template.
+var emailPreviewBody = template.Must(template.New("email-preview-body").Parse(
+	`<strong>Reservation Confirmation</strong><br>
+Dear {{.FirstName}},<br>
+This is a preview of your reservation from {{.StartDate}} to {{.EndDate}}.`))
+
+// AdminEmailPreview handles GET requests for /admin/email-preview/{template}.
+// It renders ./email-templates/{template} with sample reservation data so staff
+// can iterate on email markup without sending mail. Sample values may be
+// overridden with the first_name, start_date and end_date query parameters.
+//
+// The endpoint is a development aid only: in production, and for template
+// names that are not a plain file in the templates directory, it responds
+// with 404 Not Found.
+func (m *Repository) AdminEmailPreview(w http.ResponseWriter, r *http.Request) {
+	if m.App.InProduction {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Reject anything that would escape the email templates directory.
+	name := chi.URLParam(r, "template")
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		http.NotFound(w, r)
+		return
+	}
+
+	raw, err := os.ReadFile(filepath.Join(pathToEmailTemplates, name))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			http.NotFound(w, r)
+			return
+		}
+		helpers.ServerError(w, err)
+		return
+	}
+
+	// Sample data, overridable from the query string.
+	sample := map[string]string{
+		"FirstName": "Milo",
+		"StartDate": "01/02/2030",
+		"EndDate":   "01/05/2030",
+	}
+	q := r.URL.Query()
+	for key, field := range map[string]string{"first_name": "FirstName", "start_date": "StartDate", "end_date": "EndDate"} {
+		if v := q.Get(key); v != "" {
+			sample[field] = v
+		}
+	}
+
+	// Render the sample body first so query values are escaped, then the layout.
+	var body bytes.Buffer
+	if err := emailPreviewBody.Execute(&body, sample); err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	t, err := template.New(name).Parse(string(raw))
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	var out bytes.Buffer
+	if err := t.Execute(&out, sample); err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	html := strings.Replace(out.String(), "[%body%]", body.String(), 1)
+	html = strings.ReplaceAll(html, "[%property%]", template.HTMLEscapeString(m.App.Property()))
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write([]byte(html))
+}
+
+// Waitlist handles GET requests for the waitlist signup page. The start and end
+// query parameters (set when an availability search comes back empty) prefill
+// the requested dates.
+func (m *Repository) Waitlist(w http.ResponseWriter, r *http.Request) {
+	stringMap := make(map[string]string)
+	stringMap["start"] = r.URL.Query().Get("start")
+	stringMap["end"] = r.URL.Query().Get("end")
+
+	data := make(map[string]interface{})
+	data["entry"] = models.WaitlistEntry{}
+
+	render.Template(w, r, "waitlist.page.tmpl", &models.TemplateData{
+		Form:      forms.New(nil),
+		Data:      data,
+		StringMap: stringMap,
+	})
+}
+
+// PostWaitlist handles POST requests for the waitlist signup form. It validates
+// the guest's details, stores the entry and redirects home with a confirmation.
+// Invalid submissions re-render the form with errors; unparseable dates or a
+// database failure redirect with an error flash, like PostReservation.
+func (m *Repository) PostWaitlist(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		if helpers.BodyTooLarge(w, err) {
+			return
+		}
+		helpers.RedirectWithError(w, r, m.App.Session, "/", "can't parse form!")
+		return
+	}
+
+	start := r.Form.Get("start")
+	end := r.Form.Get("end")
+
+	layout := "01/02/2006"
+	startDate, err := time.Parse(layout, start)
+	if err != nil {
+		helpers.RedirectWithError(w, r, m.App.Session, "/waitlist", "can't parse start date!")
+		return
+	}
+
+	endDate, err := time.Parse(layout, end)
+	if err != nil {
+		helpers.RedirectWithError(w, r, m.App.Session, "/waitlist", "can't parse end date!")
+		return
+	}
+	if !endDate.After(startDate) {
+		helpers.RedirectWithError(w, r, m.App.Session, "/waitlist", "Departure must be after arrival")
+		return
+	}
+
+	entry := models.WaitlistEntry{
+		FirstName: r.Form.Get("first_name"),
+		LastName:  r.Form.Get("last_name"),
+		Email:     r.Form.Get("email"),
+		Phone:     r.Form.Get("phone"),
+		StartDate: startDate,
+		EndDate:   endDate,
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("first_name", "last_name", "email")
+	form.MinLength("first_name", 3)
+	form.IsEmail("email")
+
+	if !form.Valid() {
+		stringMap := make(map[string]string)
+		stringMap["start"] = start
+		stringMap["end"] = end
+
+		data := make(map[string]interface{})
+		data["entry"] = entry
+
+		render.Template(w, r, "waitlist.page.tmpl", &models.TemplateData{
+			Form:      form,
+			Data:      data,
+			StringMap: stringMap,
+		})
+		return
+	}
+
+	err = m.DB.AddToWaitlist(entry)
+	if err != nil {
+		helpers.RedirectWithError(w, r, m.App.Session, "/", "can't join the waitlist!")
+		return
+	}
+
+	helpers.RedirectWithFlash(w, r, m.App.Session, "/", "You're on the waitlist! We'll be in touch if those dates open up.")
+}
+
+// logWaitlistMatches logs waitlist entries overlapping a cancelled reservation's
+// dates so staff can follow up with those guests. Lookup failures are logged and
+// otherwise ignored; cancellation has already succeeded.
+func (m *Repository) logWaitlistMatches(res models.Reservation) {
+	entries, err := m.DB.WaitlistForDates(res.StartDate, res.EndDate)
+	if err != nil {
+		m.App.ErrorLog.Println(err)
+		return
+	}
+
+	for _, e := range entries {
+		m.App.InfoLog.Printf("waitlist follow-up: reservation %d freed %s - %s; contact %s %s <%s> (wants %s - %s)",
+			res.ID,
+			res.StartDate.Format("01/02/2006"), res.EndDate.Format("01/02/2006"),
+			e.FirstName, e.LastName, e.Email,
+			e.StartDate.Format("01/02/2006"), e.EndDate.Format("01/02/2006"))
+	}
+}
+
+// defaultMinStayNights and defaultMaxStayNights bound the length of a stay
+// when neither a saved setting nor AppConfig.MinStayNights/MaxStayNights is
+// configured.
+const (
+	defaultMinStayNights = 1
+	defaultMaxStayNights = 30
+)
+
+// defaultMaxAdvanceDays is how far ahead a stay may start when
+// AppConfig.MaxAdvanceDays is not configured.
+const defaultMaxAdvanceDays = 365
+
+// checkAdvanceWindow adds a start_date error to form when start is more than
+// AppConfig.MaxAdvanceDays (or defaultMaxAdvanceDays) after m.today(). A
+// stay starting exactly on the last day of the window is allowed.
+func (m *Repository) checkAdvanceWindow(form *forms.Form, start time.Time) {
+	days := m.App.MaxAdvanceDays
+	if days <= 0 {
+		days = defaultMaxAdvanceDays
+	}
+	if start.After(m.today().AddDate(0, 0, days)) {
+		form.Errors.Add("start_date", fmt.Sprintf("Bookings can start at most %d days from today", days))
+	}
+}
+
+// checkLeadTime adds a start_date error to form when start is fewer than the
+// room's lead days after m.today(). Room.LeadDays overrides
+// AppConfig.MinLeadDays; a lead time of zero allows same-day stays.
+func (m *Repository) checkLeadTime(form *forms.Form, start time.Time, room models.Room) {
+	days := m.App.MinLeadDays
+	if room.LeadDays != nil {
+		days = *room.LeadDays
+	}
+	if days <= 0 {
+		return
+	}
+	if start.Before(m.today().AddDate(0, 0, days)) {
+		unit := "days"
+		if days == 1 {
+			unit = "day"
+		}
+		form.Errors.Add("start_date", fmt.Sprintf("This room must be booked at least %d %s in advance", days, unit))
+	}
+}
+
+// stayLimits returns the minimum and maximum number of nights from the saved
+// settings, then AppConfig, falling back to the defaults for unset values.
+func (m *Repository) stayLimits() (int, int) {
+	minNights, maxNights := m.App.MinStayNights, m.App.MaxStayNights
+	if n, ok := m.intSetting(settingMinStayNights); ok {
+		minNights = n
+	}
+	if n, ok := m.intSetting(settingMaxStayNights); ok {
+		maxNights = n
+	}
+	if minNights <= 0 {
+		minNights = defaultMinStayNights
+	}
+	if maxNights <= 0 {
+		maxNights = defaultMaxStayNights
+	}
+	return minNights, maxNights
+}
+
+// quoteNight is the price of a single night in a quote.
+type quoteNight struct {
+	Date string `json:"date"` // Night being priced (MM/DD/YYYY)
+	Rate int    `json:"rate"` // Nightly rate in cents after overrides
+}
+
+// quotePolicy is the outcome of one booking rule checked by quote.
+type quotePolicy struct {
+	Name    string `json:"name"`              // Rule name: min_stay, max_stay or capacity
+	Passed  bool   `json:"passed"`            // Whether the stay satisfies the rule
+	Message string `json:"message,omitempty"` // Reason the rule failed
+}
+
+// reservationQuote is the priced result of applying the booking rules to a
+// prospective stay. It is returned as JSON by QuoteAPI and used by
+// PostReservation to reject stays that break a rule.
+type reservationQuote struct {
+	OK        bool          `json:"ok"`         // Every policy passed (and, for QuoteAPI, the room is available)
+	Available bool          `json:"available"`  // Room is free for the dates; set by QuoteAPI
+	RoomID    int           `json:"room_id"`    // Room being quoted
+	StartDate string        `json:"start_date"` // Arrival (MM/DD/YYYY)
+	EndDate   string        `json:"end_date"`   // Departure (MM/DD/YYYY)
+	Guests    int           `json:"guests"`     // Party size checked against room capacity
+	Nights    int           `json:"nights"`     // Number of nights in the stay
+	PerNight  []quoteNight  `json:"per_night"`  // Price of each night; empty when the stay length is rejected
+	Total     int           `json:"total"`      // Sum of PerNight in cents
+	Policies  []quotePolicy `json:"policies"`   // Outcome of each booking rule
+}
+
+// failures returns the messages of the policies the quote did not pass.
+func (q reservationQuote) failures() []string {
+	var msgs []string
+	for _, p := range q.Policies {
+		if !p.Passed {
+			msgs = append(msgs, p.Message)
+		}
+	}
+	return msgs
+}
+
+// quote applies the booking rules shared by QuoteAPI and PostReservation to a
+// prospective reservation: the stay must be between the minimum and maximum
+// number of nights, and the party must fit the room. When the stay length is
+// acceptable, every night from start up to (but not including) end is priced,
+// so per-date overrides such as weekend or holiday rates apply only to the
+// nights they cover. Availability is not checked here; callers that need it
+// query the repository themselves.
+//
+// Returns an error if the room or a nightly rate cannot be loaded.
+func (m *Repository) quote(res models.Reservation, guests int) (reservationQuote, error) {
+	q := reservationQuote{
+		RoomID:    res.RoomID,
+		StartDate: res.StartDate.Format("01/02/2006"),
+		EndDate:   res.EndDate.Format("01/02/2006"),
+		Guests:    guests,
+		PerNight:  []quoteNight{},
+	}
+
+	room, err := m.DB.GetRoomByID(res.RoomID)
+	if err != nil {
+		return q, err
+	}
+
+	for d := res.StartDate; d.Before(res.EndDate); d = d.AddDate(0, 0, 1) {
+		q.Nights++
+	}
+
+	minNights, maxNights := m.stayLimits()
+	q.Policies = []quotePolicy{
+		{Name: "min_stay", Passed: q.Nights >= minNights},
+		{Name: "max_stay", Passed: q.Nights <= maxNights},
+		{Name: "capacity", Passed: guests >= 1 && guests <= room.MaxGuests},
+	}
+	if !q.Policies[0].Passed {
+		q.Policies[0].Message = fmt.Sprintf("Stays must be at least %d night(s)", minNights)
+	}
+	if !q.Policies[1].Passed {
+		q.Policies[1].Message = fmt.Sprintf("Stays can be at most %d nights", maxNights)
+	}
+	if !q.Policies[2].Passed {
+		q.Policies[2].Message = fmt.Sprintf("This room sleeps 1 to %d guests", room.MaxGuests)
+	}
+
+	// Only price stays of an acceptable length, so an absurd date range
+	// cannot trigger thousands of rate lookups.
+	if q.Policies[0].Passed && q.Policies[1].Passed {
+		q.PerNight, q.Total, err = m.priceNights(res.RoomID, res.StartDate, res.EndDate)
+		if err != nil {
+			return q, err
+		}
+	}
+
+	q.OK = len(q.failures()) == 0
+	return q, nil
+}
+
+// priceNights prices every night of a stay in roomID from start up to (but not
+// including) end, applying per-date rate overrides, and returns the nights and
+// their sum in cents. It checks no booking rules; quote bounds the stay length
+// before calling it.
+func (m *Repository) priceNights(roomID int, start, end time.Time) ([]quoteNight, int, error) {
+	nights := []quoteNight{}
+	total := 0
+	for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+		rate, err := m.DB.GetRateForDate(roomID, d)
+		if err != nil {
+			return nil, 0, err
+		}
+		nights = append(nights, quoteNight{Date: d.Format("01/02/2006"), Rate: rate})
+		total += rate
+	}
+	return nights, total, nil
+}
+
+// formatPrice renders an amount in cents as dollars, e.g. 25000 -> "$250.00".
+func formatPrice(cents int) string {
+	return fmt.Sprintf("$%d.%02d", cents/100, cents%100)
+}
+
+// sitemapPaths lists the public, crawlable pages that are not derived from rooms.
+var sitemapPaths = []string{"/", "/about", "/photos", "/rooms", "/search-availability", "/contact"}
+
+// siteURL returns the site's public address without a trailing slash, e.g.
+// "https://example.com", for building absolute URLs in crawler files and
+// emails. BaseURL is used when configured; otherwise the scheme and host the
+// request was made to.
+func (m *Repository) siteURL(r *http.Request) string {
+	if m.App.BaseURL != "" {
+		return strings.TrimRight(m.App.BaseURL, "/")
+	}
+	scheme := "http"
+	if m.App.InProduction || r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// errNoBaseURL is returned by emailURL when BASE_URL is not configured.
+var errNoBaseURL = errors.New("BASE_URL is not set; not sending an email with links")
+
+// emailURL returns path as an absolute URL under the configured BaseURL, for
+// links sent by email. It never falls back to the request's Host header: a
+// forged Host would otherwise point a password reset link, token and all, at
+// someone else's server. Without BaseURL it returns errNoBaseURL and the
+// caller must not send the email.
+func (m *Repository) emailURL(path string) (string, error) {
+	if m.App.BaseURL == "" {
+		return "", errNoBaseURL
+	}
+	return strings.TrimRight(m.App.BaseURL, "/") + "/" + strings.TrimLeft(path, "/"), nil
+}
+
+// healthTimeout bounds the database ping made by Healthz.
+const healthTimeout = 2 * time.Second
+
+// healthStatus is the JSON body returned by Healthz.
+type healthStatus struct {
+	Status string `json:"status"` // "ok" or "unavailable"
+}
+
+// Healthz handles GET /healthz for load balancers and uptime checks. It pings
+// the database and returns 200 {"status":"ok"} when it answers within
+// healthTimeout, or 503 {"status":"unavailable"} (with the error logged)
+// when it does not.
+func (m *Repository) Healthz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), healthTimeout)
+	defer cancel()
+
+	if err := m.DB.Ping(ctx); err != nil {
+		m.App.ErrorLog.Println(err)
+		writeJSON(w, http.StatusServiceUnavailable, healthStatus{Status: "unavailable"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, healthStatus{Status: "ok"})
+}
+
+// RobotsTxt handles GET /robots.txt. It allows crawling of the public site,
+// disallows each prefix in App.RobotsDisallow, and points at the sitemap.
+func (m *Repository) RobotsTxt(w http.ResponseWriter, r *http.Request) {
+	var b strings.Builder
+	b.WriteString("User-agent: *\n")
+	if len(m.App.RobotsDisallow) == 0 {
+		b.WriteString("Disallow:\n")
+	}
+	for _, path := range m.App.RobotsDisallow {
+		fmt.Fprintf(&b, "Disallow: %s\n", path)
+	}
+	fmt.Fprintf(&b, "\nSitemap: %s/sitemap.xml\n", m.siteURL(r))
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write([]byte(b.String()))
+}
+
+// webManifest is the JSON body of /site.webmanifest, the Web App Manifest
+// browsers read for the installed-app name and icon.
+type webManifest struct {
+	Name            string            `json:"name"`
+	ShortName       string            `json:"short_name"`
+	StartURL        string            `json:"start_url"`
+	Display         string            `json:"display"`
+	BackgroundColor string            `json:"background_color"`
+	ThemeColor      string            `json:"theme_color"`
+	Icons           []webManifestIcon `json:"icons"`
+}
+
+// webManifestIcon is one entry in webManifest.Icons.
+type webManifestIcon struct {
+	Src   string `json:"src"`
+	Sizes string `json:"sizes"`
+}
+
+// WebManifest handles GET /site.webmanifest. The app name is the configured
+// property name and the icon is the favicon served at /favicon.ico. The
+// response is cacheable for App.StaticMaxAge, like other static assets.
+func (m *Repository) WebManifest(w http.ResponseWriter, r *http.Request) {
+	manifest := webManifest{
+		Name:            m.App.Property(),
+		ShortName:       m.App.Property(),
+		StartURL:        "/",
+		Display:         "browser",
+		BackgroundColor: "#ffffff",
+		ThemeColor:      "#ffffff",
+		Icons:           []webManifestIcon{{Src: "/favicon.ico", Sizes: "any"}},
+	}
+
+	body, err := marshalJSON(manifest)
+	if err != nil {
+		m.App.ErrorLog.Println(err)
+		writeAPIError(w, http.StatusInternalServerError, errCodeServer, "Could not build manifest")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/manifest+json")
+	if maxAge := m.App.StaticMaxAge; maxAge > 0 {
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+	}
+	_, _ = w.Write(body)
+}
+
+// sitemapURLSet is the <urlset> root element of a sitemap.xml document.
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+// sitemapURL is a single <url> entry in a sitemap.
+type sitemapURL struct {
+	Loc string `xml:"loc"`
+}
+
+// SitemapXML handles GET /sitemap.xml. It lists the static public pages plus
+// each RoomRoutes page whose room AllRooms still returns and is open, as
+// absolute URLs on the requested host. Rooms without a page are left out
+// rather than guessed at from their names, which would list URLs that 404,
+// and closed rooms are left out as they are on /rooms.
+// A database failure returns 500 so crawlers retry rather than cache a
+// sitemap missing every room.
+func (m *Repository) SitemapXML(w http.ResponseWriter, r *http.Request) {
+	rooms, err := m.DB.AllRooms()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	base := m.siteURL(r)
+	set := sitemapURLSet{XMLNS: "http://www.sitemaps.org/schemas/sitemap/0.9"}
+	for _, path := range sitemapPaths {
+		set.URLs = append(set.URLs, sitemapURL{Loc: base + path})
+	}
+	open := make(map[int]bool, len(rooms))
+	for _, room := range rooms {
+		open[room.ID] = room.Active
+	}
+	for _, page := range RoomRoutes {
+		if open[page.RoomID] {
+			set.URLs = append(set.URLs, sitemapURL{Loc: base + page.Path})
+		}
+	}
+
+	out, err := xml.MarshalIndent(set, "", "  ")
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
 
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	_, _ = w.Write([]byte(xml.Header))
+	_, _ = w.Write(out)
 }
@@ -8,19 +8,29 @@
 package handlers
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
+	"unicode/utf8"
 
 	"github.com/bensabler/milos-residence/internal/config"
 	"github.com/bensabler/milos-residence/internal/driver"
 	"github.com/bensabler/milos-residence/internal/models"
+	"github.com/bensabler/milos-residence/internal/render"
 	"github.com/bensabler/milos-residence/internal/repository/dbrepo"
 	"github.com/go-chi/chi/v5"
 )
@@ -90,11 +100,6 @@ func toForm(m map[string]string) url.Values {
 	return v
 }
 
-// ptrBool returns a pointer to a bool value for table-driven tests.
-// This is needed when test cases need to distinguish between false and nil
-// for optional boolean assertions.
-func ptrBool(b bool) *bool { return &b }
-
 // TestNewRepo verifies that NewRepo constructor creates a repository with proper configuration.
 // This test ensures the repository is correctly initialized with the provided application
 // configuration and database connection, and that all required fields are set.
@@ -146,6 +151,7 @@ func TestRoutes_Smoke(t *testing.T) {
 		{"about", "/about"},
 		{"photos", "/photos"},
 		{"search-availability", "/search-availability"},
+		{"waitlist", "/waitlist?start=01/01/2100&end=01/02/2100"},
 		{"golden-haybeam-loft", "/golden-haybeam-loft"},
 		{"window-perch-theater", "/window-perch-theater"},
 		{"laundry-basket-nook", "/laundry-basket-nook"},
@@ -222,13 +228,14 @@ func TestRepository_PostReservation_ParseFormAndRoomLookupErrors(t *testing.T) {
 
 	// Test room lookup failure after successful validation
 	req = newPOSTForm("/make-reservation", toForm(map[string]string{
-		"start_date": "01/01/2100",
-		"end_date":   "01/02/2100",
-		"first_name": "John",
-		"last_name":  "Smith",
-		"email":      "john@smith.com",
-		"phone":      "1234567891",
-		"room_id":    "100", // triggers GetRoomByID error in test repo
+		"start_date":   "01/01/2100",
+		"end_date":     "01/02/2100",
+		"first_name":   "John",
+		"last_name":    "Smith",
+		"email":        "john@smith.com",
+		"phone":        "1234567891",
+		"accept_terms": "1",
+		"room_id":      "100", // triggers GetRoomByID error in test repo
 	}))
 	rr = do(Repo.PostReservation, req)
 	mustStatus(t, rr, http.StatusSeeOther)
@@ -247,91 +254,98 @@ func TestRepository_PostReservation(t *testing.T) {
 		{
 			name: "success",
 			form: map[string]string{
-				"start_date": "01/01/2100",
-				"end_date":   "01/02/2100",
-				"first_name": "John",
-				"last_name":  "Smith",
-				"email":      "john@smith.com",
-				"phone":      "1234567891",
-				"room_id":    "1",
+				"start_date":   "01/01/2100",
+				"end_date":     "01/02/2100",
+				"first_name":   "John",
+				"last_name":    "Smith",
+				"email":        "john@smith.com",
+				"phone":        "1234567891",
+				"accept_terms": "1",
+				"room_id":      "1",
 			},
 			wantStatus: http.StatusSeeOther,
 		},
 		{
 			name: "invalid start date",
 			form: map[string]string{
-				"start_date": "invalid",
-				"end_date":   "01/02/2100",
-				"first_name": "John",
-				"last_name":  "Smith",
-				"email":      "john@smith.com",
-				"phone":      "1234567891",
-				"room_id":    "1",
+				"start_date":   "invalid",
+				"end_date":     "01/02/2100",
+				"first_name":   "John",
+				"last_name":    "Smith",
+				"email":        "john@smith.com",
+				"phone":        "1234567891",
+				"accept_terms": "1",
+				"room_id":      "1",
 			},
 			wantStatus: http.StatusSeeOther,
 		},
 		{
 			name: "validation failure (first name too short)",
 			form: map[string]string{
-				"start_date": "01/01/2100",
-				"end_date":   "01/02/2100",
-				"first_name": "J", // fails MinLength validation
-				"last_name":  "Smith",
-				"email":      "john@smith.com",
-				"phone":      "1234567891",
-				"room_id":    "1",
+				"start_date":   "01/01/2100",
+				"end_date":     "01/02/2100",
+				"first_name":   "J", // fails MinLength validation
+				"last_name":    "Smith",
+				"email":        "john@smith.com",
+				"phone":        "1234567891",
+				"accept_terms": "1",
+				"room_id":      "1",
 			},
 			wantStatus: http.StatusOK, // re-renders form with errors
 		},
 		{
 			name: "insert reservation database error",
 			form: map[string]string{
-				"start_date": "01/01/2100",
-				"end_date":   "01/02/2100",
-				"first_name": "John",
-				"last_name":  "Smith",
-				"email":      "john@smith.com",
-				"phone":      "1234567891",
-				"room_id":    "2", // triggers error in test repo
+				"start_date":   "01/01/2100",
+				"end_date":     "01/02/2100",
+				"first_name":   "John",
+				"last_name":    "Smith",
+				"email":        "john@smith.com",
+				"phone":        "1234567891",
+				"accept_terms": "1",
+				"room_id":      "2", // triggers error in test repo
 			},
 			wantStatus: http.StatusSeeOther,
 		},
 		{
 			name: "room restriction insert error",
 			form: map[string]string{
-				"start_date": "01/01/2100",
-				"end_date":   "01/02/2100",
-				"first_name": "John",
-				"last_name":  "Smith",
-				"email":      "john@smith.com",
-				"phone":      "1234567891",
-				"room_id":    "3", // triggers restriction error in test repo
+				"start_date":   "01/01/2100",
+				"end_date":     "01/02/2100",
+				"first_name":   "John",
+				"last_name":    "Smith",
+				"email":        "john@smith.com",
+				"phone":        "1234567891",
+				"accept_terms": "1",
+				"room_id":      "3", // triggers restriction error in test repo
 			},
 			wantStatus: http.StatusSeeOther,
 		},
 		{
 			name: "invalid end date",
 			form: map[string]string{
-				"start_date": "01/01/2100",
-				"end_date":   "not-a-date",
-				"first_name": "John",
-				"last_name":  "Smith",
-				"email":      "john@smith.com",
-				"phone":      "1234567891",
-				"room_id":    "1",
+				"start_date":   "01/01/2100",
+				"end_date":     "not-a-date",
+				"first_name":   "John",
+				"last_name":    "Smith",
+				"email":        "john@smith.com",
+				"phone":        "1234567891",
+				"accept_terms": "1",
+				"room_id":      "1",
 			},
 			wantStatus: http.StatusSeeOther,
 		},
 		{
 			name: "invalid room_id (non-numeric)",
 			form: map[string]string{
-				"start_date": "01/01/2100",
-				"end_date":   "01/02/2100",
-				"first_name": "John",
-				"last_name":  "Smith",
-				"email":      "john@smith.com",
-				"phone":      "1234567891",
-				"room_id":    "x", // invalid integer conversion
+				"start_date":   "01/01/2100",
+				"end_date":     "01/02/2100",
+				"first_name":   "John",
+				"last_name":    "Smith",
+				"email":        "john@smith.com",
+				"phone":        "1234567891",
+				"accept_terms": "1",
+				"room_id":      "x", // invalid integer conversion
 			},
 			wantStatus: http.StatusSeeOther,
 		},
@@ -429,7 +443,7 @@ func TestRepository_PostAvailability(t *testing.T) {
 		}))
 		rr := do(Repo.PostAvailability, req)
 		mustStatus(t, rr, http.StatusSeeOther)
-		mustRedirectContains(t, rr, "/search-availability")
+		mustRedirectContains(t, rr, "/waitlist?end=01%2F02%2F2100&start=01%2F01%2F2100")
 	})
 
 	t.Run("rooms found for dates", func(t *testing.T) {
@@ -442,6 +456,168 @@ func TestRepository_PostAvailability(t *testing.T) {
 	})
 }
 
+// TestRepository_PostAvailability_Holds verifies that an unexpired hold takes
+// its room out of the search results while an expired one does not.
+func TestRepository_PostAvailability_Holds(t *testing.T) {
+	dbrepo.ResetHolds()
+	defer dbrepo.ResetHolds()
+
+	start := time.Date(2101, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2101, time.January, 2, 0, 0, 0, 0, time.UTC)
+	search := func() *httptest.ResponseRecorder {
+		req := newPOSTForm("/search-availability", toForm(map[string]string{
+			"start": "01/01/2101",
+			"end":   "01/02/2101",
+		}))
+		return do(Repo.PostAvailability, req)
+	}
+
+	if _, err := Repo.DB.CreateHold(1, start, end, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	rr := search()
+	mustStatus(t, rr, http.StatusOK)
+	if !strings.Contains(rr.Body.String(), "Golden Haybeam Loft") {
+		t.Error("room with only an expired hold should be listed")
+	}
+
+	if _, err := Repo.DB.CreateHold(1, start, end, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	rr = search()
+	mustRedirectContains(t, rr, "/waitlist")
+}
+
+// TestRepository_PostAvailability_Pages verifies that a large result is split
+// into pages of AppConfig.RoomsPerPage: only the requested slice is rendered,
+// the page position and neighbour links are shown, out-of-range pages clamp,
+// and the listed rooms show their cover images, loaded lazily.
+func TestRepository_PostAvailability_Pages(t *testing.T) {
+	rooms := make([]models.Room, 25)
+	for i := range rooms {
+		rooms[i] = models.Room{ID: i + 1, RoomName: fmt.Sprintf("Suite %02d", i+1), Active: true}
+	}
+	dbrepo.ForceAvailableRooms = rooms
+	defer func() { dbrepo.ForceAvailableRooms = nil }()
+	dbrepo.ResetRoomImages()
+	defer dbrepo.ResetRoomImages()
+	if _, err := Repo.DB.InsertRoomImage(models.RoomImage{RoomID: 3, URL: "/static/images/rooms/suite-03.jpg"}); err != nil {
+		t.Fatal(err)
+	}
+
+	saved := app.RoomsPerPage
+	app.RoomsPerPage = 10
+	defer func() { app.RoomsPerPage = saved }()
+
+	search := func(page string) string {
+		t.Helper()
+		req := newPOSTForm("/search-availability", toForm(map[string]string{
+			"start": "01/01/2100",
+			"end":   "01/02/2100",
+			"page":  page,
+		}))
+		rr := do(Repo.PostAvailability, req)
+		mustStatus(t, rr, http.StatusOK)
+		return rr.Body.String()
+	}
+	listed := func(body string) []int {
+		var ids []int
+		for _, room := range rooms {
+			if strings.Contains(body, ">"+room.RoomName+"<") {
+				ids = append(ids, room.ID)
+			}
+		}
+		return ids
+	}
+
+	body := search("2")
+	if got, want := listed(body), []int{11, 12, 13, 14, 15, 16, 17, 18, 19, 20}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("page 2 rooms: got %v, want %v", got, want)
+	}
+	for _, want := range []string{
+		"Page 2 of 3 (25 rooms)",
+		`name="page" value="1"`,
+		`name="page" value="3"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("page 2 body missing %q", want)
+		}
+	}
+	if strings.Contains(body, "suite-03.jpg") {
+		t.Error("page 2 shows the cover of a room on page 1")
+	}
+
+	body = search("")
+	if got := listed(body); len(got) != 10 || got[0] != 1 {
+		t.Errorf("default page rooms: got %v", got)
+	}
+	if strings.Contains(body, `name="page" value="0"`) {
+		t.Error("first page links to a previous page")
+	}
+	if !strings.Contains(body, `<img src="/static/images/rooms/suite-03.jpg"`) || !strings.Contains(body, `loading="lazy"`) {
+		t.Error("first page: missing lazy cover image for room 3")
+	}
+
+	body = search("9")
+	if got, want := listed(body), []int{21, 22, 23, 24, 25}; !reflect.DeepEqual(got, want) {
+		t.Errorf("clamped page rooms: got %v, want %v", got, want)
+	}
+	if !strings.Contains(body, "Page 3 of 3") || strings.Contains(body, `name="page" value="4"`) {
+		t.Error("last page: wrong position or a next link")
+	}
+}
+
+// TestRepository_PostAvailability_Negotiation verifies that the search
+// answers Accept: application/json with the available rooms as JSON and
+// apiError envelopes, and keeps rendering the choose-room page otherwise.
+func TestRepository_PostAvailability_Negotiation(t *testing.T) {
+	search := func(accept, start string) *httptest.ResponseRecorder {
+		req := newPOSTForm("/search-availability", toForm(map[string]string{
+			"start": start,
+			"end":   "01/02/2101",
+		}))
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		return do(Repo.PostAvailability, req)
+	}
+
+	rr := search("application/json", "01/01/2101")
+	mustStatus(t, rr, http.StatusOK)
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("Content-Type: got %q", ct)
+	}
+	var got availabilityResults
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("body is not JSON: %v", err)
+	}
+	if !got.OK || got.StartDate != "01/01/2101" || got.EndDate != "01/02/2101" ||
+		len(got.Rooms) != 1 || got.Rooms[0].RoomName != "Golden Haybeam Loft" {
+		t.Errorf("results: got %+v", got)
+	}
+
+	rr = search("application/json", "01/01/2100")
+	mustStatus(t, rr, http.StatusOK)
+	if !strings.Contains(rr.Body.String(), `"rooms": []`) || !strings.Contains(rr.Body.String(), `"ok": false`) {
+		t.Errorf("no availability: got %s", rr.Body.String())
+	}
+
+	rr = search("application/json", "nope")
+	mustStatus(t, rr, http.StatusBadRequest)
+	var apiErr apiError
+	if err := json.Unmarshal(rr.Body.Bytes(), &apiErr); err != nil || apiErr.Code != errCodeInvalidInput {
+		t.Errorf("bad date: got %s", rr.Body.String())
+	}
+
+	for _, accept := range []string{"", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", "text/html, application/json;q=0.5"} {
+		rr = search(accept, "01/01/2101")
+		mustStatus(t, rr, http.StatusOK)
+		if !strings.Contains(rr.Body.String(), "Choose a Room") {
+			t.Errorf("Accept %q: expected the choose-room page", accept)
+		}
+	}
+}
+
 // TestRepository_PostAvailability_ParseFormError tests malformed request body handling.
 // This covers the case where the request body cannot be parsed as form data,
 // which should result in a graceful error response.
@@ -455,20 +631,24 @@ func TestRepository_PostAvailability_ParseFormError(t *testing.T) {
 
 // TestRepository_AvailabilityJSON tests the AJAX availability checking endpoint.
 // This endpoint returns JSON responses for real-time availability checking
-// on individual room pages. Tests cover form parsing errors, database errors,
-// and both available and unavailable scenarios.
+// on individual room pages. Tests cover both available and unavailable
+// scenarios, and that every failure returns the apiError envelope with a
+// matching HTTP status.
 func TestRepository_AvailabilityJSON(t *testing.T) {
 	tests := []struct {
-		name       string
-		body       string
-		wantCode   int
-		wantOK     *bool
-		wantMsgSub string
+		name     string
+		body     string
+		wantCode int
+		wantOK   bool
+		wantErr  string // expected apiError code; empty for success
 	}{
-		{"parse form error", "%not-urlencoded", http.StatusOK, ptrBool(false), "Internal server error"},
-		{"database error (room 2)", "start=01/01/2102&end=01/02/2102&room_id=2", http.StatusOK, ptrBool(false), "Error querying database"},
-		{"room not available", "start=01/01/2100&end=01/02/2100&room_id=1", http.StatusOK, ptrBool(false), ""},
-		{"room available", "start=01/01/2101&end=01/02/2101&room_id=1", http.StatusOK, ptrBool(true), ""},
+		{"parse form error", "%not-urlencoded", http.StatusBadRequest, false, errCodeInvalidInput},
+		{"invalid start date", "start=nope&end=01/02/2101&room_id=1", http.StatusBadRequest, false, errCodeInvalidInput},
+		{"invalid end date", "start=01/01/2101&end=&room_id=1", http.StatusBadRequest, false, errCodeInvalidInput},
+		{"invalid room", "start=01/01/2101&end=01/02/2101&room_id=x", http.StatusBadRequest, false, errCodeInvalidInput},
+		{"database error (room 2)", "start=01/01/2102&end=01/02/2102&room_id=2", http.StatusInternalServerError, false, errCodeServer},
+		{"room not available", "start=01/01/2100&end=01/02/2100&room_id=1", http.StatusOK, false, ""},
+		{"room available", "start=01/01/2101&end=01/02/2101&room_id=1", http.StatusOK, true, ""},
 	}
 
 	for _, tc := range tests {
@@ -478,22 +658,82 @@ func TestRepository_AvailabilityJSON(t *testing.T) {
 			req = sessionize(req)
 
 			rr := do(Repo.AvailabilityJSON, req)
+			if tc.wantErr != "" {
+				mustAPIError(t, rr, tc.wantCode, tc.wantErr)
+				return
+			}
 			mustStatus(t, rr, tc.wantCode)
 
 			var resp jsonResponse
 			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
 				t.Fatalf("json unmarshal: %v", err)
 			}
-			if tc.wantOK != nil && resp.OK != *tc.wantOK {
-				t.Fatalf("OK: got %v, want %v", resp.OK, *tc.wantOK)
-			}
-			if tc.wantMsgSub != "" && !strings.Contains(resp.Message, tc.wantMsgSub) {
-				t.Fatalf("Message: got %q, want contains %q", resp.Message, tc.wantMsgSub)
+			if resp.OK != tc.wantOK {
+				t.Fatalf("OK: got %v, want %v", resp.OK, tc.wantOK)
 			}
 		})
 	}
 }
 
+// TestRepository_AvailabilityJSON_ZeroNights verifies that equal start and end
+// dates are refused with a specific message before the repository is asked.
+func TestRepository_AvailabilityJSON_ZeroNights(t *testing.T) {
+	dbrepo.ResetAvailabilityCalls()
+	defer dbrepo.ResetAvailabilityCalls()
+
+	req := httptest.NewRequest(http.MethodPost, "/search-availability-json",
+		strings.NewReader("start=01/01/2101&end=01/01/2101&room_id=1"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = sessionize(req)
+
+	rr := do(Repo.AvailabilityJSON, req)
+	mustStatus(t, rr, http.StatusOK)
+
+	var resp jsonResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("json unmarshal: %v", err)
+	}
+	if resp.OK {
+		t.Fatal("OK: got true, want false")
+	}
+	if resp.Message != "select at least one night" {
+		t.Fatalf("Message: got %q, want %q", resp.Message, "select at least one night")
+	}
+	if dbrepo.AvailabilityCalls != 0 {
+		t.Fatalf("SearchAvailabilityByDatesByRoomID called %d times, want 0", dbrepo.AvailabilityCalls)
+	}
+}
+
+// TestRepository_AvailabilityJSON_TooLarge verifies that an oversized body is
+// reported as 413 request_too_large rather than a generic failure.
+func TestRepository_AvailabilityJSON_TooLarge(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/search-availability-json",
+		strings.NewReader("room_id="+strings.Repeat("1", 64)))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	req.Body = http.MaxBytesReader(rr, req.Body, 16)
+
+	Repo.AvailabilityJSON(rr, req)
+	mustAPIError(t, rr, http.StatusRequestEntityTooLarge, errCodeTooLarge)
+}
+
+// mustAPIError asserts that rr carries the given status and an apiError body
+// with the expected code and a non-empty message.
+func mustAPIError(t *testing.T, rr *httptest.ResponseRecorder, status int, code string) {
+	t.Helper()
+	mustStatus(t, rr, status)
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("Content-Type: got %q, want application/json", ct)
+	}
+	var resp apiError
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("json unmarshal: %v", err)
+	}
+	if resp.Code != code || resp.Message == "" {
+		t.Fatalf("error body: got %+v, want code %q with a message", resp, code)
+	}
+}
+
 // TestRepository_ChooseRoom verifies room selection from availability results.
 // This handler processes room selection after availability search, updating
 // the session with the chosen room and redirecting to the reservation form.
@@ -529,23 +769,46 @@ func TestRepository_ChooseRoom(t *testing.T) {
 // TestRepository_BookRoom tests direct room booking from external links.
 // This handler processes booking requests with room ID and dates in query parameters,
 // typically used for direct booking links from room detail pages.
-// Tests cover parameter parsing and room lookup validation.
+// Tests cover parameter parsing and room lookup validation, and check that a
+// hold is only taken for a link with a usable stay.
 func TestRepository_BookRoom(t *testing.T) {
+	dbrepo.ResetHolds()
+	defer dbrepo.ResetHolds()
+
+	repo, _ := newMailCaptureRepo()
+	repo.App.HoldTTL = 15 * time.Minute
+
 	tests := []struct {
-		name       string
-		q          string
-		wantStatus int
+		name      string
+		q         string
+		wantLoc   string
+		wantError string
+		wantHold  bool
 	}{
-		{"valid booking request", "?id=1&s=01/01/2100&e=01/02/2100", http.StatusSeeOther},
-		{"missing date parameters", "?id=1", http.StatusSeeOther},
-		{"invalid room id", "?id=100&s=01/01/2100&e=01/02/2100", http.StatusSeeOther},
+		{"valid booking request", "?id=1&s=01/01/2100&e=01/02/2100", "/make-reservation", "", true},
+		{"missing date parameters", "?id=1", "/", "can't parse start date!", false},
+		{"malformed end date", "?id=1&s=01/01/2100&e=2100-01-02", "/", "can't parse end date!", false},
+		{"end before start", "?id=1&s=01/02/2100&e=01/01/2100", "/", "End date must be after start date!", false},
+		{"same start and end", "?id=1&s=01/01/2100&e=01/01/2100", "/", "End date must be after start date!", false},
+		{"invalid room id", "?id=100&s=01/01/2100&e=01/02/2100", "/", "Can't get room from db!", false},
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
+			dbrepo.ResetHolds()
+
 			req := newGET("/book-room" + tc.q)
-			rr := do(Repo.BookRoom, req)
-			mustStatus(t, rr, tc.wantStatus)
+			rr := do(repo.BookRoom, req)
+			mustStatus(t, rr, http.StatusSeeOther)
+			if got := rr.Header().Get("Location"); got != tc.wantLoc {
+				t.Errorf("redirect: got %q, want %q", got, tc.wantLoc)
+			}
+			if got := session.GetString(req.Context(), "error"); got != tc.wantError {
+				t.Errorf("error: got %q, want %q", got, tc.wantError)
+			}
+			if got := len(dbrepo.Holds()) == 1; got != tc.wantHold {
+				t.Errorf("hold taken: got %v, want %v (%v)", got, tc.wantHold, dbrepo.Holds())
+			}
 		})
 	}
 }
@@ -589,6 +852,29 @@ func TestRepository_LoginRouteIntegration(t *testing.T) {
 	}
 }
 
+// TestRepository_PasswordResetPages_Router verifies that the forgot-password
+// and reset-password forms are reachable without logging in, and that the
+// reset form posts back to its own token.
+func TestRepository_PasswordResetPages_Router(t *testing.T) {
+	ts := httptest.NewTLSServer(getRoutes())
+	defer ts.Close()
+
+	for _, p := range []string{"/user/forgot-password", "/user/reset-password/some-token"} {
+		resp, err := ts.Client().Get(ts.URL + p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("GET %s: status %d want %d", p, resp.StatusCode, http.StatusOK)
+		}
+		if !strings.Contains(string(body), `action="`+p+`"`) {
+			t.Errorf("GET %s: form does not post back to the page", p)
+		}
+	}
+}
+
 // TestRepository_PostShowLogin covers login form validation and successful authentication.
 // Tests include missing required fields, invalid email format, and successful login
 // scenarios. Successful authentication should redirect to the home page.
@@ -626,17 +912,14 @@ func TestRepository_Logout(t *testing.T) {
 }
 
 // TestRepository_StaticRoomPages tests that static informational pages render correctly.
-// These pages include room detail pages and general information pages that don't
-// require complex data processing or user input.
+// These are general information pages that don't require complex data processing
+// or user input; room pages are covered by TestRepository_RoomPage.
 func TestRepository_StaticRoomPages(t *testing.T) {
 	pages := []struct {
 		name string
 		h    http.HandlerFunc
 		u    string
 	}{
-		{"golden haybeam loft", Repo.GoldenHaybeamLoft, "/golden-haybeam-loft"},
-		{"window perch theater", Repo.WindowPerchTheater, "/window-perch-theater"},
-		{"laundry basket nook", Repo.LaundryBasketNook, "/laundry-basket-nook"},
 		{"about", Repo.About, "/about"},
 		{"photos", Repo.Photos, "/photos"},
 		{"contact", Repo.Contact, "/contact"},
@@ -659,44 +942,124 @@ func TestRepository_AdminDashboard(t *testing.T) {
 	mustStatus(t, rr, http.StatusOK)
 }
 
-// TestRepository_AdminAllReservations verifies the complete reservations list displays correctly.
-// This administrative page shows all reservations in the system for management purposes.
-func TestRepository_AdminAllReservations(t *testing.T) {
-	req := newGET("/admin/reservations-all")
-	rr := do(Repo.AdminAllReservations, req)
-	mustStatus(t, rr, http.StatusOK)
-}
+// TestRepository_AdminReservations verifies that each status filter asks the
+// repository for the matching subset, renders only those reservations, links
+// them back to the same filter and marks the matching tab active.
+func TestRepository_AdminReservations(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus string
+		wantIDs    []int
+	}{
+		{name: "all", query: "?status=all", wantStatus: "all", wantIDs: []int{1, 2}},
+		{name: "new", query: "?status=new", wantStatus: "new", wantIDs: []int{2}},
+		{name: "processed", query: "?status=processed", wantStatus: "processed", wantIDs: []int{1}},
+		{name: "default", query: "", wantStatus: "all", wantIDs: []int{1, 2}},
+		{name: "unknown", query: "?status=deleted", wantStatus: "all", wantIDs: []int{1, 2}},
+	}
 
-// TestRepository_AdminAllReservations_DBError tests database error handling in the reservations list.
-// When the database query fails, the page should return a 500 error rather than crashing.
-func TestRepository_AdminAllReservations_DBError(t *testing.T) {
-	dbrepo.ForceAllReservationsErr = true
-	defer func() { dbrepo.ForceAllReservationsErr = false }()
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := do(Repo.AdminReservations, newGET("/admin/reservations"+tc.query))
+			mustStatus(t, rr, http.StatusOK)
 
-	req := newGET("/admin/reservations-all")
-	rr := do(Repo.AdminAllReservations, req)
-	mustStatus(t, rr, http.StatusInternalServerError)
+			if got := dbrepo.LastReservationFilter.Status; got != tc.wantStatus {
+				t.Errorf("requested status: got %q, want %q", got, tc.wantStatus)
+			}
+
+			body := rr.Body.String()
+			for _, id := range []int{1, 2} {
+				link := fmt.Sprintf(`href="/admin/reservations/%s/%d/show"`, tc.wantStatus, id)
+				want := false
+				for _, w := range tc.wantIDs {
+					want = want || w == id
+				}
+				if strings.Contains(body, link) != want {
+					t.Errorf("reservation %d listed = %v, want %v", id, !want, want)
+				}
+			}
+			active := fmt.Sprintf(`class="nav-link active" href="/admin/reservations?status=%s&sort=`, tc.wantStatus)
+			if !strings.Contains(body, active) {
+				t.Errorf("expected %s tab active", tc.wantStatus)
+			}
+		})
+	}
 }
 
-// TestRepository_AdminNewReservations verifies the unprocessed reservations list displays correctly.
-// This page shows reservations that require staff review and processing.
-func TestRepository_AdminNewReservations(t *testing.T) {
-	req := newGET("/admin/reservations-new")
-	rr := do(Repo.AdminNewReservations, req)
-	mustStatus(t, rr, http.StatusOK)
+// TestRepository_AdminReservations_Sort verifies that an allowed sort is
+// reflected in the selector and that an unknown sort falls back to the default.
+func TestRepository_AdminReservations_Sort(t *testing.T) {
+	tests := []struct {
+		name, query, wantSelected string
+	}{
+		{"allowed sort", "?sort=last_name_desc", "last_name_desc"},
+		{"unknown sort", "?sort=id;drop%20table%20reservations", "start_date_asc"},
+		{"no sort", "", "start_date_asc"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := do(Repo.AdminReservations, newGET("/admin/reservations"+tc.query))
+			mustStatus(t, rr, http.StatusOK)
+			if dbrepo.LastReservationFilter.Sort != tc.wantSelected {
+				t.Errorf("requested sort: got %q, want %q", dbrepo.LastReservationFilter.Sort, tc.wantSelected)
+			}
+			want := `<option value="` + tc.wantSelected + `" selected>`
+			if !strings.Contains(rr.Body.String(), want) {
+				t.Fatalf("expected %s selected", tc.wantSelected)
+			}
+			if strings.Count(rr.Body.String(), " selected>") != 1 {
+				t.Fatal("expected exactly one selected sort")
+			}
+		})
+	}
+}
+
+// TestRepository_AdminReservations_Stream verifies that ?stream=1 renders the
+// same page as the default buffered path.
+func TestRepository_AdminReservations_Stream(t *testing.T) {
+	buffered := do(Repo.AdminReservations, newGET("/admin/reservations?status=all"))
+	mustStatus(t, buffered, http.StatusOK)
+
+	streamed := do(Repo.AdminReservations, newGET("/admin/reservations?status=all&stream=1"))
+	mustStatus(t, streamed, http.StatusOK)
+
+	if streamed.Body.String() != buffered.Body.String() {
+		t.Fatal("streamed page differs from the buffered page")
+	}
+	if !strings.Contains(streamed.Body.String(), `href="/admin/reservations/all/2/show"`) {
+		t.Error("streamed page is missing reservation rows")
+	}
 }
 
-// TestRepository_AdminNewReservations_DBError tests database error handling in the new reservations list.
+// TestRepository_AdminReservations_DBError tests database error handling in the reservations list.
 // When the database query fails, the page should return a 500 error rather than crashing.
-func TestRepository_AdminNewReservations_DBError(t *testing.T) {
-	dbrepo.ForceAllNewReservationsErr = true
-	defer func() { dbrepo.ForceAllNewReservationsErr = false }()
+func TestRepository_AdminReservations_DBError(t *testing.T) {
+	dbrepo.ForceSearchReservationsErr = true
+	defer func() { dbrepo.ForceSearchReservationsErr = false }()
 
-	req := newGET("/admin/reservations-new")
-	rr := do(Repo.AdminNewReservations, req)
+	req := newGET("/admin/reservations?status=new")
+	rr := do(Repo.AdminReservations, req)
 	mustStatus(t, rr, http.StatusInternalServerError)
 }
 
+// TestRepository_AdminLegacyReservationLists verifies that the old
+// /admin/reservations-all and -new pages redirect permanently to the
+// matching filter, keeping the sort.
+func TestRepository_AdminLegacyReservationLists(t *testing.T) {
+	rr := do(Repo.AdminAllReservations, newGET("/admin/reservations-all?sort=last_name_asc"))
+	mustStatus(t, rr, http.StatusMovedPermanently)
+	if loc := rr.Header().Get("Location"); loc != "/admin/reservations?sort=last_name_asc&status=all" {
+		t.Errorf("all: Location %q", loc)
+	}
+
+	rr = do(Repo.AdminNewReservations, newGET("/admin/reservations-new"))
+	mustStatus(t, rr, http.StatusMovedPermanently)
+	if loc := rr.Header().Get("Location"); loc != "/admin/reservations?status=new" {
+		t.Errorf("new: Location %q", loc)
+	}
+}
+
 // TestRepository_AdminShowReservation verifies individual reservation detail page rendering.
 // This page allows administrators to view and edit detailed reservation information.
 // Tests cover valid reservations, invalid URLs, and reservations that don't exist.
@@ -709,7 +1072,7 @@ func TestRepository_AdminShowReservation(t *testing.T) {
 	}{
 		{"valid reservation", "/admin/reservations/new/1/show", "?y=2025&m=12", http.StatusOK},
 		{"invalid reservation id", "/admin/reservations/new/invalid/show", "", http.StatusInternalServerError},
-		{"reservation not found", "/admin/reservations/new/999/show", "", http.StatusOK},
+		{"reservation not found", "/admin/reservations/new/999/show", "", http.StatusNotFound},
 	}
 
 	for _, tc := range tests {
@@ -736,6 +1099,47 @@ func TestRepository_AdminShowReservation_DBError(t *testing.T) {
 	mustStatus(t, rr, http.StatusInternalServerError)
 }
 
+// TestRepository_AdminPrintReservation verifies the printable confirmation:
+// it renders the reservation details standalone, without the admin layout's
+// navigation, and maps bad or unknown IDs to 400 and 404.
+func TestRepository_AdminPrintReservation(t *testing.T) {
+	tests := []struct {
+		name       string
+		id         string
+		wantStatus int
+	}{
+		{"printable page", "1", http.StatusOK},
+		{"invalid id", "abc", http.StatusBadRequest},
+		{"not found", "999", http.StatusNotFound},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := newGET("/admin/reservations/all/" + tc.id + "/print")
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("src", "all")
+			rctx.URLParams.Add("id", tc.id)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			rr := do(Repo.AdminPrintReservation, req)
+			mustStatus(t, rr, tc.wantStatus)
+			if tc.wantStatus != http.StatusOK {
+				return
+			}
+
+			body := rr.Body.String()
+			for _, want := range []string{"Reservation confirmation #1", "01/01/2050", "01/02/2050", "window.print()"} {
+				if !strings.Contains(body, want) {
+					t.Errorf("body missing %q", want)
+				}
+			}
+			if strings.Contains(body, "<nav") || strings.Contains(body, "navbar") {
+				t.Error("printable page should not include the admin layout navigation")
+			}
+		})
+	}
+}
+
 // TestRepository_AdminPostShowReservation verifies reservation update form processing.
 // This handler processes updates to reservation details from the administrative interface.
 // Tests cover successful updates, invalid data, and different redirect destinations
@@ -780,10 +1184,10 @@ func TestRepository_AdminPostShowReservation(t *testing.T) {
 			wantStatus: http.StatusInternalServerError,
 		},
 		{
-			name:       "reservation not found still redirects",
+			name:       "reservation not found",
 			reqURI:     "/admin/reservations/new/999/show",
 			form:       map[string]string{"first_name": "Test"},
-			wantStatus: http.StatusSeeOther,
+			wantStatus: http.StatusNotFound,
 		},
 	}
 
@@ -815,72 +1219,293 @@ func TestRepository_AdminPostShowReservation_UpdateError(t *testing.T) {
 	mustStatus(t, rr, http.StatusInternalServerError)
 }
 
-// TestRepository_AdminPostShowReservation_ParseFormError tests malformed form handling.
-// When the request body cannot be parsed, the handler should return a 500 error.
-func TestRepository_AdminPostShowReservation_ParseFormError(t *testing.T) {
-	reqURI := "/admin/reservations/new/1/show"
-	req := httptest.NewRequest(http.MethodPost, reqURI, strings.NewReader("%not-urlencoded"))
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req = sessionize(req)
-	req.RequestURI = reqURI
-	rr := do(Repo.AdminPostShowReservation, req)
-	mustStatus(t, rr, http.StatusInternalServerError)
-}
-
-// TestRepository_AdminReservationsCalendar_SessionSeeds ensures session data is properly stored.
-// The calendar handler stores room block data in the session for later form processing.
-// This test verifies that the session contains the expected data structure.
-func TestRepository_AdminReservationsCalendar_SessionSeeds(t *testing.T) {
-	req := newGET("/admin/reservations-calendar?y=2050&m=1")
-	rr := do(Repo.AdminReservationsCalendar, req)
-	mustStatus(t, rr, http.StatusOK)
-
-	// Verify that block map data is stored in session for form processing
-	val := session.Get(req.Context(), "block_map_1")
-	m, ok := val.(map[string]int)
-	if !ok || len(m) == 0 {
-		t.Fatalf("expected non-empty block_map_1 in session; got %#v", val)
-	}
-}
-
-// TestRepository_AdminReservationsCalendar verifies calendar page rendering.
-// The calendar displays room availability with different views for current month
-// and specific months specified via query parameters.
-func TestRepository_AdminReservationsCalendar(t *testing.T) {
+// TestRepository_AdminPostShowReservation_Stale verifies optimistic concurrency:
+// saving with the updated_at the page was rendered with succeeds, while saving
+// an older version sends the admin back to the reservation with a warning.
+func TestRepository_AdminPostShowReservation_Stale(t *testing.T) {
 	tests := []struct {
-		name string
-		url  string
+		name      string
+		updatedAt time.Time
+		wantLoc   string
+		wantWarn  bool
 	}{
-		{"current month display", "/admin/reservations-calendar"},
-		{"specific month display", "/admin/reservations-calendar?y=2050&m=1"},
+		{name: "current version saves", updatedAt: dbrepo.TestReservationUpdatedAt, wantLoc: "/admin/reservations?status=all"},
+		{name: "stale version warns", updatedAt: dbrepo.TestReservationUpdatedAt.Add(-time.Minute), wantLoc: "/admin/reservations/all/1/show?y=2050&m=01", wantWarn: true},
 	}
+
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			req := newGET(tc.url)
-			rr := do(Repo.AdminReservationsCalendar, req)
-			mustStatus(t, rr, http.StatusOK)
+			reqURI := "/admin/reservations/all/1"
+			form := map[string]string{
+				"first_name": "John",
+				"last_name":  "Smith",
+				"email":      "john@smith.com",
+				"phone":      "1234567890",
+				"updated_at": tc.updatedAt.Format(time.RFC3339Nano),
+			}
+			if tc.wantWarn {
+				form["year"], form["month"] = "2050", "01"
+			}
+			req := newPOSTForm(reqURI, toForm(form))
+			req.RequestURI = reqURI
+			rr := do(Repo.AdminPostShowReservation, req)
+			mustStatus(t, rr, http.StatusSeeOther)
+			if loc := rr.Header().Get("Location"); loc != tc.wantLoc {
+				t.Fatalf("Location: got %q, want %q", loc, tc.wantLoc)
+			}
+
+			flashes, _ := session.Get(req.Context(), models.FlashSessionKey).([]models.FlashMessage)
+			warned := len(flashes) == 1 && flashes[0].Level == models.FlashWarning &&
+				strings.Contains(flashes[0].Text, "changed by someone else")
+			if warned != tc.wantWarn {
+				t.Fatalf("warning flash: got %v (%+v), want %v", warned, flashes, tc.wantWarn)
+			}
 		})
 	}
 }
 
-// TestRepository_AdminReservationsCalendar_AllRoomsError tests room data error handling.
-// When the room lookup fails, the calendar page should return a 500 error.
-func TestRepository_AdminReservationsCalendar_AllRoomsError(t *testing.T) {
-	dbrepo.ForceAllRoomsErr = true
-	defer func() { dbrepo.ForceAllRoomsErr = false }()
+// TestReservationChanges verifies that only guest-facing fields that differ
+// are listed, in form order, and that staff notes are ignored.
+func TestReservationChanges(t *testing.T) {
+	before := models.Reservation{
+		FirstName: "John",
+		LastName:  "Smith",
+		Email:     "john@smith.com",
+		Phone:     "555",
+		Notes:     "old note",
+		StartDate: time.Date(2050, time.January, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2050, time.January, 2, 0, 0, 0, 0, time.UTC),
+	}
+	after := before
+	after.Email = "john@example.com"
+	after.EndDate = time.Date(2050, time.January, 4, 0, 0, 0, 0, time.UTC)
+	after.Notes = "new note"
 
-	req := newGET("/admin/reservations-calendar")
-	rr := do(Repo.AdminReservationsCalendar, req)
-	mustStatus(t, rr, http.StatusInternalServerError)
+	want := []reservationChange{
+		{Field: "Email", From: "john@smith.com", To: "john@example.com"},
+		{Field: "Departure", From: "01/02/2050", To: "01/04/2050"},
+	}
+	if got := reservationChanges(before, after); !reflect.DeepEqual(got, want) {
+		t.Fatalf("changes: got %+v, want %+v", got, want)
+	}
+	if got := reservationChanges(before, before); len(got) != 0 {
+		t.Fatalf("unchanged reservation: got %+v", got)
+	}
 }
 
-// TestRepository_AdminReservationsCalendar_RestrictionsError tests restrictions data error handling.
+// TestRepository_AdminPostShowReservation_NotifyGuest verifies that an edit
+// emails the guest a summary of the changes only when "notify guest" is
+// checked, and that the email goes to the newly entered address.
+func TestRepository_AdminPostShowReservation_NotifyGuest(t *testing.T) {
+	for _, notify := range []bool{true, false} {
+		t.Run("notify="+strconv.FormatBool(notify), func(t *testing.T) {
+			repo, mail := newMailCaptureRepo()
+
+			form := map[string]string{
+				"first_name": "John",
+				"last_name":  "Smith",
+				"email":      "john@example.com",
+				"phone":      "1234567890",
+				"updated_at": dbrepo.TestReservationUpdatedAt.Format(time.RFC3339Nano),
+			}
+			if notify {
+				form["notify_guest"] = "1"
+			}
+			reqURI := "/admin/reservations/all/1"
+			req := newPOSTForm(reqURI, toForm(form))
+			req.RequestURI = reqURI
+			rr := do(repo.AdminPostShowReservation, req)
+			mustStatus(t, rr, http.StatusSeeOther)
+
+			msgs := mail.sent()
+			if !notify {
+				if len(msgs) != 0 {
+					t.Fatalf("queued mails: got %d, want 0", len(msgs))
+				}
+				return
+			}
+			if len(msgs) != 1 {
+				t.Fatalf("queued mails: got %d, want 1", len(msgs))
+			}
+			if msgs[0].To != "john@example.com" || msgs[0].Subject != "Your reservation was updated" {
+				t.Errorf("update email: got To=%q Subject=%q", msgs[0].To, msgs[0].Subject)
+			}
+			if !strings.Contains(msgs[0].Content, "<strong>Email:</strong> (none) &rarr; john@example.com") {
+				t.Errorf("update email should list the email change: %s", msgs[0].Content)
+			}
+		})
+	}
+}
+
+// TestRepository_AdminPostShowReservation_Notes verifies that staff notes
+// saved through the edit form are shown when the reservation is reopened, and
+// that an over-long note re-renders the form with an error instead of saving.
+func TestRepository_AdminPostShowReservation_Notes(t *testing.T) {
+	dbrepo.ResetReservationNotes()
+	defer dbrepo.ResetReservationNotes()
+
+	save := func(notes string) *httptest.ResponseRecorder {
+		reqURI := "/admin/reservations/all/1"
+		req := newPOSTForm(reqURI, toForm(map[string]string{
+			"first_name": "John",
+			"last_name":  "Smith",
+			"email":      "john@smith.com",
+			"phone":      "1234567890",
+			"notes":      notes,
+			"updated_at": dbrepo.TestReservationUpdatedAt.Format(time.RFC3339Nano),
+		}))
+		req.RequestURI = reqURI
+		return do(Repo.AdminPostShowReservation, req)
+	}
+
+	mustStatus(t, save("  Late check-in after 10pm; allergic to feathers  "), http.StatusSeeOther)
+
+	res, err := Repo.DB.GetReservationByID(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Notes != "Late check-in after 10pm; allergic to feathers" {
+		t.Fatalf("saved notes: got %q", res.Notes)
+	}
+
+	reqURI := "/admin/reservations/all/1/show"
+	req := newGET(reqURI)
+	req.RequestURI = reqURI
+	rr := do(Repo.AdminShowReservation, req)
+	mustStatus(t, rr, http.StatusOK)
+	if !strings.Contains(rr.Body.String(), "Late check-in after 10pm; allergic to feathers</textarea>") {
+		t.Fatal("show page does not display the saved notes")
+	}
+
+	rr = save(strings.Repeat("x", maxReservationNotesLength+1))
+	mustStatus(t, rr, http.StatusOK)
+	if !strings.Contains(rr.Body.String(), "at most 2000 characters") {
+		t.Fatal("expected a notes length error on the re-rendered form")
+	}
+	if res, _ := Repo.DB.GetReservationByID(1); res.Notes != "Late check-in after 10pm; allergic to feathers" {
+		t.Fatalf("over-long note was saved: got %d characters", len(res.Notes))
+	}
+}
+
+// TestRepository_AdminShowReservation_UpdatedAtField verifies the edit form
+// carries the loaded updated_at for the concurrency check.
+func TestRepository_AdminShowReservation_UpdatedAtField(t *testing.T) {
+	reqURI := "/admin/reservations/all/1/show"
+	req := newGET(reqURI)
+	req.RequestURI = reqURI
+	rr := do(Repo.AdminShowReservation, req)
+	mustStatus(t, rr, http.StatusOK)
+
+	want := `name="updated_at" value="` + dbrepo.TestReservationUpdatedAt.Format(time.RFC3339Nano) + `"`
+	if !strings.Contains(rr.Body.String(), want) {
+		t.Fatalf("form missing %s", want)
+	}
+}
+
+// TestRepository_AdminPostShowReservation_ParseFormError tests malformed form handling.
+// When the request body cannot be parsed, the handler should return a 500 error.
+func TestRepository_AdminPostShowReservation_ParseFormError(t *testing.T) {
+	reqURI := "/admin/reservations/new/1/show"
+	req := httptest.NewRequest(http.MethodPost, reqURI, strings.NewReader("%not-urlencoded"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = sessionize(req)
+	req.RequestURI = reqURI
+	rr := do(Repo.AdminPostShowReservation, req)
+	mustStatus(t, rr, http.StatusInternalServerError)
+}
+
+// TestRepository_AdminReservationsCalendar_SessionSeeds ensures session data is properly stored.
+// The calendar handler stores room block data in the session for later form processing.
+// This test verifies that the session contains the expected data structure.
+func TestRepository_AdminReservationsCalendar_SessionSeeds(t *testing.T) {
+	req := newGET("/admin/reservations-calendar?y=" + calendarYear + "&m=1")
+	rr := do(Repo.AdminReservationsCalendar, req)
+	mustStatus(t, rr, http.StatusOK)
+
+	// Verify that block map data is stored in session for form processing
+	val := session.Get(req.Context(), "block_map_1")
+	m, ok := val.(map[string]int)
+	if !ok || len(m) == 0 {
+		t.Fatalf("expected non-empty block_map_1 in session; got %#v", val)
+	}
+}
+
+// calendarYear is a year inside the admin calendar's display window, used by
+// tests that navigate to a specific month.
+var calendarYear = strconv.Itoa(time.Now().Year() + 1)
+
+// TestRepository_AdminReservationsCalendar verifies calendar page rendering.
+// The calendar displays room availability with different views for current month
+// and specific months specified via query parameters.
+func TestRepository_AdminReservationsCalendar(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+	}{
+		{"current month display", "/admin/reservations-calendar"},
+		{"specific month display", "/admin/reservations-calendar?y=" + calendarYear + "&m=1"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := newGET(tc.url)
+			rr := do(Repo.AdminReservationsCalendar, req)
+			mustStatus(t, rr, http.StatusOK)
+		})
+	}
+}
+
+// TestRepository_AdminReservationsCalendar_InvalidMonth verifies that
+// nonsensical y/m parameters fall back to the current month with a warning
+// instead of building a date from the bad values.
+func TestRepository_AdminReservationsCalendar_InvalidMonth(t *testing.T) {
+	now := time.Now()
+	wantMonth := `name="m" value="` + now.Format("01") + `"`
+	wantYear := `name="y" value="` + now.Format("2006") + `"`
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"month 0", "?y=" + calendarYear + "&m=0"},
+		{"month 13", "?y=" + calendarYear + "&m=13"},
+		{"non-numeric year", "?y=abc&m=3"},
+		{"year outside window", "?y=" + strconv.Itoa(now.Year()+calendarYearWindow+1) + "&m=3"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := do(Repo.AdminReservationsCalendar, newGET("/admin/reservations-calendar"+tc.query))
+			mustStatus(t, rr, http.StatusOK)
+
+			body := rr.Body.String()
+			if !strings.Contains(body, wantMonth) || !strings.Contains(body, wantYear) {
+				t.Fatalf("expected fallback to current month %s/%s", now.Format("01"), now.Format("2006"))
+			}
+			if !strings.Contains(body, "showing the current month instead") {
+				t.Fatal("expected a warning flash about the invalid month")
+			}
+		})
+	}
+}
+
+// TestRepository_AdminReservationsCalendar_AllRoomsError tests room data error handling.
+// When the room lookup fails, the calendar page should return a 500 error.
+func TestRepository_AdminReservationsCalendar_AllRoomsError(t *testing.T) {
+	dbrepo.ForceAllRoomsErr = true
+	defer func() { dbrepo.ForceAllRoomsErr = false }()
+
+	req := newGET("/admin/reservations-calendar")
+	rr := do(Repo.AdminReservationsCalendar, req)
+	mustStatus(t, rr, http.StatusInternalServerError)
+}
+
+// TestRepository_AdminReservationsCalendar_RestrictionsError tests restrictions data error handling.
 // When the room restrictions lookup fails, the calendar page should return a 500 error.
 func TestRepository_AdminReservationsCalendar_RestrictionsError(t *testing.T) {
 	dbrepo.ForceRestrictionsErr = true
 	defer func() { dbrepo.ForceRestrictionsErr = false }()
 
-	req := newGET("/admin/reservations-calendar?y=2050&m=1")
+	req := newGET("/admin/reservations-calendar?y=" + calendarYear + "&m=1")
 	rr := do(Repo.AdminReservationsCalendar, req)
 	mustStatus(t, rr, http.StatusInternalServerError)
 }
@@ -895,8 +1520,9 @@ func TestRepository_AdminProcessReservation(t *testing.T) {
 		id, src    string
 		wantSubLoc string
 	}{
-		{"redirect to new reservations list", "/admin/process-reservation/new/1/do", "1", "new", "/admin/reservations-new"},
+		{"redirect to new reservations list", "/admin/process-reservation/new/1/do", "1", "new", "/admin/reservations?status=new"},
 		{"redirect to calendar view", "/admin/process-reservation/new/1/do?y=2050&m=01", "1", "new", "/admin/reservations-calendar?y=2050&m=01"},
+		{"unexpected src falls back to all", "/admin/process-reservation/evil.com/1/do", "1", "..%2F..%2Fevil.com", "/admin/reservations?status=all"},
 	}
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
@@ -914,6 +1540,45 @@ func TestRepository_AdminProcessReservation(t *testing.T) {
 	}
 }
 
+// TestRepository_AdminUnprocessReservation verifies that reopening a
+// reservation stores processed=0 for the route's ID and redirects like the
+// process handler.
+func TestRepository_AdminUnprocessReservation(t *testing.T) {
+	tests := []struct {
+		name       string
+		url        string
+		src        string
+		wantSubLoc string
+	}{
+		{"redirect to all reservations list", "/admin/unprocess-reservation/all/7/do", "all", "/admin/reservations?status=all"},
+		{"redirect to calendar view", "/admin/unprocess-reservation/cal/7/do?y=2050&m=01", "cal", "/admin/reservations-calendar?y=2050&m=01"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dbrepo.ResetProcessedCalls()
+			defer dbrepo.ResetProcessedCalls()
+
+			req := newGET(tc.url)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", "7")
+			rctx.URLParams.Add("src", tc.src)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			rr := do(Repo.AdminUnprocessReservation, req)
+			mustStatus(t, rr, http.StatusSeeOther)
+			mustRedirectContains(t, rr, tc.wantSubLoc)
+
+			want := []dbrepo.ProcessedCall{{ID: 7, Processed: 0}}
+			if !reflect.DeepEqual(dbrepo.ProcessedCalls, want) {
+				t.Fatalf("UpdateProcessedForReservation calls: got %+v, want %+v", dbrepo.ProcessedCalls, want)
+			}
+			if flash := session.GetString(req.Context(), "flash"); flash != "Reservation reopened!" {
+				t.Errorf("flash: got %q", flash)
+			}
+		})
+	}
+}
+
 // TestRepository_AdminProcessReservation_UpdateError tests processing error handling.
 // When the database update fails, the handler should still redirect but log the error.
 func TestRepository_AdminProcessReservation_UpdateError(t *testing.T) {
@@ -941,8 +1606,10 @@ func TestRepository_AdminDeleteReservation(t *testing.T) {
 		id, src    string
 		wantSubLoc string
 	}{
-		{"redirect to new reservations list", "/admin/delete-reservation/new/1/do", "1", "new", "/admin/reservations-new"},
+		{"redirect to new reservations list", "/admin/delete-reservation/new/1/do", "1", "new", "/admin/reservations?status=new"},
 		{"redirect to calendar view", "/admin/delete-reservation/new/1/do?y=2050&m=01", "1", "new", "/admin/reservations-calendar?y=2050&m=01"},
+		{"unexpected src falls back to all", "/admin/delete-reservation/x/1/do", "1", "/evil.com", "/admin/reservations?status=all"},
+		{"cal src without month returns to calendar", "/admin/delete-reservation/cal/1/do", "1", "cal", "/admin/reservations-calendar"},
 	}
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
@@ -959,6 +1626,202 @@ func TestRepository_AdminDeleteReservation(t *testing.T) {
 	}
 }
 
+// TestRepository_AdminDeleteReservation_Audit verifies that a delete records an
+// audit entry against the logged-in user, that failed deletes record nothing,
+// and that an audit failure does not block the delete.
+func TestRepository_AdminDeleteReservation_Audit(t *testing.T) {
+	tests := []struct {
+		name      string
+		deleteErr bool
+		auditErr  bool
+		wantAudit bool
+	}{
+		{name: "delete is audited", wantAudit: true},
+		{name: "failed delete is not audited", deleteErr: true},
+		{name: "audit failure still deletes", auditErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dbrepo.ResetAudit()
+			dbrepo.ForceDeleteReservationErr = tc.deleteErr
+			defer func() {
+				dbrepo.ResetAudit()
+				dbrepo.ForceDeleteReservationErr = false
+				dbrepo.ForceAuditErr = false
+			}()
+
+			req := newGET("/admin/delete-reservation/all/1/do")
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", "1")
+			rctx.URLParams.Add("src", "all")
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			session.Put(req.Context(), "user_id", dbrepo.TestAdminUserID)
+
+			dbrepo.ForceAuditErr = tc.auditErr
+			rr := do(Repo.AdminDeleteReservation, req)
+			mustStatus(t, rr, http.StatusSeeOther)
+			dbrepo.ForceAuditErr = false
+
+			entries, err := Repo.DB.RecentAudit(10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tc.wantAudit {
+				if len(entries) != 0 {
+					t.Fatalf("expected no audit entries, got %+v", entries)
+				}
+				return
+			}
+			if len(entries) != 1 {
+				t.Fatalf("audit entries: got %d, want 1", len(entries))
+			}
+			e := entries[0]
+			if e.UserID != dbrepo.TestAdminUserID || e.Action != "reservation.delete" || !strings.Contains(e.Detail, "reservation 1") {
+				t.Fatalf("audit entry: got %+v", e)
+			}
+		})
+	}
+}
+
+// TestRepository_AdminAuditLog verifies the audit page lists recorded entries
+// and returns 500 when they cannot be loaded.
+func TestRepository_AdminAuditLog(t *testing.T) {
+	dbrepo.ResetAudit()
+	defer dbrepo.ResetAudit()
+
+	if err := Repo.DB.RecordAudit(dbrepo.TestAdminUserID, "block.add", "Added Owner Block on 02/10/2050 to room 1"); err != nil {
+		t.Fatal(err)
+	}
+
+	rr := do(Repo.AdminAuditLog, newGET("/admin/audit"))
+	mustStatus(t, rr, http.StatusOK)
+	if body := rr.Body.String(); !strings.Contains(body, "block.add") || !strings.Contains(body, "02/10/2050") {
+		t.Fatal("audit page missing recorded entry")
+	}
+
+	dbrepo.ForceAuditErr = true
+	defer func() { dbrepo.ForceAuditErr = false }()
+	rr = do(Repo.AdminAuditLog, newGET("/admin/audit"))
+	mustStatus(t, rr, http.StatusInternalServerError)
+}
+
+// TestRepository_AdminMailLog verifies that the mail log page lists logged
+// send attempts with their outcome, and that a failed lookup returns 500.
+func TestRepository_AdminMailLog(t *testing.T) {
+	dbrepo.ResetMailLog()
+	defer dbrepo.ResetMailLog()
+
+	sent := models.MailData{To: "ann@example.com", From: "milo@milos-residence.com", Subject: "Reservation Confirmation"}
+	failed := models.MailData{To: "bob@example.com", From: "milo@milos-residence.com", Subject: "Reservation Notification"}
+	if err := Repo.DB.LogSentMail(sent, models.MailStatusSent); err != nil {
+		t.Fatal(err)
+	}
+	if err := Repo.DB.LogSentMail(failed, models.MailStatusFailed); err != nil {
+		t.Fatal(err)
+	}
+
+	rr := do(Repo.AdminMailLog, newGET("/admin/mail-log"))
+	mustStatus(t, rr, http.StatusOK)
+	body := rr.Body.String()
+	for _, want := range []string{"ann@example.com", "Reservation Confirmation", "bob@example.com", ">failed<"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("mail log page missing %q", want)
+		}
+	}
+	if strings.Index(body, "bob@example.com") > strings.Index(body, "ann@example.com") {
+		t.Error("expected newest entry first")
+	}
+
+	dbrepo.ForceMailLogErr = true
+	defer func() { dbrepo.ForceMailLogErr = false }()
+	rr = do(Repo.AdminMailLog, newGET("/admin/mail-log"))
+	mustStatus(t, rr, http.StatusInternalServerError)
+}
+
+// TestRepository_AdminMailFailures verifies that the failed-mail page lists
+// dead-lettered messages with their error, newest first, and that a failed
+// lookup returns 500.
+func TestRepository_AdminMailFailures(t *testing.T) {
+	dbrepo.ResetFailedMail()
+	defer dbrepo.ResetFailedMail()
+
+	first := models.MailData{To: "ann@example.com", From: "milo@milos-residence.com", Subject: "Reservation Confirmation"}
+	second := models.MailData{To: "bob@example.com", From: "milo@milos-residence.com", Subject: "Reservation Notification"}
+	if err := Repo.DB.DeadLetterMail(first, "dial tcp: connection refused"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Repo.DB.DeadLetterMail(second, "421 try again later"); err != nil {
+		t.Fatal(err)
+	}
+
+	rr := do(Repo.AdminMailFailures, newGET("/admin/mail-failures"))
+	mustStatus(t, rr, http.StatusOK)
+	body := rr.Body.String()
+	for _, want := range []string{"ann@example.com", "connection refused", "bob@example.com", "/admin/mail-failures/1/retry", "/admin/mail-failures/2/retry"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("failed-mail page missing %q", want)
+		}
+	}
+	if strings.Index(body, "bob@example.com") > strings.Index(body, "ann@example.com") {
+		t.Error("expected newest message first")
+	}
+
+	dbrepo.ForceFailedMailErr = true
+	defer func() { dbrepo.ForceFailedMailErr = false }()
+	rr = do(Repo.AdminMailFailures, newGET("/admin/mail-failures"))
+	mustStatus(t, rr, http.StatusInternalServerError)
+}
+
+// TestRepository_AdminRetryMail verifies that retrying a failed message puts
+// it back on the mail channel unchanged and takes it out of the queue, that a
+// second retry of the same message redirects with an error, and that bad IDs
+// and database errors are reported.
+func TestRepository_AdminRetryMail(t *testing.T) {
+	dbrepo.ResetFailedMail()
+	defer dbrepo.ResetFailedMail()
+
+	repo, mail := newMailCaptureRepo()
+	msg := models.MailData{To: "ann@example.com", From: "milo@milos-residence.com", Subject: "Reservation Confirmation", Content: "<p>Hi</p>", Template: "basic.html"}
+	if err := repo.DB.DeadLetterMail(msg, "connection refused"); err != nil {
+		t.Fatal(err)
+	}
+
+	retry := func(id string) *httptest.ResponseRecorder {
+		req := newPOSTForm("/admin/mail-failures/"+id+"/retry", url.Values{})
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", id)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		return do(repo.AdminRetryMail, req)
+	}
+
+	rr := retry("1")
+	mustStatus(t, rr, http.StatusSeeOther)
+	mustRedirectContains(t, rr, "/admin/mail-failures")
+	sent := mail.sent()
+	if len(sent) != 1 || sent[0] != msg {
+		t.Fatalf("queued: got %+v, want [%+v]", sent, msg)
+	}
+	if left, _ := repo.DB.FailedMail(); len(left) != 0 {
+		t.Errorf("queue after retry: got %d messages, want 0", len(left))
+	}
+
+	rr = retry("1")
+	mustStatus(t, rr, http.StatusSeeOther)
+	mustRedirectContains(t, rr, "/admin/mail-failures")
+	if sent := mail.sent(); len(sent) != 0 {
+		t.Errorf("second retry queued %d messages, want 0", len(sent))
+	}
+
+	rr = retry("abc")
+	mustStatus(t, rr, http.StatusBadRequest)
+
+	dbrepo.ForceFailedMailErr = true
+	defer func() { dbrepo.ForceFailedMailErr = false }()
+	rr = retry("1")
+	mustStatus(t, rr, http.StatusInternalServerError)
+}
+
 // TestRepository_AdminPostReservationsCalendar tests calendar block management form processing.
 // This handler processes calendar form submissions to add or remove room blocks.
 // Tests cover basic saves, adding blocks, and removing blocks.
@@ -1012,8 +1875,9 @@ func TestRepository_AdminPages_Router(t *testing.T) {
 
 	paths := []string{
 		"/admin/dashboard",
-		"/admin/reservations-all",
-		"/admin/reservations-new",
+		"/admin/reservations?status=all",
+		"/admin/reservations?status=new",
+		"/admin/reservations?status=processed",
 	}
 
 	for _, p := range paths {
@@ -1039,6 +1903,36 @@ func TestRepository_AdminPostReservationsCalendar_ParseFormError(t *testing.T) {
 	mustStatus(t, rr, http.StatusInternalServerError)
 }
 
+// TestRepository_AdminPostReservationsCalendar_MissingBlockMap verifies that
+// saving the calendar for a room with no block map in the session (expired
+// session, or a room added after the page loaded) skips that room instead of
+// panicking, and still applies new blocks.
+func TestRepository_AdminPostReservationsCalendar_MissingBlockMap(t *testing.T) {
+	dbrepo.ResetBlocks()
+	defer dbrepo.ResetBlocks()
+
+	form := url.Values{"y": {"2050"}, "m": {"1"}, "add_block_1_01/05/2050": {"1"}}
+	req := newPOSTForm("/admin/reservations-calendar", form)
+	session.Remove(req.Context(), "block_map_1")
+
+	rr := do(Repo.AdminPostReservationsCalendar, req)
+	mustStatus(t, rr, http.StatusSeeOther)
+	mustRedirectContains(t, rr, "/admin/reservations-calendar?y=2050&m=1")
+}
+
+// TestRepository_AdminReservationsCalendar_NoRooms verifies that the calendar
+// explains that no rooms are configured rather than rendering an empty grid.
+func TestRepository_AdminReservationsCalendar_NoRooms(t *testing.T) {
+	dbrepo.ForceNoRooms = true
+	defer func() { dbrepo.ForceNoRooms = false }()
+
+	rr := do(Repo.AdminReservationsCalendar, newGET("/admin/reservations-calendar?y="+calendarYear+"&m=1"))
+	mustStatus(t, rr, http.StatusOK)
+	if !strings.Contains(rr.Body.String(), "No rooms are configured yet") {
+		t.Fatal("expected a no-rooms message")
+	}
+}
+
 // TestRepository_AdminPostReservationsCalendar_AllRoomsError tests room data error in calendar updates.
 // When room lookup fails during calendar processing, the handler should return a 500 error.
 func TestRepository_AdminPostReservationsCalendar_AllRoomsError(t *testing.T) {
@@ -1061,28 +1955,108 @@ func TestRepository_AdminPostReservationsCalendar_DeleteBlockPath(t *testing.T)
 	mustStatus(t, rr, http.StatusSeeOther)
 }
 
-// TestRepository_AdminReservationsCalendar_WithReservationRestrictions tests reservation display in calendar.
-// This test forces the test repo to include reservation restrictions, ensuring the calendar
-// properly handles and displays both reservation blocks and owner blocks.
-func TestRepository_AdminReservationsCalendar_WithReservationRestrictions(t *testing.T) {
-	dbrepo.ForceHasReservationRestriction = true
-	defer func() { dbrepo.ForceHasReservationRestriction = false }()
+// TestRepository_AdminPostReservationsCalendar_BlockReason verifies that new
+// blocks are saved with the selected reason and trimmed note, that unknown
+// reasons fall back to Owner Block, and that the calendar shows both on hover.
+func TestRepository_AdminPostReservationsCalendar_BlockReason(t *testing.T) {
+	tests := []struct {
+		name      string
+		reason    string
+		wantTitle string
+	}{
+		{name: "maintenance", reason: "3", wantTitle: `title="Maintenance: Boiler service"`},
+		{name: "reservation reason rejected", reason: "1", wantTitle: `title="Owner Block: Boiler service"`},
+	}
 
-	req := newGET("/admin/reservations-calendar?y=2050&m=1")
-	rr := do(Repo.AdminReservationsCalendar, req)
-	mustStatus(t, rr, http.StatusOK)
-}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dbrepo.ResetBlocks()
+			defer dbrepo.ResetBlocks()
 
-// TestRepository_AdminPostShowReservation_GetReservationErr tests reservation lookup error during updates.
-// When the reservation cannot be retrieved for updating, the handler should return a 500 error.
-func TestRepository_AdminPostShowReservation_GetReservationErr(t *testing.T) {
-	dbrepo.ForceGetReservationErr = true
-	defer func() { dbrepo.ForceGetReservationErr = false }()
+			form := url.Values{
+				"y": {calendarYear}, "m": {"2"},
+				"add_block_1_02/10/" + calendarYear: {"1"},
+				"block_reason":                      {tc.reason},
+				"block_note":                        {"  Boiler service "},
+			}
+			req := newPOSTForm("/admin/reservations-calendar", form)
+			session.Put(req.Context(), "block_map_1", map[string]int{})
+			rr := do(Repo.AdminPostReservationsCalendar, req)
+			mustStatus(t, rr, http.StatusSeeOther)
 
-	reqURI := "/admin/reservations/new/1/show"
-	req := newPOSTForm(reqURI, toForm(map[string]string{
-		"first_name": "X",
-		"last_name":  "Y",
+			rr = do(Repo.AdminReservationsCalendar, newGET("/admin/reservations-calendar?y="+calendarYear+"&m=2"))
+			mustStatus(t, rr, http.StatusOK)
+			if !strings.Contains(rr.Body.String(), tc.wantTitle) {
+				t.Fatalf("calendar missing %s", tc.wantTitle)
+			}
+		})
+	}
+}
+
+// TestRepository_AdminPostReservationsCalendar_PastBlock verifies that an
+// add_block checkbox dated before today is skipped with a warning while one
+// from today onward is inserted. The clock is fixed so the dates don't drift.
+func TestRepository_AdminPostReservationsCalendar_PastBlock(t *testing.T) {
+	dbrepo.ResetBlocks()
+	defer dbrepo.ResetBlocks()
+
+	repo := NewTestRepo(&app)
+	repo.now = func() time.Time { return time.Date(2050, time.March, 15, 9, 30, 0, 0, time.Local) }
+
+	form := url.Values{
+		"y": {"2050"}, "m": {"3"},
+		"add_block_1_03/14/2050": {"1"},
+		"add_block_1_03/15/2050": {"1"},
+		"add_block_1_03/20/2050": {"1"},
+	}
+	req := newPOSTForm("/admin/reservations-calendar", form)
+	session.Put(req.Context(), "block_map_1", map[string]int{})
+	rr := do(repo.AdminPostReservationsCalendar, req)
+	mustStatus(t, rr, http.StatusSeeOther)
+
+	flashes, _ := session.Get(req.Context(), models.FlashSessionKey).([]models.FlashMessage)
+	if len(flashes) != 1 || flashes[0].Level != models.FlashWarning || !strings.Contains(flashes[0].Text, "03/14/2050") {
+		t.Errorf("flashes: got %+v, want a warning naming 03/14/2050", flashes)
+	}
+
+	restrictions, err := repo.DB.GetRestrictionsForRoomByDate(1, time.Date(2050, time.March, 1, 0, 0, 0, 0, time.UTC), time.Date(2050, time.April, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var blocked []string
+	for _, res := range restrictions {
+		if res.ID >= 1000 { // stored by InsertBlockForRoom, not canned
+			blocked = append(blocked, res.StartDate.Format("01/02/2006"))
+		}
+	}
+	sort.Strings(blocked)
+	if want := []string{"03/15/2050", "03/20/2050"}; !reflect.DeepEqual(blocked, want) {
+		t.Errorf("blocks inserted: got %v, want %v", blocked, want)
+	}
+}
+
+// TestRepository_AdminReservationsCalendar_WithReservationRestrictions tests reservation display in calendar.
+// This test forces the test repo to include reservation restrictions, ensuring the calendar
+// properly handles and displays both reservation blocks and owner blocks.
+func TestRepository_AdminReservationsCalendar_WithReservationRestrictions(t *testing.T) {
+	dbrepo.ForceHasReservationRestriction = true
+	defer func() { dbrepo.ForceHasReservationRestriction = false }()
+
+	req := newGET("/admin/reservations-calendar?y=" + calendarYear + "&m=1")
+	rr := do(Repo.AdminReservationsCalendar, req)
+	mustStatus(t, rr, http.StatusOK)
+}
+
+// TestRepository_AdminPostShowReservation_GetReservationErr tests reservation lookup error during updates.
+// When the reservation cannot be retrieved for updating, the handler should return a 500 error.
+func TestRepository_AdminPostShowReservation_GetReservationErr(t *testing.T) {
+	dbrepo.ForceGetReservationErr = true
+	defer func() { dbrepo.ForceGetReservationErr = false }()
+
+	reqURI := "/admin/reservations/new/1/show"
+	req := newPOSTForm(reqURI, toForm(map[string]string{
+		"first_name": "X",
+		"last_name":  "Y",
 		"email":      "x@y.com",
 		"phone":      "1",
 	}))
@@ -1109,13 +2083,14 @@ func TestRepository_PostShowLogin_ParseFormError(t *testing.T) {
 // the handler should redirect with an error rather than crashing.
 func TestRepository_PostReservation_InvalidForm_RoomLookupError(t *testing.T) {
 	req := newPOSTForm("/make-reservation", toForm(map[string]string{
-		"start_date": "01/01/2100",
-		"end_date":   "01/02/2100",
-		"first_name": "J", // too short, causes validation failure
-		"last_name":  "Smith",
-		"email":      "john@smith.com",
-		"phone":      "1234567891",
-		"room_id":    "100", // triggers GetRoomByID error during form re-render
+		"start_date":   "01/01/2100",
+		"end_date":     "01/02/2100",
+		"first_name":   "J", // too short, causes validation failure
+		"last_name":    "Smith",
+		"email":        "john@smith.com",
+		"phone":        "1234567891",
+		"accept_terms": "1",
+		"room_id":      "100", // triggers GetRoomByID error during form re-render
 	}))
 	rr := do(Repo.PostReservation, req)
 	mustStatus(t, rr, http.StatusSeeOther)
@@ -1168,3 +2143,3368 @@ func TestRepository_AdminShowReservation_ShortURL(t *testing.T) {
 	rr := do(Repo.AdminShowReservation, req)
 	mustStatus(t, rr, http.StatusInternalServerError)
 }
+
+// TestRepository_AdminReservationJSON verifies the admin reservation detail API.
+// The handler returns the reservation as JSON on success, a 404 JSON body when
+// the reservation does not exist, and a 500 JSON body on database failure.
+func TestRepository_AdminReservationJSON(t *testing.T) {
+	tests := []struct {
+		name       string
+		id         string
+		notFound   bool
+		dbErr      bool
+		wantStatus int
+		wantErr    string
+	}{
+		{name: "reservation found", id: "1", wantStatus: http.StatusOK},
+		{name: "invalid id", id: "abc", wantStatus: http.StatusBadRequest, wantErr: errCodeInvalidInput},
+		{name: "reservation not found", id: "1", notFound: true, wantStatus: http.StatusNotFound, wantErr: errCodeNotFound},
+		{name: "database error", id: "1", dbErr: true, wantStatus: http.StatusInternalServerError, wantErr: errCodeServer},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dbrepo.ForceReservationNotFound = tc.notFound
+			dbrepo.ForceGetReservationErr = tc.dbErr
+			defer func() {
+				dbrepo.ForceReservationNotFound = false
+				dbrepo.ForceGetReservationErr = false
+			}()
+
+			req := newGET("/admin/api/reservations/" + tc.id)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", tc.id)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			rr := do(Repo.AdminReservationJSON, req)
+			mustStatus(t, rr, tc.wantStatus)
+
+			if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+				t.Fatalf("Content-Type: got %q, want application/json", ct)
+			}
+
+			if tc.wantStatus == http.StatusOK {
+				var res models.Reservation
+				if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+					t.Fatalf("json unmarshal: %v", err)
+				}
+				if res.ID != 1 {
+					t.Fatalf("ID: got %d, want 1", res.ID)
+				}
+				return
+			}
+
+			mustAPIError(t, rr, tc.wantStatus, tc.wantErr)
+		})
+	}
+}
+
+// mailCapture is a buffered mail channel that stands in for the shared
+// app.MailChan, which TestMain drains into the void. Handlers queue onto it
+// without blocking and tests read back what was sent.
+type mailCapture chan models.MailData
+
+// sent drains and returns every message queued so far, in queue order.
+func (c mailCapture) sent() []models.MailData {
+	var msgs []models.MailData
+	for len(c) > 0 {
+		msgs = append(msgs, <-c)
+	}
+	return msgs
+}
+
+// newMailCaptureRepo returns a test Repository whose AppConfig copies the shared
+// test config but queues mail on a mailCapture, so tests can assert recipients,
+// subjects and content without racing the global mail listener.
+func newMailCaptureRepo() (*Repository, mailCapture) {
+	testApp := app
+	mailChan := make(mailCapture, 16)
+	testApp.MailChan = mailChan
+	return NewTestRepo(&testApp), mailChan
+}
+
+// TestReservationEmails verifies that a reservation produces the guest
+// confirmation first, then one notification per staff address.
+func TestReservationEmails(t *testing.T) {
+	res := models.Reservation{
+		FirstName: "John",
+		Email:     "john@smith.com",
+		StartDate: time.Date(2100, time.January, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2100, time.January, 3, 0, 0, 0, 0, time.UTC),
+		Room:      models.Room{RoomName: "Golden Haybeam Loft"},
+	}
+
+	msgs := reservationEmails("Milo's Residence", res, "en", "3:00 PM", "11:00 AM", []string{"a@example.com", "b@example.com"})
+	if len(msgs) != 3 {
+		t.Fatalf("messages: got %d, want 3", len(msgs))
+	}
+	if msgs[0].To != "john@smith.com" || msgs[0].Subject != "Reservation Confirmation" || msgs[0].Template != "basic.html" {
+		t.Errorf("guest confirmation: got %+v", msgs[0])
+	}
+	for i, to := range []string{"a@example.com", "b@example.com"} {
+		staff := msgs[i+1]
+		if staff.To != to || staff.Subject != "Reservation Notification" {
+			t.Errorf("staff notification %d: got %+v", i, staff)
+		}
+		if !strings.Contains(staff.Content, "Golden Haybeam Loft") || !strings.Contains(staff.Content, "01/01/2100 to 01/03/2100") {
+			t.Errorf("staff notification %d content: %s", i, staff.Content)
+		}
+	}
+}
+
+// TestReservationEmails_PropertyName verifies that the property name passed
+// in, rather than a built-in one, appears HTML-escaped in the guest
+// confirmation and the staff notification, and that the guest's first name
+// is escaped too.
+func TestReservationEmails_PropertyName(t *testing.T) {
+	res := models.Reservation{
+		FirstName: "<b>John</b>",
+		Email:     "john@smith.com",
+		StartDate: time.Date(2100, time.January, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2100, time.January, 3, 0, 0, 0, 0, time.UTC),
+		Room:      models.Room{RoomName: "Golden Haybeam Loft"},
+	}
+
+	for _, locale := range []string{"en", "es"} {
+		msgs := reservationEmails("Otis's Bungalow", res, locale, "3:00 PM", "11:00 AM", []string{"staff@example.com"})
+		for _, msg := range msgs {
+			if !strings.Contains(msg.Content, "Otis&#39;s Bungalow") {
+				t.Errorf("%s message to %s does not name the property escaped: %s", locale, msg.To, msg.Content)
+			}
+			if strings.Contains(msg.Content, "<b>John") {
+				t.Errorf("%s message to %s has the first name unescaped: %s", locale, msg.To, msg.Content)
+			}
+			if strings.Contains(msg.Content, "Milo's Residence") {
+				t.Errorf("%s message to %s uses the built-in name", locale, msg.To)
+			}
+		}
+	}
+
+	msgs := contactEmails("Otis's Bungalow", "Jane", "jane@example.com", "general", "Hi", "staff@example.com")
+	if msgs[1].Subject != "Thanks for contacting Otis's Bungalow" {
+		t.Errorf("contact confirmation subject: got %q", msgs[1].Subject)
+	}
+}
+
+// TestContactEmails verifies that a contact submission is forwarded to staff
+// from the sender's address and confirmed back to the sender.
+func TestContactEmails(t *testing.T) {
+	msgs := contactEmails("Milo's Residence", "Jane Doe", "jane@example.com", "billing", "Where is my invoice?", "billing@example.com")
+	if len(msgs) != 2 {
+		t.Fatalf("messages: got %d, want 2", len(msgs))
+	}
+
+	forward, confirm := msgs[0], msgs[1]
+	if forward.To != "billing@example.com" || forward.From != "jane@example.com" || forward.Subject != "Contact Form: billing" {
+		t.Errorf("forward: got %+v", forward)
+	}
+	if !strings.Contains(forward.Content, "Where is my invoice?") {
+		t.Errorf("forward content: %s", forward.Content)
+	}
+	if confirm.To != "jane@example.com" || confirm.From != contactMailFrom || !strings.Contains(confirm.Content, "Hi Jane Doe") {
+		t.Errorf("confirmation: got %+v", confirm)
+	}
+}
+
+// TestRepository_PostReservation_Mail verifies that a reservation queues
+// exactly two messages: the guest confirmation and the staff notification.
+func TestRepository_PostReservation_Mail(t *testing.T) {
+	dbrepo.ResetSettings()
+	repo, mail := newMailCaptureRepo()
+
+	req := newPOSTForm("/make-reservation", toForm(map[string]string{
+		"start_date":   "01/01/2100",
+		"end_date":     "01/02/2100",
+		"first_name":   "John",
+		"last_name":    "Smith",
+		"email":        "john@smith.com",
+		"phone":        "1234567891",
+		"accept_terms": "1",
+		"room_id":      "1",
+	}))
+	rr := do(repo.PostReservation, req)
+	mustStatus(t, rr, http.StatusSeeOther)
+
+	msgs := mail.sent()
+	if len(msgs) != 2 {
+		t.Fatalf("queued mails: got %d, want 2", len(msgs))
+	}
+	if msgs[0].To != "john@smith.com" || msgs[0].Subject != "Reservation Confirmation" {
+		t.Errorf("guest confirmation: got To=%q Subject=%q", msgs[0].To, msgs[0].Subject)
+	}
+	if msgs[1].To != repo.contactRecipient(notificationFallbackTopic) || msgs[1].Subject != "Reservation Notification" {
+		t.Errorf("staff notification: got To=%q Subject=%q", msgs[1].To, msgs[1].Subject)
+	}
+}
+
+// TestRepository_AdvanceBookingWindow verifies that the booking form and the
+// reservation API accept a stay starting on the last day of the
+// MaxAdvanceDays window and reject one starting a day later with a
+// start_date field error.
+func TestRepository_AdvanceBookingWindow(t *testing.T) {
+	testApp := app
+	testApp.MaxAdvanceDays = 365
+	repo := NewTestRepo(&testApp)
+	repo.now = func() time.Time { return time.Date(2100, time.June, 1, 10, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name   string
+		start  string
+		end    string
+		inside bool
+	}{
+		{name: "last day of window", start: "06/01/2101", end: "06/03/2101", inside: true},
+		{name: "day after window", start: "06/02/2101", end: "06/04/2101"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name+" form", func(t *testing.T) {
+			req := newPOSTForm("/make-reservation", toForm(map[string]string{
+				"start_date":   tc.start,
+				"end_date":     tc.end,
+				"first_name":   "John",
+				"last_name":    "Smith",
+				"email":        "john@smith.com",
+				"phone":        "1234567891",
+				"accept_terms": "1",
+				"room_id":      "1",
+			}))
+			rr := do(repo.PostReservation, req)
+			if tc.inside {
+				mustStatus(t, rr, http.StatusSeeOther)
+				mustRedirectContains(t, rr, "/reservation-summary")
+				return
+			}
+			mustStatus(t, rr, http.StatusOK)
+			if !strings.Contains(rr.Body.String(), "Bookings can start at most 365 days from today") {
+				t.Error("expected the advance window error")
+			}
+		})
+
+		t.Run(tc.name+" API", func(t *testing.T) {
+			dbrepo.ResetCreatedReservations()
+			defer dbrepo.ResetCreatedReservations()
+
+			b, _ := json.Marshal(map[string]interface{}{
+				"room_id": 1, "start_date": tc.start, "end_date": tc.end,
+				"first_name": "John", "last_name": "Smith", "email": "john@smith.com",
+				"phone": "555-555-5555", "accept_terms": true,
+			})
+			req := httptest.NewRequest(http.MethodPost, "/api/reservations", bytes.NewReader(b))
+			rr := do(repo.ReservationAPI, req)
+			if tc.inside {
+				mustStatus(t, rr, http.StatusCreated)
+				return
+			}
+			mustAPIError(t, rr, http.StatusBadRequest, errCodeInvalidInput)
+			var e apiError
+			if err := json.Unmarshal(rr.Body.Bytes(), &e); err != nil {
+				t.Fatal(err)
+			}
+			if len(e.Fields["start_date"]) != 1 {
+				t.Errorf("fields: got %v, want a start_date error", e.Fields)
+			}
+		})
+	}
+}
+
+// TestRepository_PostContact_Confirmation verifies that a contact submission
+// queues a confirmation addressed to the sender.
+func TestRepository_PostContact_Confirmation(t *testing.T) {
+	repo, mail := newMailCaptureRepo()
+
+	req := newPOSTForm("/contact", toForm(map[string]string{
+		"name":    "Jane Doe",
+		"email":   "jane@example.com",
+		"topic":   "general",
+		"message": "Do you take guinea pigs?",
+	}))
+	rr := do(repo.PostContact, req)
+	mustStatus(t, rr, http.StatusSeeOther)
+
+	msgs := mail.sent()
+	if len(msgs) != 2 {
+		t.Fatalf("queued mails: got %d, want 2", len(msgs))
+	}
+	confirm := msgs[1]
+	if confirm.To != "jane@example.com" || confirm.Subject != "Thanks for contacting Milo's Residence" {
+		t.Errorf("confirmation: got To=%q Subject=%q", confirm.To, confirm.Subject)
+	}
+	if !strings.Contains(confirm.Content, "Jane Doe") {
+		t.Errorf("confirmation should greet the sender: %s", confirm.Content)
+	}
+}
+
+// TestRepository_PostContact_EscapesContent verifies that markup typed into
+// the contact form is escaped in both outbound emails, and that the
+// message's line breaks survive as <br>.
+func TestRepository_PostContact_EscapesContent(t *testing.T) {
+	repo, mail := newMailCaptureRepo()
+
+	req := newPOSTForm("/contact", toForm(map[string]string{
+		"name":    "Jane <b>Doe</b>",
+		"email":   "jane@example.com",
+		"topic":   "general",
+		"message": "Hello <script>alert('x')</script>\nSecond line",
+	}))
+	rr := do(repo.PostContact, req)
+	mustStatus(t, rr, http.StatusSeeOther)
+
+	msgs := mail.sent()
+	if len(msgs) != 2 {
+		t.Fatalf("queued mails: got %d, want 2", len(msgs))
+	}
+	forward, confirm := msgs[0], msgs[1]
+
+	for _, raw := range []string{"<script>", "</script>", "<b>"} {
+		if strings.Contains(forward.Content, raw) || strings.Contains(confirm.Content, raw) {
+			t.Errorf("outbound content contains unescaped %q", raw)
+		}
+	}
+	for _, want := range []string{"&lt;script&gt;alert(&#39;x&#39;)&lt;/script&gt;<br>", "Second line", "Jane &lt;b&gt;Doe&lt;/b&gt;"} {
+		if !strings.Contains(forward.Content, want) {
+			t.Errorf("forward content missing %q: %s", want, forward.Content)
+		}
+	}
+	if !strings.Contains(confirm.Content, "Hi Jane &lt;b&gt;Doe&lt;/b&gt;") {
+		t.Errorf("confirmation should greet the escaped name: %s", confirm.Content)
+	}
+}
+
+// TestRepository_PostContact_Honeypot verifies spam detection on the contact form.
+// The honeypot input name comes from AppConfig.HoneypotField; filling it must
+// reject the submission before any email is queued, while the old default name
+// is treated as an ordinary field once a custom name is configured.
+func TestRepository_PostContact_Honeypot(t *testing.T) {
+	valid := map[string]string{
+		"name":    "Jane Doe",
+		"email":   "jane@example.com",
+		"topic":   "Booking",
+		"message": "Hello, is the loft available?",
+	}
+
+	tests := []struct {
+		name      string
+		honeypot  string
+		filled    string
+		wantMails int
+		wantKey   string
+	}{
+		{"default field triggers rejection", "", "website", 0, "error"},
+		{"configured field triggers rejection", "company_url", "company_url", 0, "error"},
+		{"old field ignored when renamed", "company_url", "website", 2, "flash"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo, mailChan := newMailCaptureRepo()
+			repo.App.HoneypotField = tc.honeypot
+
+			form := toForm(valid)
+			form.Set(tc.filled, "http://spam.example")
+
+			req := newPOSTForm("/contact", form)
+			rr := do(repo.PostContact, req)
+			mustStatus(t, rr, http.StatusSeeOther)
+			mustRedirectContains(t, rr, "/contact")
+
+			if got := len(mailChan); got != tc.wantMails {
+				t.Fatalf("queued mails: got %d, want %d", got, tc.wantMails)
+			}
+			if !session.Exists(req.Context(), tc.wantKey) {
+				t.Fatalf("expected %q message in session", tc.wantKey)
+			}
+		})
+	}
+}
+
+// TestRepository_PostContact verifies validation and successful submission.
+// A valid message queues the admin notification and the sender confirmation.
+func TestRepository_PostContact(t *testing.T) {
+	t.Run("validation failure re-renders form", func(t *testing.T) {
+		repo, mailChan := newMailCaptureRepo()
+		req := newPOSTForm("/contact", toForm(map[string]string{"name": "J"}))
+		rr := do(repo.PostContact, req)
+		mustStatus(t, rr, http.StatusOK)
+		if len(mailChan) != 0 {
+			t.Fatalf("queued mails: got %d, want 0", len(mailChan))
+		}
+	})
+
+	t.Run("valid submission queues both emails", func(t *testing.T) {
+		repo, mailChan := newMailCaptureRepo()
+		req := newPOSTForm("/contact", toForm(map[string]string{
+			"name":    "Jane Doe",
+			"email":   "jane@example.com",
+			"message": "Hello, is the loft available?",
+		}))
+		rr := do(repo.PostContact, req)
+		mustStatus(t, rr, http.StatusSeeOther)
+		if len(mailChan) != 2 {
+			t.Fatalf("queued mails: got %d, want 2", len(mailChan))
+		}
+		if got := (<-mailChan).To; got != config.DefaultContactRecipient {
+			t.Fatalf("missing topic routed to %q, want %q", got, config.DefaultContactRecipient)
+		}
+	})
+}
+
+// TestRepository_PostContact_TopicRouting verifies that the staff notification
+// goes to the address configured for the topic, that unconfigured topics use
+// the general address, and that a topic outside the allowlist re-renders the
+// form without sending mail.
+func TestRepository_PostContact_TopicRouting(t *testing.T) {
+	tests := []struct {
+		name   string
+		topic  string
+		wantTo string
+	}{
+		{name: "billing", topic: "billing", wantTo: "billing@example.com"},
+		{name: "case-insensitive", topic: "Billing", wantTo: "billing@example.com"},
+		{name: "unconfigured topic uses general", topic: "booking", wantTo: "hello@example.com"},
+		{name: "invalid topic", topic: "lawsuit"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo, mailChan := newMailCaptureRepo()
+			repo.App.ContactRecipients = map[string]string{
+				"billing": "billing@example.com",
+				"general": "hello@example.com",
+			}
+
+			req := newPOSTForm("/contact", toForm(map[string]string{
+				"name":    "Jane Doe",
+				"email":   "jane@example.com",
+				"topic":   tc.topic,
+				"message": "Question about my last invoice",
+			}))
+			rr := do(repo.PostContact, req)
+
+			if tc.wantTo == "" {
+				mustStatus(t, rr, http.StatusOK)
+				if !strings.Contains(rr.Body.String(), "is-invalid") {
+					t.Fatal("expected the topic field to be marked invalid")
+				}
+				if len(mailChan) != 0 {
+					t.Fatalf("queued mails: got %d, want 0", len(mailChan))
+				}
+				return
+			}
+
+			mustStatus(t, rr, http.StatusSeeOther)
+			if got := (<-mailChan).To; got != tc.wantTo {
+				t.Fatalf("staff mail To: got %q, want %q", got, tc.wantTo)
+			}
+		})
+	}
+}
+
+// TestRepository_AdminReportConflicts verifies the restriction conflict report.
+// The test repo returns one known conflict, which must appear in the rendered page;
+// a forced query failure must produce a 500.
+func TestRepository_AdminReportConflicts(t *testing.T) {
+	t.Run("renders known conflict", func(t *testing.T) {
+		req := newGET("/admin/reports/conflicts")
+		rr := do(Repo.AdminReportConflicts, req)
+		mustStatus(t, rr, http.StatusOK)
+
+		body := rr.Body.String()
+		for _, want := range []string{"Golden Haybeam Loft", "Owner block #11", "Reservation 777"} {
+			if !strings.Contains(body, want) {
+				t.Fatalf("body missing %q", want)
+			}
+		}
+	})
+
+	t.Run("database error", func(t *testing.T) {
+		dbrepo.ForceConflictsErr = true
+		defer func() { dbrepo.ForceConflictsErr = false }()
+
+		req := newGET("/admin/reports/conflicts")
+		rr := do(Repo.AdminReportConflicts, req)
+		mustStatus(t, rr, http.StatusInternalServerError)
+	})
+}
+
+// TestStatusOnWrite verifies that the pending status goes out with the first
+// body write, and that an explicit status set first (such as a render
+// failure's 500) is kept instead.
+func TestStatusOnWrite(t *testing.T) {
+	rr := httptest.NewRecorder()
+	w := &statusOnWrite{ResponseWriter: rr, status: http.StatusNotFound}
+	w.Write([]byte("page"))
+	mustStatus(t, rr, http.StatusNotFound)
+
+	rr = httptest.NewRecorder()
+	w = &statusOnWrite{ResponseWriter: rr, status: http.StatusNotFound}
+	http.Error(w, "Template Execution Error", http.StatusInternalServerError)
+	mustStatus(t, rr, http.StatusInternalServerError)
+}
+
+// TestRepository_AdminShowReservation_NotFound verifies that a missing reservation
+// renders the dedicated not-found page with a 404, while a real database failure
+// still produces a 500.
+func TestRepository_AdminShowReservation_NotFound(t *testing.T) {
+	t.Run("missing id renders not-found page", func(t *testing.T) {
+		reqURI := "/admin/reservations/all/999/show"
+		req := newGET(reqURI)
+		req.RequestURI = reqURI
+		rr := do(Repo.AdminShowReservation, req)
+		mustStatus(t, rr, http.StatusNotFound)
+
+		if !strings.Contains(rr.Body.String(), "Reservation not found") {
+			t.Fatal("expected not-found template in response body")
+		}
+	})
+
+	t.Run("database error returns 500", func(t *testing.T) {
+		dbrepo.ForceGetReservationErr = true
+		defer func() { dbrepo.ForceGetReservationErr = false }()
+
+		reqURI := "/admin/reservations/all/999/show"
+		req := newGET(reqURI)
+		req.RequestURI = reqURI
+		rr := do(Repo.AdminShowReservation, req)
+		mustStatus(t, rr, http.StatusInternalServerError)
+	})
+}
+
+// TestRepository_AdminEmailPreview verifies that known email templates render
+// with sample data in development and that the preview is hidden in production.
+func TestRepository_AdminEmailPreview(t *testing.T) {
+	prodApp := app
+	prodApp.InProduction = true
+	prodRepo := NewTestRepo(&prodApp)
+
+	tests := []struct {
+		name       string
+		repo       *Repository
+		template   string
+		query      string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "known template", repo: Repo, template: "basic.html", wantStatus: http.StatusOK, wantBody: "Dear Milo"},
+		{name: "query override escaped", repo: Repo, template: "basic.html", query: "?first_name=%3Cb%3EAda", wantStatus: http.StatusOK, wantBody: "Dear &lt;b&gt;Ada"},
+		{name: "unknown template", repo: Repo, template: "missing.html", wantStatus: http.StatusNotFound},
+		{name: "path traversal", repo: Repo, template: "../go.mod", wantStatus: http.StatusNotFound},
+		{name: "production", repo: prodRepo, template: "basic.html", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := newGET("/admin/email-preview/x" + tc.query)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("template", tc.template)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			rr := do(tc.repo.AdminEmailPreview, req)
+			mustStatus(t, rr, tc.wantStatus)
+
+			if tc.wantBody == "" {
+				return
+			}
+			body := rr.Body.String()
+			if !strings.Contains(body, tc.wantBody) {
+				t.Fatalf("body missing %q", tc.wantBody)
+			}
+			if strings.Contains(body, "[%body%]") {
+				t.Fatal("body placeholder was not replaced")
+			}
+		})
+	}
+}
+
+// TestRepository_AdminPostShowReservation_UnexpectedSrc verifies that an unknown
+// src segment is normalized so the post-update redirect lands on the "all"
+// reservations list rather than a path derived from user input.
+func TestRepository_AdminPostShowReservation_UnexpectedSrc(t *testing.T) {
+	reqURI := "/admin/reservations/@evil.com/1"
+	req := newPOSTForm(reqURI, toForm(map[string]string{"first_name": "John"}))
+	req.RequestURI = reqURI
+
+	rr := do(Repo.AdminPostShowReservation, req)
+	mustStatus(t, rr, http.StatusSeeOther)
+
+	if loc := rr.Header().Get("Location"); loc != "/admin/reservations?status=all" {
+		t.Fatalf("Location: got %q, want /admin/reservations?status=all", loc)
+	}
+}
+
+// TestRepository_PostWaitlist verifies waitlist signup: valid entries are stored
+// and redirect home, invalid details re-render the form, and bad dates or a
+// database failure redirect with an error.
+func TestRepository_PostWaitlist(t *testing.T) {
+	valid := map[string]string{
+		"first_name": "Jane",
+		"last_name":  "Doe",
+		"email":      "jane@example.com",
+		"phone":      "555-555-5555",
+		"start":      "01/01/2100",
+		"end":        "01/03/2100",
+	}
+
+	with := func(key, value string) map[string]string {
+		form := make(map[string]string)
+		for k, v := range valid {
+			form[k] = v
+		}
+		form[key] = value
+		return form
+	}
+
+	tests := []struct {
+		name       string
+		form       map[string]string
+		dbErr      bool
+		wantStatus int
+		wantLoc    string
+		wantError  string
+		wantStored int
+	}{
+		{name: "valid entry", form: valid, wantStatus: http.StatusSeeOther, wantLoc: "/", wantStored: 1},
+		{name: "invalid email", form: with("email", "not-an-email"), wantStatus: http.StatusOK},
+		{name: "invalid start date", form: with("start", "invalid"), wantStatus: http.StatusSeeOther, wantLoc: "/waitlist", wantError: "can't parse start date!"},
+		{name: "invalid end date", form: with("end", "invalid"), wantStatus: http.StatusSeeOther, wantLoc: "/waitlist", wantError: "can't parse end date!"},
+		{name: "end before start", form: with("end", "12/31/2099"), wantStatus: http.StatusSeeOther, wantLoc: "/waitlist", wantError: "Departure must be after arrival"},
+		{name: "end on start", form: with("end", "01/01/2100"), wantStatus: http.StatusSeeOther, wantLoc: "/waitlist", wantError: "Departure must be after arrival"},
+		{name: "database error", form: valid, dbErr: true, wantStatus: http.StatusSeeOther, wantLoc: "/"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dbrepo.ResetWaitlist()
+			dbrepo.ForceWaitlistErr = tc.dbErr
+			defer func() {
+				dbrepo.ForceWaitlistErr = false
+				dbrepo.ResetWaitlist()
+			}()
+
+			req := newPOSTForm("/waitlist", toForm(tc.form))
+			rr := do(Repo.PostWaitlist, req)
+			mustStatus(t, rr, tc.wantStatus)
+			if tc.wantLoc != "" {
+				if loc := rr.Header().Get("Location"); loc != tc.wantLoc {
+					t.Fatalf("Location: got %q, want %q", loc, tc.wantLoc)
+				}
+			}
+			if tc.wantError != "" {
+				if got := session.GetString(req.Context(), "error"); got != tc.wantError {
+					t.Errorf("session error: got %q, want %q", got, tc.wantError)
+				}
+			}
+
+			entries, _ := Repo.DB.WaitlistForDates(
+				time.Date(2100, time.January, 1, 0, 0, 0, 0, time.UTC),
+				time.Date(2100, time.January, 3, 0, 0, 0, 0, time.UTC),
+			)
+			if len(entries) != tc.wantStored {
+				t.Fatalf("stored entries: got %d, want %d", len(entries), tc.wantStored)
+			}
+		})
+	}
+}
+
+// TestRepository_AdminDeleteReservation_LogsWaitlist verifies that cancelling a
+// reservation logs waitlist entries whose dates overlap the freed stay.
+func TestRepository_AdminDeleteReservation_LogsWaitlist(t *testing.T) {
+	dbrepo.ResetWaitlist()
+	defer dbrepo.ResetWaitlist()
+
+	// Test repo reservation 1 runs 01/01/2050 - 01/02/2050.
+	res, err := Repo.DB.GetReservationByID(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = Repo.DB.AddToWaitlist(models.WaitlistEntry{
+		FirstName: "Jane", LastName: "Doe", Email: "jane@example.com",
+		StartDate: res.StartDate, EndDate: res.EndDate,
+	})
+
+	var buf bytes.Buffer
+	testApp := app
+	testApp.InfoLog = log.New(&buf, "", 0)
+	repo := NewTestRepo(&testApp)
+
+	req := newGET("/admin/delete-reservation/new/1/do")
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "1")
+	rctx.URLParams.Add("src", "new")
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	rr := do(repo.AdminDeleteReservation, req)
+	mustStatus(t, rr, http.StatusSeeOther)
+
+	if !strings.Contains(buf.String(), "jane@example.com") {
+		t.Fatalf("expected waitlist follow-up log, got %q", buf.String())
+	}
+}
+
+// TestRepository_PostContact_BodyTooLarge verifies that a form body cut off by
+// http.MaxBytesReader is answered with 413 rather than a generic redirect.
+func TestRepository_PostContact_BodyTooLarge(t *testing.T) {
+	body := "message=" + strings.Repeat("x", 64)
+	req := httptest.NewRequest(http.MethodPost, "/contact", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = sessionize(req)
+
+	rr := httptest.NewRecorder()
+	req.Body = http.MaxBytesReader(rr, req.Body, 16)
+	Repo.PostContact(rr, req)
+
+	mustStatus(t, rr, http.StatusRequestEntityTooLarge)
+}
+
+// TestRepository_quoteTotal verifies that the quoted total sums per-night
+// rates, charging the override only for the night it covers.
+func TestRepository_quoteTotal(t *testing.T) {
+	override := dbrepo.TestRateOverrideDate
+
+	tests := []struct {
+		name       string
+		start, end time.Time
+		want       int
+	}{
+		{name: "plain night", start: override.AddDate(0, 0, -2), end: override.AddDate(0, 0, -1), want: dbrepo.TestBaseRate},
+		{name: "spans override and plain night", start: override.AddDate(0, 0, -1), end: override.AddDate(0, 0, 1), want: dbrepo.TestBaseRate + dbrepo.TestOverrideRate},
+		{name: "checkout on override day", start: override.AddDate(0, 0, -1), end: override, want: dbrepo.TestBaseRate},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := Repo.quote(models.Reservation{RoomID: 1, StartDate: tc.start, EndDate: tc.end}, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if q.Total != tc.want {
+				t.Fatalf("total: got %d, want %d", q.Total, tc.want)
+			}
+		})
+	}
+}
+
+// TestRepository_PostReservation_Total verifies that PostReservation stores the
+// summed total on the reservation and that a pricing failure aborts booking.
+func TestRepository_PostReservation_Total(t *testing.T) {
+	form := map[string]string{
+		"start_date":   "12/24/2100",
+		"end_date":     "12/26/2100",
+		"first_name":   "John",
+		"last_name":    "Smith",
+		"email":        "john@smith.com",
+		"phone":        "1234567891",
+		"accept_terms": "1",
+		"room_id":      "1",
+	}
+
+	t.Run("total stored in session", func(t *testing.T) {
+		req := newPOSTForm("/make-reservation", toForm(form))
+		rr := do(Repo.PostReservation, req)
+		mustStatus(t, rr, http.StatusSeeOther)
+		mustRedirectContains(t, rr, "/reservation-summary")
+
+		res, ok := session.Get(req.Context(), "reservation").(models.Reservation)
+		if !ok {
+			t.Fatal("reservation not stored in session")
+		}
+		if want := dbrepo.TestBaseRate + dbrepo.TestOverrideRate; res.Total != want {
+			t.Fatalf("total: got %d, want %d", res.Total, want)
+		}
+	})
+
+	t.Run("rate lookup error", func(t *testing.T) {
+		dbrepo.ForceRateErr = true
+		defer func() { dbrepo.ForceRateErr = false }()
+
+		req := newPOSTForm("/make-reservation", toForm(form))
+		rr := do(Repo.PostReservation, req)
+		mustStatus(t, rr, http.StatusSeeOther)
+		mustRedirectContains(t, rr, "/")
+		if msg := session.GetString(req.Context(), "error"); msg != "can't calculate price!" {
+			t.Fatalf("error flash: got %q", msg)
+		}
+	})
+}
+
+// TestRepository_SitemapXML verifies that the sitemap lists every public path
+// and the page of each open room that exists, and fails with 500 when rooms
+// cannot be loaded.
+func TestRepository_SitemapXML(t *testing.T) {
+	req := newGET("/sitemap.xml")
+	req.Host = "example.com"
+	rr := do(Repo.SitemapXML, req)
+	mustStatus(t, rr, http.StatusOK)
+
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/xml") {
+		t.Fatalf("Content-Type: got %q", ct)
+	}
+
+	body := rr.Body.String()
+	for _, path := range sitemapPaths {
+		want := "<loc>http://example.com" + path + "</loc>"
+		if !strings.Contains(body, want) {
+			t.Errorf("sitemap missing %s", want)
+		}
+	}
+	if !strings.Contains(body, "<loc>http://example.com/golden-haybeam-loft</loc>") {
+		t.Error("sitemap missing room URL")
+	}
+	// The test repository's AllRooms returns only room 1, so the other
+	// room pages have no room behind them.
+	if strings.Contains(body, "/window-perch-theater") || strings.Contains(body, "/laundry-basket-nook") {
+		t.Error("sitemap lists a page whose room does not exist")
+	}
+
+	// A closed room's page is left out.
+	if err := Repo.DB.SetRoomActive(1, false); err != nil {
+		t.Fatal(err)
+	}
+	rr = do(Repo.SitemapXML, req)
+	dbrepo.ResetClosedRooms()
+	if strings.Contains(rr.Body.String(), "/golden-haybeam-loft") {
+		t.Error("sitemap lists a closed room")
+	}
+
+	// A configured BASE_URL wins over the request host.
+	app.BaseURL = "https://milosresidence.com/"
+	rr = do(Repo.SitemapXML, req)
+	app.BaseURL = ""
+	if !strings.Contains(rr.Body.String(), "<loc>https://milosresidence.com/about</loc>") {
+		t.Error("sitemap ignored BaseURL")
+	}
+
+	dbrepo.ForceAllRoomsErr = true
+	defer func() { dbrepo.ForceAllRoomsErr = false }()
+
+	rr = do(Repo.SitemapXML, newGET("/sitemap.xml"))
+	mustStatus(t, rr, http.StatusInternalServerError)
+}
+
+// TestRepository_Healthz verifies the health check reports ok while the
+// database answers pings and 503 unavailable when it does not, and that the
+// home page renders regardless of database health.
+func TestRepository_Healthz(t *testing.T) {
+	tests := []struct {
+		name       string
+		pingErr    bool
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "healthy", wantStatus: http.StatusOK, wantBody: "ok"},
+		{name: "database down", pingErr: true, wantStatus: http.StatusServiceUnavailable, wantBody: "unavailable"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dbrepo.ForcePingErr = tc.pingErr
+			defer func() { dbrepo.ForcePingErr = false }()
+
+			rr := do(Repo.Healthz, newGET("/healthz"))
+			mustStatus(t, rr, tc.wantStatus)
+
+			var got healthStatus
+			if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != tc.wantBody {
+				t.Fatalf("status: got %q, want %q", got.Status, tc.wantBody)
+			}
+
+			mustStatus(t, do(Repo.Home, newGET("/")), http.StatusOK)
+		})
+	}
+}
+
+// TestRepository_RobotsTxt verifies that robots.txt reflects the configured
+// disallow list and allows everything when the list is empty.
+func TestRepository_RobotsTxt(t *testing.T) {
+	tests := []struct {
+		name     string
+		disallow []string
+		want     []string
+	}{
+		{name: "configured list", disallow: []string{"/admin", "/user"}, want: []string{"Disallow: /admin\n", "Disallow: /user\n"}},
+		{name: "empty list", disallow: nil, want: []string{"Disallow:\n"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			testApp := app
+			testApp.RobotsDisallow = tc.disallow
+			repo := NewTestRepo(&testApp)
+
+			req := newGET("/robots.txt")
+			req.Host = "example.com"
+			rr := do(repo.RobotsTxt, req)
+			mustStatus(t, rr, http.StatusOK)
+
+			body := rr.Body.String()
+			for _, want := range append(tc.want, "User-agent: *\n", "Sitemap: http://example.com/sitemap.xml\n") {
+				if !strings.Contains(body, want) {
+					t.Errorf("robots.txt missing %q:\n%s", want, body)
+				}
+			}
+		})
+	}
+}
+
+// TestRepository_WebManifest verifies that the web app manifest is served as
+// application/manifest+json with the static cache lifetime, names the
+// configured property and lists the favicon as its icon.
+func TestRepository_WebManifest(t *testing.T) {
+	testApp := app
+	testApp.PropertyName = "River House"
+	testApp.StaticMaxAge = 2 * time.Hour
+	repo := NewTestRepo(&testApp)
+
+	rr := do(repo.WebManifest, newGET("/site.webmanifest"))
+	mustStatus(t, rr, http.StatusOK)
+
+	if got := rr.Header().Get("Content-Type"); got != "application/manifest+json" {
+		t.Errorf("Content-Type: got %q, want application/manifest+json", got)
+	}
+	if got := rr.Header().Get("Cache-Control"); got != "public, max-age=7200" {
+		t.Errorf("Cache-Control: got %q, want public, max-age=7200", got)
+	}
+
+	var manifest webManifest
+	if err := json.Unmarshal(rr.Body.Bytes(), &manifest); err != nil {
+		t.Fatalf("decode manifest: %v", err)
+	}
+	if manifest.Name != "River House" || manifest.ShortName != "River House" {
+		t.Errorf("name: got %q/%q, want River House", manifest.Name, manifest.ShortName)
+	}
+	if len(manifest.Icons) != 1 || manifest.Icons[0].Src != "/favicon.ico" {
+		t.Errorf("icons: got %+v, want /favicon.ico", manifest.Icons)
+	}
+}
+
+// TestRepository_AdminShowReservation_History verifies that the guest's prior
+// stays render on the reservation page, excluding the reservation being viewed,
+// and that a history lookup failure returns 500.
+func TestRepository_AdminShowReservation_History(t *testing.T) {
+	reqURI := "/admin/reservations/all/1/show"
+
+	req := newGET(reqURI)
+	req.RequestURI = reqURI
+	rr := do(Repo.AdminShowReservation, req)
+	mustStatus(t, rr, http.StatusOK)
+
+	body := rr.Body.String()
+	if !strings.Contains(body, "<strong>Previous stays:</strong> 2") {
+		t.Fatal("previous stay count not rendered")
+	}
+	for _, want := range []string{"/admin/reservations/all/101/show", "/admin/reservations/all/102/show"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing link to prior stay %s", want)
+		}
+	}
+	if strings.Contains(body, "/admin/reservations/all/1/show") {
+		t.Error("current reservation should not be listed as a previous stay")
+	}
+
+	dbrepo.ForceReservationsByEmailErr = true
+	defer func() { dbrepo.ForceReservationsByEmailErr = false }()
+
+	req = newGET(reqURI)
+	req.RequestURI = reqURI
+	rr = do(Repo.AdminShowReservation, req)
+	mustStatus(t, rr, http.StatusInternalServerError)
+}
+
+// TestRepository_PostReservation_TrimsInput verifies that names and contact
+// details are stored trimmed, with internal name whitespace collapsed.
+func TestRepository_PostReservation_TrimsInput(t *testing.T) {
+	req := newPOSTForm("/make-reservation", toForm(map[string]string{
+		"start_date":   " 01/01/2100 ",
+		"end_date":     "01/02/2100",
+		"first_name":   "  Mary   Ann ",
+		"last_name":    "Smith  ",
+		"email":        "  mary@smith.com ",
+		"phone":        " 1234567891",
+		"accept_terms": "1",
+		"room_id":      " 1 ",
+	}))
+	rr := do(Repo.PostReservation, req)
+	mustStatus(t, rr, http.StatusSeeOther)
+	mustRedirectContains(t, rr, "/reservation-summary")
+
+	res, ok := session.Get(req.Context(), "reservation").(models.Reservation)
+	if !ok {
+		t.Fatal("reservation not stored in session")
+	}
+	if res.FirstName != "Mary Ann" || res.LastName != "Smith" {
+		t.Errorf("name: got %q %q", res.FirstName, res.LastName)
+	}
+	if res.Email != "mary@smith.com" || res.Phone != "1234567891" {
+		t.Errorf("contact: got %q %q", res.Email, res.Phone)
+	}
+}
+
+// TestRepository_RoomPage iterates the configured room routes and verifies each
+// renders 200 with its own template, with or without room data from the DB.
+func TestRepository_RoomPage(t *testing.T) {
+	headings := map[string]string{
+		"golden-haybeam-loft.page.tmpl":  "Golden Haybeam Loft",
+		"window-perch-theater.page.tmpl": "Window Perch Theater",
+		"laundry-basket-nook.page.tmpl":  "Laundry-Basket Nook",
+	}
+	for _, page := range RoomRoutes {
+		t.Run(page.Path, func(t *testing.T) {
+			rr := do(Repo.RoomPage(page), newGET(page.Path))
+			mustStatus(t, rr, http.StatusOK)
+
+			heading := `<h1 class="fw-bold mb-1">` + headings[page.Template] + `</h1>`
+			if !strings.Contains(rr.Body.String(), heading) {
+				t.Fatalf("%s did not render %s", page.Path, page.Template)
+			}
+		})
+	}
+
+	t.Run("renders without room data", func(t *testing.T) {
+		page := RoomRoutes[0]
+		page.RoomID = 99
+		rr := do(Repo.RoomPage(page), newGET(page.Path))
+		mustStatus(t, rr, http.StatusOK)
+	})
+}
+
+// TestRepository_RoomBlockedAPI verifies the blocked-ranges endpoint: a range
+// holding one reservation and one owner block returns both with the right
+// type labels, and malformed input is rejected with 400 invalid_input.
+func TestRepository_RoomBlockedAPI(t *testing.T) {
+	withRoom := func(target, id string) *http.Request {
+		req := newGET(target)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", id)
+		return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	}
+
+	t.Run("reservation and block", func(t *testing.T) {
+		dbrepo.ForceHasReservationRestriction = true
+		defer func() { dbrepo.ForceHasReservationRestriction = false }()
+
+		rr := do(Repo.RoomBlockedAPI, withRoom("/api/rooms/1/blocked?start=2050-01-01&end=2050-01-31", "1"))
+		mustStatus(t, rr, http.StatusOK)
+
+		var got []blockedRange
+		if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		want := []blockedRange{
+			{Start: "2050-01-05", End: "2050-01-05", Type: "block"},
+			{Start: "2050-01-02", End: "2050-01-04", Type: "reservation"},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("ranges: got %+v, want %+v", got, want)
+		}
+	})
+
+	bad := []struct {
+		name   string
+		target string
+		id     string
+	}{
+		{name: "bad room", target: "/api/rooms/x/blocked?start=2050-01-01&end=2050-01-31", id: "x"},
+		{name: "missing start", target: "/api/rooms/1/blocked?end=2050-01-31", id: "1"},
+		{name: "bad end", target: "/api/rooms/1/blocked?start=2050-01-01&end=01/31/2050", id: "1"},
+		{name: "end before start", target: "/api/rooms/1/blocked?start=2050-01-31&end=2050-01-01", id: "1"},
+	}
+	for _, tc := range bad {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := do(Repo.RoomBlockedAPI, withRoom(tc.target, tc.id))
+			mustAPIError(t, rr, http.StatusBadRequest, errCodeInvalidInput)
+		})
+	}
+
+	t.Run("database error", func(t *testing.T) {
+		dbrepo.ForceRestrictionsErr = true
+		defer func() { dbrepo.ForceRestrictionsErr = false }()
+
+		rr := do(Repo.RoomBlockedAPI, withRoom("/api/rooms/1/blocked?start=2050-01-01&end=2050-01-31", "1"))
+		mustAPIError(t, rr, http.StatusInternalServerError, errCodeServer)
+	})
+}
+
+// TestRepository_AdminRoomCheck verifies the manual booking preview: a range
+// covering a known block reports it, in the HTML fragment and as JSON, while
+// a clear range reports no conflicts.
+func TestRepository_AdminRoomCheck(t *testing.T) {
+	dbrepo.ResetBlocks()
+	defer dbrepo.ResetBlocks()
+	if err := Repo.DB.InsertBlockForRoom(1, time.Date(2050, time.March, 10, 0, 0, 0, 0, time.UTC), 2, "Deep clean"); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(query, accept string) *httptest.ResponseRecorder {
+		req := newGET("/admin/rooms/1/check" + query)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", "1")
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		return do(Repo.AdminRoomCheck, req)
+	}
+
+	const blocked, clear = "?start=2050-03-08&end=2050-03-11", "?start=2050-03-01&end=2050-03-04"
+
+	t.Run("conflict fragment", func(t *testing.T) {
+		rr := check(blocked, "")
+		mustStatus(t, rr, http.StatusOK)
+		body := rr.Body.String()
+		for _, want := range []string{"Conflicts with 1 existing restriction", "2050-03-10 to 2050-03-11", "owner block", "Deep clean"} {
+			if !strings.Contains(body, want) {
+				t.Errorf("body missing %q:\n%s", want, body)
+			}
+		}
+	})
+
+	t.Run("conflict JSON", func(t *testing.T) {
+		rr := check(blocked, "application/json")
+		mustStatus(t, rr, http.StatusOK)
+		var got []roomConflict
+		if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		want := []roomConflict{{Start: "2050-03-10", End: "2050-03-11", Type: "block", Note: "Deep clean"}}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("conflicts: got %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("clear range", func(t *testing.T) {
+		rr := check(clear, "")
+		mustStatus(t, rr, http.StatusOK)
+		if !strings.Contains(rr.Body.String(), "No conflicts") {
+			t.Errorf("expected no conflicts, got:\n%s", rr.Body.String())
+		}
+
+		rr = check(clear, "application/json")
+		mustStatus(t, rr, http.StatusOK)
+		if got := strings.TrimSpace(rr.Body.String()); got != "[]" {
+			t.Errorf("JSON: got %s, want []", got)
+		}
+	})
+
+	t.Run("invalid range", func(t *testing.T) {
+		mustStatus(t, check("?start=2050-03-08&end=2050-03-08", ""), http.StatusBadRequest)
+		mustAPIError(t, check("?start=03/08/2050&end=2050-03-11", "application/json"), http.StatusBadRequest, errCodeInvalidInput)
+	})
+
+	t.Run("database error", func(t *testing.T) {
+		dbrepo.ForceRestrictionsErr = true
+		defer func() { dbrepo.ForceRestrictionsErr = false }()
+		mustStatus(t, check(blocked, ""), http.StatusInternalServerError)
+	})
+}
+
+// TestRepository_AvailabilityICal verifies the search-result calendar export:
+// a valid request yields one TENTATIVE all-day event spanning the stay, and
+// malformed input is rejected.
+func TestRepository_AvailabilityICal(t *testing.T) {
+	rr := do(Repo.AvailabilityICal, newGET("/search-availability.ics?room_id=1&start=01/10/2050&end=01/13/2050"))
+	mustStatus(t, rr, http.StatusOK)
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/calendar") {
+		t.Fatalf("Content-Type: got %q", ct)
+	}
+
+	// Unfold continuation lines, then index each property of the events.
+	body := strings.ReplaceAll(rr.Body.String(), "\r\n ", "")
+	var events []map[string]string
+	for _, line := range strings.Split(strings.TrimSuffix(body, "\r\n"), "\r\n") {
+		switch {
+		case line == "BEGIN:VEVENT":
+			events = append(events, map[string]string{})
+		case len(events) > 0 && line != "END:VEVENT" && line != "END:VCALENDAR":
+			name, value, _ := strings.Cut(line, ":")
+			events[len(events)-1][name] = value
+		}
+	}
+
+	if len(events) != 1 {
+		t.Fatalf("events: got %d, want 1", len(events))
+	}
+	e := events[0]
+	want := map[string]string{
+		"STATUS":             "TENTATIVE",
+		"DTSTART;VALUE=DATE": "20500110",
+		"DTEND;VALUE=DATE":   "20500113",
+	}
+	for k, v := range want {
+		if e[k] != v {
+			t.Errorf("%s: got %q, want %q", k, e[k], v)
+		}
+	}
+
+	bad := map[string]string{
+		"bad start":        "/search-availability.ics?room_id=1&start=2050-01-10&end=01/13/2050",
+		"end before start": "/search-availability.ics?room_id=1&start=01/13/2050&end=01/10/2050",
+		"bad room":         "/search-availability.ics?room_id=x&start=01/10/2050&end=01/13/2050",
+	}
+	for name, target := range bad {
+		t.Run(name, func(t *testing.T) {
+			mustStatus(t, do(Repo.AvailabilityICal, newGET(target)), http.StatusBadRequest)
+		})
+	}
+}
+
+// TestBuildICal_FoldRunes verifies that long lines are folded at 75 octets
+// or less without splitting a multi-byte character, and unfold to the
+// original text.
+func TestBuildICal_FoldRunes(t *testing.T) {
+	summary := "Chambre " + strings.Repeat("é", 80)
+	out := buildICal([]icalEvent{{UID: "1@test", Summary: summary, Start: time.Now(), End: time.Now()}}, time.Now())
+
+	for _, line := range strings.Split(out, "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("line longer than 75 octets: %q", line)
+		}
+		if !utf8.ValidString(line) {
+			t.Errorf("line is not valid UTF-8: %q", line)
+		}
+	}
+	if !strings.Contains(strings.ReplaceAll(out, "\r\n ", ""), "SUMMARY:"+icalEscaper.Replace(summary)+"\r\n") {
+		t.Error("unfolded summary does not match the original")
+	}
+}
+
+// loginCookie seeds a session holding user_id and returns the cookie that
+// carries it, so requests through the router arrive logged in.
+func loginCookie(t *testing.T, userID int) *http.Cookie {
+	t.Helper()
+	ctx, err := session.Load(context.Background(), "")
+	if err != nil {
+		t.Fatalf("load session: %v", err)
+	}
+	session.Put(ctx, "user_id", userID)
+	token, _, err := session.Commit(ctx)
+	if err != nil {
+		t.Fatalf("commit session: %v", err)
+	}
+	return &http.Cookie{Name: session.Cookie.Name, Value: token}
+}
+
+// TestAuthRoutes_RedirectsLoggedOut verifies that the auth-gated router sends
+// logged-out requests for admin pages and admin JSON to the login page with
+// the "Log in first!" message, without running the handler.
+func TestAuthRoutes_RedirectsLoggedOut(t *testing.T) {
+	routes := getAuthRoutes()
+
+	for _, path := range []string{"/admin/dashboard", "/admin/reservations", "/admin/api/reservations/1"} {
+		t.Run(path, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			routes.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+			mustStatus(t, rr, http.StatusSeeOther)
+			if loc := rr.Header().Get("Location"); loc != "/user/login" {
+				t.Fatalf("Location: got %q, want /user/login", loc)
+			}
+
+			var token string
+			for _, c := range rr.Result().Cookies() {
+				if c.Name == session.Cookie.Name {
+					token = c.Value
+				}
+			}
+			if token == "" {
+				t.Fatal("no session cookie set")
+			}
+			ctx, err := session.Load(context.Background(), token)
+			if err != nil {
+				t.Fatalf("load session: %v", err)
+			}
+			if msg := session.GetString(ctx, "error"); msg != "Log in first!" {
+				t.Errorf("error message: got %q", msg)
+			}
+		})
+	}
+}
+
+// TestAuthRoutes_AllowsLoggedIn verifies that a session holding user_id
+// passes the gate and reaches the admin handlers.
+func TestAuthRoutes_AllowsLoggedIn(t *testing.T) {
+	routes := getAuthRoutes()
+	cookie := loginCookie(t, 1)
+
+	for _, path := range []string{"/admin/dashboard", "/admin/reservations", "/admin/api/reservations/1"} {
+		t.Run(path, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req.AddCookie(cookie)
+			rr := httptest.NewRecorder()
+			routes.ServeHTTP(rr, req)
+			mustStatus(t, rr, http.StatusOK)
+		})
+	}
+
+	// Public pages stay open either way.
+	rr := httptest.NewRecorder()
+	routes.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/about", nil))
+	mustStatus(t, rr, http.StatusOK)
+}
+
+// TestRepository_SessionStatus verifies that GET /api/session/status reports
+// the login state of the session and always hands out a CSRF token, through
+// the router so the CSRF and session middleware run.
+func TestRepository_SessionStatus(t *testing.T) {
+	routes := getRoutes()
+
+	tests := []struct {
+		name     string
+		cookie   *http.Cookie
+		wantAuth bool
+	}{
+		{"anonymous", nil, false},
+		{"logged in", loginCookie(t, 1), true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/session/status", nil)
+			if tc.cookie != nil {
+				req.AddCookie(tc.cookie)
+			}
+			rr := httptest.NewRecorder()
+			routes.ServeHTTP(rr, req)
+			mustStatus(t, rr, http.StatusOK)
+
+			if cc := rr.Header().Get("Cache-Control"); cc != "no-store" {
+				t.Errorf("Cache-Control: got %q, want no-store", cc)
+			}
+			var got sessionStatus
+			if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+				t.Fatalf("body is not JSON: %v (%q)", err, rr.Body.String())
+			}
+			if got.Authenticated != tc.wantAuth {
+				t.Errorf("authenticated: got %v, want %v", got.Authenticated, tc.wantAuth)
+			}
+			if got.CSRFToken == "" {
+				t.Error("csrf_token is empty")
+			}
+		})
+	}
+}
+
+// TestAPIMethodNotAllowed verifies that a wrong-method request to an /api
+// route gets a JSON 405 envelope with an Allow header listing the accepted
+// methods, while other routes keep chi's default empty 405.
+func TestAPIMethodNotAllowed(t *testing.T) {
+	routes := getRoutes()
+
+	// A JSON client, so the CSRF check lets the POST through to the router.
+	req := httptest.NewRequest(http.MethodPost, "/api/rooms/1/blocked", nil)
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	routes.ServeHTTP(rr, req)
+	mustStatus(t, rr, http.StatusMethodNotAllowed)
+	if got := rr.Header().Values("Allow"); !reflect.DeepEqual(got, []string{http.MethodGet}) {
+		t.Errorf("Allow: got %v, want [GET]", got)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type: got %q", ct)
+	}
+	var body apiError
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("body is not JSON: %v (%q)", err, rr.Body.String())
+	}
+	if body.Code != errCodeMethodNotAllowed || !strings.Contains(body.Message, "POST") {
+		t.Errorf("body: got %+v", body)
+	}
+
+	rr = httptest.NewRecorder()
+	routes.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/quote", nil))
+	mustStatus(t, rr, http.StatusMethodNotAllowed)
+	if got := rr.Header().Get("Allow"); got != http.MethodPost {
+		t.Errorf("Allow for /api/quote: got %q, want POST", got)
+	}
+
+	// Outside /api the default handler still answers, with no body.
+	rr = httptest.NewRecorder()
+	routes.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/search-availability-json", nil))
+	mustStatus(t, rr, http.StatusMethodNotAllowed)
+	if rr.Body.Len() != 0 || rr.Header().Get("Content-Type") == "application/json" {
+		t.Errorf("non-API 405 should be chi's default, got %q", rr.Body.String())
+	}
+	if got := rr.Header().Get("Allow"); got != http.MethodPost {
+		t.Errorf("non-API Allow: got %q, want POST", got)
+	}
+}
+
+// TestRepository_QuoteAPI verifies the dry-run quote endpoint: an available
+// stay is priced per night, a stay that breaks a policy reports which rule
+// failed, and malformed input is rejected with a 400 invalid_input envelope.
+func TestRepository_QuoteAPI(t *testing.T) {
+	t.Run("available priced quote", func(t *testing.T) {
+		req := newPOSTForm("/api/quote", url.Values{
+			"room_id": {"1"}, "start": {"01/01/2101"}, "end": {"01/03/2101"}, "guests": {"2"},
+		})
+		rr := do(Repo.QuoteAPI, req)
+		mustStatus(t, rr, http.StatusOK)
+
+		var q reservationQuote
+		if err := json.Unmarshal(rr.Body.Bytes(), &q); err != nil {
+			t.Fatal(err)
+		}
+		if !q.OK || !q.Available {
+			t.Fatalf("expected ok and available quote, got %+v", q)
+		}
+		if q.Nights != 2 || len(q.PerNight) != 2 {
+			t.Fatalf("nights: got %d (%d priced), want 2", q.Nights, len(q.PerNight))
+		}
+		if q.PerNight[0].Date != "01/01/2101" || q.PerNight[0].Rate != dbrepo.TestBaseRate {
+			t.Errorf("first night: got %+v", q.PerNight[0])
+		}
+		if q.Total != 2*dbrepo.TestBaseRate {
+			t.Errorf("total: got %d, want %d", q.Total, 2*dbrepo.TestBaseRate)
+		}
+		for _, p := range q.Policies {
+			if !p.Passed {
+				t.Errorf("policy %s failed: %s", p.Name, p.Message)
+			}
+		}
+	})
+
+	t.Run("policy violations", func(t *testing.T) {
+		req := newPOSTForm("/api/quote", url.Values{
+			"room_id": {"1"}, "start": {"01/01/2101"}, "end": {"01/01/2101"},
+			"guests": {strconv.Itoa(dbrepo.TestMaxGuests + 1)},
+		})
+		rr := do(Repo.QuoteAPI, req)
+		mustStatus(t, rr, http.StatusOK)
+
+		var q reservationQuote
+		if err := json.Unmarshal(rr.Body.Bytes(), &q); err != nil {
+			t.Fatal(err)
+		}
+		if q.OK {
+			t.Fatal("expected quote to fail policies")
+		}
+		failed := map[string]bool{}
+		for _, p := range q.Policies {
+			if !p.Passed {
+				failed[p.Name] = true
+			}
+		}
+		if !failed["min_stay"] || !failed["capacity"] || failed["max_stay"] {
+			t.Fatalf("failed policies: got %v, want min_stay and capacity", failed)
+		}
+		if len(q.PerNight) != 0 || q.Total != 0 {
+			t.Errorf("rejected stay should not be priced, got %+v", q.PerNight)
+		}
+	})
+
+	t.Run("bad input", func(t *testing.T) {
+		for _, form := range []url.Values{
+			{"room_id": {"1"}, "start": {"nope"}, "end": {"01/03/2101"}},
+			{"room_id": {"x"}, "start": {"01/01/2101"}, "end": {"01/03/2101"}},
+			{"room_id": {"1"}, "start": {"01/01/2101"}, "end": {"01/03/2101"}, "guests": {"two"}},
+		} {
+			rr := do(Repo.QuoteAPI, newPOSTForm("/api/quote", form))
+			mustAPIError(t, rr, http.StatusBadRequest, errCodeInvalidInput)
+		}
+	})
+
+	t.Run("unknown room", func(t *testing.T) {
+		req := newPOSTForm("/api/quote", url.Values{"room_id": {"100"}, "start": {"01/01/2101"}, "end": {"01/03/2101"}})
+		rr := do(Repo.QuoteAPI, req)
+		mustAPIError(t, rr, http.StatusInternalServerError, errCodeServer)
+	})
+}
+
+// TestRepository_PostReservation_PolicyViolation verifies that PostReservation
+// applies the same booking rules as the quote endpoint and refuses oversize parties.
+func TestRepository_PostReservation_PolicyViolation(t *testing.T) {
+	req := newPOSTForm("/make-reservation", toForm(map[string]string{
+		"start_date":   "01/01/2100",
+		"end_date":     "01/02/2100",
+		"first_name":   "John",
+		"last_name":    "Smith",
+		"email":        "john@smith.com",
+		"phone":        "1234567891",
+		"accept_terms": "1",
+		"room_id":      "1",
+		"guests":       strconv.Itoa(dbrepo.TestMaxGuests + 1),
+	}))
+	rr := do(Repo.PostReservation, req)
+	mustStatus(t, rr, http.StatusSeeOther)
+	mustRedirectContains(t, rr, "/")
+
+	if msg := session.GetString(req.Context(), "error"); !strings.Contains(msg, "sleeps") {
+		t.Fatalf("error flash: got %q", msg)
+	}
+	if session.Exists(req.Context(), "reservation") {
+		t.Fatal("reservation should not be stored")
+	}
+}
+
+// TestRepository_AdminNewUser verifies that the staff account form is only
+// available at the top access level.
+func TestRepository_AdminNewUser(t *testing.T) {
+	req := newGET("/admin/users/new")
+	session.Put(req.Context(), "user_id", dbrepo.TestAdminUserID)
+	rr := do(Repo.AdminNewUser, req)
+	mustStatus(t, rr, http.StatusOK)
+
+	req = newGET("/admin/users/new")
+	session.Put(req.Context(), "user_id", dbrepo.TestAdminUserID+1)
+	rr = do(Repo.AdminNewUser, req)
+	mustStatus(t, rr, http.StatusForbidden)
+}
+
+// TestRepository_AdminPostNewUser covers staff account creation: a new email
+// creates the account, a generated password is rendered once in the response
+// and never stored in the session, an existing email (in any case) is
+// rejected on the form, invalid input re-renders, and lower access levels are
+// refused.
+func TestRepository_AdminPostNewUser(t *testing.T) {
+	valid := func(email string) url.Values {
+		return url.Values{
+			"first_name":   {"New"},
+			"last_name":    {"Staff"},
+			"email":        {email},
+			"access_level": {"1"},
+		}
+	}
+
+	tests := []struct {
+		name       string
+		userID     int
+		form       url.Values
+		wantStatus int
+		wantBody   string
+		wantFlash  string
+	}{
+		{
+			name:       "creates account with generated password",
+			userID:     dbrepo.TestAdminUserID,
+			form:       valid("new.staff@example.com"),
+			wantStatus: http.StatusOK,
+			wantBody:   "Temporary password: <code>",
+		},
+		{
+			name:   "creates account with given password",
+			userID: dbrepo.TestAdminUserID,
+			form: func() url.Values {
+				f := valid("given@example.com")
+				f.Set("password", "correct-horse-battery")
+				return f
+			}(),
+			wantStatus: http.StatusSeeOther,
+			wantFlash:  "Account created for given@example.com",
+		},
+		{
+			name:       "duplicate email rejected",
+			userID:     dbrepo.TestAdminUserID,
+			form:       valid(strings.ToUpper(dbrepo.TestExistingUserEmail)),
+			wantStatus: http.StatusOK,
+			wantBody:   "A user with this email already exists",
+		},
+		{
+			name:   "short password rejected",
+			userID: dbrepo.TestAdminUserID,
+			form: func() url.Values {
+				f := valid("short@example.com")
+				f.Set("password", "abc")
+				return f
+			}(),
+			wantStatus: http.StatusOK,
+			wantBody:   "Password must be at least",
+		},
+		{
+			name:       "lower access level forbidden",
+			userID:     dbrepo.TestAdminUserID + 1,
+			form:       valid("other@example.com"),
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "anonymous forbidden",
+			form:       valid("other@example.com"),
+			wantStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dbrepo.ResetUsers()
+			defer dbrepo.ResetUsers()
+
+			req := newPOSTForm("/admin/users/new", tc.form)
+			if tc.userID != 0 {
+				session.Put(req.Context(), "user_id", tc.userID)
+			}
+			rr := do(Repo.AdminPostNewUser, req)
+			mustStatus(t, rr, tc.wantStatus)
+
+			if tc.wantBody != "" && !strings.Contains(rr.Body.String(), tc.wantBody) {
+				t.Fatalf("body missing %q", tc.wantBody)
+			}
+			if strings.Contains(tc.wantBody, "Temporary password") {
+				if got := rr.Header().Get("Cache-Control"); got != "no-store" {
+					t.Errorf("Cache-Control: got %q, want no-store", got)
+				}
+				if flash := session.GetString(req.Context(), "flash"); flash != "" {
+					t.Errorf("password page should not leave a flash in the session: %q", flash)
+				}
+			}
+			if tc.wantFlash != "" {
+				mustRedirectContains(t, rr, "/admin/users/new")
+				if flash := session.GetString(req.Context(), "flash"); !strings.Contains(flash, tc.wantFlash) {
+					t.Fatalf("flash: got %q, want %q", flash, tc.wantFlash)
+				}
+				if _, err := Repo.DB.GetUserByEmail(tc.form.Get("email")); err != nil {
+					t.Fatalf("created user not found: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// TestRepository_AdminPostNewUser_WelcomeEmail verifies that the welcome
+// email links to BASE_URL rather than the request's Host, and that no email
+// is sent when BASE_URL is not configured.
+func TestRepository_AdminPostNewUser_WelcomeEmail(t *testing.T) {
+	for _, baseURL := range []string{"https://milosresidence.com", ""} {
+		t.Run("base "+baseURL, func(t *testing.T) {
+			dbrepo.ResetUsers()
+			defer dbrepo.ResetUsers()
+
+			repo, mail := newMailCaptureRepo()
+			repo.App.BaseURL = baseURL
+			req := newPOSTForm("/admin/users/new", url.Values{
+				"first_name":   {"New"},
+				"last_name":    {"Staff"},
+				"email":        {"welcome@example.com"},
+				"password":     {"correct-horse-battery"},
+				"access_level": {"1"},
+			})
+			req.Host = "attacker.example"
+			session.Put(req.Context(), "user_id", dbrepo.TestAdminUserID)
+			rr := do(repo.AdminPostNewUser, req)
+			mustStatus(t, rr, http.StatusSeeOther)
+
+			msgs := mail.sent()
+			if baseURL == "" {
+				if len(msgs) != 0 {
+					t.Fatalf("mails: got %d, want 0", len(msgs))
+				}
+				return
+			}
+			if len(msgs) != 1 {
+				t.Fatalf("mails: got %d, want 1", len(msgs))
+			}
+			if !strings.Contains(msgs[0].Content, `href="https://milosresidence.com/user/login"`) || strings.Contains(msgs[0].Content, "attacker.example") {
+				t.Errorf("welcome links: %s", msgs[0].Content)
+			}
+		})
+	}
+}
+
+// TestRepository_PostForgotPassword verifies that a known email is sent a
+// reset link built from BASE_URL (never the request Host) while an unknown
+// one is not, that nothing is sent without BASE_URL, and that all get the
+// same redirect and flash so the form doesn't reveal which emails have
+// accounts.
+func TestRepository_PostForgotPassword(t *testing.T) {
+	tests := []struct {
+		name      string
+		email     string
+		tokenErr  bool
+		wantMails int
+		baseURL   string
+	}{
+		{name: "known email", email: strings.ToUpper(dbrepo.TestExistingUserEmail), wantMails: 1, baseURL: "https://milosresidence.com/"},
+		{name: "unknown email", email: "nobody@example.com", baseURL: "https://milosresidence.com"},
+		{name: "token error", email: dbrepo.TestExistingUserEmail, tokenErr: true, baseURL: "https://milosresidence.com"},
+		{name: "no base url", email: dbrepo.TestExistingUserEmail},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dbrepo.ResetResetTokens()
+			defer dbrepo.ResetResetTokens()
+			dbrepo.ForceResetTokenErr = tc.tokenErr
+			defer func() { dbrepo.ForceResetTokenErr = false }()
+
+			repo, mail := newMailCaptureRepo()
+			repo.App.BaseURL = tc.baseURL
+			req := newPOSTForm("/user/forgot-password", url.Values{"email": {tc.email}})
+			req.Host = "attacker.example"
+			rr := do(repo.PostForgotPassword, req)
+			mustStatus(t, rr, http.StatusSeeOther)
+			mustRedirectContains(t, rr, "/user/login")
+			if flash := session.GetString(req.Context(), "flash"); flash != forgotPasswordFlash {
+				t.Errorf("flash: got %q", flash)
+			}
+
+			msgs := mail.sent()
+			if len(msgs) != tc.wantMails {
+				t.Fatalf("mails: got %d, want %d", len(msgs), tc.wantMails)
+			}
+			if tc.wantMails == 1 {
+				if msgs[0].To != dbrepo.TestExistingUserEmail {
+					t.Errorf("To: got %q", msgs[0].To)
+				}
+				if !strings.Contains(msgs[0].Content, "https://milosresidence.com/user/reset-password/reset-1-") {
+					t.Errorf("reset link missing: %s", msgs[0].Content)
+				}
+				if strings.Contains(msgs[0].Content, "attacker.example") {
+					t.Errorf("reset link uses the request Host: %s", msgs[0].Content)
+				}
+				if !msgs[0].Sensitive {
+					t.Error("reset email must be marked sensitive so it is never dead-lettered")
+				}
+			}
+		})
+	}
+
+	rr := do(Repo.PostForgotPassword, newPOSTForm("/user/forgot-password", url.Values{"email": {"not-an-email"}}))
+	mustStatus(t, rr, http.StatusOK)
+	if !strings.Contains(rr.Body.String(), "Invalid email address") {
+		t.Error("expected an email validation error")
+	}
+}
+
+// TestRepository_PostResetPassword verifies the reset form: a mismatched
+// password re-renders without using the token, a valid one sets the password
+// and uses it up, and a used or unknown token is sent back to request a new
+// link.
+func TestRepository_PostResetPassword(t *testing.T) {
+	dbrepo.ResetResetTokens()
+	defer dbrepo.ResetResetTokens()
+	dbrepo.ResetPasswords()
+	defer dbrepo.ResetPasswords()
+
+	token, err := Repo.DB.CreateResetToken(dbrepo.TestAdminUserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	post := func(token, password, confirm string) (*httptest.ResponseRecorder, *http.Request) {
+		req := newPOSTForm("/user/reset-password/"+token, url.Values{
+			"password":         {password},
+			"password_confirm": {confirm},
+		})
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("token", token)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		return do(Repo.PostResetPassword, req), req
+	}
+
+	rr, _ := post(token, "new-password", "other-password")
+	mustStatus(t, rr, http.StatusOK)
+	if !strings.Contains(rr.Body.String(), "Passwords don&#39;t match") {
+		t.Error("expected a mismatch error")
+	}
+
+	rr, _ = post(token, "short", "short")
+	mustStatus(t, rr, http.StatusOK)
+	if !strings.Contains(rr.Body.String(), "Password must be at least") {
+		t.Error("expected a length error")
+	}
+
+	rr, req := post(token, "new-password", "new-password")
+	mustStatus(t, rr, http.StatusSeeOther)
+	mustRedirectContains(t, rr, "/user/login")
+	if got, _ := dbrepo.PasswordFor(dbrepo.TestAdminUserID); got != "new-password" {
+		t.Errorf("password: got %q, want new-password", got)
+	}
+	if flash := session.GetString(req.Context(), "flash"); !strings.Contains(flash, "password has been reset") {
+		t.Errorf("flash: got %q", flash)
+	}
+
+	for _, reused := range []string{token, "unknown-token"} {
+		rr, req = post(reused, "another-password", "another-password")
+		mustStatus(t, rr, http.StatusSeeOther)
+		mustRedirectContains(t, rr, "/user/forgot-password")
+		if msg := session.GetString(req.Context(), "error"); !strings.Contains(msg, "invalid or has expired") {
+			t.Errorf("%s: error flash %q", reused, msg)
+		}
+	}
+	if got, _ := dbrepo.PasswordFor(dbrepo.TestAdminUserID); got != "new-password" {
+		t.Errorf("password changed by a used token: got %q", got)
+	}
+
+	dbrepo.ForceResetTokenErr = true
+	defer func() { dbrepo.ForceResetTokenErr = false }()
+	rr, _ = post("any-token", "another-password", "another-password")
+	mustStatus(t, rr, http.StatusInternalServerError)
+}
+
+// TestRepository_PostReservation_Duplicate verifies that a booking matching an
+// existing reservation for the same guest, room and dates is refused before
+// anything is inserted or mailed, while a unique booking goes through.
+func TestRepository_PostReservation_Duplicate(t *testing.T) {
+	tests := []struct {
+		name      string
+		email     string
+		existsErr bool
+		wantLoc   string
+		wantError string
+		wantMails int
+	}{
+		{name: "unique booking", email: "john@smith.com", wantLoc: "/reservation-summary", wantMails: 2},
+		{name: "duplicate booking", email: strings.ToUpper(dbrepo.TestDuplicateEmail), wantLoc: "/", wantError: "You already have a booking for these dates."},
+		{name: "lookup error", email: "john@smith.com", existsErr: true, wantLoc: "/", wantError: "can't check for existing reservations!"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dbrepo.ForceReservationExistsErr = tc.existsErr
+			defer func() { dbrepo.ForceReservationExistsErr = false }()
+
+			repo, mailChan := newMailCaptureRepo()
+			req := newPOSTForm("/make-reservation", toForm(map[string]string{
+				"start_date":   "01/01/2100",
+				"end_date":     "01/02/2100",
+				"first_name":   "John",
+				"last_name":    "Smith",
+				"email":        tc.email,
+				"phone":        "1234567891",
+				"accept_terms": "1",
+				"room_id":      "1",
+			}))
+			rr := do(repo.PostReservation, req)
+			mustStatus(t, rr, http.StatusSeeOther)
+			if loc := rr.Header().Get("Location"); loc != tc.wantLoc {
+				t.Fatalf("Location: got %q, want %q", loc, tc.wantLoc)
+			}
+			if got := session.GetString(req.Context(), "error"); got != tc.wantError {
+				t.Errorf("session error: got %q, want %q", got, tc.wantError)
+			}
+			if len(mailChan) != tc.wantMails {
+				t.Errorf("queued mails: got %d, want %d", len(mailChan), tc.wantMails)
+			}
+		})
+	}
+}
+
+// TestMessages verifies catalog lookup: known locales get their own strings,
+// region and case are not significant to requestLocale, and unknown locales
+// and strings missing from a stub locale fall back to en.
+func TestMessages(t *testing.T) {
+	en, es := messages("en"), messages("es")
+	if en.ConfirmationSubject == es.ConfirmationSubject {
+		t.Fatalf("es confirmation subject should be translated, got %q", es.ConfirmationSubject)
+	}
+	if got := messages("xx"); got != en {
+		t.Errorf("unknown locale: got %+v, want en", got)
+	}
+	if es.NotificationSubject != en.NotificationSubject {
+		t.Errorf("es notification subject: got %q, want en fallback %q", es.NotificationSubject, en.NotificationSubject)
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		accept string
+		want   string
+	}{
+		{"no preference", "/", "", "en"},
+		{"lang param", "/?lang=es", "", "es"},
+		{"lang param beats header", "/?lang=EN", "es", "en"},
+		{"unknown lang param uses header", "/?lang=fr", "es-MX,es;q=0.9", "es"},
+		{"header weights", "/", "fr;q=1, en;q=0.5, es;q=0.8", "es"},
+		{"unsupported header", "/", "de-DE,fr;q=0.8", "en"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.target, nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept-Language", tc.accept)
+			}
+			if got := requestLocale(req); got != tc.want {
+				t.Errorf("requestLocale: got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestRepository_PostReservation_CheckInOutTimes verifies that the configured
+// check-in and check-out times appear in the guest confirmation email.
+func TestRepository_PostReservation_CheckInOutTimes(t *testing.T) {
+	repo, mailChan := newMailCaptureRepo()
+	repo.App.CheckInTime = "4:30 PM"
+	repo.App.CheckOutTime = "10:00 AM"
+
+	req := newPOSTForm("/make-reservation", toForm(map[string]string{
+		"start_date":   "01/01/2100",
+		"end_date":     "01/02/2100",
+		"first_name":   "John",
+		"last_name":    "Smith",
+		"email":        "john@smith.com",
+		"phone":        "1234567891",
+		"accept_terms": "1",
+		"room_id":      "1",
+	}))
+	rr := do(repo.PostReservation, req)
+	mustStatus(t, rr, http.StatusSeeOther)
+
+	if len(mailChan) == 0 {
+		t.Fatal("no confirmation queued")
+	}
+	guest := <-mailChan
+	for _, want := range []string{"4:30 PM", "10:00 AM"} {
+		if !strings.Contains(guest.Content, want) || strings.Contains(guest.Content, "%!") {
+			t.Errorf("confirmation missing %q: %s", want, guest.Content)
+		}
+	}
+}
+
+// TestRepository_PostReservation_Locale verifies that the guest confirmation
+// follows the request locale, falls back to English for unknown locales, and
+// that the staff notification stays in English either way.
+func TestRepository_PostReservation_Locale(t *testing.T) {
+	tests := []struct {
+		name   string
+		lang   string
+		accept string
+		want   string
+	}{
+		{"default", "", "", "en"},
+		{"lang param", "es", "", "es"},
+		{"accept-language", "", "es-ES,es;q=0.9", "es"},
+		{"unknown locale", "zz", "", "en"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo, mailChan := newMailCaptureRepo()
+
+			req := newPOSTForm("/make-reservation?lang="+tc.lang, toForm(map[string]string{
+				"start_date":   "01/01/2100",
+				"end_date":     "01/02/2100",
+				"first_name":   "John",
+				"last_name":    "Smith",
+				"email":        "john@smith.com",
+				"phone":        "1234567891",
+				"accept_terms": "1",
+				"room_id":      "1",
+			}))
+			if tc.accept != "" {
+				req.Header.Set("Accept-Language", tc.accept)
+			}
+			rr := do(repo.PostReservation, req)
+			mustStatus(t, rr, http.StatusSeeOther)
+
+			if len(mailChan) != 2 {
+				t.Fatalf("queued mails: got %d, want 2", len(mailChan))
+			}
+			want := messages(tc.want)
+			guest, staff := <-mailChan, <-mailChan
+			if guest.Subject != want.ConfirmationSubject {
+				t.Errorf("guest subject: got %q, want %q", guest.Subject, want.ConfirmationSubject)
+			}
+			if _, body := want.confirmation("John", config.DefaultPropertyName, "01/01/2100", "01/02/2100", config.DefaultCheckInTime, config.DefaultCheckOutTime); guest.Content != body {
+				t.Errorf("guest body: got %q, want %q", guest.Content, body)
+			}
+			if staff.Subject != messages("en").NotificationSubject {
+				t.Errorf("staff subject: got %q, want English", staff.Subject)
+			}
+		})
+	}
+}
+
+// TestRepository_AdminPostSettings verifies that saving the settings form
+// stores only the values that differ from those in effect, replaces values
+// already cached by earlier reads, and routes staff notifications to the
+// saved addresses; the form shows the effective notification recipient,
+// invalid input re-renders the form and non-admins are refused.
+func TestRepository_AdminPostSettings(t *testing.T) {
+	dbrepo.ResetSettings()
+	defer dbrepo.ResetSettings()
+
+	repo, mailChan := newMailCaptureRepo()
+
+	// Prime the cache with the defaults.
+	if in, out := repo.checkInOutTimes(); in != config.DefaultCheckInTime || out != config.DefaultCheckOutTime {
+		t.Fatalf("defaults: got %q/%q", in, out)
+	}
+
+	valid := url.Values{
+		"notification_emails": {"front@example.com, owner@example.com"},
+		"min_stay_nights":     {"2"},
+		"max_stay_nights":     {"14"},
+		"check_in_time":       {"4:00 PM"},
+		"check_out_time":      {"10:00 AM"},
+	}
+
+	req := newPOSTForm("/admin/settings", valid)
+	session.Put(req.Context(), "user_id", dbrepo.TestAdminUserID+1)
+	rr := do(repo.AdminPostSettings, req)
+	mustStatus(t, rr, http.StatusForbidden)
+
+	invalid := toForm(map[string]string{
+		"notification_emails": "front@example.com",
+		"min_stay_nights":     "7",
+		"max_stay_nights":     "3",
+		"check_in_time":       "4:00 PM",
+		"check_out_time":      "10:00 AM",
+	})
+	req = newPOSTForm("/admin/settings", invalid)
+	session.Put(req.Context(), "user_id", dbrepo.TestAdminUserID)
+	rr = do(repo.AdminPostSettings, req)
+	mustStatus(t, rr, http.StatusOK)
+	if !strings.Contains(rr.Body.String(), "shorter than the minimum") {
+		t.Error("expected stay limit error on the re-rendered form")
+	}
+
+	// The form starts out showing the values in effect, including the
+	// address notifications go to before any are saved.
+	req = newGET("/admin/settings")
+	session.Put(req.Context(), "user_id", dbrepo.TestAdminUserID)
+	rr = do(repo.AdminSettings, req)
+	mustStatus(t, rr, http.StatusOK)
+	if want := `value="` + repo.contactRecipient(notificationFallbackTopic) + `"`; !strings.Contains(rr.Body.String(), want) {
+		t.Errorf("settings page should show the effective recipient %s", want)
+	}
+
+	// Submitting the form with only the check-in time changed saves just that.
+	current := repo.settingsForm()
+	partial := url.Values{}
+	for _, key := range settingKeys {
+		partial.Set(key, current.Get(key))
+	}
+	partial.Set("check_in_time", "2:00 PM")
+	req = newPOSTForm("/admin/settings", partial)
+	session.Put(req.Context(), "user_id", dbrepo.TestAdminUserID)
+	mustStatus(t, do(repo.AdminPostSettings, req), http.StatusSeeOther)
+	for _, key := range settingKeys {
+		_, err := repo.DB.GetSetting(key)
+		if saved := err == nil; saved != (key == "check_in_time") {
+			t.Errorf("%s saved: got %v, want %v", key, saved, key == "check_in_time")
+		}
+	}
+
+	req = newPOSTForm("/admin/settings", valid)
+	session.Put(req.Context(), "user_id", dbrepo.TestAdminUserID)
+	rr = do(repo.AdminPostSettings, req)
+	mustStatus(t, rr, http.StatusSeeOther)
+	mustRedirectContains(t, rr, "/admin/settings")
+
+	if in, out := repo.checkInOutTimes(); in != "4:00 PM" || out != "10:00 AM" {
+		t.Errorf("check-in/out after save: got %q/%q", in, out)
+	}
+	if minNights, maxNights := repo.stayLimits(); minNights != 2 || maxNights != 14 {
+		t.Errorf("stay limits after save: got %d-%d", minNights, maxNights)
+	}
+
+	req = newGET("/admin/settings")
+	session.Put(req.Context(), "user_id", dbrepo.TestAdminUserID)
+	rr = do(repo.AdminSettings, req)
+	mustStatus(t, rr, http.StatusOK)
+	if !strings.Contains(rr.Body.String(), `value="4:00 PM"`) {
+		t.Error("settings page should show the saved check-in time")
+	}
+
+	req = newPOSTForm("/make-reservation", toForm(map[string]string{
+		"start_date":   "01/01/2100",
+		"end_date":     "01/03/2100",
+		"first_name":   "John",
+		"last_name":    "Smith",
+		"email":        "john@smith.com",
+		"phone":        "1234567891",
+		"accept_terms": "1",
+		"room_id":      "1",
+	}))
+	rr = do(repo.PostReservation, req)
+	mustStatus(t, rr, http.StatusSeeOther)
+
+	var to []string
+	for _, msg := range mailChan.sent() {
+		to = append(to, msg.To)
+	}
+	want := []string{"john@smith.com", "front@example.com", "owner@example.com"}
+	if !reflect.DeepEqual(to, want) {
+		t.Errorf("recipients: got %v, want %v", to, want)
+	}
+}
+
+// TestRepository_Rooms verifies that the rooms page shows each room's next
+// available night, skipping past a week of blocks starting today, and that a
+// failed availability lookup is a server error.
+func TestRepository_Rooms(t *testing.T) {
+	dbrepo.ResetBlocks()
+	defer dbrepo.ResetBlocks()
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	for d := 0; d < 7; d++ {
+		if err := Repo.DB.InsertBlockForRoom(1, today.AddDate(0, 0, d), 2, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rr := do(Repo.Rooms, newGET("/rooms"))
+	mustStatus(t, rr, http.StatusOK)
+	body := rr.Body.String()
+	if !strings.Contains(body, `<a href="/golden-haybeam-loft">Golden Haybeam Loft</a>`) {
+		t.Error("rooms page should link to the room page")
+	}
+	if want := render.PrettyDate(today.AddDate(0, 0, 7)); !strings.Contains(body, want) {
+		t.Errorf("rooms page should show next available %q", want)
+	}
+
+	dbrepo.ForceNextAvailableErr = true
+	defer func() { dbrepo.ForceNextAvailableErr = false }()
+	rr = do(Repo.Rooms, newGET("/rooms"))
+	mustStatus(t, rr, http.StatusInternalServerError)
+}
+
+// TestRepository_PostFlexibleAvailability searches a future March with the
+// room blocked from the 10th to the 14th and checks the results page lists
+// the free stretches either side with booking links, and that bad input is
+// sent back to the search form.
+func TestRepository_PostFlexibleAvailability(t *testing.T) {
+	dbrepo.ResetBlocks()
+	defer dbrepo.ResetBlocks()
+
+	for d := 10; d < 15; d++ {
+		if err := Repo.DB.InsertBlockForRoom(1, time.Date(2100, time.March, d, 0, 0, 0, 0, time.UTC), 2, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rr := do(Repo.PostFlexibleAvailability, newPOSTForm("/search-flexible", url.Values{"month": {"2100-03"}, "nights": {"3"}}))
+	mustStatus(t, rr, http.StatusOK)
+	body := rr.Body.String()
+	for _, want := range []string{
+		"3 nights in March 2100",
+		"Golden Haybeam Loft",
+		"Mar 1 – 10, 2100 (9 nights free)",
+		"Mar 15 – Apr 1, 2100 (17 nights free)",
+		`href="/book-room?e=03%2F04%2F2100&amp;id=1&amp;s=03%2F01%2F2100"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("results missing %q", want)
+		}
+	}
+	if strings.Contains(body, "Mar 10 –") {
+		t.Error("results should not offer the booked nights")
+	}
+
+	// Longer than either gap: the page says nothing fits.
+	rr = do(Repo.PostFlexibleAvailability, newPOSTForm("/search-flexible", url.Values{"month": {"2100-03"}, "nights": {"20"}}))
+	mustStatus(t, rr, http.StatusOK)
+	if !strings.Contains(rr.Body.String(), `id="no-windows"`) {
+		t.Error("expected the no-results message")
+	}
+
+	for _, form := range []url.Values{
+		{"month": {"March"}, "nights": {"3"}},
+		{"month": {"2000-03"}, "nights": {"3"}},
+		{"month": {"2100-02"}, "nights": {"29"}},
+		{"month": {"2100-03"}, "nights": {"0"}},
+	} {
+		rr := do(Repo.PostFlexibleAvailability, newPOSTForm("/search-flexible", form))
+		mustStatus(t, rr, http.StatusSeeOther)
+		mustRedirectContains(t, rr, "/search-availability")
+	}
+
+	dbrepo.ForceFindWindowsErr = true
+	defer func() { dbrepo.ForceFindWindowsErr = false }()
+	rr = do(Repo.PostFlexibleAvailability, newPOSTForm("/search-flexible", url.Values{"month": {"2100-03"}, "nights": {"3"}}))
+	mustRedirectContains(t, rr, "/search-availability")
+}
+
+// TestRepository_PostReservation_Terms verifies that a reservation without the
+// terms checkbox is rejected with a field error and nothing is stored, while an
+// accepted one records when the terms were accepted.
+func TestRepository_PostReservation_Terms(t *testing.T) {
+	form := map[string]string{
+		"start_date": "01/01/2100",
+		"end_date":   "01/02/2100",
+		"first_name": "John",
+		"last_name":  "Smith",
+		"email":      "john@smith.com",
+		"phone":      "1234567891",
+		"room_id":    "1",
+	}
+
+	repo, mail := newMailCaptureRepo()
+	req := newPOSTForm("/make-reservation", toForm(form))
+	rr := do(repo.PostReservation, req)
+	mustStatus(t, rr, http.StatusOK)
+	if !strings.Contains(rr.Body.String(), "You must accept the terms") {
+		t.Error("expected a terms error on the re-rendered form")
+	}
+	if len(mail.sent()) != 0 {
+		t.Error("no mail should be queued for a rejected reservation")
+	}
+
+	form["accept_terms"] = "1"
+	before := time.Now()
+	req = newPOSTForm("/make-reservation", toForm(form))
+	rr = do(repo.PostReservation, req)
+	mustStatus(t, rr, http.StatusSeeOther)
+	mustRedirectContains(t, rr, "/reservation-summary")
+
+	res, ok := session.Get(req.Context(), "reservation").(models.Reservation)
+	if !ok {
+		t.Fatal("reservation not stored in session")
+	}
+	if res.TermsAcceptedAt.Before(before) {
+		t.Fatalf("TermsAcceptedAt: got %v, want at or after %v", res.TermsAcceptedAt, before)
+	}
+}
+
+// TestRepository_Holds follows a hold through the booking flow: choosing a
+// room holds its dates, a second guest choosing the same dates is sent back
+// to the search, and a successful booking releases the hold.
+func TestRepository_Holds(t *testing.T) {
+	dbrepo.ResetHolds()
+	defer dbrepo.ResetHolds()
+
+	repo, _ := newMailCaptureRepo()
+	repo.App.HoldTTL = 15 * time.Minute
+	repo.now = func() time.Time { return time.Date(2099, time.December, 1, 10, 0, 0, 0, time.UTC) }
+
+	stay := models.Reservation{
+		StartDate: time.Date(2100, time.January, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2100, time.January, 3, 0, 0, 0, 0, time.UTC),
+	}
+	choose := func() *http.Request {
+		req := newGET("/choose-room/1")
+		req.RequestURI = "/choose-room/1"
+		session.Put(req.Context(), "reservation", stay)
+		return req
+	}
+
+	first := choose()
+	rr := do(repo.ChooseRoom, first)
+	mustRedirectContains(t, rr, "/make-reservation")
+
+	holdID := session.GetInt(first.Context(), "hold_id")
+	hold, ok := dbrepo.Holds()[holdID]
+	if !ok {
+		t.Fatalf("no hold stored for session hold_id %d", holdID)
+	}
+	if hold.RoomID != 1 || !hold.Start.Equal(stay.StartDate) || !hold.End.Equal(stay.EndDate) {
+		t.Errorf("hold: got %+v, want room 1 for the chosen stay", hold)
+	}
+	if want := repo.now().Add(15 * time.Minute); !hold.ExpiresAt.Equal(want) {
+		t.Errorf("hold expiry: got %v, want %v", hold.ExpiresAt, want)
+	}
+
+	// Another guest can't take the held dates.
+	second := choose()
+	rr = do(repo.ChooseRoom, second)
+	mustRedirectContains(t, rr, "/search-availability")
+	if got := session.GetString(second.Context(), "error"); got != datesTakenMessage {
+		t.Errorf("error: got %q, want %q", got, datesTakenMessage)
+	}
+	if len(dbrepo.Holds()) != 1 {
+		t.Errorf("holds: got %d, want 1", len(dbrepo.Holds()))
+	}
+
+	// Booking converts the hold into the reservation's restriction.
+	req := newPOSTForm("/make-reservation", url.Values{
+		"start_date":   {"01/01/2100"},
+		"end_date":     {"01/03/2100"},
+		"first_name":   {"John"},
+		"last_name":    {"Smith"},
+		"email":        {"john@smith.com"},
+		"phone":        {"1234567891"},
+		"room_id":      {"1"},
+		"accept_terms": {"1"},
+	})
+	session.Put(req.Context(), "hold_id", holdID)
+	rr = do(repo.PostReservation, req)
+	mustRedirectContains(t, rr, "/reservation-summary")
+	if len(dbrepo.Holds()) != 0 {
+		t.Errorf("hold should be released after booking, got %v", dbrepo.Holds())
+	}
+	if session.Exists(req.Context(), "hold_id") {
+		t.Error("hold_id should be cleared from the session")
+	}
+}
+
+// TestRepository_Holds_Disabled verifies that no hold is taken when HoldTTL
+// is zero, and that a failed hold doesn't stop the guest from continuing.
+func TestRepository_Holds_Disabled(t *testing.T) {
+	dbrepo.ResetHolds()
+	defer dbrepo.ResetHolds()
+
+	req := newGET("/book-room?id=1&s=01/01/2100&e=01/02/2100")
+	rr := do(Repo.BookRoom, req)
+	mustRedirectContains(t, rr, "/make-reservation")
+	if len(dbrepo.Holds()) != 0 {
+		t.Errorf("holds: got %d, want none with HoldTTL unset", len(dbrepo.Holds()))
+	}
+
+	repo, _ := newMailCaptureRepo()
+	repo.App.HoldTTL = time.Minute
+	dbrepo.ForceHoldErr = true
+	defer func() { dbrepo.ForceHoldErr = false }()
+
+	req = newGET("/book-room?id=1&s=01/01/2100&e=01/02/2100")
+	rr = do(repo.BookRoom, req)
+	mustRedirectContains(t, rr, "/make-reservation")
+}
+
+// TestRepository_AdminCloseOpenRoom verifies that closing a room removes it
+// from availability search and the public rooms page while the admin calendar
+// still lists it as closed, that reopening restores it, and that unknown or
+// malformed room IDs are rejected.
+func TestRepository_AdminCloseOpenRoom(t *testing.T) {
+	dbrepo.ResetClosedRooms()
+	defer dbrepo.ResetClosedRooms()
+
+	post := func(h http.HandlerFunc, id string, form map[string]string) *httptest.ResponseRecorder {
+		req := newPOSTForm("/admin/rooms/"+id, toForm(form))
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", id)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		return do(h, req)
+	}
+	search := func() int {
+		req := newPOSTForm("/search-availability", toForm(map[string]string{
+			"start": "01/01/2101",
+			"end":   "01/02/2101",
+		}))
+		return do(Repo.PostAvailability, req).Code
+	}
+
+	rr := post(Repo.AdminCloseRoom, "1", map[string]string{"y": "2050", "m": "01"})
+	mustStatus(t, rr, http.StatusSeeOther)
+	if loc := rr.Header().Get("Location"); loc != "/admin/reservations-calendar?y=2050&m=01" {
+		t.Fatalf("Location: got %q", loc)
+	}
+
+	if code := search(); code != http.StatusSeeOther {
+		t.Errorf("search with the room closed: got %d, want redirect to waitlist", code)
+	}
+	if ok, _ := Repo.DB.SearchAvailabilityByDatesByRoomID(time.Date(2101, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2101, 1, 2, 0, 0, 0, 0, time.UTC), 1); ok {
+		t.Error("closed room reported available")
+	}
+	rr = do(Repo.Rooms, newGET("/rooms"))
+	if strings.Contains(rr.Body.String(), `<td><a href="/golden-haybeam-loft">`) {
+		t.Error("closed room listed on the public rooms page")
+	}
+	rr = do(Repo.AdminReservationsCalendar, newGET("/admin/reservations-calendar"))
+	mustStatus(t, rr, http.StatusOK)
+	if body := rr.Body.String(); !strings.Contains(body, "Golden Haybeam Loft") || !strings.Contains(body, `formaction="/admin/rooms/1/open"`) {
+		t.Error("admin calendar should still list the closed room with a reopen button")
+	}
+
+	rr = post(Repo.AdminOpenRoom, "1", nil)
+	mustStatus(t, rr, http.StatusSeeOther)
+	mustRedirectContains(t, rr, "/admin/reservations-calendar")
+	if code := search(); code != http.StatusOK {
+		t.Errorf("search with the room reopened: got %d, want 200", code)
+	}
+
+	mustStatus(t, post(Repo.AdminCloseRoom, "99", nil), http.StatusNotFound)
+	mustStatus(t, post(Repo.AdminCloseRoom, "x", nil), http.StatusBadRequest)
+}
+
+// TestRepository_AdminEditRoom verifies the room details form: it shows the
+// current values, saves a unique name, and re-renders with an error when the
+// name matches another room's, ignoring case and spacing.
+func TestRepository_AdminEditRoom(t *testing.T) {
+	dbrepo.ResetUpdatedRooms()
+	defer dbrepo.ResetUpdatedRooms()
+
+	withID := func(req *http.Request, id string) *http.Request {
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", id)
+		return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	}
+	post := func(id string, form url.Values) *httptest.ResponseRecorder {
+		return do(Repo.AdminPostEditRoom, withID(newPOSTForm("/admin/rooms/"+id+"/edit", form), id))
+	}
+
+	rr := do(Repo.AdminEditRoom, withID(newGET("/admin/rooms/2/edit"), "2"))
+	mustStatus(t, rr, http.StatusOK)
+	if !strings.Contains(rr.Body.String(), `name='room_name' value="Room"`) {
+		t.Error("form should be filled in with the room's current name")
+	}
+
+	rr = post("2", url.Values{"room_name": {"  Window   Perch Suite "}, "max_guests": {"3"}})
+	mustStatus(t, rr, http.StatusSeeOther)
+	mustRedirectContains(t, rr, "/admin/rooms/2/edit")
+	if len(dbrepo.UpdatedRooms) != 1 || dbrepo.UpdatedRooms[0].RoomName != "Window Perch Suite" || dbrepo.UpdatedRooms[0].MaxGuests != 3 {
+		t.Fatalf("updated rooms: got %+v", dbrepo.UpdatedRooms)
+	}
+
+	rr = post("2", url.Values{"room_name": {"golden  HAYBEAM loft"}, "max_guests": {"3"}})
+	mustStatus(t, rr, http.StatusOK)
+	if !strings.Contains(rr.Body.String(), "Another room is already called that") {
+		t.Error("duplicate name should be reported on the form")
+	}
+	if len(dbrepo.UpdatedRooms) != 1 {
+		t.Errorf("duplicate name should not be saved: %+v", dbrepo.UpdatedRooms)
+	}
+
+	// Room 1 may keep its own name.
+	mustStatus(t, post("1", url.Values{"room_name": {dbrepo.TestTakenRoomName}, "max_guests": {"2"}}), http.StatusSeeOther)
+
+	for name, form := range map[string]url.Values{
+		"missing name":  {"max_guests": {"2"}},
+		"zero capacity": {"room_name": {"Nook"}, "max_guests": {"0"}},
+		"bad capacity":  {"room_name": {"Nook"}, "max_guests": {"two"}},
+	} {
+		if rr := post("2", form); rr.Code != http.StatusOK {
+			t.Errorf("%s: got %d, want 200 with errors", name, rr.Code)
+		}
+	}
+	mustStatus(t, post("x", url.Values{"room_name": {"Nook"}, "max_guests": {"2"}}), http.StatusBadRequest)
+}
+
+// TestRepository_AdminEditRoom_RenamePageBacked verifies that renaming a room
+// with a public page keeps the two together: the rooms list links the new
+// name to the same page, and the page still loads the room's gallery.
+func TestRepository_AdminEditRoom_RenamePageBacked(t *testing.T) {
+	dbrepo.ResetUpdatedRooms()
+	dbrepo.ResetRoomImages()
+	defer dbrepo.ResetUpdatedRooms()
+	defer dbrepo.ResetRoomImages()
+
+	page := RoomRoutes[0]
+	if _, err := Repo.DB.InsertRoomImage(models.RoomImage{RoomID: page.RoomID, URL: "/static/images/loft-a.jpg"}); err != nil {
+		t.Fatal(err)
+	}
+
+	id := strconv.Itoa(page.RoomID)
+	req := newPOSTForm("/admin/rooms/"+id+"/edit", url.Values{"room_name": {"Sunlit Haybeam Loft"}, "max_guests": {"2"}})
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", id)
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	mustStatus(t, do(Repo.AdminPostEditRoom, req), http.StatusSeeOther)
+
+	rr := do(Repo.Rooms, newGET("/rooms"))
+	mustStatus(t, rr, http.StatusOK)
+	if link := `<a href="` + page.Path + `">Sunlit Haybeam Loft</a>`; !strings.Contains(rr.Body.String(), link) {
+		t.Errorf("rooms list should link the renamed room to %s", page.Path)
+	}
+
+	rr = do(Repo.RoomPage(page), newGET(page.Path))
+	mustStatus(t, rr, http.StatusOK)
+	if !strings.Contains(rr.Body.String(), "loft-a.jpg") {
+		t.Error("room page should still show the renamed room's gallery")
+	}
+}
+
+// TestRepository_RoomImages verifies the admin gallery: images are added
+// with validation, listed and shown on the room page in sort order (new
+// images last by default), and removed; unknown images are a 404.
+func TestRepository_RoomImages(t *testing.T) {
+	dbrepo.ResetRoomImages()
+	defer dbrepo.ResetRoomImages()
+
+	withIDs := func(req *http.Request, params ...string) *http.Request {
+		rctx := chi.NewRouteContext()
+		for i := 0; i+1 < len(params); i += 2 {
+			rctx.URLParams.Add(params[i], params[i+1])
+		}
+		return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	}
+	add := func(form map[string]string) *httptest.ResponseRecorder {
+		return do(Repo.AdminPostRoomImage, withIDs(newPOSTForm("/admin/rooms/1/images", toForm(form)), "id", "1"))
+	}
+
+	rr := add(map[string]string{"url": "/static/images/loft-b.jpg", "caption": "Second", "sort_order": "5"})
+	mustStatus(t, rr, http.StatusSeeOther)
+	mustRedirectContains(t, rr, "/admin/rooms/1/images")
+	mustStatus(t, add(map[string]string{"url": "/static/images/loft-a.jpg", "caption": "First", "sort_order": "1"}), http.StatusSeeOther)
+	mustStatus(t, add(map[string]string{"url": "https://cdn.example.com/loft-c.jpg", "caption": "Third"}), http.StatusSeeOther)
+
+	images, err := Repo.DB.ImagesForRoom(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var captions []string
+	for _, img := range images {
+		captions = append(captions, img.Caption)
+	}
+	if want := []string{"First", "Second", "Third"}; !reflect.DeepEqual(captions, want) {
+		t.Fatalf("gallery order: got %v, want %v", captions, want)
+	}
+	if images[2].SortOrder != 6 {
+		t.Errorf("image without a position should go last: got sort order %d", images[2].SortOrder)
+	}
+
+	rr = do(Repo.AdminRoomImages, withIDs(newGET("/admin/rooms/1/images"), "id", "1"))
+	mustStatus(t, rr, http.StatusOK)
+	if body := rr.Body.String(); !strings.Contains(body, "loft-a.jpg") || !strings.Contains(body, "/admin/rooms/1/images/"+strconv.Itoa(images[0].ID)+"/delete") {
+		t.Error("admin gallery page should list each image with a remove form")
+	}
+
+	var page RoomRoute
+	for _, p := range RoomRoutes {
+		if p.RoomID == 1 {
+			page = p
+		}
+	}
+	body := do(Repo.RoomPage(page), newGET(page.Path)).Body.String()
+	first, second := strings.Index(body, "loft-a.jpg"), strings.Index(body, "loft-b.jpg")
+	if first < 0 || second < 0 || first > second {
+		t.Errorf("room page should show the gallery in order (first at %d, second at %d)", first, second)
+	}
+
+	for name, form := range map[string]map[string]string{
+		"missing url":   {"caption": "No image"},
+		"relative url":  {"url": "images/x.jpg"},
+		"plain http":    {"url": "http://example.com/x.jpg"},
+		"no scheme":     {"url": "//evil.example.com/x.jpg"},
+		"backslash":     {"url": "/\\evil.example.com/x.jpg"},
+		"https no host": {"url": "https:///x.jpg"},
+		"bad position":  {"url": "/static/images/x.jpg", "sort_order": "-1"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			rr := add(form)
+			mustStatus(t, rr, http.StatusOK)
+			if !strings.Contains(rr.Body.String(), "text-danger") {
+				t.Error("expected the form to re-render with an error")
+			}
+		})
+	}
+
+	rr = do(Repo.AdminDeleteRoomImage, withIDs(newPOSTForm("/", nil), "id", "1", "imageID", strconv.Itoa(images[0].ID)))
+	mustStatus(t, rr, http.StatusSeeOther)
+	if images, _ := Repo.DB.ImagesForRoom(1); len(images) != 2 {
+		t.Errorf("after delete: got %d images, want 2", len(images))
+	}
+	mustStatus(t, do(Repo.AdminDeleteRoomImage, withIDs(newPOSTForm("/", nil), "id", "2", "imageID", strconv.Itoa(images[1].ID))), http.StatusNotFound)
+	mustStatus(t, do(Repo.AdminDeleteRoomImage, withIDs(newPOSTForm("/", nil), "id", "1", "imageID", "x")), http.StatusBadRequest)
+
+	// A gallery that can't be loaded doesn't take the room page down.
+	dbrepo.ForceRoomImagesErr = true
+	defer func() { dbrepo.ForceRoomImagesErr = false }()
+	mustStatus(t, do(Repo.RoomPage(page), newGET(page.Path)), http.StatusOK)
+}
+
+// TestRepository_AdminArrivals verifies that the arrivals page shows only
+// guests arriving on the chosen day, sorted by name, defaults to today, and
+// rejects malformed dates.
+func TestRepository_AdminArrivals(t *testing.T) {
+	rr := do(Repo.AdminArrivals, newGET("/admin/arrivals?date=2050-03-10"))
+	mustStatus(t, rr, http.StatusOK)
+	body := rr.Body.String()
+	abbott, mews, whiskers := strings.Index(body, "Abbott, Bella"), strings.Index(body, "Mews, Luna"), strings.Index(body, "Whiskers, Oscar")
+	if abbott < 0 || mews < 0 || whiskers < 0 || !(abbott < mews && mews < whiskers) {
+		t.Errorf("arrivals on 03/10 should be listed by name (positions %d, %d, %d)", abbott, mews, whiskers)
+	}
+	if strings.Contains(body, "Tabby") {
+		t.Error("a guest arriving the next day should not be listed")
+	}
+	if !strings.Contains(body, "Thu, Mar 10 2050") || !strings.Contains(body, `date=2050-03-11"`) {
+		t.Error("expected the day heading and a next-day link")
+	}
+
+	repo := NewTestRepo(&app)
+	repo.now = func() time.Time { return time.Date(2050, time.March, 11, 18, 0, 0, 0, time.Local) }
+	body = do(repo.AdminArrivals, newGET("/admin/arrivals")).Body.String()
+	if !strings.Contains(body, "Tabby, Felix") || strings.Contains(body, "Abbott") {
+		t.Error("without a date the page should show today's arrivals")
+	}
+
+	body = do(Repo.AdminArrivals, newGET("/admin/arrivals?date=2050-03-12")).Body.String()
+	if !strings.Contains(body, "No arrivals on this day") {
+		t.Error("expected the empty-day message")
+	}
+
+	mustStatus(t, do(Repo.AdminArrivals, newGET("/admin/arrivals?date=03/10/2050")), http.StatusBadRequest)
+
+	dbrepo.ForceArrivalsErr = true
+	defer func() { dbrepo.ForceArrivalsErr = false }()
+	mustStatus(t, do(Repo.AdminArrivals, newGET("/admin/arrivals")), http.StatusInternalServerError)
+}
+
+// TestRepository_ReservationAPI verifies POST /api/reservations: a valid body
+// is stored and echoed back with 201, invalid fields come back as a 400
+// envelope with per-field messages, and booking conflicts and storage
+// failures map to 409 and 500.
+func TestRepository_ReservationAPI(t *testing.T) {
+	valid := func() map[string]interface{} {
+		return map[string]interface{}{
+			"room_id": 1, "start_date": "01/01/2101", "end_date": "01/03/2101", "guests": 2,
+			"first_name": "John", "last_name": "Smith", "email": "john@smith.com",
+			"phone": "555-555-5555", "accept_terms": true,
+		}
+	}
+	post := func(body map[string]interface{}) *httptest.ResponseRecorder {
+		b, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest(http.MethodPost, "/api/reservations", bytes.NewReader(b))
+		req.Header.Set("Content-Type", "application/json")
+		return do(Repo.ReservationAPI, req)
+	}
+
+	t.Run("created", func(t *testing.T) {
+		dbrepo.ResetCreatedReservations()
+		defer dbrepo.ResetCreatedReservations()
+
+		rr := post(valid())
+		mustStatus(t, rr, http.StatusCreated)
+
+		var res models.Reservation
+		if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+		if res.ID != 1 || res.RoomID != 1 || res.Email != "john@smith.com" {
+			t.Errorf("reservation: got %+v", res)
+		}
+		if res.Total != 2*dbrepo.TestBaseRate {
+			t.Errorf("total: got %d, want %d", res.Total, 2*dbrepo.TestBaseRate)
+		}
+		if res.TermsAcceptedAt.IsZero() {
+			t.Error("terms_accepted_at not set")
+		}
+		if got := dbrepo.CreatedReservations(); len(got) != 1 || got[0].FirstName != "John" {
+			t.Errorf("stored reservations: got %+v", got)
+		}
+	})
+
+	t.Run("field errors", func(t *testing.T) {
+		dbrepo.ResetCreatedReservations()
+		defer dbrepo.ResetCreatedReservations()
+
+		body := valid()
+		body["email"] = "not-an-email"
+		body["first_name"] = "J"
+		body["start_date"] = "2101-01-01"
+		delete(body, "accept_terms")
+
+		rr := post(body)
+		mustStatus(t, rr, http.StatusBadRequest)
+
+		var e apiError
+		if err := json.Unmarshal(rr.Body.Bytes(), &e); err != nil {
+			t.Fatal(err)
+		}
+		if e.Code != errCodeInvalidInput {
+			t.Errorf("code: got %q, want %q", e.Code, errCodeInvalidInput)
+		}
+		for _, field := range []string{"email", "first_name", "start_date", "accept_terms"} {
+			if len(e.Fields[field]) == 0 {
+				t.Errorf("expected a field error for %s, got %v", field, e.Fields)
+			}
+		}
+		if _, ok := e.Fields["last_name"]; ok {
+			t.Errorf("unexpected last_name error: %v", e.Fields)
+		}
+		if len(dbrepo.CreatedReservations()) != 0 {
+			t.Error("invalid request stored a reservation")
+		}
+	})
+
+	tests := []struct {
+		name     string
+		change   func(map[string]interface{})
+		raw      string
+		wantCode int
+		wantErr  string
+	}{
+		{name: "malformed json", raw: `{"room_id":`, wantCode: http.StatusBadRequest, wantErr: errCodeInvalidInput},
+		{name: "policy failure", change: func(b map[string]interface{}) { b["guests"] = dbrepo.TestMaxGuests + 1 }, wantCode: http.StatusBadRequest, wantErr: errCodeInvalidInput},
+		{name: "unavailable", change: func(b map[string]interface{}) { b["start_date"], b["end_date"] = "01/01/2100", "01/03/2100" }, wantCode: http.StatusConflict, wantErr: errCodeUnavailable},
+		{name: "duplicate", change: func(b map[string]interface{}) { b["email"] = dbrepo.TestDuplicateEmail }, wantCode: http.StatusConflict, wantErr: errCodeDuplicate},
+		{name: "create fails", change: func(b map[string]interface{}) { b["room_id"] = 3 }, wantCode: http.StatusInternalServerError, wantErr: errCodeServer},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var rr *httptest.ResponseRecorder
+			if tc.raw != "" {
+				req := httptest.NewRequest(http.MethodPost, "/api/reservations", strings.NewReader(tc.raw))
+				rr = do(Repo.ReservationAPI, req)
+			} else {
+				body := valid()
+				tc.change(body)
+				rr = post(body)
+			}
+			mustStatus(t, rr, tc.wantCode)
+
+			var e apiError
+			if err := json.Unmarshal(rr.Body.Bytes(), &e); err != nil {
+				t.Fatal(err)
+			}
+			if e.Code != tc.wantErr {
+				t.Errorf("code: got %q, want %q", e.Code, tc.wantErr)
+			}
+		})
+	}
+}
+
+// TestRepository_LeadTime verifies that a stay starting inside the room's
+// lead time is refused by the booking form and the API, that one starting
+// far enough ahead is accepted, and that a room's override takes precedence
+// over MinLeadDays.
+func TestRepository_LeadTime(t *testing.T) {
+	testApp := app
+	testApp.MinLeadDays = 1
+	repo := NewTestRepo(&testApp)
+	repo.now = func() time.Time { return time.Date(2101, time.January, 1, 10, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name     string
+		start    string
+		end      string
+		roomLead int // per-room override; -1 for none
+		accepted bool
+		message  string
+	}{
+		{name: "same day under 1-day lead", start: "01/01/2101", end: "01/03/2101", roomLead: -1, message: "at least 1 day in advance"},
+		{name: "next day under 1-day lead", start: "01/02/2101", end: "01/04/2101", roomLead: -1, accepted: true},
+		{name: "room override allows same day", start: "01/01/2101", end: "01/03/2101", roomLead: 0, accepted: true},
+		{name: "room override needs 3 days", start: "01/03/2101", end: "01/05/2101", roomLead: 3, message: "at least 3 days in advance"},
+	}
+
+	for _, tc := range tests {
+		setLead := func() {
+			dbrepo.ResetRoomLeadDays()
+			if tc.roomLead >= 0 {
+				dbrepo.SetRoomLeadDays(1, tc.roomLead)
+			}
+		}
+
+		t.Run(tc.name+" form", func(t *testing.T) {
+			setLead()
+			defer dbrepo.ResetRoomLeadDays()
+
+			req := newPOSTForm("/make-reservation", toForm(map[string]string{
+				"start_date":   tc.start,
+				"end_date":     tc.end,
+				"first_name":   "John",
+				"last_name":    "Smith",
+				"email":        "john@smith.com",
+				"phone":        "1234567891",
+				"accept_terms": "1",
+				"room_id":      "1",
+			}))
+			rr := do(repo.PostReservation, req)
+			if tc.accepted {
+				mustStatus(t, rr, http.StatusSeeOther)
+				mustRedirectContains(t, rr, "/reservation-summary")
+				return
+			}
+			mustStatus(t, rr, http.StatusOK)
+			if !strings.Contains(rr.Body.String(), tc.message) {
+				t.Errorf("expected the lead time error %q", tc.message)
+			}
+		})
+
+		t.Run(tc.name+" API", func(t *testing.T) {
+			setLead()
+			defer dbrepo.ResetRoomLeadDays()
+			dbrepo.ResetCreatedReservations()
+			defer dbrepo.ResetCreatedReservations()
+
+			b, _ := json.Marshal(map[string]interface{}{
+				"room_id": 1, "start_date": tc.start, "end_date": tc.end,
+				"first_name": "John", "last_name": "Smith", "email": "john@smith.com",
+				"phone": "555-555-5555", "accept_terms": true,
+			})
+			req := httptest.NewRequest(http.MethodPost, "/api/reservations", bytes.NewReader(b))
+			rr := do(repo.ReservationAPI, req)
+			if tc.accepted {
+				mustStatus(t, rr, http.StatusCreated)
+				return
+			}
+			mustAPIError(t, rr, http.StatusBadRequest, errCodeInvalidInput)
+			var e apiError
+			if err := json.Unmarshal(rr.Body.Bytes(), &e); err != nil {
+				t.Fatal(err)
+			}
+			if len(e.Fields["start_date"]) != 1 || !strings.Contains(e.Fields["start_date"][0], tc.message) {
+				t.Errorf("fields: got %v, want the start_date lead time error", e.Fields)
+			}
+		})
+	}
+}
+
+// TestRepository_AdminPostReservationDates verifies that changing a
+// reservation's dates moves the restriction returned by
+// GetReservationRestriction, ignores that restriction when checking the new
+// dates, reprices the new nights even for dates a guest could not book, and
+// sends validation problems back to the reservation page.
+func TestRepository_AdminPostReservationDates(t *testing.T) {
+	tests := []struct {
+		name       string
+		id         string
+		start      string
+		end        string
+		setup      func()
+		wantStatus int
+		wantMoved  bool
+	}{
+		{name: "moved", id: "5", start: "2050-02-01", end: "2050-02-04", wantStatus: http.StatusSeeOther, wantMoved: true},
+		{name: "past dates", id: "5", start: "2020-02-01", end: "2020-02-04", wantStatus: http.StatusSeeOther, wantMoved: true},
+		{name: "malformed date", id: "5", start: "02/01/2050", end: "2050-02-04", wantStatus: http.StatusSeeOther},
+		{name: "end before start", id: "5", start: "2050-02-04", end: "2050-02-01", wantStatus: http.StatusSeeOther},
+		{name: "conflict", id: "5", start: "2050-02-01", end: "2050-02-04", setup: func() { dbrepo.ForceHasReservationRestriction = true }, wantStatus: http.StatusSeeOther},
+		{name: "no restriction", id: "5", start: "2050-02-01", end: "2050-02-04", setup: func() { dbrepo.ForceNoReservationRestriction = true }, wantStatus: http.StatusSeeOther},
+		{name: "not found", id: "999", start: "2050-02-01", end: "2050-02-04", wantStatus: http.StatusNotFound},
+		{name: "bad id", id: "abc", start: "2050-02-01", end: "2050-02-04", wantStatus: http.StatusBadRequest},
+		{name: "update error", id: "5", start: "2050-02-01", end: "2050-02-04", setup: func() { dbrepo.ForceUpdateDatesErr = true }, wantStatus: http.StatusInternalServerError},
+		{name: "rate error", id: "5", start: "2050-02-01", end: "2050-02-04", setup: func() { dbrepo.ForceRateErr = true }, wantStatus: http.StatusInternalServerError},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dbrepo.LastDateChange = models.RoomRestriction{}
+			dbrepo.LastDateChangeTotal = 0
+			defer func() {
+				dbrepo.ForceHasReservationRestriction = false
+				dbrepo.ForceNoReservationRestriction = false
+				dbrepo.ForceUpdateDatesErr = false
+				dbrepo.ForceRateErr = false
+			}()
+			if tc.setup != nil {
+				tc.setup()
+			}
+
+			req := newPOSTForm("/admin/reservations/all/"+tc.id+"/dates", url.Values{
+				"start_date": {tc.start},
+				"end_date":   {tc.end},
+			})
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("src", "all")
+			rctx.URLParams.Add("id", tc.id)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			rr := do(Repo.AdminPostReservationDates, req)
+			mustStatus(t, rr, tc.wantStatus)
+			if tc.wantStatus == http.StatusSeeOther {
+				mustRedirectContains(t, rr, "/admin/reservations/all/5/show")
+			}
+
+			moved := dbrepo.LastDateChange.ID != 0
+			if moved != tc.wantMoved {
+				t.Fatalf("dates changed: got %v, want %v", moved, tc.wantMoved)
+			}
+			if moved {
+				start, _ := time.Parse("2006-01-02", tc.start)
+				end, _ := time.Parse("2006-01-02", tc.end)
+				want := models.RoomRestriction{
+					ID:            dbrepo.TestReservationRestrictionID(5),
+					ReservationID: 5,
+					StartDate:     start,
+					EndDate:       end,
+				}
+				if dbrepo.LastDateChange != want {
+					t.Errorf("change: got %+v, want %+v", dbrepo.LastDateChange, want)
+				}
+				nights := int(end.Sub(start).Hours() / 24)
+				if dbrepo.LastDateChangeTotal != nights*dbrepo.TestBaseRate {
+					t.Errorf("total: got %d, want %d nights at the base rate", dbrepo.LastDateChangeTotal, nights)
+				}
+			}
+		})
+	}
+}
+
+// TestRepository_AdminSetReservationStatus verifies that an allowed status
+// change is applied and audited, that a disallowed one (cancelled to
+// checked in) is refused with an error on the reservation page, and that
+// unknown statuses and reservations are rejected.
+func TestRepository_AdminSetReservationStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		id         string
+		from       string
+		to         string
+		wantStatus int
+		wantFinal  string
+		wantFlash  string
+		wantError  string
+	}{
+		{name: "pending to confirmed", id: "5", from: models.StatusPending, to: models.StatusConfirmed,
+			wantStatus: http.StatusSeeOther, wantFinal: models.StatusConfirmed, wantFlash: "Status changed to confirmed"},
+		{name: "confirmed to checked in", id: "5", from: models.StatusConfirmed, to: models.StatusCheckedIn,
+			wantStatus: http.StatusSeeOther, wantFinal: models.StatusCheckedIn, wantFlash: "Status changed to checked in"},
+		{name: "cancelled to checked in rejected", id: "5", from: models.StatusCancelled, to: models.StatusCheckedIn,
+			wantStatus: http.StatusSeeOther, wantFinal: models.StatusCancelled, wantError: "can't be changed to checked in"},
+		{name: "unknown status", id: "5", from: models.StatusPending, to: "archived",
+			wantStatus: http.StatusBadRequest, wantFinal: models.StatusPending},
+		{name: "unknown reservation", id: "999", to: models.StatusConfirmed, wantStatus: http.StatusNotFound},
+		{name: "bad id", id: "abc", to: models.StatusConfirmed, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dbrepo.ResetReservationStatuses()
+			defer dbrepo.ResetReservationStatuses()
+			if tc.from != "" {
+				dbrepo.SetTestReservationStatus(5, tc.from)
+			}
+
+			req := newPOSTForm("/admin/reservations/all/"+tc.id+"/status", url.Values{"status": {tc.to}})
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("src", "all")
+			rctx.URLParams.Add("id", tc.id)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			rr := do(Repo.AdminSetReservationStatus, req)
+			mustStatus(t, rr, tc.wantStatus)
+			if tc.wantStatus == http.StatusSeeOther {
+				mustRedirectContains(t, rr, "/admin/reservations/all/5/show")
+			}
+
+			if tc.wantFlash != "" {
+				if got := session.GetString(req.Context(), "flash"); got != tc.wantFlash {
+					t.Errorf("flash: got %q, want %q", got, tc.wantFlash)
+				}
+			}
+			if tc.wantError != "" {
+				if got := session.GetString(req.Context(), "error"); !strings.Contains(got, tc.wantError) {
+					t.Errorf("error: got %q, want it to contain %q", got, tc.wantError)
+				}
+			}
+			if tc.wantFinal != "" {
+				res, err := Repo.DB.GetReservationByID(5)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if res.Status != tc.wantFinal || res.Processed != models.ProcessedForStatus(tc.wantFinal) {
+					t.Errorf("reservation: got status %q processed %d, want %q", res.Status, res.Processed, tc.wantFinal)
+				}
+			}
+		})
+	}
+}
+
+// TestRepository_AdminSetReservationStatus_CancelLogsWaitlist verifies that
+// cancelling a reservation, which frees its dates, logs waitlist entries
+// whose dates overlap the stay, as deleting it does.
+func TestRepository_AdminSetReservationStatus_CancelLogsWaitlist(t *testing.T) {
+	dbrepo.ResetWaitlist()
+	dbrepo.ResetReservationStatuses()
+	defer dbrepo.ResetWaitlist()
+	defer dbrepo.ResetReservationStatuses()
+
+	res, err := Repo.DB.GetReservationByID(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = Repo.DB.AddToWaitlist(models.WaitlistEntry{
+		FirstName: "Jane", LastName: "Doe", Email: "jane@example.com",
+		StartDate: res.StartDate, EndDate: res.EndDate,
+	})
+
+	var buf bytes.Buffer
+	testApp := app
+	testApp.InfoLog = log.New(&buf, "", 0)
+	repo := NewTestRepo(&testApp)
+
+	req := newPOSTForm("/admin/reservations/all/5/status", url.Values{"status": {models.StatusCancelled}})
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("src", "all")
+	rctx.URLParams.Add("id", "5")
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	rr := do(repo.AdminSetReservationStatus, req)
+	mustStatus(t, rr, http.StatusSeeOther)
+
+	if !strings.Contains(buf.String(), "jane@example.com") {
+		t.Fatalf("expected waitlist follow-up log, got %q", buf.String())
+	}
+}
+
+// TestRepository_AdminBulkDeleteReservations covers the bulk delete form:
+// confirmed batches are deleted and reported, anything else deletes nothing.
+func TestRepository_AdminBulkDeleteReservations(t *testing.T) {
+	tests := []struct {
+		name        string
+		form        url.Values
+		wantStatus  int
+		wantDeleted []int
+		wantFlash   string
+		wantError   string
+	}{
+		{name: "multiple ids", form: url.Values{"ids[]": {"3", "4", "3", "7"}, "confirm": {"DELETE"}, "status": {"new"}},
+			wantStatus: http.StatusSeeOther, wantDeleted: []int{3, 4, 7}, wantFlash: "Deleted 3 reservations"},
+		{name: "missing confirmation", form: url.Values{"ids[]": {"3", "4"}, "status": {"new"}},
+			wantStatus: http.StatusSeeOther, wantError: "Type DELETE to confirm"},
+		{name: "wrong confirmation", form: url.Values{"ids[]": {"3", "4"}, "confirm": {"yes"}, "status": {"new"}},
+			wantStatus: http.StatusSeeOther, wantError: "Type DELETE to confirm"},
+		{name: "nothing selected", form: url.Values{"confirm": {"DELETE"}, "status": {"new"}},
+			wantStatus: http.StatusSeeOther, wantError: "Select at least one reservation"},
+		{name: "missing reservation", form: url.Values{"ids[]": {"3", "999"}, "confirm": {"DELETE"}, "status": {"new"}},
+			wantStatus: http.StatusSeeOther, wantError: "nothing was deleted"},
+		{name: "bad id", form: url.Values{"ids[]": {"abc"}, "confirm": {"DELETE"}}, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dbrepo.ResetDeletedReservations()
+			defer dbrepo.ResetDeletedReservations()
+
+			req := newPOSTForm("/admin/reservations/bulk-delete", tc.form)
+			rr := do(Repo.AdminBulkDeleteReservations, req)
+			mustStatus(t, rr, tc.wantStatus)
+			if tc.wantStatus == http.StatusSeeOther {
+				mustRedirectContains(t, rr, "/admin/reservations?status=new")
+			}
+
+			if !reflect.DeepEqual(dbrepo.DeletedReservationIDs, tc.wantDeleted) {
+				t.Errorf("deleted: got %v, want %v", dbrepo.DeletedReservationIDs, tc.wantDeleted)
+			}
+			if tc.wantFlash != "" {
+				if got := session.GetString(req.Context(), "flash"); got != tc.wantFlash {
+					t.Errorf("flash: got %q, want %q", got, tc.wantFlash)
+				}
+			}
+			if tc.wantError != "" {
+				if got := session.GetString(req.Context(), "error"); !strings.Contains(got, tc.wantError) {
+					t.Errorf("error: got %q, want it to contain %q", got, tc.wantError)
+				}
+			}
+		})
+	}
+}
+
+// TestRepository_AdminShowReservation_StatusButtons verifies that the
+// reservation page shows the current status and offers only the allowed
+// next statuses.
+func TestRepository_AdminShowReservation_StatusButtons(t *testing.T) {
+	dbrepo.ResetReservationStatuses()
+	defer dbrepo.ResetReservationStatuses()
+	dbrepo.SetTestReservationStatus(5, models.StatusConfirmed)
+
+	rr := do(Repo.AdminShowReservation, newGET("/admin/reservations/all/5/show"))
+	mustStatus(t, rr, http.StatusOK)
+	body := rr.Body.String()
+
+	if !strings.Contains(body, `id="reservation-status">Confirmed<`) {
+		t.Error("expected the current status badge")
+	}
+	for _, want := range []string{`value="checked_in"`, `value="cancelled"`, `value="pending"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing status button %s", want)
+		}
+	}
+	if strings.Contains(body, `value="checked_out"`) {
+		t.Error("checked_out offered from confirmed")
+	}
+}
+
+// TestRepository_AdminBookingsStats verifies the dashboard chart endpoint:
+// the JSON shape, one entry per day ending today with the days the
+// repository omits filled with zeros, the default range, and rejection of
+// ranges outside the allow-list.
+func TestRepository_AdminBookingsStats(t *testing.T) {
+	repo := NewTestRepo(&app)
+	repo.now = func() time.Time { return time.Date(2050, time.March, 15, 10, 0, 0, 0, time.UTC) }
+
+	rr := do(repo.AdminBookingsStats, newGET("/admin/api/stats/bookings?range=7d"))
+	mustStatus(t, rr, http.StatusOK)
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("Content-Type: got %q, want application/json", ct)
+	}
+
+	// The test repository reports bookings on every third day from the
+	// start of the range (the 9th, 12th and 15th) and nothing in between.
+	var got map[string]any
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"range": "7d",
+		"start": "2050-03-09",
+		"end":   "2050-03-15",
+		"days": []any{
+			map[string]any{"date": "2050-03-09", "count": 2.0},
+			map[string]any{"date": "2050-03-10", "count": 0.0},
+			map[string]any{"date": "2050-03-11", "count": 0.0},
+			map[string]any{"date": "2050-03-12", "count": 1.0},
+			map[string]any{"date": "2050-03-13", "count": 0.0},
+			map[string]any{"date": "2050-03-14", "count": 0.0},
+			map[string]any{"date": "2050-03-15", "count": 4.0},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("body:\n got %v\nwant %v", got, want)
+	}
+
+	rr = do(repo.AdminBookingsStats, newGET("/admin/api/stats/bookings"))
+	mustStatus(t, rr, http.StatusOK)
+	var series bookingsSeries
+	if err := json.Unmarshal(rr.Body.Bytes(), &series); err != nil {
+		t.Fatal(err)
+	}
+	if series.Range != "30d" || len(series.Days) != 30 || series.Days[29].Date != "2050-03-15" {
+		t.Errorf("default range: got %s with %d days ending %s", series.Range, len(series.Days), series.Days[len(series.Days)-1].Date)
+	}
+
+	for _, rng := range []string{"1y", "-1d", "100000d"} {
+		mustAPIError(t, do(repo.AdminBookingsStats, newGET("/admin/api/stats/bookings?range="+rng)), http.StatusBadRequest, errCodeInvalidInput)
+	}
+
+	dbrepo.ForceBookingsPerDayErr = true
+	defer func() { dbrepo.ForceBookingsPerDayErr = false }()
+	mustAPIError(t, do(repo.AdminBookingsStats, newGET("/admin/api/stats/bookings?range=7d")), http.StatusInternalServerError, errCodeServer)
+}
+
+// TestRepository_NoSession verifies that the session-backed reservation pages
+// answer a request that skipped the session middleware with a redirect home
+// instead of panicking inside scs.
+func TestRepository_NoSession(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		path    string
+	}{
+		{name: "make reservation", handler: Repo.MakeReservation, path: "/make-reservation"},
+		{name: "reservation summary", handler: Repo.ReservationSummary, path: "/reservation-summary"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+
+			var rr *httptest.ResponseRecorder
+			func() {
+				defer func() {
+					if p := recover(); p != nil {
+						t.Fatalf("handler panicked without a session: %v", p)
+					}
+				}()
+				rr = do(tc.handler, req)
+			}()
+
+			mustStatus(t, rr, http.StatusSeeOther)
+			if loc := rr.Header().Get("Location"); loc != "/" {
+				t.Errorf("Location: got %q, want /", loc)
+			}
+		})
+	}
+}
+
+// TestRepository_ReservationAPI_IdempotencyKey verifies that a first request
+// with an Idempotency-Key creates a reservation, that a retry with the same
+// key returns the original reservation without inserting or emailing again,
+// and that a new key creates another reservation.
+func TestRepository_ReservationAPI_IdempotencyKey(t *testing.T) {
+	dbrepo.ResetCreatedReservations()
+	dbrepo.ResetIdempotencyKeys()
+	defer dbrepo.ResetCreatedReservations()
+	defer dbrepo.ResetIdempotencyKeys()
+
+	repo, mail := newMailCaptureRepo()
+	body := `{"room_id":1,"start_date":"01/01/2101","end_date":"01/03/2101","guests":2,
+		"first_name":"John","last_name":"Smith","email":"john@smith.com","phone":"555-555-5555","accept_terms":true}`
+	post := func(key string) (*httptest.ResponseRecorder, models.Reservation) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/api/reservations", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		rr := do(repo.ReservationAPI, req)
+		mustStatus(t, rr, http.StatusCreated)
+		var res models.Reservation
+		if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+		return rr, res
+	}
+
+	rr, first := post("retry-me")
+	if first.ID != 1 || rr.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("first request: got id %d, replayed %q", first.ID, rr.Header().Get("Idempotent-Replayed"))
+	}
+	if len(mail.sent()) == 0 {
+		t.Fatal("first request sent no email")
+	}
+
+	rr, retry := post("retry-me")
+	if retry.ID != first.ID {
+		t.Errorf("retry: got reservation %d, want the original %d", retry.ID, first.ID)
+	}
+	if rr.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("retry not marked as replayed")
+	}
+	if got := len(dbrepo.CreatedReservations()); got != 1 {
+		t.Errorf("reservations stored after retry: got %d, want 1", got)
+	}
+	if got := len(mail.sent()); got != 0 {
+		t.Errorf("retry sent %d more emails", got)
+	}
+
+	// Later nights, since the first booking now holds these.
+	body = strings.NewReplacer("01/01/2101", "02/01/2101", "01/03/2101", "02/03/2101").Replace(body)
+	rr, second := post("another-key")
+	if second.ID == first.ID || rr.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("new key: got reservation %d (replayed %q), want a new one", second.ID, rr.Header().Get("Idempotent-Replayed"))
+	}
+	if got := len(dbrepo.CreatedReservations()); got != 2 {
+		t.Errorf("reservations stored after new key: got %d, want 2", got)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/reservations", strings.NewReader(body))
+	req.Header.Set("Idempotency-Key", strings.Repeat("k", 256))
+	mustAPIError(t, do(repo.ReservationAPI, req), http.StatusBadRequest, errCodeInvalidInput)
+
+	dbrepo.ForceIdempotencyErr = true
+	defer func() { dbrepo.ForceIdempotencyErr = false }()
+	req = httptest.NewRequest(http.MethodPost, "/api/reservations", strings.NewReader(body))
+	req.Header.Set("Idempotency-Key", "retry-me")
+	mustAPIError(t, do(repo.ReservationAPI, req), http.StatusInternalServerError, errCodeServer)
+}
+
+// TestRepository_ReservationAPI_IdempotencyRace verifies that when two
+// requests with the same Idempotency-Key both miss the replay lookup, the
+// one that loses the claim inside the create transaction is answered with
+// the winner's reservation, marked as replayed, without another booking or
+// email.
+func TestRepository_ReservationAPI_IdempotencyRace(t *testing.T) {
+	dbrepo.ResetCreatedReservations()
+	dbrepo.ResetIdempotencyKeys()
+	dbrepo.ForceIdempotencyMiss = true
+	defer dbrepo.ResetCreatedReservations()
+	defer dbrepo.ResetIdempotencyKeys()
+	defer func() { dbrepo.ForceIdempotencyMiss = false }()
+
+	repo, mail := newMailCaptureRepo()
+	post := func() *httptest.ResponseRecorder {
+		body := `{"room_id":1,"start_date":"01/01/2101","end_date":"01/03/2101","guests":2,
+			"first_name":"John","last_name":"Smith","email":"john@smith.com","phone":"555-555-5555","accept_terms":true}`
+		req := httptest.NewRequest(http.MethodPost, "/api/reservations", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Idempotency-Key", "same-key")
+		return do(repo.ReservationAPI, req)
+	}
+
+	mustStatus(t, post(), http.StatusCreated)
+	mail.sent()
+
+	rr := post()
+	mustStatus(t, rr, http.StatusCreated)
+	if rr.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("losing request not marked as replayed")
+	}
+	if got := len(dbrepo.CreatedReservations()); got != 1 {
+		t.Errorf("reservations stored: got %d, want 1", got)
+	}
+	if got := len(mail.sent()); got != 0 {
+		t.Errorf("losing request sent %d emails", got)
+	}
+}
+
+// TestRepository_ReservationAPI_LostRace verifies that a booking which passes
+// the availability check but loses the room to another request before its
+// insert commits is answered with 409 unavailable, not a server error.
+func TestRepository_ReservationAPI_LostRace(t *testing.T) {
+	dbrepo.ResetCreatedReservations()
+	defer dbrepo.ResetCreatedReservations()
+
+	repo, mail := newMailCaptureRepo()
+	post := func(email string) *httptest.ResponseRecorder {
+		body := `{"room_id":1,"start_date":"01/01/2101","end_date":"01/03/2101","guests":2,
+			"first_name":"John","last_name":"Smith","email":"` + email + `","phone":"555-555-5555","accept_terms":true}`
+		req := httptest.NewRequest(http.MethodPost, "/api/reservations", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		return do(repo.ReservationAPI, req)
+	}
+
+	mustStatus(t, post("first@here.com"), http.StatusCreated)
+	mail.sent()
+	mustAPIError(t, post("second@here.com"), http.StatusConflict, errCodeUnavailable)
+	if got := len(dbrepo.CreatedReservations()); got != 1 {
+		t.Errorf("reservations stored: got %d, want 1", got)
+	}
+	if got := len(mail.sent()); got != 0 {
+		t.Errorf("losing request sent %d emails", got)
+	}
+}
+
+// panickingJSON is a payload whose MarshalJSON panics, standing in for a
+// value with a broken custom encoder.
+type panickingJSON struct{}
+
+// MarshalJSON panics.
+func (panickingJSON) MarshalJSON() ([]byte, error) { panic("broken encoder") }
+
+// TestWriteJSON_EncodeFailure verifies that a payload that can't be marshaled,
+// or whose encoder panics, is answered with a 500 server_error envelope
+// instead of an empty body, and that ordinary payloads are unaffected.
+func TestWriteJSON_EncodeFailure(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload interface{}
+	}{
+		{name: "channel", payload: map[string]interface{}{"ch": make(chan int)}},
+		{name: "NaN", payload: map[string]float64{"rate": math.NaN()}},
+		{name: "panicking marshaler", payload: panickingJSON{}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			func() {
+				defer func() {
+					if p := recover(); p != nil {
+						t.Fatalf("writeJSON panicked: %v", p)
+					}
+				}()
+				writeJSON(rr, http.StatusOK, tc.payload)
+			}()
+			mustAPIError(t, rr, http.StatusInternalServerError, errCodeServer)
+		})
+	}
+
+	rr := httptest.NewRecorder()
+	writeJSON(rr, http.StatusCreated, map[string]int{"id": 7})
+	mustStatus(t, rr, http.StatusCreated)
+	if !strings.Contains(rr.Body.String(), `"id": 7`) {
+		t.Errorf("body: got %s", rr.Body.String())
+	}
+}
+
+// TestRepository_AdminViewAsGuest verifies that a top-level admin sees a
+// guest's reservations in the labeled read-only view and that the lookup is
+// audited, that an invalid email re-renders the form, and that lower access
+// levels and anonymous requests are refused without reading anything.
+func TestRepository_AdminViewAsGuest(t *testing.T) {
+	dbrepo.ResetAudit()
+	defer dbrepo.ResetAudit()
+
+	get := func(userID int, query string) *httptest.ResponseRecorder {
+		req := newGET("/admin/view-as" + query)
+		if userID != 0 {
+			session.Put(req.Context(), "user_id", userID)
+		}
+		return do(Repo.AdminViewAsGuest, req)
+	}
+
+	rr := get(dbrepo.TestAdminUserID, "?email=+Guest@Example.com+")
+	mustStatus(t, rr, http.StatusOK)
+	body := rr.Body.String()
+	for _, want := range []string{`id="view-as-banner"`, "Read-only staff view", "guest@example.com", "Golden Haybeam Loft"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("view missing %q", want)
+		}
+	}
+	if strings.Contains(body, "/admin/reservations/") {
+		t.Error("read-only view links to editable reservation pages")
+	}
+	entries, _ := Repo.DB.RecentAudit(10)
+	if len(entries) != 1 || entries[0].Action != "guest.view_as" || !strings.Contains(entries[0].Detail, "guest@example.com") {
+		t.Errorf("audit: got %+v", entries)
+	}
+
+	rr = get(dbrepo.TestAdminUserID, "")
+	mustStatus(t, rr, http.StatusOK)
+	if strings.Contains(rr.Body.String(), `id="view-as-banner"`) {
+		t.Error("lookup form shows results without an email")
+	}
+
+	rr = get(dbrepo.TestAdminUserID, "?email=not-an-email")
+	mustStatus(t, rr, http.StatusOK)
+	if strings.Contains(rr.Body.String(), `id="view-as-banner"`) || !strings.Contains(rr.Body.String(), "is-invalid") {
+		t.Error("invalid email should re-render the form with an error")
+	}
+
+	dbrepo.ResetAudit()
+	mustStatus(t, get(dbrepo.TestAdminUserID+1, "?email=guest@example.com"), http.StatusForbidden)
+	mustStatus(t, get(0, "?email=guest@example.com"), http.StatusForbidden)
+	if entries, _ := Repo.DB.RecentAudit(10); len(entries) != 0 {
+		t.Errorf("refused requests were audited: %+v", entries)
+	}
+
+	dbrepo.ForceReservationsByEmailErr = true
+	defer func() { dbrepo.ForceReservationsByEmailErr = false }()
+	mustStatus(t, get(dbrepo.TestAdminUserID, "?email=guest@example.com"), http.StatusInternalServerError)
+}
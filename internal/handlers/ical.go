@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// icalProdID identifies this application as the producer of iCalendar files.
+const icalProdID = "-//Milo's Residence//Reservations//EN"
+
+// icalEvent is one all-day VEVENT. End is exclusive, matching both RFC 5545
+// DATE semantics and the check-out day of a reservation.
+type icalEvent struct {
+	UID     string
+	Summary string
+	Start   time.Time
+	End     time.Time
+	Status  string // TENTATIVE, CONFIRMED or CANCELLED
+}
+
+// icalEscaper escapes TEXT values per RFC 5545 section 3.3.11.
+var icalEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+
+// buildICal renders events as a VCALENDAR document with CRLF line endings,
+// folding content lines longer than 75 octets on rune boundaries. now is used for DTSTAMP.
+func buildICal(events []icalEvent, now time.Time) string {
+	var b strings.Builder
+	line := func(s string) {
+		// Fold at 75 octets, backing up to a rune boundary so multi-byte
+		// characters stay whole; continuation lines start with a single space.
+		for len(s) > 75 {
+			cut := 75
+			for !utf8.RuneStart(s[cut]) {
+				cut--
+			}
+			b.WriteString(s[:cut] + "\r\n")
+			s = " " + s[cut:]
+		}
+		b.WriteString(s + "\r\n")
+	}
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:" + icalProdID)
+	line("CALSCALE:GREGORIAN")
+	for _, e := range events {
+		line("BEGIN:VEVENT")
+		line("UID:" + e.UID)
+		line("DTSTAMP:" + now.UTC().Format("20060102T150405Z"))
+		line("DTSTART;VALUE=DATE:" + e.Start.Format("20060102"))
+		line("DTEND;VALUE=DATE:" + e.End.Format("20060102"))
+		line("SUMMARY:" + icalEscaper.Replace(e.Summary))
+		if e.Status != "" {
+			line("STATUS:" + e.Status)
+		}
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+
+	return b.String()
+}
+
+// writeICal sends events as a downloadable text/calendar attachment named
+// filename.
+func writeICal(w http.ResponseWriter, filename string, events []icalEvent) {
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	_, _ = w.Write([]byte(buildICal(events, time.Now())))
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+
+	"github.com/bensabler/milos-residence/internal/models"
+)
+
+// Sender addresses for outgoing mail.
+const (
+	reservationMailFrom = "milo@milos-residence.com"
+	contactMailFrom     = "hello@milosresidence.com"
+)
+
+// reservationEmails builds the mail sent when a reservation is made: the
+// guest confirmation in locale, followed by one staff notification (always
+// in defaultLocale) per address in staff.
+//
+// Parameters:
+//   - property: Property name (AppConfig.Property)
+//   - res: Stored reservation, with Room.RoomName filled in
+//   - locale: Catalog locale for the guest confirmation
+//   - checkIn, checkOut: Times quoted in the confirmation
+//   - staff: Notification recipients
+//
+// Returns the messages in the order they should be queued.
+func reservationEmails(property string, res models.Reservation, locale, checkIn, checkOut string, staff []string) []models.MailData {
+	start, end := res.StartDate.Format("01/02/2006"), res.EndDate.Format("01/02/2006")
+
+	subject, body := messages(locale).confirmation(res.FirstName, property, start, end, checkIn, checkOut)
+	msgs := []models.MailData{{
+		To:       res.Email,
+		From:     reservationMailFrom,
+		Subject:  subject,
+		Content:  body,
+		Template: "basic.html",
+	}}
+
+	subject, body = messages(defaultLocale).notification(property, res.Room.RoomName, start, end)
+	for _, to := range staff {
+		msgs = append(msgs, models.MailData{
+			To:      to,
+			From:    reservationMailFrom,
+			Subject: subject,
+			Content: body,
+		})
+	}
+	return msgs
+}
+
+// contactEmails builds the mail sent for a contact form submission: the
+// message forwarded to staffTo with the sender as From, followed by a
+// confirmation to the sender.
+//
+// Parameters:
+//   - property: Property name (AppConfig.Property)
+//   - name, email: Sender's name and address
+//   - topic: Normalized contact topic
+//   - message: Message text
+//   - staffTo: Staff address for the topic
+//
+// Returns the messages in the order they should be queued. Everything the
+// sender typed is HTML-escaped before it goes into a body, so markup in the
+// form reaches staff inboxes as text.
+func contactEmails(property, name, email, topic, message, staffTo string) []models.MailData {
+	forward := fmt.Sprintf(`
+		<strong>New Contact Form Message</strong><br><br>
+		<strong>From:</strong> %s (%s)<br>
+		<strong>Topic:</strong> %s<br><br>
+		<strong>Message:</strong><br>
+		%s
+	`, template.HTMLEscapeString(name), template.HTMLEscapeString(email), template.HTMLEscapeString(topic), escapeMultiline(message))
+
+	confirmation := fmt.Sprintf(`
+		Hi %s,<br><br>
+		Thank you for contacting %s! We've received your message and will get back to you within 24 hours.<br><br>
+		Best purrs,<br>
+		The %s Team
+	`, template.HTMLEscapeString(name), template.HTMLEscapeString(property), template.HTMLEscapeString(property))
+
+	return []models.MailData{
+		{
+			To:       staffTo,
+			From:     email,
+			Subject:  fmt.Sprintf("Contact Form: %s", topic),
+			Content:  forward,
+			Template: "basic.html",
+		},
+		{
+			To:       email,
+			From:     contactMailFrom,
+			Subject:  "Thanks for contacting " + property,
+			Content:  confirmation,
+			Template: "basic.html",
+		},
+	}
+}
+
+// escapeMultiline HTML-escapes s and then turns its line breaks into <br>,
+// so text typed into a textarea keeps its lines in an HTML email without
+// letting any markup through.
+func escapeMultiline(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.ReplaceAll(template.HTMLEscapeString(s), "\n", "<br>\n")
+}
+
+// reservationUpdatedEmail builds the message telling a guest that staff
+// changed their reservation, listing each change. It is addressed to the
+// reservation's current email, so a corrected address receives it.
+func reservationUpdatedEmail(res models.Reservation, changes []reservationChange) models.MailData {
+	var list strings.Builder
+	for _, c := range changes {
+		from := c.From
+		if from == "" {
+			from = "(none)"
+		}
+		fmt.Fprintf(&list, "\t\t<li><strong>%s:</strong> %s &rarr; %s</li>\n",
+			c.Field, template.HTMLEscapeString(from), template.HTMLEscapeString(c.To))
+	}
+
+	return models.MailData{
+		To:      res.Email,
+		From:    reservationMailFrom,
+		Subject: "Your reservation was updated",
+		Content: fmt.Sprintf(`
+		<strong>Your reservation was updated</strong><br>
+		Dear %s, <br>
+		We've updated your reservation from %s to %s:
+		<ul>
+%s		</ul>
+		If anything looks wrong, just reply to this email.
+	`, template.HTMLEscapeString(res.FirstName), res.StartDate.Format("01/02/2006"), res.EndDate.Format("01/02/2006"), list.String()),
+		Template: "basic.html",
+	}
+}
+
+// passwordResetEmail builds the message carrying a password reset link to a
+// staff user of property.
+func passwordResetEmail(property string, u models.User, link string) models.MailData {
+	return models.MailData{
+		To:      u.Email,
+		From:    reservationMailFrom,
+		Subject: fmt.Sprintf("Reset your %s password", property),
+		Content: fmt.Sprintf(`
+		<strong>Reset your password</strong><br>
+		Hi %s,<br>
+		Someone asked to reset the password for your %s staff account.
+		Choose a new one at <a href="%s">%s</a>.<br>
+		The link works once and expires soon. If you didn't ask for this, you can ignore this email.
+	`, template.HTMLEscapeString(u.FirstName), template.HTMLEscapeString(property), link, link),
+		Template:  "basic.html",
+		Sensitive: true,
+	}
+}
+
+// queueMail hands each message to the mail listener in order.
+func (m *Repository) queueMail(msgs []models.MailData) {
+	for _, msg := range msgs {
+		m.App.MailChan <- msg
+	}
+}
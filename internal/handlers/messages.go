@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// defaultLocale is the catalog entry used when a request asks for no locale or
+// for one the catalog does not contain. It must always be present in catalog.
+const defaultLocale = "en"
+
+// mailMessages holds the localized subject and body formats for the emails sent
+// when a reservation is made. Body strings are fmt formats; the argument order
+// is documented on each field.
+type mailMessages struct {
+	ConfirmationSubject string
+	ConfirmationBody    string // first name, property name, start date, end date, check-in time, check-out time
+	NotificationSubject string
+	NotificationBody    string // property name, room name, start date, end date
+}
+
+// catalog maps a lowercase primary language subtag (e.g. "en") to its messages.
+// Entries missing a string fall back to the defaultLocale entry for that string.
+var catalog = map[string]mailMessages{
+	"en": {
+		ConfirmationSubject: "Reservation Confirmation",
+		ConfirmationBody: `
+			<strong>Reservation Confirmation</strong><br>
+			Dear %s, <br>
+			This is to confirm your reservation at %s from %s to %s.<br>
+			Check-in is from %s; check-out is by %s.
+	`,
+		NotificationSubject: "Reservation Notification",
+		NotificationBody: `
+			<strong>Reservation Notification</strong><br>
+			A reservation has been made at %s for the %s snooze spot from %s to %s.
+	`,
+	},
+	// es is a stub: only the guest-facing confirmation is translated so far.
+	"es": {
+		ConfirmationSubject: "Confirmación de reserva",
+		ConfirmationBody: `
+			<strong>Confirmación de reserva</strong><br>
+			Estimado/a %s, <br>
+			Le confirmamos su reserva en %s del %s al %s.<br>
+			La entrada es a partir de las %s y la salida antes de las %s.
+	`,
+	},
+}
+
+// messages returns the mail strings for locale, filling any string the locale
+// does not define (or the whole set, for an unknown locale) from defaultLocale.
+func messages(locale string) mailMessages {
+	base := catalog[defaultLocale]
+	m, ok := catalog[strings.ToLower(locale)]
+	if !ok {
+		return base
+	}
+	if m.ConfirmationSubject == "" {
+		m.ConfirmationSubject = base.ConfirmationSubject
+	}
+	if m.ConfirmationBody == "" {
+		m.ConfirmationBody = base.ConfirmationBody
+	}
+	if m.NotificationSubject == "" {
+		m.NotificationSubject = base.NotificationSubject
+	}
+	if m.NotificationBody == "" {
+		m.NotificationBody = base.NotificationBody
+	}
+	return m
+}
+
+// confirmation returns the guest confirmation subject and HTML body. The
+// arguments are HTML-escaped, so a name such as "Milo's" or one containing
+// markup reaches the guest as text.
+func (m mailMessages) confirmation(firstName, property, start, end, checkIn, checkOut string) (string, string) {
+	return m.ConfirmationSubject, fmt.Sprintf(m.ConfirmationBody, escapeAll(firstName, property, start, end, checkIn, checkOut)...)
+}
+
+// notification returns the staff notification subject and HTML body, with
+// the arguments HTML-escaped as in confirmation.
+func (m mailMessages) notification(property, roomName, start, end string) (string, string) {
+	return m.NotificationSubject, fmt.Sprintf(m.NotificationBody, escapeAll(property, roomName, start, end)...)
+}
+
+// escapeAll HTML-escapes each value for use as fmt arguments.
+func escapeAll(values ...string) []interface{} {
+	args := make([]interface{}, len(values))
+	for i, v := range values {
+		args[i] = template.HTMLEscapeString(v)
+	}
+	return args
+}
+
+// requestLocale picks the catalog locale for r. An explicit ?lang= (or form
+// field "lang") wins when the catalog has it; otherwise the highest-weighted
+// supported language in Accept-Language is used. Region subtags are ignored,
+// so "es-MX" selects "es". Falls back to defaultLocale.
+func requestLocale(r *http.Request) string {
+	if lang := primaryTag(r.FormValue("lang")); lang != "" {
+		if _, ok := catalog[lang]; ok {
+			return lang
+		}
+	}
+
+	best, bestQ := defaultLocale, 0.0
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		lang := primaryTag(tag)
+		if _, ok := catalog[lang]; ok && q > bestQ {
+			best, bestQ = lang, q
+		}
+	}
+	return best
+}
+
+// primaryTag returns the lowercase primary subtag of a language tag, e.g.
+// "es" for "es-MX".
+func primaryTag(tag string) string {
+	lang, _, _ := strings.Cut(strings.TrimSpace(tag), "-")
+	return strings.ToLower(lang)
+}
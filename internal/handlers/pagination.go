@@ -0,0 +1,59 @@
+package handlers
+
+import "strconv"
+
+// defaultRoomsPerPage is how many rooms a page of availability results lists
+// when AppConfig.RoomsPerPage is not set.
+const defaultRoomsPerPage = 10
+
+// pagination describes one page of a list for templates: which page is shown,
+// how many there are, and the neighbours to link to. Pages are numbered from 1.
+type pagination struct {
+	Page       int // Page being shown, 1 to TotalPages
+	PerPage    int // Items per page
+	TotalItems int // Items across all pages
+	TotalPages int // Number of pages; at least 1
+}
+
+// newPagination returns the pagination for page of a list of total items,
+// perPage at a time. page is clamped into range, so a stale or hand-edited
+// page number shows the nearest real page instead of an empty one.
+func newPagination(total, page, perPage int) pagination {
+	if perPage < 1 {
+		perPage = defaultRoomsPerPage
+	}
+	pages := (total + perPage - 1) / perPage
+	if pages < 1 {
+		pages = 1
+	}
+	page = min(max(page, 1), pages)
+	return pagination{Page: page, PerPage: perPage, TotalItems: total, TotalPages: pages}
+}
+
+// parsePage reads a page number from a form or query value. Anything that is
+// not a positive integer selects page 1.
+func parsePage(v string) int {
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		return 1
+	}
+	return n
+}
+
+// Bounds returns the slice indexes of the current page's items.
+func (p pagination) Bounds() (int, int) {
+	first := (p.Page - 1) * p.PerPage
+	return min(first, p.TotalItems), min(first+p.PerPage, p.TotalItems)
+}
+
+// HasPrev reports whether there is a page before this one.
+func (p pagination) HasPrev() bool { return p.Page > 1 }
+
+// HasNext reports whether there is a page after this one.
+func (p pagination) HasNext() bool { return p.Page < p.TotalPages }
+
+// PrevPage returns the number of the previous page.
+func (p pagination) PrevPage() int { return p.Page - 1 }
+
+// NextPage returns the number of the next page.
+func (p pagination) NextPage() int { return p.Page + 1 }
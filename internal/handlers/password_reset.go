@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/bensabler/milos-residence/internal/forms"
+	"github.com/bensabler/milos-residence/internal/helpers"
+	"github.com/bensabler/milos-residence/internal/models"
+	"github.com/bensabler/milos-residence/internal/render"
+	"github.com/bensabler/milos-residence/internal/repository"
+	"github.com/go-chi/chi/v5"
+)
+
+// forgotPasswordFlash is shown after every valid forgot-password request,
+// whether or not the email belongs to an account, so the form can't be used
+// to find out who has one.
+const forgotPasswordFlash = "If an account exists for that email, we've sent a link to reset its password."
+
+// ForgotPassword handles GET /user/forgot-password and renders the form that
+// asks for the account's email address.
+func (m *Repository) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	render.Template(w, r, "forgot-password.page.tmpl", &models.TemplateData{
+		Form: forms.New(nil),
+	})
+}
+
+// PostForgotPassword handles POST /user/forgot-password. When the email
+// belongs to an account it issues a reset token and emails the user a link
+// to /user/reset-password/{token}. The link is built from BASE_URL only; when
+// it is not configured no email is sent, since the request's Host header
+// can't be trusted with a live token.
+//
+// The response is the same redirect and flash whether or not an account
+// was found; lookup and token failures are logged rather than shown, so they
+// don't reveal that the account exists either.
+func (m *Repository) PostForgotPassword(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		if helpers.BodyTooLarge(w, err) {
+			return
+		}
+		helpers.ServerError(w, err)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Trim("email")
+	form.Required("email")
+	form.IsEmail("email")
+
+	if !form.Valid() {
+		render.Template(w, r, "forgot-password.page.tmpl", &models.TemplateData{
+			Form: form,
+		})
+		return
+	}
+
+	u, err := m.DB.GetUserByEmail(form.Get("email"))
+	switch {
+	case err == nil:
+		token, err := m.DB.CreateResetToken(u.ID)
+		if err != nil {
+			m.App.ErrorLog.Println(err)
+			break
+		}
+		link, err := m.emailURL("/user/reset-password/" + url.PathEscape(token))
+		if err != nil {
+			m.App.ErrorLog.Println(err)
+			break
+		}
+		m.queueMail([]models.MailData{passwordResetEmail(m.App.Property(), u, link)})
+	case !errors.Is(err, repository.ErrUserNotFound):
+		m.App.ErrorLog.Println(err)
+	}
+
+	helpers.RedirectWithFlash(w, r, m.App.Session, "/user/login", forgotPasswordFlash)
+}
+
+// ResetPassword handles GET /user/reset-password/{token} and renders the
+// new password form. The token is only checked when the form is submitted,
+// so viewing the page doesn't use it up.
+func (m *Repository) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	render.Template(w, r, "reset-password.page.tmpl", &models.TemplateData{
+		Form:      forms.New(nil),
+		StringMap: map[string]string{"token": chi.URLParam(r, "token")},
+	})
+}
+
+// PostResetPassword handles POST /user/reset-password/{token}. It validates
+// the new password, consumes the token and stores the password for the
+// token's user.
+//
+// Processing logic:
+//  1. Requires a password of at least minPasswordLength characters that
+//     matches its confirmation, re-rendering the form otherwise; the token
+//     is left unused so the user can try again
+//  2. Consumes the token; an unknown, expired or used token sends the user
+//     back to /user/forgot-password with an error
+//  3. Updates the password, records an audit entry and redirects to the
+//     login page with a flash
+func (m *Repository) PostResetPassword(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+
+	err := r.ParseForm()
+	if err != nil {
+		if helpers.BodyTooLarge(w, err) {
+			return
+		}
+		helpers.ServerError(w, err)
+		return
+	}
+
+	// Passwords are used exactly as typed.
+	form := forms.New(r.PostForm)
+	form.Required("password", "password_confirm")
+
+	password := form.Get("password")
+	if password != "" && len(password) < minPasswordLength {
+		form.Errors.Add("password", fmt.Sprintf("Password must be at least %d characters long", minPasswordLength))
+	}
+	if form.Get("password_confirm") != "" && form.Get("password_confirm") != password {
+		form.Errors.Add("password_confirm", "Passwords don't match")
+	}
+
+	if !form.Valid() {
+		render.Template(w, r, "reset-password.page.tmpl", &models.TemplateData{
+			Form:      form,
+			StringMap: map[string]string{"token": token},
+		})
+		return
+	}
+
+	userID, err := m.DB.ConsumeResetToken(token)
+	if errors.Is(err, repository.ErrInvalidResetToken) {
+		helpers.RedirectWithError(w, r, m.App.Session, "/user/forgot-password", "This reset link is invalid or has expired. Please request a new one.")
+		return
+	}
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	if err := m.DB.UpdatePassword(userID, password); err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	if err := m.DB.RecordAudit(userID, "user.password_reset", "Password reset by email link"); err != nil {
+		m.App.ErrorLog.Println(err)
+	}
+
+	helpers.RedirectWithFlash(w, r, m.App.Session, "/user/login", "Your password has been reset. Please log in.")
+}
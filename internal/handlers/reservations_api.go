@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bensabler/milos-residence/internal/forms"
+	"github.com/bensabler/milos-residence/internal/models"
+	"github.com/bensabler/milos-residence/internal/repository"
+)
+
+// idempotencyKeyHeader is the request header a client sets to make retries
+// of POST /api/reservations safe. Responses replayed from an earlier request
+// carry idempotentReplayedHeader set to "true".
+const (
+	idempotencyKeyHeader     = "Idempotency-Key"
+	idempotentReplayedHeader = "Idempotent-Replayed"
+)
+
+// maxIdempotencyKeyLength matches the idempotency_keys.key column.
+const maxIdempotencyKeyLength = 255
+
+// reservationRequest is the JSON body accepted by POST /api/reservations.
+// Dates use the booking form's MM/DD/YYYY layout and guests defaults to one.
+type reservationRequest struct {
+	RoomID      int    `json:"room_id"`
+	StartDate   string `json:"start_date"`
+	EndDate     string `json:"end_date"`
+	Guests      int    `json:"guests"`
+	FirstName   string `json:"first_name"`
+	LastName    string `json:"last_name"`
+	Email       string `json:"email"`
+	Phone       string `json:"phone"`
+	AcceptTerms bool   `json:"accept_terms"`
+}
+
+// form returns the request as booking form values, so it can be canonicalized
+// and validated by the same forms rules as PostReservation.
+func (req reservationRequest) form() *forms.Form {
+	values := url.Values{}
+	values.Set("room_id", strconv.Itoa(req.RoomID))
+	values.Set("start_date", req.StartDate)
+	values.Set("end_date", req.EndDate)
+	values.Set("first_name", req.FirstName)
+	values.Set("last_name", req.LastName)
+	values.Set("email", req.Email)
+	values.Set("phone", req.Phone)
+	if req.AcceptTerms {
+		values.Set("accept_terms", "1")
+	}
+
+	form := forms.New(values)
+	form.Trim("start_date", "end_date", "email", "phone")
+	form.Collapse("first_name", "last_name")
+	return form
+}
+
+// ReservationAPI handles POST /api/reservations, the programmatic counterpart
+// of the booking form. It takes a JSON reservationRequest, applies the same
+// guest-field validation, advance-booking window, lead time and booking
+// rules as PostReservation, checks that the room is free, and stores the reservation and its room restriction in
+// one transaction. Confirmation and staff emails are queued as for the form.
+//
+// A request with an Idempotency-Key header is processed once: the key is
+// claimed in the transaction that stores the reservation, and a later
+// request with the same key gets that reservation back (201,
+// Idempotent-Replayed: true) without validation, a second insert or more
+// email. Two requests sent with the same key at once can both get past the
+// first lookup, but only one can claim the key; the other is answered with
+// the winner's reservation the same way. Keys are not compared against the
+// body, so a client must use a new key for a different booking.
+//
+// Responses:
+//   - 201 with the stored reservation, including its ID, room and total
+//   - 400 invalid_input for malformed JSON, with per-field messages in
+//     "fields" when validation fails, or the failed policies in "message"
+//   - 409 unavailable if the room is booked for the dates, or duplicate if
+//     the guest already holds the same booking
+//   - 413 request_too_large if the body exceeds the size limit
+//   - 500 server_error on a database failure
+func (m *Repository) ReservationAPI(w http.ResponseWriter, r *http.Request) {
+	key := r.Header.Get(idempotencyKeyHeader)
+	if len(key) > maxIdempotencyKeyLength {
+		writeAPIError(w, http.StatusBadRequest, errCodeInvalidInput, "Idempotency-Key must be at most 255 characters")
+		return
+	}
+	if key != "" && m.replayReservation(w, key) {
+		return
+	}
+
+	var req reservationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			writeAPIError(w, http.StatusRequestEntityTooLarge, errCodeTooLarge, "Request body too large")
+			return
+		}
+		writeAPIError(w, http.StatusBadRequest, errCodeInvalidInput, "Invalid JSON body")
+		return
+	}
+
+	form := req.form()
+	validateGuestFields(form)
+
+	layout := "01/02/2006"
+	startDate, err := time.Parse(layout, form.Get("start_date"))
+	if err != nil {
+		form.Errors.Add("start_date", "Enter a date as MM/DD/YYYY")
+	}
+	endDate, err := time.Parse(layout, form.Get("end_date"))
+	if err != nil {
+		form.Errors.Add("end_date", "Enter a date as MM/DD/YYYY")
+	}
+	if form.Errors.Get("start_date") == "" {
+		m.checkAdvanceWindow(form, startDate)
+	}
+	if req.Guests < 0 {
+		form.Errors.Add("guests", "Enter a positive number of guests")
+	}
+
+	var room models.Room
+	if req.RoomID <= 0 {
+		form.Errors.Add("room_id", "Choose a room")
+	} else {
+		room, err = m.DB.GetRoomByID(req.RoomID)
+		if errors.Is(err, sql.ErrNoRows) {
+			form.Errors.Add("room_id", "Choose a room")
+		} else if err != nil {
+			m.App.ErrorLog.Println(err)
+			writeAPIError(w, http.StatusInternalServerError, errCodeServer, "Error querying database")
+			return
+		}
+	}
+	if room.ID != 0 && form.Errors.Get("start_date") == "" {
+		m.checkLeadTime(form, startDate, room)
+	}
+
+	if !form.Valid() {
+		writeJSON(w, http.StatusBadRequest, apiError{
+			Code:    errCodeInvalidInput,
+			Message: "Some fields are invalid",
+			Fields:  form.Errors,
+		})
+		return
+	}
+
+	reservation := models.Reservation{
+		FirstName:       form.Get("first_name"),
+		LastName:        form.Get("last_name"),
+		Email:           form.Get("email"),
+		Phone:           form.Get("phone"),
+		StartDate:       startDate,
+		EndDate:         endDate,
+		RoomID:          room.ID,
+		Room:            room,
+		TermsAcceptedAt: time.Now().UTC(),
+	}
+
+	guests := req.Guests
+	if guests == 0 {
+		guests = 1
+	}
+
+	q, err := m.quote(reservation, guests)
+	if err != nil {
+		m.App.ErrorLog.Println(err)
+		writeAPIError(w, http.StatusInternalServerError, errCodeServer, "Error calculating price")
+		return
+	}
+	if !q.OK {
+		writeAPIError(w, http.StatusBadRequest, errCodeInvalidInput, strings.Join(q.failures(), "; "))
+		return
+	}
+	reservation.Total = q.Total
+
+	available, err := m.DB.SearchAvailabilityByDatesByRoomID(startDate, endDate, reservation.RoomID)
+	if err != nil {
+		m.App.ErrorLog.Println(err)
+		writeAPIError(w, http.StatusInternalServerError, errCodeServer, "Error querying database")
+		return
+	}
+	if !available {
+		writeAPIError(w, http.StatusConflict, errCodeUnavailable, "The room is not available for these dates")
+		return
+	}
+
+	exists, err := m.DB.ReservationExists(reservation.Email, reservation.RoomID, startDate, endDate)
+	if err != nil {
+		m.App.ErrorLog.Println(err)
+		writeAPIError(w, http.StatusInternalServerError, errCodeServer, "Error querying database")
+		return
+	}
+	if exists {
+		writeAPIError(w, http.StatusConflict, errCodeDuplicate, "You already have a booking for these dates")
+		return
+	}
+
+	// The check above is repeated inside the insert's transaction; losing a
+	// race for the same nights, or for the same key, surfaces here.
+	reservation.ID, err = m.DB.CreateReservation(reservation, key)
+	if errors.Is(err, repository.ErrIdempotencyKeyUsed) {
+		m.writeReplay(w, reservation.ID)
+		return
+	}
+	if errors.Is(err, repository.ErrDatesTaken) {
+		writeAPIError(w, http.StatusConflict, errCodeUnavailable, "The room is not available for these dates")
+		return
+	}
+	if err != nil {
+		m.App.ErrorLog.Println(err)
+		writeAPIError(w, http.StatusInternalServerError, errCodeServer, "Error saving reservation")
+		return
+	}
+
+	checkIn, checkOut := m.checkInOutTimes()
+	m.queueMail(reservationEmails(m.App.Property(), reservation, requestLocale(r), checkIn, checkOut, m.notificationRecipients()))
+
+	writeJSON(w, http.StatusCreated, reservation)
+}
+
+// replayReservation writes the response for a ReservationAPI request whose
+// Idempotency-Key has already been processed and reports whether it did. It
+// returns false, so the request is processed normally, when the key is new
+// or its reservation has since been deleted; a database failure is answered
+// with a 500 and reported as handled.
+func (m *Repository) replayReservation(w http.ResponseWriter, key string) bool {
+	resID, err := m.DB.GetIdempotentResult(key)
+	if errors.Is(err, repository.ErrIdempotencyKeyNotFound) {
+		return false
+	}
+	if err != nil {
+		m.App.ErrorLog.Println(err)
+		writeAPIError(w, http.StatusInternalServerError, errCodeServer, "Error querying database")
+		return true
+	}
+
+	res, err := m.DB.GetReservationByID(resID)
+	if errors.Is(err, repository.ErrReservationNotFound) {
+		return false
+	}
+	if err != nil {
+		m.App.ErrorLog.Println(err)
+		writeAPIError(w, http.StatusInternalServerError, errCodeServer, "Error querying database")
+		return true
+	}
+
+	w.Header().Set(idempotentReplayedHeader, "true")
+	writeJSON(w, http.StatusCreated, res)
+	return true
+}
+
+// writeReplay answers a ReservationAPI request that lost the race to claim
+// its Idempotency-Key with resID, the reservation the winning request
+// created, exactly as replayReservation would have.
+func (m *Repository) writeReplay(w http.ResponseWriter, resID int) {
+	res, err := m.DB.GetReservationByID(resID)
+	if err != nil {
+		m.App.ErrorLog.Println(err)
+		writeAPIError(w, http.StatusInternalServerError, errCodeServer, "Error querying database")
+		return
+	}
+
+	w.Header().Set(idempotentReplayedHeader, "true")
+	writeJSON(w, http.StatusCreated, res)
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/bensabler/milos-residence/internal/forms"
+	"github.com/bensabler/milos-residence/internal/helpers"
+	"github.com/bensabler/milos-residence/internal/models"
+	"github.com/bensabler/milos-residence/internal/render"
+	"github.com/go-chi/chi/v5"
+)
+
+// maxRoomImageFieldLength matches the room_images url and caption columns.
+const maxRoomImageFieldLength = 255
+
+// roomImagesPath returns the admin gallery page for a room.
+func roomImagesPath(roomID int) string {
+	return fmt.Sprintf("/admin/rooms/%d/images", roomID)
+}
+
+// adminRoom loads the room named by the {id} URL parameter for the admin
+// room pages. On failure it writes the response (400 for a malformed ID, 404 for
+// an unknown room, 500 otherwise) and returns false.
+func (m *Repository) adminRoom(w http.ResponseWriter, r *http.Request) (models.Room, bool) {
+	roomID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return models.Room{}, false
+	}
+
+	room, err := m.DB.GetRoomByID(roomID)
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return models.Room{}, false
+	} else if err != nil {
+		helpers.ServerError(w, err)
+		return models.Room{}, false
+	}
+	return room, true
+}
+
+// renderRoomImages renders the admin gallery page for room with form, which
+// carries the add-image values and any validation errors.
+func (m *Repository) renderRoomImages(w http.ResponseWriter, r *http.Request, room models.Room, form *forms.Form) {
+	images, err := m.DB.ImagesForRoom(room.ID)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	render.Template(w, r, "admin-room-images.page.tmpl", &models.TemplateData{
+		Form: form,
+		Data: map[string]interface{}{
+			"room":   room,
+			"images": images,
+		},
+	})
+}
+
+// AdminRoomImages handles GET /admin/rooms/{id}/images and lists the room's
+// gallery in display order, with a form to add an image and a remove button
+// for each one.
+func (m *Repository) AdminRoomImages(w http.ResponseWriter, r *http.Request) {
+	room, ok := m.adminRoom(w, r)
+	if !ok {
+		return
+	}
+
+	m.renderRoomImages(w, r, room, forms.New(url.Values{}))
+}
+
+// AdminPostRoomImage handles POST /admin/rooms/{id}/images and adds an image
+// to the room's gallery.
+//
+// Processing logic:
+//  1. Requires url, a site path ("/static/...") or an https:// address; see
+//     validImageURL
+//  2. Caps url and caption at the column length
+//  3. Uses sort_order when given, otherwise places the image last
+//  4. Inserts the image, records an audit entry and redirects back to the
+//     gallery page with a flash; validation errors re-render the page
+func (m *Repository) AdminPostRoomImage(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		if helpers.BodyTooLarge(w, err) {
+			return
+		}
+		helpers.ServerError(w, err)
+		return
+	}
+
+	room, ok := m.adminRoom(w, r)
+	if !ok {
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Trim("url", "caption", "sort_order")
+	form.Required("url")
+	form.MaxLength("url", maxRoomImageFieldLength)
+	form.MaxLength("caption", maxRoomImageFieldLength)
+
+	imageURL := form.Get("url")
+	if imageURL != "" && !validImageURL(imageURL) {
+		form.Errors.Add("url", "Use a site path such as /static/images/photo.jpg or an https:// address")
+	}
+
+	sortOrder := -1
+	if v := form.Get("sort_order"); v != "" {
+		sortOrder, err = strconv.Atoi(v)
+		if err != nil || sortOrder < 0 {
+			form.Errors.Add("sort_order", "Enter a position of 0 or more")
+		}
+	}
+
+	if !form.Valid() {
+		m.renderRoomImages(w, r, room, form)
+		return
+	}
+
+	if sortOrder < 0 {
+		images, err := m.DB.ImagesForRoom(room.ID)
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+		sortOrder = 0
+		if len(images) > 0 {
+			sortOrder = images[len(images)-1].SortOrder + 1
+		}
+	}
+
+	id, err := m.DB.InsertRoomImage(models.RoomImage{
+		RoomID:    room.ID,
+		URL:       imageURL,
+		Caption:   form.Get("caption"),
+		SortOrder: sortOrder,
+	})
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	m.audit(r, "room_image.add", fmt.Sprintf("Added image %d (%s) to room %d", id, imageURL, room.ID))
+	helpers.RedirectWithFlash(w, r, m.App.Session, roomImagesPath(room.ID), "Image added")
+}
+
+// AdminDeleteRoomImage handles POST /admin/rooms/{id}/images/{imageID}/delete
+// and removes the image from the room's gallery. An image that does not
+// belong to the room is a 404.
+func (m *Repository) AdminDeleteRoomImage(w http.ResponseWriter, r *http.Request) {
+	roomID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	}
+	imageID, err := strconv.Atoi(chi.URLParam(r, "imageID"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = m.DB.DeleteRoomImage(roomID, imageID)
+	if errors.Is(err, sql.ErrNoRows) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	} else if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	m.audit(r, "room_image.delete", fmt.Sprintf("Removed image %d from room %d", imageID, roomID))
+	helpers.RedirectWithFlash(w, r, m.App.Session, roomImagesPath(roomID), "Image removed")
+}
+
+// validImageURL reports whether s is a site path or an https:// address with
+// a host. Protocol-relative addresses ("//host/x.jpg", or "/\host/x.jpg",
+// which browsers read the same way) start with a slash but load from another
+// site over any scheme, so they are refused.
+func validImageURL(s string) bool {
+	if strings.HasPrefix(s, "/") {
+		return !strings.HasPrefix(s, "//") && !strings.HasPrefix(s, "/\\")
+	}
+	u, err := url.Parse(s)
+	return err == nil && u.Scheme == "https" && u.Host != ""
+}
@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bensabler/milos-residence/internal/forms"
+	"github.com/bensabler/milos-residence/internal/helpers"
+	"github.com/bensabler/milos-residence/internal/models"
+	"github.com/bensabler/milos-residence/internal/render"
+	"github.com/bensabler/milos-residence/internal/repository"
+)
+
+// Keys of the runtime settings editable on /admin/settings. A saved value
+// takes precedence over the matching AppConfig field and built-in default.
+const (
+	settingNotificationEmails = "notification_emails"
+	settingMinStayNights      = "min_stay_nights"
+	settingMaxStayNights      = "max_stay_nights"
+	settingCheckInTime        = "check_in_time"
+	settingCheckOutTime       = "check_out_time"
+)
+
+// settingKeys lists the settings saved from /admin/settings, in form order.
+var settingKeys = []string{settingNotificationEmails, settingMinStayNights, settingMaxStayNights, settingCheckInTime, settingCheckOutTime}
+
+// notificationFallbackTopic is the contact topic whose staff address receives
+// reservation notifications until notification_emails is saved.
+const notificationFallbackTopic = "booking"
+
+// maxTimeSettingLength caps the free-text check-in and check-out times.
+const maxTimeSettingLength = 20
+
+// settingsCache keeps settings read from the database in memory so pages that
+// consult them don't query on every request. A key with no saved value is
+// cached as unset. Lookups that fail are not cached and are retried.
+type settingsCache struct {
+	mu     sync.RWMutex
+	values map[string]cachedSetting
+}
+
+// cachedSetting is one cached lookup; ok is false when the key is unset.
+type cachedSetting struct {
+	value string
+	ok    bool
+}
+
+// newSettingsCache returns an empty cache.
+func newSettingsCache() *settingsCache {
+	return &settingsCache{values: map[string]cachedSetting{}}
+}
+
+// get returns the cached lookup for key and whether one was cached.
+func (c *settingsCache) get(key string) (cachedSetting, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	s, ok := c.values[key]
+	return s, ok
+}
+
+// put caches the lookup for key.
+func (c *settingsCache) put(key string, s cachedSetting) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[key] = s
+}
+
+// invalidate drops every cached lookup so the next read goes to the database.
+func (c *settingsCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values = map[string]cachedSetting{}
+}
+
+// setting returns the saved value for key and whether one is set, reading
+// through the settings cache. A database error is logged and treated as
+// unset so callers fall back to their defaults.
+func (m *Repository) setting(key string) (string, bool) {
+	if s, ok := m.settings.get(key); ok {
+		return s.value, s.ok
+	}
+
+	value, err := m.DB.GetSetting(key)
+	switch {
+	case err == nil:
+		m.settings.put(key, cachedSetting{value: value, ok: true})
+		return value, true
+	case errors.Is(err, repository.ErrSettingNotFound):
+		m.settings.put(key, cachedSetting{})
+	default:
+		m.App.ErrorLog.Println(err)
+	}
+	return "", false
+}
+
+// intSetting returns the saved value for key when it is a positive integer.
+func (m *Repository) intSetting(key string) (int, bool) {
+	value, ok := m.setting(key)
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+	return n, true
+}
+
+// notificationRecipients returns the addresses that receive staff
+// reservation notifications, from notification_emails when saved and the
+// booking contact address (see contactRecipient) otherwise.
+func (m *Repository) notificationRecipients() []string {
+	value, _ := m.setting(settingNotificationEmails)
+	if recipients := emailList(value); len(recipients) > 0 {
+		return recipients
+	}
+	return []string{m.contactRecipient(notificationFallbackTopic)}
+}
+
+// emailList splits a comma-separated address list, dropping blank entries.
+func emailList(value string) []string {
+	var addrs []string
+	for _, addr := range strings.Split(value, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+// settingsForm returns the form values currently in effect, so the settings
+// page shows what guests and staff actually see.
+func (m *Repository) settingsForm() *forms.Form {
+	minNights, maxNights := m.stayLimits()
+	checkIn, checkOut := m.checkInOutTimes()
+
+	form := forms.New(url.Values{})
+	form.Add(settingNotificationEmails, strings.Join(m.notificationRecipients(), ", "))
+	form.Add(settingMinStayNights, strconv.Itoa(minNights))
+	form.Add(settingMaxStayNights, strconv.Itoa(maxNights))
+	form.Add(settingCheckInTime, checkIn)
+	form.Add(settingCheckOutTime, checkOut)
+	return form
+}
+
+// AdminSettings handles GET /admin/settings and renders the runtime settings
+// form. Only users at adminAccessLevel may change settings; others get 403.
+func (m *Repository) AdminSettings(w http.ResponseWriter, r *http.Request) {
+	if !m.hasAccessLevel(r, adminAccessLevel) {
+		helpers.ClientError(w, http.StatusForbidden)
+		return
+	}
+
+	render.Template(w, r, "admin-settings.page.tmpl", &models.TemplateData{
+		Form: m.settingsForm(),
+	})
+}
+
+// AdminPostSettings handles POST /admin/settings. It validates the submitted
+// values, saves the ones the admin changed with SetSetting, and invalidates
+// the settings cache so the new values take effect on the next request.
+//
+// Only values that differ from the ones in effect are saved. A field left at
+// its environment or built-in default therefore stays unsaved and keeps
+// following the environment, instead of being frozen into the database.
+//
+// Processing logic:
+//  1. Rejects callers below adminAccessLevel with 403
+//  2. Validates the notification list, stay limits (positive, min <= max)
+//     and check-in/check-out times, re-rendering the form on failure
+//  3. Saves the changed settings, invalidates the cache and records an
+//     audit entry naming them
+//  4. Redirects back to the settings page with a flash
+func (m *Repository) AdminPostSettings(w http.ResponseWriter, r *http.Request) {
+	if !m.hasAccessLevel(r, adminAccessLevel) {
+		helpers.ClientError(w, http.StatusForbidden)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		if helpers.BodyTooLarge(w, err) {
+			return
+		}
+		helpers.ServerError(w, err)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Trim(settingNotificationEmails, settingMinStayNights, settingMaxStayNights)
+	form.Collapse(settingCheckInTime, settingCheckOutTime)
+
+	form.Required(settingMinStayNights, settingMaxStayNights, settingCheckInTime, settingCheckOutTime)
+	form.IsEmailList(settingNotificationEmails)
+	form.MaxLength(settingCheckInTime, maxTimeSettingLength)
+	form.MaxLength(settingCheckOutTime, maxTimeSettingLength)
+
+	minNights, minErr := strconv.Atoi(form.Get(settingMinStayNights))
+	if minErr != nil || minNights < 1 {
+		form.Errors.Add(settingMinStayNights, "Enter a whole number of nights")
+	}
+	maxNights, maxErr := strconv.Atoi(form.Get(settingMaxStayNights))
+	if maxErr != nil || maxNights < 1 {
+		form.Errors.Add(settingMaxStayNights, "Enter a whole number of nights")
+	}
+	if form.Errors.Get(settingMinStayNights) == "" && form.Errors.Get(settingMaxStayNights) == "" && minNights > maxNights {
+		form.Errors.Add(settingMaxStayNights, "Maximum stay can't be shorter than the minimum")
+	}
+
+	if !form.Valid() {
+		render.Template(w, r, "admin-settings.page.tmpl", &models.TemplateData{
+			Form: form,
+		})
+		return
+	}
+
+	// Compare in the form settingsForm displays, so an untouched field matches.
+	current := m.settingsForm()
+	values := map[string]string{
+		settingNotificationEmails: strings.Join(emailList(form.Get(settingNotificationEmails)), ", "),
+		settingMinStayNights:      strconv.Itoa(minNights),
+		settingMaxStayNights:      strconv.Itoa(maxNights),
+		settingCheckInTime:        form.Get(settingCheckInTime),
+		settingCheckOutTime:       form.Get(settingCheckOutTime),
+	}
+
+	var changed []string
+	for _, key := range settingKeys {
+		if values[key] == current.Get(key) {
+			continue
+		}
+		if err := m.DB.SetSetting(key, values[key]); err != nil {
+			m.settings.invalidate()
+			helpers.ServerError(w, err)
+			return
+		}
+		changed = append(changed, fmt.Sprintf("%s=%q", key, values[key]))
+	}
+	m.settings.invalidate()
+
+	if len(changed) == 0 {
+		helpers.RedirectWithFlash(w, r, m.App.Session, "/admin/settings", "No changes to save")
+		return
+	}
+
+	m.audit(r, "settings.update", "Changed "+strings.Join(changed, ", "))
+
+	helpers.RedirectWithFlash(w, r, m.App.Session, "/admin/settings", "Settings saved")
+}
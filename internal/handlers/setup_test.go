@@ -33,11 +33,17 @@ import (
 // Keys:
 //   - humanDate: formats a time as "01-02-2006"
 //   - formatDate: formats a time using a supplied layout
+//   - prettyDate: formats a time as "Mon, Jan 2 2006"
+//   - dateRange: formats a stay compactly, e.g. "Jan 2 – 5, 2006"
+//   - localTime: converts a stored UTC timestamp to the display zone
 //   - iterate: returns [0..count-1] for simple range loops
 //   - add: returns a+b for index arithmetic inside templates
 var functions = template.FuncMap{
 	"humanDate":  func(t time.Time) string { return t.Format("01-02-2006") },
 	"formatDate": func(t time.Time, f string) string { return t.Format(f) },
+	"prettyDate": render.PrettyDate,
+	"dateRange":  render.DateRange,
+	"localTime":  render.LocalTime,
 	"iterate": func(count int) []int {
 		var items []int
 		for i := 0; i < count; i++ {
@@ -72,10 +78,14 @@ var pathToTemplates = "./../../templates"
 func TestMain(m *testing.M) {
 	// Register types for session encoding/decoding.
 	gob.Register(models.Reservation{})
+	gob.Register([]models.FlashMessage{})
 
 	// Configure application for test environment.
 	app.InProduction = false
 
+	// Fixtures book decades ahead (e.g. 2100), beyond the default window.
+	app.MaxAdvanceDays = 100 * 365
+
 	// Set up logging.
 	infoLog := log.New(os.Stdout, "INFO:\t", log.Ldate|log.Ltime)
 	app.InfoLog = infoLog
@@ -114,6 +124,9 @@ func TestMain(m *testing.M) {
 	// Suppress error log output during tests for cleaner output.
 	errorLog.SetOutput(io.Discard)
 
+	// Email templates live at the repository root.
+	pathToEmailTemplates = "./../../email-templates"
+
 	// Execute tests.
 	os.Exit(m.Run())
 }
@@ -130,11 +143,33 @@ func listenForMail() {
 
 // getRoutes constructs the HTTP router configured for tests.
 // It installs core middleware (panic recovery, CSRF, session) and registers
-// all application routes against the test Repository.
+// all application routes against the test Repository. The /admin routes are
+// left open so handler tests need not log in; see getAuthRoutes.
 //
 // Returns:
 //   - http.Handler: a chi.Mux with routes and middleware configured.
 func getRoutes() http.Handler {
+	return buildRoutes()
+}
+
+// getAuthRoutes is getRoutes with the /admin routes behind the same
+// authentication gate as production, wired to the test session.
+//
+// Returns:
+//   - http.Handler: a chi.Mux that redirects logged-out /admin requests.
+func getAuthRoutes() http.Handler {
+	return buildRoutes(helpers.RequireAuth(session))
+}
+
+// buildRoutes registers the test routes, applying adminMiddleware to the
+// /admin group.
+//
+// Parameters:
+//   - adminMiddleware: middleware for /admin only; none leaves it open.
+//
+// Returns:
+//   - http.Handler: the configured chi.Mux.
+func buildRoutes(adminMiddleware ...func(http.Handler) http.Handler) http.Handler {
 	mux := chi.NewRouter()
 
 	// Core middleware used in tests to match production behavior.
@@ -142,23 +177,40 @@ func getRoutes() http.Handler {
 	mux.Use(NoSurf)
 	mux.Use(SessionLoad)
 
+	// Crawler files and health check.
+	mux.Get("/healthz", Repo.Healthz)
+	mux.Get("/robots.txt", Repo.RobotsTxt)
+	mux.Get("/sitemap.xml", Repo.SitemapXML)
+
 	// Public routes.
 	mux.Get("/", Repo.Home)
 	mux.Get("/about", Repo.About)
 	mux.Get("/photos", Repo.Photos)
+	mux.Get("/rooms", Repo.Rooms)
 
-	mux.Get("/golden-haybeam-loft", Repo.GoldenHaybeamLoft)
-	mux.Get("/window-perch-theater", Repo.WindowPerchTheater)
-	mux.Get("/laundry-basket-nook", Repo.LaundryBasketNook)
+	for _, page := range RoomRoutes {
+		mux.Get(page.Path, Repo.RoomPage(page))
+	}
 
 	mux.Get("/search-availability", Repo.Availability)
 	mux.Post("/search-availability", Repo.PostAvailability)
 	mux.Post("/search-availability-json", Repo.AvailabilityJSON)
+	mux.Post("/search-flexible", Repo.PostFlexibleAvailability)
+	mux.Get("/search-availability.ics", Repo.AvailabilityICal)
+	mux.Route("/api", func(mux chi.Router) {
+		mux.MethodNotAllowed(APIMethodNotAllowed(mux))
+		mux.Post("/quote", Repo.QuoteAPI)
+		mux.Post("/reservations", Repo.ReservationAPI)
+		mux.Get("/rooms/{id}/blocked", Repo.RoomBlockedAPI)
+		mux.Get("/session/status", Repo.SessionStatus)
+	})
 
 	mux.Get("/choose-room/{id}", Repo.ChooseRoom)
 	mux.Get("/book-room", Repo.BookRoom)
 
 	mux.Get("/contact", Repo.Contact)
+	mux.Get("/waitlist", Repo.Waitlist)
+	mux.Post("/waitlist", Repo.PostWaitlist)
 
 	mux.Get("/make-reservation", Repo.MakeReservation)
 	mux.Post("/make-reservation", Repo.PostReservation)
@@ -168,22 +220,56 @@ func getRoutes() http.Handler {
 	mux.Get("/user/login", Repo.ShowLogin)
 	mux.Post("/user/login", Repo.PostShowLogin)
 	mux.Get("/user/logout", Repo.Logout)
+	mux.Get("/user/forgot-password", Repo.ForgotPassword)
+	mux.Post("/user/forgot-password", Repo.PostForgotPassword)
+	mux.Get("/user/reset-password/{token}", Repo.ResetPassword)
+	mux.Post("/user/reset-password/{token}", Repo.PostResetPassword)
 
 	// Static assets.
 	fileServer := http.FileServer(http.Dir("./static/"))
 	mux.Handle("/static/*", http.StripPrefix("/static", fileServer))
 
-	// Admin routes (no auth middleware for tests).
+	// Admin routes, behind adminMiddleware when given.
 	mux.Route("/admin", func(mux chi.Router) {
+		mux.Use(adminMiddleware...)
+
 		mux.Get("/dashboard", Repo.AdminDashboard)
+		mux.Get("/reservations", Repo.AdminReservations)
 		mux.Get("/reservations-new", Repo.AdminNewReservations)
 		mux.Get("/reservations-all", Repo.AdminAllReservations)
+		mux.Get("/arrivals", Repo.AdminArrivals)
 		mux.Get("/reservations-calendar", Repo.AdminReservationsCalendar)
 		mux.Post("/reservations-calendar", Repo.AdminPostReservationsCalendar)
 		mux.Get("/process-reservation/{src}/{id}/do", Repo.AdminProcessReservation)
+		mux.Get("/unprocess-reservation/{src}/{id}/do", Repo.AdminUnprocessReservation)
 		mux.Get("/delete-reservation/{src}/{id}/do", Repo.AdminDeleteReservation)
+		mux.Post("/reservations/bulk-delete", Repo.AdminBulkDeleteReservations)
+		mux.Post("/rooms/{id}/close", Repo.AdminCloseRoom)
+		mux.Post("/rooms/{id}/open", Repo.AdminOpenRoom)
+		mux.Get("/rooms/{id}/check", Repo.AdminRoomCheck)
+		mux.Get("/rooms/{id}/edit", Repo.AdminEditRoom)
+		mux.Post("/rooms/{id}/edit", Repo.AdminPostEditRoom)
+		mux.Get("/rooms/{id}/images", Repo.AdminRoomImages)
+		mux.Post("/rooms/{id}/images", Repo.AdminPostRoomImage)
+		mux.Post("/rooms/{id}/images/{imageID}/delete", Repo.AdminDeleteRoomImage)
+		mux.Get("/reports/conflicts", Repo.AdminReportConflicts)
+		mux.Get("/audit", Repo.AdminAuditLog)
+		mux.Get("/view-as", Repo.AdminViewAsGuest)
+		mux.Get("/mail-log", Repo.AdminMailLog)
+		mux.Get("/mail-failures", Repo.AdminMailFailures)
+		mux.Post("/mail-failures/{id}/retry", Repo.AdminRetryMail)
+		mux.Get("/users/new", Repo.AdminNewUser)
+		mux.Post("/users/new", Repo.AdminPostNewUser)
+		mux.Get("/settings", Repo.AdminSettings)
+		mux.Post("/settings", Repo.AdminPostSettings)
 		mux.Get("/reservations/{src}/{id}/show", Repo.AdminShowReservation)
+		mux.Get("/reservations/{src}/{id}/print", Repo.AdminPrintReservation)
 		mux.Post("/reservations/{src}/{id}", Repo.AdminPostShowReservation)
+		mux.Post("/reservations/{src}/{id}/dates", Repo.AdminPostReservationDates)
+		mux.Post("/reservations/{src}/{id}/status", Repo.AdminSetReservationStatus)
+		mux.Get("/api/reservations/{id}", Repo.AdminReservationJSON)
+		mux.Get("/api/stats/bookings", Repo.AdminBookingsStats)
+		mux.Get("/email-preview/{template}", Repo.AdminEmailPreview)
 	})
 
 	return mux
@@ -194,6 +280,7 @@ func getRoutes() http.Handler {
 // Behavior:
 //   - Uses nosurf with a base cookie set to HttpOnly, path "/", SameSite Lax,
 //     and Secure honoring app.InProduction.
+//   - Exempts /api requests per helpers.CSRFExempt, as in production.
 //
 // Parameters:
 //   - next: downstream handler to wrap with CSRF protection.
@@ -202,6 +289,7 @@ func getRoutes() http.Handler {
 //   - http.Handler: the wrapped handler enforcing CSRF tokens.
 func NoSurf(next http.Handler) http.Handler {
 	csrfHandler := nosurf.New(next)
+	csrfHandler.ExemptFunc(helpers.CSRFExempt)
 
 	csrfHandler.SetBaseCookie(http.Cookie{
 		HttpOnly: true,
@@ -226,10 +314,11 @@ func SessionLoad(next http.Handler) http.Handler {
 }
 
 // CreateTestTemplateCache builds a template cache for tests by parsing all
-// page (*.page.tmpl) and layout (*.layout.tmpl) templates rooted at pathToTemplates.
+// page (*.page.tmpl), layout (*.layout.tmpl), and partial (*.partial.tmpl)
+// templates rooted at pathToTemplates, mirroring render.CreateTemplateCache.
 //
 // Returns:
-//   - map[string]*template.Template: compiled templates keyed by page filename
+//   - map[string]*template.Template: compiled templates keyed by filename
 //   - error: non-nil on discovery or parse failure
 func CreateTestTemplateCache() (map[string]*template.Template, error) {
 	myCache := map[string]*template.Template{}
@@ -240,7 +329,13 @@ func CreateTestTemplateCache() (map[string]*template.Template, error) {
 		return myCache, err
 	}
 
-	// Parse each page template and attach any layouts.
+	// Find all partial templates.
+	partials, err := filepath.Glob(fmt.Sprintf("%s/*.partial.tmpl", pathToTemplates))
+	if err != nil {
+		return myCache, err
+	}
+
+	// Parse each page template and attach any layouts and partials.
 	for _, page := range pages {
 		name := filepath.Base(page)
 
@@ -262,6 +357,25 @@ func CreateTestTemplateCache() (map[string]*template.Template, error) {
 			}
 		}
 
+		if len(partials) > 0 {
+			ts, err = ts.ParseFiles(partials...)
+			if err != nil {
+				return myCache, err
+			}
+		}
+
+		myCache[name] = ts
+	}
+
+	// Parse partials standalone for fragment rendering.
+	for _, partial := range partials {
+		name := filepath.Base(partial)
+
+		ts, err := template.New(name).Funcs(functions).ParseFiles(partial)
+		if err != nil {
+			return myCache, err
+		}
+
 		myCache[name] = ts
 	}
 
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// statsRanges maps the range values accepted by the dashboard stats endpoints
+// to the number of days they cover, ending today. Anything else is rejected
+// so a request can't ask for an unbounded scan.
+var statsRanges = map[string]int{
+	"7d":   7,
+	"30d":  30,
+	"90d":  90,
+	"365d": 365,
+}
+
+// defaultStatsRange is used when the range parameter is omitted.
+const defaultStatsRange = "30d"
+
+// dayCount is one point in a bookingsSeries.
+type dayCount struct {
+	Date  string `json:"date"`  // Day, YYYY-MM-DD
+	Count int    `json:"count"` // Reservations made that day
+}
+
+// bookingsSeries is the JSON body of GET /admin/api/stats/bookings. Days has
+// one entry for every day from Start to End inclusive, oldest first.
+type bookingsSeries struct {
+	Range string     `json:"range"` // Range value the series covers, e.g. "30d"
+	Start string     `json:"start"` // First day, YYYY-MM-DD
+	End   string     `json:"end"`   // Last day (today), YYYY-MM-DD
+	Days  []dayCount `json:"days"`
+}
+
+// AdminBookingsStats handles GET /admin/api/stats/bookings?range=30d, the
+// data behind the dashboard's bookings chart. It returns the number of
+// reservations made on each of the last N days, ending today, with days that
+// had none filled in as zero so the chart has an evenly spaced x-axis.
+//
+// Responses:
+//   - 200 with a bookingsSeries
+//   - 400 invalid_input if range is not one of statsRanges
+//   - 500 server_error if the counts cannot be loaded
+func (m *Repository) AdminBookingsStats(w http.ResponseWriter, r *http.Request) {
+	rng := r.URL.Query().Get("range")
+	if rng == "" {
+		rng = defaultStatsRange
+	}
+	days, ok := statsRanges[rng]
+	if !ok {
+		writeAPIError(w, http.StatusBadRequest, errCodeInvalidInput, "range must be one of "+strings.Join(statsRangeNames(), ", "))
+		return
+	}
+
+	end := m.today().AddDate(0, 0, 1)
+	start := end.AddDate(0, 0, -days)
+
+	counts, err := m.DB.BookingsPerDay(start, end)
+	if err != nil {
+		m.App.ErrorLog.Println(err)
+		writeAPIError(w, http.StatusInternalServerError, errCodeServer, "Error querying database")
+		return
+	}
+
+	byDay := make(map[string]int, len(counts))
+	for _, c := range counts {
+		byDay[c.Date.Format("2006-01-02")] += c.Count
+	}
+
+	series := bookingsSeries{
+		Range: rng,
+		Start: start.Format("2006-01-02"),
+		End:   end.AddDate(0, 0, -1).Format("2006-01-02"),
+		Days:  make([]dayCount, 0, days),
+	}
+	for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+		key := d.Format("2006-01-02")
+		series.Days = append(series.Days, dayCount{Date: key, Count: byDay[key]})
+	}
+
+	writeJSON(w, http.StatusOK, series)
+}
+
+// statsRangeNames returns the accepted range values, shortest first, for
+// error messages.
+func statsRangeNames() []string {
+	names := make([]string, 0, len(statsRanges))
+	for name := range statsRanges {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return statsRanges[names[i]] < statsRanges[names[j]] })
+	return names
+}
@@ -1,14 +1,25 @@
 // Package helpers provides small, shared utilities for HTTP handlers and middleware.
 // It centralizes consistent client/server error responses, global helper init,
-// and an authentication check that relies on session state.
+// queued flash messages, flash-on-redirect responses, a check that session data was loaded,
+// an authentication check that relies on session state and the middleware
+// that gates admin routes on it,
+// Accept header negotiation, and client IP resolution behind trusted proxies.
 package helpers
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"mime"
+	"net"
 	"net/http"
 	"runtime/debug"
+	"strconv"
+	"strings"
 
+	"github.com/alexedwards/scs/v2"
 	"github.com/bensabler/milos-residence/internal/config"
+	"github.com/bensabler/milos-residence/internal/models"
 )
 
 // app holds the process-wide application configuration used by helpers.
@@ -44,6 +55,35 @@ func ClientError(w http.ResponseWriter, status int) {
 	http.Error(w, http.StatusText(status), status)
 }
 
+// BodyTooLarge reports whether err came from reading a request body past the
+// limit set by http.MaxBytesReader and, if so, writes a 413 response.
+//
+// Parameters:
+//   - w: response writer
+//   - err: error returned by r.ParseForm or a body read
+//
+// Returns:
+//   - bool: true when a 413 Request Entity Too Large response was written;
+//     callers should return immediately.
+//
+// Usage:
+//
+//	if err := r.ParseForm(); err != nil {
+//		if helpers.BodyTooLarge(w, err) {
+//			return
+//		}
+//		// ...handle other parse errors
+//	}
+func BodyTooLarge(w http.ResponseWriter, err error) bool {
+	var maxErr *http.MaxBytesError
+	if !errors.As(err, &maxErr) {
+		return false
+	}
+
+	ClientError(w, http.StatusRequestEntityTooLarge)
+	return true
+}
+
 // ServerError writes a standardized 500 response and logs a stack trace.
 // It captures the current stack and the error message for diagnostics.
 //
@@ -72,9 +112,346 @@ func ServerError(w http.ResponseWriter, err error) {
 //   - r: current HTTP request
 //
 // Returns:
-//   - bool: true when a user_id exists in session; otherwise false.
+//   - bool: true when a user_id exists in session; otherwise false, including
+//     when no session was loaded for the request.
 func IsAuthenticated(r *http.Request) bool {
+	// A request without a loaded session can't carry a login.
+	session, err := SessionFromContext(r.Context(), app.Session)
+	if err != nil {
+		return false
+	}
+
 	// Lookup a user marker in the session to indicate authentication.
-	exists := app.Session.Exists(r.Context(), "user_id")
+	exists := session.Exists(r.Context(), "user_id")
 	return exists
 }
+
+// RequireAuth returns middleware that lets a request through only when the
+// session sm has a "user_id". Anyone else gets the one-time error
+// "Log in first!" and a 303 redirect to /user/login. The session manager is a
+// parameter so the production binary and the handler tests can each wire in
+// their own.
+//
+// Parameters:
+//   - sm: the session manager whose LoadAndSave runs earlier in the chain.
+//
+// Returns:
+//   - func(http.Handler) http.Handler: middleware for chi's Use.
+//
+// Usage:
+//
+//	mux.Route("/admin", func(mux chi.Router) {
+//		mux.Use(helpers.RequireAuth(session))
+//	})
+func RequireAuth(sm *scs.SessionManager) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			session, err := SessionFromContext(r.Context(), sm)
+			if err != nil {
+				// Without a session there is nowhere to leave the message.
+				http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+				return
+			}
+
+			if !session.Exists(r.Context(), "user_id") {
+				session.Put(r.Context(), "error", "Log in first!")
+				http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// AddFlash queues a one-time message for the next rendered page. Unlike the
+// single "flash"/"error"/"warning" session strings, queued messages accumulate,
+// so consecutive operations before a render do not overwrite each other.
+//
+// Parameters:
+//   - r: current HTTP request (must carry a loaded session)
+//   - level: models.FlashSuccess, models.FlashWarning or models.FlashError
+//   - msg: message text shown to the user
+//
+// Usage:
+//
+//	helpers.AddFlash(r, models.FlashSuccess, "Reservation deleted!")
+func AddFlash(r *http.Request, level, msg string) {
+	flashes, _ := app.Session.Get(r.Context(), models.FlashSessionKey).([]models.FlashMessage)
+	flashes = append(flashes, models.FlashMessage{Level: level, Text: msg})
+	app.Session.Put(r.Context(), models.FlashSessionKey, flashes)
+}
+
+// RedirectWithError stores msg as the session's "error" message and sends a
+// 303 See Other to url, where the next rendered page shows it. It replaces
+// the Session.Put plus http.Redirect pair so every such redirect uses the
+// same status code.
+//
+// Parameters:
+//   - w: response writer
+//   - r: current HTTP request (must carry a loaded session)
+//   - session: session manager holding the message
+//   - url: redirect target, e.g. "/"
+//   - msg: message text shown to the user
+//
+// Usage:
+//
+//	helpers.RedirectWithError(w, r, m.App.Session, "/", "can't find room!")
+//	return
+func RedirectWithError(w http.ResponseWriter, r *http.Request, session *scs.SessionManager, url, msg string) {
+	redirectWithMessage(w, r, session, "error", url, msg)
+}
+
+// RedirectWithFlash is RedirectWithError for success messages: msg is stored
+// as the session's "flash" message before the 303 redirect to url.
+//
+// Usage:
+//
+//	helpers.RedirectWithFlash(w, r, m.App.Session, "/admin/dashboard", "Changes saved")
+//	return
+func RedirectWithFlash(w http.ResponseWriter, r *http.Request, session *scs.SessionManager, url, msg string) {
+	redirectWithMessage(w, r, session, "flash", url, msg)
+}
+
+// redirectWithMessage puts msg under key in the session and redirects to url
+// with 303 See Other.
+// Without a loaded session the message is logged and dropped, but the
+// redirect still happens.
+func redirectWithMessage(w http.ResponseWriter, r *http.Request, session *scs.SessionManager, key, url, msg string) {
+	if _, err := SessionFromContext(r.Context(), session); err != nil {
+		app.ErrorLog.Printf("%v; dropping %s message %q", err, key, msg)
+	} else {
+		session.Put(r.Context(), key, msg)
+	}
+	http.Redirect(w, r, url, http.StatusSeeOther)
+}
+
+// ErrNoSession is returned by SessionFromContext when the request carries no
+// session data, which means the route is not behind the SessionLoad
+// middleware (or the handler was called directly).
+var ErrNoSession = errors.New("helpers: no session loaded for request; is the route behind SessionLoad?")
+
+// SessionFromContext returns session when the session middleware has loaded
+// data for ctx, and ErrNoSession otherwise. scs panics on Get, Put and the
+// other accessors when no data was loaded, so handlers reached by a route
+// that might be misconfigured check first and fail with a response instead.
+//
+// Parameters:
+//   - ctx: request context
+//   - session: the application's session manager; nil is treated as not loaded
+//
+// Usage:
+//
+//	session, err := helpers.SessionFromContext(r.Context(), m.App.Session)
+//	if err != nil {
+//		// log and redirect instead of panicking
+//	}
+func SessionFromContext(ctx context.Context, session *scs.SessionManager) (*scs.SessionManager, error) {
+	if session == nil || !sessionLoaded(ctx, session) {
+		return nil, ErrNoSession
+	}
+	return session, nil
+}
+
+// sessionLoaded reports whether ctx carries session data for session. scs
+// offers no non-panicking check, so the panic from Status is recovered.
+func sessionLoaded(ctx context.Context, session *scs.SessionManager) (loaded bool) {
+	defer func() {
+		if recover() != nil {
+			loaded = false
+		}
+	}()
+	session.Status(ctx)
+	return true
+}
+
+// WantsJSON reports whether the client prefers a JSON response, based on the
+// Accept header. It is true when application/json is listed with a higher
+// quality than text/html (or html is absent), so browsers, which send
+// text/html first, keep getting pages. Wildcards such as */* are ignored.
+//
+// Parameters:
+//   - r: current HTTP request
+//
+// Usage:
+//
+//	if helpers.WantsJSON(r) {
+//		// ...write JSON instead of rendering a template
+//	}
+func WantsJSON(r *http.Request) bool {
+	var jsonQ, htmlQ float64
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, _ := strings.Cut(part, ";")
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				parsed, err := strconv.ParseFloat(v, 64)
+				if err != nil {
+					parsed = 0
+				}
+				q = parsed
+			}
+		}
+
+		switch strings.ToLower(strings.TrimSpace(mediaType)) {
+		case "application/json":
+			jsonQ = max(jsonQ, q)
+		case "text/html":
+			htmlQ = max(htmlQ, q)
+		}
+	}
+	return jsonQ > 0 && jsonQ > htmlQ
+}
+
+// AbsURL returns path as an absolute URL under the configured BaseURL, for
+// links in emails and other places a relative path won't do. Slashes are
+// normalized, so "https://example.com/" and "user/login" still join to
+// "https://example.com/user/login". A path that is already an absolute
+// http(s) URL is returned unchanged, as is any path when BaseURL is unset.
+//
+// Parameters:
+//   - path: site path such as "/user/login", optionally with a query
+//
+// Usage:
+//
+//	link := helpers.AbsURL("/user/login")
+func AbsURL(path string) string {
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		return path
+	}
+	if app == nil || app.BaseURL == "" {
+		return path
+	}
+	return strings.TrimRight(app.BaseURL, "/") + "/" + strings.TrimLeft(path, "/")
+}
+
+// csrfExemptForms lists the /api endpoints that accept form posts without a
+// CSRF token. They must not change anything: a cross-site form can reach them.
+var csrfExemptForms = map[string]bool{
+	"/api/quote": true, // dry-run quote, nothing is written
+}
+
+// CSRFExempt reports whether r may skip the CSRF token check. Only /api
+// requests qualify, and only when a cross-site page can't forge them:
+//   - GET and other safe methods, which nosurf never checks anyway;
+//   - form posts to the read-only endpoints in csrfExemptForms;
+//   - requests with a JSON body (Content-Type: application/json). Browsers
+//     send those cross-origin only after a CORS preflight, which only
+//     CORS_ALLOWED_ORIGINS pass, and HTML forms can't produce them.
+//
+// So a form posted from another site to a state-changing endpoint such as
+// POST /api/reservations still needs a token and is refused without one.
+func CSRFExempt(r *http.Request) bool {
+	if !strings.HasPrefix(r.URL.Path, "/api/") {
+		return false
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	if csrfExemptForms[r.URL.Path] {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "application/json"
+}
+
+// ParseTrustedProxies parses a list of CIDRs ("10.0.0.0/8") or single
+// addresses ("192.0.2.10", "::1") into networks for AppConfig.TrustedProxies.
+// A single address is treated as a /32 (or /128 for IPv6). Blank entries are
+// skipped.
+//
+// Parameters:
+//   - list: entries from TRUSTED_PROXIES
+//
+// Returns:
+//   - []*net.IPNet: parsed networks, in input order
+//   - error: names the first entry that is neither a CIDR nor an IP address
+func ParseTrustedProxies(list []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, entry := range list {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if _, n, err := net.ParseCIDR(entry); err == nil {
+			nets = append(nets, n)
+			continue
+		}
+		ip := net.ParseIP(entry)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: want a CIDR or IP address", entry)
+		}
+		bits := 128
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 32
+		}
+		nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+	}
+	return nets, nil
+}
+
+// ClientIP returns the IP address of the client that made r, for logging and
+// per-client limits.
+//
+// Forwarding headers are only believed when the immediate peer (RemoteAddr)
+// is in AppConfig.TrustedProxies; otherwise anyone could claim any address.
+// Behind a trusted proxy, X-Forwarded-For is read right to left, skipping
+// further trusted proxies, and the first other address is the client. When
+// that header is absent, X-Real-IP is used. A malformed entry stops the
+// search and the last trusted hop is returned, since nothing to its left can
+// be relied on.
+//
+// Parameters:
+//   - r: current HTTP request
+//
+// Returns:
+//   - string: client IP address without a port; RemoteAddr as-is if it can't
+//     be parsed
+//
+// Usage:
+//
+//	app.InfoLog.Printf("spam from %s", helpers.ClientIP(r))
+func ClientIP(r *http.Request) string {
+	peer := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(peer); err == nil {
+		peer = host
+	}
+	if !trustedProxy(net.ParseIP(peer)) {
+		return peer
+	}
+
+	if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		hops := strings.Split(strings.Join(xff, ","), ",")
+		client := peer
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip := net.ParseIP(strings.TrimSpace(hops[i]))
+			if ip == nil {
+				break
+			}
+			client = ip.String()
+			if !trustedProxy(ip) {
+				break
+			}
+		}
+		return client
+	}
+
+	if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ip != nil {
+		return ip.String()
+	}
+	return peer
+}
+
+// trustedProxy reports whether ip is inside one of AppConfig.TrustedProxies.
+func trustedProxy(ip net.IP) bool {
+	if ip == nil || app == nil {
+		return false
+	}
+	for _, n := range app.TrustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
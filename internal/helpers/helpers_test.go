@@ -0,0 +1,225 @@
+package helpers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/bensabler/milos-residence/internal/config"
+)
+
+// TestAbsURL verifies that AbsURL joins BaseURL and path with exactly one
+// slash, keeps any path prefix on the base, and leaves absolute URLs and
+// unconfigured bases alone.
+func TestAbsURL(t *testing.T) {
+	defer NewHelpers(app)
+
+	tests := []struct {
+		name string
+		base string
+		path string
+		want string
+	}{
+		{name: "no trailing slash", base: "https://example.com", path: "/user/login", want: "https://example.com/user/login"},
+		{name: "trailing slash", base: "https://example.com/", path: "/user/login", want: "https://example.com/user/login"},
+		{name: "relative path", base: "https://example.com", path: "user/login", want: "https://example.com/user/login"},
+		{name: "both slashes", base: "https://example.com//", path: "//user/login", want: "https://example.com/user/login"},
+		{name: "base with prefix", base: "https://example.com/milo/", path: "/rooms?x=1", want: "https://example.com/milo/rooms?x=1"},
+		{name: "root", base: "https://example.com", path: "/", want: "https://example.com/"},
+		{name: "absolute url input", base: "https://example.com", path: "https://cdn.example.net/a.jpg", want: "https://cdn.example.net/a.jpg"},
+		{name: "no base url", base: "", path: "/user/login", want: "/user/login"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			NewHelpers(&config.AppConfig{BaseURL: tc.base})
+			if got := AbsURL(tc.path); got != tc.want {
+				t.Errorf("AbsURL(%q) with base %q: got %q, want %q", tc.path, tc.base, got, tc.want)
+			}
+		})
+	}
+}
+
+// TestRedirectWithMessage verifies that RedirectWithError and
+// RedirectWithFlash store the message under their session key and answer
+// with a 303 to the given URL.
+func TestRedirectWithMessage(t *testing.T) {
+	tests := []struct {
+		name     string
+		redirect func(http.ResponseWriter, *http.Request, *scs.SessionManager, string, string)
+		key      string
+	}{
+		{name: "error", redirect: RedirectWithError, key: "error"},
+		{name: "flash", redirect: RedirectWithFlash, key: "flash"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			session := scs.New()
+
+			var got string
+			h := session.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				tc.redirect(w, r, session, "/rooms?x=1", "Saved!")
+				got = session.GetString(r.Context(), tc.key)
+			}))
+
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/", nil))
+
+			if rr.Code != http.StatusSeeOther {
+				t.Errorf("status: got %d, want %d", rr.Code, http.StatusSeeOther)
+			}
+			if loc := rr.Header().Get("Location"); loc != "/rooms?x=1" {
+				t.Errorf("Location: got %q, want %q", loc, "/rooms?x=1")
+			}
+			if got != "Saved!" {
+				t.Errorf("session %q: got %q, want %q", tc.key, got, "Saved!")
+			}
+		})
+	}
+}
+
+// TestSessionFromContext verifies that a request without loaded session data
+// is reported as ErrNoSession rather than panicking, that a loaded one
+// returns the manager, and that the redirect helpers still redirect (and log
+// the dropped message) when no session was loaded.
+func TestSessionFromContext(t *testing.T) {
+	defer NewHelpers(app)
+	var logged bytes.Buffer
+	NewHelpers(&config.AppConfig{ErrorLog: log.New(&logged, "", 0)})
+
+	session := scs.New()
+
+	if _, err := SessionFromContext(context.Background(), session); !errors.Is(err, ErrNoSession) {
+		t.Errorf("no session data: got %v, want ErrNoSession", err)
+	}
+	if _, err := SessionFromContext(context.Background(), nil); !errors.Is(err, ErrNoSession) {
+		t.Errorf("nil manager: got %v, want ErrNoSession", err)
+	}
+
+	var loaded *scs.SessionManager
+	var loadedErr error
+	h := session.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		loaded, loadedErr = SessionFromContext(r.Context(), session)
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if loadedErr != nil || loaded != session {
+		t.Errorf("loaded session: got %v, %v", loaded, loadedErr)
+	}
+
+	rr := httptest.NewRecorder()
+	RedirectWithError(rr, httptest.NewRequest(http.MethodGet, "/", nil), session, "/rooms", "Oops")
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/rooms" {
+		t.Errorf("redirect without session: got %d to %q", rr.Code, rr.Header().Get("Location"))
+	}
+	if !strings.Contains(logged.String(), "Oops") {
+		t.Errorf("dropped message not logged: %q", logged.String())
+	}
+}
+
+// TestClientIP verifies that forwarding headers are honored only when the
+// immediate peer is a trusted proxy, that chains of trusted proxies are
+// skipped, and that malformed headers never yield a spoofed address.
+func TestClientIP(t *testing.T) {
+	defer NewHelpers(app)
+
+	trusted, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.0.2.10", "::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	NewHelpers(&config.AppConfig{TrustedProxies: trusted})
+
+	tests := []struct {
+		name   string
+		remote string
+		xff    []string
+		realIP string
+		want   string
+	}{
+		{name: "trusted proxy uses XFF", remote: "10.1.2.3:5555", xff: []string{"203.0.113.7"}, want: "203.0.113.7"},
+		{name: "trusted single address", remote: "192.0.2.10:80", xff: []string{"203.0.113.7"}, want: "203.0.113.7"},
+		{name: "trusted IPv6 loopback", remote: "[::1]:443", xff: []string{"2001:db8::1"}, want: "2001:db8::1"},
+		{name: "untrusted peer ignores XFF", remote: "198.51.100.9:5555", xff: []string{"203.0.113.7"}, want: "198.51.100.9"},
+		{name: "untrusted peer ignores X-Real-IP", remote: "198.51.100.9:5555", realIP: "203.0.113.7", want: "198.51.100.9"},
+		{name: "spoofed left entry skipped", remote: "10.1.2.3:5555", xff: []string{"1.2.3.4, 203.0.113.7"}, want: "203.0.113.7"},
+		{name: "chain of trusted proxies", remote: "10.1.2.3:5555", xff: []string{"203.0.113.7, 10.9.9.9"}, want: "203.0.113.7"},
+		{name: "repeated headers", remote: "10.1.2.3:5555", xff: []string{"1.2.3.4", "203.0.113.7"}, want: "203.0.113.7"},
+		{name: "X-Real-IP without XFF", remote: "10.1.2.3:5555", realIP: "203.0.113.8", want: "203.0.113.8"},
+		{name: "malformed XFF", remote: "10.1.2.3:5555", xff: []string{"not-an-ip"}, want: "10.1.2.3"},
+		{name: "malformed entry behind trusted hop", remote: "10.1.2.3:5555", xff: []string{"203.0.113.7, garbage, 10.9.9.9"}, want: "10.9.9.9"},
+		{name: "empty XFF", remote: "10.1.2.3:5555", xff: []string{""}, want: "10.1.2.3"},
+		{name: "malformed X-Real-IP", remote: "10.1.2.3:5555", realIP: "203.0.113.8:99", want: "10.1.2.3"},
+		{name: "remote without port", remote: "198.51.100.9", want: "198.51.100.9"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tc.remote
+			for _, v := range tc.xff {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			if tc.realIP != "" {
+				r.Header.Set("X-Real-IP", tc.realIP)
+			}
+			if got := ClientIP(r); got != tc.want {
+				t.Errorf("ClientIP: got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestParseTrustedProxies verifies that CIDRs and single addresses are
+// accepted and anything else is rejected.
+func TestParseTrustedProxies(t *testing.T) {
+	nets, err := ParseTrustedProxies([]string{"10.0.0.0/8", " 192.0.2.10 ", "", "2001:db8::/32"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nets) != 3 || nets[1].String() != "192.0.2.10/32" {
+		t.Errorf("networks: got %v", nets)
+	}
+
+	if _, err := ParseTrustedProxies([]string{"10.0.0.0/8", "proxy.local"}); err == nil {
+		t.Error("expected an error for a hostname")
+	}
+}
+
+// TestCSRFExempt verifies that only /api requests a cross-site page can't
+// forge skip the CSRF check: reads, form posts to the read-only quote, and
+// JSON bodies. A form post to a state-changing endpoint is not exempt.
+func TestCSRFExempt(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		want        bool
+	}{
+		{"api read", http.MethodGet, "/api/session/status", "", true},
+		{"quote form", http.MethodPost, "/api/quote", "application/x-www-form-urlencoded", true},
+		{"reservation json", http.MethodPost, "/api/reservations", "application/json; charset=utf-8", true},
+		{"reservation form", http.MethodPost, "/api/reservations", "application/x-www-form-urlencoded", false},
+		{"reservation text", http.MethodPost, "/api/reservations", "text/plain", false},
+		{"reservation no type", http.MethodPost, "/api/reservations", "", false},
+		{"outside api json", http.MethodPost, "/make-reservation", "application/json", false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			if got := CSRFExempt(req); got != tc.want {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
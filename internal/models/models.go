@@ -4,7 +4,11 @@
 // can be reused in handlers, repositories, and templates without side effects.
 package models
 
-import "time"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
 
 // User represents an application user with authorization context.
 // Password is expected to be stored as a secure hash (never plaintext).
@@ -15,14 +19,30 @@ type User struct {
 	Email       string    // Unique email address for login/notifications
 	Password    string    // Hashed password (implementation detail outside this package)
 	AccessLevel int       // Authorization level/role; higher implies more privileges
-	CreatedAt   time.Time // Creation timestamp (UTC recommended)
+	CreatedAt   time.Time // Creation timestamp (UTC)
 	UpdatedAt   time.Time // Last update timestamp
 }
 
 // Room represents a reservable unit (e.g., a named suite).
 type Room struct {
+	ID          int       `json:"id"`           // Primary key
+	RoomName    string    `json:"room_name"`    // Human-readable name (unique display label)
+	NightlyRate int       `json:"nightly_rate"` // Base price per night in cents; room_rates may override per date
+	MaxGuests   int       `json:"max_guests"`   // Maximum number of guests the room sleeps
+	Active      bool      `json:"active"`       // False while the room is closed and not offered to guests
+	LeadDays    *int      `json:"lead_days"`    // Days of notice a booking needs; nil uses AppConfig.MinLeadDays
+	CreatedAt   time.Time `json:"created_at"`   // Creation timestamp
+	UpdatedAt   time.Time `json:"updated_at"`   // Last update timestamp
+}
+
+// RoomImage is one photo in a room's gallery. Images are shown in ascending
+// SortOrder, with ties broken by ID (insertion order).
+type RoomImage struct {
 	ID        int       // Primary key
-	RoomName  string    // Human-readable name (unique display label)
+	RoomID    int       // Foreign key to Room
+	URL       string    // Image location, e.g. "/static/images/loft-4.jpg"
+	Caption   string    // Alt text and caption; may be empty
+	SortOrder int       // Position in the gallery, lowest first
 	CreatedAt time.Time // Creation timestamp
 	UpdatedAt time.Time // Last update timestamp
 }
@@ -37,18 +57,135 @@ type Restriction struct {
 
 // Reservation represents a booking request/record for a room across a date range.
 type Reservation struct {
+	ID        int       `json:"id"`         // Primary key
+	FirstName string    `json:"first_name"` // Guest given name
+	LastName  string    `json:"last_name"`  // Guest family name
+	Email     string    `json:"email"`      // Guest email for correspondence
+	Phone     string    `json:"phone"`      // Guest phone number
+	StartDate time.Time `json:"start_date"` // Check-in (inclusive)
+	EndDate   time.Time `json:"end_date"`   // Check-out (exclusive by convention unless specified)
+	RoomID    int       `json:"room_id"`    // Foreign key to Room
+	CreatedAt time.Time `json:"created_at"` // Creation timestamp
+	UpdatedAt time.Time `json:"updated_at"` // Last update timestamp
+	Processed int       `json:"processed"`  // ReservationNew or ReservationProcessed; derived from Status
+	Status    string    `json:"status"`     // Lifecycle stage, one of ReservationStatuses
+	Total     int       `json:"total"`      // Sum of nightly rates in cents, computed at booking
+	Notes     string    `json:"notes"`      // Staff-only notes such as special requests
+	Room      Room      `json:"room"`       // Eager-loaded room details (optional; zero value if not set)
+
+	TermsAcceptedAt time.Time `json:"terms_accepted_at"` // When the guest accepted the terms at booking
+}
+
+// Values of Reservation.Processed. Staff mark a new reservation processed
+// once they have reviewed it; no other values are stored.
+const (
+	ReservationNew       = 0 // Awaiting staff review
+	ReservationProcessed = 1 // Reviewed by staff
+)
+
+// Values of Reservation.Status, in lifecycle order. A reservation starts
+// pending; Processed is ReservationProcessed for every status after it.
+const (
+	StatusPending    = "pending"     // Booked, awaiting staff review
+	StatusConfirmed  = "confirmed"   // Reviewed and confirmed by staff
+	StatusCheckedIn  = "checked_in"  // Guest has arrived
+	StatusCheckedOut = "checked_out" // Guest has left
+	StatusCancelled  = "cancelled"   // Called off before arrival
+)
+
+// ReservationStatuses lists every valid Reservation.Status in lifecycle order.
+var ReservationStatuses = []string{StatusPending, StatusConfirmed, StatusCheckedIn, StatusCheckedOut, StatusCancelled}
+
+// StatusTransitions maps each status to the statuses a reservation may move
+// to from it. Checked-out and cancelled reservations are final. Confirmed
+// may return to pending, matching the admin "Reopen" action.
+var StatusTransitions = map[string][]string{
+	StatusPending:   {StatusConfirmed, StatusCancelled},
+	StatusConfirmed: {StatusCheckedIn, StatusCancelled, StatusPending},
+	StatusCheckedIn: {StatusCheckedOut},
+}
+
+// CanTransition reports whether a reservation in status from may be moved to
+// status to.
+func CanTransition(from, to string) bool {
+	for _, next := range StatusTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// ProcessedForStatus returns the Processed value stored alongside status:
+// ReservationNew while pending, ReservationProcessed afterwards.
+func ProcessedForStatus(status string) int {
+	if status == StatusPending {
+		return ReservationNew
+	}
+	return ReservationProcessed
+}
+
+// WaitlistEntry records a guest who asked to be notified when rooms free up
+// for a date range that had no availability at search time.
+type WaitlistEntry struct {
 	ID        int       // Primary key
 	FirstName string    // Guest given name
 	LastName  string    // Guest family name
-	Email     string    // Guest email for correspondence
+	Email     string    // Guest email for follow-up
 	Phone     string    // Guest phone number
-	StartDate time.Time // Check-in (inclusive)
-	EndDate   time.Time // Check-out (exclusive by convention unless specified)
-	RoomID    int       // Foreign key to Room
+	StartDate time.Time // Requested arrival (inclusive)
+	EndDate   time.Time // Requested departure (exclusive)
 	CreatedAt time.Time // Creation timestamp
 	UpdatedAt time.Time // Last update timestamp
-	Processed int       // Processing status flag (0/1 or enum mapping)
-	Room      Room      // Eager-loaded room details (optional; zero value if not set)
+}
+
+// AuditEntry records one administrative action (an edit, delete, process or
+// calendar block change) and the staff member who performed it.
+type AuditEntry struct {
+	ID        int       // Primary key
+	UserID    int       // Foreign key to User who acted
+	UserEmail string    // Acting user's email, joined for display (optional)
+	Action    string    // Short action key, e.g. "reservation.delete"
+	Detail    string    // Human-readable description of what changed
+	CreatedAt time.Time // When the action was recorded
+}
+
+// Outcomes recorded in MailLogEntry.Status.
+const (
+	MailStatusSent   = "sent"   // Accepted by the SMTP server
+	MailStatusFailed = "failed" // Connection, template or send failure
+)
+
+// MailLogEntry records one attempt to send an email, kept so owners have a
+// record of what was sent to whom. The message body is not stored.
+type MailLogEntry struct {
+	ID        int       // Primary key
+	To        string    // Recipient email address
+	From      string    // Sender email address
+	Subject   string    // Message subject line
+	Template  string    // Template the body was wrapped in, if any
+	Status    string    // MailStatusSent or MailStatusFailed
+	CreatedAt time.Time // When the attempt was made
+}
+
+// OutboundMail is a message held in the outbound_mail dead-letter queue after
+// it could not be delivered. Unlike MailLogEntry it keeps the body, so the
+// message can be sent again exactly as it was.
+type OutboundMail struct {
+	ID        int       // Primary key
+	To        string    // Recipient email address
+	From      string    // Sender email address
+	Subject   string    // Message subject line
+	Template  string    // Template the body is wrapped in, if any
+	Content   string    // Message body as queued
+	Status    string    // MailStatusFailed while it waits for a retry
+	Error     string    // Error from the last delivery attempt
+	CreatedAt time.Time // When the message was dead-lettered
+}
+
+// Mail returns the message as MailData, ready to be queued again.
+func (o OutboundMail) Mail() MailData {
+	return MailData{To: o.To, From: o.From, Subject: o.Subject, Content: o.Content, Template: o.Template}
 }
 
 // RoomRestriction associates a restriction with a specific room (and optionally
@@ -60,12 +197,90 @@ type RoomRestriction struct {
 	RoomID        int         // Foreign key to Room
 	ReservationID int         // Optional link to Reservation (0 if not tied)
 	RestrictionID int         // Foreign key to Restriction
+	Note          string      // Staff note explaining a block (empty for reservations)
 	UpdatedAt     time.Time   // Last update timestamp
 	Room          Room        // Eager-loaded Room (optional)
 	Reservation   Reservation // Eager-loaded Reservation (optional)
 	Restriction   Restriction // Eager-loaded Restriction (optional)
 }
 
+// RestrictionConflict pairs two room restrictions on the same room whose date
+// ranges overlap. Such pairs indicate data drift (e.g., a block added over an
+// existing reservation) and are surfaced for manual cleanup.
+type RestrictionConflict struct {
+	Room   Room            // Room both restrictions apply to
+	First  RoomRestriction // Earlier-created restriction (lower ID)
+	Second RoomRestriction // Later-created restriction (higher ID)
+}
+
+// DateRange is a run of nights, from Start up to (not including) End, the
+// same convention as a stay's check-in and check-out dates.
+type DateRange struct {
+	Start time.Time // First night (UTC midnight)
+	End   time.Time // Morning after the last night (UTC midnight)
+}
+
+// Nights returns the number of nights the range covers.
+func (d DateRange) Nights() int {
+	return int(d.End.Sub(d.Start).Hours() / 24)
+}
+
+// TurnoverTimes are the clock times a stay starts and ends on its check-in and
+// check-out days, as offsets from midnight. They turn the day-granular dates
+// stored on reservations and restrictions into the instants a room is
+// actually occupied, so a guest can check out the same day another checks in.
+type TurnoverTimes struct {
+	CheckIn  time.Duration // Offset of check-in from midnight, e.g. 15h
+	CheckOut time.Duration // Offset of check-out from midnight, e.g. 11h
+}
+
+// DefaultTurnoverTimes is 3:00 PM check-in and 11:00 AM check-out, matching
+// the times shown to guests when none are configured.
+var DefaultTurnoverTimes = TurnoverTimes{CheckIn: 15 * time.Hour, CheckOut: 11 * time.Hour}
+
+// CheckInAt returns the check-in instant on day's calendar date. Only the
+// year, month and day of day are used; the result is in UTC, like stored dates.
+func (t TurnoverTimes) CheckInAt(day time.Time) time.Time {
+	return utcDay(day).Add(t.CheckIn)
+}
+
+// CheckOutAt returns the check-out instant on day's calendar date, in UTC.
+func (t TurnoverTimes) CheckOutAt(day time.Time) time.Time {
+	return utcDay(day).Add(t.CheckOut)
+}
+
+// utcDay returns midnight UTC on day's calendar date.
+func utcDay(day time.Time) time.Time {
+	y, m, d := day.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// clockLayouts are the time-of-day formats ParseClock accepts.
+var clockLayouts = []string{"3:04 PM", "3:04PM", "3 PM", "3PM", "15:04"}
+
+// ParseClock parses a time of day such as "3:00 PM", "11am" or "15:00" into
+// its offset from midnight.
+//
+// Returns:
+//   - time.Duration: offset from midnight, 0 to just under 24h
+//   - error: non-nil when s matches none of the accepted formats
+func ParseClock(s string) (time.Duration, error) {
+	v := strings.ToUpper(strings.TrimSpace(s))
+	for _, layout := range clockLayouts {
+		if t, err := time.Parse(layout, v); err == nil {
+			return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid time of day %q", s)
+}
+
+// DayCount is one day in a time series of counts, such as the number of
+// reservations made on that day.
+type DayCount struct {
+	Date  time.Time // Calendar day (UTC midnight)
+	Count int       // Number of items on that day
+}
+
 // MailData contains information needed to send an email message, optionally
 // referencing a template name for rendering the body.
 type MailData struct {
@@ -74,4 +289,9 @@ type MailData struct {
 	Subject  string // Message subject line
 	Content  string // Raw content; may be ignored if Template is used
 	Template string // Template identifier for render pipeline (optional)
+
+	// Sensitive marks a message whose body carries a secret, such as a
+	// single-use password reset link. It is never written to the failed-mail
+	// queue.
+	Sensitive bool
 }
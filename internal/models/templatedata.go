@@ -17,6 +17,27 @@ type TemplateData struct {
 	Flash           string                 // One-time success/info message
 	Warning         string                 // One-time warning message
 	Error           string                 // One-time error message
+	Flashes         []FlashMessage         // All one-time messages queued for this render
 	Form            *forms.Form            // Optional form state/validation
 	IsAuthenticated int                    // 1 if user is authenticated; else 0
+	PropertyName    string                 // Configured property name for titles and headings
+}
+
+// Flash message levels. The values double as the notification type passed to
+// the layout's notify() helper.
+const (
+	FlashSuccess = "success"
+	FlashWarning = "warning"
+	FlashError   = "error"
+)
+
+// FlashSessionKey is the session key holding queued []FlashMessage values.
+const FlashSessionKey = "flashes"
+
+// FlashMessage is a one-time UI message with a severity level. Handlers queue
+// them with helpers.AddFlash; render.AddDefaultData pops them into
+// TemplateData.Flashes so several messages can survive a single redirect.
+type FlashMessage struct {
+	Level string // FlashSuccess, FlashWarning or FlashError
+	Text  string // Message shown to the user
 }
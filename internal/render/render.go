@@ -3,7 +3,7 @@
 // default view data (CSRF token, flash messages, auth status), and exposes
 // small template helpers via template.FuncMap. The package is configured at
 // startup with an AppConfig and assumes templates live under pathToTemplates
-// using *.page.tmpl and *.layout.tmpl naming conventions.
+// using *.page.tmpl, *.layout.tmpl, and *.partial.tmpl naming conventions.
 package render
 
 import (
@@ -11,6 +11,7 @@ import (
 	"errors"
 	"fmt"
 	"html/template"
+	"io"
 	"log"
 	"net/http"
 	"path/filepath"
@@ -26,6 +27,9 @@ import (
 var functions = template.FuncMap{
 	"humanDate":  HumanDate,
 	"formatDate": FormatDate,
+	"prettyDate": PrettyDate,
+	"dateRange":  DateRange,
+	"localTime":  LocalTime,
 	"iterate":    Iterate,
 	"add":        Add,
 }
@@ -71,10 +75,51 @@ func FormatDate(t time.Time, f string) string {
 	return t.Format(f)
 }
 
+// LocalTime returns the timestamp t in app.DisplayLocation (the server's
+// local zone when unset), for showing stored UTC timestamps such as
+// CreatedAt. Use it before formatDate or humanDate, e.g.
+// {{formatDate (localTime .CreatedAt) "2006-01-02 15:04"}}. Calendar dates
+// like a stay's StartDate are not instants and should not be converted.
+func LocalTime(t time.Time) time.Time {
+	loc := time.Local
+	if app != nil && app.DisplayLocation != nil {
+		loc = app.DisplayLocation
+	}
+	return t.In(loc)
+}
+
+// PrettyDate formats t for guests and staff to read, e.g. "Mon, Jan 2 2006".
+func PrettyDate(t time.Time) string {
+	return t.Format("Mon, Jan 2 2006")
+}
+
+// DateRange formats the stay from a to b as compactly as reads naturally:
+//   - same day:   "Jan 2, 2006"
+//   - same month: "Jan 2 – 5, 2006"
+//   - same year:  "Jan 30 – Feb 2, 2006"
+//   - otherwise:  "Dec 30, 2006 – Jan 2, 2007"
+func DateRange(a, b time.Time) string {
+	switch {
+	case a.Year() != b.Year():
+		return a.Format("Jan 2, 2006") + " – " + b.Format("Jan 2, 2006")
+	case a.Month() != b.Month():
+		return a.Format("Jan 2") + " – " + b.Format("Jan 2, 2006")
+	case a.Day() != b.Day():
+		return a.Format("Jan 2") + " – " + b.Format("2, 2006")
+	default:
+		return a.Format("Jan 2, 2006")
+	}
+}
+
 // AddDefaultData injects standard cross-page data into td:
 //   - Flash / Error / Warning: one-time messages popped from session
+//   - Flashes: every queued message, including the three above, in order
 //   - CSRFToken: per-request token from nosurf
 //   - IsAuthenticated: 1 if a user_id exists in session, otherwise 0
+//   - PropertyName: the configured property name (AppConfig.Property)
+//
+// The single-string Flash/Error/Warning fields remain for handlers that still
+// Put plain "flash"/"error"/"warning" strings; layouts render Flashes only.
 //
 // Call this immediately before template execution to ensure dynamic values
 // reflect the current request/session state.
@@ -83,6 +128,23 @@ func AddDefaultData(td *models.TemplateData, r *http.Request) *models.TemplateDa
 	td.Error = app.Session.PopString(r.Context(), "error")
 	td.Warning = app.Session.PopString(r.Context(), "warning")
 	td.CSRFToken = nosurf.Token(r)
+	td.PropertyName = app.Property()
+
+	// Fold legacy single-string messages into the queue, then pop queued ones.
+	var flashes []models.FlashMessage
+	if td.Error != "" {
+		flashes = append(flashes, models.FlashMessage{Level: models.FlashError, Text: td.Error})
+	}
+	if td.Flash != "" {
+		flashes = append(flashes, models.FlashMessage{Level: models.FlashSuccess, Text: td.Flash})
+	}
+	if td.Warning != "" {
+		flashes = append(flashes, models.FlashMessage{Level: models.FlashWarning, Text: td.Warning})
+	}
+	if queued, ok := app.Session.Pop(r.Context(), models.FlashSessionKey).([]models.FlashMessage); ok {
+		flashes = append(flashes, queued...)
+	}
+	td.Flashes = flashes
 
 	if app.Session.Exists(r.Context(), "user_id") {
 		td.IsAuthenticated = 1
@@ -91,6 +153,16 @@ func AddDefaultData(td *models.TemplateData, r *http.Request) *models.TemplateDa
 	return td
 }
 
+// templateCache returns the cache to render from. When app.UseCache is true
+// the prebuilt app.TemplateCache is used; otherwise a fresh cache is parsed
+// from disk so template edits show up without a restart.
+func templateCache() (map[string]*template.Template, error) {
+	if app.UseCache {
+		return app.TemplateCache, nil
+	}
+	return CreateTemplateCache()
+}
+
 // Template resolves and executes the named template into w using td as data.
 // Behavior depends on configuration:
 //   - If app.UseCache is true, it uses app.TemplateCache.
@@ -106,20 +178,11 @@ func AddDefaultData(td *models.TemplateData, r *http.Request) *models.TemplateDa
 //   - td: TemplateData to render (nil-safe; AddDefaultData will enrich it)
 func Template(w http.ResponseWriter, r *http.Request, tmpl string, td *models.TemplateData) error {
 	// Choose cache based on configuration.
-	var (
-		tc  map[string]*template.Template
-		err error
-	)
-
-	if app.UseCache {
-		tc = app.TemplateCache
-	} else {
-		tc, err = CreateTemplateCache()
-		if err != nil {
-			log.Printf("error creating template cache: %v", err)
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-			return err
-		}
+	tc, err := templateCache()
+	if err != nil {
+		log.Printf("error creating template cache: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return err
 	}
 
 	// Lookup the requested template.
@@ -149,13 +212,122 @@ func Template(w http.ResponseWriter, r *http.Request, tmpl string, td *models.Te
 	return nil
 }
 
-// CreateTemplateCache parses all page and layout templates under pathToTemplates
-// and returns a cache keyed by page template filename. Each entry is a compiled
-// template with the shared helper FuncMap attached.
+// TemplateStream is Template without the buffer: it executes the named
+// template straight into w, so a very large page (e.g., thousands of admin
+// table rows) goes out in chunks instead of being held in memory first.
+//
+// The tradeoff is partial writes. If execution fails before anything has
+// been written, the client gets the same 500 as from Template; once output
+// has started the status is already sent, so the error is only logged and
+// the client receives a truncated page. Use Template unless the page is
+// known to be large.
+//
+// Parameters match Template.
+func TemplateStream(w http.ResponseWriter, r *http.Request, tmpl string, td *models.TemplateData) error {
+	tc, err := templateCache()
+	if err != nil {
+		log.Printf("error creating template cache: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return err
+	}
+
+	t, ok := tc[tmpl]
+	if !ok {
+		log.Printf("template %q not found in cache", tmpl)
+		http.Error(w, "Template Not Found", http.StatusInternalServerError)
+		return errors.New("can't get template from cache")
+	}
+
+	td = AddDefaultData(td, r)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	sw := &startedWriter{w: w}
+	if err = t.Execute(sw, td); err != nil {
+		log.Printf("error executing template %q: %v", tmpl, err)
+		if !sw.started {
+			http.Error(w, "Template Execution Error", http.StatusInternalServerError)
+		}
+		return err
+	}
+	return nil
+}
+
+// startedWriter passes writes through to w and records whether any output
+// has been sent, after which the response status can no longer change.
+type startedWriter struct {
+	w       io.Writer
+	started bool
+}
+
+func (s *startedWriter) Write(p []byte) (int, error) {
+	if len(p) > 0 {
+		s.started = true
+	}
+	return s.w.Write(p)
+}
+
+// Fragment renders a partial template (*.partial.tmpl) into w without any
+// surrounding layout. It is intended for AJAX callers that swap an HTML
+// snippet into an already-loaded page (e.g., availability results).
+//
+// Unlike Template, Fragment does not pop flash messages from the session:
+// a snippet has nowhere to display them, and consuming them here would hide
+// them from the next full page render. The CSRF token, auth flag and property
+// name are still populated so fragments can contain forms and auth-aware
+// markup.
+//
+// Parameters:
+//   - w: http.ResponseWriter to receive rendered output
+//   - r: current request (used for CSRF and session)
+//   - tmpl: partial template key (e.g., "room-list.partial.tmpl")
+//   - td: TemplateData to render (nil-safe)
+func Fragment(w http.ResponseWriter, r *http.Request, tmpl string, td *models.TemplateData) error {
+	tc, err := templateCache()
+	if err != nil {
+		log.Printf("error creating template cache: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return err
+	}
+
+	t, ok := tc[tmpl]
+	if !ok {
+		log.Printf("fragment %q not found in cache", tmpl)
+		http.Error(w, "Template Not Found", http.StatusInternalServerError)
+		return errors.New("can't get fragment from cache")
+	}
+
+	if td == nil {
+		td = &models.TemplateData{}
+	}
+	td.CSRFToken = nosurf.Token(r)
+	td.PropertyName = app.Property()
+	if app.Session.Exists(r.Context(), "user_id") {
+		td.IsAuthenticated = 1
+	}
+
+	buf := new(bytes.Buffer)
+	if err = t.Execute(buf, td); err != nil {
+		log.Printf("error executing fragment %q: %v", tmpl, err)
+		http.Error(w, "Template Execution Error", http.StatusInternalServerError)
+		return err
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if _, err = buf.WriteTo(w); err != nil {
+		fmt.Println("error writing fragment to response:", err)
+	}
+	return nil
+}
+
+// CreateTemplateCache parses all page, layout, and partial templates under
+// pathToTemplates and returns a cache keyed by template filename. Each entry
+// is a compiled template with the shared helper FuncMap attached.
 //
 // Expected naming:
-//   - Pages:  *.page.tmpl
-//   - Layouts: *.layout.tmpl
+//   - Pages:    *.page.tmpl    (parsed with all layouts and partials)
+//   - Layouts:  *.layout.tmpl
+//   - Partials: *.partial.tmpl (cached standalone for Fragment, and also
+//     attached to every page so pages can {{template "x.partial.tmpl" .}})
 //
 // Returns a non-nil cache map on success. On failure, returns the partial map
 // alongside the encountered error.
@@ -168,6 +340,12 @@ func CreateTemplateCache() (map[string]*template.Template, error) {
 		return myCache, err
 	}
 
+	// Discover partial templates once; they are shared by pages and fragments.
+	partials, err := filepath.Glob(fmt.Sprintf("%s/*.partial.tmpl", pathToTemplates))
+	if err != nil {
+		return myCache, err
+	}
+
 	// Parse each page and its layouts into a single compiled template.
 	for _, page := range pages {
 		name := filepath.Base(page)
@@ -189,6 +367,25 @@ func CreateTemplateCache() (map[string]*template.Template, error) {
 			}
 		}
 
+		// Attach partials so pages can embed the same snippets fragments return.
+		if len(partials) > 0 {
+			if ts, err = ts.ParseFiles(partials...); err != nil {
+				return myCache, err
+			}
+		}
+
+		myCache[name] = ts
+	}
+
+	// Parse each partial standalone (no layout) for Fragment.
+	for _, partial := range partials {
+		name := filepath.Base(partial)
+
+		ts, err := template.New(name).Funcs(functions).ParseFiles(partial)
+		if err != nil {
+			return myCache, err
+		}
+
 		myCache[name] = ts
 	}
 
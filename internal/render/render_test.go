@@ -4,10 +4,16 @@
 package render
 
 import (
+	"fmt"
+	"html/template"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/bensabler/milos-residence/internal/config"
+	"github.com/bensabler/milos-residence/internal/helpers"
 	"github.com/bensabler/milos-residence/internal/models"
 )
 
@@ -29,6 +35,46 @@ func TestAddDefaultData(t *testing.T) {
 	}
 }
 
+// TestAddDefaultData_PropertyName verifies that the configured property name
+// is injected into every render, that the default is used when none is
+// configured, and that layouts show it in place of the built-in name.
+func TestAddDefaultData_PropertyName(t *testing.T) {
+	defer func() { testApp.PropertyName = "" }()
+
+	r, err := getSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := AddDefaultData(&models.TemplateData{}, r).PropertyName; got != config.DefaultPropertyName {
+		t.Errorf("unconfigured: got %q, want %q", got, config.DefaultPropertyName)
+	}
+
+	testApp.PropertyName = "Otis's Bungalow"
+	if got := AddDefaultData(&models.TemplateData{}, r).PropertyName; got != "Otis's Bungalow" {
+		t.Errorf("configured: got %q, want %q", got, "Otis's Bungalow")
+	}
+
+	pathToTemplates = "./../../templates"
+	tc, err := CreateTemplateCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.TemplateCache = tc
+
+	ww := httptest.NewRecorder()
+	if err := Template(ww, r, "home.page.tmpl", &models.TemplateData{}); err != nil {
+		t.Fatal(err)
+	}
+	body := ww.Body.String()
+	if !strings.Contains(body, "<title>Otis&#39;s Bungalow") {
+		t.Error("configured name missing from the page title")
+	}
+	if strings.Contains(body, "Milo's Residence") || strings.Contains(body, "Milo&#39;s Residence") {
+		t.Error("built-in name still rendered")
+	}
+}
+
 // TestRenderTemplate exercises end-to-end template resolution and execution,
 // including handling of a non-existent template key.
 func TestRenderTemplate(t *testing.T) {
@@ -89,3 +135,211 @@ func TestCreateTemplateCache(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+// TestFragment verifies that a partial renders on its own, without the base
+// layout wrapper, and that an unknown fragment key yields an error.
+func TestFragment(t *testing.T) {
+	pathToTemplates = "./../../templates"
+
+	tc, err := CreateTemplateCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.TemplateCache = tc
+
+	r, err := getSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ww := httptest.NewRecorder()
+	data := map[string]interface{}{
+		"rooms": []models.Room{{ID: 1, RoomName: "Golden Haybeam Loft"}},
+	}
+
+	if err = Fragment(ww, r, "room-list.partial.tmpl", &models.TemplateData{Data: data}); err != nil {
+		t.Fatalf("error rendering fragment: %v", err)
+	}
+
+	body := ww.Body.String()
+	if !strings.Contains(body, "Golden Haybeam Loft") {
+		t.Errorf("fragment missing room name; got %q", body)
+	}
+	if strings.Contains(body, "<!DOCTYPE html>") || strings.Contains(body, "<head>") {
+		t.Error("fragment output includes layout boilerplate")
+	}
+
+	if err = Fragment(httptest.NewRecorder(), r, "non-existent.partial.tmpl", nil); err == nil {
+		t.Error("rendered fragment that does not exist")
+	}
+}
+
+// TestTemplateStream verifies that streaming a moderately large admin table
+// produces exactly what the buffered Template produces, and that an unknown
+// template still fails with a 500 before anything is written.
+func TestTemplateStream(t *testing.T) {
+	pathToTemplates = "./../../templates"
+
+	tc, err := CreateTemplateCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.TemplateCache = tc
+	app.UseCache = true
+	defer func() { app.UseCache = false }()
+
+	reservations := make([]models.Reservation, 2000)
+	for i := range reservations {
+		reservations[i] = models.Reservation{
+			ID:        i + 1,
+			FirstName: "Guest",
+			LastName:  fmt.Sprintf("Number%d", i+1),
+			StartDate: time.Date(2050, time.January, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, i),
+			EndDate:   time.Date(2050, time.January, 2, 0, 0, 0, 0, time.UTC).AddDate(0, 0, i),
+			Room:      models.Room{RoomName: "Golden Haybeam Loft"},
+		}
+	}
+	td := func() *models.TemplateData {
+		return &models.TemplateData{
+			Data:      map[string]interface{}{"reservations": reservations},
+			StringMap: map[string]string{"status": "all", "title": "All Reservations"},
+		}
+	}
+
+	r, err := getSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buffered := httptest.NewRecorder()
+	if err := Template(buffered, r, "admin-reservations.page.tmpl", td()); err != nil {
+		t.Fatal(err)
+	}
+	streamed := httptest.NewRecorder()
+	if err := TemplateStream(streamed, r, "admin-reservations.page.tmpl", td()); err != nil {
+		t.Fatal(err)
+	}
+
+	if streamed.Code != http.StatusOK {
+		t.Fatalf("status: got %d", streamed.Code)
+	}
+	if streamed.Body.String() != buffered.Body.String() {
+		t.Fatal("streamed page differs from the buffered page")
+	}
+	if n := strings.Count(streamed.Body.String(), "/admin/reservations/all/"); n != len(reservations) {
+		t.Errorf("rows: got %d, want %d", n, len(reservations))
+	}
+	if !strings.Contains(streamed.Body.String(), "Number2000") {
+		t.Error("last row missing")
+	}
+
+	missing := httptest.NewRecorder()
+	if err := TemplateStream(missing, r, "non-existent.page.tmpl", td()); err == nil {
+		t.Error("streamed template that does not exist")
+	}
+	if missing.Code != http.StatusInternalServerError {
+		t.Errorf("missing template status: got %d, want 500", missing.Code)
+	}
+}
+
+// TestAddDefaultData_Flashes verifies that several queued flash messages, plus
+// a legacy single-string message, all survive to the rendered page.
+func TestAddDefaultData_Flashes(t *testing.T) {
+	helpers.NewHelpers(&testApp)
+	pathToTemplates = "./../../templates"
+
+	tc, err := CreateTemplateCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.TemplateCache = tc
+
+	r, err := getSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session.Put(r.Context(), "error", "legacy error")
+	helpers.AddFlash(r, models.FlashSuccess, "first saved")
+	helpers.AddFlash(r, models.FlashWarning, "second warned")
+
+	ww := httptest.NewRecorder()
+	if err := Template(ww, r, "home.page.tmpl", &models.TemplateData{}); err != nil {
+		t.Fatal(err)
+	}
+
+	body := ww.Body.String()
+	for _, want := range []string{"legacy error", "first saved", "second warned"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("rendered page missing flash %q", want)
+		}
+	}
+
+	// Messages are one-time: a second render must not repeat them.
+	td := AddDefaultData(&models.TemplateData{}, r)
+	if len(td.Flashes) != 0 {
+		t.Errorf("flashes not popped: %+v", td.Flashes)
+	}
+}
+
+// TestPrettyDate verifies the friendly single-date format.
+func TestPrettyDate(t *testing.T) {
+	d := time.Date(2050, time.January, 2, 15, 4, 0, 0, time.UTC)
+	if got, want := PrettyDate(d), "Sun, Jan 2 2050"; got != want {
+		t.Errorf("PrettyDate: got %q, want %q", got, want)
+	}
+}
+
+// TestLocalTime verifies that stored UTC timestamps are shown in the
+// configured display zone, directly and through the localTime template
+// helper, and fall back to the server zone when none is configured.
+func TestLocalTime(t *testing.T) {
+	stored := time.Date(2050, time.January, 2, 3, 30, 0, 0, time.UTC)
+
+	app.DisplayLocation = time.FixedZone("UTC-6", -6*3600)
+	defer func() { app.DisplayLocation = nil }()
+
+	if got, want := LocalTime(stored).Format("2006-01-02 15:04"), "2050-01-01 21:30"; got != want {
+		t.Errorf("LocalTime: got %q, want %q", got, want)
+	}
+
+	tmpl := template.Must(template.New("t").Funcs(functions).Parse(`{{formatDate (localTime .) "2006-01-02 15:04"}}`))
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, stored); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "2050-01-01 21:30"; got != want {
+		t.Errorf("template: got %q, want %q", got, want)
+	}
+
+	app.DisplayLocation = nil
+	if got := LocalTime(stored); got.Location() != time.Local || !got.Equal(stored) {
+		t.Errorf("default zone: got %s", got)
+	}
+}
+
+// TestDateRange verifies that ranges drop repeated month and year parts only
+// when both ends share them.
+func TestDateRange(t *testing.T) {
+	day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name string
+		a, b time.Time
+		want string
+	}{
+		{"same day", day(2050, time.January, 2), day(2050, time.January, 2), "Jan 2, 2050"},
+		{"same month", day(2050, time.January, 2), day(2050, time.January, 5), "Jan 2 – 5, 2050"},
+		{"cross month", day(2050, time.January, 30), day(2050, time.February, 2), "Jan 30 – Feb 2, 2050"},
+		{"cross year", day(2049, time.December, 30), day(2050, time.January, 2), "Dec 30, 2049 – Jan 2, 2050"},
+		{"same month different year", day(2049, time.January, 2), day(2050, time.January, 5), "Jan 2, 2049 – Jan 5, 2050"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := DateRange(tc.a, tc.b); got != tc.want {
+				t.Errorf("DateRange: got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -28,6 +28,7 @@ var testApp config.AppConfig
 // non-production mode with SameSite Lax cookies and non-secure transport.
 func TestMain(m *testing.M) {
 	gob.Register(models.Reservation{})
+	gob.Register([]models.FlashMessage{})
 	testApp.InProduction = false
 
 	infoLog := log.New(os.Stdout, "INFO:\t", log.Ldate|log.Ltime)
@@ -4,8 +4,11 @@ package dbrepo
 
 import (
 	"database/sql"
+	"slices"
+	"time"
 
 	"github.com/bensabler/milos-residence/internal/config"
+	"github.com/bensabler/milos-residence/internal/models"
 	"github.com/bensabler/milos-residence/internal/repository"
 )
 
@@ -41,3 +44,82 @@ func NewTestingRepo(a *config.AppConfig) repository.DatabaseRepo {
 		App: a,
 	}
 }
+
+// turnoverTimes resolves the check-in and check-out clock times from
+// a.CheckInTime and a.CheckOutTime, using models.DefaultTurnoverTimes for any
+// that are unset or unparseable.
+func turnoverTimes(a *config.AppConfig) models.TurnoverTimes {
+	t := models.DefaultTurnoverTimes
+	if a == nil {
+		return t
+	}
+	if d, err := models.ParseClock(a.CheckInTime); err == nil {
+		t.CheckIn = d
+	}
+	if d, err := models.ParseClock(a.CheckOutTime); err == nil {
+		t.CheckOut = d
+	}
+	return t
+}
+
+// occupiedBetween reports whether r occupies its room at any instant from
+// start up to (not including) end. A restriction runs from check-in on its
+// start date to check-out on its end date, so with the default times a stay
+// ending on the 3rd at 11:00 does not touch one starting that day at 15:00.
+func occupiedBetween(r models.RoomRestriction, start, end time.Time, t models.TurnoverTimes) bool {
+	return start.Before(t.CheckOutAt(r.EndDate)) && end.After(t.CheckInAt(r.StartDate))
+}
+
+// freeWindows returns the runs of nights from from up to until that no
+// restriction covers and that are at least nights long, earliest first. Each
+// run is as long as it can be; callers pick the stay inside it.
+func freeWindows(from, until time.Time, nights int, restrictions []models.RoomRestriction) []models.DateRange {
+	sorted := slices.Clone(restrictions)
+	slices.SortFunc(sorted, func(a, b models.RoomRestriction) int { return a.StartDate.Compare(b.StartDate) })
+
+	var windows []models.DateRange
+	add := func(start, end time.Time) {
+		if end.After(until) {
+			end = until
+		}
+		if w := (models.DateRange{Start: start, End: end}); w.Nights() >= nights {
+			windows = append(windows, w)
+		}
+	}
+
+	night := from
+	for _, r := range sorted {
+		if !r.EndDate.After(night) {
+			continue
+		}
+		if r.StartDate.After(night) {
+			add(night, r.StartDate)
+		}
+		night = r.EndDate
+		if !night.Before(until) {
+			return windows
+		}
+	}
+	add(night, until)
+	return windows
+}
+
+// firstFreeNight scans forward from the night of from and returns the first
+// night not covered by any restriction, where a restriction covers the nights
+// from its start date up to (not including) its end date. It reports false
+// when that night is not before until.
+func firstFreeNight(from, until time.Time, restrictions []models.RoomRestriction) (time.Time, bool) {
+	sorted := slices.Clone(restrictions)
+	slices.SortFunc(sorted, func(a, b models.RoomRestriction) int { return a.StartDate.Compare(b.StartDate) })
+
+	night := from
+	for _, r := range sorted {
+		if r.StartDate.After(night) {
+			break
+		}
+		if r.EndDate.After(night) {
+			night = r.EndDate
+		}
+	}
+	return night, night.Before(until)
+}
@@ -5,29 +5,88 @@
 //
 // The implementation uses context-based timeouts for all database operations to prevent
 // hanging connections and ensure responsive application behavior under load.
+//
+// Driver errors are wrapped with the failing method, e.g.
+// "dbrepo.GetRoomByID: sql: no rows in result set", so logs identify the call
+// while errors.Is and errors.As still match the underlying error. Repository
+// sentinels such as repository.ErrReservationNotFound are returned unwrapped.
+//
+// Timestamps the repository writes (created_at, updated_at, terms_accepted_at
+// and the like) are always UTC, whatever the server's local zone, so stored
+// values mean the same thing on every deployment. Templates convert them to
+// the configured display zone with the localTime helper.
 package dbrepo
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"log"
 	"time"
 
 	"github.com/bensabler/milos-residence/internal/models"
+	"github.com/bensabler/milos-residence/internal/repository"
+	"github.com/jackc/pgx/v5/pgconn"
 	"golang.org/x/crypto/bcrypt"
 )
 
-// AllUsers is a placeholder method that returns a boolean indicating system health.
-// This method was implemented as a basic connectivity test during development
-// and currently serves as a simple database interaction verification.
-// In a production system, this would typically return actual user data or counts.
+// defaultSlowQueryThreshold is how long a repository call may take before it
+// is logged as slow when AppConfig.SlowQueryThreshold is not configured.
+const defaultSlowQueryThreshold = 500 * time.Millisecond
+
+// timeQuery starts timing the database work of a repository method and
+// returns a function that stops the clock. Methods defer the result right
+// after creating their context, labelled with their own name:
+//
+//	defer m.timeQuery("GetRoomByID")()
 //
-// Returns true if the database connection is functional, though the current
-// implementation always returns true regardless of database state.
-func (m *postgresDBRepo) AllUsers() bool {
+// Calls slower than the configured threshold are written to App.InfoLog so
+// slow queries show up in the application log without a profiler.
+func (m *postgresDBRepo) timeQuery(label string) func() {
+	start := time.Now()
+	return func() {
+		m.logIfSlow(label, time.Since(start))
+	}
+}
+
+// logIfSlow logs label and elapsed to App.InfoLog when elapsed exceeds the
+// slow-query threshold, and reports whether it did.
+func (m *postgresDBRepo) logIfSlow(label string, elapsed time.Duration) bool {
+	threshold := m.App.SlowQueryThreshold
+	if threshold <= 0 {
+		threshold = defaultSlowQueryThreshold
+	}
+	if elapsed <= threshold || m.App.InfoLog == nil {
+		return false
+	}
+
+	m.App.InfoLog.Printf("slow query: %s took %s (threshold %s)", label, elapsed.Round(time.Millisecond), threshold)
 	return true
 }
 
+// Ping verifies that a connection to PostgreSQL can be established and is
+// alive. It is used by the /healthz endpoint and the startup check.
+//
+// Parameters:
+//   - ctx: Bounds how long to wait for the database
+//
+// Returns:
+//   - error: Wrapped driver error if the database is unreachable, nil otherwise
+func (m *postgresDBRepo) Ping(ctx context.Context) error {
+	defer m.timeQuery("Ping")()
+
+	if err := m.DB.PingContext(ctx); err != nil {
+		return fmt.Errorf("dbrepo.Ping: %w", err)
+	}
+
+	return nil
+}
+
 // InsertReservation creates a new reservation record in the PostgreSQL database.
 // It inserts guest information, reservation dates, and room assignment into
 // the reservations table and returns the auto-generated reservation ID for
@@ -52,12 +111,13 @@ func (m *postgresDBRepo) AllUsers() bool {
 func (m *postgresDBRepo) InsertReservation(res models.Reservation) (int, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
+	defer m.timeQuery("InsertReservation")()
 
 	var newId int
 
 	stmt := `insert into reservations (first_name, last_name, email, phone, start_date,
-	 end_date, room_id, created_at, updated_at)
-	 values ($1, $2, $3, $4, $5, $6, $7, $8, $9) returning id`
+	 end_date, room_id, total, terms_accepted_at, created_at, updated_at)
+	 values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) returning id`
 
 	err := m.DB.QueryRowContext(ctx, stmt,
 		res.FirstName,
@@ -67,12 +127,14 @@ func (m *postgresDBRepo) InsertReservation(res models.Reservation) (int, error)
 		res.StartDate,
 		res.EndDate,
 		res.RoomID,
-		time.Now(),
-		time.Now(),
+		res.Total,
+		res.TermsAcceptedAt.UTC(),
+		time.Now().UTC(),
+		time.Now().UTC(),
 	).Scan(&newId)
 
 	if err != nil {
-		return 0, err
+		return 0, fmt.Errorf("dbrepo.InsertReservation: %w", err)
 	}
 
 	return newId, nil
@@ -102,6 +164,7 @@ func (m *postgresDBRepo) InsertReservation(res models.Reservation) (int, error)
 func (m *postgresDBRepo) InsertRoomRestriction(r models.RoomRestriction) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
+	defer m.timeQuery("InsertRoomRestriction")()
 
 	stmt := `insert into room_restrictions (start_date, end_date, room_id, reservation_id,
 				created_at, updated_at, restriction_id)
@@ -112,18 +175,130 @@ func (m *postgresDBRepo) InsertRoomRestriction(r models.RoomRestriction) error {
 		r.EndDate,
 		r.RoomID,
 		r.ReservationID,
-		time.Now(),
-		time.Now(),
+		time.Now().UTC(),
+		time.Now().UTC(),
 		r.RestrictionID,
 	)
 
 	if err != nil {
-		return err
+		return fmt.Errorf("dbrepo.InsertRoomRestriction: %w", err)
 	}
 
 	return nil
 }
 
+// CreateReservation inserts a reservation and its reservation-type room
+// restriction in a single transaction, so a failure part way through never
+// leaves a reservation that doesn't block its room (or the reverse). The
+// statements match InsertReservation and InsertRoomRestriction.
+//
+// Availability is checked again inside the transaction, after locking the
+// room row as CreateHold does, so two requests racing for the same nights
+// can't both pass a check made beforehand and double-book the room: the
+// second waits for the first to commit and then sees its restriction.
+//
+// The Idempotency-Key, when given, is claimed in the same transaction with
+// an insert that does nothing on conflict. Two requests with the same key
+// therefore can't both book: the second blocks on the key until the first
+// commits, finds it taken, and gets the first reservation's ID back. The
+// key is claimed before the room is locked, so a retry is answered with the
+// original booking rather than refused because that booking holds the dates.
+//
+// Parameters:
+//   - res: Reservation to store; the restriction covers res.StartDate to res.EndDate
+//   - idempotencyKey: Idempotency-Key header value, or "" for none
+//
+// Returns:
+//   - int: ID of the new reservation, or of the reservation already created
+//     with idempotencyKey
+//   - error: repository.ErrIdempotencyKeyUsed when the key was already
+//     claimed; repository.ErrDatesTaken when a reservation, block or
+//     unexpired hold overlaps the dates; a database error from the lock,
+//     check, any insert or the commit otherwise. Nothing is stored on error.
+func (m *postgresDBRepo) CreateReservation(res models.Reservation, idempotencyKey string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	defer m.timeQuery("CreateReservation")()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("dbrepo.CreateReservation: %w", err)
+	}
+	// Rollback is a no-op once Commit has succeeded.
+	defer tx.Rollback()
+
+	var newID int
+	err = tx.QueryRowContext(ctx, `insert into reservations (first_name, last_name, email, phone, start_date,
+	 end_date, room_id, total, terms_accepted_at, created_at, updated_at)
+	 values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) returning id`,
+		res.FirstName,
+		res.LastName,
+		res.Email,
+		res.Phone,
+		res.StartDate,
+		res.EndDate,
+		res.RoomID,
+		res.Total,
+		res.TermsAcceptedAt.UTC(),
+		time.Now().UTC(),
+		time.Now().UTC(),
+	).Scan(&newID)
+	if err != nil {
+		return 0, fmt.Errorf("dbrepo.CreateReservation: %w", err)
+	}
+
+	if idempotencyKey != "" {
+		result, err := tx.ExecContext(ctx, `
+			insert into idempotency_keys
+				(key, reservation_id, created_at)
+			values
+				($1, $2, $3)
+			on conflict (key) do nothing`,
+			idempotencyKey, newID, time.Now().UTC(),
+		)
+		if err != nil {
+			return 0, fmt.Errorf("dbrepo.CreateReservation: %w", err)
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return 0, fmt.Errorf("dbrepo.CreateReservation: %w", err)
+		}
+		if n == 0 {
+			var firstID int
+			err = tx.QueryRowContext(ctx, `select reservation_id from idempotency_keys where key = $1`, idempotencyKey).Scan(&firstID)
+			if err != nil {
+				return 0, fmt.Errorf("dbrepo.CreateReservation: %w", err)
+			}
+			return firstID, repository.ErrIdempotencyKeyUsed
+		}
+	}
+
+	if err = lockRoomForDates(ctx, tx, res.RoomID, res.StartDate, res.EndDate); err != nil {
+		return 0, fmt.Errorf("dbrepo.CreateReservation: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `insert into room_restrictions (start_date, end_date, room_id, reservation_id,
+				created_at, updated_at, restriction_id)
+				values ($1, $2, $3, $4, $5, $6, $7)`,
+		res.StartDate,
+		res.EndDate,
+		res.RoomID,
+		newID,
+		time.Now().UTC(),
+		time.Now().UTC(),
+		1,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("dbrepo.CreateReservation: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("dbrepo.CreateReservation: %w", err)
+	}
+
+	return newID, nil
+}
+
 // SearchAvailabilityByDatesByRoomID checks if a specific room is available for given dates.
 // It queries the room_restrictions table to count any overlapping restrictions
 // (reservations or owner blocks) that would prevent booking the room during
@@ -147,33 +322,37 @@ func (m *postgresDBRepo) InsertRoomRestriction(r models.RoomRestriction) error {
 //   - error: Database error if query fails, nil on success
 //
 // The query will return false (unavailable) if any overlapping restrictions exist,
-// regardless of restriction type (reservation or owner block).
+// regardless of restriction type (reservation, owner block or unexpired hold),
+// and for a closed (inactive) or unknown room. Holds past their expiry are
+// ignored even before ExpireHolds removes them, matching CreateHold.
 func (m *postgresDBRepo) SearchAvailabilityByDatesByRoomID(start, end time.Time, roomID int) (bool, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	var numRows int
+	defer m.timeQuery("SearchAvailabilityByDatesByRoomID")()
+	var available bool
 
 	query := `
-		select 
-			count(id) 
-		from 
-			room_restrictions 
-		where
-			room_id = $1
-		and 
-			$2 < end_date and $3 > start_date;`
+		select
+			coalesce((select active from rooms where id = $1), false)
+		and not exists (
+			select 1
+			from
+				room_restrictions
+			where
+				room_id = $1
+			and
+				$2 < end_date and $3 > start_date
+			and
+				(hold_expires_at is null or hold_expires_at > now())
+		);`
 
 	row := m.DB.QueryRowContext(ctx, query, roomID, start, end)
-	err := row.Scan(&numRows)
+	err := row.Scan(&available)
 	if err != nil {
-		return false, err
-	}
-
-	if numRows == 0 {
-		return true, nil
+		return false, fmt.Errorf("dbrepo.SearchAvailabilityByDatesByRoomID: %w", err)
 	}
 
-	return false, nil
+	return available, nil
 }
 
 // SearchAvailabilityForAllRooms retrieves all rooms that are available during specified dates.
@@ -199,10 +378,13 @@ func (m *postgresDBRepo) SearchAvailabilityByDatesByRoomID(start, end time.Time,
 //   - error: Database error if query fails, nil on success
 //
 // Returns an empty slice if no rooms are available during the specified dates.
+// Closed (inactive) rooms are never returned. Expired holds do not make a room
+// unavailable, as in SearchAvailabilityByDatesByRoomID.
 // Each returned room includes sufficient information for display in the room selection interface.
 func (m *postgresDBRepo) SearchAvailabilityForAllRooms(start, end time.Time) ([]models.Room, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
+	defer m.timeQuery("SearchAvailabilityForAllRooms")()
 
 	var rooms []models.Room
 
@@ -212,30 +394,33 @@ func (m *postgresDBRepo) SearchAvailabilityForAllRooms(start, end time.Time) ([]
 		from 
 			rooms r 
 		where
+			r.active
+		and
 			r.id not in (
 				select room_id 
 				from room_restrictions rr
 				where $1 < rr.end_date and $2 > rr.start_date
+				and (rr.hold_expires_at is null or rr.hold_expires_at > now())
 			)`
 
 	rows, err := m.DB.QueryContext(ctx, query, start, end)
 	if err != nil {
-		return rooms, err
+		return rooms, fmt.Errorf("dbrepo.SearchAvailabilityForAllRooms: %w", err)
 	}
 
 	for rows.Next() {
-		var room models.Room
+		room := models.Room{Active: true}
 
 		err := rows.Scan(&room.ID, &room.RoomName)
 		if err != nil {
-			return rooms, err
+			return rooms, fmt.Errorf("dbrepo.SearchAvailabilityForAllRooms: %w", err)
 		}
 
 		rooms = append(rooms, room)
 	}
 
 	if err = rows.Err(); err != nil {
-		return rooms, err
+		return rooms, fmt.Errorf("dbrepo.SearchAvailabilityForAllRooms: %w", err)
 	}
 
 	return rooms, nil
@@ -265,12 +450,13 @@ func (m *postgresDBRepo) SearchAvailabilityForAllRooms(start, end time.Time) ([]
 func (m *postgresDBRepo) GetRoomByID(id int) (models.Room, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
+	defer m.timeQuery("GetRoomByID")()
 
 	var room models.Room
 
 	query := `
 		select 
-			id, room_name, created_at, updated_at 
+			id, room_name, nightly_rate, max_guests, active, lead_days, created_at, updated_at 
 		from 
 			rooms 
 		where
@@ -281,12 +467,16 @@ func (m *postgresDBRepo) GetRoomByID(id int) (models.Room, error) {
 	err := row.Scan(
 		&room.ID,
 		&room.RoomName,
+		&room.NightlyRate,
+		&room.MaxGuests,
+		&room.Active,
+		&room.LeadDays,
 		&room.CreatedAt,
 		&room.UpdatedAt,
 	)
 
 	if err != nil {
-		return room, err
+		return room, fmt.Errorf("dbrepo.GetRoomByID: %w", err)
 	}
 
 	return room, nil
@@ -316,6 +506,7 @@ func (m *postgresDBRepo) GetRoomByID(id int) (models.Room, error) {
 func (m *postgresDBRepo) GetUserByID(id int) (models.User, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
+	defer m.timeQuery("GetUserByID")()
 
 	query := `
 		select 
@@ -340,13 +531,107 @@ func (m *postgresDBRepo) GetUserByID(id int) (models.User, error) {
 	)
 
 	if err != nil {
-		return u, err
+		return u, fmt.Errorf("dbrepo.GetUserByID: %w", err)
+	}
+
+	return u, nil
+
+}
+
+// GetUserByEmail retrieves a user by email address. The comparison is
+// case-insensitive, matching the unique index on lower(email), so
+// "Staff@Example.com" and "staff@example.com" are the same account.
+//
+// Parameters:
+//   - email: Email address to look up
+//
+// Returns:
+//   - models.User: Matching user, including the password hash
+//   - error: repository.ErrUserNotFound if no user matches, other database errors wrapped
+func (m *postgresDBRepo) GetUserByEmail(email string) (models.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	defer m.timeQuery("GetUserByEmail")()
+
+	query := `
+		select
+			id, first_name, last_name, email, password, access_level, created_at, updated_at
+		from
+			users
+		where
+			lower(email) = lower($1)`
+
+	var u models.User
+	err := m.DB.QueryRowContext(ctx, query, email).Scan(
+		&u.ID,
+		&u.FirstName,
+		&u.LastName,
+		&u.Email,
+		&u.Password,
+		&u.AccessLevel,
+		&u.CreatedAt,
+		&u.UpdatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return u, repository.ErrUserNotFound
+	}
+	if err != nil {
+		return u, fmt.Errorf("dbrepo.GetUserByEmail: %w", err)
 	}
 
 	return u, nil
+}
+
+// CreateUser inserts a new staff account. The plaintext password is hashed
+// with bcrypt at the configured cost before it is stored; it is never written
+// or logged as given.
+//
+// Duplicate emails are rejected by the unique index on lower(email), which
+// also covers two admins creating the same account at once. The resulting
+// unique-violation error is reported as repository.ErrDuplicateEmail.
+//
+// Parameters:
+//   - u: User details; ID, Password and timestamps are ignored
+//   - plainPassword: Initial password to hash
+//
+// Returns:
+//   - int: ID of the new user
+//   - error: repository.ErrDuplicateEmail, a hashing error, or a database error
+func (m *postgresDBRepo) CreateUser(u models.User, plainPassword string) (int, error) {
+	hash, err := hashPassword(plainPassword, m.bcryptCost())
+	if err != nil {
+		return 0, fmt.Errorf("dbrepo.CreateUser: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	defer m.timeQuery("CreateUser")()
+
+	query := `
+		insert into users
+			(first_name, last_name, email, password, access_level, created_at, updated_at)
+		values
+			($1, $2, $3, $4, $5, $6, $7)
+		returning id`
+
+	var newID int
+	err = m.DB.QueryRowContext(ctx, query,
+		u.FirstName, u.LastName, u.Email, hash, u.AccessLevel, time.Now().UTC(), time.Now().UTC(),
+	).Scan(&newID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return 0, repository.ErrDuplicateEmail
+		}
+		return 0, fmt.Errorf("dbrepo.CreateUser: %w", err)
+	}
 
+	return newID, nil
 }
 
+// uniqueViolation is the PostgreSQL SQLSTATE for a unique constraint violation.
+const uniqueViolation = "23505"
+
 // UpdateUser modifies user information in the PostgreSQL database.
 // This method updates user profile data including name, email, and access level
 // while automatically updating the modification timestamp. The password field
@@ -375,6 +660,7 @@ func (m *postgresDBRepo) GetUserByID(id int) (models.User, error) {
 func (m *postgresDBRepo) UpdateUser(u models.User) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
+	defer m.timeQuery("UpdateUser")()
 
 	query := `
 		update
@@ -383,16 +669,88 @@ func (m *postgresDBRepo) UpdateUser(u models.User) error {
 			first_name = $1, last_name = $2, email = $3, access_level = $4, updated_at = $5
 		`
 
-	_, err := m.DB.ExecContext(ctx, query, u.FirstName, u.LastName, u.Email, u.AccessLevel, time.Now())
+	_, err := m.DB.ExecContext(ctx, query, u.FirstName, u.LastName, u.Email, u.AccessLevel, time.Now().UTC())
 
 	if err != nil {
-		return err
+		return fmt.Errorf("dbrepo.UpdateUser: %w", err)
 	}
 
 	return nil
 
 }
 
+// defaultBcryptCost is the password hashing work factor used when
+// AppConfig.BcryptCost is not configured.
+const defaultBcryptCost = 12
+
+// bcryptCost returns the configured password hashing cost, falling back to
+// defaultBcryptCost when the application config leaves it unset.
+func (m *postgresDBRepo) bcryptCost() int {
+	if m.App != nil && m.App.BcryptCost != 0 {
+		return m.App.BcryptCost
+	}
+	return defaultBcryptCost
+}
+
+// hashPassword returns the bcrypt hash of password at the given cost.
+func hashPassword(password string, cost int) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// needsRehash reports whether hash was produced with a bcrypt cost lower than
+// target. Unparseable hashes report false; they will already have failed the
+// password comparison.
+func needsRehash(hash string, target int) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	if err != nil {
+		return false
+	}
+	return cost < target
+}
+
+// UpdatePassword replaces a user's password with a bcrypt hash of password
+// generated at the configured cost. It is used both for explicit password
+// changes and for transparent cost upgrades during Authenticate.
+//
+// Parameters:
+//   - id: User ID whose password is being replaced
+//   - password: New plaintext password (never stored)
+//
+// Returns:
+//   - error: Hashing or database error, nil on success
+func (m *postgresDBRepo) UpdatePassword(id int, password string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	hash, err := hashPassword(password, m.bcryptCost())
+	if err != nil {
+		return fmt.Errorf("dbrepo.UpdatePassword: %w", err)
+	}
+
+	// Time only the update; hashing is deliberately slow.
+	defer m.timeQuery("UpdatePassword")()
+
+	query := `
+		update
+			users
+		set
+			password = $1, updated_at = $2
+		where
+			id = $3
+	`
+
+	_, err = m.DB.ExecContext(ctx, query, hash, time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("dbrepo.UpdatePassword: %w", err)
+	}
+
+	return nil
+}
+
 // Authenticate verifies user credentials against the PostgreSQL database.
 // This method implements secure authentication by retrieving the user's hashed
 // password and comparing it with the provided password using bcrypt hashing.
@@ -402,12 +760,14 @@ func (m *postgresDBRepo) UpdateUser(u models.User) error {
 // 1. Query database for user record by email address
 // 2. Retrieve stored bcrypt hash for the user account
 // 3. Compare provided password against stored hash using bcrypt.CompareHashAndPassword
-// 4. Return user ID and hash on success, or appropriate error on failure
+// 4. If the stored hash uses a lower cost than configured, rehash and persist it
+// 5. Return user ID and hash on success, or appropriate error on failure
 //
 // Security features:
 // - Uses bcrypt for secure password hashing and comparison
 // - Protects against timing attacks through consistent bcrypt operations
 // - Returns specific error for incorrect passwords vs. database errors
+// - Raises the work factor of old hashes over time without forcing resets
 // - Context timeout prevents indefinite blocking during authentication
 //
 // Parameters:
@@ -422,8 +782,11 @@ func (m *postgresDBRepo) UpdateUser(u models.User) error {
 // Possible errors:
 // - sql.ErrNoRows: Email address not found in database
 // - bcrypt.ErrMismatchedHashAndPassword: Converted to "incorrect password" error
-// - Other bcrypt errors: Returned as-is for debugging
-// - Database connectivity errors: Returned as-is
+// - Other bcrypt errors: Returned wrapped for debugging
+// - Database connectivity errors: Returned wrapped
+//
+// A failed cost upgrade is logged but does not fail the login; the old hash
+// remains valid and the upgrade is retried on the next login.
 func (m *postgresDBRepo) Authenticate(email, testPassword string) (int, string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
@@ -431,58 +794,226 @@ func (m *postgresDBRepo) Authenticate(email, testPassword string) (int, string,
 	var id int
 	var hashedPassword string
 
-	row := m.DB.QueryRowContext(ctx, "select id, password from users where email = $1", email)
+	// Time only the lookup; the bcrypt comparison below is deliberately slow.
+	done := m.timeQuery("Authenticate")
+	// Match emails ignoring case, as GetUserByEmail and the unique index do.
+	row := m.DB.QueryRowContext(ctx, "select id, password from users where lower(email) = lower($1)", email)
 	err := row.Scan(&id, &hashedPassword)
+	done()
 	if err != nil {
-		return id, "", err
+		return id, "", fmt.Errorf("dbrepo.Authenticate: %w", err)
 	}
 
 	err = bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(testPassword))
 	if err == bcrypt.ErrMismatchedHashAndPassword {
 		return 0, "", errors.New("incorrect password")
 	} else if err != nil {
-		return 0, "", err
+		return 0, "", fmt.Errorf("dbrepo.Authenticate: %w", err)
+	}
+
+	if needsRehash(hashedPassword, m.bcryptCost()) {
+		if err := m.UpdatePassword(id, testPassword); err != nil {
+			log.Println("password rehash failed:", err)
+		}
 	}
 
 	return id, hashedPassword, nil
 
 }
 
-// AllReservations retrieves all reservation records from the PostgreSQL database.
-// This method performs a comprehensive query joining reservation data with room
-// information to provide complete reservation details for administrative interfaces.
-// Results are ordered chronologically by start date for logical presentation.
+// defaultPasswordResetTTL is how long a password reset token stays valid when
+// AppConfig.PasswordResetTTL is not configured.
+const defaultPasswordResetTTL = time.Hour
+
+// passwordResetTTL returns the configured reset token lifetime, falling back
+// to defaultPasswordResetTTL when the application config leaves it unset.
+func (m *postgresDBRepo) passwordResetTTL() time.Duration {
+	if m.App != nil && m.App.PasswordResetTTL > 0 {
+		return m.App.PasswordResetTTL
+	}
+	return defaultPasswordResetTTL
+}
+
+// hashResetToken returns the hex SHA-256 digest stored in place of a reset
+// token, so the table never holds a usable link.
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateResetToken issues a password reset token for a user. The token is 32
+// random bytes, URL-safe base64 encoded; only its SHA-256 digest is stored,
+// with an expiry of now plus the configured lifetime. Any earlier unused
+// tokens for the user are marked used in the same transaction, so only the
+// most recent reset link works.
+//
+// Parameters:
+//   - userID: User the token lets reset their password
+//
+// Returns:
+//   - string: Token to embed in the reset link
+//   - error: Random source or database error, nil on success
+func (m *postgresDBRepo) CreateResetToken(userID int) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("dbrepo.CreateResetToken: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	defer m.timeQuery("CreateResetToken")()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("dbrepo.CreateResetToken: %w", err)
+	}
+	// Rollback is a no-op once Commit has succeeded.
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+
+	_, err = tx.ExecContext(ctx, `
+		update password_reset_tokens set used_at = $1
+		where user_id = $2 and used_at is null`, now, userID)
+	if err != nil {
+		return "", fmt.Errorf("dbrepo.CreateResetToken: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		insert into password_reset_tokens (token_hash, user_id, expires_at, created_at)
+		values ($1, $2, $3, $4)`,
+		hashResetToken(token), userID, now.Add(m.passwordResetTTL()), now)
+	if err != nil {
+		return "", fmt.Errorf("dbrepo.CreateResetToken: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", fmt.Errorf("dbrepo.CreateResetToken: %w", err)
+	}
+
+	return token, nil
+}
+
+// ConsumeResetToken marks a reset token used and returns the user it belongs
+// to. The check and the update are one statement, so two requests racing
+// with the same token cannot both succeed.
 //
-// The method uses a LEFT JOIN to ensure all reservations are returned even if
-// room data is missing (though this should not occur in normal operation due to
-// foreign key constraints). This approach provides defensive programming against
-// data inconsistencies while maintaining complete reservation visibility.
+// Parameters:
+//   - token: Token from the reset link
 //
-// Administrative uses include:
-// - Comprehensive reservation reporting and analytics
-// - Administrative oversight of all booking activity
-// - Historical reservation data for business intelligence
-// - Audit trails and compliance reporting requirements
+// Returns:
+//   - int: ID of the user whose password may now be reset
+//   - error: repository.ErrInvalidResetToken when the token is unknown,
+//     expired or already used; database error otherwise
+func (m *postgresDBRepo) ConsumeResetToken(token string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	defer m.timeQuery("ConsumeResetToken")()
+
+	query := `
+		update
+			password_reset_tokens
+		set
+			used_at = $1
+		where
+			token_hash = $2 and used_at is null and expires_at > $1
+		returning user_id
+	`
+
+	var userID int
+	err := m.DB.QueryRowContext(ctx, query, time.Now().UTC(), hashResetToken(token)).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, repository.ErrInvalidResetToken
+	}
+	if err != nil {
+		return 0, fmt.Errorf("dbrepo.ConsumeResetToken: %w", err)
+	}
+
+	return userID, nil
+}
+
+// reservationOrderBy maps each key in repository.ReservationSorts to a fixed
+// ORDER BY clause. Reservation ID breaks ties so paging through equal values
+// is stable.
+var reservationOrderBy = map[string]string{
+	"start_date_asc":  "r.start_date asc, r.id asc",
+	"start_date_desc": "r.start_date desc, r.id desc",
+	"created_at_asc":  "r.created_at asc, r.id asc",
+	"created_at_desc": "r.created_at desc, r.id desc",
+	"last_name_asc":   "lower(r.last_name) asc, r.id asc",
+	"last_name_desc":  "lower(r.last_name) desc, r.id desc",
+}
+
+// reservationStatusWhere maps the repository.ReservationStatuses that filter
+// rows to a fixed WHERE clause. The processed value it compares against is
+// bound as $1 from reservationStatusProcessed. Statuses not listed (all,
+// empty, unknown) add no condition.
+var reservationStatusWhere = map[string]string{
+	repository.ReservationStatusNew:       "where r.processed = $1",
+	repository.ReservationStatusProcessed: "where r.processed = $1",
+}
+
+// reservationStatusProcessed holds the processed value bound for each status
+// in reservationStatusWhere.
+var reservationStatusProcessed = map[string]int{
+	repository.ReservationStatusNew:       models.ReservationNew,
+	repository.ReservationStatusProcessed: models.ReservationProcessed,
+}
+
+// reservationSearchSQL is the only place SearchReservations builds SQL from a
+// filter. Filter values are used solely as keys into the allowlists above, so
+// the returned clauses are always one of their fixed strings and anything the
+// caller typed can only reach the database as a bound argument. Add new
+// filter or sort options here, never by concatenating filter fields.
 //
 // Returns:
-//   - []models.Reservation: All reservations with embedded room information, ordered by start_date
-//   - error: Database error if query fails, nil on success
+//   - where: WHERE clause, or "" for no condition
+//   - orderBy: ORDER BY expression, repository.DefaultReservationSort's for unknown keys
+//   - args: Values bound to the placeholders in where
+func reservationSearchSQL(filter repository.ReservationFilter) (where, orderBy string, args []interface{}) {
+	orderBy, ok := reservationOrderBy[filter.Sort]
+	if !ok {
+		orderBy = reservationOrderBy[repository.DefaultReservationSort]
+	}
+
+	if clause, ok := reservationStatusWhere[filter.Status]; ok {
+		where = clause
+		args = append(args, reservationStatusProcessed[filter.Status])
+	}
+
+	return where, orderBy, args
+}
+
+// SearchReservations retrieves reservations with their room names for the
+// admin reservations page. The status selects all, new (processed = 0) or
+// processed reservations and the sort key picks the order; both are turned
+// into SQL by reservationSearchSQL, so caller input is never interpolated.
 //
-// Performance considerations:
-// - Query returns all reservations which could be large datasets in production
-// - Consider implementing pagination for systems with extensive reservation history
-// - LEFT JOIN adds minimal overhead due to foreign key relationship optimization
-// - Context timeout prevents indefinite blocking during large result set processing
-func (m *postgresDBRepo) AllReservations() ([]models.Reservation, error) {
+// The method uses a LEFT JOIN so reservations are listed even if their room
+// row is missing, which foreign keys should prevent.
+//
+// Parameters:
+//   - filter: Status (one of repository.ReservationStatuses) and sort (one of
+//     repository.ReservationSorts); unknown values use the defaults
+//
+// Returns:
+//   - []models.Reservation: Matching reservations with embedded room information, in the requested order
+//   - error: Database error if query fails, nil on success
+func (m *postgresDBRepo) SearchReservations(filter repository.ReservationFilter) ([]models.Reservation, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
+	defer m.timeQuery("SearchReservations")()
 
 	var reservations []models.Reservation
 
+	where, orderBy, args := reservationSearchSQL(filter)
+
 	query := `
 		select 
 			r.id, r.first_name, r.last_name, r.email, r.phone, r.start_date, 
 			r.end_date, r.room_id, r.created_at, r.updated_at, r.processed, 
+			r.status, r.notes, 
 			rm.id, rm.room_name
 		from 
 			reservations r 
@@ -490,13 +1021,13 @@ func (m *postgresDBRepo) AllReservations() ([]models.Reservation, error) {
 			rooms rm 
 		on 
 			(r.room_id = rm.id)
+		` + where + `
 		order by
-			r.start_date asc
-	`
+			` + orderBy
 
-	rows, err := m.DB.QueryContext(ctx, query)
+	rows, err := m.DB.QueryContext(ctx, query, args...)
 	if err != nil {
-		return reservations, err
+		return reservations, fmt.Errorf("dbrepo.SearchReservations: %w", err)
 	}
 	defer rows.Close()
 
@@ -514,73 +1045,63 @@ func (m *postgresDBRepo) AllReservations() ([]models.Reservation, error) {
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.Processed,
+			&i.Status,
+			&i.Notes,
 			&i.Room.ID,
 			&i.Room.RoomName,
 		)
 
 		if err != nil {
-			return reservations, err
+			return reservations, fmt.Errorf("dbrepo.SearchReservations: %w", err)
 		}
 		reservations = append(reservations, i)
 	}
 
 	if err = rows.Err(); err != nil {
-		return reservations, err
+		return reservations, fmt.Errorf("dbrepo.SearchReservations: %w", err)
 	}
 
 	return reservations, nil
-
 }
 
-// AllNewReservations retrieves unprocessed reservation records from the PostgreSQL database.
-// This method filters reservations to show only those requiring administrative attention
-// (processed = 0), enabling staff to efficiently manage incoming bookings and guest requests.
-// Like AllReservations, it joins with room data and orders results chronologically.
+// ArrivalsBetween lists the reservations whose arrival (start_date) falls in
+// the half-open range [start, end), for the front desk's arrivals page. Rows
+// are ordered by guest name so staff can find a guest at check-in.
 //
-// The processed flag workflow:
-// - New reservations start with processed = 0 (requires attention)
-// - Staff review, validate, and confirm reservations through admin interface
-// - Processed reservations are marked processed = 1 (completed/confirmed)
-// - This method shows only the processed = 0 reservations needing action
-//
-// Administrative workflow uses:
-// - Daily reservation processing queues for staff
-// - New booking notification and review processes
-// - Quality control and fraud prevention screening
-// - Guest communication and confirmation workflows
+// Parameters:
+//   - start: First arrival date to include
+//   - end: Day after the last arrival date to include
 //
 // Returns:
-//   - []models.Reservation: Unprocessed reservations with embedded room information, ordered by start_date
-//   - error: Database error if query fails, nil on success
-//
-// The chronological ordering (start_date ASC) helps staff prioritize processing
-// based on arrival dates, ensuring near-term reservations receive prompt attention.
-func (m *postgresDBRepo) AllNewReservations() ([]models.Reservation, error) {
+//   - []models.Reservation: Arriving reservations with Room.ID and Room.RoomName set
+//   - error: Database error if the query fails, nil on success
+func (m *postgresDBRepo) ArrivalsBetween(start, end time.Time) ([]models.Reservation, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
+	defer m.timeQuery("ArrivalsBetween")()
 
 	var reservations []models.Reservation
 
 	query := `
-		select 
-			r.id, r.first_name, r.last_name, r.email, r.phone, r.start_date, 
-			r.end_date, r.room_id, r.created_at, r.updated_at, r.processed, 
+		select
+			r.id, r.first_name, r.last_name, r.email, r.phone, r.start_date,
+			r.end_date, r.room_id, r.created_at, r.updated_at, r.processed,
 			rm.id, rm.room_name
-		from 
-			reservations r 
+		from
+			reservations r
 		left join
-			rooms rm 
-		on 
+			rooms rm
+		on
 			(r.room_id = rm.id)
 		where
-			processed = 0
+			r.start_date >= $1 and r.start_date < $2
 		order by
-			r.start_date asc
+			lower(r.last_name), lower(r.first_name), r.id
 	`
 
-	rows, err := m.DB.QueryContext(ctx, query)
+	rows, err := m.DB.QueryContext(ctx, query, start, end)
 	if err != nil {
-		return reservations, err
+		return reservations, fmt.Errorf("dbrepo.ArrivalsBetween: %w", err)
 	}
 	defer rows.Close()
 
@@ -601,19 +1122,17 @@ func (m *postgresDBRepo) AllNewReservations() ([]models.Reservation, error) {
 			&i.Room.ID,
 			&i.Room.RoomName,
 		)
-
 		if err != nil {
-			return reservations, err
+			return reservations, fmt.Errorf("dbrepo.ArrivalsBetween: %w", err)
 		}
 		reservations = append(reservations, i)
 	}
 
 	if err = rows.Err(); err != nil {
-		return reservations, err
+		return reservations, fmt.Errorf("dbrepo.ArrivalsBetween: %w", err)
 	}
 
 	return reservations, nil
-
 }
 
 // GetReservationByID retrieves a specific reservation record by its unique identifier.
@@ -638,12 +1157,13 @@ func (m *postgresDBRepo) AllNewReservations() ([]models.Reservation, error) {
 //   - models.Reservation: Complete reservation record with embedded room information
 //   - error: Database error if query fails or reservation not found, nil on success
 //
-// Returns sql.ErrNoRows if the specified reservation ID does not exist.
-// Calling code should handle this error appropriately to provide user feedback
-// for invalid reservation access attempts.
+// Returns repository.ErrReservationNotFound if the specified reservation ID does
+// not exist. Calling code should handle this error appropriately to provide user
+// feedback for invalid reservation access attempts.
 func (m *postgresDBRepo) GetReservationByID(id int) (models.Reservation, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
+	defer m.timeQuery("GetReservationByID")()
 
 	var res models.Reservation
 
@@ -651,6 +1171,7 @@ func (m *postgresDBRepo) GetReservationByID(id int) (models.Reservation, error)
 		select 
 			r.id, r.first_name, r.last_name, r.email, r.phone, r.start_date, 
 			r.end_date, r.room_id, r.created_at, r.updated_at, r.processed, 
+			r.status, r.notes, 
 			rm.id, rm.room_name
 		from 
 			reservations r 
@@ -675,12 +1196,16 @@ func (m *postgresDBRepo) GetReservationByID(id int) (models.Reservation, error)
 		&res.CreatedAt,
 		&res.UpdatedAt,
 		&res.Processed,
+		&res.Status,
+		&res.Notes,
 		&res.Room.ID,
 		&res.Room.RoomName,
 	)
 
-	if err != nil {
-		return res, err
+	if errors.Is(err, sql.ErrNoRows) {
+		return res, repository.ErrReservationNotFound
+	} else if err != nil {
+		return res, fmt.Errorf("dbrepo.GetReservationByID: %w", err)
 	}
 
 	return res, nil
@@ -689,7 +1214,7 @@ func (m *postgresDBRepo) GetReservationByID(id int) (models.Reservation, error)
 
 // UpdateReservation modifies guest information for an existing reservation.
 // This method updates the primary guest contact details (name, email, phone)
-// while preserving reservation dates, room assignments, and system timestamps.
+// and the staff notes while preserving reservation dates, room assignments, and system timestamps.
 // The updated_at field is automatically refreshed to track modification history.
 //
 // The method specifically handles guest information updates that commonly occur:
@@ -703,11 +1228,18 @@ func (m *postgresDBRepo) GetReservationByID(id int) (models.Reservation, error)
 // - Room assignments (room_id): Require availability checking and restriction updates
 // - System fields (created_at, processed): Maintained by specific business logic
 //
+// Optimistic concurrency: the update only applies while the row's updated_at
+// still equals u.UpdatedAt, the value the caller loaded. If another admin saved
+// the reservation in the meantime (or deleted it), no row matches and
+// repository.ErrStaleUpdate is returned instead of silently overwriting their
+// changes.
+//
 // Parameters:
-//   - u: Reservation model containing updated guest information; ID field determines which record to update
+//   - u: Reservation model containing updated guest information; ID field determines which
+//     record to update and UpdatedAt must be the value read when the record was loaded
 //
 // Returns:
-//   - error: Database error if update fails, nil on success
+//   - error: repository.ErrStaleUpdate if the record changed, other database errors wrapped, nil on success
 //
 // Business considerations:
 // - Email changes may require re-sending confirmation messages in calling code
@@ -716,26 +1248,147 @@ func (m *postgresDBRepo) GetReservationByID(id int) (models.Reservation, error)
 func (m *postgresDBRepo) UpdateReservation(u models.Reservation) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
+	defer m.timeQuery("UpdateReservation")()
 
 	query := `
 		update
 			reservations
 		set 
-			first_name = $1, last_name = $2, email = $3, phone = $4, updated_at = $5
+			first_name = $1, last_name = $2, email = $3, phone = $4, notes = $5, updated_at = $6
 		where
-			id = $6
+			id = $7
+		and
+			updated_at = $8
 		`
 
-	_, err := m.DB.ExecContext(ctx, query, u.FirstName, u.LastName, u.Email, u.Phone, time.Now(), u.ID)
-
+	result, err := m.DB.ExecContext(ctx, query, u.FirstName, u.LastName, u.Email, u.Phone, u.Notes, time.Now().UTC(), u.ID, u.UpdatedAt)
 	if err != nil {
-		return err
+		return fmt.Errorf("dbrepo.UpdateReservation: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("dbrepo.UpdateReservation: %w", err)
+	}
+	if n == 0 {
+		return repository.ErrStaleUpdate
 	}
 
 	return nil
 
 }
 
+// GetReservationRestriction returns the room_restrictions row created with
+// the reservation, which is what the calendar shows for its dates. Editing a
+// reservation's dates has to move this row too, or the calendar and
+// availability searches keep the old nights blocked.
+//
+// Parameters:
+//   - reservationID: Reservation whose restriction to load
+//
+// Returns:
+//   - models.RoomRestriction: The restriction, with ReservationID set
+//   - error: Wraps sql.ErrNoRows if the reservation has no restriction, other database errors wrapped
+func (m *postgresDBRepo) GetReservationRestriction(reservationID int) (models.RoomRestriction, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	defer m.timeQuery("GetReservationRestriction")()
+
+	var r models.RoomRestriction
+
+	query := `
+		select
+			id, reservation_id, restriction_id, room_id, start_date, end_date, note, updated_at
+		from
+			room_restrictions
+		where
+			reservation_id = $1
+		order by
+			id
+		limit 1
+	`
+
+	err := m.DB.QueryRowContext(ctx, query, reservationID).Scan(
+		&r.ID,
+		&r.ReservationID,
+		&r.RestrictionID,
+		&r.RoomID,
+		&r.StartDate,
+		&r.EndDate,
+		&r.Note,
+		&r.UpdatedAt,
+	)
+	if err != nil {
+		return r, fmt.Errorf("dbrepo.GetReservationRestriction: %w", err)
+	}
+
+	return r, nil
+}
+
+// UpdateReservationDates sets new dates and total on a reservation and new
+// dates on its room restriction in one transaction, so the reservation and
+// the calendar can't disagree. Availability is not checked here; callers
+// confirm the new dates are free first.
+//
+// Parameters:
+//   - reservationID: Reservation to move
+//   - restrictionID: The reservation's restriction, from GetReservationRestriction
+//   - start, end: New arrival and departure dates
+//   - total: Price of the new nights in cents
+//
+// Returns:
+//   - error: Wraps sql.ErrNoRows if either row is missing (nothing is
+//     changed), other database errors wrapped
+func (m *postgresDBRepo) UpdateReservationDates(reservationID, restrictionID int, start, end time.Time, total int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	defer m.timeQuery("UpdateReservationDates")()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("dbrepo.UpdateReservationDates: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+
+	result, err := tx.ExecContext(ctx, `
+		update reservations
+		set start_date = $1, end_date = $2, total = $3, updated_at = $4
+		where id = $5`,
+		start, end, total, now, reservationID,
+	)
+	if err != nil {
+		return fmt.Errorf("dbrepo.UpdateReservationDates: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("dbrepo.UpdateReservationDates: %w", err)
+	} else if n == 0 {
+		return fmt.Errorf("dbrepo.UpdateReservationDates: %w", sql.ErrNoRows)
+	}
+
+	result, err = tx.ExecContext(ctx, `
+		update room_restrictions
+		set start_date = $1, end_date = $2, updated_at = $3
+		where id = $4 and reservation_id = $5`,
+		start, end, now, restrictionID, reservationID,
+	)
+	if err != nil {
+		return fmt.Errorf("dbrepo.UpdateReservationDates: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("dbrepo.UpdateReservationDates: %w", err)
+	} else if n == 0 {
+		return fmt.Errorf("dbrepo.UpdateReservationDates: %w", sql.ErrNoRows)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("dbrepo.UpdateReservationDates: %w", err)
+	}
+
+	return nil
+}
+
 // DeleteReservation removes a reservation record from the PostgreSQL database.
 // This method performs a hard delete of the reservation record and should typically
 // be used only in administrative scenarios such as spam cleanup, test data removal,
@@ -768,6 +1421,7 @@ func (m *postgresDBRepo) UpdateReservation(u models.Reservation) error {
 func (m *postgresDBRepo) DeleteReservation(id int) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
+	defer m.timeQuery("DeleteReservation")()
 
 	query := `
 		delete
@@ -780,13 +1434,69 @@ func (m *postgresDBRepo) DeleteReservation(id int) error {
 
 	_, err := m.DB.ExecContext(ctx, query, id)
 	if err != nil {
-		return err
+		return fmt.Errorf("dbrepo.DeleteReservation: %w", err)
 	}
 
 	return nil
 
 }
 
+// DeleteReservationsBatch removes several reservations, and the room
+// restrictions that hold their dates, in a single transaction. It backs the
+// admin bulk delete, so either every selected reservation goes or none do:
+// an ID that matches no reservation rolls the whole batch back.
+//
+// Like DeleteReservation this is a hard delete; the restrictions are removed
+// explicitly rather than left to the foreign key cascade so the calendar is
+// freed even where the cascade is missing.
+//
+// Parameters:
+//   - ids: Reservations to delete; an empty slice is a no-op
+//
+// Returns:
+//   - error: repository.ErrReservationNotFound (wrapped, naming the ID) when
+//     an ID does not exist, other database errors wrapped, nil on success
+func (m *postgresDBRepo) DeleteReservationsBatch(ids []int) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	defer m.timeQuery("DeleteReservationsBatch")()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("dbrepo.DeleteReservationsBatch: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, id := range ids {
+		_, err = tx.ExecContext(ctx, `delete from room_restrictions where reservation_id = $1`, id)
+		if err != nil {
+			return fmt.Errorf("dbrepo.DeleteReservationsBatch: %w", err)
+		}
+
+		result, err := tx.ExecContext(ctx, `delete from reservations where id = $1`, id)
+		if err != nil {
+			return fmt.Errorf("dbrepo.DeleteReservationsBatch: %w", err)
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("dbrepo.DeleteReservationsBatch: %w", err)
+		}
+		if n == 0 {
+			return fmt.Errorf("dbrepo.DeleteReservationsBatch: reservation %d: %w", id, repository.ErrReservationNotFound)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("dbrepo.DeleteReservationsBatch: %w", err)
+	}
+
+	return nil
+}
+
 // UpdateProcessedForReservation modifies the processing status of a reservation.
 // This method implements the reservation workflow by allowing staff to mark
 // reservations as processed (reviewed, confirmed, and ready) or reset them
@@ -799,43 +1509,140 @@ func (m *postgresDBRepo) DeleteReservation(id int) error {
 // - If issues are discovered later, staff can reset to processed = 0 for re-review
 //
 // The processed flag is used by:
-// - AllNewReservations() to show only unprocessed reservations needing attention
+// - SearchReservations() to show only unprocessed reservations needing attention
 // - Administrative dashboards to track processing progress and workload
 // - Automated systems to trigger confirmation emails or other post-processing actions
 // - Reporting systems to distinguish between pending and confirmed reservations
 //
+// Status is the source of truth and processed is derived from it, so this
+// changes the status and lets processed follow: marking a pending
+// reservation processed confirms it, and reopening a confirmed one returns
+// it to pending. Any other status is left alone, and processed is rewritten
+// from it, so the two can never disagree.
+//
 // Parameters:
 //   - id: Unique identifier of the reservation to update
 //   - processed: New processing status (0 = unprocessed, 1 = processed)
 //
 // Returns:
-//   - error: Database error if update fails, nil on success
-//
-// The method does not validate the processed value - calling code should ensure
-// only appropriate values (0 or 1) are passed to maintain data consistency.
+//   - error: repository.ErrInvalidProcessed for any other processed value
+//     (nothing is written), a database error if the update fails, nil on success
 func (m *postgresDBRepo) UpdateProcessedForReservation(id, processed int) error {
+	if processed != models.ReservationNew && processed != models.ReservationProcessed {
+		return fmt.Errorf("dbrepo.UpdateProcessedForReservation: %w", repository.ErrInvalidProcessed)
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
+	defer m.timeQuery("UpdateProcessedForReservation")()
 
 	query := `
 		update
 			reservations
-		set 
-			processed = $1
+		set
+			status = target.status,
+			processed = case when target.status = 'pending' then 0 else 1 end
+		from (
+			select
+				id,
+				case
+					when $1 = 1 and status = 'pending' then 'confirmed'
+					when $1 = 0 and status = 'confirmed' then 'pending'
+					else status
+				end as status
+			from
+				reservations
+			where
+				id = $2
+		) target
 		where
-			id = $2
+			reservations.id = target.id
 	`
 
 	_, err := m.DB.ExecContext(ctx, query, processed, id)
 
 	if err != nil {
-		return err
+		return fmt.Errorf("dbrepo.UpdateProcessedForReservation: %w", err)
 	}
 
 	return nil
 
 }
 
+// SetReservationStatus moves a reservation through its lifecycle. The
+// current status is read with a row lock and checked against
+// models.StatusTransitions before anything is written, so two staff members
+// changing the same reservation can't skip a step between them. The
+// processed flag is written alongside (see models.ProcessedForStatus).
+//
+// Cancelling also deletes the reservation's room restriction in the same
+// transaction, so the nights go back on sale the moment the status changes,
+// just as they do when the reservation is deleted.
+//
+// Parameters:
+//   - id: Reservation to update
+//   - status: New status, one of models.ReservationStatuses
+//
+// Returns:
+//   - error: repository.ErrInvalidStatus for an unknown status (nothing
+//     runs), repository.ErrReservationNotFound for an unknown ID,
+//     repository.ErrStatusTransition (wrapped, naming both statuses) for a
+//     disallowed change, other database errors wrapped
+func (m *postgresDBRepo) SetReservationStatus(id int, status string) error {
+	valid := false
+	for _, s := range models.ReservationStatuses {
+		valid = valid || s == status
+	}
+	if !valid {
+		return fmt.Errorf("dbrepo.SetReservationStatus: %w", repository.ErrInvalidStatus)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	defer m.timeQuery("SetReservationStatus")()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("dbrepo.SetReservationStatus: %w", err)
+	}
+	defer tx.Rollback()
+
+	var current string
+	err = tx.QueryRowContext(ctx, `select status from reservations where id = $1 for update`, id).Scan(&current)
+	if errors.Is(err, sql.ErrNoRows) {
+		return repository.ErrReservationNotFound
+	} else if err != nil {
+		return fmt.Errorf("dbrepo.SetReservationStatus: %w", err)
+	}
+
+	if !models.CanTransition(current, status) {
+		return fmt.Errorf("dbrepo.SetReservationStatus: %s to %s: %w", current, status, repository.ErrStatusTransition)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		update reservations
+		set status = $1, processed = $2, updated_at = $3
+		where id = $4`,
+		status, models.ProcessedForStatus(status), time.Now().UTC(), id,
+	)
+	if err != nil {
+		return fmt.Errorf("dbrepo.SetReservationStatus: %w", err)
+	}
+
+	if status == models.StatusCancelled {
+		_, err = tx.ExecContext(ctx, `delete from room_restrictions where reservation_id = $1`, id)
+		if err != nil {
+			return fmt.Errorf("dbrepo.SetReservationStatus: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("dbrepo.SetReservationStatus: %w", err)
+	}
+
+	return nil
+}
+
 // AllRooms retrieves all room records from the PostgreSQL database.
 // This method returns complete room information ordered alphabetically by
 // room name for consistent presentation in user interfaces and administrative
@@ -864,12 +1671,13 @@ func (m *postgresDBRepo) UpdateProcessedForReservation(id, processed int) error
 func (m *postgresDBRepo) AllRooms() ([]models.Room, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
+	defer m.timeQuery("AllRooms")()
 
 	var rooms []models.Room
 
 	query := `
 		select
-			id, room_name, created_at, updated_at
+			id, room_name, active, created_at, updated_at
 		from 
 			rooms
 		order by
@@ -878,7 +1686,7 @@ func (m *postgresDBRepo) AllRooms() ([]models.Room, error) {
 
 	rows, err := m.DB.QueryContext(ctx, query)
 	if err != nil {
-		return rooms, err
+		return rooms, fmt.Errorf("dbrepo.AllRooms: %w", err)
 	}
 	defer rows.Close()
 
@@ -887,162 +1695,518 @@ func (m *postgresDBRepo) AllRooms() ([]models.Room, error) {
 		err := rows.Scan(
 			&rm.ID,
 			&rm.RoomName,
+			&rm.Active,
 			&rm.CreatedAt,
 			&rm.UpdatedAt,
 		)
 		if err != nil {
-			return rooms, err
+			return rooms, fmt.Errorf("dbrepo.AllRooms: %w", err)
 		}
 		rooms = append(rooms, rm)
 	}
 
 	if err = rows.Err(); err != nil {
-		return rooms, err
+		return rooms, fmt.Errorf("dbrepo.AllRooms: %w", err)
 	}
 
 	return rooms, nil
 }
 
-// GetRestrictionsForRoomByDate retrieves room restrictions overlapping a specified date range.
-// This method queries room_restrictions to find all conflicts (reservations and owner blocks)
-// that intersect with the given time period for a specific room. It's essential for
-// calendar displays, availability management, and administrative oversight of room usage.
+// InsertRoom adds a room with its name, base nightly rate, capacity, active
+// flag and lead time override. Rooms are normally created by migrations; this is used by the
+// development seed.
 //
-// The method uses standard interval overlap logic to find restrictions:
-// - Query conditions: queryStart < restrictionEnd AND queryEnd >= restrictionStart
-// - This captures all restrictions that have any overlap with the query period
-// - Uses COALESCE for reservation_id to handle owner blocks (NULL reservation_id)
+// Names must be unique ignoring case and extra whitespace; the
+// rooms_room_name_normalized_idx index enforces it.
 //
-// Restriction types returned:
-// - Reservations: restriction_id=1, has valid reservation_id linking to reservation record
-// - Owner blocks: restriction_id=2, reservation_id is NULL (handled by COALESCE)
+// Parameters:
+//   - room: Room to add; ID and timestamps are ignored
 //
-// Administrative uses:
-// - Calendar interfaces showing room availability and booking status
-// - Conflict detection when manually scheduling maintenance or blocks
-// - Administrative reporting on room utilization and restriction patterns
-// - Validation of booking requests against existing restrictions
+// Returns:
+//   - int: ID of the new room
+//   - error: repository.ErrDuplicateRoomName when the name is taken, other
+//     database errors wrapped, nil on success
+func (m *postgresDBRepo) InsertRoom(room models.Room) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	defer m.timeQuery("InsertRoom")()
+
+	query := `
+		insert into rooms
+			(room_name, nightly_rate, max_guests, active, lead_days, created_at, updated_at)
+		values
+			($1, $2, $3, $4, $5, $6, $7)
+		returning id`
+
+	var newID int
+	err := m.DB.QueryRowContext(ctx, query,
+		room.RoomName, room.NightlyRate, room.MaxGuests, room.Active, room.LeadDays, time.Now().UTC(), time.Now().UTC(),
+	).Scan(&newID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return 0, repository.ErrDuplicateRoomName
+		}
+		return 0, fmt.Errorf("dbrepo.InsertRoom: %w", err)
+	}
+
+	return newID, nil
+}
+
+// UpdateRoom saves the room's name, base nightly rate, capacity and lead time
+// override. The active flag is left alone; SetRoomActive changes it. Like
+// InsertRoom, a name another room already has (ignoring case and extra
+// whitespace) is refused by the unique index.
 //
 // Parameters:
-//   - roomID: Specific room to query restrictions for
-//   - start: Beginning of date range to check for overlapping restrictions
-//   - end: End of date range to check for overlapping restrictions
+//   - room: Room to save, identified by room.ID
 //
 // Returns:
-//   - []models.RoomRestriction: All restrictions overlapping the specified date range
-//   - error: Database error if query fails, nil on success
+//   - error: repository.ErrDuplicateRoomName when the name is taken,
+//     sql.ErrNoRows (wrapped) for an unknown room, other database errors
+//     wrapped, nil on success
+func (m *postgresDBRepo) UpdateRoom(room models.Room) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	defer m.timeQuery("UpdateRoom")()
+
+	query := `
+		update
+			rooms
+		set
+			room_name = $1, nightly_rate = $2, max_guests = $3, lead_days = $4, updated_at = $5
+		where
+			id = $6`
+
+	result, err := m.DB.ExecContext(ctx, query,
+		room.RoomName, room.NightlyRate, room.MaxGuests, room.LeadDays, time.Now().UTC(), room.ID,
+	)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return repository.ErrDuplicateRoomName
+		}
+		return fmt.Errorf("dbrepo.UpdateRoom: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("dbrepo.UpdateRoom: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("dbrepo.UpdateRoom: room %d: %w", room.ID, sql.ErrNoRows)
+	}
+
+	return nil
+}
+
+// SetRoomActive opens or closes a room. A closed room keeps its reservations
+// and blocks but is excluded from SearchAvailabilityForAllRooms and reported
+// unavailable by SearchAvailabilityByDatesByRoomID.
 //
-// Each returned restriction includes sufficient information to distinguish between
-// reservation restrictions (with reservation_id) and owner blocks (reservation_id=0).
-func (m *postgresDBRepo) GetRestrictionsForRoomByDate(roomID int, start, end time.Time) ([]models.RoomRestriction, error) {
+// Parameters:
+//   - id: Room to update
+//   - active: true to open the room, false to close it
+//
+// Returns:
+//   - error: Wraps sql.ErrNoRows if no room has the ID, other database errors wrapped
+func (m *postgresDBRepo) SetRoomActive(id int, active bool) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
+	defer m.timeQuery("SetRoomActive")()
 
-	var restrictions []models.RoomRestriction
+	stmt := `update rooms set active = $1, updated_at = $2 where id = $3`
+
+	result, err := m.DB.ExecContext(ctx, stmt, active, time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("dbrepo.SetRoomActive: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("dbrepo.SetRoomActive: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("dbrepo.SetRoomActive: %w", sql.ErrNoRows)
+	}
+
+	return nil
+}
+
+// ImagesForRoom returns the room's gallery images in display order: ascending
+// sort_order, then id so images added with the same position keep the order
+// they were added in.
+//
+// Parameters:
+//   - roomID: Room whose gallery to load
+//
+// Returns:
+//   - []models.RoomImage: Images in display order; empty if the room has none
+//   - error: Database error if the query fails, nil on success
+func (m *postgresDBRepo) ImagesForRoom(roomID int) ([]models.RoomImage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	defer m.timeQuery("ImagesForRoom")()
+
+	var images []models.RoomImage
 
 	query := `
 		select
-			id, coalesce(reservation_id, 0), restriction_id, room_id, start_date, end_date
-		from 
-			room_restrictions
+			id, room_id, url, caption, sort_order, created_at, updated_at
+		from
+			room_images
 		where
-			$1 < end_date
-		and
-			$2 >= start_date
-		and 
-			room_id = $3
+			room_id = $1
+		order by
+			sort_order, id
 	`
 
-	rows, err := m.DB.QueryContext(ctx, query, start, end, roomID)
+	rows, err := m.DB.QueryContext(ctx, query, roomID)
 	if err != nil {
-		return nil, err
+		return images, fmt.Errorf("dbrepo.ImagesForRoom: %w", err)
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		var r models.RoomRestriction
+		var img models.RoomImage
 		err := rows.Scan(
-			&r.ID,
-			&r.ReservationID,
-			&r.RestrictionID,
-			&r.RoomID,
-			&r.StartDate,
-			&r.EndDate,
+			&img.ID,
+			&img.RoomID,
+			&img.URL,
+			&img.Caption,
+			&img.SortOrder,
+			&img.CreatedAt,
+			&img.UpdatedAt,
 		)
 		if err != nil {
-			return nil, err
+			return images, fmt.Errorf("dbrepo.ImagesForRoom: %w", err)
 		}
-		restrictions = append(restrictions, r)
+		images = append(images, img)
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, err
+		return images, fmt.Errorf("dbrepo.ImagesForRoom: %w", err)
 	}
 
-	return restrictions, nil
-
+	return images, nil
 }
 
-// InsertBlockForRoom creates an owner block restriction for a specific room and date.
-// Owner blocks are administrative restrictions that prevent guest bookings during
-// maintenance periods, personal use, or other operational requirements. This method
-// creates single-day blocks that can be managed through the administrative calendar interface.
-//
-// Block characteristics:
-// - Restriction type: restriction_id=2 (Owner Block, vs. 1 for reservations)
-// - Duration: Single day (startDate to startDate + 1 day)
-// - Purpose: Administrative control over room availability
-// - No reservation association: reservation_id remains NULL
-//
-// The single-day approach simplifies calendar management by creating discrete blocks
-// that can be individually added or removed. Multi-day blocks are created by calling
-// this method multiple times for consecutive dates, providing granular control.
-//
-// Administrative workflow:
-// - Staff use calendar interface to click dates for blocking
-// - Each click calls this method to create a single-day owner block
-// - Blocks immediately affect availability searches and prevent new bookings
-// - Blocks can be removed individually using DeleteBlockByID
+// InsertRoomImage adds an image to a room's gallery.
 //
 // Parameters:
-//   - id: Room ID to create the block for
-//   - startDate: Date to block (end date is automatically set to startDate + 1 day)
+//   - img: Image to store; RoomID, URL, Caption and SortOrder are used
 //
 // Returns:
-//   - error: Database error if insertion fails, nil on success
-//
-// The method logs errors but also returns them, allowing calling code to decide
-// on appropriate error handling strategies (logging, user notification, rollback).
-func (m *postgresDBRepo) InsertBlockForRoom(id int, startDate time.Time) error {
+//   - int: ID of the new image
+//   - error: Database error (including an unknown room) if insertion fails
+func (m *postgresDBRepo) InsertRoomImage(img models.RoomImage) (int, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
+	defer m.timeQuery("InsertRoomImage")()
 
-	query := `
-		insert into room_restrictions
-			(start_date, end_date, room_id, restriction_id, created_at, updated_at)
+	stmt := `
+		insert into room_images
+			(room_id, url, caption, sort_order, created_at, updated_at)
 		values
 			($1, $2, $3, $4, $5, $6)
+		returning id
 	`
 
-	_, err := m.DB.ExecContext(ctx, query, startDate, startDate.AddDate(0, 0, 1), id, 2, time.Now(), time.Now())
+	var id int
+	err := m.DB.QueryRowContext(ctx, stmt,
+		img.RoomID,
+		img.URL,
+		img.Caption,
+		img.SortOrder,
+		time.Now().UTC(),
+		time.Now().UTC(),
+	).Scan(&id)
 	if err != nil {
-		log.Println(err)
-		return err
+		return 0, fmt.Errorf("dbrepo.InsertRoomImage: %w", err)
 	}
 
-	return nil
-
+	return id, nil
 }
 
-// DeleteBlockByID removes a specific room restriction by its unique identifier.
-// This method is used to remove owner blocks from the administrative calendar
-// interface, allowing staff to unblock dates that were previously restricted.
-// It performs a hard deletion of the restriction record.
+// DeleteRoomImage removes an image from a room's gallery. The room ID is part
+// of the match so a stale or tampered form cannot delete another room's image.
 //
-// The method is typically used for:
-// - Removing owner blocks that are no longer needed (maintenance completed, plans changed)
-// - Administrative cleanup of incorrectly created blocks
-// - Calendar interface interactions where staff uncheck blocked dates
+// Parameters:
+//   - roomID: Room the image belongs to
+//   - imageID: Image to delete
+//
+// Returns:
+//   - error: Wraps sql.ErrNoRows if the room has no such image, other database errors wrapped
+func (m *postgresDBRepo) DeleteRoomImage(roomID, imageID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	defer m.timeQuery("DeleteRoomImage")()
+
+	result, err := m.DB.ExecContext(ctx, `delete from room_images where id = $1 and room_id = $2`, imageID, roomID)
+	if err != nil {
+		return fmt.Errorf("dbrepo.DeleteRoomImage: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("dbrepo.DeleteRoomImage: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("dbrepo.DeleteRoomImage: %w", sql.ErrNoRows)
+	}
+
+	return nil
+}
+
+// GetRestrictionsForRoomByDate retrieves room restrictions overlapping a specified date range.
+// This method queries room_restrictions to find all conflicts (reservations and owner blocks)
+// that intersect with the given time period for a specific room. It's essential for
+// calendar displays, availability management, and administrative oversight of room usage.
+//
+// The method uses standard interval overlap logic on whole days:
+// - Match conditions: queryStart < restrictionEnd AND queryEnd >= restrictionStart
+// - This captures all restrictions that have any overlap with the query period
+//
+// It is a thin wrapper over GetRestrictionsForRoomBetween: start becomes the
+// check-out time on start and end the check-in time on the day after end,
+// which selects exactly the rows the day comparison above does.
+//
+// Restriction types returned:
+// - Reservations: restriction_id=1, has valid reservation_id linking to reservation record
+// - Owner blocks: restriction_id=2, reservation_id is NULL (handled by COALESCE)
+// - Maintenance blocks: restriction_id=3, reservation_id is NULL
+//
+// Blocks carry the staff note entered when they were created, so calendar views
+// can explain why a date is unavailable.
+//
+// Administrative uses:
+// - Calendar interfaces showing room availability and booking status
+// - Conflict detection when manually scheduling maintenance or blocks
+// - Administrative reporting on room utilization and restriction patterns
+// - Validation of booking requests against existing restrictions
+//
+// Parameters:
+//   - roomID: Specific room to query restrictions for
+//   - start: Beginning of date range to check for overlapping restrictions
+//   - end: End of date range to check for overlapping restrictions
+//
+// Returns:
+//   - []models.RoomRestriction: All restrictions overlapping the specified date range
+//   - error: Database error if query fails, nil on success
+//
+// Each returned restriction includes sufficient information to distinguish between
+// reservation restrictions (with reservation_id) and owner blocks (reservation_id=0).
+func (m *postgresDBRepo) GetRestrictionsForRoomByDate(roomID int, start, end time.Time) ([]models.RoomRestriction, error) {
+	t := turnoverTimes(m.App)
+	restrictions, err := m.GetRestrictionsForRoomBetween(roomID, t.CheckOutAt(start), t.CheckInAt(end.AddDate(0, 0, 1)))
+	if err != nil {
+		return nil, fmt.Errorf("dbrepo.GetRestrictionsForRoomByDate: %w", err)
+	}
+	return restrictions, nil
+}
+
+// GetRestrictionsForRoomBetween retrieves the restrictions that occupy a room at
+// any instant from start up to (not including) end. Restriction dates are
+// whole days, so each one is taken to run from the check-in time on its start
+// date to the check-out time on its end date (AppConfig.CheckInTime and
+// CheckOutTime, defaulting to 3:00 PM and 11:00 AM). That lets a stay checking
+// out on the morning of the 3rd sit beside one checking in that afternoon.
+//
+// The query selects every restriction touching the calendar days from start
+// to end, and the exact timestamp comparison is made on the rows returned.
+// Restriction days are read as UTC dates, so start and end should be on the
+// same UTC wall clock as the check-in and check-out times.
+//
+// Parameters:
+//   - roomID: Room to query restrictions for
+//   - start: First instant of the period
+//   - end: Instant the period ends (exclusive)
+//
+// Returns:
+//   - []models.RoomRestriction: Restrictions occupying the room during the period
+//   - error: Database error if the query fails, nil on success
+func (m *postgresDBRepo) GetRestrictionsForRoomBetween(roomID int, start, end time.Time) ([]models.RoomRestriction, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	defer m.timeQuery("GetRestrictionsForRoomBetween")()
+
+	query := `
+		select
+			id, coalesce(reservation_id, 0), restriction_id, room_id, start_date, end_date, note
+		from
+			room_restrictions
+		where
+			$1 <= end_date
+		and
+			$2 >= start_date
+		and
+			room_id = $3
+	`
+
+	firstDay := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	lastDay := time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, time.UTC)
+
+	rows, err := m.DB.QueryContext(ctx, query, firstDay, lastDay, roomID)
+	if err != nil {
+		return nil, fmt.Errorf("dbrepo.GetRestrictionsForRoomBetween: %w", err)
+	}
+	defer rows.Close()
+
+	t := turnoverTimes(m.App)
+	var restrictions []models.RoomRestriction
+	for rows.Next() {
+		var r models.RoomRestriction
+		err := rows.Scan(
+			&r.ID,
+			&r.ReservationID,
+			&r.RestrictionID,
+			&r.RoomID,
+			&r.StartDate,
+			&r.EndDate,
+			&r.Note,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("dbrepo.GetRestrictionsForRoomBetween: %w", err)
+		}
+		if occupiedBetween(r, start, end, t) {
+			restrictions = append(restrictions, r)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("dbrepo.GetRestrictionsForRoomBetween: %w", err)
+	}
+
+	return restrictions, nil
+}
+
+// GetRestrictionsForAllRoomsByDate retrieves restrictions overlapping a date
+// range for every room in a single query, grouped by room ID. It uses the same
+// overlap conditions as GetRestrictionsForRoomByDate and replaces one query per
+// room when rendering the admin calendar.
+//
+// Parameters:
+//   - start: Beginning of date range to check for overlapping restrictions
+//   - end: End of date range to check for overlapping restrictions
+//
+// Returns:
+//   - map[int][]models.RoomRestriction: Restrictions keyed by room ID, ordered by
+//     start date; rooms without restrictions have no entry
+//   - error: Database error if query fails, nil on success
+func (m *postgresDBRepo) GetRestrictionsForAllRoomsByDate(start, end time.Time) (map[int][]models.RoomRestriction, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	defer m.timeQuery("GetRestrictionsForAllRoomsByDate")()
+
+	restrictions := make(map[int][]models.RoomRestriction)
+
+	query := `
+		select
+			id, coalesce(reservation_id, 0), restriction_id, room_id, start_date, end_date, note
+		from
+			room_restrictions
+		where
+			$1 < end_date
+		and
+			$2 >= start_date
+		order by
+			room_id, start_date
+	`
+
+	rows, err := m.DB.QueryContext(ctx, query, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("dbrepo.GetRestrictionsForAllRoomsByDate: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r models.RoomRestriction
+		err := rows.Scan(
+			&r.ID,
+			&r.ReservationID,
+			&r.RestrictionID,
+			&r.RoomID,
+			&r.StartDate,
+			&r.EndDate,
+			&r.Note,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("dbrepo.GetRestrictionsForAllRoomsByDate: %w", err)
+		}
+		restrictions[r.RoomID] = append(restrictions[r.RoomID], r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("dbrepo.GetRestrictionsForAllRoomsByDate: %w", err)
+	}
+
+	return restrictions, nil
+}
+
+// InsertBlockForRoom creates a block restriction for a specific room and date.
+// Owner blocks are administrative restrictions that prevent guest bookings during
+// maintenance periods, personal use, or other operational requirements. This method
+// creates single-day blocks that can be managed through the administrative calendar interface.
+//
+// Block characteristics:
+// - Restriction type: the supplied reason, 2 (Owner Block) or 3 (Maintenance)
+// - Note: free-text explanation shown to staff on the calendar
+// - Duration: Single day (startDate to startDate + 1 day)
+// - Purpose: Administrative control over room availability
+// - No reservation association: reservation_id remains NULL
+//
+// The single-day approach simplifies calendar management by creating discrete blocks
+// that can be individually added or removed. Multi-day blocks are created by calling
+// this method multiple times for consecutive dates, providing granular control.
+//
+// Administrative workflow:
+// - Staff use calendar interface to click dates for blocking
+// - Each click calls this method to create a single-day owner block
+// - Blocks immediately affect availability searches and prevent new bookings
+// - Blocks can be removed individually using DeleteBlockByID
+//
+// Parameters:
+//   - id: Room ID to create the block for
+//   - startDate: Date to block (end date is automatically set to startDate + 1 day)
+//   - restrictionID: Block reason (restriction type) to record
+//   - note: Staff note explaining the block; may be empty
+//
+// Returns:
+//   - error: Database error if insertion fails, nil on success
+//
+// The method logs errors but also returns them, allowing calling code to decide
+// on appropriate error handling strategies (logging, user notification, rollback).
+func (m *postgresDBRepo) InsertBlockForRoom(id int, startDate time.Time, restrictionID int, note string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	defer m.timeQuery("InsertBlockForRoom")()
+
+	query := `
+		insert into room_restrictions
+			(start_date, end_date, room_id, restriction_id, note, created_at, updated_at)
+		values
+			($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	_, err := m.DB.ExecContext(ctx, query, startDate, startDate.AddDate(0, 0, 1), id, restrictionID, note, time.Now().UTC(), time.Now().UTC())
+	if err != nil {
+		log.Println(err)
+		return fmt.Errorf("dbrepo.InsertBlockForRoom: %w", err)
+	}
+
+	return nil
+
+}
+
+// DeleteBlockByID removes a specific room restriction by its unique identifier.
+// This method is used to remove owner blocks from the administrative calendar
+// interface, allowing staff to unblock dates that were previously restricted.
+// It performs a hard deletion of the restriction record.
+//
+// The method is typically used for:
+// - Removing owner blocks that are no longer needed (maintenance completed, plans changed)
+// - Administrative cleanup of incorrectly created blocks
+// - Calendar interface interactions where staff uncheck blocked dates
 // - Bulk cleanup operations for expired or outdated restrictions
 //
 // Important considerations:
@@ -1068,6 +2232,7 @@ func (m *postgresDBRepo) InsertBlockForRoom(id int, startDate time.Time) error {
 func (m *postgresDBRepo) DeleteBlockByID(id int) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
+	defer m.timeQuery("DeleteBlockByID")()
 
 	query := `
 		delete from
@@ -1079,9 +2244,1093 @@ func (m *postgresDBRepo) DeleteBlockByID(id int) error {
 	_, err := m.DB.ExecContext(ctx, query, id)
 	if err != nil {
 		log.Println(err)
-		return err
+		return fmt.Errorf("dbrepo.DeleteBlockByID: %w", err)
+	}
+
+	return nil
+
+}
+
+// FindOverlappingRestrictions reports every pair of room restrictions on the same
+// room whose date ranges overlap. Because reservations and owner blocks are created
+// through separate workflows, the table can drift into double-booked states; this
+// audit query surfaces them so staff can clean up manually.
+//
+// The query self-joins room_restrictions using the same half-open interval test as
+// availability searches (a.start < b.end AND b.start < a.end). The a.id < b.id
+// condition reports each pair once and excludes a row matching itself.
+//
+// Returns:
+//   - []models.RestrictionConflict: conflicting pairs ordered by room name and start date
+//   - error: Database error if query fails, nil on success
+func (m *postgresDBRepo) FindOverlappingRestrictions() ([]models.RestrictionConflict, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	defer m.timeQuery("FindOverlappingRestrictions")()
+
+	var conflicts []models.RestrictionConflict
+
+	query := `
+		select
+			a.id, a.start_date, a.end_date, coalesce(a.reservation_id, 0), a.restriction_id,
+			b.id, b.start_date, b.end_date, coalesce(b.reservation_id, 0), b.restriction_id,
+			rm.id, rm.room_name
+		from
+			room_restrictions a
+		join
+			room_restrictions b
+		on
+			a.room_id = b.room_id
+			and a.id < b.id
+			and a.start_date < b.end_date
+			and b.start_date < a.end_date
+		left join
+			rooms rm
+		on
+			(a.room_id = rm.id)
+		order by
+			rm.room_name, a.start_date
+	`
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("dbrepo.FindOverlappingRestrictions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c models.RestrictionConflict
+		err := rows.Scan(
+			&c.First.ID,
+			&c.First.StartDate,
+			&c.First.EndDate,
+			&c.First.ReservationID,
+			&c.First.RestrictionID,
+			&c.Second.ID,
+			&c.Second.StartDate,
+			&c.Second.EndDate,
+			&c.Second.ReservationID,
+			&c.Second.RestrictionID,
+			&c.Room.ID,
+			&c.Room.RoomName,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("dbrepo.FindOverlappingRestrictions: %w", err)
+		}
+		c.First.RoomID = c.Room.ID
+		c.Second.RoomID = c.Room.ID
+		conflicts = append(conflicts, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("dbrepo.FindOverlappingRestrictions: %w", err)
+	}
+
+	return conflicts, nil
+}
+
+// AddToWaitlist inserts a waitlist entry for a guest whose search found no
+// available rooms. Staff follow up on these entries when dates free up.
+//
+// Parameters:
+//   - entry: Guest contact details and requested date range
+//
+// Returns:
+//   - error: Database error if insertion fails, nil on success
+func (m *postgresDBRepo) AddToWaitlist(entry models.WaitlistEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	defer m.timeQuery("AddToWaitlist")()
+
+	stmt := `
+		insert into waitlist
+			(first_name, last_name, email, phone, start_date, end_date, created_at, updated_at)
+		values
+			($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+
+	_, err := m.DB.ExecContext(ctx, stmt,
+		entry.FirstName,
+		entry.LastName,
+		entry.Email,
+		entry.Phone,
+		entry.StartDate,
+		entry.EndDate,
+		time.Now().UTC(),
+		time.Now().UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("dbrepo.AddToWaitlist: %w", err)
 	}
 
 	return nil
+}
 
+// WaitlistForDates returns waitlist entries whose requested stay overlaps the
+// half-open range [start, end), oldest first so staff contact guests in the
+// order they asked.
+//
+// Parameters:
+//   - start: Beginning of the freed date range (inclusive)
+//   - end: End of the freed date range (exclusive)
+//
+// Returns:
+//   - []models.WaitlistEntry: Matching entries, oldest first
+//   - error: Database error if the query fails, nil on success
+func (m *postgresDBRepo) WaitlistForDates(start, end time.Time) ([]models.WaitlistEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	defer m.timeQuery("WaitlistForDates")()
+
+	var entries []models.WaitlistEntry
+
+	query := `
+		select
+			id, first_name, last_name, email, phone, start_date, end_date, created_at, updated_at
+		from
+			waitlist
+		where
+			start_date < $2 and end_date > $1
+		order by
+			created_at
+	`
+
+	rows, err := m.DB.QueryContext(ctx, query, start, end)
+	if err != nil {
+		return entries, fmt.Errorf("dbrepo.WaitlistForDates: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e models.WaitlistEntry
+		err := rows.Scan(
+			&e.ID,
+			&e.FirstName,
+			&e.LastName,
+			&e.Email,
+			&e.Phone,
+			&e.StartDate,
+			&e.EndDate,
+			&e.CreatedAt,
+			&e.UpdatedAt,
+		)
+		if err != nil {
+			return entries, fmt.Errorf("dbrepo.WaitlistForDates: %w", err)
+		}
+		entries = append(entries, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return entries, fmt.Errorf("dbrepo.WaitlistForDates: %w", err)
+	}
+
+	return entries, nil
+}
+
+// NextAvailableDate loads the room's restrictions in the next
+// repository.NextAvailableHorizonDays nights with one query and scans forward
+// from from to the first night none of them covers.
+//
+// Parameters:
+//   - roomID: Room to check
+//   - from: First night to consider (a date; the time of day is ignored)
+//
+// Returns:
+//   - time.Time: First free night
+//   - error: repository.ErrNoAvailability if the room is booked through the
+//     horizon, other database errors wrapped
+func (m *postgresDBRepo) NextAvailableDate(roomID int, from time.Time) (time.Time, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	defer m.timeQuery("NextAvailableDate")()
+
+	from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	until := from.AddDate(0, 0, repository.NextAvailableHorizonDays)
+
+	query := `
+		select
+			start_date, end_date
+		from
+			room_restrictions
+		where
+			room_id = $1
+		and
+			$2 < end_date
+		and
+			$3 > start_date
+		and
+			(hold_expires_at is null or hold_expires_at > now())
+		order by
+			start_date
+	`
+
+	rows, err := m.DB.QueryContext(ctx, query, roomID, from, until)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("dbrepo.NextAvailableDate: %w", err)
+	}
+	defer rows.Close()
+
+	var restrictions []models.RoomRestriction
+	for rows.Next() {
+		var r models.RoomRestriction
+		if err := rows.Scan(&r.StartDate, &r.EndDate); err != nil {
+			return time.Time{}, fmt.Errorf("dbrepo.NextAvailableDate: %w", err)
+		}
+		restrictions = append(restrictions, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return time.Time{}, fmt.Errorf("dbrepo.NextAvailableDate: %w", err)
+	}
+
+	night, ok := firstFreeNight(from, until, restrictions)
+	if !ok {
+		return time.Time{}, repository.ErrNoAvailability
+	}
+	return night, nil
+}
+
+// FindAvailableWindows loads the room's restrictions overlapping the month
+// that starts at monthStart with one query and returns the gaps between them
+// that are at least nights long. Nights run from the first to the last day
+// of the month, so a window can end (check out) on the first of the next.
+//
+// Parameters:
+//   - roomID: Room to check
+//   - monthStart: Any time in the first day of the month; only the year and
+//     month are used
+//   - nights: Minimum window length
+//
+// Returns:
+//   - []models.DateRange: Free windows, earliest first; empty when none fit
+//   - error: Database errors wrapped
+func (m *postgresDBRepo) FindAvailableWindows(roomID int, monthStart time.Time, nights int) ([]models.DateRange, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	defer m.timeQuery("FindAvailableWindows")()
+
+	from := time.Date(monthStart.Year(), monthStart.Month(), 1, 0, 0, 0, 0, time.UTC)
+	until := from.AddDate(0, 1, 0)
+
+	query := `
+		select
+			start_date, end_date
+		from
+			room_restrictions
+		where
+			room_id = $1
+		and
+			$2 < end_date
+		and
+			$3 > start_date
+		and
+			(hold_expires_at is null or hold_expires_at > now())
+		order by
+			start_date
+	`
+
+	rows, err := m.DB.QueryContext(ctx, query, roomID, from, until)
+	if err != nil {
+		return nil, fmt.Errorf("dbrepo.FindAvailableWindows: %w", err)
+	}
+	defer rows.Close()
+
+	var restrictions []models.RoomRestriction
+	for rows.Next() {
+		var r models.RoomRestriction
+		if err := rows.Scan(&r.StartDate, &r.EndDate); err != nil {
+			return nil, fmt.Errorf("dbrepo.FindAvailableWindows: %w", err)
+		}
+		restrictions = append(restrictions, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("dbrepo.FindAvailableWindows: %w", err)
+	}
+
+	return freeWindows(from, until, nights, restrictions), nil
+}
+
+// GetRateForDate returns the nightly rate in cents for a single night in a
+// room. A room_rates row for that date (weekend, holiday or seasonal pricing)
+// takes precedence; otherwise the room's base nightly_rate applies.
+//
+// Parameters:
+//   - roomID: Room being priced
+//   - date: The night being priced (check-in date of that night)
+//
+// Returns:
+//   - int: Rate in cents
+//   - error: sql.ErrNoRows if the room does not exist, or a database error
+func (m *postgresDBRepo) GetRateForDate(roomID int, date time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	defer m.timeQuery("GetRateForDate")()
+
+	query := `
+		select
+			coalesce(rr.nightly_rate, r.nightly_rate)
+		from
+			rooms r
+			left join room_rates rr on (rr.room_id = r.id and rr.rate_date = $2)
+		where
+			r.id = $1
+	`
+
+	var rate int
+	err := m.DB.QueryRowContext(ctx, query, roomID, date).Scan(&rate)
+	if err != nil {
+		return 0, fmt.Errorf("dbrepo.GetRateForDate: %w", err)
+	}
+
+	return rate, nil
+}
+
+// ReservationExists reports whether a reservation for the same guest, room
+// and dates is already stored, so a double-submitted booking form can be
+// rejected instead of creating an identical second reservation. Email
+// matching is case-insensitive, like ReservationsByEmail.
+//
+// Parameters:
+//   - email: Guest email address to match
+//   - roomID: Room the reservation is for
+//   - start: Arrival date
+//   - end: Departure date
+//
+// Returns:
+//   - bool: true if a matching reservation exists
+//   - error: Database error if query fails, nil on success
+func (m *postgresDBRepo) ReservationExists(email string, roomID int, start, end time.Time) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	defer m.timeQuery("ReservationExists")()
+
+	query := `
+		select exists (
+			select
+				1
+			from
+				reservations
+			where
+				lower(email) = lower($1)
+			and
+				room_id = $2
+			and
+				start_date = $3 and end_date = $4
+		)
+	`
+
+	var exists bool
+	err := m.DB.QueryRowContext(ctx, query, email, roomID, start, end).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("dbrepo.ReservationExists: %w", err)
+	}
+
+	return exists, nil
+}
+
+// ExpireUnconfirmedReservations deletes reservations that are still pending
+// (nobody has confirmed them) and were created before olderThan, releasing
+// the dates they hold. Their room_restrictions rows go with them through the
+// reservation_id foreign key's ON DELETE CASCADE.
+//
+// Parameters:
+//   - olderThan: Reservations created before this instant are expired
+//
+// Returns:
+//   - int: Number of reservations deleted
+//   - error: Database error if the delete fails, nil on success
+func (m *postgresDBRepo) ExpireUnconfirmedReservations(olderThan time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	defer m.timeQuery("ExpireUnconfirmedReservations")()
+
+	query := `
+		delete
+		from
+			reservations
+		where
+			status = 'pending'
+		and
+			created_at < $1
+	`
+
+	result, err := m.DB.ExecContext(ctx, query, olderThan)
+	if err != nil {
+		return 0, fmt.Errorf("dbrepo.ExpireUnconfirmedReservations: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("dbrepo.ExpireUnconfirmedReservations: %w", err)
+	}
+
+	return int(n), nil
+}
+
+// lockRoomForDates locks the room's row for the rest of tx and returns
+// repository.ErrDatesTaken when a reservation, block or unexpired hold already
+// covers any night from start to end. Holding the lock until commit makes the
+// check and the caller's insert atomic with respect to other bookings of the
+// same room, which take the same lock first.
+func lockRoomForDates(ctx context.Context, tx *sql.Tx, roomID int, start, end time.Time) error {
+	var locked int
+	err := tx.QueryRowContext(ctx, `select id from rooms where id = $1 for update`, roomID).Scan(&locked)
+	if err != nil {
+		return err
+	}
+
+	var taken int
+	err = tx.QueryRowContext(ctx, `
+		select
+			count(id)
+		from
+			room_restrictions
+		where
+			room_id = $1
+		and
+			$2 < end_date
+		and
+			$3 > start_date
+		and
+			(hold_expires_at is null or hold_expires_at > $4)`,
+		roomID, start, end, time.Now().UTC(),
+	).Scan(&taken)
+	if err != nil {
+		return err
+	}
+	if taken > 0 {
+		return repository.ErrDatesTaken
+	}
+	return nil
+}
+
+// CreateHold stores a hold on a room's nights for a guest who has chosen a
+// room but not yet submitted the booking form. The room row is locked for the
+// check and insert, so two guests choosing the same room at once can't both
+// get a hold; whoever is second sees repository.ErrDatesTaken. Holds past
+// their expiry are ignored by the check even before ExpireHolds removes them.
+//
+// Parameters:
+//   - roomID: Room to hold
+//   - start: First night (check-in date)
+//   - end: Check-out date
+//   - expiresAt: When the hold lapses if the guest hasn't booked
+//
+// Returns:
+//   - int: ID of the hold's room_restrictions row
+//   - error: repository.ErrDatesTaken if any night is already reserved,
+//     blocked or held, sql.ErrNoRows (wrapped) for an unknown room, other
+//     database errors wrapped
+func (m *postgresDBRepo) CreateHold(roomID int, start, end, expiresAt time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	defer m.timeQuery("CreateHold")()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("dbrepo.CreateHold: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err = lockRoomForDates(ctx, tx, roomID, start, end); err != nil {
+		if errors.Is(err, repository.ErrDatesTaken) {
+			return 0, err
+		}
+		return 0, fmt.Errorf("dbrepo.CreateHold: %w", err)
+	}
+
+	now := time.Now().UTC()
+
+	var id int
+	err = tx.QueryRowContext(ctx, `
+		insert into room_restrictions (start_date, end_date, room_id, restriction_id,
+			hold_expires_at, created_at, updated_at)
+		values ($1, $2, $3, $4, $5, $6, $7)
+		returning id`,
+		start, end, roomID, repository.HoldRestrictionID, expiresAt.UTC(), now, now,
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("dbrepo.CreateHold: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("dbrepo.CreateHold: %w", err)
+	}
+
+	return id, nil
+}
+
+// ReleaseHold deletes a hold, freeing its nights. Only hold rows are
+// touched, so a stale ID from a session can never remove a reservation's
+// restriction or a staff block.
+//
+// Parameters:
+//   - id: Hold ID returned by CreateHold
+//
+// Returns:
+//   - error: Database error if the delete fails; a missing hold is not an error
+func (m *postgresDBRepo) ReleaseHold(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	defer m.timeQuery("ReleaseHold")()
+
+	_, err := m.DB.ExecContext(ctx,
+		`delete from room_restrictions where id = $1 and restriction_id = $2`,
+		id, repository.HoldRestrictionID,
+	)
+	if err != nil {
+		return fmt.Errorf("dbrepo.ReleaseHold: %w", err)
+	}
+
+	return nil
+}
+
+// ExpireHolds deletes holds whose guests never finished booking, returning
+// their nights to availability searches.
+//
+// Parameters:
+//   - now: Holds that expired at or before this instant are removed
+//
+// Returns:
+//   - int: Number of holds deleted
+//   - error: Database error if the delete fails, nil on success
+func (m *postgresDBRepo) ExpireHolds(now time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	defer m.timeQuery("ExpireHolds")()
+
+	query := `
+		delete
+		from
+			room_restrictions
+		where
+			restriction_id = $1
+		and
+			hold_expires_at <= $2
+	`
+
+	result, err := m.DB.ExecContext(ctx, query, repository.HoldRestrictionID, now.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("dbrepo.ExpireHolds: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("dbrepo.ExpireHolds: %w", err)
+	}
+
+	return int(n), nil
+}
+
+// ReservationsByEmail returns all reservations made with a guest email address,
+// newest stay first, with each reservation's room name loaded. Email matching is
+// case-insensitive so "Jane@Example.com" and "jane@example.com" are one guest.
+// Staff use it to see a guest's booking history from the reservation page.
+//
+// Parameters:
+//   - email: Guest email address to match
+//
+// Returns:
+//   - []models.Reservation: Matching reservations ordered by start date, newest first
+//   - error: Database error if query fails, nil on success
+func (m *postgresDBRepo) ReservationsByEmail(email string) ([]models.Reservation, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	defer m.timeQuery("ReservationsByEmail")()
+
+	var reservations []models.Reservation
+
+	query := `
+		select
+			r.id, r.first_name, r.last_name, r.email, r.phone, r.start_date,
+			r.end_date, r.room_id, r.created_at, r.updated_at, r.processed,
+			rm.id, rm.room_name
+		from
+			reservations r
+		left join
+			rooms rm
+		on
+			(r.room_id = rm.id)
+		where
+			lower(r.email) = lower($1)
+		order by
+			r.start_date desc
+	`
+
+	rows, err := m.DB.QueryContext(ctx, query, email)
+	if err != nil {
+		return reservations, fmt.Errorf("dbrepo.ReservationsByEmail: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var i models.Reservation
+		err := rows.Scan(
+			&i.ID,
+			&i.FirstName,
+			&i.LastName,
+			&i.Email,
+			&i.Phone,
+			&i.StartDate,
+			&i.EndDate,
+			&i.RoomID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Processed,
+			&i.Room.ID,
+			&i.Room.RoomName,
+		)
+		if err != nil {
+			return reservations, fmt.Errorf("dbrepo.ReservationsByEmail: %w", err)
+		}
+		reservations = append(reservations, i)
+	}
+
+	if err = rows.Err(); err != nil {
+		return reservations, fmt.Errorf("dbrepo.ReservationsByEmail: %w", err)
+	}
+
+	return reservations, nil
+}
+
+// RecordAudit appends an entry to audit_log for an action taken by userID.
+//
+// Parameters:
+//   - userID: ID of the staff member who performed the action
+//   - action: Short action key, e.g. "reservation.delete"
+//   - detail: Human-readable description of what changed
+//
+// Returns:
+//   - error: Database error if the insert fails, nil on success
+func (m *postgresDBRepo) RecordAudit(userID int, action, detail string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	defer m.timeQuery("RecordAudit")()
+
+	stmt := `
+		insert into audit_log
+			(user_id, action, detail, created_at)
+		values
+			($1, $2, $3, $4)
+	`
+
+	_, err := m.DB.ExecContext(ctx, stmt, userID, action, detail, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("dbrepo.RecordAudit: %w", err)
+	}
+
+	return nil
+}
+
+// RecentAudit returns up to limit audit entries, newest first, with the acting
+// user's email joined in for display.
+//
+// Parameters:
+//   - limit: Maximum number of entries to return
+//
+// Returns:
+//   - []models.AuditEntry: Entries, newest first
+//   - error: Database error if the query fails, nil on success
+func (m *postgresDBRepo) RecentAudit(limit int) ([]models.AuditEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	defer m.timeQuery("RecentAudit")()
+
+	var entries []models.AuditEntry
+
+	query := `
+		select
+			a.id, a.user_id, coalesce(u.email, ''), a.action, a.detail, a.created_at
+		from
+			audit_log a
+			left join users u on (u.id = a.user_id)
+		order by
+			a.created_at desc, a.id desc
+		limit $1
+	`
+
+	rows, err := m.DB.QueryContext(ctx, query, limit)
+	if err != nil {
+		return entries, fmt.Errorf("dbrepo.RecentAudit: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e models.AuditEntry
+		err := rows.Scan(
+			&e.ID,
+			&e.UserID,
+			&e.UserEmail,
+			&e.Action,
+			&e.Detail,
+			&e.CreatedAt,
+		)
+		if err != nil {
+			return entries, fmt.Errorf("dbrepo.RecentAudit: %w", err)
+		}
+		entries = append(entries, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return entries, fmt.Errorf("dbrepo.RecentAudit: %w", err)
+	}
+
+	return entries, nil
+}
+
+// LogSentMail appends an entry to mail_log for an attempt to send msg. Only
+// the envelope and subject are kept; the body is not stored.
+//
+// Parameters:
+//   - msg: Message that was sent or attempted
+//   - status: Outcome, models.MailStatusSent or models.MailStatusFailed
+//
+// Returns:
+//   - error: Database error if the insert fails, nil on success
+func (m *postgresDBRepo) LogSentMail(msg models.MailData, status string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	defer m.timeQuery("LogSentMail")()
+
+	stmt := `
+		insert into mail_log
+			(recipient, sender, subject, template, status, created_at)
+		values
+			($1, $2, $3, $4, $5, $6)
+	`
+
+	_, err := m.DB.ExecContext(ctx, stmt, msg.To, msg.From, msg.Subject, msg.Template, status, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("dbrepo.LogSentMail: %w", err)
+	}
+
+	return nil
+}
+
+// RecentSentMail returns up to limit mail log entries, newest first.
+//
+// Parameters:
+//   - limit: Maximum number of entries to return
+//
+// Returns:
+//   - []models.MailLogEntry: Entries, newest first
+//   - error: Database error if the query fails, nil on success
+func (m *postgresDBRepo) RecentSentMail(limit int) ([]models.MailLogEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	defer m.timeQuery("RecentSentMail")()
+
+	var entries []models.MailLogEntry
+
+	query := `
+		select
+			id, recipient, sender, subject, template, status, created_at
+		from
+			mail_log
+		order by
+			created_at desc, id desc
+		limit $1
+	`
+
+	rows, err := m.DB.QueryContext(ctx, query, limit)
+	if err != nil {
+		return entries, fmt.Errorf("dbrepo.RecentSentMail: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e models.MailLogEntry
+		err := rows.Scan(
+			&e.ID,
+			&e.To,
+			&e.From,
+			&e.Subject,
+			&e.Template,
+			&e.Status,
+			&e.CreatedAt,
+		)
+		if err != nil {
+			return entries, fmt.Errorf("dbrepo.RecentSentMail: %w", err)
+		}
+		entries = append(entries, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return entries, fmt.Errorf("dbrepo.RecentSentMail: %w", err)
+	}
+
+	return entries, nil
+}
+
+// DeadLetterMail stores msg in outbound_mail with status
+// models.MailStatusFailed after a delivery attempt failed. The whole message
+// is kept, body included, so RequeueMail can hand it back unchanged.
+//
+// A message marked Sensitive is not stored at all: its body holds a secret
+// (a reset link is as good as a password until it expires) that must not sit
+// in the database in plain text. The failure still shows in the mail log,
+// and the user can ask for a new link.
+//
+// Parameters:
+//   - msg: Message that could not be delivered
+//   - sendErr: Error text from the failed attempt
+//
+// Returns:
+//   - error: Database error if the insert fails, nil on success
+func (m *postgresDBRepo) DeadLetterMail(msg models.MailData, sendErr string) error {
+	if msg.Sensitive {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	defer m.timeQuery("DeadLetterMail")()
+
+	now := time.Now().UTC()
+	stmt := `
+		insert into outbound_mail
+			(recipient, sender, subject, template, content, status, last_error, created_at, updated_at)
+		values
+			($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+
+	_, err := m.DB.ExecContext(ctx, stmt,
+		msg.To, msg.From, msg.Subject, msg.Template, msg.Content,
+		models.MailStatusFailed, sendErr, now, now,
+	)
+	if err != nil {
+		return fmt.Errorf("dbrepo.DeadLetterMail: %w", err)
+	}
+
+	return nil
+}
+
+// FailedMail returns every message in outbound_mail with status
+// models.MailStatusFailed, newest first.
+//
+// Returns:
+//   - []models.OutboundMail: Failed messages, newest first
+//   - error: Database error if the query fails, nil on success
+func (m *postgresDBRepo) FailedMail() ([]models.OutboundMail, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	defer m.timeQuery("FailedMail")()
+
+	var msgs []models.OutboundMail
+
+	query := `
+		select
+			id, recipient, sender, subject, template, content, status, last_error, created_at
+		from
+			outbound_mail
+		where
+			status = $1
+		order by
+			created_at desc, id desc
+	`
+
+	rows, err := m.DB.QueryContext(ctx, query, models.MailStatusFailed)
+	if err != nil {
+		return msgs, fmt.Errorf("dbrepo.FailedMail: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var o models.OutboundMail
+		err := rows.Scan(
+			&o.ID,
+			&o.To,
+			&o.From,
+			&o.Subject,
+			&o.Template,
+			&o.Content,
+			&o.Status,
+			&o.Error,
+			&o.CreatedAt,
+		)
+		if err != nil {
+			return msgs, fmt.Errorf("dbrepo.FailedMail: %w", err)
+		}
+		msgs = append(msgs, o)
+	}
+
+	if err = rows.Err(); err != nil {
+		return msgs, fmt.Errorf("dbrepo.FailedMail: %w", err)
+	}
+
+	return msgs, nil
+}
+
+// RequeueMail deletes a failed message from outbound_mail and returns it for
+// sending. Removing the row in the same statement means two retries of the
+// same message can't both queue it; a retry that fails again is
+// dead-lettered as a new row.
+//
+// Parameters:
+//   - id: outbound_mail ID of the failed message
+//
+// Returns:
+//   - models.MailData: The message as originally queued
+//   - error: Wraps sql.ErrNoRows if no failed message has the ID, other database errors wrapped
+func (m *postgresDBRepo) RequeueMail(id int) (models.MailData, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	defer m.timeQuery("RequeueMail")()
+
+	var msg models.MailData
+
+	query := `
+		delete from outbound_mail
+		where
+			id = $1 and status = $2
+		returning
+			recipient, sender, subject, template, content
+	`
+
+	err := m.DB.QueryRowContext(ctx, query, id, models.MailStatusFailed).Scan(
+		&msg.To,
+		&msg.From,
+		&msg.Subject,
+		&msg.Template,
+		&msg.Content,
+	)
+	if err != nil {
+		return msg, fmt.Errorf("dbrepo.RequeueMail: %w", err)
+	}
+
+	return msg, nil
+}
+
+// BookingsPerDay counts reservations by the UTC day they were made, for the
+// admin dashboard chart. Days with no reservations have no row; callers fill
+// the gaps.
+//
+// Parameters:
+//   - start: First day of the range (inclusive)
+//   - end: End of the range (exclusive)
+//
+// Returns:
+//   - []models.DayCount: One entry per day with at least one reservation, oldest first
+//   - error: Database error if the query fails, nil on success
+func (m *postgresDBRepo) BookingsPerDay(start, end time.Time) ([]models.DayCount, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	defer m.timeQuery("BookingsPerDay")()
+
+	var counts []models.DayCount
+
+	query := `
+		select
+			date(created_at) as day, count(*)
+		from
+			reservations
+		where
+			created_at >= $1 and created_at < $2
+		group by
+			day
+		order by
+			day
+	`
+
+	rows, err := m.DB.QueryContext(ctx, query, start.UTC(), end.UTC())
+	if err != nil {
+		return counts, fmt.Errorf("dbrepo.BookingsPerDay: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c models.DayCount
+		if err := rows.Scan(&c.Date, &c.Count); err != nil {
+			return counts, fmt.Errorf("dbrepo.BookingsPerDay: %w", err)
+		}
+		counts = append(counts, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return counts, fmt.Errorf("dbrepo.BookingsPerDay: %w", err)
+	}
+
+	return counts, nil
+}
+
+// GetIdempotentResult returns the reservation ID saved for an Idempotency-Key.
+//
+// Parameters:
+//   - key: Idempotency-Key header value
+//
+// Returns:
+//   - int: ID of the reservation the key's first request created
+//   - error: repository.ErrIdempotencyKeyNotFound if the key has no row, other database errors wrapped
+func (m *postgresDBRepo) GetIdempotentResult(key string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	defer m.timeQuery("GetIdempotentResult")()
+
+	var resID int
+	err := m.DB.QueryRowContext(ctx, `select reservation_id from idempotency_keys where key = $1`, key).Scan(&resID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, repository.ErrIdempotencyKeyNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("dbrepo.GetIdempotentResult: %w", err)
+	}
+
+	return resID, nil
+}
+
+// GetSetting returns the value stored in the settings table for key.
+//
+// Parameters:
+//   - key: Setting name, e.g. "check_in_time"
+//
+// Returns:
+//   - string: Stored value
+//   - error: repository.ErrSettingNotFound if the key has no row, other database errors wrapped
+func (m *postgresDBRepo) GetSetting(key string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	defer m.timeQuery("GetSetting")()
+
+	var value string
+	err := m.DB.QueryRowContext(ctx, `select value from settings where key = $1`, key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", repository.ErrSettingNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("dbrepo.GetSetting: %w", err)
+	}
+
+	return value, nil
+}
+
+// SetSetting inserts or replaces the value stored for key and stamps
+// updated_at.
+//
+// Parameters:
+//   - key: Setting name, e.g. "check_in_time"
+//   - value: New value
+//
+// Returns:
+//   - error: Database error if the upsert fails, nil on success
+func (m *postgresDBRepo) SetSetting(key, value string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	defer m.timeQuery("SetSetting")()
+
+	stmt := `
+		insert into settings
+			(key, value, updated_at)
+		values
+			($1, $2, $3)
+		on conflict (key) do update
+			set value = excluded.value, updated_at = excluded.updated_at
+	`
+
+	_, err := m.DB.ExecContext(ctx, stmt, key, value, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("dbrepo.SetSetting: %w", err)
+	}
+
+	return nil
 }
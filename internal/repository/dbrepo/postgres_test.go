@@ -0,0 +1,2002 @@
+// Package dbrepo contains tests for database-independent helpers used by the
+// PostgreSQL repository, such as bcrypt cost handling during authentication,
+// and for the in-memory behavior of the testing repository.
+package dbrepo
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bensabler/milos-residence/internal/config"
+	"github.com/bensabler/milos-residence/internal/models"
+	"github.com/bensabler/milos-residence/internal/repository"
+	"github.com/jackc/pgx/v5/pgconn"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// TestNeedsRehash_UpgradesLowerCost verifies the cost-upgrade path: a hash below
+// the target cost is flagged, and rehashing produces a hash at the target cost
+// that still verifies against the original password.
+func TestNeedsRehash_UpgradesLowerCost(t *testing.T) {
+	target := bcrypt.MinCost + 1
+
+	old, err := hashPassword("password", bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !needsRehash(old, target) {
+		t.Fatal("expected hash below target cost to need a rehash")
+	}
+
+	upgraded, err := hashPassword("password", target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cost, err := bcrypt.Cost([]byte(upgraded))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cost != target {
+		t.Fatalf("upgraded cost: got %d, want %d", cost, target)
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(upgraded), []byte("password")); err != nil {
+		t.Fatalf("upgraded hash does not verify: %v", err)
+	}
+	if needsRehash(upgraded, target) {
+		t.Fatal("upgraded hash should not need another rehash")
+	}
+}
+
+// TestNeedsRehash_LeavesMatchingCost verifies that hashes at or above the target
+// cost, and unparseable values, are left unchanged.
+func TestNeedsRehash_LeavesMatchingCost(t *testing.T) {
+	hash, err := hashPassword("password", bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if needsRehash(hash, bcrypt.MinCost) {
+		t.Fatal("hash at target cost should not need a rehash")
+	}
+	if needsRehash("not-a-bcrypt-hash", bcrypt.MinCost) {
+		t.Fatal("unparseable hash should not be flagged for rehash")
+	}
+}
+
+// TestBcryptCost verifies that the repository uses the configured cost and
+// falls back to the default when none is set.
+func TestBcryptCost(t *testing.T) {
+	repo := &postgresDBRepo{App: &config.AppConfig{}}
+	if got := repo.bcryptCost(); got != defaultBcryptCost {
+		t.Fatalf("default cost: got %d, want %d", got, defaultBcryptCost)
+	}
+
+	repo.App.BcryptCost = 14
+	if got := repo.bcryptCost(); got != 14 {
+		t.Fatalf("configured cost: got %d, want 14", got)
+	}
+}
+
+// TestAuthenticate_EmailCase verifies that Authenticate looks the user up by
+// email ignoring case, so an address typed with different capitals signs in
+// to the same account.
+func TestAuthenticate_EmailCase(t *testing.T) {
+	hash, err := hashPassword("password", bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := &fakeConnector{columns: []string{"id", "password"}, rows: [][]driver.Value{{int64(7), hash}}}
+	db := sql.OpenDB(conn)
+	defer db.Close()
+	repo := NewPostgresRepo(db, &config.AppConfig{BcryptCost: bcrypt.MinCost})
+
+	id, _, err := repo.Authenticate("Admin@Example.com", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 7 {
+		t.Errorf("id: got %d, want 7", id)
+	}
+	if !strings.Contains(conn.lastSQL, "lower(email) = lower($1)") || conn.lastArgs[0] != "Admin@Example.com" {
+		t.Errorf("email match should be case-insensitive: %s %v", conn.lastSQL, conn.lastArgs)
+	}
+}
+
+// TestTestingRepo_Waitlist verifies the add-then-query contract shared by the
+// waitlist implementations: entries overlapping the half-open range are
+// returned, adjacent or disjoint ranges are not, and forced errors surface.
+func TestTestingRepo_Waitlist(t *testing.T) {
+	ResetWaitlist()
+	defer ResetWaitlist()
+
+	repo := NewTestingRepo(&config.AppConfig{})
+	day := func(d int) time.Time { return time.Date(2100, time.March, d, 0, 0, 0, 0, time.UTC) }
+
+	if err := repo.AddToWaitlist(models.WaitlistEntry{Email: "a@example.com", StartDate: day(1), EndDate: day(4)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.AddToWaitlist(models.WaitlistEntry{Email: "b@example.com", StartDate: day(10), EndDate: day(12)}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		start, end time.Time
+		want       []string
+	}{
+		{name: "overlaps first", start: day(3), end: day(5), want: []string{"a@example.com"}},
+		{name: "overlaps both", start: day(2), end: day(11), want: []string{"a@example.com", "b@example.com"}},
+		{name: "adjacent checkout", start: day(4), end: day(6), want: nil},
+		{name: "disjoint", start: day(20), end: day(22), want: nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			entries, err := repo.WaitlistForDates(tc.start, tc.end)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != len(tc.want) {
+				t.Fatalf("entries: got %d, want %d", len(entries), len(tc.want))
+			}
+			for i, e := range entries {
+				if e.Email != tc.want[i] {
+					t.Errorf("entry %d: got %s, want %s", i, e.Email, tc.want[i])
+				}
+			}
+		})
+	}
+
+	ForceWaitlistErr = true
+	defer func() { ForceWaitlistErr = false }()
+
+	if err := repo.AddToWaitlist(models.WaitlistEntry{}); err == nil {
+		t.Error("expected AddToWaitlist error")
+	}
+	if _, err := repo.WaitlistForDates(day(1), day(2)); err == nil {
+		t.Error("expected WaitlistForDates error")
+	}
+}
+
+// TestWaitlist verifies the PostgreSQL waitlist queries: AddToWaitlist writes
+// the entry's details and dates, WaitlistForDates passes the half-open range
+// and scans matching rows, and database errors are wrapped.
+func TestWaitlist(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2100, time.March, d, 0, 0, 0, 0, time.UTC) }
+	created := time.Date(2099, time.December, 1, 0, 0, 0, 0, time.UTC)
+
+	conn := &fakeConnector{
+		columns: []string{"id", "first_name", "last_name", "email", "phone", "start_date", "end_date", "created_at", "updated_at"},
+		rows: [][]driver.Value{
+			{int64(4), "Jane", "Doe", "jane@example.com", "555-555-5555", day(1), day(4), created, created},
+		},
+		affected: 1,
+	}
+	db := sql.OpenDB(conn)
+	defer db.Close()
+	repo := NewPostgresRepo(db, &config.AppConfig{})
+
+	entry := models.WaitlistEntry{FirstName: "Jane", LastName: "Doe", Email: "jane@example.com", Phone: "555-555-5555", StartDate: day(1), EndDate: day(4)}
+	if err := repo.AddToWaitlist(entry); err != nil {
+		t.Fatal(err)
+	}
+	// first_name, last_name, email, phone, start_date, end_date, created_at, updated_at.
+	if len(conn.execArgs) != 8 || conn.execArgs[2] != "jane@example.com" {
+		t.Fatalf("insert args: got %v", conn.execArgs)
+	}
+	if got, ok := conn.execArgs[5].(time.Time); !ok || !got.Equal(day(4)) {
+		t.Errorf("end date: got %v, want %v", conn.execArgs[5], day(4))
+	}
+
+	entries, err := repo.WaitlistForDates(day(3), day(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(conn.lastSQL, "start_date < $2 and end_date > $1") {
+		t.Errorf("range should be half-open: %s", conn.lastSQL)
+	}
+	if len(conn.lastArgs) != 2 || !conn.lastArgs[0].(time.Time).Equal(day(3)) || !conn.lastArgs[1].(time.Time).Equal(day(5)) {
+		t.Errorf("query args: got %v", conn.lastArgs)
+	}
+	if len(entries) != 1 || entries[0].ID != 4 || entries[0].Email != "jane@example.com" || !entries[0].EndDate.Equal(day(4)) {
+		t.Fatalf("entries: got %+v", entries)
+	}
+
+	conn.execErr = errors.New("insert failed")
+	conn.err = errors.New("query failed")
+	if err := repo.AddToWaitlist(entry); err == nil || !strings.Contains(err.Error(), "dbrepo.AddToWaitlist") {
+		t.Errorf("insert error: got %v", err)
+	}
+	if _, err := repo.WaitlistForDates(day(3), day(5)); err == nil || !strings.Contains(err.Error(), "dbrepo.WaitlistForDates") {
+		t.Errorf("query error: got %v", err)
+	}
+}
+
+// fakeConnector is a minimal database/sql driver that answers every query with
+// a fixed result set and counts the queries it receives. Exec arguments are
+// recorded so tests can check what would have been written. It stands in for a
+// real PostgreSQL connection in tests that only need to check query counts and
+// row handling. A delay simulates slow queries.
+type fakeConnector struct {
+	columns  []string
+	rows     [][]driver.Value
+	results  [][][]driver.Value // per-query rows, used in order before rows
+	queries  int
+	execArgs []driver.Value
+	delay    time.Duration  // simulated query latency
+	lastSQL  string         // text of the most recent query or exec
+	lastArgs []driver.Value // arguments of the most recent query
+	err      error          // returned by every query when set
+	affected int64          // rows affected reported by every exec
+	execErr  error          // returned by every exec when set
+	commits  int            // transactions committed
+	rollback int            // transactions rolled back
+}
+
+func (c *fakeConnector) Connect(context.Context) (driver.Conn, error) { return &fakeConn{c: c}, nil }
+func (c *fakeConnector) Driver() driver.Driver                        { return nil }
+
+// fakeConn implements driver.QueryerContext and driver.ExecerContext so
+// database/sql skips Prepare.
+type fakeConn struct{ c *fakeConnector }
+
+func (fc *fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (fc *fakeConn) Close() error                        { return nil }
+func (fc *fakeConn) Begin() (driver.Tx, error)           { return &fakeTx{c: fc.c}, nil }
+
+// fakeTx counts how transactions end; queries inside one go through fakeConn
+// as usual.
+type fakeTx struct{ c *fakeConnector }
+
+func (tx *fakeTx) Commit() error   { tx.c.commits++; return nil }
+func (tx *fakeTx) Rollback() error { tx.c.rollback++; return nil }
+
+func (fc *fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	fc.c.queries++
+	fc.c.lastSQL = query
+	fc.c.lastArgs = fc.c.lastArgs[:0]
+	for _, a := range args {
+		fc.c.lastArgs = append(fc.c.lastArgs, a.Value)
+	}
+	time.Sleep(fc.c.delay)
+	if fc.c.err != nil {
+		return nil, fc.c.err
+	}
+	if len(fc.c.results) > 0 {
+		rows := fc.c.results[0]
+		fc.c.results = fc.c.results[1:]
+		return &fakeRows{columns: fc.c.columns, rows: rows}, nil
+	}
+	return &fakeRows{columns: fc.c.columns, rows: fc.c.rows}, nil
+}
+
+func (fc *fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	fc.c.lastSQL = query
+	fc.c.execArgs = fc.c.execArgs[:0]
+	for _, a := range args {
+		fc.c.execArgs = append(fc.c.execArgs, a.Value)
+	}
+	if fc.c.execErr != nil {
+		return nil, fc.c.execErr
+	}
+	return driver.RowsAffected(fc.c.affected), nil
+}
+
+// fakeRows iterates over a fixed set of rows.
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+	pos     int
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.pos])
+	r.pos++
+	return nil
+}
+
+// TestGetRestrictionsForAllRoomsByDate verifies that restrictions for every
+// room are loaded with a single query and grouped by room ID.
+func TestGetRestrictionsForAllRoomsByDate(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2050, time.January, d, 0, 0, 0, 0, time.UTC) }
+
+	conn := &fakeConnector{
+		columns: []string{"id", "reservation_id", "restriction_id", "room_id", "start_date", "end_date", "note"},
+		rows: [][]driver.Value{
+			{int64(1), int64(10), int64(1), int64(1), day(1), day(3), ""},
+			{int64(2), int64(0), int64(2), int64(1), day(5), day(5), "family visit"},
+			{int64(3), int64(11), int64(1), int64(3), day(2), day(4), ""},
+		},
+	}
+	db := sql.OpenDB(conn)
+	defer db.Close()
+
+	repo := NewPostgresRepo(db, &config.AppConfig{})
+
+	got, err := repo.GetRestrictionsForAllRoomsByDate(day(1), day(31))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if conn.queries != 1 {
+		t.Fatalf("queries: got %d, want 1", conn.queries)
+	}
+
+	wantIDs := map[int][]int{1: {1, 2}, 3: {3}}
+	if len(got) != len(wantIDs) {
+		t.Fatalf("rooms: got %d, want %d", len(got), len(wantIDs))
+	}
+	for roomID, ids := range wantIDs {
+		if len(got[roomID]) != len(ids) {
+			t.Fatalf("room %d: got %d restrictions, want %d", roomID, len(got[roomID]), len(ids))
+		}
+		for i, id := range ids {
+			r := got[roomID][i]
+			if r.ID != id || r.RoomID != roomID {
+				t.Errorf("room %d restriction %d: got ID %d room %d, want ID %d", roomID, i, r.ID, r.RoomID, id)
+			}
+		}
+	}
+
+	if got[1][1].ReservationID != 0 || got[1][1].RestrictionID != 2 || got[1][1].Note != "family visit" {
+		t.Errorf("owner block not scanned correctly: %+v", got[1][1])
+	}
+}
+
+// TestBlockNote_RoundTrip verifies that a block's reason and note are written
+// by InsertBlockForRoom and read back by GetRestrictionsForRoomByDate, against
+// both the PostgreSQL repository and the testing repository.
+func TestBlockNote_RoundTrip(t *testing.T) {
+	date := time.Date(2050, time.March, 10, 0, 0, 0, 0, time.UTC)
+	const note = "boiler service"
+
+	t.Run("postgres", func(t *testing.T) {
+		conn := &fakeConnector{
+			columns: []string{"id", "reservation_id", "restriction_id", "room_id", "start_date", "end_date", "note"},
+		}
+		db := sql.OpenDB(conn)
+		defer db.Close()
+		repo := NewPostgresRepo(db, &config.AppConfig{})
+
+		if err := repo.InsertBlockForRoom(2, date, 3, note); err != nil {
+			t.Fatal(err)
+		}
+		// start, end, room_id, restriction_id, note, created_at, updated_at
+		if len(conn.execArgs) != 7 {
+			t.Fatalf("exec args: got %d, want 7", len(conn.execArgs))
+		}
+		if conn.execArgs[3] != int64(3) || conn.execArgs[4] != note {
+			t.Fatalf("inserted reason/note: got %v/%v", conn.execArgs[3], conn.execArgs[4])
+		}
+
+		// Feed the written values back as the stored row.
+		conn.rows = [][]driver.Value{{int64(7), int64(0), conn.execArgs[3], conn.execArgs[2], conn.execArgs[0], conn.execArgs[1], conn.execArgs[4]}}
+		got, err := repo.GetRestrictionsForRoomByDate(2, date, date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 1 || got[0].Note != note || got[0].RestrictionID != 3 {
+			t.Fatalf("read back: got %+v", got)
+		}
+	})
+
+	t.Run("testing repo", func(t *testing.T) {
+		ResetBlocks()
+		defer ResetBlocks()
+
+		repo := NewTestingRepo(&config.AppConfig{})
+		if err := repo.InsertBlockForRoom(2, date, 3, note); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := repo.GetRestrictionsForRoomByDate(2, date.AddDate(0, 0, -1), date.AddDate(0, 0, 1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var found bool
+		for _, r := range got {
+			if r.StartDate.Equal(date) {
+				found = true
+				if r.Note != note || r.RestrictionID != 3 || r.RoomID != 2 {
+					t.Errorf("read back: got %+v", r)
+				}
+			}
+		}
+		if !found {
+			t.Fatal("inserted block not returned")
+		}
+	})
+}
+
+// TestGetRateForDate verifies that the rate resolved by the database (override
+// or base) is returned, and that a missing room surfaces sql.ErrNoRows.
+func TestGetRateForDate(t *testing.T) {
+	date := time.Date(2050, time.December, 25, 0, 0, 0, 0, time.UTC)
+
+	conn := &fakeConnector{
+		columns: []string{"nightly_rate"},
+		rows:    [][]driver.Value{{int64(15000)}},
+	}
+	db := sql.OpenDB(conn)
+	defer db.Close()
+	repo := NewPostgresRepo(db, &config.AppConfig{})
+
+	rate, err := repo.GetRateForDate(1, date)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rate != 15000 {
+		t.Fatalf("rate: got %d, want 15000", rate)
+	}
+
+	conn.rows = nil
+	if _, err := repo.GetRateForDate(99, date); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("missing room: got %v, want sql.ErrNoRows", err)
+	}
+}
+
+// TestTimeQuery_LogsSlowQueries verifies that a repository call slower than the
+// configured threshold is logged with its method label, and a fast one is not.
+func TestTimeQuery_LogsSlowQueries(t *testing.T) {
+	tests := []struct {
+		name    string
+		delay   time.Duration
+		wantLog bool
+	}{
+		{name: "slow query logged", delay: 30 * time.Millisecond, wantLog: true},
+		{name: "fast query not logged", delay: 0, wantLog: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			app := &config.AppConfig{
+				InfoLog:            log.New(&buf, "", 0),
+				SlowQueryThreshold: 10 * time.Millisecond,
+			}
+
+			conn := &fakeConnector{
+				columns: []string{"nightly_rate"},
+				rows:    [][]driver.Value{{int64(10000)}},
+				delay:   tc.delay,
+			}
+			db := sql.OpenDB(conn)
+			defer db.Close()
+			repo := NewPostgresRepo(db, app)
+
+			if _, err := repo.GetRateForDate(1, time.Now()); err != nil {
+				t.Fatal(err)
+			}
+
+			logged := strings.Contains(buf.String(), "slow query: GetRateForDate")
+			if logged != tc.wantLog {
+				t.Fatalf("logged: got %v, want %v (log %q)", logged, tc.wantLog, buf.String())
+			}
+		})
+	}
+}
+
+// TestSearchReservations_Sort verifies that every allowed sort key produces
+// its fixed ORDER BY clause and that unknown keys, including SQL fragments,
+// fall back to the default order without reaching the query.
+func TestSearchReservations_Sort(t *testing.T) {
+	conn := &fakeConnector{}
+	db := sql.OpenDB(conn)
+	defer db.Close()
+	repo := NewPostgresRepo(db, &config.AppConfig{})
+
+	tests := []struct {
+		sort string
+		want string
+	}{
+		{sort: "start_date_asc", want: "order by\n\t\t\tr.start_date asc, r.id asc"},
+		{sort: "start_date_desc", want: "order by\n\t\t\tr.start_date desc, r.id desc"},
+		{sort: "created_at_asc", want: "order by\n\t\t\tr.created_at asc, r.id asc"},
+		{sort: "created_at_desc", want: "order by\n\t\t\tr.created_at desc, r.id desc"},
+		{sort: "last_name_asc", want: "order by\n\t\t\tlower(r.last_name) asc, r.id asc"},
+		{sort: "last_name_desc", want: "order by\n\t\t\tlower(r.last_name) desc, r.id desc"},
+		{sort: "", want: "order by\n\t\t\tr.start_date asc, r.id asc"},
+		{sort: "r.id; drop table reservations", want: "order by\n\t\t\tr.start_date asc, r.id asc"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.sort, func(t *testing.T) {
+			if _, err := repo.SearchReservations(repository.ReservationFilter{Sort: tc.sort}); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasSuffix(strings.TrimSpace(conn.lastSQL), strings.TrimSpace(tc.want)) {
+				t.Fatalf("query does not end with %q:\n%s", tc.want, conn.lastSQL)
+			}
+			if strings.Contains(conn.lastSQL, "drop table") {
+				t.Fatal("sort key leaked into query")
+			}
+		})
+	}
+
+	// Every advertised key must have a clause.
+	for _, key := range repository.ReservationSorts {
+		if _, ok := reservationOrderBy[key]; !ok {
+			t.Errorf("no ORDER BY clause for %q", key)
+		}
+	}
+}
+
+// TestSearchReservations_Status verifies that new and processed bind the
+// processed value as a parameter, and that all, empty and unknown statuses
+// add no condition.
+func TestSearchReservations_Status(t *testing.T) {
+	conn := &fakeConnector{}
+	db := sql.OpenDB(conn)
+	defer db.Close()
+	repo := NewPostgresRepo(db, &config.AppConfig{})
+
+	tests := []struct {
+		status   string
+		wantArgs []driver.Value
+	}{
+		{status: repository.ReservationStatusNew, wantArgs: []driver.Value{int64(models.ReservationNew)}},
+		{status: repository.ReservationStatusProcessed, wantArgs: []driver.Value{int64(models.ReservationProcessed)}},
+		{status: repository.ReservationStatusAll},
+		{status: ""},
+		{status: "processed' or 1=1 --"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.status, func(t *testing.T) {
+			if _, err := repo.SearchReservations(repository.ReservationFilter{Status: tc.status}); err != nil {
+				t.Fatal(err)
+			}
+			if len(conn.lastArgs) != len(tc.wantArgs) || (len(tc.wantArgs) > 0 && conn.lastArgs[0] != tc.wantArgs[0]) {
+				t.Errorf("args: got %v, want %v", conn.lastArgs, tc.wantArgs)
+			}
+			if hasWhere := strings.Contains(conn.lastSQL, "where r.processed = $1"); hasWhere != (len(tc.wantArgs) > 0) {
+				t.Errorf("where clause present = %v:\n%s", hasWhere, conn.lastSQL)
+			}
+			if strings.Contains(conn.lastSQL, "1=1") {
+				t.Fatal("status leaked into query")
+			}
+		})
+	}
+}
+
+// TestSearchReservations_RejectsInjection guards reservationSearchSQL against
+// regressions as filter and sort options are added: hostile sort and status
+// values must map to the default clauses, never appear in the query text and
+// never be bound as arguments, and every allowlisted clause must be a fixed
+// fragment with no statement separator or comment.
+func TestSearchReservations_RejectsInjection(t *testing.T) {
+	conn := &fakeConnector{}
+	db := sql.OpenDB(conn)
+	defer db.Close()
+	repo := NewPostgresRepo(db, &config.AppConfig{})
+
+	defaultOrder := reservationOrderBy[repository.DefaultReservationSort]
+	hostile := []string{
+		"start_date;drop table",
+		"start_date;drop table reservations",
+		"start_date_asc; delete from users",
+		"start_date_asc --",
+		"r.id desc, (select password from users limit 1)",
+		"new' or '1'='1",
+		"START_DATE_ASC",
+	}
+
+	for _, input := range hostile {
+		t.Run(input, func(t *testing.T) {
+			filter := repository.ReservationFilter{Status: input, Sort: input}
+			where, orderBy, args := reservationSearchSQL(filter)
+			if where != "" || len(args) != 0 {
+				t.Errorf("status %q: got where %q args %v, want no condition", input, where, args)
+			}
+			if orderBy != defaultOrder {
+				t.Errorf("sort %q: got order %q, want default %q", input, orderBy, defaultOrder)
+			}
+
+			if _, err := repo.SearchReservations(filter); err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(conn.lastSQL, input) || strings.Contains(strings.ToLower(conn.lastSQL), "drop") {
+				t.Fatalf("input reached the query:\n%s", conn.lastSQL)
+			}
+			if !strings.HasSuffix(strings.TrimSpace(conn.lastSQL), defaultOrder) {
+				t.Errorf("query does not use the default order:\n%s", conn.lastSQL)
+			}
+		})
+	}
+
+	var clauses []string
+	for _, c := range reservationOrderBy {
+		clauses = append(clauses, c)
+	}
+	for _, c := range reservationStatusWhere {
+		clauses = append(clauses, c)
+	}
+	for _, c := range clauses {
+		if strings.ContainsAny(c, ";'\"") || strings.Contains(c, "--") || strings.Contains(c, "/*") {
+			t.Errorf("allowlisted clause %q contains a separator, quote or comment", c)
+		}
+	}
+	for status := range reservationStatusWhere {
+		if _, ok := reservationStatusProcessed[status]; !ok {
+			t.Errorf("status %q has a where clause but no bound value", status)
+		}
+	}
+}
+
+// TestCreateUser verifies that a new user is inserted with a bcrypt hash of the
+// password, never the plaintext, and that a unique-violation on email is
+// reported as repository.ErrDuplicateEmail.
+func TestCreateUser(t *testing.T) {
+	conn := &fakeConnector{
+		columns: []string{"id"},
+		rows:    [][]driver.Value{{int64(7)}},
+	}
+	db := sql.OpenDB(conn)
+	defer db.Close()
+	repo := NewPostgresRepo(db, &config.AppConfig{BcryptCost: bcrypt.MinCost})
+
+	u := models.User{FirstName: "New", LastName: "Staff", Email: "new@example.com", AccessLevel: 1}
+
+	id, err := repo.CreateUser(u, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 7 {
+		t.Fatalf("id: got %d, want 7", id)
+	}
+
+	// first_name, last_name, email, password, access_level, created_at, updated_at
+	hash, _ := conn.lastArgs[3].(string)
+	if hash == "correct horse" {
+		t.Fatal("plaintext password was stored")
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte("correct horse")); err != nil {
+		t.Fatalf("stored hash does not verify: %v", err)
+	}
+
+	conn.err = &pgconn.PgError{Code: "23505", ConstraintName: "idx_users_email_unique"}
+	if _, err := repo.CreateUser(u, "correct horse"); !errors.Is(err, repository.ErrDuplicateEmail) {
+		t.Fatalf("duplicate: got %v, want ErrDuplicateEmail", err)
+	}
+}
+
+// TestInsertRoom_UpdateRoom verifies that a unique name is saved and that a
+// unique-violation from the normalized room name index is reported as
+// repository.ErrDuplicateRoomName by both methods.
+func TestInsertRoom_UpdateRoom(t *testing.T) {
+	conn := &fakeConnector{columns: []string{"id"}, rows: [][]driver.Value{{int64(5)}}, affected: 1}
+	db := sql.OpenDB(conn)
+	defer db.Close()
+	repo := NewPostgresRepo(db, &config.AppConfig{})
+
+	room := models.Room{RoomName: "Sunbeam Attic", NightlyRate: 12000, MaxGuests: 2, Active: true}
+
+	id, err := repo.InsertRoom(room)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 5 || conn.lastArgs[0] != "Sunbeam Attic" {
+		t.Errorf("insert: got id %d args %v", id, conn.lastArgs)
+	}
+
+	room.ID = 5
+	room.RoomName = "Sunbeam Garret"
+	if err := repo.UpdateRoom(room); err != nil {
+		t.Fatal(err)
+	}
+	// room_name, nightly_rate, max_guests, lead_days, updated_at, id
+	if len(conn.execArgs) != 6 || conn.execArgs[0] != "Sunbeam Garret" || conn.execArgs[5] != int64(5) {
+		t.Errorf("update args: got %v", conn.execArgs)
+	}
+
+	conn.affected = 0
+	if err := repo.UpdateRoom(room); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("missing room: got %v, want sql.ErrNoRows", err)
+	}
+
+	dup := &pgconn.PgError{Code: "23505", ConstraintName: "rooms_room_name_normalized_idx"}
+	conn.err, conn.execErr = dup, dup
+	if _, err := repo.InsertRoom(room); !errors.Is(err, repository.ErrDuplicateRoomName) {
+		t.Errorf("duplicate insert: got %v, want ErrDuplicateRoomName", err)
+	}
+	if err := repo.UpdateRoom(room); !errors.Is(err, repository.ErrDuplicateRoomName) {
+		t.Errorf("duplicate update: got %v, want ErrDuplicateRoomName", err)
+	}
+}
+
+// TestGetUserByEmail verifies that a missing user is reported as
+// repository.ErrUserNotFound rather than sql.ErrNoRows.
+func TestGetUserByEmail(t *testing.T) {
+	conn := &fakeConnector{
+		columns: []string{"id", "first_name", "last_name", "email", "password", "access_level", "created_at", "updated_at"},
+	}
+	db := sql.OpenDB(conn)
+	defer db.Close()
+	repo := NewPostgresRepo(db, &config.AppConfig{})
+
+	if _, err := repo.GetUserByEmail("nobody@example.com"); !errors.Is(err, repository.ErrUserNotFound) {
+		t.Fatalf("missing user: got %v, want ErrUserNotFound", err)
+	}
+
+	now := time.Now()
+	conn.rows = [][]driver.Value{{int64(3), "Ada", "Admin", "ada@example.com", "hash", int64(3), now, now}}
+	u, err := repo.GetUserByEmail("ADA@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.ID != 3 || u.AccessLevel != 3 {
+		t.Fatalf("user: got %+v", u)
+	}
+}
+
+// TestTestingRepo_CreateUser verifies the testing repository's duplicate
+// detection, which handler tests rely on.
+func TestTestingRepo_CreateUser(t *testing.T) {
+	ResetUsers()
+	defer ResetUsers()
+
+	repo := NewTestingRepo(&config.AppConfig{})
+
+	if _, err := repo.CreateUser(models.User{Email: strings.ToUpper(TestExistingUserEmail)}, "password1"); !errors.Is(err, repository.ErrDuplicateEmail) {
+		t.Fatalf("existing email: got %v, want ErrDuplicateEmail", err)
+	}
+
+	id, err := repo.CreateUser(models.User{Email: "new@example.com"}, "password1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := repo.GetUserByEmail("New@Example.com")
+	if err != nil || u.ID != id {
+		t.Fatalf("lookup: got %+v, %v", u, err)
+	}
+	if _, err := repo.CreateUser(models.User{Email: "new@example.com"}, "password1"); !errors.Is(err, repository.ErrDuplicateEmail) {
+		t.Fatalf("second create: got %v, want ErrDuplicateEmail", err)
+	}
+}
+
+// TestUpdateReservation_Stale verifies the optimistic-concurrency check: the
+// loaded updated_at is part of the update's WHERE clause, and an update that
+// matches no row is reported as repository.ErrStaleUpdate.
+func TestUpdateReservation_Stale(t *testing.T) {
+	conn := &fakeConnector{}
+	db := sql.OpenDB(conn)
+	defer db.Close()
+	repo := NewPostgresRepo(db, &config.AppConfig{})
+
+	loaded := time.Date(2050, time.January, 1, 9, 0, 0, 0, time.UTC)
+	res := models.Reservation{ID: 5, FirstName: "Ann", UpdatedAt: loaded}
+
+	conn.affected = 1
+	if err := repo.UpdateReservation(res); err != nil {
+		t.Fatalf("current version: %v", err)
+	}
+	// first_name, last_name, email, phone, notes, updated_at, id, loaded updated_at
+	if got, ok := conn.execArgs[7].(time.Time); !ok || !got.Equal(loaded) {
+		t.Fatalf("where updated_at: got %v, want %v", conn.execArgs[7], loaded)
+	}
+
+	conn.affected = 0
+	if err := repo.UpdateReservation(res); !errors.Is(err, repository.ErrStaleUpdate) {
+		t.Fatalf("stale version: got %v, want ErrStaleUpdate", err)
+	}
+}
+
+// TestRecordAudit_RecentAudit verifies that RecordAudit writes the acting user,
+// action and detail, and that RecentAudit passes the limit through and scans
+// the joined user email.
+func TestRecordAudit_RecentAudit(t *testing.T) {
+	when := time.Date(2050, time.January, 1, 9, 0, 0, 0, time.UTC)
+	conn := &fakeConnector{
+		columns: []string{"id", "user_id", "email", "action", "detail", "created_at"},
+		rows: [][]driver.Value{
+			{int64(2), int64(1), "admin@example.com", "reservation.delete", "Deleted reservation 7", when},
+		},
+	}
+	db := sql.OpenDB(conn)
+	defer db.Close()
+
+	repo := NewPostgresRepo(db, &config.AppConfig{})
+
+	if err := repo.RecordAudit(1, "reservation.delete", "Deleted reservation 7"); err != nil {
+		t.Fatal(err)
+	}
+	if len(conn.execArgs) < 3 || conn.execArgs[0] != int64(1) || conn.execArgs[1] != "reservation.delete" || conn.execArgs[2] != "Deleted reservation 7" {
+		t.Fatalf("exec args: got %v", conn.execArgs)
+	}
+
+	entries, err := repo.RecentAudit(50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conn.lastArgs) != 1 || conn.lastArgs[0] != int64(50) {
+		t.Errorf("limit arg: got %v, want [50]", conn.lastArgs)
+	}
+	if len(entries) != 1 || entries[0].UserEmail != "admin@example.com" || entries[0].Action != "reservation.delete" {
+		t.Fatalf("entries: got %+v", entries)
+	}
+}
+
+// TestReservationExists verifies that ReservationExists matches on guest,
+// room and exact dates and reports the database's answer.
+func TestReservationExists(t *testing.T) {
+	start := time.Date(2050, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 2)
+
+	for _, want := range []bool{true, false} {
+		conn := &fakeConnector{columns: []string{"exists"}, rows: [][]driver.Value{{want}}}
+		db := sql.OpenDB(conn)
+
+		repo := NewPostgresRepo(db, &config.AppConfig{})
+		got, err := repo.ReservationExists("Jane@Example.com", 3, start, end)
+		db.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("exists: got %v, want %v", got, want)
+		}
+		if !strings.Contains(conn.lastSQL, "lower(email) = lower($1)") {
+			t.Errorf("email match should be case-insensitive: %s", conn.lastSQL)
+		}
+		wantArgs := []driver.Value{"Jane@Example.com", int64(3), start, end}
+		if !reflect.DeepEqual(conn.lastArgs, wantArgs) {
+			t.Errorf("args: got %v, want %v", conn.lastArgs, wantArgs)
+		}
+	}
+}
+
+// TestExpireUnconfirmedReservations verifies that only pending rows
+// created before the cutoff are deleted and the affected count is returned.
+func TestExpireUnconfirmedReservations(t *testing.T) {
+	conn := &fakeConnector{affected: 3}
+	db := sql.OpenDB(conn)
+	defer db.Close()
+	repo := NewPostgresRepo(db, &config.AppConfig{})
+
+	cutoff := time.Date(2050, time.January, 1, 9, 0, 0, 0, time.UTC)
+	n, err := repo.ExpireUnconfirmedReservations(cutoff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Fatalf("expired: got %d, want 3", n)
+	}
+	if !strings.Contains(conn.lastSQL, "status = 'pending'") || !strings.Contains(conn.lastSQL, "created_at < $1") {
+		t.Fatalf("unexpected delete: %s", conn.lastSQL)
+	}
+	if got, ok := conn.execArgs[0].(time.Time); !ok || !got.Equal(cutoff) {
+		t.Fatalf("cutoff: got %v, want %v", conn.execArgs[0], cutoff)
+	}
+}
+
+// TestErrorWrapping verifies that driver errors are wrapped with the failing
+// method's name while errors.Is still matches the underlying sentinel.
+func TestErrorWrapping(t *testing.T) {
+	conn := &fakeConnector{columns: []string{"id", "room_name"}}
+	db := sql.OpenDB(conn)
+	defer db.Close()
+	repo := NewPostgresRepo(db, &config.AppConfig{})
+
+	_, err := repo.GetRoomByID(42)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("errors.Is(err, sql.ErrNoRows) = false for %v", err)
+	}
+	if !strings.Contains(err.Error(), "dbrepo.GetRoomByID") {
+		t.Fatalf("error %q does not name the method", err)
+	}
+
+	conn.err = context.DeadlineExceeded
+	_, err = repo.AllRooms()
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "dbrepo.AllRooms") {
+		t.Fatalf("AllRooms error: got %v", err)
+	}
+}
+
+// TestGetSetting_SetSetting verifies that a missing key is reported as
+// repository.ErrSettingNotFound, a stored value is scanned, and SetSetting
+// upserts the key and value.
+func TestGetSetting_SetSetting(t *testing.T) {
+	conn := &fakeConnector{columns: []string{"value"}}
+	db := sql.OpenDB(conn)
+	defer db.Close()
+	repo := NewPostgresRepo(db, &config.AppConfig{})
+
+	if _, err := repo.GetSetting("check_in_time"); !errors.Is(err, repository.ErrSettingNotFound) {
+		t.Fatalf("missing key: got %v, want ErrSettingNotFound", err)
+	}
+
+	conn.rows = [][]driver.Value{{"4:00 PM"}}
+	value, err := repo.GetSetting("check_in_time")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != "4:00 PM" {
+		t.Errorf("value: got %q, want %q", value, "4:00 PM")
+	}
+	if len(conn.lastArgs) != 1 || conn.lastArgs[0] != "check_in_time" {
+		t.Errorf("key arg: got %v", conn.lastArgs)
+	}
+
+	if err := repo.SetSetting("check_in_time", "5:00 PM"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(conn.lastSQL, "on conflict (key) do update") {
+		t.Errorf("SetSetting should upsert: %s", conn.lastSQL)
+	}
+	if len(conn.execArgs) < 2 || conn.execArgs[0] != "check_in_time" || conn.execArgs[1] != "5:00 PM" {
+		t.Fatalf("exec args: got %v", conn.execArgs)
+	}
+}
+
+// TestTestingRepo_Settings verifies the testing repository's settings store,
+// which the admin settings handler tests rely on.
+func TestTestingRepo_Settings(t *testing.T) {
+	ResetSettings()
+	defer ResetSettings()
+
+	repo := NewTestingRepo(&config.AppConfig{})
+
+	if _, err := repo.GetSetting("min_stay_nights"); !errors.Is(err, repository.ErrSettingNotFound) {
+		t.Fatalf("unset key: got %v, want ErrSettingNotFound", err)
+	}
+	if err := repo.SetSetting("min_stay_nights", "2"); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := repo.GetSetting("min_stay_nights"); err != nil || v != "2" {
+		t.Fatalf("after set: got %q, %v", v, err)
+	}
+
+	ForceSettingsErr = true
+	defer func() { ForceSettingsErr = false }()
+	if err := repo.SetSetting("min_stay_nights", "3"); err == nil {
+		t.Error("expected SetSetting error")
+	}
+}
+
+// TestCreateHold verifies that a hold is inserted as a hold restriction with
+// its expiry when the dates are free, and refused without an insert when
+// anything already covers them.
+func TestCreateHold(t *testing.T) {
+	start := time.Date(2050, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 2)
+	expires := time.Date(2049, time.December, 1, 10, 15, 0, 0, time.UTC)
+
+	// Every query in the fake returns the row: room 0 locked, 0 overlapping
+	// restrictions, new hold ID 0.
+	conn := &fakeConnector{columns: []string{"n"}, rows: [][]driver.Value{{int64(0)}}}
+	db := sql.OpenDB(conn)
+	defer db.Close()
+	repo := NewPostgresRepo(db, &config.AppConfig{})
+
+	if _, err := repo.CreateHold(3, start, end, expires); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(conn.lastSQL, "insert into room_restrictions") {
+		t.Fatalf("last statement: %s", conn.lastSQL)
+	}
+	if len(conn.lastArgs) != 7 || conn.lastArgs[2] != int64(3) ||
+		conn.lastArgs[3] != int64(repository.HoldRestrictionID) || conn.lastArgs[4] != expires {
+		t.Errorf("insert args: got %v", conn.lastArgs)
+	}
+	if conn.commits != 1 {
+		t.Errorf("commits: got %d, want 1", conn.commits)
+	}
+
+	// Two overlapping restrictions: refused before the insert.
+	conn.rows = [][]driver.Value{{int64(2)}}
+	if _, err := repo.CreateHold(3, start, end, expires); !errors.Is(err, repository.ErrDatesTaken) {
+		t.Fatalf("taken dates: got %v, want ErrDatesTaken", err)
+	}
+	if !strings.Contains(conn.lastSQL, "count(id)") || conn.commits != 1 {
+		t.Errorf("taken dates ran %q (commits %d)", conn.lastSQL, conn.commits)
+	}
+}
+
+// TestReleaseHold_ExpireHolds verifies that both only delete hold rows and
+// that ExpireHolds reports how many went.
+func TestReleaseHold_ExpireHolds(t *testing.T) {
+	conn := &fakeConnector{affected: 3}
+	db := sql.OpenDB(conn)
+	defer db.Close()
+	repo := NewPostgresRepo(db, &config.AppConfig{})
+
+	if err := repo.ReleaseHold(12); err != nil {
+		t.Fatal(err)
+	}
+	if want := []driver.Value{int64(12), int64(repository.HoldRestrictionID)}; !reflect.DeepEqual(conn.execArgs, want) {
+		t.Errorf("release args: got %v, want %v", conn.execArgs, want)
+	}
+
+	now := time.Date(2050, time.January, 1, 12, 0, 0, 0, time.UTC)
+	n, err := repo.ExpireHolds(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("expired: got %d, want 3", n)
+	}
+	if want := []driver.Value{int64(repository.HoldRestrictionID), now}; !reflect.DeepEqual(conn.execArgs, want) {
+		t.Errorf("expire args: got %v, want %v", conn.execArgs, want)
+	}
+
+	conn.execErr = errors.New("db down")
+	if _, err := repo.ExpireHolds(now); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
+
+// TestFindAvailableWindows scans March 2050 with a booking on the 10th to the
+// 15th and checks the windows either side of it, trimmed to the month and to
+// the requested length.
+func TestFindAvailableWindows(t *testing.T) {
+	march := time.Date(2050, time.March, 1, 0, 0, 0, 0, time.UTC)
+	day := func(d int) time.Time { return time.Date(2050, time.March, d, 0, 0, 0, 0, time.UTC) }
+	april1 := time.Date(2050, time.April, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		rows   [][]driver.Value
+		nights int
+		want   []models.DateRange
+	}{
+		{name: "free month", nights: 3, want: []models.DateRange{{Start: march, End: april1}}},
+		{name: "one booked span", rows: [][]driver.Value{{day(10), day(15)}}, nights: 3,
+			want: []models.DateRange{{Start: march, End: day(10)}, {Start: day(15), End: april1}}},
+		{name: "gap too short", rows: [][]driver.Value{{day(3), day(15)}, {day(17), day(31)}}, nights: 3,
+			want: nil},
+		{name: "spans outside the month", rows: [][]driver.Value{{day(1).AddDate(0, 0, -4), day(4)}, {day(29), april1.AddDate(0, 0, 3)}}, nights: 7,
+			want: []models.DateRange{{Start: day(4), End: day(29)}}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			conn := &fakeConnector{columns: []string{"start_date", "end_date"}, rows: tc.rows}
+			db := sql.OpenDB(conn)
+			defer db.Close()
+			repo := NewPostgresRepo(db, &config.AppConfig{})
+
+			got, err := repo.FindAvailableWindows(2, day(1).Add(9*time.Hour), tc.nights)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("windows: got %v, want %v", got, tc.want)
+			}
+			for i := range got {
+				if !got[i].Start.Equal(tc.want[i].Start) || !got[i].End.Equal(tc.want[i].End) {
+					t.Errorf("window %d: got %v, want %v", i, got[i], tc.want[i])
+				}
+			}
+			wantArgs := []driver.Value{int64(2), march, april1}
+			if !reflect.DeepEqual(conn.lastArgs, wantArgs) {
+				t.Errorf("args: got %v, want %v", conn.lastArgs, wantArgs)
+			}
+		})
+	}
+}
+
+// TestNextAvailableDate verifies that a room blocked for a week starting on
+// the first night is next free the night the block ends, that a free first
+// night is returned unchanged, and that a room booked through the horizon
+// reports repository.ErrNoAvailability.
+func TestNextAvailableDate(t *testing.T) {
+	from := time.Date(2050, time.January, 1, 0, 0, 0, 0, time.UTC)
+	day := func(d int) time.Time { return from.AddDate(0, 0, d) }
+
+	tests := []struct {
+		name    string
+		rows    [][]driver.Value
+		want    time.Time
+		wantErr error
+	}{
+		{name: "free", want: from},
+		{name: "blocked for a week", rows: [][]driver.Value{{day(0), day(7)}}, want: day(7)},
+		{name: "back-to-back stays", rows: [][]driver.Value{{day(0), day(7)}, {day(7), day(9)}, {day(12), day(14)}}, want: day(9)},
+		{name: "later block only", rows: [][]driver.Value{{day(3), day(10)}}, want: from},
+		{name: "booked through horizon", rows: [][]driver.Value{{day(-2), day(repository.NextAvailableHorizonDays + 5)}}, wantErr: repository.ErrNoAvailability},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			conn := &fakeConnector{columns: []string{"start_date", "end_date"}, rows: tc.rows}
+			db := sql.OpenDB(conn)
+			defer db.Close()
+			repo := NewPostgresRepo(db, &config.AppConfig{})
+
+			got, err := repo.NextAvailableDate(4, from.Add(15*time.Hour))
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("error: got %v, want %v", err, tc.wantErr)
+			}
+			if !got.Equal(tc.want) {
+				t.Errorf("next available: got %v, want %v", got, tc.want)
+			}
+			wantArgs := []driver.Value{int64(4), from, day(repository.NextAvailableHorizonDays)}
+			if !reflect.DeepEqual(conn.lastArgs, wantArgs) {
+				t.Errorf("args: got %v, want %v", conn.lastArgs, wantArgs)
+			}
+		})
+	}
+}
+
+// TestSetRoomActive verifies that SetRoomActive writes the flag for the room
+// and reports an unknown room as sql.ErrNoRows, and that the availability
+// queries exclude closed rooms.
+func TestSetRoomActive(t *testing.T) {
+	conn := &fakeConnector{affected: 1}
+	db := sql.OpenDB(conn)
+	defer db.Close()
+	repo := NewPostgresRepo(db, &config.AppConfig{})
+
+	if err := repo.SetRoomActive(2, false); err != nil {
+		t.Fatal(err)
+	}
+	// active, updated_at, id
+	if len(conn.execArgs) != 3 || conn.execArgs[0] != false || conn.execArgs[2] != int64(2) {
+		t.Fatalf("exec args: got %v", conn.execArgs)
+	}
+
+	conn.affected = 0
+	if err := repo.SetRoomActive(42, true); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("unknown room: got %v, want sql.ErrNoRows", err)
+	}
+
+	conn.columns = []string{"id", "room_name"}
+	if _, err := repo.SearchAvailabilityForAllRooms(time.Now(), time.Now().AddDate(0, 0, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(conn.lastSQL, "r.active") {
+		t.Errorf("SearchAvailabilityForAllRooms should skip closed rooms: %s", conn.lastSQL)
+	}
+
+	conn.columns, conn.rows = []string{"available"}, [][]driver.Value{{false}}
+	ok, err := repo.SearchAvailabilityByDatesByRoomID(time.Now(), time.Now().AddDate(0, 0, 1), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok || !strings.Contains(conn.lastSQL, "select active from rooms") {
+		t.Errorf("SearchAvailabilityByDatesByRoomID should check the room is open: %v, %s", ok, conn.lastSQL)
+	}
+}
+
+// TestSearchAvailability_ExpiredHolds verifies that every availability query
+// skips hold rows past hold_expires_at, as CreateHold does, so an abandoned
+// hold stops blocking a room as soon as it lapses rather than at the next
+// ExpireHolds run.
+func TestSearchAvailability_ExpiredHolds(t *testing.T) {
+	const unexpired = "hold_expires_at > now()"
+	start, end := time.Now(), time.Now().AddDate(0, 0, 2)
+
+	conn := &fakeConnector{}
+	db := sql.OpenDB(conn)
+	defer db.Close()
+	repo := NewPostgresRepo(db, &config.AppConfig{})
+
+	conn.columns, conn.rows = []string{"available"}, [][]driver.Value{{true}}
+	if ok, err := repo.SearchAvailabilityByDatesByRoomID(start, end, 1); err != nil || !ok {
+		t.Fatalf("SearchAvailabilityByDatesByRoomID: got %v, %v", ok, err)
+	}
+	if !strings.Contains(conn.lastSQL, unexpired) {
+		t.Errorf("SearchAvailabilityByDatesByRoomID counts expired holds: %s", conn.lastSQL)
+	}
+
+	conn.columns, conn.rows = []string{"id", "room_name"}, [][]driver.Value{{int64(1), "Golden Haybeam Loft"}}
+	rooms, err := repo.SearchAvailabilityForAllRooms(start, end)
+	if err != nil || len(rooms) != 1 {
+		t.Fatalf("SearchAvailabilityForAllRooms: got %v, %v", rooms, err)
+	}
+	if !strings.Contains(conn.lastSQL, "rr."+unexpired) {
+		t.Errorf("SearchAvailabilityForAllRooms counts expired holds: %s", conn.lastSQL)
+	}
+
+	conn.columns, conn.rows = []string{"start_date", "end_date"}, nil
+	if _, err := repo.NextAvailableDate(1, start); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(conn.lastSQL, unexpired) {
+		t.Errorf("NextAvailableDate counts expired holds: %s", conn.lastSQL)
+	}
+	if _, err := repo.FindAvailableWindows(1, start, 2); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(conn.lastSQL, unexpired) {
+		t.Errorf("FindAvailableWindows counts expired holds: %s", conn.lastSQL)
+	}
+}
+
+// TestRoomImages verifies that ImagesForRoom queries in display order and
+// scans every row, that InsertRoomImage passes the image fields and returns
+// the new ID, and that deleting a missing image reports sql.ErrNoRows.
+func TestRoomImages(t *testing.T) {
+	now := time.Now()
+	conn := &fakeConnector{
+		columns: []string{"id", "room_id", "url", "caption", "sort_order", "created_at", "updated_at"},
+		rows: [][]driver.Value{
+			{int64(7), int64(1), "/static/images/a.jpg", "Sunbeam", int64(0), now, now},
+			{int64(3), int64(1), "/static/images/b.jpg", "", int64(2), now, now},
+		},
+	}
+	db := sql.OpenDB(conn)
+	defer db.Close()
+	repo := NewPostgresRepo(db, &config.AppConfig{})
+
+	images, err := repo.ImagesForRoom(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(images) != 2 || images[0].ID != 7 || images[0].Caption != "Sunbeam" || images[1].SortOrder != 2 {
+		t.Fatalf("images: got %+v", images)
+	}
+	if !strings.Contains(conn.lastSQL, "order by") || !strings.Contains(conn.lastSQL, "sort_order, id") {
+		t.Errorf("ImagesForRoom should order by sort_order, id: %s", conn.lastSQL)
+	}
+
+	conn.columns, conn.rows = []string{"id"}, [][]driver.Value{{int64(12)}}
+	id, err := repo.InsertRoomImage(models.RoomImage{RoomID: 2, URL: "/static/images/c.jpg", Caption: "Nook", SortOrder: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 12 {
+		t.Errorf("id: got %d, want 12", id)
+	}
+	// room_id, url, caption, sort_order, created_at, updated_at
+	if len(conn.lastArgs) != 6 || conn.lastArgs[0] != int64(2) || conn.lastArgs[1] != "/static/images/c.jpg" ||
+		conn.lastArgs[2] != "Nook" || conn.lastArgs[3] != int64(5) {
+		t.Errorf("insert args: got %v", conn.lastArgs)
+	}
+
+	conn.affected = 0
+	if err := repo.DeleteRoomImage(2, 12); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("missing image: got %v, want sql.ErrNoRows", err)
+	}
+	conn.affected = 1
+	if err := repo.DeleteRoomImage(2, 12); err != nil {
+		t.Fatal(err)
+	}
+	if conn.execArgs[0] != int64(12) || conn.execArgs[1] != int64(2) {
+		t.Errorf("delete args: got %v", conn.execArgs)
+	}
+}
+
+// TestArrivalsBetween verifies that ArrivalsBetween filters on start_date
+// within the half-open range, sorts by name and scans the room name.
+func TestArrivalsBetween(t *testing.T) {
+	day := time.Date(2050, time.March, 10, 0, 0, 0, 0, time.UTC)
+	conn := &fakeConnector{
+		columns: []string{"id", "first_name", "last_name", "email", "phone", "start_date", "end_date",
+			"room_id", "created_at", "updated_at", "processed", "id", "room_name"},
+		rows: [][]driver.Value{
+			{int64(5), "Bella", "Abbott", "b@example.com", "555", day, day.AddDate(0, 0, 2), int64(2), day, day, int64(0), int64(2), "Window Perch Theater"},
+		},
+	}
+	db := sql.OpenDB(conn)
+	defer db.Close()
+	repo := NewPostgresRepo(db, &config.AppConfig{})
+
+	got, err := repo.ArrivalsBetween(day, day.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].LastName != "Abbott" || got[0].Room.RoomName != "Window Perch Theater" {
+		t.Fatalf("arrivals: got %+v", got)
+	}
+	if !reflect.DeepEqual(conn.lastArgs, []driver.Value{day, day.AddDate(0, 0, 1)}) {
+		t.Errorf("args: got %v", conn.lastArgs)
+	}
+	for _, want := range []string{"r.start_date >= $1 and r.start_date < $2", "order by", "last_name"} {
+		if !strings.Contains(conn.lastSQL, want) {
+			t.Errorf("query missing %q: %s", want, conn.lastSQL)
+		}
+	}
+}
+
+// TestCreateReservation verifies that the room is locked and checked for
+// overlapping restrictions before the restriction insert, all in one
+// committed transaction; that taken dates are refused with ErrDatesTaken and
+// a key claimed by another request with ErrIdempotencyKeyUsed and that
+// request's reservation ID, both rolling back; and that a failed restriction
+// insert rolls everything back.
+func TestCreateReservation(t *testing.T) {
+	conn := &fakeConnector{columns: []string{"n"}, affected: 1}
+	db := sql.OpenDB(conn)
+	defer db.Close()
+	repo := NewPostgresRepo(db, &config.AppConfig{})
+
+	res := models.Reservation{
+		FirstName: "Ann",
+		RoomID:    2,
+		StartDate: time.Date(2050, time.January, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2050, time.January, 3, 0, 0, 0, 0, time.UTC),
+	}
+	// The reservation insert's returned ID, then the room lock and overlap count.
+	free := func() [][][]driver.Value {
+		return [][][]driver.Value{{{int64(42)}}, {{int64(2)}}, {{int64(0)}}}
+	}
+
+	conn.results = free()
+	id, err := repo.CreateReservation(res, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 42 {
+		t.Errorf("id: got %d, want 42", id)
+	}
+	if conn.queries != 3 {
+		t.Errorf("queries: got %d, want insert, lock and check", conn.queries)
+	}
+	// start_date, end_date, room_id, reservation_id, created_at, updated_at, restriction_id
+	if len(conn.execArgs) != 7 || conn.execArgs[2] != int64(2) || conn.execArgs[3] != int64(42) || conn.execArgs[6] != int64(1) {
+		t.Errorf("restriction args: got %v", conn.execArgs)
+	}
+	if conn.commits != 1 || conn.rollback != 0 {
+		t.Errorf("commits/rollbacks: got %d/%d, want 1/0", conn.commits, conn.rollback)
+	}
+
+	// Another booking already covers a night: the restriction is never inserted.
+	conn.results = [][][]driver.Value{{{int64(42)}}, {{int64(2)}}, {{int64(1)}}}
+	conn.execArgs = nil
+	if _, err := repo.CreateReservation(res, ""); !errors.Is(err, repository.ErrDatesTaken) {
+		t.Fatalf("taken dates: got %v, want ErrDatesTaken", err)
+	}
+	if !strings.Contains(conn.lastSQL, "count(id)") || len(conn.execArgs) != 0 {
+		t.Errorf("taken dates ran %q", conn.lastSQL)
+	}
+	if conn.commits != 1 || conn.rollback != 1 {
+		t.Errorf("commits/rollbacks: got %d/%d, want 1/1", conn.commits, conn.rollback)
+	}
+
+	// A new key is claimed before the room is locked.
+	conn.results = free()
+	if id, err := repo.CreateReservation(res, "key-1"); err != nil || id != 42 {
+		t.Fatalf("new key: got %d, %v", id, err)
+	}
+	if conn.commits != 2 {
+		t.Errorf("commits: got %d, want 2", conn.commits)
+	}
+
+	// The key is already claimed: the first request's reservation is
+	// returned and this one is rolled back.
+	conn.affected = 0
+	conn.queries = 0
+	conn.results = [][][]driver.Value{{{int64(43)}}, {{int64(42)}}}
+	id, err = repo.CreateReservation(res, "key-1")
+	if !errors.Is(err, repository.ErrIdempotencyKeyUsed) || id != 42 {
+		t.Fatalf("used key: got %d, %v; want 42, ErrIdempotencyKeyUsed", id, err)
+	}
+	if !strings.Contains(conn.lastSQL, "from idempotency_keys where key = $1") || conn.queries != 2 {
+		t.Errorf("used key should look up the first reservation without locking the room: %d queries, last %s", conn.queries, conn.lastSQL)
+	}
+	if conn.commits != 2 || conn.rollback != 2 {
+		t.Errorf("commits/rollbacks: got %d/%d, want 2/2", conn.commits, conn.rollback)
+	}
+	conn.affected = 1
+
+	conn.results = free()
+	conn.execErr = errors.New("restriction insert failed")
+	if _, err := repo.CreateReservation(res, ""); err == nil {
+		t.Fatal("expected error when the restriction insert fails")
+	}
+	if conn.commits != 2 || conn.rollback != 3 {
+		t.Errorf("commits/rollbacks: got %d/%d, want 2/3", conn.commits, conn.rollback)
+	}
+}
+
+// TestLogSentMail_RecentSentMail verifies that LogSentMail writes the
+// envelope, subject, template and outcome, and that RecentSentMail passes the
+// limit through and scans each entry.
+func TestLogSentMail_RecentSentMail(t *testing.T) {
+	when := time.Date(2050, time.January, 1, 9, 0, 0, 0, time.UTC)
+	conn := &fakeConnector{
+		columns: []string{"id", "recipient", "sender", "subject", "template", "status", "created_at"},
+		rows: [][]driver.Value{
+			{int64(3), "ann@example.com", "milo@milos-residence.com", "Reservation Confirmation", "basic.html", models.MailStatusFailed, when},
+		},
+	}
+	db := sql.OpenDB(conn)
+	defer db.Close()
+	repo := NewPostgresRepo(db, &config.AppConfig{})
+
+	msg := models.MailData{
+		To:       "ann@example.com",
+		From:     "milo@milos-residence.com",
+		Subject:  "Reservation Confirmation",
+		Content:  "<p>secret body</p>",
+		Template: "basic.html",
+	}
+	if err := repo.LogSentMail(msg, models.MailStatusSent); err != nil {
+		t.Fatal(err)
+	}
+	// recipient, sender, subject, template, status, created_at
+	want := []driver.Value{msg.To, msg.From, msg.Subject, msg.Template, models.MailStatusSent}
+	if len(conn.execArgs) != 6 || !reflect.DeepEqual(conn.execArgs[:5], want) {
+		t.Fatalf("exec args: got %v, want %v followed by a timestamp", conn.execArgs, want)
+	}
+
+	entries, err := repo.RecentSentMail(25)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conn.lastArgs) != 1 || conn.lastArgs[0] != int64(25) {
+		t.Errorf("limit arg: got %v, want [25]", conn.lastArgs)
+	}
+	if len(entries) != 1 || entries[0].To != "ann@example.com" || entries[0].Status != models.MailStatusFailed || !entries[0].CreatedAt.Equal(when) {
+		t.Fatalf("entries: got %+v", entries)
+	}
+
+	conn.execErr = errors.New("insert failed")
+	if err := repo.LogSentMail(msg, models.MailStatusSent); err == nil {
+		t.Error("expected LogSentMail error")
+	}
+}
+
+// TestDeadLetterMail_FailedMail_RequeueMail verifies that DeadLetterMail
+// stores the whole message with the failed status and error but never a
+// sensitive one, that FailedMail
+// filters on the failed status and scans each message, and that RequeueMail
+// returns the message and wraps sql.ErrNoRows for unknown IDs.
+func TestDeadLetterMail_FailedMail_RequeueMail(t *testing.T) {
+	when := time.Date(2050, time.January, 1, 9, 0, 0, 0, time.UTC)
+	msg := models.MailData{
+		To:       "ann@example.com",
+		From:     "milo@milos-residence.com",
+		Subject:  "Reservation Confirmation",
+		Content:  "<p>Hi</p>",
+		Template: "basic.html",
+	}
+
+	conn := &fakeConnector{
+		columns: []string{"id", "recipient", "sender", "subject", "template", "content", "status", "last_error", "created_at"},
+		rows: [][]driver.Value{
+			{int64(7), msg.To, msg.From, msg.Subject, msg.Template, msg.Content, models.MailStatusFailed, "connection refused", when},
+		},
+	}
+	db := sql.OpenDB(conn)
+	defer db.Close()
+	repo := NewPostgresRepo(db, &config.AppConfig{})
+
+	if err := repo.DeadLetterMail(msg, "connection refused"); err != nil {
+		t.Fatal(err)
+	}
+	// recipient, sender, subject, template, content, status, last_error, created_at, updated_at
+	want := []driver.Value{msg.To, msg.From, msg.Subject, msg.Template, msg.Content, models.MailStatusFailed, "connection refused"}
+	if len(conn.execArgs) != 9 || !reflect.DeepEqual(conn.execArgs[:7], want) {
+		t.Fatalf("exec args: got %v, want %v followed by two timestamps", conn.execArgs, want)
+	}
+
+	conn.lastSQL = ""
+	secret := msg
+	secret.Content = `<a href="https://milosresidence.com/user/reset-password?token=secret">reset</a>`
+	secret.Sensitive = true
+	if err := repo.DeadLetterMail(secret, "connection refused"); err != nil {
+		t.Fatal(err)
+	}
+	if conn.lastSQL != "" {
+		t.Errorf("sensitive message was stored: %s", conn.lastSQL)
+	}
+
+	msgs, err := repo.FailedMail()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conn.lastArgs) != 1 || conn.lastArgs[0] != models.MailStatusFailed {
+		t.Errorf("status arg: got %v, want [%s]", conn.lastArgs, models.MailStatusFailed)
+	}
+	if len(msgs) != 1 || msgs[0].ID != 7 || msgs[0].Error != "connection refused" || msgs[0].Mail() != msg || !msgs[0].CreatedAt.Equal(when) {
+		t.Fatalf("messages: got %+v", msgs)
+	}
+
+	conn.columns = []string{"recipient", "sender", "subject", "template", "content"}
+	conn.rows = [][]driver.Value{{msg.To, msg.From, msg.Subject, msg.Template, msg.Content}}
+	got, err := repo.RequeueMail(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != msg {
+		t.Errorf("requeued: got %+v, want %+v", got, msg)
+	}
+	if !strings.HasPrefix(strings.TrimSpace(conn.lastSQL), "delete from outbound_mail") {
+		t.Errorf("RequeueMail SQL: got %q, want a delete ... returning", conn.lastSQL)
+	}
+
+	conn.rows = nil
+	if _, err := repo.RequeueMail(8); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("unknown ID: got %v, want sql.ErrNoRows", err)
+	}
+}
+
+// TestUpdateProcessedForReservation_Range verifies that only 0 and 1 are
+// accepted, and that processed is then written from the resulting status
+// rather than from the argument: 2 and -1 are rejected with
+// repository.ErrInvalidProcessed before any statement runs.
+func TestUpdateProcessedForReservation_Range(t *testing.T) {
+	conn := &fakeConnector{affected: 1}
+	db := sql.OpenDB(conn)
+	defer db.Close()
+	repo := NewPostgresRepo(db, &config.AppConfig{})
+
+	for _, processed := range []int{models.ReservationNew, models.ReservationProcessed} {
+		if err := repo.UpdateProcessedForReservation(7, processed); err != nil {
+			t.Errorf("processed %d: unexpected error %v", processed, err)
+		}
+		if len(conn.execArgs) != 2 || conn.execArgs[0] != int64(processed) {
+			t.Errorf("processed %d: exec args %v", processed, conn.execArgs)
+		}
+		if !strings.Contains(conn.lastSQL, "processed = case when target.status = 'pending' then 0 else 1 end") {
+			t.Errorf("processed %d: not derived from status:\n%s", processed, conn.lastSQL)
+		}
+	}
+
+	for _, processed := range []int{2, -1} {
+		conn.lastSQL = ""
+		err := repo.UpdateProcessedForReservation(7, processed)
+		if !errors.Is(err, repository.ErrInvalidProcessed) {
+			t.Errorf("processed %d: got %v, want ErrInvalidProcessed", processed, err)
+		}
+		if conn.lastSQL != "" {
+			t.Errorf("processed %d: statement ran: %s", processed, conn.lastSQL)
+		}
+	}
+
+	testRepo := NewTestingRepo(&config.AppConfig{})
+	if err := testRepo.UpdateProcessedForReservation(7, 2); !errors.Is(err, repository.ErrInvalidProcessed) {
+		t.Errorf("test repo: got %v, want ErrInvalidProcessed", err)
+	}
+}
+
+// TestCreateResetToken_ConsumeResetToken verifies that CreateResetToken
+// stores only the SHA-256 digest of a random token with the configured
+// expiry, and that ConsumeResetToken looks the token up by that digest and
+// reports a missing row as repository.ErrInvalidResetToken.
+func TestCreateResetToken_ConsumeResetToken(t *testing.T) {
+	conn := &fakeConnector{columns: []string{"user_id"}, rows: [][]driver.Value{{int64(7)}}}
+	db := sql.OpenDB(conn)
+	defer db.Close()
+	repo := NewPostgresRepo(db, &config.AppConfig{PasswordResetTTL: 30 * time.Minute})
+
+	before := time.Now()
+	token, err := repo.CreateResetToken(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(token) < 40 {
+		t.Errorf("token %q is too short", token)
+	}
+	other, _ := repo.CreateResetToken(7)
+	if other == token {
+		t.Error("tokens repeat")
+	}
+	if conn.commits != 2 {
+		t.Errorf("commits: got %d, want 2", conn.commits)
+	}
+
+	// token_hash, user_id, expires_at, created_at of the second token.
+	if len(conn.execArgs) != 4 || conn.execArgs[0] != hashResetToken(other) || conn.execArgs[1] != int64(7) {
+		t.Fatalf("insert args: got %v", conn.execArgs)
+	}
+	if conn.execArgs[0] == other {
+		t.Error("token stored in plain text")
+	}
+	expires := conn.execArgs[2].(time.Time)
+	if d := expires.Sub(before); d < 30*time.Minute || d > 31*time.Minute {
+		t.Errorf("expires after %s, want 30m", d)
+	}
+
+	userID, err := repo.ConsumeResetToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userID != 7 {
+		t.Errorf("user ID: got %d, want 7", userID)
+	}
+	if len(conn.lastArgs) != 2 || conn.lastArgs[1] != hashResetToken(token) {
+		t.Errorf("consume args: got %v", conn.lastArgs)
+	}
+	if !strings.Contains(conn.lastSQL, "used_at is null") || !strings.Contains(conn.lastSQL, "expires_at > $1") {
+		t.Errorf("consume query does not check use and expiry: %s", conn.lastSQL)
+	}
+
+	conn.rows = nil
+	if _, err := repo.ConsumeResetToken(token); !errors.Is(err, repository.ErrInvalidResetToken) {
+		t.Errorf("used token: got %v, want ErrInvalidResetToken", err)
+	}
+}
+
+// TestInsertReservation_UTC verifies that the terms acceptance time and the
+// created_at/updated_at stamps are passed to the insert in UTC, even when the
+// caller's time carries another zone.
+func TestInsertReservation_UTC(t *testing.T) {
+	conn := &fakeConnector{columns: []string{"id"}, rows: [][]driver.Value{{int64(1)}}}
+	db := sql.OpenDB(conn)
+	defer db.Close()
+	repo := NewPostgresRepo(db, &config.AppConfig{})
+
+	accepted := time.Date(2050, time.January, 1, 9, 0, 0, 0, time.FixedZone("UTC-6", -6*3600))
+	_, err := repo.InsertReservation(models.Reservation{
+		FirstName:       "Ann",
+		RoomID:          1,
+		StartDate:       time.Date(2050, time.January, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:         time.Date(2050, time.January, 3, 0, 0, 0, 0, time.UTC),
+		TermsAcceptedAt: accepted,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// terms_accepted_at, created_at, updated_at are the last three arguments.
+	if len(conn.lastArgs) != 11 {
+		t.Fatalf("args: got %d, want 11", len(conn.lastArgs))
+	}
+	for i, name := range map[int]string{8: "terms_accepted_at", 9: "created_at", 10: "updated_at"} {
+		ts, ok := conn.lastArgs[i].(time.Time)
+		if !ok {
+			t.Fatalf("%s: got %T, want time.Time", name, conn.lastArgs[i])
+		}
+		if ts.Location() != time.UTC {
+			t.Errorf("%s: got zone %s, want UTC", name, ts.Location())
+		}
+	}
+	if got := conn.lastArgs[8].(time.Time); !got.Equal(accepted) {
+		t.Errorf("terms_accepted_at: got %s, want the same instant as %s", got, accepted)
+	}
+}
+
+// TestGetRoomByID_LeadDays verifies that a NULL lead_days leaves the room
+// without an override and a stored value is returned as one.
+func TestGetRoomByID_LeadDays(t *testing.T) {
+	columns := []string{"id", "room_name", "nightly_rate", "max_guests", "active", "lead_days", "created_at", "updated_at"}
+	now := time.Now().UTC()
+
+	tests := []struct {
+		name string
+		lead driver.Value
+		want *int
+	}{
+		{name: "null", lead: nil},
+		{name: "override", lead: int64(2), want: func() *int { n := 2; return &n }()},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			conn := &fakeConnector{
+				columns: columns,
+				rows:    [][]driver.Value{{int64(1), "Loft", int64(12000), int64(2), true, tc.lead, now, now}},
+			}
+			db := sql.OpenDB(conn)
+			defer db.Close()
+			repo := NewPostgresRepo(db, &config.AppConfig{})
+
+			room, err := repo.GetRoomByID(1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			switch {
+			case tc.want == nil && room.LeadDays != nil:
+				t.Errorf("LeadDays: got %d, want nil", *room.LeadDays)
+			case tc.want != nil && (room.LeadDays == nil || *room.LeadDays != *tc.want):
+				t.Errorf("LeadDays: got %v, want %d", room.LeadDays, *tc.want)
+			}
+		})
+	}
+}
+
+// TestGetReservationRestriction verifies that the restriction for a known
+// reservation is looked up by reservation ID and scanned, and that a
+// reservation without one wraps sql.ErrNoRows.
+func TestGetReservationRestriction(t *testing.T) {
+	start := time.Date(2050, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2050, time.January, 3, 0, 0, 0, 0, time.UTC)
+	conn := &fakeConnector{
+		columns: []string{"id", "reservation_id", "restriction_id", "room_id", "start_date", "end_date", "note", "updated_at"},
+		rows:    [][]driver.Value{{int64(31), int64(9), int64(1), int64(2), start, end, "", start}},
+	}
+	db := sql.OpenDB(conn)
+	defer db.Close()
+	repo := NewPostgresRepo(db, &config.AppConfig{})
+
+	rr, err := repo.GetReservationRestriction(9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conn.lastArgs) != 1 || conn.lastArgs[0] != int64(9) {
+		t.Errorf("args: got %v, want [9]", conn.lastArgs)
+	}
+	if rr.ID != 31 || rr.ReservationID != 9 || rr.RoomID != 2 || !rr.StartDate.Equal(start) || !rr.EndDate.Equal(end) {
+		t.Errorf("restriction: got %+v", rr)
+	}
+
+	conn.rows = nil
+	if _, err := repo.GetReservationRestriction(10); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("no restriction: got %v, want sql.ErrNoRows", err)
+	}
+}
+
+// TestUpdateReservationDates verifies that both rows are updated in one
+// committed transaction along with the reservation's total, and that a
+// missing row rolls it back with sql.ErrNoRows.
+func TestUpdateReservationDates(t *testing.T) {
+	start := time.Date(2050, time.February, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2050, time.February, 4, 0, 0, 0, 0, time.UTC)
+
+	conn := &fakeConnector{affected: 1}
+	db := sql.OpenDB(conn)
+	defer db.Close()
+	repo := NewPostgresRepo(db, &config.AppConfig{})
+
+	if err := repo.UpdateReservationDates(9, 31, start, end, 30000); err != nil {
+		t.Fatal(err)
+	}
+	// The restriction update is last: start, end, updated_at, id, reservation_id.
+	if len(conn.execArgs) != 5 || conn.execArgs[3] != int64(31) || conn.execArgs[4] != int64(9) {
+		t.Errorf("restriction update args: got %v", conn.execArgs)
+	}
+	if got, ok := conn.execArgs[0].(time.Time); !ok || !got.Equal(start) {
+		t.Errorf("start: got %v, want %v", conn.execArgs[0], start)
+	}
+	if conn.commits != 1 {
+		t.Errorf("commits: got %d, want 1", conn.commits)
+	}
+
+	conn.affected = 0
+	if err := repo.UpdateReservationDates(9, 31, start, end, 30000); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("missing row: got %v, want sql.ErrNoRows", err)
+	}
+	// The reservation update stops it: start, end, total, updated_at, id.
+	if len(conn.execArgs) != 5 || conn.execArgs[2] != int64(30000) || conn.execArgs[4] != int64(9) {
+		t.Errorf("reservation update args: got %v", conn.execArgs)
+	}
+	if conn.commits != 1 || conn.rollback != 1 {
+		t.Errorf("commits/rollbacks: got %d/%d, want 1/1", conn.commits, conn.rollback)
+	}
+}
+
+// TestSetReservationStatus verifies that an allowed change writes the status
+// with its derived processed flag in a committed transaction, and that a
+// disallowed change, an unknown status and an unknown reservation write
+// nothing.
+func TestDeleteReservationsBatch(t *testing.T) {
+	conn := &fakeConnector{affected: 1}
+	db := sql.OpenDB(conn)
+	defer db.Close()
+	repo := NewPostgresRepo(db, &config.AppConfig{})
+
+	if err := repo.DeleteReservationsBatch([]int{3, 4}); err != nil {
+		t.Fatal(err)
+	}
+	if conn.commits != 1 {
+		t.Errorf("commits: got %d, want 1", conn.commits)
+	}
+	if !strings.Contains(conn.lastSQL, "delete from reservations") || len(conn.execArgs) != 1 || conn.execArgs[0] != int64(4) {
+		t.Errorf("last exec: %s %v", conn.lastSQL, conn.execArgs)
+	}
+
+	// A missing reservation rolls the batch back.
+	conn.affected = 0
+	err := repo.DeleteReservationsBatch([]int{5})
+	if !errors.Is(err, repository.ErrReservationNotFound) {
+		t.Fatalf("missing id: got %v, want ErrReservationNotFound", err)
+	}
+	if conn.commits != 1 || conn.rollback != 1 {
+		t.Errorf("missing id: commits %d rollbacks %d, want 1 and 1", conn.commits, conn.rollback)
+	}
+
+	conn.lastSQL = ""
+	if err := repo.DeleteReservationsBatch(nil); err != nil || conn.lastSQL != "" {
+		t.Errorf("empty batch: got %v, ran %q", err, conn.lastSQL)
+	}
+}
+
+// TestSetReservationStatus verifies that SetReservationStatus writes the new
+// status with its derived processed flag, that cancelling also deletes the
+// reservation's room restriction inside the transaction, and that a
+// disallowed change, an unknown status or an unknown reservation writes
+// nothing.
+func TestSetReservationStatus(t *testing.T) {
+	conn := &fakeConnector{columns: []string{"status"}, rows: [][]driver.Value{{models.StatusPending}}, affected: 1}
+	db := sql.OpenDB(conn)
+	defer db.Close()
+	repo := NewPostgresRepo(db, &config.AppConfig{})
+
+	if err := repo.SetReservationStatus(7, models.StatusConfirmed); err != nil {
+		t.Fatal(err)
+	}
+	// status, processed, updated_at, id
+	if len(conn.execArgs) != 4 || conn.execArgs[0] != models.StatusConfirmed ||
+		conn.execArgs[1] != int64(models.ReservationProcessed) || conn.execArgs[3] != int64(7) {
+		t.Errorf("exec args: got %v", conn.execArgs)
+	}
+	if conn.commits != 1 {
+		t.Errorf("commits: got %d, want 1", conn.commits)
+	}
+
+	conn.rows = [][]driver.Value{{models.StatusConfirmed}}
+	if err := repo.SetReservationStatus(7, models.StatusCancelled); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(conn.lastSQL, "delete from room_restrictions where reservation_id = $1") ||
+		len(conn.execArgs) != 1 || conn.execArgs[0] != int64(7) {
+		t.Errorf("cancel did not free the dates: %s %v", conn.lastSQL, conn.execArgs)
+	}
+	if conn.commits != 2 {
+		t.Errorf("commits: got %d, want 2", conn.commits)
+	}
+
+	conn.rows = [][]driver.Value{{models.StatusCancelled}}
+	conn.execArgs = nil
+	err := repo.SetReservationStatus(7, models.StatusCheckedIn)
+	if !errors.Is(err, repository.ErrStatusTransition) {
+		t.Fatalf("cancelled to checked_in: got %v, want ErrStatusTransition", err)
+	}
+	if !strings.Contains(err.Error(), "cancelled to checked_in") {
+		t.Errorf("error %q does not name the statuses", err)
+	}
+	if conn.execArgs != nil || conn.commits != 2 {
+		t.Errorf("disallowed change wrote %v (commits %d)", conn.execArgs, conn.commits)
+	}
+
+	conn.lastSQL = ""
+	if err := repo.SetReservationStatus(7, "archived"); !errors.Is(err, repository.ErrInvalidStatus) {
+		t.Errorf("unknown status: got %v, want ErrInvalidStatus", err)
+	}
+	if conn.lastSQL != "" {
+		t.Errorf("unknown status ran a statement: %s", conn.lastSQL)
+	}
+
+	conn.rows = nil
+	if err := repo.SetReservationStatus(8, models.StatusConfirmed); !errors.Is(err, repository.ErrReservationNotFound) {
+		t.Errorf("unknown reservation: got %v, want ErrReservationNotFound", err)
+	}
+}
+
+// TestBookingsPerDay verifies that BookingsPerDay passes the half-open range
+// as UTC timestamps and scans the grouped rows into daily counts in order.
+func TestBookingsPerDay(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2050, time.March, d, 0, 0, 0, 0, time.UTC) }
+	conn := &fakeConnector{
+		columns: []string{"day", "count"},
+		rows: [][]driver.Value{
+			{day(2), int64(3)},
+			{day(5), int64(1)},
+		},
+	}
+	db := sql.OpenDB(conn)
+	defer db.Close()
+	repo := NewPostgresRepo(db, &config.AppConfig{})
+
+	counts, err := repo.BookingsPerDay(day(1), day(8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(conn.lastSQL, "group by") {
+		t.Errorf("query is not grouped: %s", conn.lastSQL)
+	}
+	if len(conn.lastArgs) != 2 || conn.lastArgs[0] != day(1) || conn.lastArgs[1] != day(8) {
+		t.Errorf("args: got %v", conn.lastArgs)
+	}
+	want := []models.DayCount{{Date: day(2), Count: 3}, {Date: day(5), Count: 1}}
+	if !reflect.DeepEqual(counts, want) {
+		t.Errorf("counts: got %+v, want %+v", counts, want)
+	}
+
+	conn.err = errors.New("query failed")
+	if _, err := repo.BookingsPerDay(day(1), day(8)); err == nil {
+		t.Error("expected BookingsPerDay error")
+	}
+}
+
+// TestGetIdempotentResult verifies that an unknown key is reported as
+// repository.ErrIdempotencyKeyNotFound and that a saved key returns its
+// reservation ID.
+func TestGetIdempotentResult(t *testing.T) {
+	conn := &fakeConnector{columns: []string{"reservation_id"}}
+	db := sql.OpenDB(conn)
+	defer db.Close()
+	repo := NewPostgresRepo(db, &config.AppConfig{})
+
+	if _, err := repo.GetIdempotentResult("key-1"); !errors.Is(err, repository.ErrIdempotencyKeyNotFound) {
+		t.Fatalf("unknown key: got %v, want ErrIdempotencyKeyNotFound", err)
+	}
+
+	conn.rows = [][]driver.Value{{int64(42)}}
+	resID, err := repo.GetIdempotentResult("key-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resID != 42 || len(conn.lastArgs) != 1 || conn.lastArgs[0] != "key-1" {
+		t.Errorf("got id %d with args %v, want 42 for key-1", resID, conn.lastArgs)
+	}
+}
+
+// TestGetRestrictionsForRoomBetween verifies the timestamp comparison against
+// a stay from the 1st to the 3rd: a same-day check-in after the configured
+// check-out time does not clash, one before it does, and moving check-out
+// later turns the afternoon arrival into a clash. The day-based wrapper keeps
+// treating the 3rd as free.
+func TestGetRestrictionsForRoomBetween(t *testing.T) {
+	day := func(d, hour int) time.Time { return time.Date(2050, time.March, d, hour, 0, 0, 0, time.UTC) }
+	stay := []driver.Value{int64(9), int64(5), int64(1), int64(2), day(1, 0), day(3, 0), ""}
+
+	tests := []struct {
+		name       string
+		checkIn    string
+		checkOut   string
+		start, end time.Time
+		want       int
+	}{
+		{"arrive after check-out", "3:00 PM", "11:00 AM", day(3, 15), day(5, 11), 0},
+		{"arrive before check-out", "3:00 PM", "11:00 AM", day(3, 10), day(5, 11), 1},
+		{"late check-out", "3:00 PM", "4:00 PM", day(3, 15), day(5, 11), 1},
+		{"leave before check-in", "3:00 PM", "11:00 AM", day(0, 15), day(1, 11), 0},
+		{"leave after check-in", "10:00", "11:00", day(0, 15), day(1, 11), 1},
+		{"unset times use defaults", "", "", day(3, 12), day(4, 11), 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			conn := &fakeConnector{
+				columns: []string{"id", "reservation_id", "restriction_id", "room_id", "start_date", "end_date", "note"},
+				rows:    [][]driver.Value{stay},
+			}
+			db := sql.OpenDB(conn)
+			defer db.Close()
+			repo := NewPostgresRepo(db, &config.AppConfig{CheckInTime: tc.checkIn, CheckOutTime: tc.checkOut})
+
+			got, err := repo.GetRestrictionsForRoomBetween(2, tc.start, tc.end)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != tc.want {
+				t.Fatalf("restrictions: got %d, want %d", len(got), tc.want)
+			}
+
+			// The query is narrowed to the calendar days of the period.
+			wantArgs := []driver.Value{tc.start.Truncate(24 * time.Hour), tc.end.Truncate(24 * time.Hour), int64(2)}
+			if !reflect.DeepEqual(conn.lastArgs, wantArgs) {
+				t.Errorf("args: got %v, want %v", conn.lastArgs, wantArgs)
+			}
+		})
+	}
+
+	t.Run("day-based wrapper", func(t *testing.T) {
+		conn := &fakeConnector{
+			columns: []string{"id", "reservation_id", "restriction_id", "room_id", "start_date", "end_date", "note"},
+			rows:    [][]driver.Value{stay},
+		}
+		db := sql.OpenDB(conn)
+		defer db.Close()
+		repo := NewPostgresRepo(db, &config.AppConfig{})
+
+		for _, c := range []struct {
+			start, end time.Time
+			want       int
+		}{
+			{day(3, 0), day(5, 0), 0},
+			{day(2, 0), day(2, 0), 1},
+			{day(0, 0), day(1, 0), 1},
+			{day(0, 0), day(0, 0), 0},
+		} {
+			got, err := repo.GetRestrictionsForRoomByDate(2, c.start, c.end)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != c.want {
+				t.Errorf("%s to %s: got %d, want %d", c.start.Format("Jan 2"), c.end.Format("Jan 2"), len(got), c.want)
+			}
+		}
+	})
+}
@@ -11,10 +11,16 @@
 package dbrepo
 
 import (
+	"context"
+	"database/sql"
 	"errors"
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/bensabler/milos-residence/internal/models"
+	"github.com/bensabler/milos-residence/internal/repository"
 )
 
 // Global toggle variables control test repository behavior to enable comprehensive error path testing.
@@ -23,24 +29,24 @@ import (
 //
 // Usage pattern in tests:
 //
-//	dbrepo.ForceAllReservationsErr = true
-//	defer func() { dbrepo.ForceAllReservationsErr = false }()
+//	dbrepo.ForceSearchReservationsErr = true
+//	defer func() { dbrepo.ForceSearchReservationsErr = false }()
 //
 // This approach enables testing of error handling without complex mocking frameworks
 // while maintaining simplicity and readability in test code.
 var (
-	// ForceAllReservationsErr causes AllReservations() to return an error.
+	// ForceSearchReservationsErr causes SearchReservations() to return an error.
 	// Used to test error handling in administrative reservation listing functionality.
-	ForceAllReservationsErr bool
-
-	// ForceAllNewReservationsErr causes AllNewReservations() to return an error.
-	// Used to test error handling when retrieving unprocessed reservations for staff review.
-	ForceAllNewReservationsErr bool
+	ForceSearchReservationsErr bool
 
 	// ForceUpdateReservationErr causes UpdateReservation() to return an error.
 	// Used to test error handling during reservation modification operations.
 	ForceUpdateReservationErr bool
 
+	// ForceDeleteReservationErr causes DeleteReservation() to return an error.
+	// Used to test that failed deletes are not reported or audited as done.
+	ForceDeleteReservationErr bool
+
 	// ForceProcessedUpdateErr causes UpdateProcessedForReservation() to return an error.
 	// Used to test error handling when marking reservations as processed/unprocessed.
 	ForceProcessedUpdateErr bool
@@ -49,11 +55,25 @@ var (
 	// Used to test error handling in room listing and calendar functionality.
 	ForceAllRoomsErr bool
 
+	// ForceAvailableRooms, when non-nil, is returned by
+	// SearchAvailabilityForAllRooms for any dates. Used to test paging through
+	// a large result.
+	ForceAvailableRooms []models.Room
+
+	// ForceNoRooms causes AllRooms() to return an empty list.
+	// Used to test the calendar with no rooms configured.
+	ForceNoRooms bool
+
 	// ForceGetReservationErr causes GetReservationByID() to return an error.
 	// Used to test error handling when retrieving specific reservation details.
 	ForceGetReservationErr bool
 
-	// ForceRestrictionsErr causes GetRestrictionsForRoomByDate() to return an error.
+	// ForceReservationNotFound causes GetReservationByID() to return repository.ErrReservationNotFound.
+	// Used to test handlers that distinguish a missing reservation from a database failure.
+	ForceReservationNotFound bool
+
+	// ForceRestrictionsErr causes GetRestrictionsForRoomByDate() and
+	// GetRestrictionsForAllRoomsByDate() to return an error.
 	// Used to test error handling in calendar and availability checking functionality.
 	ForceRestrictionsErr bool
 
@@ -67,6 +87,13 @@ var (
 	// enabling testing of reservation vs. owner-block distinction in calendar displays.
 	ForceHasReservationRestriction bool
 
+	// ForceNoReservationRestriction causes GetReservationRestriction() to
+	// report that the reservation has no restriction (sql.ErrNoRows).
+	ForceNoReservationRestriction bool
+
+	// ForceUpdateDatesErr causes UpdateReservationDates() to return an error.
+	ForceUpdateDatesErr bool
+
 	// ForceInsertBlockErr causes InsertBlockForRoom() to return an error.
 	// Used to test error handling when administrators add room blocks through the calendar interface.
 	ForceInsertBlockErr bool
@@ -74,16 +101,100 @@ var (
 	// ForceDeleteBlockErr causes DeleteBlockByID() to return an error.
 	// Used to test error handling when administrators remove room blocks through the calendar interface.
 	ForceDeleteBlockErr bool
+
+	// ForceConflictsErr causes FindOverlappingRestrictions() to return an error.
+	// Used to test error handling in the restriction conflict report.
+	ForceConflictsErr bool
+
+	// ForceReservationsByEmailErr causes ReservationsByEmail() to return an error.
+	// Used to test guest history failure handling on the reservation page.
+	ForceReservationsByEmailErr bool
+
+	// ForceRateErr causes GetRateForDate() to return an error.
+	// Used to test reservation pricing failure handling.
+	ForceRateErr bool
+
+	// ForceWaitlistErr causes AddToWaitlist() and WaitlistForDates() to return an error.
+	// Used to test waitlist signup and cancellation follow-up error handling.
+	ForceWaitlistErr bool
+
+	// ForceReservationExistsErr causes ReservationExists() to return an error.
+	// Used to test duplicate-booking check failure handling.
+	ForceReservationExistsErr bool
+
+	// ForceNextAvailableErr causes NextAvailableDate() to return an error.
+	// Used to test the rooms page when availability can't be computed.
+	ForceNextAvailableErr bool
+
+	// ForceFindWindowsErr causes FindAvailableWindows() to return an error.
+	// Used to test the flexible-dates search when availability can't be computed.
+	ForceFindWindowsErr bool
+
+	// ForceHoldErr causes CreateHold(), ReleaseHold() and ExpireHolds() to
+	// return an error. Used to test that the booking flow survives a failed hold.
+	ForceHoldErr bool
+
+	// ForceExpireUnconfirmedErr causes ExpireUnconfirmedReservations() to return an error.
+	ForceExpireUnconfirmedErr bool
+
+	// ForcePingErr causes Ping() to return an error.
+	// Used to test health checks against an unreachable database.
+	ForcePingErr bool
+
+	// ForceAuditErr causes RecordAudit() and RecentAudit() to return an error.
+	// Used to test that admin actions still complete when auditing fails, and
+	// the audit page's error handling.
+	ForceAuditErr bool
+
+	// ForceMailLogErr causes LogSentMail() and RecentSentMail() to return an
+	// error. Used to test the mail log page's error handling.
+	ForceMailLogErr bool
+
+	// ForceBookingsPerDayErr causes BookingsPerDay() to return an error.
+	// Used to test the dashboard stats endpoint's error handling.
+	ForceBookingsPerDayErr bool
+
+	// ForceIdempotencyErr causes GetIdempotentResult() to return an error.
+	ForceIdempotencyErr bool
+
+	// ForceIdempotencyMiss makes GetIdempotentResult() report every key as
+	// unseen while CreateReservation() still finds it, as happens when a
+	// request races another with the same Idempotency-Key.
+	ForceIdempotencyMiss bool
+
+	// ForceFailedMailErr causes DeadLetterMail(), FailedMail() and
+	// RequeueMail() to return a database error. Used to test the failed-mail
+	// page's error handling.
+	ForceFailedMailErr bool
+
+	// ForceResetTokenErr causes CreateResetToken() and ConsumeResetToken() to
+	// return a database error. Used to test the password reset pages' error
+	// handling.
+	ForceResetTokenErr bool
+
+	// ForceSettingsErr causes GetSetting() and SetSetting() to return an error.
+	// Used to test that pages fall back to defaults when settings can't be read.
+	ForceSettingsErr bool
+
+	// ForceArrivalsErr causes ArrivalsBetween() to return an error.
+	// Used to test the arrivals page's error handling.
+	ForceArrivalsErr bool
+
+	// ForceRoomImagesErr causes ImagesForRoom(), InsertRoomImage() and
+	// DeleteRoomImage() to return an error.
+	// Used to test that room pages still render when the gallery can't be loaded.
+	ForceRoomImagesErr bool
 )
 
-// AllUsers is a placeholder method that always returns true for basic connectivity testing.
-// This method was implemented during development for simple database interaction verification
-// and currently serves as a minimal health check operation in the test environment.
+// Ping reports the test database as reachable unless ForcePingErr is set.
 //
-// In production repository implementations, this would typically return actual user data,
-// user counts, or perform more meaningful user-related operations.
-func (m *testDBRepo) AllUsers() bool {
-	return true
+// Returns:
+//   - error: Simulated connectivity error when ForcePingErr is true, nil otherwise
+func (m *testDBRepo) Ping(ctx context.Context) error {
+	if ForcePingErr {
+		return errors.New("ping error")
+	}
+	return nil
 }
 
 // InsertReservation creates a mock reservation and returns a predictable ID.
@@ -145,6 +256,51 @@ func (m *testDBRepo) InsertRoomRestriction(r models.RoomRestriction) error {
 	return nil
 }
 
+// createdReservations holds reservations stored through CreateReservation.
+// ResetCreatedReservations clears them between tests.
+var createdReservations []models.Reservation
+
+// ResetCreatedReservations discards reservations stored by CreateReservation.
+func ResetCreatedReservations() {
+	createdReservations = nil
+}
+
+// CreatedReservations returns the reservations stored by CreateReservation,
+// oldest first.
+func CreatedReservations() []models.Reservation {
+	return createdReservations
+}
+
+// CreateReservation stores the reservation in memory and returns its
+// position in CreatedReservations as the ID, so the first reservation after
+// ResetCreatedReservations is ID 1, like InsertReservation. RoomID 3 fails,
+// standing in for a restriction insert that rolls the transaction back, so
+// nothing is stored. Like the PostgreSQL version, a key another reservation
+// already claimed gets that reservation's ID and
+// repository.ErrIdempotencyKeyUsed, and a reservation overlapping one already
+// created for the room gets repository.ErrDatesTaken, which lets tests stage
+// the loser of a race.
+func (m *testDBRepo) CreateReservation(res models.Reservation, idempotencyKey string) (int, error) {
+	if res.RoomID == 3 {
+		return 0, errors.New("create reservation error")
+	}
+	if resID, ok := idempotencyKeys[idempotencyKey]; ok && idempotencyKey != "" {
+		return resID, repository.ErrIdempotencyKeyUsed
+	}
+	for _, other := range createdReservations {
+		if other.RoomID == res.RoomID && res.StartDate.Before(other.EndDate) && res.EndDate.After(other.StartDate) {
+			return 0, repository.ErrDatesTaken
+		}
+	}
+
+	res.ID = len(createdReservations) + 1
+	createdReservations = append(createdReservations, res)
+	if idempotencyKey != "" {
+		idempotencyKeys[idempotencyKey] = res.ID
+	}
+	return res.ID, nil
+}
+
 // SearchAvailabilityByDatesByRoomID simulates room availability checking with multiple test scenarios.
 // This method provides controlled availability responses and error conditions to enable comprehensive
 // testing of room booking workflows, availability validation, and error handling patterns.
@@ -175,6 +331,8 @@ func (m *testDBRepo) InsertRoomRestriction(r models.RoomRestriction) error {
 //   - bool: true if room is available, false if unavailable or error occurred
 //   - error: Simulated database error for specific test conditions, nil for normal operation
 func (m *testDBRepo) SearchAvailabilityByDatesByRoomID(start, end time.Time, roomID int) (bool, error) {
+	AvailabilityCalls++
+
 	// Check for dynamically configured error condition via toggle system
 	if ForceSearchAvailabilityErrOn != 0 && roomID == ForceSearchAvailabilityErrOn {
 		return false, errors.New("db error")
@@ -185,6 +343,11 @@ func (m *testDBRepo) SearchAvailabilityByDatesByRoomID(start, end time.Time, roo
 		return false, errors.New("db error")
 	}
 
+	// Closed rooms are never available
+	if closedRooms[roomID] {
+		return false, nil
+	}
+
 	// Date-based availability logic for predictable test scenarios
 	if start.Year() == 2101 {
 		return true, nil // Available - triggers successful booking workflows
@@ -193,6 +356,16 @@ func (m *testDBRepo) SearchAvailabilityByDatesByRoomID(start, end time.Time, roo
 	return false, nil // Unavailable - triggers "no availability" workflows
 }
 
+// AvailabilityCalls counts SearchAvailabilityByDatesByRoomID calls so tests can
+// assert that a handler rejected a request without querying. Clear it with
+// ResetAvailabilityCalls.
+var AvailabilityCalls int
+
+// ResetAvailabilityCalls sets AvailabilityCalls back to zero.
+func ResetAvailabilityCalls() {
+	AvailabilityCalls = 0
+}
+
 // SearchAvailabilityForAllRooms simulates comprehensive availability search across all rooms.
 // This method supports testing of the main availability search functionality where users
 // input desired dates and receive a list of available rooms for selection.
@@ -205,6 +378,8 @@ func (m *testDBRepo) SearchAvailabilityByDatesByRoomID(start, end time.Time, roo
 //  2. **Rooms Available**: When start date year is 2101, returns a single room
 //     (Golden Haybeam Loft) to simulate successful availability search results.
 //     This enables testing of room selection interfaces and booking progression.
+//     An unexpired hold on room 1 overlapping the dates removes it, as in
+//     PostgreSQL; expired holds are ignored.
 //
 //  3. **No Availability**: For all other date combinations, returns an empty slice
 //     to simulate scenarios where no rooms are available for the requested dates.
@@ -226,9 +401,13 @@ func (m *testDBRepo) SearchAvailabilityForAllRooms(start, end time.Time) ([]mode
 		return nil, errors.New("all rooms error")
 	}
 
+	if ForceAvailableRooms != nil {
+		return ForceAvailableRooms, nil
+	}
+
 	// Return available room for specific test scenario (year 2101)
-	if start.Year() == 2101 {
-		return []models.Room{{ID: 1, RoomName: "Golden Haybeam Loft"}}, nil
+	if start.Year() == 2101 && !closedRooms[1] && !heldAt(1, start, end, time.Now()) {
+		return []models.Room{{ID: 1, RoomName: "Golden Haybeam Loft", Active: true}}, nil
 	}
 
 	// Return empty availability for all other scenarios
@@ -263,7 +442,25 @@ func (m *testDBRepo) GetRoomByID(id int) (models.Room, error) {
 	}
 
 	// Return mock room data with provided ID
-	return models.Room{ID: id, RoomName: "Room"}, nil
+	room := models.Room{ID: id, RoomName: roomName(id, "Room"), MaxGuests: TestMaxGuests, Active: !closedRooms[id]}
+	if days, ok := roomLeadDays[id]; ok {
+		room.LeadDays = &days
+	}
+	return room, nil
+}
+
+// roomLeadDays holds per-room lead time overrides returned by GetRoomByID.
+// Rooms without an entry use the site-wide setting.
+var roomLeadDays = map[int]int{}
+
+// SetRoomLeadDays gives the room a lead time override of days.
+func SetRoomLeadDays(id, days int) {
+	roomLeadDays[id] = days
+}
+
+// ResetRoomLeadDays removes every lead time override.
+func ResetRoomLeadDays() {
+	roomLeadDays = map[int]int{}
 }
 
 // GetUserByID is a placeholder method that returns an empty User model.
@@ -281,7 +478,10 @@ func (m *testDBRepo) GetRoomByID(id int) (models.Room, error) {
 //   - models.User: Empty user model
 //   - error: Always nil in current implementation
 func (m *testDBRepo) GetUserByID(id int) (models.User, error) {
-	return models.User{}, nil
+	if id == TestAdminUserID {
+		return models.User{ID: id, Email: TestExistingUserEmail, AccessLevel: 3}, nil
+	}
+	return models.User{ID: id, AccessLevel: 1}, nil
 }
 
 // UpdateUser is a placeholder method that always succeeds.
@@ -297,6 +497,88 @@ func (m *testDBRepo) UpdateUser(u models.User) error {
 	return nil
 }
 
+// passwords holds the password most recently set through UpdatePassword for
+// each user ID. ResetPasswords clears it between tests.
+var passwords = map[int]string{}
+
+// ResetPasswords discards all passwords stored through UpdatePassword.
+func ResetPasswords() {
+	passwords = map[int]string{}
+}
+
+// PasswordFor returns the password last set for id through UpdatePassword
+// and whether one was set.
+func PasswordFor(id int) (string, bool) {
+	p, ok := passwords[id]
+	return p, ok
+}
+
+// UpdatePassword records the password as given so tests can check that a
+// handler changed it; hashing is covered by the postgres repository tests.
+//
+// Parameters:
+//   - id: User identifier
+//   - password: New plaintext password
+//
+// Returns:
+//   - error: Always nil
+func (m *testDBRepo) UpdatePassword(id int, password string) error {
+	passwords[id] = password
+	return nil
+}
+
+// TestAdminUserID is the user returned by Authenticate; GetUserByID reports it
+// at the top access level (3). Every other ID is an access-level-1 staff user.
+const TestAdminUserID = 1
+
+// TestExistingUserEmail is registered from the start, so CreateUser rejects it
+// and GetUserByEmail finds it.
+const TestExistingUserEmail = "admin@example.com"
+
+// users holds accounts created through CreateUser so tests can create and then
+// look up users. ResetUsers clears it between tests.
+var users []models.User
+
+// ResetUsers discards all users stored by the test repository.
+func ResetUsers() {
+	users = nil
+}
+
+// GetUserByEmail finds TestExistingUserEmail or a user stored by CreateUser,
+// comparing emails case-insensitively like the PostgreSQL implementation.
+//
+// Returns:
+//   - models.User: Matching user
+//   - error: repository.ErrUserNotFound when no user matches
+func (m *testDBRepo) GetUserByEmail(email string) (models.User, error) {
+	if strings.EqualFold(email, TestExistingUserEmail) {
+		return m.GetUserByID(TestAdminUserID)
+	}
+	for _, u := range users {
+		if strings.EqualFold(u.Email, email) {
+			return u, nil
+		}
+	}
+	return models.User{}, repository.ErrUserNotFound
+}
+
+// CreateUser stores the user in memory with a sequential ID. The password is
+// kept as given; hashing is covered by the postgres repository tests.
+//
+// Returns:
+//   - int: New user ID
+//   - error: repository.ErrDuplicateEmail when the email is already registered
+func (m *testDBRepo) CreateUser(u models.User, plainPassword string) (int, error) {
+	if _, err := m.GetUserByEmail(u.Email); err == nil {
+		return 0, repository.ErrDuplicateEmail
+	}
+
+	u.ID = TestAdminUserID + 1 + len(users)
+	u.Password = plainPassword
+	users = append(users, u)
+	return u.ID, nil
+}
+
 // Authenticate simulates user authentication with controlled success and failure scenarios.
 // This method enables testing of login workflows, authentication error handling,
 // and session management without requiring actual user accounts or password hashing.
@@ -326,60 +608,134 @@ func (m *testDBRepo) Authenticate(email, _ string) (int, string, error) {
 	}
 
 	// Return successful authentication for all other emails
-	return 1, "", nil
+	return TestAdminUserID, "", nil
+}
+
+// resetTokens maps each unused token issued by CreateResetToken to its user
+// ID. ResetResetTokens clears it between tests.
+var resetTokens = map[string]int{}
+
+// ResetResetTokens discards all tokens issued by the test repository.
+func ResetResetTokens() {
+	resetTokens = map[string]int{}
 }
 
-// AllReservations retrieves all reservations with controlled error scenarios for administrative testing.
-// This method simulates the comprehensive reservation listing functionality used in administrative
-// interfaces while providing error conditions for testing database connectivity and error handling.
+// CreateResetToken issues a predictable token for userID and, like the
+// PostgreSQL implementation, revokes the user's earlier unused tokens.
 //
-// When operating normally, returns a minimal reservation list with basic guest information
-// sufficient for testing reservation display, sorting, and administrative workflow functionality.
+// Returns:
+//   - string: Token of the form "reset-<userID>-<n>"
+//   - error: Simulated database error when ForceResetTokenErr is true
+func (m *testDBRepo) CreateResetToken(userID int) (string, error) {
+	if ForceResetTokenErr {
+		return "", errors.New("reset token error")
+	}
+
+	for token, id := range resetTokens {
+		if id == userID {
+			delete(resetTokens, token)
+		}
+	}
+	issuedResetTokens++
+	token := fmt.Sprintf("reset-%d-%d", userID, issuedResetTokens)
+	resetTokens[token] = userID
+	return token, nil
+}
+
+// issuedResetTokens numbers tokens so each one is distinct.
+var issuedResetTokens int
+
+// ConsumeResetToken removes token and returns its user ID, so a second use
+// fails as it would against PostgreSQL. Expiry is not simulated.
 //
-// Error scenarios (when ForceAllReservationsErr is true) enable testing of:
-//   - Database connectivity failure recovery
-//   - Administrative interface error handling and user messaging
-//   - Graceful degradation when reservation data is unavailable
-//   - Error logging and monitoring in administrative systems
+// Returns:
+//   - int: User ID the token was issued for
+//   - error: repository.ErrInvalidResetToken for an unknown or used token,
+//     or a simulated database error when ForceResetTokenErr is true
+func (m *testDBRepo) ConsumeResetToken(token string) (int, error) {
+	if ForceResetTokenErr {
+		return 0, errors.New("reset token error")
+	}
+
+	userID, ok := resetTokens[token]
+	if !ok {
+		return 0, repository.ErrInvalidResetToken
+	}
+	delete(resetTokens, token)
+	return userID, nil
+}
+
+// testListedReservations are the canned reservations SearchReservations
+// filters: one already processed and one new.
+var testListedReservations = []models.Reservation{
+	{ID: 1, FirstName: "A", LastName: "B", Processed: models.ReservationProcessed},
+	{ID: 2, FirstName: "C", LastName: "D", Processed: models.ReservationNew},
+}
+
+// LastReservationFilter is the filter passed to the most recent
+// SearchReservations call, so tests can assert what a handler requested.
+var LastReservationFilter repository.ReservationFilter
+
+// SearchReservations returns the canned reservations matching filter.Status
+// (all of them for "all" or an unknown status) and records the filter in
+// LastReservationFilter. The sort key is accepted but not applied.
 //
 // Returns:
-//   - []models.Reservation: Single mock reservation for testing or nil if error forced
-//   - error: Simulated database error when ForceAllReservationsErr is true, nil otherwise
-func (m *testDBRepo) AllReservations() ([]models.Reservation, error) {
+//   - []models.Reservation: Matching canned reservations, or nil if error forced
+//   - error: Simulated database error when ForceSearchReservationsErr is true, nil otherwise
+func (m *testDBRepo) SearchReservations(filter repository.ReservationFilter) ([]models.Reservation, error) {
+	LastReservationFilter = filter
+
 	// Check for forced error condition via toggle system
-	if ForceAllReservationsErr {
-		return nil, errors.New("all reservations error")
+	if ForceSearchReservationsErr {
+		return nil, errors.New("search reservations error")
 	}
 
-	// Return minimal reservation data for successful testing scenarios
-	return []models.Reservation{{ID: 1, FirstName: "A", LastName: "B"}}, nil
+	var matches []models.Reservation
+	for _, res := range testListedReservations {
+		switch {
+		case filter.Status == repository.ReservationStatusNew && res.Processed != models.ReservationNew:
+		case filter.Status == repository.ReservationStatusProcessed && res.Processed != models.ReservationProcessed:
+		default:
+			matches = append(matches, res)
+		}
+	}
+	return matches, nil
 }
 
-// AllNewReservations retrieves unprocessed reservations with controlled error scenarios.
-// This method simulates the new reservation queue functionality used by administrative staff
-// to review, validate, and process incoming guest bookings.
-//
-// When operating normally, returns a minimal unprocessed reservation with different guest
-// information than AllReservations() to enable testing of reservation processing workflows
-// and staff interface functionality.
-//
-// Error scenarios (when ForceAllNewReservationsErr is true) enable testing of:
-//   - Database connectivity failure during staff processing workflows
-//   - New reservation queue error handling and recovery
-//   - Staff notification systems when reservation data is unavailable
-//   - Administrative workflow continuity during database issues
+// testArrivals are the canned reservations ArrivalsBetween filters: three
+// guests arriving on 03/10/2050 (listed out of name order) and one the day
+// after.
+var testArrivals = []models.Reservation{
+	{ID: 31, FirstName: "Oscar", LastName: "Whiskers", StartDate: time.Date(2050, time.March, 10, 0, 0, 0, 0, time.UTC), EndDate: time.Date(2050, time.March, 12, 0, 0, 0, 0, time.UTC), RoomID: 1, Room: models.Room{ID: 1, RoomName: "Golden Haybeam Loft"}},
+	{ID: 32, FirstName: "Bella", LastName: "Abbott", StartDate: time.Date(2050, time.March, 10, 0, 0, 0, 0, time.UTC), EndDate: time.Date(2050, time.March, 11, 0, 0, 0, 0, time.UTC), RoomID: 2, Room: models.Room{ID: 2, RoomName: "Window Perch Theater"}},
+	{ID: 33, FirstName: "Luna", LastName: "Mews", StartDate: time.Date(2050, time.March, 10, 0, 0, 0, 0, time.UTC), EndDate: time.Date(2050, time.March, 14, 0, 0, 0, 0, time.UTC), RoomID: 3, Room: models.Room{ID: 3, RoomName: "Laundry-Basket Nook"}},
+	{ID: 34, FirstName: "Felix", LastName: "Tabby", StartDate: time.Date(2050, time.March, 11, 0, 0, 0, 0, time.UTC), EndDate: time.Date(2050, time.March, 13, 0, 0, 0, 0, time.UTC), RoomID: 1, Room: models.Room{ID: 1, RoomName: "Golden Haybeam Loft"}},
+}
+
+// ArrivalsBetween returns the testArrivals arriving in [start, end), ordered
+// by last then first name like the PostgreSQL query.
 //
 // Returns:
-//   - []models.Reservation: Single mock unprocessed reservation or nil if error forced
-//   - error: Simulated database error when ForceAllNewReservationsErr is true, nil otherwise
-func (m *testDBRepo) AllNewReservations() ([]models.Reservation, error) {
-	// Check for forced error condition via toggle system
-	if ForceAllNewReservationsErr {
-		return nil, errors.New("all new reservations error")
+//   - error: Simulated failure when ForceArrivalsErr is set
+func (m *testDBRepo) ArrivalsBetween(start, end time.Time) ([]models.Reservation, error) {
+	if ForceArrivalsErr {
+		return nil, errors.New("arrivals error")
 	}
 
-	// Return minimal unprocessed reservation data for testing
-	return []models.Reservation{{ID: 2, FirstName: "C", LastName: "D"}}, nil
+	var arrivals []models.Reservation
+	for _, res := range testArrivals {
+		if !res.StartDate.Before(start) && res.StartDate.Before(end) {
+			arrivals = append(arrivals, res)
+		}
+	}
+	sort.Slice(arrivals, func(i, j int) bool {
+		if arrivals[i].LastName != arrivals[j].LastName {
+			return arrivals[i].LastName < arrivals[j].LastName
+		}
+		return arrivals[i].FirstName < arrivals[j].FirstName
+	})
+	return arrivals, nil
 }
 
 // GetReservationByID retrieves specific reservation details with controlled error scenarios.
@@ -388,6 +744,8 @@ func (m *testDBRepo) AllNewReservations() ([]models.Reservation, error) {
 //
 // When operating normally, returns a minimal reservation model with the provided ID,
 // sufficient for testing reservation detail interfaces and modification workflows.
+// ID 999 (or ForceReservationNotFound) yields repository.ErrReservationNotFound so
+// handlers can be tested against the "no such reservation" path.
 //
 // Error scenarios (when ForceGetReservationErr is true) enable testing of:
 //   - Database connectivity failure during reservation detail access
@@ -400,17 +758,153 @@ func (m *testDBRepo) AllNewReservations() ([]models.Reservation, error) {
 //
 // Returns:
 //   - models.Reservation: Mock reservation with provided ID or empty if error forced
-//   - error: Simulated database error when ForceGetReservationErr is true, nil otherwise
+//   - error: Simulated database error when ForceGetReservationErr is true,
+//     repository.ErrReservationNotFound for ID 999, nil otherwise
 func (m *testDBRepo) GetReservationByID(id int) (models.Reservation, error) {
 	// Check for forced error condition via toggle system
 	if ForceGetReservationErr {
 		return models.Reservation{}, errors.New("get reservation error")
 	}
 
-	// Return minimal reservation data with provided ID
-	return models.Reservation{ID: id}, nil
+	// Simulate a lookup that matched no rows
+	if ForceReservationNotFound || id == 999 {
+		return models.Reservation{}, repository.ErrReservationNotFound
+	}
+
+	// Return minimal reservation data with provided ID and a fixed one-night stay
+	status := reservationStatus(id)
+	return models.Reservation{
+		ID:        id,
+		StartDate: time.Date(2050, time.January, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2050, time.January, 2, 0, 0, 0, 0, time.UTC),
+		UpdatedAt: TestReservationUpdatedAt,
+		Notes:     reservationNotes[id],
+		Status:    status,
+		Processed: models.ProcessedForStatus(status),
+	}, nil
 }
 
+// reservationStatuses holds statuses set through SetReservationStatus or
+// SetTestReservationStatus, keyed by reservation ID. Reservations without an
+// entry are pending. ResetReservationStatuses clears it between tests.
+var reservationStatuses = map[int]string{}
+
+// reservationStatus returns the stored status for id, pending by default.
+func reservationStatus(id int) string {
+	if s, ok := reservationStatuses[id]; ok {
+		return s
+	}
+	return models.StatusPending
+}
+
+// SetTestReservationStatus puts a reservation in status directly, skipping
+// the transition rules, so tests can start from any stage.
+func SetTestReservationStatus(id int, status string) {
+	reservationStatuses[id] = status
+}
+
+// ResetReservationStatuses returns every reservation to pending.
+func ResetReservationStatuses() {
+	reservationStatuses = map[int]string{}
+}
+
+// SetReservationStatus applies the same validation as the PostgreSQL
+// repository against the in-memory statuses.
+//
+// Returns:
+//   - error: repository.ErrInvalidStatus, repository.ErrReservationNotFound
+//     for ID 999, repository.ErrStatusTransition (wrapped) for a disallowed
+//     change, nil otherwise
+func (m *testDBRepo) SetReservationStatus(id int, status string) error {
+	valid := false
+	for _, s := range models.ReservationStatuses {
+		valid = valid || s == status
+	}
+	if !valid {
+		return repository.ErrInvalidStatus
+	}
+	if id == 999 {
+		return repository.ErrReservationNotFound
+	}
+
+	current := reservationStatus(id)
+	if !models.CanTransition(current, status) {
+		return fmt.Errorf("set reservation status: %s to %s: %w", current, status, repository.ErrStatusTransition)
+	}
+	reservationStatuses[id] = status
+	return nil
+}
+
+// TestReservationRestrictionID returns the ID of the restriction the test
+// repository reports for a reservation through GetReservationRestriction.
+func TestReservationRestrictionID(reservationID int) int {
+	return 5000 + reservationID
+}
+
+// GetReservationRestriction returns a restriction for room 1 covering the
+// dates GetReservationByID reports, with ID
+// TestReservationRestrictionID(reservationID).
+//
+// Returns:
+//   - error: Wraps sql.ErrNoRows when ForceNoReservationRestriction is true
+func (m *testDBRepo) GetReservationRestriction(reservationID int) (models.RoomRestriction, error) {
+	if ForceNoReservationRestriction {
+		return models.RoomRestriction{}, fmt.Errorf("get reservation restriction: %w", sql.ErrNoRows)
+	}
+
+	return models.RoomRestriction{
+		ID:            TestReservationRestrictionID(reservationID),
+		StartDate:     time.Date(2050, time.January, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:       time.Date(2050, time.January, 2, 0, 0, 0, 0, time.UTC),
+		RoomID:        1,
+		ReservationID: reservationID,
+		RestrictionID: 1,
+	}, nil
+}
+
+// LastDateChange records the most recent UpdateReservationDates call: ID is
+// the restriction moved, ReservationID the reservation. Tests reset it to the
+// zero value.
+var LastDateChange models.RoomRestriction
+
+// LastDateChangeTotal is the total passed to the most recent
+// UpdateReservationDates call.
+var LastDateChangeTotal int
+
+// UpdateReservationDates records the change in LastDateChange and
+// LastDateChangeTotal.
+//
+// Returns:
+//   - error: Simulated database error when ForceUpdateDatesErr is true, nil otherwise
+func (m *testDBRepo) UpdateReservationDates(reservationID, restrictionID int, start, end time.Time, total int) error {
+	if ForceUpdateDatesErr {
+		return errors.New("update dates error")
+	}
+
+	LastDateChange = models.RoomRestriction{
+		ID:            restrictionID,
+		ReservationID: reservationID,
+		StartDate:     start,
+		EndDate:       end,
+	}
+	LastDateChangeTotal = total
+	return nil
+}
+
+// reservationNotes holds the notes saved through UpdateReservation, keyed by
+// reservation ID, so an edit can be read back through GetReservationByID.
+// ResetReservationNotes clears it between tests.
+var reservationNotes = map[int]string{}
+
+// ResetReservationNotes discards all notes saved through UpdateReservation.
+func ResetReservationNotes() {
+	reservationNotes = map[int]string{}
+}
+
+// TestReservationUpdatedAt is the updated_at of every reservation returned by
+// GetReservationByID. UpdateReservation treats any other value as stale.
+var TestReservationUpdatedAt = time.Date(2049, time.December, 1, 12, 30, 0, 123456000, time.UTC)
+
 // UpdateReservation modifies reservation information with controlled error scenarios.
 // This method simulates reservation update operations used in administrative interfaces
 // for guest information correction, contact detail updates, and reservation modifications.
@@ -422,32 +916,77 @@ func (m *testDBRepo) GetReservationByID(id int) (models.Reservation, error) {
 //   - User messaging and retry workflows for failed reservation updates
 //
 // Parameters:
-//   - u: Reservation model with updated information (not processed in test implementation)
+//   - u: Reservation model with updated information; only Notes is kept, so it
+//     round-trips through GetReservationByID
+//
+// Updates whose UpdatedAt differs from TestReservationUpdatedAt are rejected
+// as stale, mirroring the updated_at check in the PostgreSQL implementation.
 //
 // Returns:
-//   - error: Simulated database error when ForceUpdateReservationErr is true, nil otherwise
+//   - error: Simulated database error when ForceUpdateReservationErr is true,
+//     repository.ErrStaleUpdate for a stale UpdatedAt, nil otherwise
 func (m *testDBRepo) UpdateReservation(u models.Reservation) error {
 	// Check for forced error condition via toggle system
 	if ForceUpdateReservationErr {
 		return errors.New("update reservation error")
 	}
 
+	if !u.UpdatedAt.Equal(TestReservationUpdatedAt) {
+		return repository.ErrStaleUpdate
+	}
+
+	reservationNotes[u.ID] = u.Notes
 	return nil
 }
 
-// DeleteReservation is a placeholder method that always succeeds.
-// This method simulates reservation deletion operations but provides minimal
-// functionality in the current test environment.
+// DeleteReservation simulates reservation deletion; nothing is stored, so
+// there is nothing to remove.
 //
 // Parameters:
 //   - id: Reservation identifier for deletion (not processed in current implementation)
 //
 // Returns:
-//   - error: Always nil in current implementation
+//   - error: Simulated database error when ForceDeleteReservationErr is true, nil otherwise
 func (m *testDBRepo) DeleteReservation(id int) error {
+	if ForceDeleteReservationErr {
+		return errors.New("delete reservation error")
+	}
 	return nil
 }
 
+// DeleteReservationsBatch simulates a bulk delete. Reservation 999 does not
+// exist, so a batch containing it fails with repository.ErrReservationNotFound
+// and deletes nothing; otherwise the IDs are appended to DeletedReservationIDs.
+//
+// Parameters:
+//   - ids: Reservation identifiers to delete
+//
+// Returns:
+//   - error: Simulated database error when ForceDeleteReservationErr is true,
+//     repository.ErrReservationNotFound for ID 999, nil otherwise
+func (m *testDBRepo) DeleteReservationsBatch(ids []int) error {
+	if ForceDeleteReservationErr {
+		return errors.New("delete reservations error")
+	}
+	for _, id := range ids {
+		if id == 999 {
+			return repository.ErrReservationNotFound
+		}
+	}
+	DeletedReservationIDs = append(DeletedReservationIDs, ids...)
+	return nil
+}
+
+// DeletedReservationIDs records the IDs removed by DeleteReservationsBatch so
+// tests can assert what a handler deleted. Clear it with
+// ResetDeletedReservations.
+var DeletedReservationIDs []int
+
+// ResetDeletedReservations discards the recorded DeleteReservationsBatch IDs.
+func ResetDeletedReservations() {
+	DeletedReservationIDs = nil
+}
+
 // UpdateProcessedForReservation modifies reservation processing status with controlled error scenarios.
 // This method simulates the reservation processing workflow where administrative staff mark
 // reservations as reviewed, validated, and ready for guest communication and service delivery.
@@ -463,16 +1002,38 @@ func (m *testDBRepo) DeleteReservation(id int) error {
 //   - processed: New processing status (typically 0 for unprocessed, 1 for processed)
 //
 // Returns:
-//   - error: Simulated database error when ForceProcessedUpdateErr is true, nil otherwise
+//   - error: repository.ErrInvalidProcessed for values other than 0 or 1,
+//     simulated database error when ForceProcessedUpdateErr is true, nil otherwise
 func (m *testDBRepo) UpdateProcessedForReservation(id, processed int) error {
+	if processed != models.ReservationNew && processed != models.ReservationProcessed {
+		return repository.ErrInvalidProcessed
+	}
+
 	// Check for forced error condition via toggle system
 	if ForceProcessedUpdateErr {
 		return errors.New("processed update error")
 	}
 
+	ProcessedCalls = append(ProcessedCalls, ProcessedCall{ID: id, Processed: processed})
 	return nil
 }
 
+// ProcessedCall records the arguments of one UpdateProcessedForReservation call.
+type ProcessedCall struct {
+	ID        int
+	Processed int
+}
+
+// ProcessedCalls records every successful UpdateProcessedForReservation call
+// so tests can assert which value a handler passed. Clear it with
+// ResetProcessedCalls.
+var ProcessedCalls []ProcessedCall
+
+// ResetProcessedCalls discards the recorded UpdateProcessedForReservation calls.
+func ResetProcessedCalls() {
+	ProcessedCalls = nil
+}
+
 // AllRooms retrieves comprehensive room information with controlled error scenarios.
 // This method simulates room listing operations used throughout the application for
 // availability checking, administrative calendar displays, and room selection interfaces.
@@ -492,7 +1053,8 @@ func (m *testDBRepo) UpdateProcessedForReservation(id, processed int) error {
 // functionality without requiring complex test data setup.
 //
 // Returns:
-//   - []models.Room: Single room (Golden Haybeam Loft) or nil if error forced
+//   - []models.Room: Single room (Golden Haybeam Loft), empty when ForceNoRooms
+//     is set, or nil if error forced
 //   - error: Simulated database error when ForceAllRoomsErr is true, nil otherwise
 func (m *testDBRepo) AllRooms() ([]models.Room, error) {
 	// Check for forced error condition via toggle system
@@ -500,8 +1062,174 @@ func (m *testDBRepo) AllRooms() ([]models.Room, error) {
 		return nil, errors.New("all rooms error")
 	}
 
+	if ForceNoRooms {
+		return []models.Room{}, nil
+	}
+
 	// Return consistent single room data for testing
-	return []models.Room{{ID: 1, RoomName: "Golden Haybeam Loft"}}, nil
+	return []models.Room{{ID: 1, RoomName: roomName(1, "Golden Haybeam Loft"), Active: !closedRooms[1]}}, nil
+}
+
+// InsertRoom returns ID 4, the next ID after the three rooms the test
+// repository knows. The room is not stored. A name matching
+// TestTakenRoomName, ignoring case and extra whitespace, is refused with
+// repository.ErrDuplicateRoomName.
+func (m *testDBRepo) InsertRoom(room models.Room) (int, error) {
+	if sameRoomName(room.RoomName, TestTakenRoomName) {
+		return 0, repository.ErrDuplicateRoomName
+	}
+	return 4, nil
+}
+
+// TestTakenRoomName is the name of room 1, the room AllRooms returns. Other
+// rooms can't be given it through InsertRoom or UpdateRoom.
+const TestTakenRoomName = "Golden Haybeam Loft"
+
+// sameRoomName reports whether two room names match the way the rooms
+// unique index compares them: ignoring case and extra whitespace.
+func sameRoomName(a, b string) bool {
+	norm := func(s string) string { return strings.ToLower(strings.Join(strings.Fields(s), " ")) }
+	return norm(a) == norm(b)
+}
+
+// UpdatedRooms records every room saved through UpdateRoom so tests can
+// check what a handler wrote. Clear it with ResetUpdatedRooms.
+var UpdatedRooms []models.Room
+
+// ResetUpdatedRooms discards the recorded UpdateRoom calls.
+func ResetUpdatedRooms() {
+	UpdatedRooms = nil
+}
+
+// roomName returns the name the room was last given through UpdateRoom, or
+// name if it hasn't been renamed, so renames show up in AllRooms and
+// GetRoomByID.
+func roomName(id int, name string) string {
+	for _, room := range UpdatedRooms {
+		if room.ID == id {
+			name = room.RoomName
+		}
+	}
+	return name
+}
+
+// UpdateRoom records the room in UpdatedRooms.
+//
+// Returns:
+//   - error: sql.ErrNoRows for IDs above 3 (as GetRoomByID treats them as
+//     missing), repository.ErrDuplicateRoomName when a room other than 1 is
+//     given TestTakenRoomName, nil otherwise
+func (m *testDBRepo) UpdateRoom(room models.Room) error {
+	if room.ID > 3 {
+		return sql.ErrNoRows
+	}
+	if room.ID != 1 && sameRoomName(room.RoomName, TestTakenRoomName) {
+		return repository.ErrDuplicateRoomName
+	}
+	UpdatedRooms = append(UpdatedRooms, room)
+	return nil
+}
+
+// closedRooms holds the IDs of rooms closed through SetRoomActive.
+// ResetClosedRooms reopens them all between tests.
+var closedRooms = map[int]bool{}
+
+// ResetClosedRooms reopens every room closed through SetRoomActive.
+func ResetClosedRooms() {
+	closedRooms = map[int]bool{}
+}
+
+// SetRoomActive records the room as open or closed so AllRooms, GetRoomByID
+// and the availability searches reflect it.
+//
+// Returns:
+//   - error: Wraps sql.ErrNoRows for room IDs beyond the GetRoomByID range
+func (m *testDBRepo) SetRoomActive(id int, active bool) error {
+	if id > 3 {
+		return fmt.Errorf("set room active: %w", sql.ErrNoRows)
+	}
+
+	if active {
+		delete(closedRooms, id)
+	} else {
+		closedRooms[id] = true
+	}
+	return nil
+}
+
+// roomImages holds gallery images added through InsertRoomImage, keyed by
+// image ID. ResetRoomImages clears them between tests.
+var roomImages = map[int]models.RoomImage{}
+
+// nextRoomImageID is the ID assigned to the next inserted image.
+var nextRoomImageID = 1
+
+// ResetRoomImages removes every image added through InsertRoomImage.
+func ResetRoomImages() {
+	roomImages = map[int]models.RoomImage{}
+	nextRoomImageID = 1
+}
+
+// ImagesForRoom returns the stored images for the room, ordered by SortOrder
+// then ID like the PostgreSQL query.
+//
+// Returns:
+//   - error: Simulated failure when ForceRoomImagesErr is set
+func (m *testDBRepo) ImagesForRoom(roomID int) ([]models.RoomImage, error) {
+	if ForceRoomImagesErr {
+		return nil, errors.New("room images error")
+	}
+
+	var images []models.RoomImage
+	for _, img := range roomImages {
+		if img.RoomID == roomID {
+			images = append(images, img)
+		}
+	}
+	sort.Slice(images, func(i, j int) bool {
+		if images[i].SortOrder != images[j].SortOrder {
+			return images[i].SortOrder < images[j].SortOrder
+		}
+		return images[i].ID < images[j].ID
+	})
+	return images, nil
+}
+
+// InsertRoomImage stores the image and returns its ID.
+//
+// Returns:
+//   - error: Simulated failure when ForceRoomImagesErr is set, or a foreign
+//     key style error for room IDs beyond the GetRoomByID range
+func (m *testDBRepo) InsertRoomImage(img models.RoomImage) (int, error) {
+	if ForceRoomImagesErr {
+		return 0, errors.New("room images error")
+	}
+	if img.RoomID > 3 {
+		return 0, fmt.Errorf("insert room image: no room %d", img.RoomID)
+	}
+
+	img.ID = nextRoomImageID
+	nextRoomImageID++
+	img.CreatedAt, img.UpdatedAt = time.Now().UTC(), time.Now().UTC()
+	roomImages[img.ID] = img
+	return img.ID, nil
+}
+
+// DeleteRoomImage removes a stored image belonging to the room.
+//
+// Returns:
+//   - error: Simulated failure when ForceRoomImagesErr is set; wraps
+//     sql.ErrNoRows when the room has no such image
+func (m *testDBRepo) DeleteRoomImage(roomID, imageID int) error {
+	if ForceRoomImagesErr {
+		return errors.New("room images error")
+	}
+	img, ok := roomImages[imageID]
+	if !ok || img.RoomID != roomID {
+		return fmt.Errorf("delete room image: %w", sql.ErrNoRows)
+	}
+	delete(roomImages, imageID)
+	return nil
 }
 
 // GetRestrictionsForRoomByDate retrieves room restrictions with comprehensive test scenario support.
@@ -510,16 +1238,21 @@ func (m *testDBRepo) AllRooms() ([]models.Room, error) {
 //
 // The method provides multiple types of test data to support comprehensive testing:
 //
-//  1. **Default Block Restriction**: Always includes one owner block (ReservationID = 0)
-//     positioned 4 days after the start date. This simulates administrative room blocks
-//     used for maintenance, personal use, or other non-guest restrictions.
+//  1. **Default Block Restriction**: Includes one owner block (ReservationID = 0)
+//     positioned 4 days after the start date, when the range reaches that day. This
+//     simulates administrative room blocks used for maintenance, personal use, or other
+//     non-guest restrictions.
 //
 //  2. **Optional Reservation Restriction**: When ForceHasReservationRestriction is true,
 //     adds a reservation restriction (ReservationID = 777) spanning days 1-3 of the query period.
 //     This simulates actual guest reservations and enables testing of reservation vs. block
 //     distinction in calendar displays and availability calculations.
 //
-//  3. **Error Scenarios**: When ForceRestrictionsErr is true, returns database errors
+//  3. **Inserted Blocks**: Blocks created through InsertBlockForRoom for the room
+//     that overlap the query period are appended, so a block's reason and note
+//     round-trip from insert to read without a database.
+//
+//  4. **Error Scenarios**: When ForceRestrictionsErr is true, returns database errors
 //     to test error handling in calendar and availability systems.
 //
 // The dual restriction approach (blocks + reservations) enables comprehensive testing of:
@@ -541,16 +1274,18 @@ func (m *testDBRepo) GetRestrictionsForRoomByDate(roomID int, start, end time.Ti
 		return nil, errors.New("restrictions error")
 	}
 
-	// Always include an owner block restriction to provide data for delete/keep testing loops
-	// Positioned 4 days after start date to simulate realistic administrative blocking patterns
-	res := []models.RoomRestriction{
-		{
+	// Include an owner block restriction to provide data for delete/keep testing loops,
+	// positioned 4 days after start date to simulate realistic administrative blocking
+	// patterns. Like the PostgreSQL query, it is left out when the range ends before it.
+	var res []models.RoomRestriction
+	if blockDay := start.AddDate(0, 0, 4); !end.Before(blockDay) {
+		res = append(res, models.RoomRestriction{
 			ID:            11,
-			StartDate:     start.AddDate(0, 0, 4),
-			EndDate:       start.AddDate(0, 0, 4),
+			StartDate:     blockDay,
+			EndDate:       blockDay,
 			RoomID:        roomID,
 			ReservationID: 0, // Owner block (no associated reservation)
-		},
+		})
 	}
 
 	// Optionally include a reservation restriction when toggle is enabled
@@ -566,10 +1301,73 @@ func (m *testDBRepo) GetRestrictionsForRoomByDate(roomID int, start, end time.Ti
 		})
 	}
 
+	for _, b := range blocks {
+		if b.RoomID == roomID && start.Before(b.EndDate) && !end.Before(b.StartDate) {
+			res = append(res, b)
+		}
+	}
+
+	return res, nil
+}
+
+// GetRestrictionsForRoomBetween returns the blocks stored with
+// InsertBlockForRoom that occupy roomID between start and end, using the
+// check-in and check-out times from m.App like the PostgreSQL version. The
+// canned restrictions of GetRestrictionsForRoomByDate are not included.
+//
+// Returns:
+//   - []models.RoomRestriction: Matching blocks, in insertion order
+//   - error: Simulated database error when ForceRestrictionsErr is true
+func (m *testDBRepo) GetRestrictionsForRoomBetween(roomID int, start, end time.Time) ([]models.RoomRestriction, error) {
+	if ForceRestrictionsErr {
+		return nil, errors.New("restrictions error")
+	}
+
+	t := turnoverTimes(m.App)
+	var res []models.RoomRestriction
+	for _, b := range blocks {
+		if b.RoomID == roomID && occupiedBetween(b, start, end, t) {
+			res = append(res, b)
+		}
+	}
 	return res, nil
 }
 
+// GetRestrictionsForAllRoomsByDate returns the canned restrictions from
+// GetRestrictionsForRoomByDate for every room in AllRooms, keyed by room ID,
+// so calendar tests see the same data as before the single-query refactor.
+//
+// Returns:
+//   - map[int][]models.RoomRestriction: Restrictions keyed by room ID
+//   - error: Simulated database error when ForceRestrictionsErr or ForceAllRoomsErr is true
+func (m *testDBRepo) GetRestrictionsForAllRoomsByDate(start, end time.Time) (map[int][]models.RoomRestriction, error) {
+	rooms, err := m.AllRooms()
+	if err != nil {
+		return nil, err
+	}
+
+	restrictions := make(map[int][]models.RoomRestriction)
+	for _, room := range rooms {
+		res, err := m.GetRestrictionsForRoomByDate(room.ID, start, end)
+		if err != nil {
+			return nil, err
+		}
+		restrictions[room.ID] = res
+	}
+	return restrictions, nil
+}
+
+// blocks holds restrictions created through InsertBlockForRoom so tests can
+// insert a block and read it back. ResetBlocks clears it between tests.
+var blocks []models.RoomRestriction
+
+// ResetBlocks discards all blocks stored by the test repository.
+func ResetBlocks() {
+	blocks = nil
+}
+
 // InsertBlockForRoom creates room blocks with controlled error scenarios for calendar testing.
+// Successful inserts are kept in memory and returned by GetRestrictionsForRoomByDate.
 // This method simulates the administrative block creation functionality used in calendar
 // interfaces where staff can click dates to create owner blocks for maintenance, personal use,
 // or other non-guest restrictions.
@@ -582,16 +1380,26 @@ func (m *testDBRepo) GetRestrictionsForRoomByDate(roomID int, start, end time.Ti
 //
 // Parameters:
 //   - id: Room identifier for block creation
-//   - startDate: Date to create block for (not processed in test implementation)
+//   - startDate: Date to create block for
+//   - restrictionID: Block reason (restriction type)
+//   - note: Staff note explaining the block
 //
 // Returns:
 //   - error: Simulated database error when ForceInsertBlockErr is true, nil otherwise
-func (m *testDBRepo) InsertBlockForRoom(id int, startDate time.Time) error {
+func (m *testDBRepo) InsertBlockForRoom(id int, startDate time.Time, restrictionID int, note string) error {
 	// Check for forced error condition via toggle system
 	if ForceInsertBlockErr {
 		return errors.New("insert block error")
 	}
 
+	blocks = append(blocks, models.RoomRestriction{
+		ID:            1000 + len(blocks),
+		StartDate:     startDate,
+		EndDate:       startDate.AddDate(0, 0, 1),
+		RoomID:        id,
+		RestrictionID: restrictionID,
+		Note:          note,
+	})
 	return nil
 }
 
@@ -619,3 +1427,585 @@ func (m *testDBRepo) DeleteBlockByID(id int) error {
 
 	return nil
 }
+
+// FindOverlappingRestrictions returns a single known conflict for report testing.
+// The canned pair is a reservation (ID 42) overlapping an owner block (ID 11) on
+// room 1, giving templates realistic data for both restriction types.
+//
+// Returns:
+//   - []models.RestrictionConflict: One conflict, or nil if error forced
+//   - error: Simulated database error when ForceConflictsErr is true, nil otherwise
+func (m *testDBRepo) FindOverlappingRestrictions() ([]models.RestrictionConflict, error) {
+	// Check for forced error condition via toggle system
+	if ForceConflictsErr {
+		return nil, errors.New("conflicts error")
+	}
+
+	room := models.Room{ID: 1, RoomName: "Golden Haybeam Loft"}
+	start := time.Date(2050, time.January, 10, 0, 0, 0, 0, time.UTC)
+
+	return []models.RestrictionConflict{
+		{
+			Room: room,
+			First: models.RoomRestriction{
+				ID:            11,
+				StartDate:     start.AddDate(0, 0, 1),
+				EndDate:       start.AddDate(0, 0, 2),
+				RoomID:        room.ID,
+				RestrictionID: 2,
+			},
+			Second: models.RoomRestriction{
+				ID:            42,
+				StartDate:     start,
+				EndDate:       start.AddDate(0, 0, 3),
+				RoomID:        room.ID,
+				ReservationID: 777,
+				RestrictionID: 1,
+			},
+		},
+	}, nil
+}
+
+// waitlist holds entries added through AddToWaitlist so tests can add and then
+// query entries without a database. ResetWaitlist clears it between tests.
+var waitlist []models.WaitlistEntry
+
+// ResetWaitlist discards all entries stored by the test repository's waitlist.
+func ResetWaitlist() {
+	waitlist = nil
+}
+
+// AddToWaitlist stores the entry in memory with a sequential ID.
+//
+// Returns:
+//   - error: Simulated database error when ForceWaitlistErr is true, nil otherwise
+func (m *testDBRepo) AddToWaitlist(entry models.WaitlistEntry) error {
+	// Check for forced error condition via toggle system
+	if ForceWaitlistErr {
+		return errors.New("waitlist error")
+	}
+
+	entry.ID = len(waitlist) + 1
+	waitlist = append(waitlist, entry)
+	return nil
+}
+
+// WaitlistForDates returns stored entries overlapping [start, end), matching
+// the half-open overlap used by the PostgreSQL implementation.
+//
+// Returns:
+//   - []models.WaitlistEntry: Matching entries in insertion order
+//   - error: Simulated database error when ForceWaitlistErr is true, nil otherwise
+func (m *testDBRepo) WaitlistForDates(start, end time.Time) ([]models.WaitlistEntry, error) {
+	// Check for forced error condition via toggle system
+	if ForceWaitlistErr {
+		return nil, errors.New("waitlist error")
+	}
+
+	var entries []models.WaitlistEntry
+	for _, e := range waitlist {
+		if e.StartDate.Before(end) && e.EndDate.After(start) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+// TestMaxGuests is the capacity of every room returned by GetRoomByID.
+const TestMaxGuests = 2
+
+// Test pricing data: every room costs TestBaseRate per night except on
+// TestRateOverrideDate, which costs TestOverrideRate.
+const (
+	TestBaseRate     = 10000
+	TestOverrideRate = 15000
+)
+
+// TestRateOverrideDate is the single night with an override rate in the test repository.
+var TestRateOverrideDate = time.Date(2100, time.December, 25, 0, 0, 0, 0, time.UTC)
+
+// GetRateForDate returns TestOverrideRate on TestRateOverrideDate and
+// TestBaseRate on every other night, mirroring the override-then-base lookup.
+//
+// Returns:
+//   - int: Rate in cents
+//   - error: Simulated database error when ForceRateErr is true or the room does not exist
+func (m *testDBRepo) GetRateForDate(roomID int, date time.Time) (int, error) {
+	// Check for forced error condition via toggle system
+	if ForceRateErr {
+		return 0, errors.New("rate error")
+	}
+
+	// Match the GetRoomByID "room not found" range
+	if roomID > 3 {
+		return 0, errors.New("room not found")
+	}
+
+	y, mo, d := date.Date()
+	if y == TestRateOverrideDate.Year() && mo == TestRateOverrideDate.Month() && d == TestRateOverrideDate.Day() {
+		return TestOverrideRate, nil
+	}
+	return TestBaseRate, nil
+}
+
+// NextAvailableDate scans forward from from over the blocks stored through
+// InsertBlockForRoom for the room, so tests control availability by inserting
+// blocks.
+//
+// Returns:
+//   - time.Time: First night no stored block covers
+//   - error: repository.ErrNoAvailability when every night in the horizon is
+//     blocked, a simulated database error when ForceNextAvailableErr is true
+func (m *testDBRepo) NextAvailableDate(roomID int, from time.Time) (time.Time, error) {
+	if ForceNextAvailableErr {
+		return time.Time{}, errors.New("next available error")
+	}
+
+	from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	until := from.AddDate(0, 0, repository.NextAvailableHorizonDays)
+
+	var roomBlocks []models.RoomRestriction
+	for _, b := range blocks {
+		if b.RoomID == roomID {
+			roomBlocks = append(roomBlocks, b)
+		}
+	}
+
+	night, ok := firstFreeNight(from, until, roomBlocks)
+	if !ok {
+		return time.Time{}, repository.ErrNoAvailability
+	}
+	return night, nil
+}
+
+// FindAvailableWindows finds gaps in the month between the blocks stored
+// through InsertBlockForRoom for the room, like NextAvailableDate.
+//
+// Returns:
+//   - []models.DateRange: Free windows of at least nights, earliest first
+//   - error: Simulated database error when ForceFindWindowsErr is true
+func (m *testDBRepo) FindAvailableWindows(roomID int, monthStart time.Time, nights int) ([]models.DateRange, error) {
+	if ForceFindWindowsErr {
+		return nil, errors.New("find windows error")
+	}
+
+	from := time.Date(monthStart.Year(), monthStart.Month(), 1, 0, 0, 0, 0, time.UTC)
+	until := from.AddDate(0, 1, 0)
+
+	var roomBlocks []models.RoomRestriction
+	for _, b := range blocks {
+		if b.RoomID == roomID {
+			roomBlocks = append(roomBlocks, b)
+		}
+	}
+
+	return freeWindows(from, until, nights, roomBlocks), nil
+}
+
+// Hold is a hold stored by CreateHold.
+type Hold struct {
+	RoomID    int
+	Start     time.Time
+	End       time.Time
+	ExpiresAt time.Time
+}
+
+// holds holds the holds created through CreateHold, keyed by ID. ResetHolds
+// clears it between tests.
+var holds = map[int]Hold{}
+
+// nextHoldID is the ID CreateHold gives the next hold.
+var nextHoldID = 1
+
+// Holds returns a copy of the stored holds, keyed by ID, so tests can check
+// which a handler created, released or expired.
+func Holds() map[int]Hold {
+	out := make(map[int]Hold, len(holds))
+	for id, h := range holds {
+		out[id] = h
+	}
+	return out
+}
+
+// ResetHolds discards every stored hold.
+func ResetHolds() {
+	holds = map[int]Hold{}
+	nextHoldID = 1
+}
+
+// CreateHold stores a hold unless the nights overlap a block stored through
+// InsertBlockForRoom or another hold that hasn't expired.
+//
+// Returns:
+//   - int: ID of the new hold
+//   - error: repository.ErrDatesTaken on overlap, a simulated database error
+//     when ForceHoldErr is true
+func (m *testDBRepo) CreateHold(roomID int, start, end, expiresAt time.Time) (int, error) {
+	if ForceHoldErr {
+		return 0, errors.New("create hold error")
+	}
+
+	for _, b := range blocks {
+		if b.RoomID == roomID && start.Before(b.EndDate) && end.After(b.StartDate) {
+			return 0, repository.ErrDatesTaken
+		}
+	}
+	if heldAt(roomID, start, end, time.Now()) {
+		return 0, repository.ErrDatesTaken
+	}
+
+	id := nextHoldID
+	nextHoldID++
+	holds[id] = Hold{RoomID: roomID, Start: start, End: end, ExpiresAt: expiresAt}
+	return id, nil
+}
+
+// heldAt reports whether a stored hold on roomID overlapping start to end is
+// still unexpired at now.
+func heldAt(roomID int, start, end, now time.Time) bool {
+	for _, h := range holds {
+		if h.RoomID == roomID && start.Before(h.End) && end.After(h.Start) && h.ExpiresAt.After(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReleaseHold removes the stored hold, if any.
+//
+// Returns:
+//   - error: Simulated database error when ForceHoldErr is true, nil otherwise
+func (m *testDBRepo) ReleaseHold(id int) error {
+	if ForceHoldErr {
+		return errors.New("release hold error")
+	}
+	delete(holds, id)
+	return nil
+}
+
+// ExpireHolds removes stored holds that expired at or before now.
+//
+// Returns:
+//   - int: Number of holds removed
+//   - error: Simulated database error when ForceHoldErr is true
+func (m *testDBRepo) ExpireHolds(now time.Time) (int, error) {
+	if ForceHoldErr {
+		return 0, errors.New("expire holds error")
+	}
+	n := 0
+	for id, h := range holds {
+		if !h.ExpiresAt.After(now) {
+			delete(holds, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// TestDuplicateEmail is treated by ReservationExists as already holding a
+// booking for any room and dates.
+const TestDuplicateEmail = "duplicate@example.com"
+
+// ReservationExists reports a duplicate for TestDuplicateEmail (compared
+// case-insensitively) and no duplicate for any other guest.
+//
+// Returns:
+//   - bool: true when email is TestDuplicateEmail
+//   - error: Simulated database error when ForceReservationExistsErr is true
+func (m *testDBRepo) ReservationExists(email string, roomID int, start, end time.Time) (bool, error) {
+	if ForceReservationExistsErr {
+		return false, errors.New("reservation exists error")
+	}
+
+	return strings.EqualFold(email, TestDuplicateEmail), nil
+}
+
+// ExpireUnconfirmedReservations reports that nothing expired; the test
+// repository never removes its pending reservations.
+//
+// Returns:
+//   - int: Always 0
+//   - error: Simulated database error when ForceExpireUnconfirmedErr is true
+func (m *testDBRepo) ExpireUnconfirmedReservations(olderThan time.Time) (int, error) {
+	if ForceExpireUnconfirmedErr {
+		return 0, errors.New("expire unconfirmed error")
+	}
+	return 0, nil
+}
+
+// ReservationsByEmail returns reservation 1 (the one GetReservationByID serves
+// in most tests) plus two prior stays, so callers can exercise excluding the
+// current reservation from a guest's history.
+//
+// Returns:
+//   - []models.Reservation: Three reservations, newest first
+//   - error: Simulated database error when ForceReservationsByEmailErr is true
+func (m *testDBRepo) ReservationsByEmail(email string) ([]models.Reservation, error) {
+	// Check for forced error condition via toggle system
+	if ForceReservationsByEmailErr {
+		return nil, errors.New("reservations by email error")
+	}
+
+	room := models.Room{ID: 1, RoomName: "Golden Haybeam Loft"}
+	return []models.Reservation{
+		{ID: 1, Email: email, StartDate: time.Date(2050, time.January, 1, 0, 0, 0, 0, time.UTC), EndDate: time.Date(2050, time.January, 2, 0, 0, 0, 0, time.UTC), RoomID: room.ID, Room: room},
+		{ID: 101, Email: email, StartDate: time.Date(2049, time.June, 10, 0, 0, 0, 0, time.UTC), EndDate: time.Date(2049, time.June, 12, 0, 0, 0, 0, time.UTC), RoomID: room.ID, Room: room},
+		{ID: 102, Email: email, StartDate: time.Date(2048, time.March, 3, 0, 0, 0, 0, time.UTC), EndDate: time.Date(2048, time.March, 5, 0, 0, 0, 0, time.UTC), RoomID: room.ID, Room: room},
+	}, nil
+}
+
+// audit holds entries recorded through RecordAudit so tests can assert that a
+// handler logged an action. ResetAudit clears it between tests.
+var audit []models.AuditEntry
+
+// ResetAudit discards all entries stored by the test repository's audit log.
+func ResetAudit() {
+	audit = nil
+}
+
+// RecordAudit stores the entry in memory with a sequential ID.
+//
+// Returns:
+//   - error: Simulated database error when ForceAuditErr is true, nil otherwise
+func (m *testDBRepo) RecordAudit(userID int, action, detail string) error {
+	if ForceAuditErr {
+		return errors.New("audit error")
+	}
+
+	audit = append(audit, models.AuditEntry{
+		ID:        len(audit) + 1,
+		UserID:    userID,
+		Action:    action,
+		Detail:    detail,
+		CreatedAt: time.Now().UTC(),
+	})
+	return nil
+}
+
+// RecentAudit returns up to limit stored entries, newest first.
+//
+// Returns:
+//   - []models.AuditEntry: Stored entries, newest first
+//   - error: Simulated database error when ForceAuditErr is true, nil otherwise
+func (m *testDBRepo) RecentAudit(limit int) ([]models.AuditEntry, error) {
+	if ForceAuditErr {
+		return nil, errors.New("audit error")
+	}
+
+	var entries []models.AuditEntry
+	for i := len(audit) - 1; i >= 0 && len(entries) < limit; i-- {
+		entries = append(entries, audit[i])
+	}
+	return entries, nil
+}
+
+// mailLog holds entries recorded through LogSentMail. ResetMailLog clears it
+// between tests.
+var mailLog []models.MailLogEntry
+
+// ResetMailLog discards all entries stored by the test repository's mail log.
+func ResetMailLog() {
+	mailLog = nil
+}
+
+// LogSentMail stores the entry in memory with a sequential ID.
+//
+// Returns:
+//   - error: Simulated database error when ForceMailLogErr is true, nil otherwise
+func (m *testDBRepo) LogSentMail(msg models.MailData, status string) error {
+	if ForceMailLogErr {
+		return errors.New("mail log error")
+	}
+
+	mailLog = append(mailLog, models.MailLogEntry{
+		ID:        len(mailLog) + 1,
+		To:        msg.To,
+		From:      msg.From,
+		Subject:   msg.Subject,
+		Template:  msg.Template,
+		Status:    status,
+		CreatedAt: time.Now().UTC(),
+	})
+	return nil
+}
+
+// RecentSentMail returns up to limit stored entries, newest first.
+//
+// Returns:
+//   - []models.MailLogEntry: Stored entries, newest first
+//   - error: Simulated database error when ForceMailLogErr is true, nil otherwise
+func (m *testDBRepo) RecentSentMail(limit int) ([]models.MailLogEntry, error) {
+	if ForceMailLogErr {
+		return nil, errors.New("mail log error")
+	}
+
+	var entries []models.MailLogEntry
+	for i := len(mailLog) - 1; i >= 0 && len(entries) < limit; i-- {
+		entries = append(entries, mailLog[i])
+	}
+	return entries, nil
+}
+
+// failedMail holds messages dead-lettered through DeadLetterMail, keyed by
+// ID. ResetFailedMail clears it between tests.
+var failedMail = map[int]models.OutboundMail{}
+
+// nextFailedMailID is the ID given to the next dead-lettered message.
+var nextFailedMailID = 1
+
+// ResetFailedMail empties the test repository's failed-mail queue.
+func ResetFailedMail() {
+	failedMail = map[int]models.OutboundMail{}
+	nextFailedMailID = 1
+}
+
+// DeadLetterMail stores the message in memory with a sequential ID. Like
+// the PostgreSQL version it skips messages marked Sensitive.
+//
+// Returns:
+//   - error: Simulated database error when ForceFailedMailErr is true, nil otherwise
+func (m *testDBRepo) DeadLetterMail(msg models.MailData, sendErr string) error {
+	if ForceFailedMailErr {
+		return errors.New("failed mail error")
+	}
+	if msg.Sensitive {
+		return nil
+	}
+
+	failedMail[nextFailedMailID] = models.OutboundMail{
+		ID:        nextFailedMailID,
+		To:        msg.To,
+		From:      msg.From,
+		Subject:   msg.Subject,
+		Template:  msg.Template,
+		Content:   msg.Content,
+		Status:    models.MailStatusFailed,
+		Error:     sendErr,
+		CreatedAt: time.Now().UTC(),
+	}
+	nextFailedMailID++
+	return nil
+}
+
+// FailedMail returns the stored messages, newest (highest ID) first.
+//
+// Returns:
+//   - error: Simulated database error when ForceFailedMailErr is true, nil otherwise
+func (m *testDBRepo) FailedMail() ([]models.OutboundMail, error) {
+	if ForceFailedMailErr {
+		return nil, errors.New("failed mail error")
+	}
+
+	var msgs []models.OutboundMail
+	for id := nextFailedMailID - 1; id > 0; id-- {
+		if o, ok := failedMail[id]; ok {
+			msgs = append(msgs, o)
+		}
+	}
+	return msgs, nil
+}
+
+// RequeueMail removes the message from memory and returns it.
+//
+// Returns:
+//   - error: Wraps sql.ErrNoRows for unknown IDs; simulated database error
+//     when ForceFailedMailErr is true
+func (m *testDBRepo) RequeueMail(id int) (models.MailData, error) {
+	if ForceFailedMailErr {
+		return models.MailData{}, errors.New("failed mail error")
+	}
+
+	o, ok := failedMail[id]
+	if !ok {
+		return models.MailData{}, fmt.Errorf("requeue mail: %w", sql.ErrNoRows)
+	}
+	delete(failedMail, id)
+	return o.Mail(), nil
+}
+
+// settings holds values saved through SetSetting so tests can exercise the
+// admin settings page. ResetSettings clears it between tests.
+var settings = map[string]string{}
+
+// ResetSettings discards all values stored by the test repository's settings.
+func ResetSettings() {
+	settings = map[string]string{}
+}
+
+// GetSetting returns the value saved for key.
+//
+// Returns:
+//   - string: Stored value
+//   - error: repository.ErrSettingNotFound for unset keys, a simulated
+//     database error when ForceSettingsErr is true
+func (m *testDBRepo) GetSetting(key string) (string, error) {
+	if ForceSettingsErr {
+		return "", errors.New("settings error")
+	}
+
+	value, ok := settings[key]
+	if !ok {
+		return "", repository.ErrSettingNotFound
+	}
+	return value, nil
+}
+
+// SetSetting stores value for key in memory.
+//
+// Returns:
+//   - error: Simulated database error when ForceSettingsErr is true, nil otherwise
+func (m *testDBRepo) SetSetting(key, value string) error {
+	if ForceSettingsErr {
+		return errors.New("settings error")
+	}
+
+	settings[key] = value
+	return nil
+}
+
+// BookingsPerDay returns a deterministic series for handler tests: every
+// third day in [start, end) counted from start (start, start+3, ...) has
+// (day number % 4) + 1 bookings; the other days are left out, the way the
+// grouped query omits days with no reservations.
+//
+// Returns:
+//   - []models.DayCount: Sparse daily counts, oldest first
+//   - error: Simulated database error when ForceBookingsPerDayErr is true, nil otherwise
+func (m *testDBRepo) BookingsPerDay(start, end time.Time) ([]models.DayCount, error) {
+	if ForceBookingsPerDayErr {
+		return nil, errors.New("bookings per day error")
+	}
+
+	var counts []models.DayCount
+	for d := start; d.Before(end); d = d.AddDate(0, 0, 3) {
+		counts = append(counts, models.DayCount{Date: d, Count: d.Day()%4 + 1})
+	}
+	return counts, nil
+}
+
+// idempotencyKeys holds the keys claimed through CreateReservation.
+// ResetIdempotencyKeys clears it between tests.
+var idempotencyKeys = map[string]int{}
+
+// ResetIdempotencyKeys discards every saved idempotency key.
+func ResetIdempotencyKeys() {
+	idempotencyKeys = map[string]int{}
+}
+
+// GetIdempotentResult returns the reservation ID saved for key.
+//
+// Returns:
+//   - int: Saved reservation ID
+//   - error: Simulated database error when ForceIdempotencyErr is true,
+//     repository.ErrIdempotencyKeyNotFound for unknown keys
+func (m *testDBRepo) GetIdempotentResult(key string) (int, error) {
+	if ForceIdempotencyErr {
+		return 0, errors.New("idempotency error")
+	}
+
+	resID, ok := idempotencyKeys[key]
+	if !ok || ForceIdempotencyMiss {
+		return 0, repository.ErrIdempotencyKeyNotFound
+	}
+	return resID, nil
+}
@@ -2,16 +2,139 @@
 package repository
 
 import (
+	"context"
+	"errors"
 	"time"
 
 	"github.com/bensabler/milos-residence/internal/models"
 )
 
+// ErrReservationNotFound is returned by DatabaseRepo implementations when no
+// reservation matches the requested ID. Callers should compare with errors.Is
+// to distinguish a missing record from a database failure.
+var ErrReservationNotFound = errors.New("reservation not found")
+
+// ErrStaleUpdate is returned by UpdateReservation when the reservation was
+// changed (or deleted) after the caller loaded it, so the update was not
+// applied. Callers should reload the record and let the user retry.
+var ErrStaleUpdate = errors.New("reservation changed since it was loaded")
+
+// ErrInvalidProcessed is returned by UpdateProcessedForReservation for a
+// value other than models.ReservationNew or models.ReservationProcessed.
+var ErrInvalidProcessed = errors.New("processed must be 0 or 1")
+
+// ErrInvalidStatus is returned by SetReservationStatus for a status that is
+// not one of models.ReservationStatuses.
+var ErrInvalidStatus = errors.New("unknown reservation status")
+
+// ErrStatusTransition is returned by SetReservationStatus when the
+// reservation's current status may not move to the requested one (see
+// models.StatusTransitions).
+var ErrStatusTransition = errors.New("reservation status change not allowed")
+
+// ErrUserNotFound is returned by GetUserByEmail when no user has the email.
+var ErrUserNotFound = errors.New("user not found")
+
+// ErrInvalidResetToken is returned by ConsumeResetToken when the token is
+// unknown, has expired or has already been used.
+var ErrInvalidResetToken = errors.New("invalid or expired password reset token")
+
+// ErrDuplicateEmail is returned by CreateUser when another user already has
+// the email address (compared case-insensitively).
+var ErrDuplicateEmail = errors.New("email already registered")
+
+// ErrDuplicateRoomName is returned by InsertRoom and UpdateRoom when another
+// room already has the same name, ignoring case and extra whitespace.
+var ErrDuplicateRoomName = errors.New("room name already in use")
+
+// ErrNoAvailability is returned by NextAvailableDate when the room has no free
+// night within NextAvailableHorizonDays.
+var ErrNoAvailability = errors.New("no availability within horizon")
+
+// ErrDatesTaken is returned by CreateReservation and CreateHold when another reservation, block or
+// unexpired hold already covers some of the requested nights.
+var ErrDatesTaken = errors.New("dates already reserved or held")
+
+// HoldRestrictionID is the restrictions row for a hold: dates kept for a
+// guest between choosing a room and submitting the booking form.
+const HoldRestrictionID = 4
+
+// NextAvailableHorizonDays is how far ahead of the start date
+// NextAvailableDate looks for a free night.
+const NextAvailableHorizonDays = 180
+
+// ErrIdempotencyKeyNotFound is returned by GetIdempotentResult when no
+// request with the key has been processed.
+var ErrIdempotencyKeyNotFound = errors.New("idempotency key not found")
+
+// ErrIdempotencyKeyUsed is returned by CreateReservation when another
+// request has already created a reservation with the same Idempotency-Key.
+// The ID returned alongside it is that reservation's.
+var ErrIdempotencyKeyUsed = errors.New("idempotency key already used")
+
+// ErrSettingNotFound is returned by GetSetting when no value has been saved
+// for the key. Callers normally fall back to a configured default.
+var ErrSettingNotFound = errors.New("setting not found")
+
+// DefaultReservationSort is the SearchReservations order used when no sort, or a
+// sort outside ReservationSorts, is requested.
+const DefaultReservationSort = "start_date_asc"
+
+// ReservationSorts is the allowlist of sort keys accepted by SearchReservations,
+// in the order they are offered on the admin list page. Implementations map
+// each key to a fixed ORDER BY clause; the key itself never reaches SQL.
+var ReservationSorts = []string{
+	"start_date_asc", "start_date_desc",
+	"created_at_asc", "created_at_desc",
+	"last_name_asc", "last_name_desc",
+}
+
+// ValidReservationSort reports whether sort is one of ReservationSorts.
+func ValidReservationSort(sort string) bool {
+	for _, s := range ReservationSorts {
+		if s == sort {
+			return true
+		}
+	}
+	return false
+}
+
+// Reservation statuses accepted by ReservationFilter.Status.
+const (
+	ReservationStatusAll       = "all"       // Every reservation
+	ReservationStatusNew       = "new"       // Not yet processed by staff
+	ReservationStatusProcessed = "processed" // Processed by staff
+)
+
+// ReservationStatuses lists the accepted statuses in the order the admin
+// reservations page offers them.
+var ReservationStatuses = []string{ReservationStatusNew, ReservationStatusProcessed, ReservationStatusAll}
+
+// ValidReservationStatus reports whether status is one of ReservationStatuses.
+func ValidReservationStatus(status string) bool {
+	for _, s := range ReservationStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// ReservationFilter selects and orders the reservations returned by
+// SearchReservations. Unknown values fall back to the defaults rather than
+// failing, and never reach SQL: implementations translate each field through
+// a fixed allowlist and bind any values as query parameters. New fields must
+// follow the same rule.
+type ReservationFilter struct {
+	Status string // One of ReservationStatuses; empty or unknown means all
+	Sort   string // One of ReservationSorts; empty or unknown means DefaultReservationSort
+}
+
 // DatabaseRepo defines the interface for all database operations.
 // Implementations provide data access for users, reservations, rooms, and restrictions.
 type DatabaseRepo interface {
-	// AllUsers returns true if database connection is healthy.
-	AllUsers() bool
+	// Ping verifies the database is reachable, honoring ctx's deadline.
+	Ping(ctx context.Context) error
 
 	// InsertReservation creates a new reservation record.
 	// Returns the generated reservation ID.
@@ -20,6 +143,13 @@ type DatabaseRepo interface {
 	// InsertRoomRestriction creates a room restriction record.
 	InsertRoomRestriction(r models.RoomRestriction) error
 
+	// CreateReservation inserts a reservation and the room restriction that
+	// books its dates in one transaction, returning the new reservation ID.
+	// A non-empty idempotencyKey is claimed in the same transaction; if
+	// another request already holds it, nothing is stored and the ID of that
+	// request's reservation is returned with ErrIdempotencyKeyUsed.
+	CreateReservation(res models.Reservation, idempotencyKey string) (int, error)
+
 	// SearchAvailabilityByDatesByRoomID checks if a specific room is available for the given dates.
 	SearchAvailabilityByDatesByRoomID(start, end time.Time, roomID int) (bool, error)
 
@@ -35,37 +165,213 @@ type DatabaseRepo interface {
 	// UpdateUser modifies an existing user record.
 	UpdateUser(u models.User) error
 
+	// UpdatePassword hashes password with the configured bcrypt cost and stores it for the user.
+	UpdatePassword(id int, password string) error
+
 	// Authenticate verifies user credentials.
-	// Returns user ID and password hash on success.
+	// Returns user ID and password hash on success. Hashes below the configured
+	// bcrypt cost are upgraded in place.
 	Authenticate(email, testPassword string) (int, string, error)
 
-	// AllReservations retrieves all reservation records.
-	AllReservations() ([]models.Reservation, error)
+	// GetUserByEmail retrieves a user by email address, case-insensitively.
+	// Returns ErrUserNotFound when no user has that email.
+	GetUserByEmail(email string) (models.User, error)
+
+	// CreateUser inserts a user with plainPassword hashed at the configured
+	// bcrypt cost and returns the new ID. Returns ErrDuplicateEmail when the
+	// email is already registered.
+	CreateUser(u models.User, plainPassword string) (int, error)
 
-	// AllNewReservations retrieves unprocessed reservation records.
-	AllNewReservations() ([]models.Reservation, error)
+	// CreateResetToken issues a single-use password reset token for userID,
+	// valid for the configured lifetime, and returns it. Earlier unused
+	// tokens for the user stop working.
+	CreateResetToken(userID int) (string, error)
+
+	// ConsumeResetToken marks token used and returns its user ID.
+	// Returns ErrInvalidResetToken when the token is unknown, expired or used.
+	ConsumeResetToken(token string) (int, error)
+
+	// SearchReservations returns the reservations matching filter.Status, with
+	// their room names, in filter.Sort order.
+	SearchReservations(filter ReservationFilter) ([]models.Reservation, error)
 
 	// GetReservationByID retrieves a reservation by its ID.
+	// Returns ErrReservationNotFound when no reservation has that ID.
 	GetReservationByID(id int) (models.Reservation, error)
 
-	// UpdateReservation modifies an existing reservation record.
+	// UpdateReservation modifies an existing reservation record if its
+	// updated_at still equals u.UpdatedAt; otherwise it returns ErrStaleUpdate.
 	UpdateReservation(u models.Reservation) error
 
+	// GetReservationRestriction returns the room restriction that holds the
+	// reservation's dates on the calendar. Wraps sql.ErrNoRows when the
+	// reservation has none.
+	GetReservationRestriction(reservationID int) (models.RoomRestriction, error)
+
+	// UpdateReservationDates moves a reservation and its room restriction
+	// (restrictionID) to start-end together, setting the reservation's total
+	// to the price of the new nights.
+	UpdateReservationDates(reservationID, restrictionID int, start, end time.Time, total int) error
+
 	// DeleteReservation removes a reservation record.
 	DeleteReservation(id int) error
 
+	// DeleteReservationsBatch removes several reservations and their room
+	// restrictions in one transaction. If any ID does not exist nothing is
+	// deleted and ErrReservationNotFound is returned.
+	DeleteReservationsBatch(ids []int) error
+
 	// UpdateProcessedForReservation updates the processed status of a reservation.
+	// Returns ErrInvalidProcessed unless processed is 0 or 1. It works through
+	// the status, which processed is derived from: pending becomes confirmed,
+	// confirmed returns to pending, and other statuses are unchanged.
 	UpdateProcessedForReservation(id, processed int) error
 
-	// AllRooms retrieves all room records.
+	// SetReservationStatus moves a reservation to status and updates the
+	// derived processed flag; cancelling also frees the reservation's dates by
+	// deleting its room restriction. Returns ErrInvalidStatus for an unknown status,
+	// ErrStatusTransition when the current status can't move to it, and
+	// ErrReservationNotFound when no reservation has the ID.
+	SetReservationStatus(id int, status string) error
+
+	// ArrivalsBetween retrieves reservations arriving in [start, end), with room
+	// names, ordered by guest last name then first name.
+	ArrivalsBetween(start, end time.Time) ([]models.Reservation, error)
+
+	// AllRooms retrieves all room records, including closed (inactive) rooms.
 	AllRooms() ([]models.Room, error)
 
+	// InsertRoom adds a room and returns its ID. Returns ErrDuplicateRoomName
+	// when another room has the same name.
+	InsertRoom(room models.Room) (int, error)
+
+	// UpdateRoom saves a room's name, nightly rate, capacity and lead time.
+	// Returns ErrDuplicateRoomName when another room has the same name and
+	// sql.ErrNoRows when the room does not exist.
+	UpdateRoom(room models.Room) error
+
+	// SetRoomActive opens (active) or closes a room. Closed rooms are never
+	// returned as available. Returns an error wrapping sql.ErrNoRows when no
+	// room has the ID.
+	SetRoomActive(id int, active bool) error
+
+	// ImagesForRoom returns the room's gallery images ordered by sort order,
+	// then ID.
+	ImagesForRoom(roomID int) ([]models.RoomImage, error)
+
+	// InsertRoomImage adds an image to a room's gallery and returns its ID.
+	InsertRoomImage(img models.RoomImage) (int, error)
+
+	// DeleteRoomImage removes an image from a room's gallery. It returns an
+	// error wrapping sql.ErrNoRows when the room has no image with that ID.
+	DeleteRoomImage(roomID, imageID int) error
+
 	// GetRestrictionsForRoomByDate retrieves room restrictions overlapping the given date range.
 	GetRestrictionsForRoomByDate(roomID int, start, end time.Time) ([]models.RoomRestriction, error)
 
-	// InsertBlockForRoom creates an owner block restriction for a room.
-	InsertBlockForRoom(id int, startDate time.Time) error
+	// GetRestrictionsForRoomBetween retrieves room restrictions that occupy the
+	// room at any instant from start up to end. Each restriction is taken to
+	// run from the configured check-in time on its start date to the
+	// check-out time on its end date.
+	GetRestrictionsForRoomBetween(roomID int, start, end time.Time) ([]models.RoomRestriction, error)
+
+	// GetRestrictionsForAllRoomsByDate retrieves restrictions overlapping the given date range
+	// for every room in one query, keyed by room ID.
+	GetRestrictionsForAllRoomsByDate(start, end time.Time) (map[int][]models.RoomRestriction, error)
+
+	// InsertBlockForRoom creates a single-day block restriction for a room with
+	// the given reason (restriction ID) and staff note.
+	InsertBlockForRoom(id int, startDate time.Time, restrictionID int, note string) error
 
 	// DeleteBlockByID removes a room restriction by its ID.
 	DeleteBlockByID(id int) error
+
+	// FindOverlappingRestrictions returns pairs of restrictions on the same room
+	// whose date ranges overlap.
+	FindOverlappingRestrictions() ([]models.RestrictionConflict, error)
+
+	// ReservationsByEmail returns every reservation made with the given guest email, newest first.
+	ReservationsByEmail(email string) ([]models.Reservation, error)
+
+	// ReservationExists reports whether a reservation with the same guest email
+	// (case-insensitive), room and dates is already stored.
+	ReservationExists(email string, roomID int, start, end time.Time) (bool, error)
+
+	// ExpireUnconfirmedReservations deletes reservations still unconfirmed that
+	// were created before olderThan, together with their room restrictions, and
+	// returns how many were deleted.
+	ExpireUnconfirmedReservations(olderThan time.Time) (int, error)
+
+	// NextAvailableDate returns the first night on or after from that the room
+	// is free. Returns ErrNoAvailability when every night in the next
+	// NextAvailableHorizonDays is reserved or blocked.
+	NextAvailableDate(roomID int, from time.Time) (time.Time, error)
+
+	// CreateHold keeps a room's nights from start to end for a guest until
+	// expiresAt and returns the hold's ID. Returns ErrDatesTaken when any of
+	// the nights is already reserved, blocked or held.
+	CreateHold(roomID int, start, end, expiresAt time.Time) (int, error)
+
+	// ReleaseHold removes a hold. Releasing a hold that has already expired
+	// or been released is not an error.
+	ReleaseHold(id int) error
+
+	// ExpireHolds removes every hold that expired at or before now and
+	// returns how many were removed.
+	ExpireHolds(now time.Time) (int, error)
+
+	// FindAvailableWindows returns the runs of free nights in the room during
+	// the calendar month starting at monthStart that are at least nights long,
+	// earliest first. A window may end on the first of the following month.
+	FindAvailableWindows(roomID int, monthStart time.Time, nights int) ([]models.DateRange, error)
+
+	// GetRateForDate returns a room's nightly rate in cents for one night,
+	// using a room_rates override when present and the room's base rate otherwise.
+	GetRateForDate(roomID int, date time.Time) (int, error)
+
+	// AddToWaitlist stores a guest's request to be told when dates free up.
+	AddToWaitlist(entry models.WaitlistEntry) error
+
+	// WaitlistForDates returns waitlist entries whose requested dates overlap the given range.
+	WaitlistForDates(start, end time.Time) ([]models.WaitlistEntry, error)
+
+	// RecordAudit stores an audit log entry for an action taken by userID.
+	RecordAudit(userID int, action, detail string) error
+
+	// RecentAudit returns up to limit audit log entries, newest first.
+	RecentAudit(limit int) ([]models.AuditEntry, error)
+
+	// LogSentMail records an attempt to send m and its outcome, one of
+	// models.MailStatusSent or models.MailStatusFailed.
+	LogSentMail(m models.MailData, status string) error
+
+	// RecentSentMail returns up to limit mail log entries, newest first.
+	RecentSentMail(limit int) ([]models.MailLogEntry, error)
+
+	// DeadLetterMail stores a message that could not be delivered in the
+	// failed-mail queue, with the error from the attempt.
+	DeadLetterMail(m models.MailData, sendErr string) error
+
+	// FailedMail returns the messages in the failed-mail queue, newest first.
+	FailedMail() ([]models.OutboundMail, error)
+
+	// RequeueMail removes a message from the failed-mail queue and returns it
+	// so the caller can send it again. Wraps sql.ErrNoRows for unknown IDs.
+	RequeueMail(id int) (models.MailData, error)
+
+	// BookingsPerDay returns the number of reservations made on each day in
+	// [start, end), oldest first. Days with no reservations are omitted.
+	BookingsPerDay(start, end time.Time) ([]models.DayCount, error)
+
+	// GetIdempotentResult returns the ID of the reservation created by the
+	// request that used key. Returns ErrIdempotencyKeyNotFound when the key
+	// has not been seen.
+	GetIdempotentResult(key string) (int, error)
+
+	// GetSetting returns the value saved for key.
+	// Returns ErrSettingNotFound when the key has never been set.
+	GetSetting(key string) (string, error)
+
+	// SetSetting saves value for key, replacing any previous value.
+	SetSetting(key, value string) error
 }
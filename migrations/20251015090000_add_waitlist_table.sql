@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE waitlist (
+    id SERIAL PRIMARY KEY,
+    first_name VARCHAR(255) DEFAULT '',
+    last_name VARCHAR(255) DEFAULT '',
+    email VARCHAR(255) NOT NULL,
+    phone VARCHAR(255) DEFAULT '',
+    start_date DATE NOT NULL,
+    end_date DATE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT chk_waitlist_dates CHECK (start_date < end_date)
+);
+
+CREATE INDEX IF NOT EXISTS idx_waitlist_start_end ON waitlist (start_date, end_date);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE waitlist;
+-- +goose StatementEnd
@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE rooms
+  ADD COLUMN nightly_rate INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE reservations
+  ADD COLUMN total INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE room_rates (
+    id SERIAL PRIMARY KEY,
+    room_id INTEGER NOT NULL REFERENCES rooms(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    rate_date DATE NOT NULL,
+    nightly_rate INTEGER NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT uq_room_rates_room_date UNIQUE (room_id, rate_date),
+    CONSTRAINT chk_room_rates_positive CHECK (nightly_rate >= 0)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE room_rates;
+
+ALTER TABLE reservations
+  DROP COLUMN IF EXISTS total;
+
+ALTER TABLE rooms
+  DROP COLUMN IF EXISTS nightly_rate;
+-- +goose StatementEnd
@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE room_restrictions
+  ADD COLUMN note TEXT NOT NULL DEFAULT '';
+
+INSERT INTO restrictions (id, restriction_name)
+SELECT 3, 'Maintenance'
+WHERE NOT EXISTS (
+  SELECT 1 FROM restrictions r WHERE r.id = 3 OR r.restriction_name = 'Maintenance'
+);
+
+SELECT setval(pg_get_serial_sequence('restrictions', 'id'), (SELECT max(id) FROM restrictions));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+UPDATE room_restrictions SET restriction_id = 2 WHERE restriction_id = 3;
+
+DELETE FROM restrictions
+WHERE restriction_name = 'Maintenance';
+
+ALTER TABLE room_restrictions
+  DROP COLUMN IF EXISTS note;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE rooms
+  ADD COLUMN max_guests INTEGER NOT NULL DEFAULT 2;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE rooms
+  DROP COLUMN IF EXISTS max_guests;
+-- +goose StatementEnd
@@ -0,0 +1,6 @@
+-- +goose NO TRANSACTION
+-- +goose Up
+CREATE UNIQUE INDEX CONCURRENTLY IF NOT EXISTS idx_users_email_unique ON users (lower(email));
+
+-- +goose Down
+DROP INDEX CONCURRENTLY IF EXISTS idx_users_email_unique;
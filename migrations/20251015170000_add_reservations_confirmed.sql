@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Existing and newly inserted reservations count as confirmed; a booking flow
+-- that requires guest confirmation inserts false until the guest confirms.
+ALTER TABLE reservations
+  ADD COLUMN confirmed BOOLEAN NOT NULL DEFAULT TRUE;
+
+CREATE INDEX reservations_unconfirmed_created_at_idx
+  ON reservations (created_at)
+  WHERE NOT confirmed;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS reservations_unconfirmed_created_at_idx;
+
+ALTER TABLE reservations
+  DROP COLUMN IF EXISTS confirmed;
+-- +goose StatementEnd
@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Null for reservations made before guests had to accept the terms.
+ALTER TABLE reservations
+  ADD COLUMN terms_accepted_at TIMESTAMP WITH TIME ZONE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE reservations
+  DROP COLUMN IF EXISTS terms_accepted_at;
+-- +goose StatementEnd
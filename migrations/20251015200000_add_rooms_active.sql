@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Inactive rooms are closed indefinitely: they keep their history and stay on
+-- the admin calendar but are never offered in availability searches.
+ALTER TABLE rooms
+  ADD COLUMN active BOOLEAN NOT NULL DEFAULT TRUE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE rooms
+  DROP COLUMN IF EXISTS active;
+-- +goose StatementEnd
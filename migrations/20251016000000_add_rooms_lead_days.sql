@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+-- lead_days overrides MIN_LEAD_DAYS for one room, e.g. a room that needs a
+-- day's notice for cleaning. NULL uses the site-wide setting.
+ALTER TABLE rooms
+  ADD COLUMN lead_days INTEGER NULL CHECK (lead_days >= 0);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE rooms
+  DROP COLUMN IF EXISTS lead_days;
+-- +goose StatementEnd
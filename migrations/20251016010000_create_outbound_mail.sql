@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+-- outbound_mail is the dead-letter queue for email: a message that could not
+-- be delivered is kept here in full, body included, with status 'failed' so
+-- staff can retry it from /admin/mail-failures.
+CREATE TABLE outbound_mail (
+    id SERIAL PRIMARY KEY,
+    recipient VARCHAR(255) NOT NULL,
+    sender VARCHAR(255) NOT NULL,
+    subject VARCHAR(255) NOT NULL DEFAULT '',
+    template VARCHAR(255) NOT NULL DEFAULT '',
+    content TEXT NOT NULL DEFAULT '',
+    status VARCHAR(32) NOT NULL,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbound_mail_status ON outbound_mail (status, created_at DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE outbound_mail;
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+-- status tracks a stay from booking to departure. processed is kept for
+-- compatibility and is 1 whenever status is past 'pending'.
+ALTER TABLE reservations
+  ADD COLUMN status VARCHAR(32) NOT NULL DEFAULT 'pending'
+  CHECK (status IN ('pending', 'confirmed', 'checked_in', 'checked_out', 'cancelled'));
+
+UPDATE reservations SET status = 'confirmed' WHERE processed = 1;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE reservations
+  DROP COLUMN IF EXISTS status;
+-- +goose StatementEnd
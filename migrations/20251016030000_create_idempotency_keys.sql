@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+-- idempotency_keys remembers the Idempotency-Key header of each processed
+-- POST /api/reservations request and the reservation it created, so a client
+-- retrying the same request gets the original reservation back instead of a
+-- second booking. Deleting the reservation deletes its key.
+CREATE TABLE idempotency_keys (
+    key VARCHAR(255) PRIMARY KEY,
+    reservation_id INTEGER NOT NULL REFERENCES reservations (id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE idempotency_keys;
+-- +goose StatementEnd
@@ -0,0 +1,48 @@
+-- +goose Up
+-- +goose StatementBegin
+-- A hold keeps a guest's chosen dates while they fill in the booking form.
+-- Holds are room_restrictions rows of restriction 4 with an expiry; rows of
+-- every other restriction leave hold_expires_at null.
+ALTER TABLE room_restrictions
+  ADD COLUMN hold_expires_at TIMESTAMP;
+
+-- The application refers to holds as restriction 4, so refuse to go on if
+-- that id, or the name 'Hold', already belongs to some other row.
+DO $$
+BEGIN
+  IF EXISTS (
+    SELECT 1 FROM restrictions
+    WHERE (id = 4) <> (restriction_name = 'Hold')
+  ) THEN
+    RAISE EXCEPTION 'restrictions: id 4 must be ''Hold''; found %',
+      (SELECT string_agg(id || '=' || restriction_name, ', ')
+       FROM restrictions WHERE id = 4 OR restriction_name = 'Hold');
+  END IF;
+END $$;
+
+INSERT INTO restrictions (id, restriction_name)
+SELECT 4, 'Hold'
+WHERE NOT EXISTS (SELECT 1 FROM restrictions r WHERE r.id = 4);
+
+SELECT setval(pg_get_serial_sequence('restrictions', 'id'), (SELECT max(id) FROM restrictions));
+
+CREATE INDEX room_restrictions_hold_expires_at_idx
+  ON room_restrictions (hold_expires_at)
+  WHERE hold_expires_at IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- Only touch restriction 4 if it is the hold row this migration added.
+DELETE FROM room_restrictions rr
+USING restrictions r
+WHERE rr.restriction_id = r.id AND r.id = 4 AND r.restriction_name = 'Hold';
+
+DELETE FROM restrictions
+WHERE id = 4 AND restriction_name = 'Hold';
+
+DROP INDEX IF EXISTS room_restrictions_hold_expires_at_idx;
+
+ALTER TABLE room_restrictions
+  DROP COLUMN IF EXISTS hold_expires_at;
+-- +goose StatementEnd
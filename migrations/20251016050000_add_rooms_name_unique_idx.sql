@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Room names are display labels, so two rooms may not share one. Names are
+-- compared case-insensitively, with surrounding whitespace trimmed and inner
+-- runs of whitespace collapsed to one space.
+CREATE UNIQUE INDEX rooms_room_name_normalized_idx
+  ON rooms (lower(regexp_replace(btrim(room_name), '\s+', ' ', 'g')));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS rooms_room_name_normalized_idx;
+-- +goose StatementEnd
@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+-- status is the single record of where a reservation stands; a reservation
+-- nobody has confirmed is one still 'pending', so the separate confirmed flag
+-- (which nothing ever set to false) goes.
+DROP INDEX IF EXISTS reservations_unconfirmed_created_at_idx;
+
+ALTER TABLE reservations
+  DROP COLUMN IF EXISTS confirmed;
+
+CREATE INDEX reservations_pending_created_at_idx
+  ON reservations (created_at)
+  WHERE status = 'pending';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS reservations_pending_created_at_idx;
+
+ALTER TABLE reservations
+  ADD COLUMN confirmed BOOLEAN NOT NULL DEFAULT TRUE;
+
+CREATE INDEX reservations_unconfirmed_created_at_idx
+  ON reservations (created_at)
+  WHERE NOT confirmed;
+-- +goose StatementEnd